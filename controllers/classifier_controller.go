@@ -31,8 +31,8 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
+	"github.com/projectsveltos/classifier-agent/internal/scope"
 	"github.com/projectsveltos/classifier-agent/pkg/classification"
-	"github.com/projectsveltos/classifier-agent/pkg/scope"
 	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
 	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
 	libsveltosset "github.com/projectsveltos/libsveltos/lib/set"
@@ -59,6 +59,12 @@ type ClassifierReconciler struct {
 	GVKClassifiers map[schema.GroupVersionKind]*libsveltosset.Set
 	// List of Classifier instances based on Kubernetes version
 	VersionClassifiers libsveltosset.Set
+	// ManagerOptions are passed to classification manager
+	ManagerOptions []classification.Option
+	// RemoteClassifiers indicates Classifiers are fetched from the management
+	// cluster. Classifier CRD is then not required in the managed cluster and
+	// no controller is started for it.
+	RemoteClassifiers bool
 }
 
 //+kubebuilder:rbac:groups=lib.projectsveltos.io,resources=classifiers,verbs=get;list;watch;create;update;patch;delete
@@ -67,6 +73,11 @@ type ClassifierReconciler struct {
 //+kubebuilder:rbac:groups=lib.projectsveltos.io,resources=classifierreports,verbs=get;list;create;update;delete;patch
 //+kubebuilder:rbac:groups=lib.projectsveltos.io,resources=classifierreports/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=*,resources=*,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch;create;update;patch
+//+kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups=coordination.k8s.io,resources=leases,verbs=get;create;update
 
 func (r *ClassifierReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, reterr error) {
 	logger := ctrl.LoggerFrom(ctx)
@@ -116,20 +127,27 @@ func (r *ClassifierReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 
 	// Handle deleted classifier
 	if !classifier.DeletionTimestamp.IsZero() {
-		return r.reconcileDelete(classifierScope, logger)
+		return r.reconcileDelete(ctx, classifierScope, logger)
 	}
 
 	// Handle non-deleted classifier
 	return r.reconcileNormal(ctx, classifierScope, logger)
 }
 
-func (r *ClassifierReconciler) reconcileDelete(
+func (r *ClassifierReconciler) reconcileDelete(ctx context.Context,
 	classifierScope *scope.ClassifierScope,
 	logger logr.Logger,
 ) (reconcile.Result, error) {
 
 	logger.V(logs.LogDebug).Info("reconcile delete")
 
+	// Once finalizer is removed, Classifier is gone and actions cannot be undone anymore
+	manager := classification.GetManager()
+	if err := manager.UndoActions(ctx, classifierScope.Classifier); err != nil {
+		logger.Error(err, "failed to undo actions")
+		return reconcile.Result{}, err
+	}
+
 	logger.V(logs.LogDebug).Info("remove classifier from maps")
 	policyRef := getKeyFromObject(r.Scheme, classifierScope.Classifier)
 
@@ -143,7 +161,6 @@ func (r *ClassifierReconciler) reconcileDelete(
 	}
 
 	// Queue Classifier for evaluation
-	manager := classification.GetManager()
 	manager.EvaluateClassifier(classifierScope.Name())
 
 	if controllerutil.ContainsFinalizer(classifierScope.Classifier, libsveltosv1alpha1.ClassifierFinalizer) {
@@ -181,11 +198,13 @@ func (r *ClassifierReconciler) reconcileNormal(ctx context.Context,
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *ClassifierReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manager) error {
-	_, err := ctrl.NewControllerManagedBy(mgr).
-		For(&libsveltosv1alpha1.Classifier{}).
-		Build(r)
-	if err != nil {
-		return errors.Wrap(err, "error creating controller")
+	if !r.RemoteClassifiers {
+		_, err := ctrl.NewControllerManagedBy(mgr).
+			For(&libsveltosv1alpha1.Classifier{}).
+			Build(r)
+		if err != nil {
+			return errors.Wrap(err, "error creating controller")
+		}
 	}
 
 	sendReport := false
@@ -193,30 +212,25 @@ func (r *ClassifierReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Ma
 		sendReport = true
 	}
 	const intervalInSecond = 10
+	options := append([]classification.Option{
+		classification.WithEventRecorder(mgr.GetEventRecorderFor("classifier-agent")),
+	}, r.ManagerOptions...)
 	classification.InitializeManager(ctx, mgr.GetLogger(),
 		mgr.GetConfig(), mgr.GetClient(), r.ClusterNamespace, r.ClusterName, r.ClusterType,
-		r.react, intervalInSecond, sendReport)
+		r.react, intervalInSecond, sendReport, options...)
 
 	return nil
 }
 
 func (r *ClassifierReconciler) updateMaps(classifier *libsveltosv1alpha1.Classifier) {
-	gvks := make([]schema.GroupVersionKind, len(classifier.Spec.DeployedResourceConstraints))
-	for i := range classifier.Spec.DeployedResourceConstraints {
-		gvk := schema.GroupVersionKind{
-			Group:   classifier.Spec.DeployedResourceConstraints[i].Group,
-			Version: classifier.Spec.DeployedResourceConstraints[i].Version,
-			Kind:    classifier.Spec.DeployedResourceConstraints[i].Kind,
-		}
-		gvks[i] = gvk
-	}
+	gvks := classification.GetClassifierGVKs(classifier)
 
 	policyRef := getKeyFromObject(r.Scheme, classifier)
 
 	r.Mux.Lock()
 	defer r.Mux.Unlock()
 
-	if classifier.Spec.KubernetesVersionConstraints != nil {
+	if classification.HasKubernetesVersionConstraints(classifier) {
 		r.VersionClassifiers.Insert(policyRef)
 	}
 
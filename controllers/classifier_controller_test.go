@@ -23,13 +23,16 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/klog/v2/klogr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/projectsveltos/classifier-agent/controllers"
+	"github.com/projectsveltos/classifier-agent/internal/scope"
 	"github.com/projectsveltos/classifier-agent/pkg/classification"
-	"github.com/projectsveltos/classifier-agent/pkg/scope"
 	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
 	libsveltosset "github.com/projectsveltos/libsveltos/lib/set"
 )
@@ -41,7 +44,8 @@ var _ = Describe("Controllers: node controller", func() {
 	BeforeEach(func() {
 		watcherCtx, cancel = context.WithCancel(context.Background())
 		classification.InitializeManager(watcherCtx, klogr.New(), testEnv.Config, testEnv.Client,
-			randomString(), randomString(), libsveltosv1alpha1.ClusterTypeCapi, nil, 10, false)
+			randomString(), randomString(), libsveltosv1alpha1.ClusterTypeCapi, nil, 10, false,
+			classification.WithAllowedActions([]classification.ActionType{classification.ActionTypeLabel}))
 	})
 
 	AfterEach(func() {
@@ -138,7 +142,7 @@ var _ = Describe("Controllers: node controller", func() {
 		})
 		Expect(err).To(BeNil())
 
-		_, err = controllers.ReconcileDelete(reconciler, classifierScope, klogr.New())
+		_, err = controllers.ReconcileDelete(reconciler, context.TODO(), classifierScope, klogr.New())
 		Expect(err).To(BeNil())
 		Expect(reconciler.VersionClassifiers.Len()).To(Equal(0))
 	})
@@ -175,9 +179,60 @@ var _ = Describe("Controllers: node controller", func() {
 		})
 		Expect(err).To(BeNil())
 
-		_, err = controllers.ReconcileDelete(reconciler, classifierScope, klogr.New())
+		_, err = controllers.ReconcileDelete(reconciler, context.TODO(), classifierScope, klogr.New())
 		Expect(err).To(BeNil())
 		Expect(reconciler.VersionClassifiers.Len()).To(Equal(0))
 		Expect(reconciler.GVKClassifiers[gvk].Len()).To(Equal(0))
 	})
+
+	It("reconcileDelete removes labels added by Classifier actions", func() {
+		key := randomString()
+		value := randomString()
+
+		node := &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   randomString(),
+				Labels: map[string]string{key: value},
+			},
+		}
+		Expect(testEnv.Create(watcherCtx, node)).To(Succeed())
+		Expect(waitForObject(watcherCtx, testEnv.Client, node)).To(Succeed())
+
+		classifier := getClassifierWithKubernetesConstraints()
+		classifier.Annotations = map[string]string{
+			classification.ClassifierExtensionAnnotation: "actions:\n- target: Nodes\n  labels:\n    " +
+				key + ": " + value,
+		}
+		Expect(testEnv.Create(watcherCtx, classifier)).To(Succeed())
+		Expect(waitForObject(watcherCtx, testEnv.Client, classifier)).To(Succeed())
+
+		reconciler := &controllers.ClassifierReconciler{
+			Client:             testEnv.Client,
+			Scheme:             scheme,
+			Mux:                sync.RWMutex{},
+			GVKClassifiers:     make(map[schema.GroupVersionKind]*libsveltosset.Set),
+			VersionClassifiers: libsveltosset.Set{},
+		}
+
+		classifierScope, err := scope.NewClassifierScope(scope.ClassifierScopeParams{
+			Client:         testEnv.Client,
+			Logger:         klogr.New(),
+			Classifier:     classifier,
+			ControllerName: "classifier",
+		})
+		Expect(err).To(BeNil())
+
+		_, err = controllers.ReconcileDelete(reconciler, context.TODO(), classifierScope, klogr.New())
+		Expect(err).To(BeNil())
+
+		Eventually(func() bool {
+			currentNode := &corev1.Node{}
+			err := testEnv.Get(context.TODO(), client.ObjectKeyFromObject(node), currentNode)
+			if err != nil {
+				return false
+			}
+			_, ok := currentNode.Labels[key]
+			return !ok
+		}, timeout, pollingInterval).Should(BeTrue())
+	})
 })
@@ -17,7 +17,8 @@ limitations under the License.
 package controllers
 
 var (
-	FindClassifierUsingKubernetesVersion = (*NodeReconciler).findClassifierUsingKubernetesVersion
+	FindClassifierUsingKubernetesVersion  = (*NodeReconciler).findClassifierUsingKubernetesVersion
+	FindClassifierUsingKubeletVersionSkew = (*NodeReconciler).findClassifierUsingKubeletVersionSkew
 )
 
 func GetKubernetesVersion(r *NodeReconciler) string {
@@ -31,7 +31,6 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"github.com/projectsveltos/classifier-agent/pkg/classification"
-	"github.com/projectsveltos/classifier-agent/pkg/utils"
 	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
 	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
 )
@@ -44,6 +43,10 @@ type NodeReconciler struct {
 
 	// kubernetesVersion is the current Kubernetes version
 	kubernetesVersion string
+
+	// kubeletVersions contains the kubelet version of each node
+	// Key: node name
+	kubeletVersions map[string]string
 }
 
 //+kubebuilder:rbac:groups=projectsveltos.io,resources=nodes,verbs=get;list;watch;create;update;patch;delete
@@ -59,6 +62,13 @@ func (r *NodeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 	err := r.Get(ctx, req.NamespacedName, node)
 	if err != nil {
 		if apierrors.IsNotFound(err) {
+			// Removing a node can change kubelet version skew
+			if _, ok := r.kubeletVersions[req.Name]; ok {
+				if err := r.evaluateClassifiersUsingKubeletVersionSkew(ctx, logger); err != nil {
+					return reconcile.Result{}, err
+				}
+				delete(r.kubeletVersions, req.Name)
+			}
 			return reconcile.Result{}, nil
 		}
 		logger.Error(err, "Failed to fetch Node")
@@ -71,7 +81,13 @@ func (r *NodeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 
 	logger = logger.WithValues("node", node.Name)
 
-	version, err := utils.GetKubernetesVersion(ctx, r.Config, logger)
+	// Make sure node, new ones included, has labels mirroring classification
+	if err := classification.GetManager().SyncNodeLabels(ctx, node.Name); err != nil {
+		logger.V(logs.LogInfo).Info(fmt.Sprintf("failed to update node labels: %v", err))
+		return reconcile.Result{}, err
+	}
+
+	version, err := classification.GetManager().GetKubernetesVersion(ctx)
 	if err != nil {
 		return reconcile.Result{}, nil
 	}
@@ -93,6 +109,18 @@ func (r *NodeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 		r.kubernetesVersion = version
 	}
 
+	kubeletVersion := node.Status.NodeInfo.KubeletVersion
+	if kubeletVersion != r.kubeletVersions[node.Name] {
+		if err := r.evaluateClassifiersUsingKubeletVersionSkew(ctx, logger); err != nil {
+			return reconcile.Result{}, err
+		}
+
+		if r.kubeletVersions == nil {
+			r.kubeletVersions = make(map[string]string)
+		}
+		r.kubeletVersions[node.Name] = kubeletVersion
+	}
+
 	logger.V(logs.LogInfo).Info("reconciliation succeeded")
 	return ctrl.Result{}, nil
 }
@@ -107,8 +135,43 @@ func (r *NodeReconciler) SetupWithManager(mgr ctrl.Manager) error {
 func (r *NodeReconciler) findClassifierUsingKubernetesVersion(ctx context.Context,
 	logger logr.Logger) ([]string, error) {
 
-	classifierList := &libsveltosv1alpha1.ClassifierList{}
-	err := r.List(ctx, classifierList)
+	return r.findClassifiers(ctx, classification.HasKubernetesVersionConstraints)
+}
+
+func (r *NodeReconciler) findClassifierUsingKubeletVersionSkew(ctx context.Context,
+	logger logr.Logger) ([]string, error) {
+
+	return r.findClassifiers(ctx, classification.HasKubeletVersionSkewConstraint)
+}
+
+// evaluateClassifiersUsingKubeletVersionSkew queues for re-evaluation all Classifiers
+// depending on node kubelet versions
+func (r *NodeReconciler) evaluateClassifiersUsingKubeletVersionSkew(ctx context.Context,
+	logger logr.Logger) error {
+
+	list, err := r.findClassifierUsingKubeletVersionSkew(ctx, logger)
+	if err != nil {
+		return err
+	}
+
+	manager := classification.GetManager()
+	for i := range list {
+		logger.V(logs.LogDebug).Info(fmt.Sprintf("classifier %s needs re-evaluation",
+			list[i]))
+		manager.EvaluateClassifier(list[i])
+	}
+
+	return nil
+}
+
+// findClassifiers returns the names of all Classifiers for which filter returns true
+func (r *NodeReconciler) findClassifiers(ctx context.Context,
+	filter func(classifier *libsveltosv1alpha1.Classifier) bool) ([]string, error) {
+
+	// Classifiers are listed through classification manager as, in remote mode,
+	// those are fetched from the management cluster
+	manager := classification.GetManager()
+	classifierList, err := manager.ListClassifiers(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -117,7 +180,7 @@ func (r *NodeReconciler) findClassifierUsingKubernetesVersion(ctx context.Contex
 
 	for i := range classifierList.Items {
 		classifier := &classifierList.Items[i]
-		if len(classifier.Spec.KubernetesVersionConstraints) > 0 {
+		if filter(classifier) {
 			result = append(result, classifier.Name)
 		}
 	}
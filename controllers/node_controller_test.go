@@ -73,6 +73,29 @@ var _ = Describe("Controllers: node controller", func() {
 		Expect(classifiers).ToNot(ContainElement(classifier2.Name))
 	})
 
+	It("findClassifierUsingKubeletVersionSkew returns classifiers using kubelet version skew", func() {
+		classifier1 := getClassifierWithResourceConstraints()
+		classifier1.Annotations = map[string]string{
+			classification.ClassifierExtensionAnnotation: "kubeletVersionSkew:\n  minorVersions: 1",
+		}
+		Expect(testEnv.Create(watcherCtx, classifier1)).To(Succeed())
+		Expect(waitForObject(watcherCtx, testEnv.Client, classifier1)).To(Succeed())
+
+		classifier2 := getClassifierWithKubernetesConstraints()
+		Expect(testEnv.Create(watcherCtx, classifier2)).To(Succeed())
+		Expect(waitForObject(watcherCtx, testEnv.Client, classifier2)).To(Succeed())
+
+		reconciler := &controllers.NodeReconciler{
+			Client: testEnv.Client,
+			Scheme: scheme,
+		}
+
+		classifiers, err := controllers.FindClassifierUsingKubeletVersionSkew(reconciler, watcherCtx, klogr.New())
+		Expect(err).To(BeNil())
+		Expect(classifiers).To(ContainElement(classifier1.Name))
+		Expect(classifiers).ToNot(ContainElement(classifier2.Name))
+	})
+
 	It("findClassifierUsingKubernetesVersion returns classifiers using Kubernetes version", func() {
 		node := getControlPlaneNode()
 		Expect(testEnv.Create(watcherCtx, node)).To(Succeed())
@@ -85,7 +108,8 @@ var _ = Describe("Controllers: node controller", func() {
 		Expect(testEnv.Status().Update(watcherCtx, &currentNode)).To(Succeed())
 
 		classification.InitializeManager(watcherCtx, klogr.New(), testEnv.Config, testEnv.Client,
-			randomString(), randomString(), libsveltosv1alpha1.ClusterTypeCapi, nil, 10, false)
+			randomString(), randomString(), libsveltosv1alpha1.ClusterTypeCapi, nil, 10, false,
+			classification.WithAllowedActions([]classification.ActionType{classification.ActionTypeLabel}))
 
 		reconciler := &controllers.NodeReconciler{
 			Client: testEnv.Client,
@@ -0,0 +1,137 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+)
+
+const (
+	// Namespace where reports will be generated
+	ReportNamespace = "projectsveltos"
+
+	// NamespaceCreatedByAnnotation is set on a namespace created by classifier-agent.
+	// Uninstall tooling removes namespaces carrying it.
+	NamespaceCreatedByAnnotation = "projectsveltos.io/created-by"
+
+	// NamespaceManagedByLabel is set on a namespace created by classifier-agent, so
+	// those can be selected by label
+	NamespaceManagedByLabel = "app.kubernetes.io/managed-by"
+
+	// NamespaceOwner is the value of NamespaceCreatedByAnnotation and NamespaceManagedByLabel
+	NamespaceOwner = "classifier-agent"
+
+	// podSecurityEnforceLabel sets the PodSecurity admission enforce level of a namespace
+	podSecurityEnforceLabel = "pod-security.kubernetes.io/enforce"
+)
+
+// ValidatePodSecurityLevel returns an error if level is not a Pod Security Standards level
+func ValidatePodSecurityLevel(level string) error {
+	switch level {
+	case "privileged", "baseline", "restricted":
+		return nil
+	default:
+		return fmt.Errorf("invalid pod security level %q: must be privileged, baseline or restricted", level)
+	}
+}
+
+// NamespaceOptions configures the namespace created by a NamespaceManager
+type NamespaceOptions struct {
+	// Labels are set on the namespace when created
+	Labels map[string]string
+
+	// PodSecurityLevel, when set, is the PodSecurity admission enforce level
+	// of the namespace when created
+	PodSecurityLevel string
+}
+
+// NamespaceManager makes sure the namespace classifier-agent writes to exists
+type NamespaceManager struct {
+	client.Client
+	name    string
+	options NamespaceOptions
+}
+
+// NewReportNamespaceManager returns a NamespaceManager for ReportNamespace
+func NewReportNamespaceManager(c client.Client, options NamespaceOptions) *NamespaceManager {
+	return &NamespaceManager{Client: c, name: ReportNamespace, options: options}
+}
+
+// Name returns the name of the namespace
+func (n *NamespaceManager) Name() string {
+	return n.name
+}
+
+// EnsureNamespace creates the namespace if it does not exist yet. An existing
+// namespace is left untouched.
+func (n *NamespaceManager) EnsureNamespace(ctx context.Context, logger logr.Logger) error {
+	logger = logger.WithValues("namespace", n.name)
+
+	ns := &corev1.Namespace{}
+	err := n.Get(ctx, types.NamespacedName{Name: n.name}, ns)
+	if err == nil {
+		logger.V(logs.LogDebug).Info("namespace already exists")
+		return nil
+	}
+	if !apierrors.IsNotFound(err) {
+		logger.V(logs.LogInfo).Info(fmt.Sprintf("failed to get namespace: %v", err))
+		return err
+	}
+
+	err = n.Create(ctx, n.getNamespace())
+	if err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			return nil
+		}
+		logger.V(logs.LogInfo).Info(fmt.Sprintf("failed to create namespace: %v", err))
+		return err
+	}
+
+	logger.V(logs.LogInfo).Info("created namespace")
+	return nil
+}
+
+func (n *NamespaceManager) getNamespace() *corev1.Namespace {
+	labels := make(map[string]string, len(n.options.Labels)+2)
+	for k, v := range n.options.Labels {
+		labels[k] = v
+	}
+	if n.options.PodSecurityLevel != "" {
+		labels[podSecurityEnforceLabel] = n.options.PodSecurityLevel
+	}
+	labels[NamespaceManagedByLabel] = NamespaceOwner
+
+	return &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   n.name,
+			Labels: labels,
+			Annotations: map[string]string{
+				NamespaceCreatedByAnnotation: NamespaceOwner,
+			},
+		},
+	}
+}
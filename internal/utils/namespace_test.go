@@ -0,0 +1,67 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2/klogr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/projectsveltos/classifier-agent/internal/utils"
+)
+
+var _ = Describe("NamespaceManager", func() {
+	It("EnsureNamespace creates and labels the report namespace", func() {
+		c := fake.NewClientBuilder().WithScheme(scheme).Build()
+		manager := utils.NewReportNamespaceManager(c, utils.NamespaceOptions{
+			Labels:           map[string]string{"team": "platform"},
+			PodSecurityLevel: "restricted",
+		})
+		Expect(manager.EnsureNamespace(context.TODO(), klogr.New())).To(Succeed())
+
+		ns := &corev1.Namespace{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: utils.ReportNamespace}, ns)).To(Succeed())
+		Expect(ns.Labels).To(HaveKeyWithValue("team", "platform"))
+		Expect(ns.Labels).To(HaveKeyWithValue("pod-security.kubernetes.io/enforce", "restricted"))
+		Expect(ns.Labels).To(HaveKeyWithValue(utils.NamespaceManagedByLabel, utils.NamespaceOwner))
+		Expect(ns.Annotations).To(HaveKeyWithValue(utils.NamespaceCreatedByAnnotation, utils.NamespaceOwner))
+	})
+
+	It("EnsureNamespace leaves an existing namespace untouched", func() {
+		existing := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: utils.ReportNamespace}}
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(existing).Build()
+		manager := utils.NewReportNamespaceManager(c, utils.NamespaceOptions{PodSecurityLevel: "baseline"})
+		Expect(manager.EnsureNamespace(context.TODO(), klogr.New())).To(Succeed())
+
+		ns := &corev1.Namespace{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: utils.ReportNamespace}, ns)).To(Succeed())
+		Expect(ns.Labels).To(BeEmpty())
+		Expect(ns.Annotations).To(BeEmpty())
+	})
+
+	It("ValidatePodSecurityLevel accepts Pod Security Standards levels only", func() {
+		Expect(utils.ValidatePodSecurityLevel("baseline")).To(Succeed())
+		Expect(utils.ValidatePodSecurityLevel("strict")).ToNot(Succeed())
+	})
+})
@@ -1,5 +1,5 @@
 /*
-Copyright 2022. projectsveltos.io. All rights reserved.
+Copyright 2022.
 
 Licensed under the Apache License, Version 2.0 (the "License");
 you may not use this file except in compliance with the License.
@@ -27,11 +27,6 @@ import (
 	"k8s.io/client-go/rest"
 )
 
-const (
-	// Namespace where reports will be generated
-	ReportNamespace = "projectsveltos"
-)
-
 func GetKubernetesVersion(ctx context.Context, cfg *rest.Config, logger logr.Logger) (string, error) {
 	discClient, err := discovery.NewDiscoveryClientForConfig(cfg)
 	if err != nil {
@@ -24,7 +24,7 @@ import (
 
 	"k8s.io/klog/v2/klogr"
 
-	"github.com/projectsveltos/classifier-agent/pkg/utils"
+	"github.com/projectsveltos/classifier-agent/internal/utils"
 )
 
 var _ = Describe("Manager", func() {
@@ -17,21 +17,28 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
+	"io"
 	"os"
 	"sync"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 	cliflag "k8s.io/component-base/cli/flag"
 	"k8s.io/klog/v2"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 
 	"github.com/projectsveltos/classifier-agent/controllers"
+	"github.com/projectsveltos/classifier-agent/internal/utils"
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
 	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
 	"github.com/projectsveltos/libsveltos/lib/logsettings"
 	libsveltosset "github.com/projectsveltos/libsveltos/lib/set"
@@ -43,14 +50,7 @@ const (
 )
 
 var (
-	setupLog             = ctrl.Log.WithName("setup")
-	metricsAddr          string
-	enableLeaderElection bool
-	probeAddr            string
-	runMode              string
-	clusterNamespace     string
-	clusterName          string
-	clusterType          string
+	setupLog = ctrl.Log.WithName("setup")
 )
 
 func main() {
@@ -61,25 +61,44 @@ func main() {
 
 	klog.InitFlags(nil)
 
-	initFlags(pflag.CommandLine)
+	options := &agentOptions{}
+	os.Args = options.parseCommand(os.Args)
+
+	options.addFlags(pflag.CommandLine)
 	pflag.CommandLine.SetNormalizeFunc(cliflag.WordSepNormalizeFunc)
 	pflag.CommandLine.AddGoFlagSet(flag.CommandLine)
 	pflag.Parse()
 
 	ctrl.SetLogger(klog.Background())
 
+	if err := options.Validate(); err != nil {
+		setupLog.Error(err, "invalid options")
+		os.Exit(1)
+	}
+
 	ctx := ctrl.SetupSignalHandler()
 
+	if options.selfTest {
+		os.Exit(runSelfTest(ctx, scheme, options))
+	}
+
+	if options.createReportNs {
+		if err := ensureReportNamespace(ctx, scheme, options); err != nil {
+			setupLog.Error(err, "unable to create report namespace", "namespace", utils.ReportNamespace)
+			os.Exit(1)
+		}
+	}
+
 	logsettings.RegisterForLogSettings(ctx,
 		libsveltosv1alpha1.ComponentClassifierAgent, ctrl.Log.WithName("log-setter"),
 		ctrl.GetConfigOrDie())
 
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
-		MetricsBindAddress:     metricsAddr,
+		MetricsBindAddress:     options.metricsAddr,
 		Port:                   9443,
-		HealthProbeBindAddress: probeAddr,
-		LeaderElection:         enableLeaderElection,
+		HealthProbeBindAddress: options.probeAddr,
+		LeaderElection:         options.enableLeaderElection,
 		LeaderElectionID:       "e8afc439.projectsveltos.io",
 		// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
 		// when the Manager ends. This requires the binary to immediately end when the
@@ -99,7 +118,7 @@ func main() {
 	}
 
 	sendReports := controllers.SendReports // do not send reports
-	if runMode == noReports {
+	if options.runMode == noReports {
 		sendReports = controllers.DoNotSendReports
 	}
 
@@ -112,9 +131,11 @@ func main() {
 		Mux:                sync.RWMutex{},
 		GVKClassifiers:     make(map[schema.GroupVersionKind]*libsveltosset.Set),
 		VersionClassifiers: libsveltosset.Set{},
-		ClusterNamespace:   clusterNamespace,
-		ClusterName:        clusterName,
-		ClusterType:        libsveltosv1alpha1.ClusterType(clusterType),
+		ClusterNamespace:   options.clusterNamespace,
+		ClusterName:        options.clusterName,
+		ClusterType:        libsveltosv1alpha1.ClusterType(options.clusterType),
+		ManagerOptions:     options.getManagerOptions(),
+		RemoteClassifiers:  options.remoteInterval > 0,
 	}).SetupWithManager(ctx, mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Classifier")
 		os.Exit(1)
@@ -131,55 +152,88 @@ func main() {
 
 	setupChecks(mgr)
 
+	var dumpResult chan error
+	if options.dumpPath != "" {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		dumpResult = make(chan error, 1)
+		go func() {
+			dumpResult <- dumpClassification(ctx, options)
+			// Classification has been dumped. Stop the manager.
+			cancel()
+		}()
+	}
+
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctx); err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
+
+	if dumpResult != nil {
+		if err := <-dumpResult; err != nil {
+			setupLog.Error(err, "failed to dump classification")
+			os.Exit(1)
+		}
+	}
+}
+
+// runSelfTest verifies the environment, writes the report to stdout and
+// returns the exit code
+func runSelfTest(ctx context.Context, scheme *runtime.Scheme, options *agentOptions) int {
+	config := ctrl.GetConfigOrDie()
+	c, err := client.New(config, client.Options{Scheme: scheme})
+	if err != nil {
+		setupLog.Error(err, "unable to create client")
+		return 1
+	}
+
+	report := classification.RunSelfTest(ctx, config, c, ctrl.Log.WithName("self-test"),
+		classification.SelfTestOptions{SendReports: options.runMode != noReports})
+	if err := classification.WriteSelfTestReport(os.Stdout, report); err != nil {
+		setupLog.Error(err, "failed to write self-test report")
+		return 1
+	}
+	if !report.Passed {
+		return 1
+	}
+	return 0
 }
 
-func initFlags(fs *pflag.FlagSet) {
-	fs.StringVar(&metricsAddr,
-		"metrics-bind-address",
-		":8080",
-		"The address the metric endpoint binds to.")
-
-	fs.StringVar(&probeAddr,
-		"health-probe-bind-address",
-		":8081",
-		"The address the probe endpoint binds to.")
-
-	flag.StringVar(
-		&runMode,
-		"run-mode",
-		noReports,
-		"indicates whether reports will be sent to management cluster or just created locally",
-	)
-
-	flag.StringVar(
-		&clusterNamespace,
-		"cluster-namespace",
-		"",
-		"cluster namespace",
-	)
-
-	flag.StringVar(
-		&clusterName,
-		"cluster-name",
-		"",
-		"cluster name",
-	)
-
-	flag.StringVar(
-		&clusterType,
-		"cluster-type",
-		"",
-		"cluster type",
-	)
-
-	fs.BoolVar(&enableLeaderElection, "leader-elect", false,
-		"Enable leader election for controller manager. "+
-			"Enabling this will ensure there is only one active controller manager.")
+// ensureReportNamespace creates the namespace reports are generated in, if missing
+func ensureReportNamespace(ctx context.Context, scheme *runtime.Scheme, options *agentOptions) error {
+	c, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+	if err != nil {
+		return err
+	}
+
+	namespaceManager := utils.NewReportNamespaceManager(c, utils.NamespaceOptions{
+		Labels:           options.reportNsLabels,
+		PodSecurityLevel: options.reportNsPodSecurity,
+	})
+	return namespaceManager.EnsureNamespace(ctx, ctrl.Log.WithName("report-namespace"))
+}
+
+// dumpClassification waits till all Classifiers have been evaluated and writes
+// the classification to dump-classification path, or to stdout if path is "-"
+func dumpClassification(ctx context.Context, options *agentOptions) error {
+	const pollInterval = time.Second
+	state, err := classification.WaitForClassification(ctx, pollInterval)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if options.dumpPath != "-" {
+		f, err := os.Create(options.dumpPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	return classification.WriteClassificationState(w, state, classification.ClassificationStateFormat(options.dumpFormat))
 }
 
 func setupChecks(mgr ctrl.Manager) {
@@ -191,4 +245,25 @@ func setupChecks(mgr ctrl.Manager) {
 		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
+	// Evaluation history is served by the metrics server
+	if err := mgr.AddMetricsExtraHandler(classification.EvaluationHistoryPath,
+		classification.EvaluationHistoryHandler()); err != nil {
+		setupLog.Error(err, "unable to set up evaluation history endpoint")
+		os.Exit(1)
+	}
+	if err := mgr.AddMetricsExtraHandler(classification.ClassificationStatePath,
+		classification.ClassificationStateHandler()); err != nil {
+		setupLog.Error(err, "unable to set up classification endpoint")
+		os.Exit(1)
+	}
+	if err := mgr.AddMetricsExtraHandler(classification.TuningPath,
+		classification.TuningHandler()); err != nil {
+		setupLog.Error(err, "unable to set up tuning endpoint")
+		os.Exit(1)
+	}
+	if err := mgr.AddMetricsExtraHandler(classification.EffectiveRBACPath,
+		classification.EffectiveRBACHandler()); err != nil {
+		setupLog.Error(err, "unable to set up effective RBAC endpoint")
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,688 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/projectsveltos/classifier-agent/internal/utils"
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+const (
+	// selfTestCommand, when passed as first argument, verifies the environment
+	// classifier-agent is deployed in and exits
+	selfTestCommand = "self-test"
+)
+
+// agentOptions contains the classifier-agent command line options
+type agentOptions struct {
+	metricsAddr               string
+	enableLeaderElection      bool
+	probeAddr                 string
+	runMode                   string
+	clusterNamespace          string
+	clusterName               string
+	clusterType               string
+	selfTest                  bool
+	allowedActions            []string
+	actionConfigMapNamespaces []string
+	actionsDryRun             bool
+	historySize               int
+	evaluationSummary         bool
+	consistentSnapshot        bool
+	versionMatching           string
+	protobufLists             bool
+	minInterval               time.Duration
+	maxInterval               time.Duration
+	broadPolicy               string
+	broadThreshold            int
+	dumpPath                  string
+	dumpFormat                string
+	reportsWithoutCRD         bool
+	remoteInterval            time.Duration
+	trendRetention            time.Duration
+	nodeLabels                bool
+	nodeLabelPrefix           string
+	nodeLabelClassifiers      []string
+	conformancePercent        int
+	versionProviders          []string
+	hostKubeconfig            string
+	hostResyncInterval        time.Duration
+	memoryPressure            string
+	maxFactStaleness          time.Duration
+	memoizeConstraints        bool
+	prometheusURL             string
+	prometheusTokenFile       string
+	prometheusCAFile          string
+	prometheusInsecure        bool
+	prometheusTimeout         time.Duration
+	prometheusResync          time.Duration
+	upgradeHandshake          bool
+	handshakeLease            time.Duration
+	rbacAnnotation            bool
+	healthGate                bool
+	healthGateTimeout         time.Duration
+	metricsProviderURLs       []string
+	metricsTimeout            time.Duration
+	metricsResync             time.Duration
+	listRetries               int
+	pollInterval              time.Duration
+	reportVerbosity           string
+	cycleBudget               float64
+	reportDeduplication       bool
+	clockSkewTolerance        time.Duration
+	stateFile                 string
+	stateFileFormat           string
+	createReportNs            bool
+	reportNsLabels            map[string]string
+	reportNsPodSecurity       string
+
+	// Following fields are set by Validate from the options above
+	hostConfig          *rest.Config
+	memoryPressureBytes uint64
+	prometheusEndpoint  *classification.PrometheusEndpoint
+	metricsProviders    []classification.MetricsProvider
+}
+
+// parseCommand records whether args start with the self-test command and
+// returns args without it
+func (o *agentOptions) parseCommand(args []string) []string {
+	o.selfTest = len(args) > 1 && args[1] == selfTestCommand
+	if o.selfTest {
+		return append(args[:1], args[2:]...)
+	}
+	return args
+}
+
+// addFlags registers the classifier-agent flags on fs
+func (o *agentOptions) addFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.metricsAddr,
+		"metrics-bind-address",
+		":8080",
+		"The address the metric endpoint binds to.")
+
+	fs.StringVar(&o.probeAddr,
+		"health-probe-bind-address",
+		":8081",
+		"The address the probe endpoint binds to.")
+
+	flag.StringVar(
+		&o.runMode,
+		"run-mode",
+		noReports,
+		"indicates whether reports will be sent to management cluster or just created locally",
+	)
+
+	flag.StringVar(
+		&o.clusterNamespace,
+		"cluster-namespace",
+		"",
+		"cluster namespace",
+	)
+
+	flag.StringVar(
+		&o.clusterName,
+		"cluster-name",
+		"",
+		"cluster name",
+	)
+
+	flag.StringVar(
+		&o.clusterType,
+		"cluster-type",
+		"",
+		"cluster type",
+	)
+
+	fs.StringSliceVar(&o.allowedActions,
+		"allowed-actions",
+		[]string{},
+		"action types classifier-agent is allowed to execute when a Classifier is evaluated. "+
+			"Empty disables actions")
+
+	fs.StringSliceVar(&o.actionConfigMapNamespaces,
+		"action-configmap-namespaces",
+		[]string{},
+		"namespaces Label actions can create and update ConfigMaps in. Requires the "+
+			"classifier-agent-actions-role ClusterRole")
+
+	fs.BoolVar(&o.actionsDryRun,
+		"actions-dry-run",
+		false,
+		"when set, actions are only logged and never executed")
+
+	fs.IntVar(&o.historySize,
+		"evaluation-history-size",
+		classification.DefaultEvaluationHistorySize,
+		"number of most recent evaluation results kept per Classifier. Zero disables it")
+
+	fs.BoolVar(&o.evaluationSummary,
+		"evaluation-summary",
+		false,
+		"when set, a ConfigMap summarizing all Classifiers is refreshed after each evaluation cycle")
+
+	fs.BoolVar(&o.consistentSnapshot,
+		"consistent-snapshot",
+		false,
+		"when set, all resources a Classifier depends on are listed at the same resourceVersion")
+
+	fs.StringVar(&o.versionMatching,
+		"version-matching",
+		string(classification.VersionMatchingStrict),
+		"how cluster Kubernetes version is compared against version constraints: strict or normalized. "+
+			"normalized drops build metadata and provider suffixes (e.g. -eks-48e63af) from cluster version")
+
+	fs.BoolVar(&o.protobufLists,
+		"protobuf-lists",
+		false,
+		"when set, built-in types (Pods, Nodes, Deployments...) are listed using protobuf instead of JSON")
+
+	fs.DurationVar(&o.minInterval,
+		"min-evaluation-interval",
+		0,
+		"lower bound of the evaluation interval when it adapts to cluster churn")
+
+	fs.DurationVar(&o.maxInterval,
+		"max-evaluation-interval",
+		0,
+		"upper bound of the evaluation interval when it adapts to cluster churn. "+
+			"When not set, evaluation interval is fixed")
+
+	fs.StringVar(&o.broadPolicy,
+		"broad-constraint-policy",
+		string(classification.BroadConstraintAllow),
+		"how constraints listing more than broad-constraint-threshold resources with no namespace nor label "+
+			"filter are evaluated: allow, sample (only the first resources are evaluated), "+
+			"defer (classifier is evaluated at most every 10 minutes) or refuse (classifier is reported as invalid)")
+
+	fs.IntVar(&o.broadThreshold,
+		"broad-constraint-threshold",
+		classification.DefaultBroadConstraintThreshold,
+		"number of resources above which a constraint with no namespace nor label filter is considered broad")
+
+	fs.StringVar(&o.dumpPath,
+		"dump-classification",
+		"",
+		"when set, classifier-agent waits till all classifiers are evaluated, writes the classification "+
+			"to this file (- for stdout) and exits")
+
+	fs.StringVar(&o.dumpFormat,
+		"dump-format",
+		string(classification.ClassificationStateJSON),
+		"format classification is dumped in: json or flat (a map of strings, as expected by "+
+			"Terraform external data sources)")
+
+	fs.BoolVar(&o.reportsWithoutCRD,
+		"send-reports-without-crd",
+		false,
+		"when ClassifierReport CRD is not installed in the managed cluster, send results kept in memory "+
+			"to the management cluster anyway")
+
+	fs.DurationVar(&o.remoteInterval,
+		"remote-classifiers-interval",
+		0,
+		"when set, Classifiers are fetched from the management cluster at this interval instead of "+
+			"being read from the managed cluster. Requires reports to be sent")
+
+	fs.DurationVar(&o.trendRetention,
+		"trend-retention",
+		classification.DefaultTrendRetention,
+		"how long resource counts are kept in memory for trend constraints")
+
+	fs.BoolVar(&o.nodeLabels,
+		"node-labels",
+		false,
+		"when set, classifier results are mirrored to labels on all nodes")
+
+	fs.StringVar(&o.nodeLabelPrefix,
+		"node-label-prefix",
+		classification.DefaultNodeLabelPrefix,
+		"prefix of the node labels classifier results are mirrored to")
+
+	fs.StringSliceVar(&o.nodeLabelClassifiers,
+		"node-label-classifiers",
+		nil,
+		"classifiers whose results are mirrored to node labels. All when not set")
+
+	fs.IntVar(&o.conformancePercent,
+		"conformance-sample-percent",
+		0,
+		"percentage of classifier evaluations verified by a slow reference evaluator. Divergences are "+
+			"reported via metrics and events. 0 disables verification")
+
+	fs.StringSliceVar(&o.versionProviders,
+		"version-providers",
+		[]string{string(classification.VersionProviderAPIServer)},
+		"ordered sources of the cluster Kubernetes version: apiserver, nodes, label, configmap. "+
+			"First source with a version is used, e.g. configmap,apiserver")
+
+	fs.StringVar(&o.hostKubeconfig,
+		"host-kubeconfig",
+		"",
+		"path to the kubeconfig of the cluster hosting the control plane of the managed cluster "+
+			"(hosted control planes). Classifier host resource constraints are evaluated against it")
+
+	fs.DurationVar(&o.hostResyncInterval,
+		"host-resync-interval",
+		classification.DefaultHostResyncInterval,
+		"interval at which classifiers with host resource constraints are evaluated again, "+
+			"since host cluster resources are not watched")
+
+	fs.StringVar(&o.memoryPressure,
+		"memory-pressure-threshold",
+		"",
+		"heap size, e.g. 512Mi, above which classifier-agent is degraded. While degraded, ClassifierReports "+
+			"carry a Degraded condition so that the management cluster can avoid acting on stale "+
+			"classifications. Empty disables memory pressure detection")
+
+	fs.DurationVar(&o.maxFactStaleness,
+		"max-fact-staleness",
+		0,
+		"age above which a cached fact, such as discovery data, is stale. Classifiers are still evaluated, "+
+			"but their ClassifierReports are annotated with the stale facts. 0 disables staleness reporting")
+
+	fs.BoolVar(&o.memoizeConstraints,
+		"memoize-constraints",
+		false,
+		"when set, resources matching identical resource constraints, count thresholds aside, are counted "+
+			"once per evaluation cycle and shared by all Classifiers. Ignored when consistent-snapshot is set")
+
+	fs.StringVar(&o.prometheusURL,
+		"prometheus-url",
+		"",
+		"base URL of the in-cluster Prometheus classifier prometheus constraints are evaluated against, "+
+			"e.g. http://prometheus.monitoring:9090. Empty disables prometheus constraints")
+
+	fs.StringVar(&o.prometheusTokenFile,
+		"prometheus-bearer-token-file",
+		"",
+		"path to a file containing the bearer token sent to Prometheus. File is read before each query")
+
+	fs.StringVar(&o.prometheusCAFile,
+		"prometheus-ca-file",
+		"",
+		"path to the CA bundle used to verify the Prometheus certificate")
+
+	fs.BoolVar(&o.prometheusInsecure,
+		"prometheus-insecure-skip-verify",
+		false,
+		"when set, the Prometheus certificate is not verified")
+
+	const defaultPrometheusTimeout = 30 * time.Second
+	fs.DurationVar(&o.prometheusTimeout,
+		"prometheus-timeout",
+		defaultPrometheusTimeout,
+		"timeout of each Prometheus query")
+
+	fs.DurationVar(&o.prometheusResync,
+		"prometheus-resync-interval",
+		classification.DefaultPrometheusResyncInterval,
+		"interval at which classifiers with prometheus constraints are evaluated again")
+
+	fs.BoolVar(&o.upgradeHandshake,
+		"upgrade-handshake",
+		false,
+		"when set, only the instance holding the handshake Lease evaluates classifiers and sends reports. "+
+			"During a rolling update, the outgoing instance evaluates queued classifiers a last time, then "+
+			"hands the Lease over to the incoming one. Requires POD_NAMESPACE and POD_NAME")
+
+	fs.DurationVar(&o.handshakeLease,
+		"handshake-lease-duration",
+		classification.DefaultHandshakeLeaseDuration,
+		"duration after which the handshake Lease can be taken over if its holder does not renew it")
+
+	fs.BoolVar(&o.rbacAnnotation,
+		"effective-rbac-annotation",
+		false,
+		"when set, the API server accesses each classifier evaluation required are set as annotation "+
+			"on the ClassifierReport in the managed cluster")
+
+	fs.BoolVar(&o.healthGate,
+		"health-gate",
+		false,
+		"when set, API server readiness and discovery are checked before each evaluation cycle. "+
+			"While checks fail, the cycle is skipped and ClassifierReports keep their last verdict")
+
+	fs.DurationVar(&o.healthGateTimeout,
+		"health-gate-timeout",
+		classification.DefaultHealthGateTimeout,
+		"timeout of each health gate check")
+
+	fs.StringSliceVar(&o.metricsProviderURLs,
+		"metrics-providers",
+		nil,
+		"name=url pairs of HTTP endpoints, typically served by sidecars, publishing numeric facts as a JSON "+
+			"object, e.g. tenants=http://localhost:8090/metrics. ConfigMaps labeled "+
+			classification.MetricsProviderLabel+" are always available as providers")
+
+	fs.DurationVar(&o.metricsTimeout,
+		"metrics-provider-timeout",
+		10*time.Second,
+		"timeout of each request to an HTTP metrics provider")
+
+	fs.DurationVar(&o.metricsResync,
+		"metrics-provider-resync-interval",
+		classification.DefaultMetricsProviderResyncInterval,
+		"interval at which classifiers with metric constraints are evaluated again")
+
+	fs.IntVar(&o.listRetries,
+		"list-retries",
+		classification.DefaultListRetries,
+		"number of times, within an evaluation, a list failing with a transient error (throttling, etcd "+
+			"leader change) is retried with a jittered backoff before the evaluation fails. 0 disables retries")
+
+	fs.DurationVar(&o.pollInterval,
+		"poll-interval",
+		0,
+		"when positive, resources are not watched and all classifiers are evaluated at this interval. "+
+			"Zero keeps the default of the cluster type (watch for capi, poll every 5m for sveltos)")
+
+	fs.StringVar(&o.reportVerbosity,
+		"report-verbosity",
+		"",
+		"full or minimal. Minimal omits the explanation annotation on ClassifierReports. "+
+			"Empty keeps the default of the cluster type (full for capi, minimal for sveltos)")
+
+	fs.Float64Var(&o.cycleBudget,
+		"cycle-budget",
+		0,
+		"fraction of the evaluation interval an evaluation cycle can take. Classifiers not evaluated "+
+			"within it are evaluated first in next cycle. 0 does not bound cycles")
+
+	fs.BoolVar(&o.reportDeduplication,
+		"report-deduplication",
+		false,
+		"when set, ClassifierReports sent to the management cluster list the classifiers with identical "+
+			"or conflicting classifier labels")
+
+	fs.DurationVar(&o.clockSkewTolerance,
+		"clock-skew-tolerance",
+		classification.DefaultClockSkewTolerance,
+		"offset between cluster clock, estimated from node heartbeats, and classifier-agent clock beyond which "+
+			"age and time window constraints are evaluated on the cluster clock and reports are flagged. 0 disables detection")
+
+	fs.StringVar(&o.stateFile,
+		"state-file",
+		"",
+		"when set, classification is written to this file, atomically replaced, after each evaluation cycle")
+
+	fs.StringVar(&o.stateFileFormat,
+		"state-file-format",
+		string(classification.ClassificationStateJSON),
+		"format of the state-file: json or flat")
+
+	fs.BoolVar(&o.createReportNs,
+		"create-report-namespace",
+		false,
+		"when set, the namespace reports are generated in is created on startup if missing, "+
+			"annotated so uninstall tooling can remove it")
+
+	fs.StringToStringVar(&o.reportNsLabels,
+		"report-namespace-labels",
+		nil,
+		"labels set on the report namespace when created by create-report-namespace")
+
+	fs.StringVar(&o.reportNsPodSecurity,
+		"report-namespace-pod-security",
+		"",
+		"PodSecurity admission enforce level (privileged, baseline or restricted) set on the report "+
+			"namespace when created by create-report-namespace")
+
+	fs.BoolVar(&o.enableLeaderElection, "leader-elect", false,
+		"Enable leader election for controller manager. "+
+			"Enabling this will ensure there is only one active controller manager.")
+}
+
+// Validate returns an error if any option is invalid. Options which need to be
+// parsed, like memory-pressure-threshold or host-kubeconfig, are parsed here.
+func (o *agentOptions) Validate() error {
+	if o.versionMatching != string(classification.VersionMatchingStrict) &&
+		o.versionMatching != string(classification.VersionMatchingNormalized) {
+
+		return fmt.Errorf("invalid version-matching value %q", o.versionMatching)
+	}
+
+	if !classification.IsValidBroadConstraintPolicy(classification.BroadConstraintPolicy(o.broadPolicy)) {
+		return fmt.Errorf("invalid broad-constraint-policy value %q", o.broadPolicy)
+	}
+
+	if !classification.IsValidClassificationStateFormat(classification.ClassificationStateFormat(o.dumpFormat)) {
+		return fmt.Errorf("invalid dump-format value %q", o.dumpFormat)
+	}
+
+	if o.runMode != noReports {
+		if err := classification.ValidateClusterType(libsveltosv1alpha1.ClusterType(o.clusterType)); err != nil {
+			return err
+		}
+		if !classification.IsKnownClusterType(libsveltosv1alpha1.ClusterType(o.clusterType)) {
+			setupLog.Info("cluster type is not a known one, it is reported as is", "cluster-type", o.clusterType)
+		}
+	}
+
+	if o.nodeLabels {
+		if err := classification.ValidateNodeLabelPrefix(o.nodeLabelPrefix); err != nil {
+			return err
+		}
+	}
+
+	if o.remoteInterval < 0 || (o.remoteInterval > 0 && o.runMode == noReports) {
+		return fmt.Errorf("remote-classifiers-interval must not be negative and requires reports to be sent")
+	}
+
+	if o.conformancePercent < 0 || o.conformancePercent > 100 {
+		return fmt.Errorf("conformance-sample-percent must be between 0 and 100")
+	}
+
+	for i := range o.versionProviders {
+		if !classification.IsValidVersionProviderType(classification.VersionProviderType(o.versionProviders[i])) {
+			return fmt.Errorf("invalid version-providers value %q", o.versionProviders[i])
+		}
+	}
+
+	if o.hostKubeconfig != "" {
+		var err error
+		o.hostConfig, err = clientcmd.BuildConfigFromFlags("", o.hostKubeconfig)
+		if err != nil {
+			return fmt.Errorf("unable to load host-kubeconfig %s: %w", o.hostKubeconfig, err)
+		}
+	}
+
+	if o.memoryPressure != "" {
+		quantity, err := resource.ParseQuantity(o.memoryPressure)
+		if err != nil || quantity.Sign() < 0 {
+			return fmt.Errorf("invalid memory-pressure-threshold value %q", o.memoryPressure)
+		}
+		o.memoryPressureBytes = uint64(quantity.Value())
+	}
+
+	if o.maxFactStaleness < 0 {
+		return fmt.Errorf("max-fact-staleness must not be negative")
+	}
+
+	if o.prometheusURL != "" {
+		var err error
+		o.prometheusEndpoint, err = o.getPrometheusEndpoint()
+		if err != nil {
+			return fmt.Errorf("invalid prometheus configuration: %w", err)
+		}
+	}
+
+	if o.upgradeHandshake && (os.Getenv("POD_NAMESPACE") == "" || os.Getenv("POD_NAME") == "") {
+		return fmt.Errorf("upgrade-handshake requires POD_NAMESPACE and POD_NAME")
+	}
+
+	if o.handshakeLease < time.Second {
+		return fmt.Errorf("handshake-lease-duration must be at least one second")
+	}
+
+	var err error
+	o.metricsProviders, err = classification.ParseMetricsProviders(o.metricsProviderURLs, o.metricsTimeout)
+	if err != nil {
+		return fmt.Errorf("invalid metrics-providers value: %w", err)
+	}
+
+	if o.listRetries < 0 {
+		return fmt.Errorf("list-retries must not be negative")
+	}
+
+	if o.pollInterval < 0 {
+		return fmt.Errorf("poll-interval must not be negative")
+	}
+
+	if o.reportVerbosity != "" {
+		if err := classification.ValidateReportVerbosity(classification.ReportVerbosity(o.reportVerbosity)); err != nil {
+			return fmt.Errorf("invalid report-verbosity value: %w", err)
+		}
+	}
+
+	if o.cycleBudget < 0 || o.cycleBudget > 1 {
+		return fmt.Errorf("cycle-budget must be between 0 and 1")
+	}
+
+	if !classification.IsValidClassificationStateFormat(classification.ClassificationStateFormat(o.stateFileFormat)) {
+		return fmt.Errorf("invalid state-file-format value %q", o.stateFileFormat)
+	}
+
+	if o.clockSkewTolerance < 0 {
+		return fmt.Errorf("clock-skew-tolerance must not be negative")
+	}
+
+	if o.reportNsPodSecurity != "" {
+		if err := utils.ValidatePodSecurityLevel(o.reportNsPodSecurity); err != nil {
+			return fmt.Errorf("invalid report-namespace-pod-security value: %w", err)
+		}
+	}
+
+	if o.healthGateTimeout <= 0 {
+		return fmt.Errorf("health-gate-timeout must be positive")
+	}
+
+	if o.maxInterval != 0 && (o.minInterval <= 0 || o.minInterval > o.maxInterval) {
+		return fmt.Errorf("min-evaluation-interval must be positive and not greater than max-evaluation-interval")
+	}
+
+	return nil
+}
+
+func (o *agentOptions) getManagerOptions() []classification.Option {
+	actionTypes := make([]classification.ActionType, len(o.allowedActions))
+	for i := range o.allowedActions {
+		actionTypes[i] = classification.ActionType(o.allowedActions[i])
+	}
+
+	options := []classification.Option{
+		classification.WithAllowedActions(actionTypes),
+		classification.WithActionConfigMapNamespaces(o.actionConfigMapNamespaces),
+		classification.WithActionsDryRun(o.actionsDryRun),
+		classification.WithEvaluationHistorySize(o.historySize),
+		classification.WithEvaluationSummary(o.evaluationSummary),
+		classification.WithConsistentSnapshot(o.consistentSnapshot),
+		classification.WithVersionMatching(classification.VersionMatchingMode(o.versionMatching)),
+		classification.WithProtobufLists(o.protobufLists),
+		classification.WithAdaptiveInterval(o.minInterval, o.maxInterval),
+		classification.WithBroadConstraintPolicy(classification.BroadConstraintPolicy(o.broadPolicy), o.broadThreshold),
+		classification.WithReportsWithoutCRD(o.reportsWithoutCRD),
+		classification.WithRemoteClassifiers(o.remoteInterval),
+		classification.WithTrendRetention(o.trendRetention),
+		classification.WithConformance(o.conformancePercent),
+		classification.WithMemoryPressureThreshold(o.memoryPressureBytes),
+		classification.WithMaxFactStaleness(o.maxFactStaleness),
+		classification.WithConstraintMemoization(o.memoizeConstraints),
+		classification.WithEffectiveRBACAnnotation(o.rbacAnnotation),
+		classification.WithHealthGate(o.healthGate, o.healthGateTimeout),
+		classification.WithListRetries(o.listRetries, 0),
+		classification.WithCycleBudget(o.cycleBudget),
+		classification.WithReportDeduplication(o.reportDeduplication),
+		classification.WithClockSkewTolerance(o.clockSkewTolerance),
+		// Overrides the behavior profile of the cluster type
+		classification.WithBehaviorProfile(classification.BehaviorProfile{
+			PollInterval:    o.pollInterval,
+			ReportVerbosity: classification.ReportVerbosity(o.reportVerbosity),
+		}),
+	}
+
+	providerTypes := make([]classification.VersionProviderType, len(o.versionProviders))
+	for i := range o.versionProviders {
+		providerTypes[i] = classification.VersionProviderType(o.versionProviders[i])
+	}
+	options = append(options, classification.WithVersionProviders(providerTypes...))
+
+	// Set via the downward API
+	options = append(options, classification.WithAgentPod(os.Getenv("POD_NAMESPACE"), os.Getenv("POD_NAME")))
+	if o.hostConfig != nil {
+		options = append(options, classification.WithHostCluster(o.hostConfig, o.hostResyncInterval))
+	}
+	if o.nodeLabels {
+		options = append(options, classification.WithNodeLabels(o.nodeLabelPrefix, o.nodeLabelClassifiers))
+	}
+	if o.prometheusEndpoint != nil {
+		options = append(options, classification.WithPrometheus(o.prometheusEndpoint, o.prometheusResync))
+	}
+	if len(o.metricsProviders) != 0 {
+		options = append(options, classification.WithMetricsProviders(o.metricsProviders, o.metricsResync))
+	}
+	if o.stateFile != "" {
+		options = append(options, classification.WithStateFile(o.stateFile,
+			classification.ClassificationStateFormat(o.stateFileFormat)))
+	}
+	if o.upgradeHandshake {
+		options = append(options, classification.WithUpgradeHandshake(os.Getenv("POD_NAMESPACE"),
+			os.Getenv("POD_NAME"), o.handshakeLease))
+	}
+
+	return options
+}
+
+// getPrometheusEndpoint returns the Prometheus endpoint set with the prometheus-* flags
+func (o *agentOptions) getPrometheusEndpoint() (*classification.PrometheusEndpoint, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: o.prometheusInsecure, //nolint: gosec // explicitly requested
+	}
+	if o.prometheusCAFile != "" {
+		ca, err := os.ReadFile(o.prometheusCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificate found in %s", o.prometheusCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &classification.PrometheusEndpoint{
+		URL:             o.prometheusURL,
+		BearerTokenFile: o.prometheusTokenFile,
+		HTTPClient:      &http.Client{Transport: transport, Timeout: o.prometheusTimeout},
+	}, nil
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"fmt"
+
+	"emperror.dev/errors"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+const (
+	// EventReasonClassifierMatch is the reason of the Event emitted when cluster is a match
+	EventReasonClassifierMatch = "ClassifierMatch"
+
+	// EventReasonClassifierNoMatch is the reason of the Event emitted when cluster is not a match
+	EventReasonClassifierNoMatch = "ClassifierNoMatch"
+)
+
+// eventAction emits a Kubernetes Event for the Classifier with its match status
+type eventAction struct {
+	recorder record.EventRecorder
+}
+
+func newEventAction(m *manager, config *MatchAction) (Action, error) {
+	if m.eventRecorder == nil {
+		return nil, errors.New("no event recorder configured")
+	}
+	return &eventAction{recorder: m.eventRecorder}, nil
+}
+
+func (a *eventAction) Type() ActionType {
+	return ActionTypeEvent
+}
+
+func (a *eventAction) Execute(ctx context.Context, classifier *libsveltosv1alpha1.Classifier,
+	isMatch bool, logger logr.Logger) error {
+
+	reason := EventReasonClassifierNoMatch
+	if isMatch {
+		reason = EventReasonClassifierMatch
+	}
+
+	a.recorder.Event(classifier, corev1.EventTypeNormal, reason,
+		fmt.Sprintf("cluster match for classifier %s: %t", classifier.Name, isMatch))
+	return nil
+}
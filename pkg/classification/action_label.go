@@ -0,0 +1,314 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"emperror.dev/errors"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+)
+
+// ActionTarget indicates the local object a Label action is applied to
+type ActionTarget string
+
+const (
+	// ActionTargetKubeSystem applies labels/annotations to the kube-system namespace
+	ActionTargetKubeSystem = ActionTarget("KubeSystemNamespace")
+
+	// ActionTargetNodes applies labels/annotations to all cluster nodes
+	ActionTargetNodes = ActionTarget("Nodes")
+
+	// ActionTargetConfigMap applies labels/annotations to a ConfigMap.
+	// ConfigMap is created if it does not exist. Only ConfigMaps in the
+	// namespaces allowed by WithActionConfigMapNamespaces can be targeted.
+	ActionTargetConfigMap = ActionTarget("ConfigMap")
+)
+
+const (
+	kubeSystemNamespace = "kube-system"
+
+	// ActionsAppliedAnnotation is set by Label actions on their targets. It records,
+	// per Classifier, the labels and annotations applied so the ones removed from
+	// the Classifier actions are removed from the target as well.
+	ActionsAppliedAnnotation = "classifier.projectsveltos.io/applied-actions"
+)
+
+// appliedMetadata contains the labels and annotations a Classifier applied to an object
+type appliedMetadata struct {
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// labelAction applies configured labels and annotations to a local object when
+// Classifier is a match. Those are removed when Classifier stops being a match.
+type labelAction struct {
+	client.Client
+	config *MatchAction
+}
+
+func newLabelAction(m *manager, config *MatchAction) (Action, error) {
+	switch config.Target {
+	case ActionTargetKubeSystem, ActionTargetNodes:
+	case ActionTargetConfigMap:
+		if config.Namespace == "" || config.Name == "" {
+			return nil, fmt.Errorf("action target %s requires namespace and name", config.Target)
+		}
+		if !m.isActionConfigMapNamespaceAllowed(config.Namespace) {
+			return nil, fmt.Errorf("action target %s not allowed in namespace %s", config.Target, config.Namespace)
+		}
+	default:
+		return nil, fmt.Errorf("unknown action target %q", config.Target)
+	}
+
+	return &labelAction{Client: m.Client, config: config}, nil
+}
+
+func (a *labelAction) Type() ActionType {
+	return ActionTypeLabel
+}
+
+func (a *labelAction) Execute(ctx context.Context, classifier *libsveltosv1alpha1.Classifier,
+	isMatch bool, logger logr.Logger) error {
+
+	desired, err := a.getDesiredMetadata(classifier)
+	if err != nil {
+		return err
+	}
+
+	switch a.config.Target {
+	case ActionTargetKubeSystem:
+		ns := &corev1.Namespace{}
+		if err := a.Get(ctx, types.NamespacedName{Name: kubeSystemNamespace}, ns); err != nil {
+			return err
+		}
+		return a.updateTarget(ctx, ns, classifier.Name, desired, isMatch)
+	case ActionTargetNodes:
+		nodes := &corev1.NodeList{}
+		if err := a.List(ctx, nodes); err != nil {
+			return err
+		}
+		for i := range nodes.Items {
+			if err := a.updateTarget(ctx, &nodes.Items[i], classifier.Name, desired, isMatch); err != nil {
+				return err
+			}
+		}
+		return nil
+	case ActionTargetConfigMap:
+		return a.processConfigMap(ctx, classifier.Name, desired, isMatch, logger)
+	default:
+		return fmt.Errorf("unknown action target %q", a.config.Target)
+	}
+}
+
+// getDesiredMetadata returns the labels and annotations all Label actions of the
+// Classifier apply to the target of this action. Merging those keeps actions sharing
+// a target from removing each other entries.
+func (a *labelAction) getDesiredMetadata(classifier *libsveltosv1alpha1.Classifier) (*appliedMetadata, error) {
+	extension, err := getClassifierExtension(classifier)
+	if err != nil {
+		return nil, err
+	}
+
+	desired := &appliedMetadata{}
+	for i := range extension.Actions {
+		config := &extension.Actions[i]
+		if getActionType(config) != ActionTypeLabel || !isSameActionTarget(config, a.config) {
+			continue
+		}
+		desired.Labels, _ = updateMap(desired.Labels, config.Labels, true)
+		desired.Annotations, _ = updateMap(desired.Annotations, config.Annotations, true)
+	}
+
+	return desired, nil
+}
+
+func isSameActionTarget(config, other *MatchAction) bool {
+	if config.Target != other.Target {
+		return false
+	}
+	if config.Target == ActionTargetConfigMap {
+		return config.Namespace == other.Namespace && config.Name == other.Name
+	}
+	return true
+}
+
+func (a *labelAction) processConfigMap(ctx context.Context, classifierName string, desired *appliedMetadata,
+	isMatch bool, logger logr.Logger) error {
+
+	configMap := &corev1.ConfigMap{}
+	err := a.Get(ctx, types.NamespacedName{Namespace: a.config.Namespace, Name: a.config.Name}, configMap)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		if !isMatch {
+			// Nothing to remove
+			return nil
+		}
+		logger.V(logs.LogDebug).Info(fmt.Sprintf("creating ConfigMap %s/%s", a.config.Namespace, a.config.Name))
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: a.config.Namespace,
+				Name:      a.config.Name,
+			},
+		}
+		if _, err := applyMetadata(configMap, classifierName, desired, isMatch); err != nil {
+			return err
+		}
+		return a.Create(ctx, configMap)
+	}
+
+	return a.updateTarget(ctx, configMap, classifierName, desired, isMatch)
+}
+
+// updateTarget adds (isMatch true) or removes (isMatch false) desired labels
+// and annotations to/from obj. obj is updated only if anything changed.
+func (a *labelAction) updateTarget(ctx context.Context, obj client.Object, classifierName string,
+	desired *appliedMetadata, isMatch bool) error {
+
+	changed, err := applyMetadata(obj, classifierName, desired, isMatch)
+	if err != nil || !changed {
+		return err
+	}
+
+	return a.Update(ctx, obj)
+}
+
+// applyMetadata adds (isMatch true) or removes (isMatch false) desired labels and
+// annotations to/from obj. Entries the Classifier applied before, as recorded in
+// ActionsAppliedAnnotation, and not desired anymore are removed as well.
+// Returns whether obj was changed.
+func applyMetadata(obj client.Object, classifierName string, desired *appliedMetadata,
+	isMatch bool) (bool, error) {
+
+	applied, err := getAppliedMetadata(obj)
+	if err != nil {
+		return false, err
+	}
+	previous := applied[classifierName]
+
+	labels, staleLabels := updateMap(obj.GetLabels(), getStaleEntries(previous.Labels, desired.Labels), false)
+	labels, labelsChanged := updateMap(labels, desired.Labels, isMatch)
+	annotations, staleAnnotations := updateMap(obj.GetAnnotations(),
+		getStaleEntries(previous.Annotations, desired.Annotations), false)
+	annotations, annotationsChanged := updateMap(annotations, desired.Annotations, isMatch)
+
+	if isMatch && (len(desired.Labels) != 0 || len(desired.Annotations) != 0) {
+		applied[classifierName] = *desired
+	} else {
+		delete(applied, classifierName)
+	}
+	annotations, recordChanged, err := setAppliedMetadata(annotations, applied)
+	if err != nil {
+		return false, err
+	}
+
+	obj.SetLabels(labels)
+	obj.SetAnnotations(annotations)
+	return staleLabels || labelsChanged || staleAnnotations || annotationsChanged || recordChanged, nil
+}
+
+// getStaleEntries returns the entries in previous whose key is not in desired
+func getStaleEntries(previous, desired map[string]string) map[string]string {
+	stale := make(map[string]string)
+	for k, v := range previous {
+		if _, ok := desired[k]; !ok {
+			stale[k] = v
+		}
+	}
+	return stale
+}
+
+// getAppliedMetadata returns, per Classifier, the labels and annotations applied to obj
+func getAppliedMetadata(obj client.Object) (map[string]appliedMetadata, error) {
+	applied := make(map[string]appliedMetadata)
+
+	value, ok := obj.GetAnnotations()[ActionsAppliedAnnotation]
+	if !ok || value == "" {
+		return applied, nil
+	}
+
+	if err := json.Unmarshal([]byte(value), &applied); err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to parse annotation %s", ActionsAppliedAnnotation))
+	}
+	return applied, nil
+}
+
+// setAppliedMetadata stores applied in the ActionsAppliedAnnotation. The annotation is
+// removed when no Classifier has anything applied. Returns whether annotations changed.
+func setAppliedMetadata(annotations map[string]string,
+	applied map[string]appliedMetadata) (map[string]string, bool, error) {
+
+	currentValue, ok := annotations[ActionsAppliedAnnotation]
+	if len(applied) == 0 {
+		if !ok {
+			return annotations, false, nil
+		}
+		delete(annotations, ActionsAppliedAnnotation)
+		return annotations, true, nil
+	}
+
+	value, err := json.Marshal(applied)
+	if err != nil {
+		return nil, false, err
+	}
+	if ok && currentValue == string(value) {
+		return annotations, false, nil
+	}
+
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[ActionsAppliedAnnotation] = string(value)
+	return annotations, true, nil
+}
+
+// updateMap adds (add true) or removes (add false) all entries in desired to/from current.
+// An entry is removed only if its value matches the one in desired.
+// Returns the resulting map and whether any change was made.
+func updateMap(current, desired map[string]string, add bool) (map[string]string, bool) {
+	changed := false
+	for k, v := range desired {
+		currentValue, ok := current[k]
+		if add {
+			if ok && currentValue == v {
+				continue
+			}
+			if current == nil {
+				current = make(map[string]string)
+			}
+			current[k] = v
+			changed = true
+		} else if ok && currentValue == v {
+			delete(current, k)
+			changed = true
+		}
+	}
+
+	return current, changed
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/go-logr/logr"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+)
+
+const (
+	webhookTimeout = 10 * time.Second
+)
+
+// WebhookPayload is the body posted by a Webhook action
+type WebhookPayload struct {
+	ClassifierName   string                         `json:"classifierName"`
+	Match            bool                           `json:"match"`
+	ClusterNamespace string                         `json:"clusterNamespace,omitempty"`
+	ClusterName      string                         `json:"clusterName,omitempty"`
+	ClusterType      libsveltosv1alpha1.ClusterType `json:"clusterType,omitempty"`
+}
+
+// webhookAction posts Classifier match status to an HTTP endpoint
+type webhookAction struct {
+	url              string
+	clusterNamespace string
+	clusterName      string
+	clusterType      libsveltosv1alpha1.ClusterType
+	httpClient       *http.Client
+}
+
+func newWebhookAction(m *manager, config *MatchAction) (Action, error) {
+	if config.URL == "" {
+		return nil, errors.New("webhook action requires url")
+	}
+
+	u, err := url.Parse(config.URL)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid webhook url")
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported webhook url scheme %q", u.Scheme)
+	}
+
+	return &webhookAction{
+		url:              config.URL,
+		clusterNamespace: m.clusterNamespace,
+		clusterName:      m.clusterName,
+		clusterType:      m.clusterType,
+		httpClient:       &http.Client{Timeout: webhookTimeout},
+	}, nil
+}
+
+func (a *webhookAction) Type() ActionType {
+	return ActionTypeWebhook
+}
+
+func (a *webhookAction) Execute(ctx context.Context, classifier *libsveltosv1alpha1.Classifier,
+	isMatch bool, logger logr.Logger) error {
+
+	payload := WebhookPayload{
+		ClassifierName:   classifier.Name,
+		Match:            isMatch,
+		ClusterNamespace: a.clusterNamespace,
+		ClusterName:      a.clusterName,
+		ClusterType:      a.clusterType,
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	logger.V(logs.LogDebug).Info(fmt.Sprintf("posting match status to %s", a.url))
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("webhook %s returned status %d", a.url, resp.StatusCode)
+	}
+
+	return nil
+}
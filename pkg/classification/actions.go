@@ -0,0 +1,295 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"emperror.dev/errors"
+	"github.com/go-logr/logr"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+)
+
+// ActionType identifies an Action implementation
+type ActionType string
+
+const (
+	// ActionTypeLabel adds labels/annotations to a local object
+	ActionTypeLabel = ActionType("Label")
+
+	// ActionTypeEvent emits a Kubernetes Event for the Classifier
+	ActionTypeEvent = ActionType("Event")
+
+	// ActionTypeWebhook sends the Classifier match status to an HTTP endpoint
+	ActionTypeWebhook = ActionType("Webhook")
+)
+
+// MatchAction describes an action classifier-agent takes locally after a
+// Classifier is evaluated. Label actions run on every evaluation, all other
+// types only when the match result changes.
+type MatchAction struct {
+	// Type of the action. Defaults to Label.
+	// +optional
+	Type ActionType `json:"type,omitempty"`
+
+	// Target is the local object labels and annotations are applied to.
+	// Only used when Type is Label.
+	// +optional
+	Target ActionTarget `json:"target,omitempty"`
+
+	// Namespace of the ConfigMap. Only used when Target is ConfigMap
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Name of the ConfigMap. Only used when Target is ConfigMap
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Labels to apply. Only used when Type is Label.
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations to apply. Only used when Type is Label.
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// URL is the endpoint Classifier match status is posted to.
+	// Only used when Type is Webhook.
+	// +optional
+	URL string `json:"url,omitempty"`
+}
+
+// Action is an operation classifier-agent performs locally after a Classifier
+// has been evaluated.
+type Action interface {
+	// Type returns the ActionType
+	Type() ActionType
+
+	// Execute runs the action. isMatch indicates whether the cluster is
+	// currently a match for the Classifier.
+	Execute(ctx context.Context, classifier *libsveltosv1alpha1.Classifier, isMatch bool,
+		logger logr.Logger) error
+}
+
+// ActionFactory builds an Action from its configuration
+type ActionFactory func(m *manager, config *MatchAction) (Action, error)
+
+var (
+	actionFactoriesMu = &sync.RWMutex{}
+	actionFactories   = map[ActionType]ActionFactory{
+		ActionTypeLabel:   newLabelAction,
+		ActionTypeEvent:   newEventAction,
+		ActionTypeWebhook: newWebhookAction,
+	}
+)
+
+// RegisterActionFactory registers a new ActionType. Registering an already
+// existing ActionType replaces its factory.
+func RegisterActionFactory(actionType ActionType, factory ActionFactory) {
+	actionFactoriesMu.Lock()
+	defer actionFactoriesMu.Unlock()
+
+	actionFactories[actionType] = factory
+}
+
+// DefaultAllowedActions returns the ActionTypes allowed when no allowlist is configured.
+// None is: actions modify the cluster or reach outside of it, so the cluster admin
+// has to opt in to each ActionType.
+func DefaultAllowedActions() []ActionType {
+	return []ActionType{}
+}
+
+func (m *manager) isActionAllowed(actionType ActionType) bool {
+	allowed := m.allowedActions
+	if allowed == nil {
+		allowed = DefaultAllowedActions()
+	}
+
+	for i := range allowed {
+		if allowed[i] == actionType {
+			return true
+		}
+	}
+	return false
+}
+
+// isActionConfigMapNamespaceAllowed returns true if ConfigMap actions can
+// target a ConfigMap in namespace
+func (m *manager) isActionConfigMapNamespaceAllowed(namespace string) bool {
+	for i := range m.actionConfigMapNamespaces {
+		if m.actionConfigMapNamespaces[i] == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// getActionType returns the type of an action. Label is the default
+func getActionType(config *MatchAction) ActionType {
+	if config.Type == "" {
+		return ActionTypeLabel
+	}
+	return config.Type
+}
+
+func (m *manager) getAction(config *MatchAction) (Action, error) {
+	actionType := getActionType(config)
+
+	actionFactoriesMu.RLock()
+	factory, ok := actionFactories[actionType]
+	actionFactoriesMu.RUnlock()
+	if !ok {
+		return nil, newInvalidClassifierError(fmt.Errorf("unknown action type %q", actionType))
+	}
+
+	action, err := factory(m, config)
+	if err != nil {
+		// Building the action again won't help till Classifier is changed
+		return nil, newInvalidClassifierError(err)
+	}
+	return action, nil
+}
+
+// UndoActions removes labels and annotations the Classifier actions added.
+// Invoked when a Classifier is deleted, before its finalizer is removed. Only Label
+// actions are run: the others have nothing to undo. Actions which cannot be parsed
+// are ignored as well.
+func (m *manager) UndoActions(ctx context.Context, classifier *libsveltosv1alpha1.Classifier) error {
+	extension, err := getClassifierExtension(classifier)
+	if err != nil {
+		return dropInvalidClassifierErrors(err)
+	}
+
+	err = m.executeActions(ctx, classifier, extension.Actions, false, isIdempotentAction)
+	return dropInvalidClassifierErrors(err)
+}
+
+// isIdempotentAction returns true if running an action of this type again,
+// with the same match result, has no effect
+func isIdempotentAction(actionType ActionType) bool {
+	return actionType == ActionTypeLabel
+}
+
+// dropInvalidClassifierErrors returns err without the invalidClassifierErrors it contains
+func dropInvalidClassifierErrors(err error) error {
+	var result error
+	for _, e := range errors.GetErrors(err) {
+		if !isInvalidClassifierError(e) {
+			result = errors.Append(result, e)
+		}
+	}
+	return result
+}
+
+// isActionMatchChanged returns true if the match result differs from the one
+// non idempotent actions last ran with. After a restart those run once again.
+func (m *manager) isActionMatchChanged(classifierName string, isMatch bool) bool {
+	m.actionMatchesMu.Lock()
+	defer m.actionMatchesMu.Unlock()
+
+	previous, ok := m.actionMatches[classifierName]
+	return !ok || previous != isMatch
+}
+
+func (m *manager) setActionMatch(classifierName string, isMatch bool) {
+	m.actionMatchesMu.Lock()
+	defer m.actionMatchesMu.Unlock()
+	m.actionMatches[classifierName] = isMatch
+}
+
+func (m *manager) removeActionMatch(classifierName string) {
+	m.actionMatchesMu.Lock()
+	defer m.actionMatchesMu.Unlock()
+	delete(m.actionMatches, classifierName)
+}
+
+// processActions runs all actions configured for a Classifier.
+// Label actions run on every evaluation so their targets converge. Other actions,
+// such as Event and Webhook, run only when the match result changes; if any of
+// those fails, all are run again next evaluation.
+// A failing action does not prevent the others from running; all failures are returned.
+func (m *manager) processActions(ctx context.Context, classifier *libsveltosv1alpha1.Classifier,
+	isMatch bool) error {
+
+	extension, err := getClassifierExtension(classifier)
+	if err != nil {
+		return err
+	}
+
+	actionsErr := m.executeActions(ctx, classifier, extension.Actions, isMatch, isIdempotentAction)
+	if !m.isActionMatchChanged(classifier.Name, isMatch) {
+		return actionsErr
+	}
+
+	transitionErr := m.executeActions(ctx, classifier, extension.Actions, isMatch,
+		func(actionType ActionType) bool { return !isIdempotentAction(actionType) })
+	// Invalid actions keep failing till Classifier is changed. Do not run all
+	// others on every evaluation because of those.
+	if dropInvalidClassifierErrors(transitionErr) == nil {
+		m.setActionMatch(classifier.Name, isMatch)
+	}
+
+	return errors.Append(actionsErr, transitionErr)
+}
+
+// executeActions runs the actions whose type is selected by filter.
+// Actions whose type is not allowed are skipped. When dry run is enabled,
+// actions are only logged.
+func (m *manager) executeActions(ctx context.Context, classifier *libsveltosv1alpha1.Classifier,
+	actions []MatchAction, isMatch bool, filter func(ActionType) bool) error {
+
+	var actionsErr error
+	logger := m.log.WithValues("classifier", classifier.Name)
+	for i := range actions {
+		config := &actions[i]
+		actionType := getActionType(config)
+		if !filter(actionType) {
+			continue
+		}
+		actionLogger := logger.WithValues("action", actionType, "match", isMatch)
+
+		if !m.isActionAllowed(actionType) {
+			actionLogger.V(logs.LogInfo).Info("action type not allowed. Skipping it")
+			continue
+		}
+
+		action, err := m.getAction(config)
+		if err != nil {
+			actionLogger.V(logs.LogInfo).Info(fmt.Sprintf("invalid action: %v", err))
+			actionsErr = errors.Append(actionsErr, err)
+			continue
+		}
+
+		if m.actionsDryRun {
+			actionLogger.V(logs.LogInfo).Info("dry run: action not executed")
+			continue
+		}
+
+		if err := action.Execute(ctx, classifier, isMatch, actionLogger); err != nil {
+			actionLogger.V(logs.LogInfo).Info(fmt.Sprintf("failed to execute action: %v", err))
+			actionsErr = errors.Append(actionsErr, err)
+			continue
+		}
+		actionLogger.V(logs.LogInfo).Info("action executed")
+	}
+
+	return actionsErr
+}
@@ -0,0 +1,420 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: actions", func() {
+	BeforeEach(func() {
+		classification.Reset()
+	})
+
+	It("getClassifierExtension parses extension annotation", func() {
+		namespace := randomString()
+		name := randomString()
+		classifier := getClassifierWithKubernetesConstraints(version25, libsveltosv1alpha1.ComparisonEqual)
+		classifier.Annotations = map[string]string{
+			classification.ClassifierExtensionAnnotation: fmt.Sprintf(`actions:
+- target: ConfigMap
+  namespace: %s
+  name: %s
+  labels:
+    env: production`, namespace, name),
+		}
+
+		extension, err := classification.GetClassifierExtension(classifier)
+		Expect(err).To(BeNil())
+		Expect(len(extension.Actions)).To(Equal(1))
+		Expect(extension.Actions[0].Target).To(Equal(classification.ActionTargetConfigMap))
+		Expect(extension.Actions[0].Namespace).To(Equal(namespace))
+		Expect(extension.Actions[0].Name).To(Equal(name))
+		Expect(extension.Actions[0].Labels).To(HaveKeyWithValue("env", "production"))
+
+		classifier.Annotations = nil
+		extension, err = classification.GetClassifierExtension(classifier)
+		Expect(err).To(BeNil())
+		Expect(extension.Actions).To(BeEmpty())
+	})
+
+	It("updateMap adds and removes only matching entries", func() {
+		current := map[string]string{"a": "1", "b": "2"}
+		desired := map[string]string{"a": "1", "c": "3"}
+
+		result, changed := classification.UpdateMap(current, desired, true)
+		Expect(changed).To(BeTrue())
+		Expect(result).To(Equal(map[string]string{"a": "1", "b": "2", "c": "3"}))
+
+		_, changed = classification.UpdateMap(result, desired, true)
+		Expect(changed).To(BeFalse())
+
+		result["c"] = "changed"
+		result, changed = classification.UpdateMap(result, desired, false)
+		Expect(changed).To(BeTrue())
+		Expect(result).To(Equal(map[string]string{"b": "2", "c": "changed"}))
+	})
+
+	It("processActions labels nodes and ConfigMap when classifier is a match", func() {
+		key := randomString()
+		value := randomString()
+		node := &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: randomString(),
+			},
+		}
+
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: randomString(),
+				Name:      randomString(),
+			},
+		}
+
+		classifier := getClassifierWithKubernetesConstraints(version25, libsveltosv1alpha1.ComparisonEqual)
+		classifier.Annotations = map[string]string{
+			classification.ClassifierExtensionAnnotation: fmt.Sprintf(`actions:
+- target: Nodes
+  labels:
+    %s: %s
+- target: ConfigMap
+  namespace: %s
+  name: %s
+  annotations:
+    %s: %s`, key, value, configMap.Namespace, configMap.Name, key, value),
+		}
+
+		initObjects := []client.Object{
+			node,
+			classifier,
+		}
+
+		c := classification.InitializeManagerWithObjects(nil, initObjects...)
+
+		manager := classification.GetManager()
+		Expect(manager).ToNot(BeNil())
+
+		classification.ApplyOptions(
+			classification.WithAllowedActions([]classification.ActionType{classification.ActionTypeLabel}),
+			classification.WithActionConfigMapNamespaces([]string{configMap.Namespace}))
+		Expect(classification.ProcessActions(manager, context.TODO(), classifier, true)).To(Succeed())
+
+		currentNode := &corev1.Node{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: node.Name}, currentNode)).To(Succeed())
+		Expect(currentNode.Labels).To(HaveKeyWithValue(key, value))
+
+		currentConfigMap := &corev1.ConfigMap{}
+		Expect(c.Get(context.TODO(),
+			types.NamespacedName{Namespace: configMap.Namespace, Name: configMap.Name}, currentConfigMap)).To(Succeed())
+		Expect(currentConfigMap.Annotations).To(HaveKeyWithValue(key, value))
+
+		Expect(classification.ProcessActions(manager, context.TODO(), classifier, false)).To(Succeed())
+
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: node.Name}, currentNode)).To(Succeed())
+		Expect(currentNode.Labels).ToNot(HaveKey(key))
+
+		Expect(c.Get(context.TODO(),
+			types.NamespacedName{Namespace: configMap.Namespace, Name: configMap.Name}, currentConfigMap)).To(Succeed())
+		Expect(currentConfigMap.Annotations).ToNot(HaveKey(key))
+	})
+	It("processActions skips actions not allowed and does not execute actions in dry run", func() {
+		key := randomString()
+		value := randomString()
+		node := &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: randomString(),
+			},
+		}
+
+		classifier := getClassifierWithKubernetesConstraints(version25, libsveltosv1alpha1.ComparisonEqual)
+		classifier.Annotations = map[string]string{
+			classification.ClassifierExtensionAnnotation: fmt.Sprintf(`actions:
+- type: Label
+  target: Nodes
+  labels:
+    %s: %s`, key, value),
+		}
+
+		c := classification.InitializeManagerWithObjects(nil, node, classifier)
+
+		manager := classification.GetManager()
+		Expect(manager).ToNot(BeNil())
+
+		classification.ApplyOptions(classification.WithAllowedActions(
+			[]classification.ActionType{classification.ActionTypeEvent}))
+		Expect(classification.ProcessActions(manager, context.TODO(), classifier, true)).To(Succeed())
+
+		currentNode := &corev1.Node{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: node.Name}, currentNode)).To(Succeed())
+		Expect(currentNode.Labels).ToNot(HaveKey(key))
+
+		classification.ApplyOptions(classification.WithAllowedActions(
+			[]classification.ActionType{classification.ActionTypeLabel}),
+			classification.WithActionsDryRun(true))
+		Expect(classification.ProcessActions(manager, context.TODO(), classifier, true)).To(Succeed())
+
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: node.Name}, currentNode)).To(Succeed())
+		Expect(currentNode.Labels).ToNot(HaveKey(key))
+	})
+
+	It("processActions executes no action by default", func() {
+		key := randomString()
+		value := randomString()
+		node := &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: randomString(),
+			},
+		}
+
+		classifier := getClassifierWithKubernetesConstraints(version25, libsveltosv1alpha1.ComparisonEqual)
+		classifier.Annotations = map[string]string{
+			classification.ClassifierExtensionAnnotation: fmt.Sprintf(`actions:
+- target: Nodes
+  labels:
+    %s: %s`, key, value),
+		}
+
+		c := classification.InitializeManagerWithObjects(nil, node, classifier)
+
+		manager := classification.GetManager()
+		Expect(manager).ToNot(BeNil())
+
+		Expect(classification.ProcessActions(manager, context.TODO(), classifier, true)).To(Succeed())
+
+		currentNode := &corev1.Node{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: node.Name}, currentNode)).To(Succeed())
+		Expect(currentNode.Labels).ToNot(HaveKey(key))
+	})
+
+	It("processActions rejects ConfigMap targets in namespaces not allowed", func() {
+		namespace := randomString()
+		classifier := getClassifierWithKubernetesConstraints(version25, libsveltosv1alpha1.ComparisonEqual)
+		classifier.Annotations = map[string]string{
+			classification.ClassifierExtensionAnnotation: fmt.Sprintf(`actions:
+- target: ConfigMap
+  namespace: %s
+  name: %s
+  labels:
+    env: production`, namespace, randomString()),
+		}
+
+		c := classification.InitializeManagerWithObjects(nil, classifier)
+
+		manager := classification.GetManager()
+		Expect(manager).ToNot(BeNil())
+
+		classification.ApplyOptions(
+			classification.WithAllowedActions([]classification.ActionType{classification.ActionTypeLabel}),
+			classification.WithActionConfigMapNamespaces([]string{randomString()}))
+		err := classification.ProcessActions(manager, context.TODO(), classifier, true)
+		Expect(err).To(HaveOccurred())
+		Expect(classification.IsInvalidClassifierError(err)).To(BeTrue())
+
+		configMaps := &corev1.ConfigMapList{}
+		Expect(c.List(context.TODO(), configMaps, client.InNamespace(namespace))).To(Succeed())
+		Expect(configMaps.Items).To(BeEmpty())
+	})
+
+	It("processActions runs all actions and reports invalid ones as invalid classifier", func() {
+		key := randomString()
+		value := randomString()
+		node := &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: randomString(),
+			},
+		}
+
+		classifier := getClassifierWithKubernetesConstraints(version25, libsveltosv1alpha1.ComparisonEqual)
+		classifier.Annotations = map[string]string{
+			classification.ClassifierExtensionAnnotation: fmt.Sprintf(`actions:
+- type: Label
+  target: Pods
+  labels:
+    %s: %s
+- type: Label
+  target: Nodes
+  labels:
+    %s: %s`, key, value, key, value),
+		}
+
+		c := classification.InitializeManagerWithObjects(nil, node, classifier)
+
+		manager := classification.GetManager()
+		Expect(manager).ToNot(BeNil())
+
+		classification.ApplyOptions(classification.WithAllowedActions(
+			[]classification.ActionType{classification.ActionTypeLabel}))
+		err := classification.ProcessActions(manager, context.TODO(), classifier, true)
+		Expect(err).To(HaveOccurred())
+		Expect(classification.IsInvalidClassifierError(err)).To(BeTrue())
+
+		currentNode := &corev1.Node{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: node.Name}, currentNode)).To(Succeed())
+		Expect(currentNode.Labels).To(HaveKeyWithValue(key, value))
+	})
+
+	It("processActions removes labels and annotations no longer configured", func() {
+		key := randomString()
+		otherKey := randomString()
+		value := randomString()
+		node := &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: randomString(),
+			},
+		}
+
+		classifier := getClassifierWithKubernetesConstraints(version25, libsveltosv1alpha1.ComparisonEqual)
+		classifier.Annotations = map[string]string{
+			classification.ClassifierExtensionAnnotation: fmt.Sprintf(`actions:
+- target: Nodes
+  labels:
+    %s: %s
+- target: Nodes
+  labels:
+    %s: %s
+  annotations:
+    %s: %s`, key, value, otherKey, value, otherKey, value),
+		}
+
+		c := classification.InitializeManagerWithObjects(nil, node, classifier)
+
+		manager := classification.GetManager()
+		Expect(manager).ToNot(BeNil())
+
+		classification.ApplyOptions(classification.WithAllowedActions(
+			[]classification.ActionType{classification.ActionTypeLabel}))
+		Expect(classification.ProcessActions(manager, context.TODO(), classifier, true)).To(Succeed())
+
+		currentNode := &corev1.Node{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: node.Name}, currentNode)).To(Succeed())
+		Expect(currentNode.Labels).To(HaveKeyWithValue(key, value))
+		Expect(currentNode.Labels).To(HaveKeyWithValue(otherKey, value))
+		Expect(currentNode.Annotations).To(HaveKeyWithValue(otherKey, value))
+		Expect(currentNode.Annotations).To(HaveKey(classification.ActionsAppliedAnnotation))
+
+		// Second action is removed from Classifier
+		classifier.Annotations[classification.ClassifierExtensionAnnotation] = fmt.Sprintf(`actions:
+- target: Nodes
+  labels:
+    %s: %s`, key, value)
+		Expect(classification.ProcessActions(manager, context.TODO(), classifier, true)).To(Succeed())
+
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: node.Name}, currentNode)).To(Succeed())
+		Expect(currentNode.Labels).To(HaveKeyWithValue(key, value))
+		Expect(currentNode.Labels).ToNot(HaveKey(otherKey))
+		Expect(currentNode.Annotations).ToNot(HaveKey(otherKey))
+
+		Expect(manager.UndoActions(context.TODO(), classifier)).To(Succeed())
+
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: node.Name}, currentNode)).To(Succeed())
+		Expect(currentNode.Labels).ToNot(HaveKey(key))
+		Expect(currentNode.Annotations).ToNot(HaveKey(classification.ActionsAppliedAnnotation))
+	})
+
+	It("UndoActions removes labels and ignores invalid actions", func() {
+		key := randomString()
+		value := randomString()
+		node := &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   randomString(),
+				Labels: map[string]string{key: value},
+			},
+		}
+
+		classifier := getClassifierWithKubernetesConstraints(version25, libsveltosv1alpha1.ComparisonEqual)
+		classifier.Annotations = map[string]string{
+			classification.ClassifierExtensionAnnotation: fmt.Sprintf(`actions:
+- target: Pods
+  labels:
+    %s: %s
+- target: Nodes
+  labels:
+    %s: %s`, key, value, key, value),
+		}
+
+		c := classification.InitializeManagerWithObjects(nil, node, classifier)
+
+		manager := classification.GetManager()
+		Expect(manager).ToNot(BeNil())
+
+		classification.ApplyOptions(classification.WithAllowedActions(
+			[]classification.ActionType{classification.ActionTypeLabel}))
+		Expect(manager.UndoActions(context.TODO(), classifier)).To(Succeed())
+
+		currentNode := &corev1.Node{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Name: node.Name}, currentNode)).To(Succeed())
+		Expect(currentNode.Labels).ToNot(HaveKey(key))
+	})
+
+	It("processActions posts match status to webhook when match changes", func() {
+		payloads := make(chan classification.WebhookPayload, 1)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			payload := classification.WebhookPayload{}
+			Expect(json.NewDecoder(r.Body).Decode(&payload)).To(Succeed())
+			payloads <- payload
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		classifier := getClassifierWithKubernetesConstraints(version25, libsveltosv1alpha1.ComparisonEqual)
+		classifier.Annotations = map[string]string{
+			classification.ClassifierExtensionAnnotation: fmt.Sprintf(`actions:
+- type: Webhook
+  url: %s`, server.URL),
+		}
+
+		classification.InitializeManagerWithObjects(nil, classifier)
+
+		manager := classification.GetManager()
+		Expect(manager).ToNot(BeNil())
+
+		classification.ApplyOptions(classification.WithAllowedActions(
+			[]classification.ActionType{classification.ActionTypeWebhook}))
+		Expect(classification.ProcessActions(manager, context.TODO(), classifier, true)).To(Succeed())
+
+		payload := <-payloads
+		Expect(payload.ClassifierName).To(Equal(classifier.Name))
+		Expect(payload.Match).To(BeTrue())
+
+		// Match result did not change
+		Expect(classification.ProcessActions(manager, context.TODO(), classifier, true)).To(Succeed())
+		Expect(payloads).ToNot(Receive())
+
+		Expect(classification.ProcessActions(manager, context.TODO(), classifier, false)).To(Succeed())
+		payload = <-payloads
+		Expect(payload.Match).To(BeFalse())
+
+		// Nothing to undo for webhooks
+		Expect(manager.UndoActions(context.TODO(), classifier)).To(Succeed())
+		Expect(payloads).ToNot(Receive())
+	})
+})
@@ -0,0 +1,150 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+)
+
+// ageCrossingMargin is added to the time a resource crosses an age bound, so that
+// the resource is past the bound when Classifier is evaluated again
+const ageCrossingMargin = time.Second
+
+// ageFilter selects resources by age, computed from their creationTimestamp
+type ageFilter struct {
+	min *time.Duration
+	max *time.Duration
+	now time.Time
+	// next is the earliest time a resource satisfying all other filters crosses
+	// an age bound. Zero if no resource will.
+	next time.Time
+}
+
+// getAgeFilter returns the ageFilter for constraint. Returns nil if constraint sets
+// neither MinAge nor MaxAge.
+func getAgeFilter(constraint *ResourceConstraint, now time.Time) (*ageFilter, error) {
+	if constraint.MinAge == nil && constraint.MaxAge == nil {
+		return nil, nil
+	}
+
+	filter := &ageFilter{now: now}
+	if constraint.MinAge != nil {
+		if constraint.MinAge.Duration < 0 {
+			return nil, fmt.Errorf("minAge %s is negative", constraint.MinAge.Duration)
+		}
+		filter.min = &constraint.MinAge.Duration
+	}
+	if constraint.MaxAge != nil {
+		if constraint.MaxAge.Duration < 0 {
+			return nil, fmt.Errorf("maxAge %s is negative", constraint.MaxAge.Duration)
+		}
+		filter.max = &constraint.MaxAge.Duration
+	}
+	if filter.min != nil && filter.max != nil && *filter.min > *filter.max {
+		return nil, fmt.Errorf("minAge %s is greater than maxAge %s", *filter.min, *filter.max)
+	}
+	return filter, nil
+}
+
+// isAMatch returns true if resource age is within bounds. It records when resource
+// will cross the next bound: MinAge for a resource too young, MaxAge otherwise.
+func (f *ageFilter) isAMatch(resource *unstructured.Unstructured) bool {
+	created := resource.GetCreationTimestamp().Time
+	age := f.now.Sub(created)
+
+	if f.min != nil && age < *f.min {
+		f.record(created.Add(*f.min))
+		return false
+	}
+	if f.max != nil {
+		if age > *f.max {
+			return false
+		}
+		f.record(created.Add(*f.max))
+	}
+	return true
+}
+
+func (f *ageFilter) record(crossing time.Time) {
+	if f.next.IsZero() || crossing.Before(f.next) {
+		f.next = crossing
+	}
+}
+
+// ageEvaluation is a Classifier evaluation scheduled for when a resource crosses
+// an age bound, or an Event occurrence leaves an EventConstraint window
+type ageEvaluation struct {
+	due   time.Time
+	timer *time.Timer
+}
+
+// scheduleAgeEvaluation queues classifier for evaluation once the first resource
+// evaluated by query crosses an age bound, as the verdict may change then.
+// Only the earliest evaluation is kept per Classifier.
+func (m *manager) scheduleAgeEvaluation(classifier *libsveltosv1alpha1.Classifier, query *resourceQuery) {
+	if classifier == nil || query.age == nil || query.age.next.IsZero() {
+		return
+	}
+
+	m.scheduleEvaluation(classifier.Name, query.age.next.Add(ageCrossingMargin),
+		fmt.Sprintf("%s crosses an age bound", query.gvk.Kind))
+}
+
+// scheduleEvaluation queues Classifier name for evaluation at due, on the cluster clock.
+// reason is only logged. Only the earliest evaluation is kept per Classifier.
+func (m *manager) scheduleEvaluation(name string, due time.Time, reason string) {
+	m.ageMu.Lock()
+	defer m.ageMu.Unlock()
+
+	if scheduled, ok := m.ageEvaluations[name]; ok {
+		if !scheduled.due.After(due) {
+			// An evaluation at least as early is already scheduled
+			return
+		}
+		scheduled.timer.Stop()
+	}
+
+	m.log.V(logs.LogDebug).Info(fmt.Sprintf("classifier %s: %s. Evaluating again at %s",
+		name, reason, due.Format(time.RFC3339)))
+	evaluation := &ageEvaluation{due: due}
+	evaluation.timer = time.AfterFunc(due.Sub(m.getClusterTime()), func() {
+		m.ageMu.Lock()
+		if m.ageEvaluations[name] == evaluation {
+			delete(m.ageEvaluations, name)
+		}
+		m.ageMu.Unlock()
+		m.EvaluateClassifier(name)
+	})
+	m.ageEvaluations[name] = evaluation
+}
+
+// removeAgeEvaluation cancels the evaluation scheduled for a Classifier, if any
+func (m *manager) removeAgeEvaluation(classifierName string) {
+	m.ageMu.Lock()
+	defer m.ageMu.Unlock()
+
+	if scheduled, ok := m.ageEvaluations[classifierName]; ok {
+		scheduled.timer.Stop()
+		delete(m.ageEvaluations, classifierName)
+	}
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: age constraints", func() {
+	var server *httptest.Server
+	var classifier *libsveltosv1alpha1.Classifier
+	var mu sync.Mutex
+	// ages are the ages of the listed widgets
+	var ages []time.Duration
+
+	BeforeEach(func() {
+		ages = []time.Duration{2 * time.Hour, 30 * time.Minute}
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			switch r.URL.Path {
+			case "/api":
+				_, _ = w.Write([]byte(`{"kind":"APIVersions","versions":[]}`))
+			case "/apis":
+				_, _ = w.Write([]byte(`{"kind":"APIGroupList","apiVersion":"v1","groups":[{"name":"example.com",` +
+					`"versions":[{"groupVersion":"example.com/v1","version":"v1"}],` +
+					`"preferredVersion":{"groupVersion":"example.com/v1","version":"v1"}}]}`))
+			case "/apis/example.com/v1":
+				_, _ = w.Write([]byte(`{"kind":"APIResourceList","apiVersion":"v1","groupVersion":"example.com/v1",` +
+					`"resources":[{"name":"widgets","singularName":"widget","namespaced":false,"kind":"Widget",` +
+					`"verbs":["list"]}]}`))
+			case "/apis/example.com/v1/widgets":
+				mu.Lock()
+				items := make([]string, len(ages))
+				for i := range ages {
+					created := time.Now().Add(-ages[i]).UTC().Format(time.RFC3339)
+					items[i] = fmt.Sprintf(`{"apiVersion":"example.com/v1","kind":"Widget",`+
+						`"metadata":{"name":"w%d","creationTimestamp":%q}}`, i, created)
+				}
+				mu.Unlock()
+				_, _ = w.Write([]byte(`{"apiVersion":"example.com/v1","kind":"WidgetList","metadata":{},"items":[` +
+					strings.Join(items, ",") + `]}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+
+		classifier = &libsveltosv1alpha1.Classifier{ObjectMeta: metav1.ObjectMeta{Name: randomString()}}
+
+		classification.InitializeManagerWithObjects(&rest.Config{Host: server.URL})
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	getAgeConstraint := func(minAge, maxAge time.Duration, count int) *classification.ResourceConstraint {
+		constraint := &classification.ResourceConstraint{
+			DeployedResourceConstraint: libsveltosv1alpha1.DeployedResourceConstraint{
+				Group: "example.com", Version: "v1", Kind: "Widget", MinCount: &count, MaxCount: &count,
+			},
+		}
+		if minAge != 0 {
+			constraint.MinAge = &metav1.Duration{Duration: minAge}
+		}
+		if maxAge != 0 {
+			constraint.MaxAge = &metav1.Duration{Duration: maxAge}
+		}
+		return constraint
+	}
+
+	It("isResourceConstraintAMatch only counts resources older than MinAge", func() {
+		isMatch, err := classification.IsResourceConstraintAMatch(classification.GetManager(), context.TODO(),
+			classifier, getAgeConstraint(time.Hour, 0, 1))
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+
+		// Evaluated again once the youngest widget is one hour old
+		due, ok := classification.GetAgeEvaluation(classifier.Name)
+		Expect(ok).To(BeTrue())
+		Expect(due).To(BeTemporally("~", time.Now().Add(30*time.Minute), 5*time.Second))
+	})
+
+	It("isResourceConstraintAMatch only counts resources younger than MaxAge", func() {
+		isMatch, err := classification.IsResourceConstraintAMatch(classification.GetManager(), context.TODO(),
+			classifier, getAgeConstraint(0, time.Hour, 1))
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+
+		// Evaluated again once the youngest widget is older than one hour
+		due, ok := classification.GetAgeEvaluation(classifier.Name)
+		Expect(ok).To(BeTrue())
+		Expect(due).To(BeTemporally("~", time.Now().Add(30*time.Minute), 5*time.Second))
+
+		isMatch, err = classification.IsResourceConstraintAMatch(classification.GetManager(), context.TODO(),
+			classifier, getAgeConstraint(time.Hour, 3*time.Hour, 1))
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+	})
+
+	It("Classifier is queued when a resource crosses an age bound", func() {
+		mu.Lock()
+		ages = []time.Duration{time.Hour - time.Second}
+		mu.Unlock()
+
+		isMatch, err := classification.IsResourceConstraintAMatch(classification.GetManager(), context.TODO(),
+			classifier, getAgeConstraint(time.Hour, 0, 1))
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeFalse())
+
+		Eventually(classification.GetJobQueue, 5*time.Second, 100*time.Millisecond).Should(
+			ContainElement(classifier.Name))
+		_, ok := classification.GetAgeEvaluation(classifier.Name)
+		Expect(ok).To(BeFalse())
+	})
+
+	It("isResourceConstraintAMatch rejects MinAge greater than MaxAge", func() {
+		_, err := classification.IsResourceConstraintAMatch(classification.GetManager(), context.TODO(),
+			classifier, getAgeConstraint(2*time.Hour, time.Hour, 1))
+		Expect(err).ToNot(BeNil())
+		Expect(classification.IsInvalidClassifierError(err)).To(BeTrue())
+	})
+})
@@ -0,0 +1,140 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Facts about the classifier-agent environment. Those can be matched by FactFilters
+// like the static facts declared in the FactsConfigMap, which cannot override them.
+const (
+	// AgentNamespaceFact is the namespace classifier-agent runs in
+	AgentNamespaceFact = "agent.projectsveltos.io/namespace"
+
+	// AgentImageFact is the classifier-agent container image
+	AgentImageFact = "agent.projectsveltos.io/image"
+
+	// AgentImageTagFact is the tag of the classifier-agent container image.
+	// Not set when image is referenced by digest only.
+	AgentImageTagFact = "agent.projectsveltos.io/image-tag"
+
+	// ClusterDomainFact is the cluster DNS domain, for instance cluster.local
+	ClusterDomainFact = "agent.projectsveltos.io/cluster-domain"
+)
+
+const (
+	agentContainerName = "manager"
+	resolvConfPath     = "/etc/resolv.conf"
+)
+
+// getAgentFacts returns the facts about the classifier-agent environment.
+// Those do not change during the agent lifetime, so they are collected once.
+func (m *manager) getAgentFacts(ctx context.Context) (map[string]string, error) {
+	m.agentMu.Lock()
+	defer m.agentMu.Unlock()
+
+	if m.agentFacts != nil {
+		return m.agentFacts, nil
+	}
+
+	facts := make(map[string]string)
+	if m.agentNamespace != "" {
+		facts[AgentNamespaceFact] = m.agentNamespace
+	}
+
+	if m.agentNamespace != "" && m.agentPodName != "" {
+		// Pod is read directly, so that no Pod informer is started for a single Get
+		clientset, err := kubernetes.NewForConfig(m.config)
+		if err != nil {
+			return nil, err
+		}
+		pod, err := clientset.CoreV1().Pods(m.agentNamespace).Get(ctx, m.agentPodName, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		if image := getAgentImage(pod); image != "" {
+			facts[AgentImageFact] = image
+			if tag := getImageTag(image); tag != "" {
+				facts[AgentImageTagFact] = tag
+			}
+		}
+	}
+
+	if resolvConf, err := os.Open(resolvConfPath); err == nil {
+		if domain := getClusterDomain(resolvConf); domain != "" {
+			facts[ClusterDomainFact] = domain
+		}
+		resolvConf.Close()
+	}
+
+	m.agentFacts = facts
+	return facts, nil
+}
+
+// getAgentImage returns the image of the classifier-agent container, or of the
+// first container if none is named manager
+func getAgentImage(pod *corev1.Pod) string {
+	for i := range pod.Spec.Containers {
+		if pod.Spec.Containers[i].Name == agentContainerName {
+			return pod.Spec.Containers[i].Image
+		}
+	}
+	if len(pod.Spec.Containers) > 0 {
+		return pod.Spec.Containers[0].Image
+	}
+	return ""
+}
+
+// getImageTag returns the tag of image, for instance v0.9.0 for
+// projectsveltos/classifier-agent:v0.9.0@sha256:... Registry port is not a tag.
+func getImageTag(image string) string {
+	if index := strings.Index(image, "@"); index >= 0 {
+		image = image[:index]
+	}
+	name := image[strings.LastIndex(image, "/")+1:]
+	if index := strings.LastIndex(name, ":"); index >= 0 {
+		return name[index+1:]
+	}
+	return ""
+}
+
+// getClusterDomain returns the cluster DNS domain from the search domains of a
+// Pod resolv.conf: cluster.local for "search ns.svc.cluster.local svc.cluster.local cluster.local"
+func getClusterDomain(resolvConf io.Reader) string {
+	scanner := bufio.NewScanner(resolvConf)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || fields[0] != "search" {
+			continue
+		}
+		for _, domain := range fields[1:] {
+			if strings.HasPrefix(domain, "svc.") {
+				return strings.TrimSuffix(strings.TrimPrefix(domain, "svc."), ".")
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2/klogr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+)
+
+var _ = Describe("Manager: agent environment facts", func() {
+	BeforeEach(func() {
+		classification.Reset()
+	})
+
+	It("getImageTag returns the image tag", func() {
+		Expect(classification.GetImageTag("projectsveltos/classifier-agent:v0.9.0")).To(Equal("v0.9.0"))
+		Expect(classification.GetImageTag("registry:5000/projectsveltos/classifier-agent")).To(BeEmpty())
+		Expect(classification.GetImageTag("registry:5000/classifier-agent:main@sha256:abcd")).To(Equal("main"))
+		Expect(classification.GetImageTag("classifier-agent@sha256:abcd")).To(BeEmpty())
+	})
+
+	It("getClusterDomain returns the cluster domain from resolv.conf search domains", func() {
+		resolvConf := `nameserver 10.96.0.10
+search projectsveltos.svc.cluster.example svc.cluster.example cluster.example
+options ndots:5
+`
+		Expect(classification.GetClusterDomain(strings.NewReader(resolvConf))).To(Equal("cluster.example"))
+		Expect(classification.GetClusterDomain(strings.NewReader("nameserver 8.8.8.8\n"))).To(BeEmpty())
+	})
+
+	It("getAgentFacts reports namespace and image of the agent Pod", func() {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			if r.URL.Path != "/api/v1/namespaces/projectsveltos/pods/classifier-agent-abc" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			requests++
+			_, _ = w.Write([]byte(`{"apiVersion":"v1","kind":"Pod","metadata":{"name":"classifier-agent-abc",` +
+				`"namespace":"projectsveltos"},"spec":{"containers":[{"name":"kube-rbac-proxy","image":"proxy:v1"},` +
+				`{"name":"manager","image":"projectsveltos/classifier-agent:v0.9.0"}]}}`))
+		}))
+		defer server.Close()
+
+		config := &rest.Config{Host: server.URL, ContentConfig: rest.ContentConfig{ContentType: "application/json"}}
+		classification.InitializeManagerWithSkip(context.TODO(), klogr.New(), config,
+			fake.NewClientBuilder().Build(), nil, 10)
+		classification.ApplyOptions(classification.WithAgentPod("projectsveltos", "classifier-agent-abc"))
+		manager := classification.GetManager()
+
+		facts, err := classification.GetAgentFacts(manager, context.TODO())
+		Expect(err).To(BeNil())
+		Expect(facts).To(HaveKeyWithValue(classification.AgentNamespaceFact, "projectsveltos"))
+		Expect(facts).To(HaveKeyWithValue(classification.AgentImageFact, "projectsveltos/classifier-agent:v0.9.0"))
+		Expect(facts).To(HaveKeyWithValue(classification.AgentImageTagFact, "v0.9.0"))
+
+		// Facts are collected once
+		_, err = classification.GetAgentFacts(manager, context.TODO())
+		Expect(err).To(BeNil())
+		Expect(requests).To(Equal(1))
+	})
+})
@@ -0,0 +1,132 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+// annotationFilterMatcher evaluates an AnnotationFilter. The API server cannot
+// select resources by annotation, so all AnnotationFilters are evaluated by
+// classifier-agent.
+type annotationFilterMatcher struct {
+	key       string
+	operation libsveltosv1alpha1.Operation
+	values    map[string]bool
+	regex     *regexp.Regexp
+}
+
+// getAnnotationFilterMatchers returns a matcher per AnnotationFilter.
+// AnnotationFilters support the same operations as LabelFilters.
+func getAnnotationFilterMatchers(filters []libsveltosv1alpha1.LabelFilter,
+	compile regexCompiler) ([]annotationFilterMatcher, error) {
+
+	return getKeyValueFilterMatchers("annotation filter", filters, compile)
+}
+
+// getKeyValueFilterMatchers returns a matcher per filter. subject is used in
+// error messages.
+func getKeyValueFilterMatchers(subject string, filters []libsveltosv1alpha1.LabelFilter,
+	compile regexCompiler) ([]annotationFilterMatcher, error) {
+
+	matchers := make([]annotationFilterMatcher, 0, len(filters))
+	for i := range filters {
+		matcher, err := getAnnotationFilterMatcher(subject, &filters[i], compile)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, *matcher)
+	}
+	return matchers, nil
+}
+
+func getAnnotationFilterMatcher(subject string, f *libsveltosv1alpha1.LabelFilter,
+	compile regexCompiler) (*annotationFilterMatcher, error) {
+
+	if errs := validation.IsQualifiedName(f.Key); len(errs) != 0 {
+		return nil, fmt.Errorf("%s %q: invalid key: %s", subject, f.Key, strings.Join(errs, "; "))
+	}
+
+	matcher := &annotationFilterMatcher{key: f.Key, operation: f.Operation}
+	switch f.Operation {
+	case libsveltosv1alpha1.OperationEqual, libsveltosv1alpha1.OperationDifferent:
+		matcher.values = map[string]bool{f.Value: true}
+	case OperationIn, OperationNotIn:
+		matcher.values = make(map[string]bool)
+		for _, v := range strings.Split(f.Value, ",") {
+			matcher.values[strings.TrimSpace(v)] = true
+		}
+	case OperationExists, OperationDoesNotExist:
+		if f.Value != "" {
+			return nil, fmt.Errorf("%s %q: operation %s does not accept a value", subject, f.Key, f.Operation)
+		}
+	case OperationMatchRegex, OperationNotMatchRegex:
+		regex, err := compile(f.Value)
+		if err != nil {
+			return nil, err
+		}
+		matcher.regex = regex
+	default:
+		return nil, fmt.Errorf("%s %q: unsupported operation %q", subject, f.Key, f.Operation)
+	}
+	return matcher, nil
+}
+
+// isAMatch returns true if object satisfies the filter. As with label
+// selectors, Different, NotIn, DoesNotExist and NotMatchRegex are satisfied
+// when annotation is absent.
+func (a *annotationFilterMatcher) isAMatch(object map[string]interface{}) bool {
+	value, found, err := unstructured.NestedString(object, "metadata", "annotations", a.key)
+	return a.isValueAMatch(value, found && err == nil)
+}
+
+// isValueAMatch returns true if value satisfies the filter. found is false
+// when the key is absent.
+func (a *annotationFilterMatcher) isValueAMatch(value string, found bool) bool {
+	switch a.operation {
+	case libsveltosv1alpha1.OperationEqual, OperationIn:
+		return found && a.values[value]
+	case libsveltosv1alpha1.OperationDifferent, OperationNotIn:
+		return !found || !a.values[value]
+	case OperationExists:
+		return found
+	case OperationDoesNotExist:
+		return !found
+	case OperationMatchRegex:
+		return found && a.regex.MatchString(value)
+	case OperationNotMatchRegex:
+		return !found || !a.regex.MatchString(value)
+	default:
+		return false
+	}
+}
+
+func areAnnotationFilterMatchersAMatch(object map[string]interface{}, matchers []annotationFilterMatcher) bool {
+	for i := range matchers {
+		if !matchers[i].isAMatch(object) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: annotation filters", func() {
+	var resources []unstructured.Unstructured
+
+	BeforeEach(func() {
+		resources = nil
+		for name, annotations := range map[string]map[string]string{
+			"helm":     {"meta.helm.sh/release-name": "nginx", "installer": "helm"},
+			"argo":     {"argocd.argoproj.io/tracking-id": "app:apps/Deployment:default/argo", "installer": "argo"},
+			"no-owner": nil,
+		} {
+			u := unstructured.Unstructured{}
+			u.SetName(name)
+			u.SetAnnotations(annotations)
+			resources = append(resources, u)
+		}
+	})
+
+	filter := func(filters ...libsveltosv1alpha1.LabelFilter) []string {
+		items, err := classification.FilterResourcesWithAnnotations(
+			append([]unstructured.Unstructured{}, resources...), filters)
+		Expect(err).To(BeNil())
+		names := make([]string, len(items))
+		for i := range items {
+			names[i] = items[i].GetName()
+		}
+		return names
+	}
+
+	It("AnnotationFilters support all LabelFilter operations", func() {
+		Expect(filter(libsveltosv1alpha1.LabelFilter{Key: "installer",
+			Operation: libsveltosv1alpha1.OperationEqual, Value: "helm"})).To(ConsistOf("helm"))
+		Expect(filter(libsveltosv1alpha1.LabelFilter{Key: "installer",
+			Operation: libsveltosv1alpha1.OperationDifferent, Value: "helm"})).To(ConsistOf("argo", "no-owner"))
+		Expect(filter(libsveltosv1alpha1.LabelFilter{Key: "installer",
+			Operation: classification.OperationIn, Value: "helm, argo"})).To(ConsistOf("helm", "argo"))
+		Expect(filter(libsveltosv1alpha1.LabelFilter{Key: "installer",
+			Operation: classification.OperationNotIn, Value: "helm,argo"})).To(ConsistOf("no-owner"))
+		Expect(filter(libsveltosv1alpha1.LabelFilter{Key: "meta.helm.sh/release-name",
+			Operation: classification.OperationExists})).To(ConsistOf("helm"))
+		Expect(filter(libsveltosv1alpha1.LabelFilter{Key: "meta.helm.sh/release-name",
+			Operation: classification.OperationDoesNotExist})).To(ConsistOf("argo", "no-owner"))
+		Expect(filter(libsveltosv1alpha1.LabelFilter{Key: "argocd.argoproj.io/tracking-id",
+			Operation: classification.OperationMatchRegex, Value: "app:.*"})).To(ConsistOf("argo"))
+		Expect(filter(libsveltosv1alpha1.LabelFilter{Key: "argocd.argoproj.io/tracking-id",
+			Operation: classification.OperationNotMatchRegex, Value: "app:.*"})).To(ConsistOf("helm", "no-owner"))
+
+		// All filters must be satisfied
+		Expect(filter(
+			libsveltosv1alpha1.LabelFilter{Key: "installer", Operation: classification.OperationExists},
+			libsveltosv1alpha1.LabelFilter{Key: "installer", Operation: libsveltosv1alpha1.OperationDifferent,
+				Value: "argo"},
+		)).To(ConsistOf("helm"))
+	})
+
+	It("AnnotationFilters values are not restricted to valid label values", func() {
+		Expect(filter(libsveltosv1alpha1.LabelFilter{Key: "argocd.argoproj.io/tracking-id",
+			Operation: libsveltosv1alpha1.OperationEqual,
+			Value:     "app:apps/Deployment:default/argo"})).To(ConsistOf("argo"))
+	})
+
+	It("invalid AnnotationFilters are rejected", func() {
+		for _, f := range []libsveltosv1alpha1.LabelFilter{
+			{Key: "not a valid key", Operation: classification.OperationExists},
+			{Key: "installer", Operation: classification.OperationExists, Value: "helm"},
+			{Key: "installer", Operation: classification.OperationMatchRegex, Value: "("},
+			{Key: "installer", Operation: libsveltosv1alpha1.Operation("Unknown"), Value: "helm"},
+		} {
+			_, err := classification.FilterResourcesWithAnnotations(resources, []libsveltosv1alpha1.LabelFilter{f})
+			Expect(err).ToNot(BeNil())
+		}
+	})
+})
@@ -0,0 +1,178 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"emperror.dev/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/restmapper"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+)
+
+// apiServiceGVK is watched by Classifiers with APIResourceConstraints,
+// along with crdGVK, as served APIs change when either changes
+var apiServiceGVK = schema.GroupVersionKind{
+	Group:   "apiregistration.k8s.io",
+	Version: "v1",
+	Kind:    "APIService",
+}
+
+// areAPIResourcesAMatch returns true if all APIResourceConstraints are satisfied
+func (m *manager) areAPIResourcesAMatch(ctx context.Context,
+	classifier *libsveltosv1alpha1.Classifier) (bool, error) {
+
+	extension, err := getClassifierExtension(classifier)
+	if err != nil {
+		return false, err
+	}
+
+	if len(extension.APIResourceConstraints) == 0 {
+		return true, nil
+	}
+
+	dc, err := m.getDiscoveryClient()
+	if err != nil {
+		return false, err
+	}
+
+	for i := range extension.APIResourceConstraints {
+		isMatch, err := isAPIResourceConstraintAMatch(dc, &extension.APIResourceConstraints[i])
+		if err != nil || !isMatch {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// isAPIResourceConstraintAMatch returns true if the cluster serves the constraint
+// group/version and, if set, kind
+func isAPIResourceConstraintAMatch(dc discovery.DiscoveryInterface, constraint *APIResourceConstraint) (bool, error) {
+	if constraint.Version == "" {
+		return false, newInvalidClassifierError(errors.New("api resource constraint version is required"))
+	}
+
+	groupVersion := schema.GroupVersion{Group: constraint.Group, Version: constraint.Version}
+
+	groups, err := dc.ServerGroups()
+	if err != nil {
+		return false, err
+	}
+
+	if !isGroupVersionServed(groups, groupVersion.String()) {
+		return false, nil
+	}
+
+	if constraint.Kind == "" {
+		return true, nil
+	}
+
+	resources, err := dc.ServerResourcesForGroupVersion(groupVersion.String())
+	if err != nil {
+		if apierrors.IsNotFound(err) || errors.Is(err, memory.ErrCacheNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	for i := range resources.APIResources {
+		if resources.APIResources[i].Kind == constraint.Kind {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func isGroupVersionServed(groups *metav1.APIGroupList, groupVersion string) bool {
+	for i := range groups.Groups {
+		for j := range groups.Groups[i].Versions {
+			if groups.Groups[i].Versions[j].GroupVersion == groupVersion {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// getDiscoveryClient returns the discovery client used for APIResourceConstraints.
+// Discovery results are cached till a CustomResourceDefinition or an APIService changes.
+func (m *manager) getDiscoveryClient() (discovery.CachedDiscoveryInterface, error) {
+	m.discoveryMu.Lock()
+	defer m.discoveryMu.Unlock()
+
+	if m.discoveryClient == nil {
+		dc, err := discovery.NewDiscoveryClientForConfig(m.config)
+		if err != nil {
+			return nil, err
+		}
+		m.discoveryClient = memory.NewMemCacheClient(dc)
+		m.recordFactRefresh(FactDiscovery, time.Now())
+	}
+
+	return m.discoveryClient, nil
+}
+
+// getRESTMapper returns the RESTMapper used to map constraint GVKs to resources.
+// It is backed by the cached discovery client, so it is reset along with it.
+func (m *manager) getRESTMapper() (meta.RESTMapper, error) {
+	dc, err := m.getDiscoveryClient()
+	if err != nil {
+		return nil, err
+	}
+
+	m.discoveryMu.Lock()
+	defer m.discoveryMu.Unlock()
+
+	if m.restMapper == nil {
+		m.restMapper = restmapper.NewDeferredDiscoveryRESTMapper(dc)
+	}
+
+	return m.restMapper, nil
+}
+
+// invalidateDiscoveryCache invalidates cached discovery results if gvk is one
+// of the types defining which APIs are served
+func (m *manager) invalidateDiscoveryCache(gvk *schema.GroupVersionKind) {
+	if gvk == nil || (*gvk != crdGVK && *gvk != apiServiceGVK) {
+		return
+	}
+
+	m.discoveryMu.Lock()
+	defer m.discoveryMu.Unlock()
+
+	if m.discoveryClient != nil {
+		m.log.V(logs.LogDebug).Info(fmt.Sprintf("%s changed: invalidating discovery cache", gvk.Kind))
+		m.discoveryClient.Invalidate()
+		m.recordFactRefresh(FactDiscovery, time.Now())
+	}
+	if m.restMapper != nil {
+		m.restMapper.Reset()
+	}
+}
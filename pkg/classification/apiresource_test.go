@@ -0,0 +1,137 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery/cached/memory"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: api resources", func() {
+	It("isAPIResourceConstraintAMatch verifies group/version and kind are served", func() {
+		dc := &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{}}
+		dc.Resources = []*metav1.APIResourceList{
+			{
+				GroupVersion: "v1",
+				APIResources: []metav1.APIResource{{Name: "pods", Kind: "Pod", Namespaced: true}},
+			},
+			{
+				GroupVersion: "gateway.networking.k8s.io/v1beta1",
+				APIResources: []metav1.APIResource{
+					{Name: "gatewayclasses", Kind: "GatewayClass"},
+					{Name: "gateways", Kind: "Gateway", Namespaced: true},
+				},
+			},
+		}
+
+		type testCase struct {
+			constraint classification.APIResourceConstraint
+			isMatch    bool
+		}
+		testCases := []testCase{
+			{constraint: classification.APIResourceConstraint{Version: "v1", Kind: "Pod"}, isMatch: true},
+			{constraint: classification.APIResourceConstraint{Version: "v1", Kind: "Gateway"}, isMatch: false},
+			{constraint: classification.APIResourceConstraint{Group: "gateway.networking.k8s.io",
+				Version: "v1beta1"}, isMatch: true},
+			{constraint: classification.APIResourceConstraint{Group: "gateway.networking.k8s.io",
+				Version: "v1beta1", Kind: "GatewayClass"}, isMatch: true},
+			{constraint: classification.APIResourceConstraint{Group: "gateway.networking.k8s.io",
+				Version: "v1", Kind: "GatewayClass"}, isMatch: false},
+			{constraint: classification.APIResourceConstraint{Group: "cert-manager.io",
+				Version: "v1"}, isMatch: false},
+		}
+
+		for i := range testCases {
+			isMatch, err := classification.IsAPIResourceConstraintAMatch(dc, &testCases[i].constraint)
+			Expect(err).To(BeNil())
+			Expect(isMatch).To(Equal(testCases[i].isMatch), fmt.Sprintf("%+v", testCases[i].constraint))
+		}
+
+		_, err := classification.IsAPIResourceConstraintAMatch(dc,
+			&classification.APIResourceConstraint{Group: "gateway.networking.k8s.io"})
+		Expect(classification.IsInvalidClassifierError(err)).To(BeTrue())
+	})
+
+	It("GetClassifierGVKs includes CustomResourceDefinition and APIService for api resource constraints", func() {
+		classifier := getClassifierWithKubernetesConstraints(version25, libsveltosv1alpha1.ComparisonEqual)
+		classifier.Annotations = map[string]string{
+			classification.ClassifierExtensionAnnotation: `apiResourceConstraints:
+- group: gateway.networking.k8s.io
+  version: v1beta1
+  kind: GatewayClass`,
+		}
+
+		gvks := classification.GetClassifierGVKs(classifier)
+		Expect(gvks).To(ContainElement(schema.GroupVersionKind{
+			Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"}))
+		Expect(gvks).To(ContainElement(schema.GroupVersionKind{
+			Group: "apiregistration.k8s.io", Version: "v1", Kind: "APIService"}))
+	})
+	It("getRESTMapper reuses discovery results till discovery cache is invalidated", func() {
+		dc := &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{}}
+		dc.Resources = []*metav1.APIResourceList{
+			{
+				GroupVersion: "v1",
+				APIResources: []metav1.APIResource{{Name: "pods", Kind: "Pod", Namespaced: true}},
+			},
+		}
+
+		classification.InitializeManagerWithObjects(nil)
+		manager := classification.GetManager()
+		classification.SetDiscoveryClient(manager, memory.NewMemCacheClient(dc))
+
+		mapper, err := classification.GetRESTMapper(manager)
+		Expect(err).To(BeNil())
+		mapping, err := mapper.RESTMapping(schema.GroupKind{Kind: "Pod"}, "v1")
+		Expect(err).To(BeNil())
+		Expect(mapping.Resource.Resource).To(Equal("pods"))
+		requests := len(dc.Actions())
+
+		gatewayKind := schema.GroupKind{Group: "gateway.networking.k8s.io", Kind: "Gateway"}
+		dc.Resources = append(dc.Resources, &metav1.APIResourceList{
+			GroupVersion: "gateway.networking.k8s.io/v1beta1",
+			APIResources: []metav1.APIResource{{Name: "gateways", Kind: "Gateway", Namespaced: true}},
+		})
+
+		mapper, err = classification.GetRESTMapper(manager)
+		Expect(err).To(BeNil())
+		_, err = mapper.RESTMapping(schema.GroupKind{Kind: "Pod"}, "v1")
+		Expect(err).To(BeNil())
+		_, err = mapper.RESTMapping(gatewayKind, "v1beta1")
+		Expect(meta.IsNoMatchError(err)).To(BeTrue())
+		Expect(len(dc.Actions())).To(Equal(requests))
+
+		// A CustomResourceDefinition changed
+		crdGVK := classification.CRDGVK
+		classification.InvalidateDiscoveryCache(manager, &crdGVK)
+		mapping, err = mapper.RESTMapping(gatewayKind, "v1beta1")
+		Expect(err).To(BeNil())
+		Expect(mapping.Resource.Resource).To(Equal("gateways"))
+	})
+})
@@ -0,0 +1,149 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+// APIServerFeature is an API server capability which can be probed, for clusters
+// where the version alone is not decisive (backports, forks)
+type APIServerFeature string
+
+const (
+	// APIServerFeatureServerSideApply is available when the API server tracks
+	// field managers, which is verified on the kube-system Namespace managedFields
+	APIServerFeatureServerSideApply = APIServerFeature("ServerSideApply")
+
+	// APIServerFeatureValidatingAdmissionPolicy is available when any version
+	// of admissionregistration.k8s.io serves ValidatingAdmissionPolicy
+	APIServerFeatureValidatingAdmissionPolicy = APIServerFeature("ValidatingAdmissionPolicy")
+
+	// APIServerFeatureOpenAPIV3 is available when the API server serves /openapi/v3
+	APIServerFeatureOpenAPIV3 = APIServerFeature("OpenAPIV3")
+)
+
+const (
+	admissionRegistrationGroup = "admissionregistration.k8s.io"
+	openAPIV3Path              = "/openapi/v3"
+)
+
+// areAPIServerFeaturesAMatch returns true if all APIServerFeatures are available
+func (m *manager) areAPIServerFeaturesAMatch(ctx context.Context,
+	classifier *libsveltosv1alpha1.Classifier) (bool, error) {
+
+	extension, err := getClassifierExtension(classifier)
+	if err != nil {
+		return false, err
+	}
+
+	if len(extension.APIServerFeatures) == 0 {
+		return true, nil
+	}
+
+	dc, err := m.getDiscoveryClient()
+	if err != nil {
+		return false, err
+	}
+
+	for _, feature := range extension.APIServerFeatures {
+		available, err := m.isAPIServerFeatureAvailable(ctx, dc, feature)
+		if err != nil {
+			return false, err
+		}
+		if !available {
+			addEvaluationNote(ctx, fmt.Sprintf("api server feature %s is not available", feature))
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// isAPIServerFeatureAvailable probes the API server for feature
+func (m *manager) isAPIServerFeatureAvailable(ctx context.Context, dc discovery.DiscoveryInterface,
+	feature APIServerFeature) (bool, error) {
+
+	switch feature {
+	case APIServerFeatureServerSideApply:
+		return isServerSideApplyAvailable(ctx, m.Client)
+	case APIServerFeatureValidatingAdmissionPolicy:
+		return isValidatingAdmissionPolicyServed(dc)
+	case APIServerFeatureOpenAPIV3:
+		return isOpenAPIV3Served(ctx, dc)
+	default:
+		return false, newInvalidClassifierError(fmt.Errorf("unknown api server feature %q", feature))
+	}
+}
+
+// isServerSideApplyAvailable returns true if the API server tracks field managers.
+// Every object written by an API server with server-side apply has managedFields.
+func isServerSideApplyAvailable(ctx context.Context, c client.Client) (bool, error) {
+	ns := &corev1.Namespace{}
+	if err := c.Get(ctx, client.ObjectKey{Name: kubeSystemNamespace}, ns); err != nil {
+		return false, err
+	}
+	return len(ns.ManagedFields) != 0, nil
+}
+
+// isValidatingAdmissionPolicyServed returns true if any admissionregistration.k8s.io
+// version serves ValidatingAdmissionPolicy
+func isValidatingAdmissionPolicyServed(dc discovery.DiscoveryInterface) (bool, error) {
+	groups, err := dc.ServerGroups()
+	if err != nil {
+		return false, err
+	}
+
+	for i := range groups.Groups {
+		if groups.Groups[i].Name != admissionRegistrationGroup {
+			continue
+		}
+		for j := range groups.Groups[i].Versions {
+			constraint := &APIResourceConstraint{
+				Group:   admissionRegistrationGroup,
+				Version: groups.Groups[i].Versions[j].Version,
+				Kind:    "ValidatingAdmissionPolicy",
+			}
+			served, err := isAPIResourceConstraintAMatch(dc, constraint)
+			if err != nil || served {
+				return served, err
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// isOpenAPIV3Served returns true if the API server serves the OpenAPI v3 discovery document
+func isOpenAPIV3Served(ctx context.Context, dc discovery.DiscoveryInterface) (bool, error) {
+	err := dc.RESTClient().Get().AbsPath(openAPIV3Path).Do(ctx).Error()
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2/klogr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: api server features", func() {
+	var server *httptest.Server
+	var serveOpenAPIV3 bool
+
+	BeforeEach(func() {
+		classification.Reset()
+		serveOpenAPIV3 = false
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			switch r.URL.Path {
+			case "/api":
+				_, _ = w.Write([]byte(`{"kind":"APIVersions","versions":["v1"]}`))
+			case "/api/v1":
+				_, _ = w.Write([]byte(`{"kind":"APIResourceList","groupVersion":"v1","resources":[]}`))
+			case "/apis":
+				_, _ = w.Write([]byte(`{"kind":"APIGroupList","apiVersion":"v1","groups":[{` +
+					`"name":"admissionregistration.k8s.io","versions":[` +
+					`{"groupVersion":"admissionregistration.k8s.io/v1","version":"v1"},` +
+					`{"groupVersion":"admissionregistration.k8s.io/v1beta1","version":"v1beta1"}],` +
+					`"preferredVersion":{"groupVersion":"admissionregistration.k8s.io/v1","version":"v1"}}]}`))
+			case "/apis/admissionregistration.k8s.io/v1":
+				_, _ = w.Write([]byte(`{"kind":"APIResourceList","apiVersion":"v1",` +
+					`"groupVersion":"admissionregistration.k8s.io/v1","resources":[` +
+					`{"name":"validatingwebhookconfigurations","namespaced":false,` +
+					`"kind":"ValidatingWebhookConfiguration","verbs":["list"]}]}`))
+			case "/apis/admissionregistration.k8s.io/v1beta1":
+				_, _ = w.Write([]byte(`{"kind":"APIResourceList","apiVersion":"v1",` +
+					`"groupVersion":"admissionregistration.k8s.io/v1beta1","resources":[` +
+					`{"name":"validatingadmissionpolicies","namespaced":false,` +
+					`"kind":"ValidatingAdmissionPolicy","verbs":["list"]}]}`))
+			case "/openapi/v3":
+				if !serveOpenAPIV3 {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				_, _ = w.Write([]byte(`{"paths":{}}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	getClassifier := func(features string) *libsveltosv1alpha1.Classifier {
+		classifier := getClassifierWithKubernetesConstraints(version25, libsveltosv1alpha1.ComparisonEqual)
+		classifier.Annotations = map[string]string{
+			classification.ClassifierExtensionAnnotation: "apiServerFeatures: [" + features + "]",
+		}
+		return classifier
+	}
+
+	initialize := func(managedFields []metav1.ManagedFieldsEntry) {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}}
+		c := &managedFieldsClient{Client: fake.NewClientBuilder().WithObjects(ns).Build(), managedFields: managedFields}
+		classification.InitializeManagerWithSkip(context.TODO(), klogr.New(), &rest.Config{Host: server.URL},
+			c, nil, 10)
+	}
+
+	It("areAPIServerFeaturesAMatch probes ValidatingAdmissionPolicy and OpenAPI v3", func() {
+		initialize(nil)
+		manager := classification.GetManager()
+
+		isMatch, _, err := classification.AreAPIServerFeaturesAMatch(manager,
+			getClassifier("ValidatingAdmissionPolicy"))
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+
+		isMatch, notes, err := classification.AreAPIServerFeaturesAMatch(manager,
+			getClassifier("ValidatingAdmissionPolicy, OpenAPIV3"))
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeFalse())
+		Expect(notes).To(ContainElement(ContainSubstring("OpenAPIV3")))
+
+		serveOpenAPIV3 = true
+		isMatch, _, err = classification.AreAPIServerFeaturesAMatch(manager,
+			getClassifier("ValidatingAdmissionPolicy, OpenAPIV3"))
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+	})
+
+	It("areAPIServerFeaturesAMatch detects server-side apply from managedFields", func() {
+		initialize(nil)
+		isMatch, _, err := classification.AreAPIServerFeaturesAMatch(classification.GetManager(),
+			getClassifier("ServerSideApply"))
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeFalse())
+
+		classification.Reset()
+		initialize([]metav1.ManagedFieldsEntry{
+			{Manager: "kube-apiserver", Operation: metav1.ManagedFieldsOperationUpdate, APIVersion: "v1"},
+		})
+		isMatch, _, err = classification.AreAPIServerFeaturesAMatch(classification.GetManager(),
+			getClassifier("ServerSideApply"))
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+	})
+
+	It("areAPIServerFeaturesAMatch rejects unknown features", func() {
+		initialize(nil)
+		_, _, err := classification.AreAPIServerFeaturesAMatch(classification.GetManager(),
+			getClassifier("TimeTravel"))
+		Expect(classification.IsInvalidClassifierError(err)).To(BeTrue())
+	})
+})
+
+// managedFieldsClient sets managedFields on all objects it gets, as an API server
+// with server-side apply would
+type managedFieldsClient struct {
+	client.Client
+	managedFields []metav1.ManagedFieldsEntry
+}
+
+func (c *managedFieldsClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object,
+	opts ...client.GetOption) error {
+
+	if err := c.Client.Get(ctx, key, obj, opts...); err != nil {
+		return err
+	}
+	obj.SetManagedFields(c.managedFields)
+	return nil
+}
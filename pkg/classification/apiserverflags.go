@@ -0,0 +1,269 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+)
+
+const (
+	// apiServerComponentLabel selects, in kube-system, the kube-apiserver static Pods
+	// created by kubeadm and most distributions
+	apiServerComponentLabel = "component"
+	apiServerComponent      = "kube-apiserver"
+
+	// kubeadmConfigMap, in kube-system, contains the kubeadm ClusterConfiguration
+	kubeadmConfigMap        = "kubeadm-config"
+	kubeadmClusterConfigKey = "ClusterConfiguration"
+
+	featureGatesFlag            = "feature-gates"
+	enableAdmissionPluginsFlag  = "enable-admission-plugins"
+	disableAdmissionPluginsFlag = "disable-admission-plugins"
+)
+
+// APIServerFlagsConstraint requires feature gates and admission plugins to be
+// explicitly enabled or disabled on the API server. Flags are read from the
+// kube-apiserver static Pod in kube-system or, when not found, from the kubeadm
+// ClusterConfiguration. Only flags explicitly set are known: a feature gate or an
+// admission plugin enabled by default is not reported as enabled. Constraint is not
+// satisfied when flags cannot be read, for instance on managed control planes.
+type APIServerFlagsConstraint struct {
+	// EnabledFeatureGates must be set to true in --feature-gates
+	// +optional
+	EnabledFeatureGates []string `json:"enabledFeatureGates,omitempty"`
+
+	// DisabledFeatureGates must be set to false in --feature-gates
+	// +optional
+	DisabledFeatureGates []string `json:"disabledFeatureGates,omitempty"`
+
+	// EnabledAdmissionPlugins must be listed in --enable-admission-plugins
+	// +optional
+	EnabledAdmissionPlugins []string `json:"enabledAdmissionPlugins,omitempty"`
+
+	// DisabledAdmissionPlugins must be listed in --disable-admission-plugins
+	// +optional
+	DisabledAdmissionPlugins []string `json:"disabledAdmissionPlugins,omitempty"`
+}
+
+// apiServerFlags contains the feature gates and admission plugins explicitly set
+// on the API server
+type apiServerFlags struct {
+	// source describes where flags were read
+	source          string
+	featureGates    map[string]bool
+	enabledPlugins  map[string]bool
+	disabledPlugins map[string]bool
+}
+
+// areAPIServerFlagsAMatch returns true if APIServerFlags constraint is satisfied
+func (m *manager) areAPIServerFlagsAMatch(ctx context.Context,
+	classifier *libsveltosv1alpha1.Classifier) (bool, error) {
+
+	extension, err := getClassifierExtension(classifier)
+	if err != nil {
+		return false, err
+	}
+
+	c := extension.APIServerFlags
+	if c == nil {
+		return true, nil
+	}
+
+	flags, err := m.getAPIServerFlags(ctx)
+	if err != nil {
+		return false, err
+	}
+	if flags == nil {
+		addEvaluationNote(ctx, "api server flags: neither kube-apiserver static pod nor kubeadm configuration found")
+		return false, nil
+	}
+
+	check := func(names []string, description string, isSet func(name string) bool) bool {
+		for _, name := range names {
+			if !isSet(name) {
+				addEvaluationNote(ctx, fmt.Sprintf("api server flags: %s %s (read from %s)",
+					name, description, flags.source))
+				return false
+			}
+		}
+		return true
+	}
+
+	return check(c.EnabledFeatureGates, "feature gate is not enabled", func(name string) bool {
+		enabled, ok := flags.featureGates[name]
+		return ok && enabled
+	}) && check(c.DisabledFeatureGates, "feature gate is not disabled", func(name string) bool {
+		enabled, ok := flags.featureGates[name]
+		return ok && !enabled
+	}) && check(c.EnabledAdmissionPlugins, "admission plugin is not enabled", func(name string) bool {
+		return flags.enabledPlugins[name]
+	}) && check(c.DisabledAdmissionPlugins, "admission plugin is not disabled", func(name string) bool {
+		return flags.disabledPlugins[name]
+	}), nil
+}
+
+// getAPIServerFlags returns the flags of the kube-apiserver static Pod or, when there
+// is none, of the kubeadm ClusterConfiguration. Returns nil when neither can be read.
+func (m *manager) getAPIServerFlags(ctx context.Context) (*apiServerFlags, error) {
+	pods := &corev1.PodList{}
+	err := m.List(ctx, pods, client.InNamespace(kubeSystemNamespace),
+		client.MatchingLabels{apiServerComponentLabel: apiServerComponent})
+	if err != nil && !apierrors.IsForbidden(err) {
+		return nil, err
+	}
+	if len(pods.Items) != 0 {
+		// All replicas usually share flags. Sorting makes the outcome stable while
+		// a rolling update of the control plane changes them.
+		sort.Slice(pods.Items, func(i, j int) bool { return pods.Items[i].Name < pods.Items[j].Name })
+		pod := &pods.Items[0]
+		for i := range pod.Spec.Containers {
+			if pod.Spec.Containers[i].Name != apiServerComponent && len(pod.Spec.Containers) > 1 {
+				continue
+			}
+			args := append(append([]string{}, pod.Spec.Containers[i].Command...), pod.Spec.Containers[i].Args...)
+			return parseAPIServerArgs(args, fmt.Sprintf("pod %s/%s", pod.Namespace, pod.Name)), nil
+		}
+	}
+
+	configMap := &corev1.ConfigMap{}
+	err = m.Get(ctx, client.ObjectKey{Namespace: kubeSystemNamespace, Name: kubeadmConfigMap}, configMap)
+	if err != nil {
+		if apierrors.IsNotFound(err) || apierrors.IsForbidden(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	extraArgs, err := getKubeadmAPIServerExtraArgs(configMap.Data[kubeadmClusterConfigKey])
+	if err != nil {
+		m.log.V(logs.LogDebug).Info(fmt.Sprintf("failed to parse kubeadm ClusterConfiguration: %v", err))
+		return nil, nil
+	}
+	flags := newAPIServerFlags(fmt.Sprintf("configMap %s/%s", kubeSystemNamespace, kubeadmConfigMap))
+	for name, value := range extraArgs {
+		flags.set(name, value)
+	}
+	return flags, nil
+}
+
+func newAPIServerFlags(source string) *apiServerFlags {
+	return &apiServerFlags{
+		source:          source,
+		featureGates:    make(map[string]bool),
+		enabledPlugins:  make(map[string]bool),
+		disabledPlugins: make(map[string]bool),
+	}
+}
+
+// set records flag name with value, if relevant. Later values override earlier ones.
+func (f *apiServerFlags) set(name, value string) {
+	switch name {
+	case featureGatesFlag:
+		for _, gate := range splitFlagList(value) {
+			key, enabled, found := strings.Cut(gate, "=")
+			if !found {
+				continue
+			}
+			if b, err := strconv.ParseBool(strings.TrimSpace(enabled)); err == nil {
+				f.featureGates[strings.TrimSpace(key)] = b
+			}
+		}
+	case enableAdmissionPluginsFlag:
+		for _, plugin := range splitFlagList(value) {
+			f.enabledPlugins[plugin] = true
+		}
+	case disableAdmissionPluginsFlag:
+		for _, plugin := range splitFlagList(value) {
+			f.disabledPlugins[plugin] = true
+		}
+	}
+}
+
+// parseAPIServerArgs parses kube-apiserver command line. Both --flag=value and
+// --flag value forms are supported.
+func parseAPIServerArgs(args []string, source string) *apiServerFlags {
+	flags := newAPIServerFlags(source)
+	for i := 0; i < len(args); i++ {
+		if !strings.HasPrefix(args[i], "--") {
+			continue
+		}
+		name, value, found := strings.Cut(strings.TrimPrefix(args[i], "--"), "=")
+		if !found {
+			if i+1 >= len(args) || strings.HasPrefix(args[i+1], "--") {
+				continue
+			}
+			i++
+			value = args[i]
+		}
+		flags.set(name, value)
+	}
+	return flags
+}
+
+// getKubeadmAPIServerExtraArgs returns apiServer.extraArgs of a kubeadm ClusterConfiguration.
+// extraArgs is a map up to v1beta3 and a list of name/value pairs from v1beta4.
+func getKubeadmAPIServerExtraArgs(clusterConfiguration string) (map[string]string, error) {
+	config := struct {
+		APIServer struct {
+			ExtraArgs json.RawMessage `json:"extraArgs"`
+		} `json:"apiServer"`
+	}{}
+	if err := yaml.Unmarshal([]byte(clusterConfiguration), &config); err != nil {
+		return nil, err
+	}
+	if len(config.APIServer.ExtraArgs) == 0 {
+		return map[string]string{}, nil
+	}
+
+	extraArgs := make(map[string]string)
+	if err := json.Unmarshal(config.APIServer.ExtraArgs, &extraArgs); err == nil {
+		return extraArgs, nil
+	}
+	args := []struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}{}
+	if err := json.Unmarshal(config.APIServer.ExtraArgs, &args); err != nil {
+		return nil, err
+	}
+	for i := range args {
+		extraArgs[args[i].Name] = args[i].Value
+	}
+	return extraArgs, nil
+}
+
+func splitFlagList(value string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,42 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+)
+
+var _ = Describe("Manager: api server flags", func() {
+	It("getKubeadmAPIServerExtraArgs supports map and list extraArgs", func() {
+		args, err := classification.GetKubeadmAPIServerExtraArgs(`apiServer:
+  extraArgs:
+    feature-gates: A=true`)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(args).To(HaveKeyWithValue("feature-gates", "A=true"))
+
+		args, err = classification.GetKubeadmAPIServerExtraArgs(`apiVersion: kubeadm.k8s.io/v1beta4
+apiServer:
+  extraArgs:
+  - name: feature-gates
+    value: A=false`)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(args).To(HaveKeyWithValue("feature-gates", "A=false"))
+	})
+})
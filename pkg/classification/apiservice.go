@@ -0,0 +1,90 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+
+	"emperror.dev/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+const (
+	// apiServiceAvailable is the APIService condition reporting whether
+	// the API is reachable and healthy
+	apiServiceAvailable = "Available"
+)
+
+// areAPIServicesAMatch returns true if all APIServiceConstraints are satisfied
+func (m *manager) areAPIServicesAMatch(ctx context.Context,
+	classifier *libsveltosv1alpha1.Classifier) (bool, error) {
+
+	extension, err := getClassifierExtension(classifier)
+	if err != nil {
+		return false, err
+	}
+
+	for i := range extension.APIServiceConstraints {
+		name := extension.APIServiceConstraints[i].Name
+		if name == "" {
+			return false, newInvalidClassifierError(errors.New("apiservice constraint name is required"))
+		}
+
+		apiService := &unstructured.Unstructured{}
+		apiService.SetGroupVersionKind(apiServiceGVK)
+		err := m.Client.Get(ctx, types.NamespacedName{Name: name}, apiService)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				return false, nil
+			}
+			return false, err
+		}
+
+		if !isAPIServiceAvailable(apiService) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// isAPIServiceAvailable returns true if APIService Available condition is True.
+// Local APIServices, served by the API server itself, are always Available.
+func isAPIServiceAvailable(apiService *unstructured.Unstructured) bool {
+	if !apiService.GetDeletionTimestamp().IsZero() {
+		return false
+	}
+
+	conditions, found, err := unstructured.NestedSlice(apiService.Object, "status", "conditions")
+	if err != nil || !found {
+		return false
+	}
+
+	for i := range conditions {
+		condition, ok := conditions[i].(map[string]interface{})
+		if !ok || condition["type"] != apiServiceAvailable {
+			continue
+		}
+		return condition["status"] == "True"
+	}
+
+	return false
+}
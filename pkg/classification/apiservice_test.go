@@ -0,0 +1,61 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosutils "github.com/projectsveltos/libsveltos/lib/utils"
+)
+
+const apiServiceTemplate = `apiVersion: apiregistration.k8s.io/v1
+kind: APIService
+metadata:
+  name: %s
+spec:
+  group: metrics.k8s.io
+  version: v1beta1
+status:
+  conditions:
+  - type: Available
+    status: "%s"
+    reason: %s`
+
+func getAPIService(name, status, reason string) *unstructured.Unstructured {
+	u, err := libsveltosutils.GetUnstructured([]byte(fmt.Sprintf(apiServiceTemplate, name, status, reason)))
+	Expect(err).To(BeNil())
+	return u
+}
+
+var _ = Describe("Manager: api services", func() {
+	It("isAPIServiceAvailable returns true only when Available condition is True", func() {
+		Expect(classification.IsAPIServiceAvailable(getAPIService("v1beta1.metrics.k8s.io", "True", "Passed"))).
+			To(BeTrue())
+		Expect(classification.IsAPIServiceAvailable(getAPIService("v1beta1.metrics.k8s.io", "False",
+			"FailedDiscoveryCheck"))).To(BeFalse())
+
+		apiService := getAPIService("v1beta1.metrics.k8s.io", "True", "Passed")
+		unstructured.RemoveNestedField(apiService.Object, "status")
+		Expect(classification.IsAPIServiceAvailable(apiService)).To(BeFalse())
+	})
+})
@@ -0,0 +1,176 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+)
+
+// BroadConstraintPolicy defines how classifier-agent handles resource constraints
+// listing a large number of resources cluster-wide, with no namespace nor label filter
+type BroadConstraintPolicy string
+
+const (
+	// BroadConstraintAllow evaluates broad constraints like any other constraint
+	BroadConstraintAllow = BroadConstraintPolicy("allow")
+
+	// BroadConstraintSample evaluates broad constraints against the first
+	// threshold resources only. Counts are therefore approximated.
+	BroadConstraintSample = BroadConstraintPolicy("sample")
+
+	// BroadConstraintDefer evaluates Classifiers with broad constraints in a
+	// slow lane: at most once every slowLaneInterval
+	BroadConstraintDefer = BroadConstraintPolicy("defer")
+
+	// BroadConstraintRefuse does not evaluate Classifiers with broad constraints.
+	// Classifier is reported as invalid.
+	BroadConstraintRefuse = BroadConstraintPolicy("refuse")
+)
+
+const (
+	// DefaultBroadConstraintThreshold is the default number of resources above
+	// which an unfiltered constraint is considered broad
+	DefaultBroadConstraintThreshold = 10000
+
+	// slowLaneInterval is the minimum interval between two evaluations of a
+	// Classifier deferred because of broad constraints
+	slowLaneInterval = 10 * time.Minute
+)
+
+// IsValidBroadConstraintPolicy returns true if policy is a known BroadConstraintPolicy
+func IsValidBroadConstraintPolicy(policy BroadConstraintPolicy) bool {
+	switch policy {
+	case BroadConstraintAllow, BroadConstraintSample, BroadConstraintDefer, BroadConstraintRefuse:
+		return true
+	default:
+		return false
+	}
+}
+
+// applyBroadConstraintPolicy verifies whether query lists, cluster-wide and with no
+// label selector, more than broadConstraintThreshold resources. If so the
+// broadConstraintPolicy is applied. Returns true if only a sample of resources,
+// the first broadConstraintThreshold ones, must be evaluated.
+// classifier is nil when constraint is not part of a Classifier.
+func (m *manager) applyBroadConstraintPolicy(ctx context.Context, classifier *libsveltosv1alpha1.Classifier,
+	query *resourceQuery) (bool, error) {
+
+	if m.broadConstraintPolicy == "" || m.broadConstraintPolicy == BroadConstraintAllow {
+		return false, nil
+	}
+
+	if query.options.LabelSelector != "" || query.options.FieldSelector != "" || len(query.names) != 0 {
+		return false, nil
+	}
+
+	estimate, err := m.estimateResourceCount(ctx, query)
+	if err != nil {
+		return false, err
+	}
+
+	threshold := m.getBroadConstraintThreshold()
+	if estimate <= int64(threshold) {
+		return false, nil
+	}
+
+	classifierName := ""
+	if classifier != nil {
+		classifierName = classifier.Name
+	}
+
+	m.log.V(logs.LogInfo).Info(fmt.Sprintf(
+		"classifier %s: constraint on %s lists about %d resources with no namespace nor label filter (policy %s)",
+		classifierName, query.gvk.String(), estimate, m.broadConstraintPolicy))
+
+	switch m.broadConstraintPolicy {
+	case BroadConstraintSample:
+		return true, nil
+	case BroadConstraintDefer:
+		if classifier != nil {
+			m.deferClassifier(classifier.Name, time.Now())
+		}
+		return false, nil
+	case BroadConstraintRefuse:
+		return false, newInvalidClassifierError(fmt.Errorf(
+			"constraint on %s lists about %d resources (threshold %d). Set a namespace or label filters",
+			query.gvk.String(), estimate, threshold))
+	}
+
+	return false, nil
+}
+
+// estimateResourceCount returns the number of resources query would list.
+// A single resource is requested: the API server reports how many remain.
+// Returns 0 if the API server does not report it.
+func (m *manager) estimateResourceCount(ctx context.Context, query *resourceQuery) (int64, error) {
+	options := metav1.ListOptions{Limit: 1}
+	list, err := m.listResources(ctx, &query.gvk, query.resource, &options)
+	if err != nil {
+		return 0, err
+	}
+
+	estimate := int64(len(list.Items))
+	if remaining := list.GetRemainingItemCount(); remaining != nil {
+		estimate += *remaining
+	}
+
+	return estimate, nil
+}
+
+// deferClassifier moves a Classifier to the slow lane. Classifier won't
+// be evaluated again before slowLaneInterval has elapsed from now.
+func (m *manager) deferClassifier(classifierName string, now time.Time) {
+	m.deferredMu.Lock()
+	defer m.deferredMu.Unlock()
+
+	m.deferred[classifierName] = now.Add(slowLaneInterval)
+}
+
+// isDeferred returns true if Classifier is in the slow lane and
+// its next evaluation is not due yet
+func (m *manager) isDeferred(classifierName string, now time.Time) bool {
+	m.deferredMu.Lock()
+	defer m.deferredMu.Unlock()
+
+	next, ok := m.deferred[classifierName]
+	if !ok {
+		return false
+	}
+
+	if now.Before(next) {
+		return true
+	}
+
+	// Classifier is moved back to the slow lane if its constraints are still broad
+	delete(m.deferred, classifierName)
+	return false
+}
+
+// removeDeferred removes a Classifier from the slow lane
+func (m *manager) removeDeferred(classifierName string) {
+	m.deferredMu.Lock()
+	defer m.deferredMu.Unlock()
+
+	delete(m.deferred, classifierName)
+}
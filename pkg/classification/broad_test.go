@@ -0,0 +1,61 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+)
+
+var _ = Describe("Manager: broad constraints", func() {
+	BeforeEach(func() {
+		var err error
+		scheme, err = setupScheme()
+		Expect(err).ToNot(HaveOccurred())
+		classification.Reset()
+	})
+
+	It("IsValidBroadConstraintPolicy accepts only known policies", func() {
+		for _, policy := range []classification.BroadConstraintPolicy{classification.BroadConstraintAllow,
+			classification.BroadConstraintSample, classification.BroadConstraintDefer,
+			classification.BroadConstraintRefuse} {
+
+			Expect(classification.IsValidBroadConstraintPolicy(policy)).To(BeTrue())
+		}
+		Expect(classification.IsValidBroadConstraintPolicy("ignore")).To(BeFalse())
+	})
+
+	It("deferClassifier keeps classifier in the slow lane till its evaluation is due", func() {
+		classification.InitializeManagerWithObjects(nil)
+		manager := classification.GetManager()
+
+		classifierName := randomString()
+		now := time.Now()
+		Expect(classification.IsDeferred(manager, classifierName, now)).To(BeFalse())
+
+		classification.DeferClassifier(manager, classifierName, now)
+		Expect(classification.IsDeferred(manager, classifierName, now.Add(time.Minute))).To(BeTrue())
+
+		// Once due, classifier leaves the slow lane
+		Expect(classification.IsDeferred(manager, classifierName, now.Add(time.Hour))).To(BeFalse())
+		Expect(classification.IsDeferred(manager, classifierName, now.Add(time.Minute))).To(BeFalse())
+	})
+})
@@ -0,0 +1,142 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"emperror.dev/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/projectsveltos/classifier-agent/internal/utils"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+)
+
+const (
+	// ClassifierReportDegradedAnnotation is set on a ClassifierReport when the last
+	// Classifier evaluation exceeded its EvaluationBudget. Match is then the one of
+	// the last complete evaluation. Value is the exceeded budget.
+	ClassifierReportDegradedAnnotation = "classifier.projectsveltos.io/degraded"
+)
+
+// errRequestBudgetExceeded is returned for API server requests sent after
+// an evaluation has used all of its EvaluationBudget MaxRequests
+var errRequestBudgetExceeded = errors.New("evaluation request budget exceeded")
+
+// budgetExceededError indicates a Classifier evaluation was aborted because it
+// exceeded its EvaluationBudget
+type budgetExceededError struct {
+	reason string
+}
+
+func (e *budgetExceededError) Error() string {
+	return e.reason
+}
+
+// isBudgetExceededError returns true if err, or any error it wraps,
+// is a budgetExceededError
+func isBudgetExceededError(err error) bool {
+	var budgetErr *budgetExceededError
+	return errors.As(err, &budgetErr)
+}
+
+// withEvaluationBudget applies the Classifier EvaluationBudget, if any, to
+// the evaluation context and cost tracker.
+// Returned cancel function must always be called.
+func withEvaluationBudget(ctx context.Context, classifier *libsveltosv1alpha1.Classifier,
+	tracker *costTracker) (context.Context, context.CancelFunc, *EvaluationBudget) {
+
+	extension, err := getClassifierExtension(classifier)
+	if err != nil || extension.Budget == nil {
+		// An invalid extension is reported by the evaluation itself
+		return ctx, func() {}, nil
+	}
+
+	budget := extension.Budget
+	tracker.maxRequests = budget.MaxRequests
+	if budget.MaxDuration == nil || budget.MaxDuration.Duration <= 0 {
+		return ctx, func() {}, budget
+	}
+
+	budgetCtx, cancel := context.WithTimeout(ctx, budget.MaxDuration.Duration)
+	return budgetCtx, cancel, budget
+}
+
+// checkEvaluationBudget returns a budgetExceededError if the evaluation run
+// with ctx and tracker went over budget
+func checkEvaluationBudget(ctx context.Context, tracker *costTracker, budget *EvaluationBudget) error {
+	if budget == nil {
+		return nil
+	}
+
+	if atomic.LoadInt32(&tracker.budgetExceeded) != 0 {
+		return &budgetExceededError{
+			reason: fmt.Sprintf("evaluation exceeded maxRequests (%d)", budget.MaxRequests),
+		}
+	}
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return &budgetExceededError{
+			reason: fmt.Sprintf("evaluation exceeded maxDuration (%s)", budget.MaxDuration.Duration),
+		}
+	}
+
+	return nil
+}
+
+// markClassifierReportDegraded sets ClassifierReportDegradedAnnotation on the
+// ClassifierReport, leaving its match untouched. ClassifierReport is created,
+// as not a match, if it does not exist yet.
+func (m *manager) markClassifierReportDegraded(ctx context.Context, classifier *libsveltosv1alpha1.Classifier,
+	budgetErr error) error {
+
+	logger := m.log.WithValues("classifier", classifier.Name)
+	logger.V(logs.LogInfo).Info(fmt.Sprintf("classifier degraded: %v", budgetErr))
+
+	current := &libsveltosv1alpha1.ClassifierReport{}
+	err := m.Get(ctx,
+		types.NamespacedName{Namespace: utils.ReportNamespace, Name: classifier.Name}, current)
+	if err != nil {
+		if isReportCRDMissing(err) {
+			logger.V(logs.LogInfo).Info("ClassifierReport CRD is not installed. Cannot mark it as degraded")
+			return nil
+		}
+		if !apierrors.IsNotFound(err) {
+			logger.Error(err, "failed to get ClassifierReport")
+			return err
+		}
+	}
+
+	// All fields classifier-agent owns are applied again, as any field not applied
+	// would be removed
+	classifierReport := m.getClassifierReport(classifier.Name, current.Spec.Match)
+	copyReportAnnotations(classifierReport, current)
+	setReportAnnotation(classifierReport, ClassifierReportDegradedAnnotation, budgetErr.Error())
+	m.setReportAgentDegraded(classifierReport)
+	m.setReportClusterUnhealthy(classifierReport)
+	err = applyClassifierReport(ctx, m.Client, classifierReport)
+	if err != nil {
+		logger.Error(err, "failed to mark ClassifierReport as degraded")
+		return err
+	}
+
+	return m.updateClassifierReportStatus(ctx, classifier)
+}
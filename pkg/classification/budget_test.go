@@ -0,0 +1,141 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+
+	"github.com/projectsveltos/classifier-agent/internal/utils"
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: evaluation budget", func() {
+	var classifier *libsveltosv1alpha1.Classifier
+
+	BeforeEach(func() {
+		classification.Reset()
+
+		classifier = getClassifierWithKubernetesConstraints(version25, libsveltosv1alpha1.ComparisonEqual)
+	})
+
+	It("withEvaluationBudget fails requests above maxRequests", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`{}`))
+		}))
+		defer server.Close()
+
+		classifier.Annotations = map[string]string{
+			classification.ClassifierExtensionAnnotation: `budget: {maxRequests: 1}`,
+		}
+
+		tracker := &classification.CostTracker{}
+		ctx, cancel, budget := classification.WithEvaluationBudget(context.TODO(), classifier, tracker)
+		defer cancel()
+		Expect(budget).ToNot(BeNil())
+
+		httpClient, err := rest.HTTPClientFor(classification.WithCostTracking(&rest.Config{Host: server.URL}))
+		Expect(err).To(BeNil())
+
+		doRequest := func() error {
+			req, err := http.NewRequestWithContext(classification.WithCostTracker(ctx, tracker),
+				http.MethodGet, server.URL, http.NoBody)
+			Expect(err).To(BeNil())
+			resp, err := httpClient.Do(req)
+			if err != nil {
+				return err
+			}
+			return resp.Body.Close()
+		}
+
+		Expect(doRequest()).To(Succeed())
+		Expect(classification.CheckEvaluationBudget(ctx, tracker, budget)).To(Succeed())
+
+		Expect(doRequest()).ToNot(Succeed())
+		err = classification.CheckEvaluationBudget(ctx, tracker, budget)
+		Expect(err).ToNot(BeNil())
+		Expect(classification.IsBudgetExceededError(err)).To(BeTrue())
+	})
+
+	It("withEvaluationBudget bounds evaluation duration", func() {
+		classifier.Annotations = map[string]string{
+			classification.ClassifierExtensionAnnotation: `budget: {maxDuration: 10ms}`,
+		}
+
+		tracker := &classification.CostTracker{}
+		ctx, cancel, budget := classification.WithEvaluationBudget(context.TODO(), classifier, tracker)
+		defer cancel()
+		Expect(classification.CheckEvaluationBudget(ctx, tracker, budget)).To(Succeed())
+
+		<-ctx.Done()
+		err := classification.CheckEvaluationBudget(ctx, tracker, budget)
+		Expect(classification.IsBudgetExceededError(err)).To(BeTrue())
+	})
+
+	It("withEvaluationBudget does nothing when Classifier has no budget", func() {
+		tracker := &classification.CostTracker{}
+		ctx, cancel, budget := classification.WithEvaluationBudget(context.TODO(), classifier, tracker)
+		defer cancel()
+		Expect(budget).To(BeNil())
+		_, ok := ctx.Deadline()
+		Expect(ok).To(BeFalse())
+		Expect(classification.CheckEvaluationBudget(ctx, tracker, budget)).To(Succeed())
+	})
+
+	It("markClassifierReportDegraded keeps match and is cleared by next complete evaluation", func() {
+		c := classification.InitializeManagerWithObjects(nil, classifier)
+		manager := classification.GetManager()
+
+		Expect(classification.CreateClassifierReport(manager, context.TODO(), classifier, true, nil)).
+			To(Succeed())
+
+		tracker := &classification.CostTracker{}
+		classifier.Annotations = map[string]string{
+			classification.ClassifierExtensionAnnotation: `budget: {maxDuration: 1ms}`,
+		}
+		ctx, cancel, budget := classification.WithEvaluationBudget(context.TODO(), classifier, tracker)
+		defer cancel()
+		time.Sleep(5 * time.Millisecond)
+		budgetErr := classification.CheckEvaluationBudget(ctx, tracker, budget)
+		Expect(budgetErr).ToNot(BeNil())
+
+		Expect(classification.MarkClassifierReportDegraded(manager, context.TODO(), classifier, budgetErr)).
+			To(Succeed())
+
+		classifierReport := &libsveltosv1alpha1.ClassifierReport{}
+		key := types.NamespacedName{Namespace: utils.ReportNamespace, Name: classifier.Name}
+		Expect(c.Get(context.TODO(), key, classifierReport)).To(Succeed())
+		Expect(classifierReport.Spec.Match).To(BeTrue())
+		Expect(classifierReport.Annotations).To(HaveKeyWithValue(
+			classification.ClassifierReportDegradedAnnotation, budgetErr.Error()))
+
+		Expect(classification.CreateClassifierReport(manager, context.TODO(), classifier, false, nil)).
+			To(Succeed())
+		Expect(c.Get(context.TODO(), key, classifierReport)).To(Succeed())
+		Expect(classifierReport.Spec.Match).To(BeFalse())
+		Expect(classifierReport.Annotations).ToNot(HaveKey(classification.ClassifierReportDegradedAnnotation))
+	})
+})
@@ -0,0 +1,112 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"fmt"
+
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+)
+
+// EvaluationProgress reports the evaluation of one Classifier during EvaluateAll
+type EvaluationProgress struct {
+	// Classifier is the name of the Classifier just evaluated
+	Classifier string
+
+	// Evaluated is the number of Classifiers evaluated so far, this one included
+	Evaluated int
+
+	// Total is the number of Classifiers EvaluateAll evaluates
+	Total int
+
+	// Result is the evaluation outcome. Not set if Classifier was deleted
+	// or evaluation failed before cluster state was evaluated.
+	Result *EvaluationResult
+
+	// Err is set if evaluation failed
+	Err error
+}
+
+// EvaluationProgressFunc is invoked by EvaluateAll after each Classifier evaluation
+type EvaluationProgressFunc func(progress EvaluationProgress)
+
+// EvaluateAll synchronously evaluates every Classifier, invoking progressFn, if
+// not nil, after each evaluation.
+// Evaluations are serialized with the ones of the background evaluation loop,
+// but run with ctx so they can be bound or cancelled by caller.
+// Classifiers whose evaluation fails are queued for background evaluation.
+// Error is returned only if Classifiers cannot be listed or ctx is done.
+func (m *manager) EvaluateAll(ctx context.Context, progressFn EvaluationProgressFunc) error {
+	classifiers, err := m.ListClassifiers(ctx)
+	if err != nil {
+		return err
+	}
+
+	total := len(classifiers.Items)
+	evaluationCtx := m.withMatchCache(ctx)
+	for i := range classifiers.Items {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		classifierName := classifiers.Items[i].Name
+		evaluationErr := m.evaluateClassifierInstanceSerialized(evaluationCtx, classifierName)
+		if evaluationErr != nil {
+			m.log.V(logs.LogInfo).Info(fmt.Sprintf("failed to evaluate classifier %s: %v",
+				classifierName, evaluationErr))
+			m.EvaluateClassifier(classifierName)
+		}
+
+		if progressFn != nil {
+			progressFn(EvaluationProgress{
+				Classifier: classifierName,
+				Evaluated:  i + 1,
+				Total:      total,
+				Result:     m.getLatestEvaluation(classifierName),
+				Err:        evaluationErr,
+			})
+		}
+	}
+
+	return nil
+}
+
+// evaluateClassifierInstanceSerialized evaluates a Classifier making sure no
+// other evaluation is in progress. Returns errHandshakeNotHeld if another
+// classifier-agent instance holds the handshake Lease.
+func (m *manager) evaluateClassifierInstanceSerialized(ctx context.Context, classifierName string) error {
+	m.evaluationMu.Lock()
+	defer m.evaluationMu.Unlock()
+
+	if !m.holdsHandshake() {
+		return errHandshakeNotHeld
+	}
+	return m.evaluateClassifierInstance(ctx, classifierName)
+}
+
+// getLatestEvaluation returns the most recent evaluation result for a Classifier, if any
+func (m *manager) getLatestEvaluation(classifierName string) *EvaluationResult {
+	m.historyMu.RLock()
+	defer m.historyMu.RUnlock()
+
+	result, ok := m.latest[classifierName]
+	if !ok {
+		return nil
+	}
+	return &result
+}
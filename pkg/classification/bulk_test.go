@@ -0,0 +1,85 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/client-go/rest"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: bulk evaluation", func() {
+	var server *httptest.Server
+
+	BeforeEach(func() {
+		classification.Reset()
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"major": "1", "minor": "25", "gitVersion": "` + version25 + `"}`))
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("EvaluateAll evaluates all classifiers and reports progress", func() {
+		matching := getClassifierWithKubernetesConstraints(version25, libsveltosv1alpha1.ComparisonEqual)
+		notMatching := getClassifierWithKubernetesConstraints(version24, libsveltosv1alpha1.ComparisonEqual)
+
+		classification.InitializeManagerWithObjects(&rest.Config{Host: server.URL}, matching, notMatching)
+		manager := classification.GetManager()
+
+		progress := make([]classification.EvaluationProgress, 0)
+		Expect(manager.EvaluateAll(context.TODO(), func(p classification.EvaluationProgress) {
+			progress = append(progress, p)
+		})).To(Succeed())
+
+		Expect(len(progress)).To(Equal(2))
+		for i := range progress {
+			Expect(progress[i].Evaluated).To(Equal(i + 1))
+			Expect(progress[i].Total).To(Equal(2))
+			Expect(progress[i].Err).To(BeNil())
+			Expect(progress[i].Result).ToNot(BeNil())
+			Expect(progress[i].Result.Match).To(Equal(progress[i].Classifier == matching.Name))
+		}
+	})
+
+	It("EvaluateAll stops when context is cancelled", func() {
+		classifier := getClassifierWithKubernetesConstraints(version25, libsveltosv1alpha1.ComparisonEqual)
+
+		classification.InitializeManagerWithObjects(&rest.Config{Host: server.URL}, classifier)
+		manager := classification.GetManager()
+
+		ctx, cancel := context.WithCancel(context.TODO())
+		cancel()
+
+		evaluated := 0
+		err := manager.EvaluateAll(ctx, func(p classification.EvaluationProgress) { evaluated++ })
+		Expect(err).To(MatchError(context.Canceled))
+		Expect(evaluated).To(BeZero())
+	})
+})
@@ -0,0 +1,260 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var podGVK = schema.GroupVersionKind{Version: "v1", Kind: "Pod"}
+
+// CapacityConstraint bounds the cluster capacity for a resource, such as cpu or memory:
+// the sum of the quantity allocatable on all Nodes and the sum of the quantity requested
+// by all Pods. It can be used to classify large clusters, for instance at least 64
+// allocatable cpu, or over-committed ones, for instance requests above 90% of allocatable.
+type CapacityConstraint struct {
+	// Resource is the name of the resource, for instance cpu or memory
+	Resource corev1.ResourceName `json:"resource"`
+
+	// MinAllocatable is the minimum sum of the quantity allocatable on Nodes
+	// +optional
+	MinAllocatable *resource.Quantity `json:"minAllocatable,omitempty"`
+
+	// MaxAllocatable is the maximum sum of the quantity allocatable on Nodes
+	// +optional
+	MaxAllocatable *resource.Quantity `json:"maxAllocatable,omitempty"`
+
+	// MinRequested is the minimum sum of the quantity requested by Pods which
+	// are not terminated
+	// +optional
+	MinRequested *resource.Quantity `json:"minRequested,omitempty"`
+
+	// MaxRequested is the maximum sum of the quantity requested by Pods which
+	// are not terminated
+	// +optional
+	MaxRequested *resource.Quantity `json:"maxRequested,omitempty"`
+
+	// MinRequestedPercentage is the minimum quantity requested by Pods, as a
+	// percentage of the quantity allocatable on Nodes. Set it to 100 to match
+	// over-committed clusters.
+	// +optional
+	MinRequestedPercentage *int `json:"minRequestedPercentage,omitempty"`
+
+	// MaxRequestedPercentage is the maximum quantity requested by Pods, as a
+	// percentage of the quantity allocatable on Nodes
+	// +optional
+	MaxRequestedPercentage *int `json:"maxRequestedPercentage,omitempty"`
+}
+
+// needsAllocatable returns true if Nodes must be listed to evaluate c
+func (c *CapacityConstraint) needsAllocatable() bool {
+	return c.MinAllocatable != nil || c.MaxAllocatable != nil || c.needsPercentage()
+}
+
+// needsRequested returns true if Pods must be listed to evaluate c
+func (c *CapacityConstraint) needsRequested() bool {
+	return c.MinRequested != nil || c.MaxRequested != nil || c.needsPercentage()
+}
+
+func (c *CapacityConstraint) needsPercentage() bool {
+	return c.MinRequestedPercentage != nil || c.MaxRequestedPercentage != nil
+}
+
+// validateCapacityConstraint returns an error if c has no resource, no bound or
+// a minimum greater than its maximum
+func validateCapacityConstraint(c *CapacityConstraint) error {
+	if c.Resource == "" {
+		return fmt.Errorf("resource is required")
+	}
+	if !c.needsAllocatable() && !c.needsRequested() {
+		return fmt.Errorf("at least one bound is required")
+	}
+	if c.MinAllocatable != nil && c.MaxAllocatable != nil && c.MinAllocatable.Cmp(*c.MaxAllocatable) > 0 {
+		return fmt.Errorf("minAllocatable %s is greater than maxAllocatable %s",
+			c.MinAllocatable.String(), c.MaxAllocatable.String())
+	}
+	if c.MinRequested != nil && c.MaxRequested != nil && c.MinRequested.Cmp(*c.MaxRequested) > 0 {
+		return fmt.Errorf("minRequested %s is greater than maxRequested %s",
+			c.MinRequested.String(), c.MaxRequested.String())
+	}
+	if (c.MinRequestedPercentage != nil && *c.MinRequestedPercentage < 0) ||
+		(c.MaxRequestedPercentage != nil && *c.MaxRequestedPercentage < 0) {
+
+		return fmt.Errorf("requested percentage cannot be negative")
+	}
+	if c.MinRequestedPercentage != nil && c.MaxRequestedPercentage != nil &&
+		*c.MinRequestedPercentage > *c.MaxRequestedPercentage {
+
+		return fmt.Errorf("minRequestedPercentage %d is greater than maxRequestedPercentage %d",
+			*c.MinRequestedPercentage, *c.MaxRequestedPercentage)
+	}
+	return nil
+}
+
+// areCapacityConstraintsAMatch returns true if all CapacityConstraints are satisfied.
+// Nodes and Pods are listed at most once.
+func (m *manager) areCapacityConstraintsAMatch(ctx context.Context, classifier *libsveltosv1alpha1.Classifier,
+	extension *ClassifierExtension, snapshot *evaluationSnapshot) (bool, error) {
+
+	var nodes, pods []unstructured.Unstructured
+	var listedNodes, listedPods bool
+	for i := range extension.CapacityConstraints {
+		c := &extension.CapacityConstraints[i]
+		if err := validateCapacityConstraint(c); err != nil {
+			return false, newInvalidClassifierError(fmt.Errorf("capacity constraint %d: %w", i, err))
+		}
+
+		var err error
+		if c.needsAllocatable() && !listedNodes {
+			nodes, err = m.listAllResources(ctx, classifier, nodeGVK, snapshot)
+			if err != nil {
+				return false, err
+			}
+			listedNodes = true
+		}
+		if c.needsRequested() && !listedPods {
+			pods, err = m.listAllResources(ctx, classifier, podGVK, snapshot)
+			if err != nil {
+				return false, err
+			}
+			listedPods = true
+		}
+
+		if !isCapacityConstraintAMatch(ctx, c, nodes, pods) {
+			addEvaluationNote(ctx, fmt.Sprintf("capacity constraint %d not satisfied", i))
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// listAllResources returns all resources of kind gvk
+func (m *manager) listAllResources(ctx context.Context, classifier *libsveltosv1alpha1.Classifier,
+	gvk schema.GroupVersionKind, snapshot *evaluationSnapshot) ([]unstructured.Unstructured, error) {
+
+	constraint := &ResourceConstraint{}
+	constraint.Group = gvk.Group
+	constraint.Version = gvk.Version
+	constraint.Kind = gvk.Kind
+	items, _, err := m.getMatchingResources(ctx, classifier, constraint, snapshot)
+	return items, err
+}
+
+// isCapacityConstraintAMatch returns true if the capacity of nodes and pods satisfies c
+func isCapacityConstraintAMatch(ctx context.Context, c *CapacityConstraint,
+	nodes, pods []unstructured.Unstructured) bool {
+
+	allocatable := getAllocatableSum(nodes, c.Resource)
+	requested := getRequestedSum(pods, c.Resource)
+
+	if c.needsAllocatable() {
+		addEvaluationNote(ctx, fmt.Sprintf("%s: %s allocatable on %d Nodes",
+			c.Resource, allocatable.String(), len(nodes)))
+	}
+	if c.needsRequested() {
+		addEvaluationNote(ctx, fmt.Sprintf("%s: %s requested by %d Pods",
+			c.Resource, requested.String(), len(pods)))
+	}
+
+	if !isQuantityInRange(&allocatable, c.MinAllocatable, c.MaxAllocatable) ||
+		!isQuantityInRange(&requested, c.MinRequested, c.MaxRequested) {
+
+		return false
+	}
+
+	if !c.needsPercentage() {
+		return true
+	}
+	if allocatable.IsZero() {
+		addEvaluationNote(ctx, fmt.Sprintf("%s: nothing allocatable", c.Resource))
+		return false
+	}
+	percentage := float64(requested.MilliValue()) * 100 / float64(allocatable.MilliValue())
+	addEvaluationNote(ctx, fmt.Sprintf("%s: %.1f%% of allocatable requested", c.Resource, percentage))
+	if c.MinRequestedPercentage != nil && percentage < float64(*c.MinRequestedPercentage) {
+		return false
+	}
+	if c.MaxRequestedPercentage != nil && percentage > float64(*c.MaxRequestedPercentage) {
+		return false
+	}
+	return true
+}
+
+// isQuantityInRange returns true if quantity is within the bounds which are set
+func isQuantityInRange(quantity, min, max *resource.Quantity) bool {
+	if min != nil && quantity.Cmp(*min) < 0 {
+		return false
+	}
+	if max != nil && quantity.Cmp(*max) > 0 {
+		return false
+	}
+	return true
+}
+
+// getRequestedSum returns the sum of the quantity of resourceName requested by pods
+// which are not terminated
+func getRequestedSum(pods []unstructured.Unstructured, resourceName corev1.ResourceName) resource.Quantity {
+	total := resource.Quantity{}
+	for i := range pods {
+		phase, _, _ := unstructured.NestedString(pods[i].Object, "status", "phase")
+		if phase == string(corev1.PodSucceeded) || phase == string(corev1.PodFailed) {
+			continue
+		}
+		total.Add(getPodRequest(&pods[i], resourceName))
+	}
+	return total
+}
+
+// getPodRequest returns the quantity of resourceName requested by pod, computed as the
+// scheduler does: the larger of the sum of container requests and of any init container
+// request, plus pod overhead
+func getPodRequest(pod *unstructured.Unstructured, resourceName corev1.ResourceName) resource.Quantity {
+	request := resource.Quantity{}
+	containers, _, _ := unstructured.NestedSlice(pod.Object, "spec", "containers")
+	for i := range containers {
+		request.Add(getContainerRequest(containers[i], resourceName))
+	}
+
+	initContainers, _, _ := unstructured.NestedSlice(pod.Object, "spec", "initContainers")
+	for i := range initContainers {
+		initRequest := getContainerRequest(initContainers[i], resourceName)
+		if initRequest.Cmp(request) > 0 {
+			request = initRequest
+		}
+	}
+
+	request.Add(getQuantity(pod.Object, "spec", "overhead", string(resourceName)))
+	return request
+}
+
+// getContainerRequest returns the quantity of resourceName requested by container.
+// Zero if not requested or if quantity cannot be parsed.
+func getContainerRequest(container interface{}, resourceName corev1.ResourceName) resource.Quantity {
+	c, ok := container.(map[string]interface{})
+	if !ok {
+		return resource.Quantity{}
+	}
+	return getQuantity(c, "resources", "requests", string(resourceName))
+}
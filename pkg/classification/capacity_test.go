@@ -0,0 +1,171 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: capacity constraints", func() {
+	var server *httptest.Server
+	var classifier *libsveltosv1alpha1.Classifier
+
+	BeforeEach(func() {
+		getNode := func(name, allocatable string) string {
+			return `{"apiVersion":"v1","kind":"Node","metadata":{"name":"` + name + `"},` +
+				`"status":{"allocatable":{` + allocatable + `}}}`
+		}
+		getPod := func(name, phase, spec string) string {
+			return `{"apiVersion":"v1","kind":"Pod","metadata":{"namespace":"default","name":"` + name + `"},` +
+				`"spec":{` + spec + `},"status":{"phase":"` + phase + `"}}`
+		}
+		getContainer := func(requests string) string {
+			return `{"name":"c","image":"nginx","resources":{"requests":{` + requests + `}}}`
+		}
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			switch r.URL.Path {
+			case "/api":
+				_, _ = w.Write([]byte(`{"kind":"APIVersions","versions":["v1"]}`))
+			case "/api/v1":
+				_, _ = w.Write([]byte(`{"kind":"APIResourceList","groupVersion":"v1","resources":[` +
+					`{"name":"nodes","singularName":"node","namespaced":false,"kind":"Node","verbs":["list"]},` +
+					`{"name":"pods","singularName":"pod","namespaced":true,"kind":"Pod","verbs":["list"]}]}`))
+			case "/apis":
+				_, _ = w.Write([]byte(`{"kind":"APIGroupList","apiVersion":"v1","groups":[]}`))
+			case "/api/v1/nodes":
+				_, _ = w.Write([]byte(`{"apiVersion":"v1","kind":"NodeList","metadata":{},"items":[` +
+					getNode("node-1", `"cpu":"4","memory":"8Gi"`) + `,` +
+					getNode("node-2", `"cpu":"4","memory":"8Gi"`) + `]}`))
+			case "/api/v1/pods":
+				_, _ = w.Write([]byte(`{"apiVersion":"v1","kind":"PodList","metadata":{},"items":[` +
+					// 2 cpu requested by containers, 1 by init container: 2 cpu
+					getPod("web", "Running", `"containers":[`+getContainer(`"cpu":"1500m","memory":"4Gi"`)+`,`+
+						getContainer(`"cpu":"500m"`)+`],"initContainers":[`+getContainer(`"cpu":"1"`)+`]`) + `,` +
+					// 3 cpu requested by init container, plus 500m overhead
+					getPod("migration", "Pending", `"containers":[`+getContainer(`"cpu":"1"`)+`],`+
+						`"initContainers":[`+getContainer(`"cpu":"3"`)+`],"overhead":{"cpu":"500m"}`) + `,` +
+					// Terminated Pods are ignored
+					getPod("job", "Succeeded", `"containers":[`+getContainer(`"cpu":"8","memory":"8Gi"`)+`]`) +
+					`]}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+
+		classifier = &libsveltosv1alpha1.Classifier{ObjectMeta: metav1.ObjectMeta{Name: randomString()}}
+
+		classification.InitializeManagerWithObjects(&rest.Config{Host: server.URL})
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	evaluate := func(capacityConstraints string) (bool, []string, error) {
+		classifier.Annotations = map[string]string{
+			classification.ClassifierExtensionAnnotation: "capacityConstraints:\n" + capacityConstraints,
+		}
+		return classification.AreCapacityConstraintsAMatch(classification.GetManager(), classifier)
+	}
+
+	It("areCapacityConstraintsAMatch sums allocatable on all Nodes", func() {
+		isMatch, notes, err := evaluate(`- resource: memory
+  minAllocatable: 16Gi`)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+		Expect(notes).To(ContainElement("memory: 16Gi allocatable on 2 Nodes"))
+
+		isMatch, notes, err = evaluate(`- resource: cpu
+  minAllocatable: "16"`)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeFalse())
+		Expect(notes).To(ContainElement("capacity constraint 0 not satisfied"))
+	})
+
+	It("areCapacityConstraintsAMatch sums requests of Pods which are not terminated", func() {
+		isMatch, notes, err := evaluate(`- resource: cpu
+  minRequested: 5500m
+  maxRequested: 5500m`)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+		Expect(notes).To(ContainElement("cpu: 5500m requested by 3 Pods"))
+
+		isMatch, _, err = evaluate(`- resource: memory
+  maxRequested: 4Gi`)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+	})
+
+	It("areCapacityConstraintsAMatch compares requests with allocatable", func() {
+		// 5.5 cpu requested out of 8
+		isMatch, notes, err := evaluate(`- resource: cpu
+  minRequestedPercentage: 60
+  maxRequestedPercentage: 70`)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+		Expect(notes).To(ContainElement("cpu: 68.8% of allocatable requested"))
+
+		// Not over-committed
+		isMatch, _, err = evaluate(`- resource: cpu
+  minRequestedPercentage: 100`)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeFalse())
+
+		// Nothing allocatable
+		isMatch, notes, err = evaluate(`- resource: nvidia.com/gpu
+  maxRequestedPercentage: 100`)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeFalse())
+		Expect(notes).To(ContainElement("nvidia.com/gpu: nothing allocatable"))
+	})
+
+	It("areCapacityConstraintsAMatch rejects invalid constraints", func() {
+		_, _, err := evaluate(`- resource: cpu`)
+		Expect(err).ToNot(BeNil())
+
+		_, _, err = evaluate(`- minAllocatable: "1"`)
+		Expect(err).ToNot(BeNil())
+
+		_, _, err = evaluate(`- resource: cpu
+  minRequested: "2"
+  maxRequested: "1"`)
+		Expect(err).ToNot(BeNil())
+	})
+
+	It("GetClassifierGVKs includes Nodes and Pods only when needed", func() {
+		classifier.Annotations = map[string]string{
+			classification.ClassifierExtensionAnnotation: `capacityConstraints:
+- resource: cpu
+  minAllocatable: "1"`,
+		}
+		gvks := classification.GetClassifierGVKs(classifier)
+		Expect(gvks).To(ContainElement(HaveField("Kind", "Node")))
+		Expect(gvks).ToNot(ContainElement(HaveField("Kind", "Pod")))
+	})
+})
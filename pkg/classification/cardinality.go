@@ -0,0 +1,172 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+// validateCardinalityConstraint verifies Cardinality field is a valid path, bounds
+// are not negative and it is not combined with Percentage or Trend
+func validateCardinalityConstraint(constraint *ResourceConstraint) error {
+	cardinality := constraint.Cardinality
+	if constraint.Percentage != nil || constraint.Trend != nil || constraint.MustNotExist {
+		return fmt.Errorf("cardinality cannot be combined with percentage, trend or mustNotExist")
+	}
+	if cardinality.Field == "" {
+		return fmt.Errorf("cardinality field is required")
+	}
+	if _, err := parseFieldPath(cardinality.Field); err != nil {
+		return err
+	}
+	if cardinality.MinDistinct != nil && *cardinality.MinDistinct < 0 {
+		return fmt.Errorf("cardinality minDistinct %d is negative", *cardinality.MinDistinct)
+	}
+	if cardinality.MaxDistinct != nil && *cardinality.MaxDistinct < 0 {
+		return fmt.Errorf("cardinality maxDistinct %d is negative", *cardinality.MaxDistinct)
+	}
+	if cardinality.MinDistinct != nil && cardinality.MaxDistinct != nil &&
+		*cardinality.MinDistinct > *cardinality.MaxDistinct {
+
+		return fmt.Errorf("cardinality minDistinct %d is greater than maxDistinct %d",
+			*cardinality.MinDistinct, *cardinality.MaxDistinct)
+	}
+	return nil
+}
+
+// distinctValues collects the distinct values a field takes across resources
+type distinctValues struct {
+	path     string
+	segments []fieldSegment
+	values   map[string]struct{}
+}
+
+func newDistinctValues(path string) (*distinctValues, error) {
+	segments, err := parseFieldPath(path)
+	if err != nil {
+		return nil, err
+	}
+	return &distinctValues{path: path, segments: segments, values: make(map[string]struct{})}, nil
+}
+
+// add records the scalar values field resolves to in each resource
+func (d *distinctValues) add(items []unstructured.Unstructured) error {
+	buffers := getFieldBuffers()
+	defer releaseFieldBuffers(buffers)
+
+	for i := range items {
+		values, err := resolveSegments(items[i].Object, d.segments, d.path, buffers)
+		if err != nil {
+			return err
+		}
+		for j := range values {
+			value, ok, err := scalarToString(values[j], d.path)
+			if err != nil {
+				return err
+			}
+			if ok {
+				d.values[value] = struct{}{}
+			}
+		}
+	}
+	return nil
+}
+
+// isKnown returns true once more values cannot change the verdict: either
+// MaxDistinct is exceeded, or MinDistinct is reached and there is no MaxDistinct
+func (d *distinctValues) isKnown(cardinality *CardinalityConstraint) bool {
+	distinct := len(d.values)
+	if cardinality.MaxDistinct != nil {
+		return distinct > *cardinality.MaxDistinct
+	}
+	return cardinality.MinDistinct == nil || distinct >= *cardinality.MinDistinct
+}
+
+// isAMatch returns true if the number of distinct values is within bounds
+func (d *distinctValues) isAMatch(cardinality *CardinalityConstraint) bool {
+	distinct := len(d.values)
+	if cardinality.MinDistinct != nil && distinct < *cardinality.MinDistinct {
+		return false
+	}
+	if cardinality.MaxDistinct != nil && distinct > *cardinality.MaxDistinct {
+		return false
+	}
+	return true
+}
+
+func addCardinalityNote(ctx context.Context, constraint *ResourceConstraint, distinct int) {
+	addEvaluationNote(ctx, fmt.Sprintf("%s: %d distinct values of %s",
+		constraint.Kind, distinct, constraint.Cardinality.Field))
+}
+
+// evaluateCardinalityConstraint lists resources matching constraint and counts the
+// distinct values of the Cardinality field. Only the distinct values are kept across
+// pages. Listing stops as soon as the verdict is known.
+// A constraint whose group/version/kind is not served by the cluster is not satisfied.
+func (m *manager) evaluateCardinalityConstraint(ctx context.Context,
+	classifier *libsveltosv1alpha1.Classifier, constraint *ResourceConstraint,
+	snapshot *evaluationSnapshot) (bool, error) {
+
+	if err := validateCardinalityConstraint(constraint); err != nil {
+		return false, newInvalidClassifierError(err)
+	}
+
+	distinct, err := newDistinctValues(constraint.Cardinality.Field)
+	if err != nil {
+		return false, newInvalidClassifierError(err)
+	}
+
+	_, served, err := m.scanResources(ctx, classifier, constraint, snapshot,
+		func(items []unstructured.Unstructured, _ int) (bool, error) {
+			if err := distinct.add(items); err != nil {
+				return false, err
+			}
+			return distinct.isKnown(constraint.Cardinality), nil
+		})
+	if err != nil || !served {
+		return false, err
+	}
+
+	addCardinalityNote(ctx, constraint, len(distinct.values))
+	return distinct.isAMatch(constraint.Cardinality), nil
+}
+
+// isCardinalityAMatch returns true if the number of distinct values of the
+// Cardinality field across items is within bounds
+func isCardinalityAMatch(ctx context.Context, constraint *ResourceConstraint,
+	items []unstructured.Unstructured) (bool, error) {
+
+	if err := validateCardinalityConstraint(constraint); err != nil {
+		return false, newInvalidClassifierError(err)
+	}
+
+	distinct, err := newDistinctValues(constraint.Cardinality.Field)
+	if err != nil {
+		return false, newInvalidClassifierError(err)
+	}
+	if err := distinct.add(items); err != nil {
+		return false, err
+	}
+
+	addCardinalityNote(ctx, constraint, len(distinct.values))
+	return distinct.isAMatch(constraint.Cardinality), nil
+}
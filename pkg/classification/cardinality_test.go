@@ -0,0 +1,166 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/rest"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: cardinality constraints", func() {
+	var server *httptest.Server
+
+	BeforeEach(func() {
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			switch r.URL.Path {
+			case "/api":
+				_, _ = w.Write([]byte(`{"kind":"APIVersions","versions":[]}`))
+			case "/apis":
+				_, _ = w.Write([]byte(`{"kind":"APIGroupList","apiVersion":"v1","groups":[{"name":"example.com",` +
+					`"versions":[{"groupVersion":"example.com/v1","version":"v1"}],` +
+					`"preferredVersion":{"groupVersion":"example.com/v1","version":"v1"}}]}`))
+			case "/apis/example.com/v1":
+				_, _ = w.Write([]byte(`{"kind":"APIResourceList","apiVersion":"v1","groupVersion":"example.com/v1",` +
+					`"resources":[{"name":"widgets","singularName":"widget","namespaced":false,"kind":"Widget",` +
+					`"verbs":["list"]}]}`))
+			case "/apis/example.com/v1/widgets":
+				_, _ = w.Write([]byte(`{"apiVersion":"example.com/v1","kind":"WidgetList","metadata":{},"items":[` +
+					`{"apiVersion":"example.com/v1","kind":"Widget","metadata":{"name":"a",` +
+					`"labels":{"topology.kubernetes.io/zone":"zone-1"}}},` +
+					`{"apiVersion":"example.com/v1","kind":"Widget","metadata":{"name":"b",` +
+					`"labels":{"topology.kubernetes.io/zone":"zone-2"}}},` +
+					`{"apiVersion":"example.com/v1","kind":"Widget","metadata":{"name":"c",` +
+					`"labels":{"topology.kubernetes.io/zone":"zone-1"}}},` +
+					`{"apiVersion":"example.com/v1","kind":"Widget","metadata":{"name":"d"}}]}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+
+		classification.InitializeManagerWithObjects(&rest.Config{Host: server.URL})
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	getCardinalityConstraint := func(minDistinct, maxDistinct *int) *classification.ResourceConstraint {
+		return &classification.ResourceConstraint{
+			DeployedResourceConstraint: libsveltosv1alpha1.DeployedResourceConstraint{
+				Group: "example.com", Version: "v1", Kind: "Widget",
+			},
+			Cardinality: &classification.CardinalityConstraint{
+				Field:       "metadata.labels['topology.kubernetes.io/zone']",
+				MinDistinct: minDistinct,
+				MaxDistinct: maxDistinct,
+			},
+		}
+	}
+
+	It("evaluateResourceConstraint counts distinct field values", func() {
+		minDistinct := 2
+		isMatch, notes, err := classification.EvaluateCardinalityConstraint(classification.GetManager(),
+			getCardinalityConstraint(&minDistinct, nil))
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+		Expect(notes).To(Equal([]string{
+			"Widget: 2 distinct values of metadata.labels['topology.kubernetes.io/zone']"}))
+
+		minDistinct = 3
+		isMatch, _, err = classification.EvaluateCardinalityConstraint(classification.GetManager(),
+			getCardinalityConstraint(&minDistinct, nil))
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeFalse())
+
+		maxDistinct := 1
+		isMatch, _, err = classification.EvaluateCardinalityConstraint(classification.GetManager(),
+			getCardinalityConstraint(nil, &maxDistinct))
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeFalse())
+	})
+
+	It("evaluateResourceConstraint ignores MinCount when Cardinality is set", func() {
+		minCount := 10
+		minDistinct := 1
+		constraint := getCardinalityConstraint(&minDistinct, nil)
+		constraint.MinCount = &minCount
+		isMatch, _, err := classification.EvaluateCardinalityConstraint(classification.GetManager(), constraint)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+	})
+
+	It("evaluateResourceConstraint rejects invalid cardinality constraints", func() {
+		minDistinct := 3
+		maxDistinct := 2
+		constraint := getCardinalityConstraint(&minDistinct, &maxDistinct)
+		_, _, err := classification.EvaluateCardinalityConstraint(classification.GetManager(), constraint)
+		Expect(err).ToNot(BeNil())
+		Expect(classification.IsInvalidClassifierError(err)).To(BeTrue())
+
+		constraint = getCardinalityConstraint(nil, nil)
+		constraint.Cardinality.Field = "metadata.labels["
+		_, _, err = classification.EvaluateCardinalityConstraint(classification.GetManager(), constraint)
+		Expect(err).ToNot(BeNil())
+		Expect(classification.IsInvalidClassifierError(err)).To(BeTrue())
+
+		constraint = getCardinalityConstraint(nil, nil)
+		constraint.MustNotExist = true
+		_, _, err = classification.EvaluateCardinalityConstraint(classification.GetManager(), constraint)
+		Expect(err).ToNot(BeNil())
+		Expect(classification.IsInvalidClassifierError(err)).To(BeTrue())
+	})
+
+	It("isCardinalityAMatch counts values of all elements a wildcard path resolves to", func() {
+		pvc := func(storageClasses ...interface{}) unstructured.Unstructured {
+			volumes := make([]interface{}, len(storageClasses))
+			for i := range storageClasses {
+				volumes[i] = map[string]interface{}{"storageClass": storageClasses[i]}
+			}
+			return unstructured.Unstructured{Object: map[string]interface{}{"spec": map[string]interface{}{
+				"volumes": volumes}}}
+		}
+
+		minDistinct := 3
+		constraint := &classification.ResourceConstraint{
+			DeployedResourceConstraint: libsveltosv1alpha1.DeployedResourceConstraint{Kind: "Widget"},
+			Cardinality: &classification.CardinalityConstraint{
+				Field:       "spec.volumes[*].storageClass",
+				MinDistinct: &minDistinct,
+			},
+		}
+		items := []unstructured.Unstructured{pvc("gold", "silver"), pvc("gold"), pvc(map[string]interface{}{})}
+		isMatch, notes, err := classification.IsCardinalityAMatch(constraint, items)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeFalse())
+		Expect(notes).To(Equal([]string{"Widget: 2 distinct values of spec.volumes[*].storageClass"}))
+
+		items = append(items, pvc("bronze"))
+		isMatch, _, err = classification.IsCardinalityAMatch(constraint, items)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+	})
+})
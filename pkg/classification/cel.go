@@ -0,0 +1,131 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+
+	"emperror.dev/errors"
+	"github.com/google/cel-go/cel"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+const (
+	// celObjectVariable is the name resources are accessible with in a CEL expression
+	celObjectVariable = "object"
+)
+
+// celActivationPool reuses the variables passed to CEL programs, as a program
+// is evaluated on every listed resource
+var celActivationPool = sync.Pool{
+	New: func() interface{} {
+		return make(map[string]interface{}, 1)
+	},
+}
+
+// classifierPrograms contains the compiled CEL programs and regular
+// expressions for a given Classifier generation
+type classifierPrograms struct {
+	generation int64
+	programs   map[string]cel.Program
+	regexes    map[string]*regexp.Regexp
+}
+
+// getCELProgram returns the compiled CEL program for expression.
+// Programs are compiled once per Classifier generation. Any program compiled
+// for a previous generation is discarded.
+func (m *manager) getCELProgram(classifier *libsveltosv1alpha1.Classifier,
+	expression string) (cel.Program, error) {
+
+	m.celMu.Lock()
+	defer m.celMu.Unlock()
+
+	cached := m.getClassifierPrograms(classifier)
+	if prg, ok := cached.programs[expression]; ok {
+		return prg, nil
+	}
+
+	prg, err := compileCELExpression(expression)
+	if err != nil {
+		return nil, newInvalidClassifierError(err)
+	}
+	cached.programs[expression] = prg
+	return prg, nil
+}
+
+// getClassifierPrograms returns the programs compiled for the current Classifier
+// generation. Must be called with celMu held.
+func (m *manager) getClassifierPrograms(classifier *libsveltosv1alpha1.Classifier) *classifierPrograms {
+	cached, ok := m.celPrograms[classifier.Name]
+	if !ok || cached.generation != classifier.Generation {
+		cached = &classifierPrograms{
+			generation: classifier.Generation,
+			programs:   make(map[string]cel.Program),
+			regexes:    make(map[string]*regexp.Regexp),
+		}
+		m.celPrograms[classifier.Name] = cached
+	}
+	return cached
+}
+
+// removeCELPrograms drops all programs and regular expressions compiled for a Classifier
+func (m *manager) removeCELPrograms(classifierName string) {
+	m.celMu.Lock()
+	defer m.celMu.Unlock()
+
+	delete(m.celPrograms, classifierName)
+}
+
+func compileCELExpression(expression string) (cel.Program, error) {
+	env, err := cel.NewEnv(
+		cel.Variable(celObjectVariable, cel.MapType(cel.StringType, cel.DynType)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	ast, issues := env.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, errors.Wrap(issues.Err(),
+			fmt.Sprintf("failed to compile expression %q", expression))
+	}
+
+	return env.Program(ast)
+}
+
+// evaluateCELProgram returns the result of running prg against object.
+// Resource fields are dynamically typed, so result type can only be verified here.
+func evaluateCELProgram(prg cel.Program, object map[string]interface{}) (bool, error) {
+	activation := celActivationPool.Get().(map[string]interface{})
+	activation[celObjectVariable] = object
+	out, _, err := prg.Eval(activation)
+	// Drop the reference so pooled activation does not keep resource alive
+	delete(activation, celObjectVariable)
+	celActivationPool.Put(activation)
+	if err != nil {
+		return false, err
+	}
+
+	result, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("expression returned %v instead of a bool", out.Value())
+	}
+	return result, nil
+}
@@ -0,0 +1,155 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: CEL expressions", func() {
+	BeforeEach(func() {
+		classification.Reset()
+	})
+
+	It("getCELProgram compiles expressions once per Classifier generation", func() {
+		classification.InitializeManagerWithObjects(nil)
+		manager := classification.GetManager()
+
+		classifier := &libsveltosv1alpha1.Classifier{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       randomString(),
+				Generation: 1,
+			},
+		}
+
+		expression := `object.metadata.name == "nginx"`
+		prg, err := classification.GetCELProgram(manager, classifier, expression)
+		Expect(err).To(BeNil())
+		Expect(prg).ToNot(BeNil())
+		Expect(classification.GetCELProgramsCount(classifier.Name)).To(Equal(1))
+
+		cachedPrg, err := classification.GetCELProgram(manager, classifier, expression)
+		Expect(err).To(BeNil())
+		Expect(cachedPrg).To(BeIdenticalTo(prg))
+
+		_, err = classification.GetCELProgram(manager, classifier, `has(object.spec)`)
+		Expect(err).To(BeNil())
+		Expect(classification.GetCELProgramsCount(classifier.Name)).To(Equal(2))
+
+		// A new generation discards all previously compiled programs
+		classifier.Generation = 2
+		_, err = classification.GetCELProgram(manager, classifier, expression)
+		Expect(err).To(BeNil())
+		Expect(classification.GetCELProgramsCount(classifier.Name)).To(Equal(1))
+	})
+
+	It("getCELProgram returns an error for invalid expressions", func() {
+		classification.InitializeManagerWithObjects(nil)
+		manager := classification.GetManager()
+
+		classifier := &libsveltosv1alpha1.Classifier{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: randomString(),
+			},
+		}
+
+		_, err := classification.GetCELProgram(manager, classifier, `object.metadata.name ==`)
+		Expect(err).ToNot(BeNil())
+
+		_, err = classification.GetCELProgram(manager, classifier, `unknown.metadata.name == "a"`)
+		Expect(err).ToNot(BeNil())
+	})
+
+	It("evaluateCELProgram evaluates expression against object", func() {
+		classification.InitializeManagerWithObjects(nil)
+		manager := classification.GetManager()
+
+		classifier := &libsveltosv1alpha1.Classifier{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: randomString(),
+			},
+		}
+
+		object := map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"name": "nginx",
+			},
+			"spec": map[string]interface{}{
+				"replicas": int64(3),
+			},
+		}
+
+		prg, err := classification.GetCELProgram(manager, classifier, `object.spec.replicas > 2`)
+		Expect(err).To(BeNil())
+		result, err := classification.EvaluateCELProgram(prg, object)
+		Expect(err).To(BeNil())
+		Expect(result).To(BeTrue())
+
+		prg, err = classification.GetCELProgram(manager, classifier, `object.metadata.name.startsWith("apache")`)
+		Expect(err).To(BeNil())
+		result, err = classification.EvaluateCELProgram(prg, object)
+		Expect(err).To(BeNil())
+		Expect(result).To(BeFalse())
+
+		// Expression not returning a bool
+		prg, err = classification.GetCELProgram(manager, classifier, `object.metadata.name`)
+		Expect(err).To(BeNil())
+		_, err = classification.EvaluateCELProgram(prg, object)
+		Expect(err).ToNot(BeNil())
+	})
+
+	It("GetClassifierGVKs returns GVKs from Classifier Spec and extension", func() {
+		classifier := &libsveltosv1alpha1.Classifier{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: randomString(),
+				Annotations: map[string]string{
+					classification.ClassifierExtensionAnnotation: fmt.Sprintf(`deployedResourceConstraints:
+- group: apps
+  version: v1
+  kind: Deployment
+  minCount: 1
+  expression: %s`, `'object.spec.replicas > 1'`),
+				},
+			},
+			Spec: libsveltosv1alpha1.ClassifierSpec{
+				DeployedResourceConstraints: []libsveltosv1alpha1.DeployedResourceConstraint{
+					{Group: "", Version: "v1", Kind: "Pod"},
+				},
+			},
+		}
+
+		extension, err := classification.GetClassifierExtension(classifier)
+		Expect(err).To(BeNil())
+		Expect(len(extension.DeployedResourceConstraints)).To(Equal(1))
+		Expect(extension.DeployedResourceConstraints[0].Expression).To(Equal("object.spec.replicas > 1"))
+
+		gvks := classification.GetClassifierGVKs(classifier)
+		Expect(gvks).To(ConsistOf(
+			schema.GroupVersionKind{Group: "", Version: "v1", Kind: "Pod"},
+			schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+		))
+	})
+})
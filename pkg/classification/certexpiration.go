@@ -0,0 +1,216 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"regexp"
+	"time"
+
+	"emperror.dev/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+// CertificateSource identifies where certificates are read from
+type CertificateSource string
+
+const (
+	// CertificateSourceTLSSecret inspects the tls.crt key of kubernetes.io/tls Secrets
+	CertificateSourceTLSSecret = CertificateSource("TLSSecret")
+
+	// CertificateSourceWebhookCABundle inspects the caBundle of admission webhook configurations
+	CertificateSourceWebhookCABundle = CertificateSource("WebhookCABundle")
+)
+
+// CertificateExpirationConstraint is satisfied when at least one certificate read from
+// Source expires within Within, or has already expired, for instance to classify
+// clusters as needing certificate rotation.
+type CertificateExpirationConstraint struct {
+	// Source is TLSSecret or WebhookCABundle
+	Source CertificateSource `json:"source"`
+
+	// Namespace restricts TLSSecret to the Secrets in this namespace. All namespaces
+	// when empty. Not used by WebhookCABundle.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// NamePattern is a regular expression (RE2 syntax) the whole name of the Secret
+	// or webhook configuration must match. All names when empty.
+	// +optional
+	NamePattern string `json:"namePattern,omitempty"`
+
+	// Within is the expiration window, for instance "720h"
+	Within string `json:"within"`
+}
+
+// certificateSource is a named set of PEM encoded certificates
+type certificateSource struct {
+	kind string
+	name string
+	data [][]byte
+}
+
+// validateCertificateExpirationConstraint returns the window of c, an error if c is not valid
+func validateCertificateExpirationConstraint(c *CertificateExpirationConstraint) (time.Duration, error) {
+	if c.Source != CertificateSourceTLSSecret && c.Source != CertificateSourceWebhookCABundle {
+		return 0, fmt.Errorf("source must be %s or %s, got %q", CertificateSourceTLSSecret,
+			CertificateSourceWebhookCABundle, c.Source)
+	}
+	if c.Namespace != "" && c.Source != CertificateSourceTLSSecret {
+		return 0, fmt.Errorf("namespace can only be set with source %s", CertificateSourceTLSSecret)
+	}
+	within, err := time.ParseDuration(c.Within)
+	if err != nil {
+		return 0, errors.Wrap(err, "invalid within")
+	}
+	if within <= 0 {
+		return 0, errors.New("within must be positive")
+	}
+	return within, nil
+}
+
+// areCertificateExpirationsAMatch returns true if all CertificateExpirationConstraints are satisfied
+func (m *manager) areCertificateExpirationsAMatch(ctx context.Context,
+	classifier *libsveltosv1alpha1.Classifier) (bool, error) {
+
+	extension, err := getClassifierExtension(classifier)
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	for i := range extension.CertificateExpirationConstraints {
+		constraint := &extension.CertificateExpirationConstraints[i]
+		within, err := validateCertificateExpirationConstraint(constraint)
+		if err != nil {
+			return false, newInvalidClassifierError(
+				errors.Wrap(err, fmt.Sprintf("invalid certificate expiration constraint %d", i)))
+		}
+
+		var namePattern *regexp.Regexp
+		if constraint.NamePattern != "" {
+			namePattern, err = m.getRegex(classifier, constraint.NamePattern)
+			if err != nil {
+				return false, newInvalidClassifierError(err)
+			}
+		}
+
+		sources, err := m.getCertificateSources(ctx, constraint)
+		if err != nil {
+			return false, err
+		}
+
+		expiring := false
+		for j := range sources {
+			if namePattern != nil && !namePattern.MatchString(sources[j].name) {
+				continue
+			}
+			if cert := getExpiringCertificate(sources[j].data, now.Add(within)); cert != nil {
+				addEvaluationNote(ctx, fmt.Sprintf("certificate expiration constraint %d: %s %s certificate %q expires at %s",
+					i, sources[j].kind, sources[j].name, cert.Subject.CommonName, cert.NotAfter.UTC().Format(time.RFC3339)))
+				expiring = true
+				break
+			}
+		}
+		if !expiring {
+			addEvaluationNote(ctx, fmt.Sprintf("certificate expiration constraint %d: no certificate expires within %s",
+				i, within))
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// getCertificateSources returns the Secrets or webhook configurations constraint inspects
+func (m *manager) getCertificateSources(ctx context.Context,
+	constraint *CertificateExpirationConstraint) ([]certificateSource, error) {
+
+	if constraint.Source == CertificateSourceWebhookCABundle {
+		configurations, err := m.getWebhookConfigurations(ctx)
+		if err != nil {
+			return nil, err
+		}
+		sources := make([]certificateSource, len(configurations))
+		for i := range configurations {
+			sources[i] = certificateSource{kind: configurations[i].kind, name: configurations[i].name,
+				data: configurations[i].caBundles}
+		}
+		return sources, nil
+	}
+
+	secrets := &corev1.SecretList{}
+	options := []client.ListOption{}
+	if constraint.Namespace != "" {
+		options = append(options, client.InNamespace(constraint.Namespace))
+	}
+	if err := m.List(ctx, secrets, options...); err != nil {
+		return nil, err
+	}
+	sources := make([]certificateSource, 0)
+	for i := range secrets.Items {
+		secret := &secrets.Items[i]
+		if secret.Type != corev1.SecretTypeTLS {
+			continue
+		}
+		// Name only, so NamePattern is consistent across sources
+		sources = append(sources, certificateSource{kind: secretGVK.Kind,
+			name: secret.Name, data: [][]byte{secret.Data[corev1.TLSCertKey]}})
+	}
+	return sources, nil
+}
+
+// getExpiringCertificate returns the first certificate in the PEM encoded data
+// not valid anymore at deadline, nil if none. Data which is not a valid certificate
+// is ignored.
+func getExpiringCertificate(data [][]byte, deadline time.Time) *x509.Certificate {
+	for _, rest := range data {
+		for {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			if block.Type != "CERTIFICATE" {
+				continue
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				continue
+			}
+			if cert.NotAfter.Before(deadline) {
+				return cert
+			}
+		}
+	}
+	return nil
+}
+
+// getCertificateExpirationGVKs returns the GVKs of the resources constraint inspects
+func getCertificateExpirationGVKs(constraint *CertificateExpirationConstraint) []schema.GroupVersionKind {
+	if constraint.Source == CertificateSourceWebhookCABundle {
+		return []schema.GroupVersionKind{validatingWebhookGVK, mutatingWebhookGVK}
+	}
+	return []schema.GroupVersionKind{secretGVK}
+}
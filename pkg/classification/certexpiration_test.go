@@ -0,0 +1,145 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+// generateCertificate returns a PEM encoded self-signed certificate expiring at notAfter
+func generateCertificate(commonName string, notAfter time.Time) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).ToNot(HaveOccurred())
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).ToNot(HaveOccurred())
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+var _ = Describe("Manager: certificate expiration constraints", func() {
+	BeforeEach(func() {
+		classification.Reset()
+
+		soon := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ingress", Name: "ingress-tls"},
+			Type:       corev1.SecretTypeTLS,
+			Data:       map[string][]byte{corev1.TLSCertKey: generateCertificate("ingress", time.Now().Add(24*time.Hour))},
+		}
+		later := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "monitoring", Name: "grafana-tls"},
+			Type:       corev1.SecretTypeTLS,
+			Data:       map[string][]byte{corev1.TLSCertKey: generateCertificate("grafana", time.Now().Add(365*24*time.Hour))},
+		}
+		// Not a TLS Secret: ignored
+		opaque := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "monitoring", Name: "opaque"},
+			Data:       map[string][]byte{corev1.TLSCertKey: generateCertificate("opaque", time.Now())},
+		}
+		validating := &admissionregistrationv1.ValidatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: "policy-engine"},
+			Webhooks: []admissionregistrationv1.ValidatingWebhook{
+				{
+					Name: "validate.policy.io",
+					ClientConfig: admissionregistrationv1.WebhookClientConfig{
+						CABundle: append([]byte("not a certificate\n"),
+							generateCertificate("policy-ca", time.Now().Add(-time.Hour))...),
+					},
+				},
+			},
+		}
+
+		classification.InitializeManagerWithObjects(nil, soon, later, opaque, validating)
+	})
+
+	getClassifier := func(constraints string) *libsveltosv1alpha1.Classifier {
+		classifier := getClassifierWithKubernetesConstraints(version25, libsveltosv1alpha1.ComparisonEqual)
+		classifier.Annotations = map[string]string{
+			classification.ClassifierExtensionAnnotation: "certificateExpirationConstraints:\n" + constraints,
+		}
+		return classifier
+	}
+
+	It("areCertificateExpirationsAMatch matches certificates expiring within the window", func() {
+		manager := classification.GetManager()
+
+		isMatch, notes, err := classification.AreCertificateExpirationsAMatch(manager, getClassifier(`
+- source: TLSSecret
+  within: 720h
+- source: WebhookCABundle
+  within: 1h`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(isMatch).To(BeTrue())
+		Expect(notes).To(HaveLen(2))
+		Expect(notes[0]).To(ContainSubstring("Secret ingress-tls certificate \"ingress\""))
+		Expect(notes[1]).To(ContainSubstring("ValidatingWebhookConfiguration policy-engine"))
+	})
+
+	It("areCertificateExpirationsAMatch filters by namespace and name pattern", func() {
+		manager := classification.GetManager()
+
+		isMatch, notes, err := classification.AreCertificateExpirationsAMatch(manager, getClassifier(`
+- source: TLSSecret
+  namespace: monitoring
+  within: 720h`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(isMatch).To(BeFalse())
+		Expect(notes).To(ConsistOf(ContainSubstring("no certificate expires within 720h")))
+
+		isMatch, _, err = classification.AreCertificateExpirationsAMatch(manager, getClassifier(`
+- source: TLSSecret
+  namePattern: "grafana-.*"
+  within: 8760h1m`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(isMatch).To(BeTrue())
+	})
+
+	It("areCertificateExpirationsAMatch rejects invalid constraints", func() {
+		manager := classification.GetManager()
+
+		for _, constraint := range []string{
+			"- source: Ingress\n  within: 1h",
+			"- source: TLSSecret\n  within: soon",
+			"- source: WebhookCABundle\n  namespace: default\n  within: 1h",
+		} {
+			_, _, err := classification.AreCertificateExpirationsAMatch(manager, getClassifier(constraint))
+			Expect(err).To(HaveOccurred())
+			Expect(classification.IsInvalidClassifierError(err)).To(BeTrue())
+		}
+	})
+})
@@ -0,0 +1,178 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+)
+
+const (
+	// ClassifierReportClockSkewAnnotation is set on all ClassifierReports while the
+	// cluster clock differs from classifier-agent clock by more than the clock skew
+	// tolerance. Value is the offset of the cluster clock, for instance "-2m10s".
+	ClassifierReportClockSkewAnnotation = "classifier.projectsveltos.io/clock-skew"
+
+	// DefaultClockSkewTolerance is the default offset between cluster and classifier-agent
+	// clocks below which no correction is applied. Zero disables clock skew detection,
+	// which lists all Nodes and node Leases.
+	DefaultClockSkewTolerance = time.Duration(0)
+
+	// clockSkewRefreshInterval is the minimum interval between two clock skew detections
+	clockSkewRefreshInterval = time.Minute
+
+	// nodeLeaseNamespace contains the Lease each kubelet renews as heartbeat
+	nodeLeaseNamespace = "kube-node-lease"
+)
+
+var clockSkewSeconds = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "clock_skew_seconds",
+		Help:      "Offset of the cluster clock, estimated from node heartbeats, from classifier-agent clock",
+	},
+)
+
+func init() {
+	metrics.Registry.MustRegister(clockSkewSeconds)
+}
+
+// refreshClockSkew estimates, at most once every clockSkewRefreshInterval, the offset of
+// the cluster clock from classifier-agent clock. Failing to estimate it keeps the last
+// estimate.
+func (m *manager) refreshClockSkew(ctx context.Context, now time.Time) {
+	if m.clockSkewTolerance <= 0 {
+		return
+	}
+
+	m.clockSkewMu.Lock()
+	due := now.Sub(m.clockSkewRefreshed) >= clockSkewRefreshInterval
+	m.clockSkewMu.Unlock()
+	if !due {
+		return
+	}
+
+	offset, ok, err := m.detectClockSkew(ctx, now)
+	if err != nil {
+		m.log.V(logs.LogInfo).Info(fmt.Sprintf("failed to detect clock skew: %v", err))
+		return
+	}
+	m.setClockSkew(offset, ok, now)
+}
+
+// detectClockSkew returns the median, across nodes, of the offset between the last
+// node heartbeat and now. Heartbeat is the kubelet Lease renewTime or, when missing,
+// the Ready condition lastHeartbeatTime. Heartbeats lag behind by up to their renew
+// interval, which the tolerance must absorb. Returns false if no node has a heartbeat.
+func (m *manager) detectClockSkew(ctx context.Context, now time.Time) (time.Duration, bool, error) {
+	nodes := &corev1.NodeList{}
+	if err := m.List(ctx, nodes); err != nil {
+		return 0, false, err
+	}
+	leases := &coordinationv1.LeaseList{}
+	if err := m.List(ctx, leases, client.InNamespace(nodeLeaseNamespace)); err != nil {
+		return 0, false, err
+	}
+
+	renewed := make(map[string]time.Time, len(leases.Items))
+	for i := range leases.Items {
+		if leases.Items[i].Spec.RenewTime != nil {
+			renewed[leases.Items[i].Name] = leases.Items[i].Spec.RenewTime.Time
+		}
+	}
+
+	offsets := make([]time.Duration, 0, len(nodes.Items))
+	for i := range nodes.Items {
+		heartbeat, ok := renewed[nodes.Items[i].Name]
+		if !ok {
+			heartbeat = getNodeReadyHeartbeat(&nodes.Items[i])
+		}
+		if heartbeat.IsZero() {
+			continue
+		}
+		offsets = append(offsets, heartbeat.Sub(now))
+	}
+	if len(offsets) == 0 {
+		return 0, false, nil
+	}
+
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+	return offsets[len(offsets)/2], true, nil
+}
+
+// getNodeReadyHeartbeat returns the lastHeartbeatTime of node Ready condition
+func getNodeReadyHeartbeat(node *corev1.Node) time.Time {
+	for i := range node.Status.Conditions {
+		if node.Status.Conditions[i].Type == corev1.NodeReady {
+			return node.Status.Conditions[i].LastHeartbeatTime.Time
+		}
+	}
+	return time.Time{}
+}
+
+// setClockSkew stores the detected offset. Offsets within tolerance are ignored.
+func (m *manager) setClockSkew(offset time.Duration, detected bool, now time.Time) {
+	m.clockSkewMu.Lock()
+	defer m.clockSkewMu.Unlock()
+
+	m.clockSkewRefreshed = now
+	if !detected {
+		return
+	}
+	clockSkewSeconds.Set(offset.Seconds())
+
+	offset = offset.Truncate(time.Second)
+	if offset < m.clockSkewTolerance && offset > -m.clockSkewTolerance {
+		offset = 0
+	}
+	if offset != m.clockSkew {
+		m.log.V(logs.LogInfo).Info(fmt.Sprintf("cluster clock offset is %s", offset))
+	}
+	m.clockSkew = offset
+}
+
+// getClusterTime returns the current time on the cluster clock. Age and time window
+// constraints are evaluated against it, as resource timestamps are set by the cluster.
+func (m *manager) getClusterTime() time.Time {
+	m.clockSkewMu.Lock()
+	defer m.clockSkewMu.Unlock()
+	return time.Now().Add(m.clockSkew)
+}
+
+// setReportClockSkew sets ClassifierReportClockSkewAnnotation while the cluster clock
+// offset exceeds the tolerance, and removes it otherwise
+func (m *manager) setReportClockSkew(report *libsveltosv1alpha1.ClassifierReport) {
+	m.clockSkewMu.Lock()
+	defer m.clockSkewMu.Unlock()
+
+	value := ""
+	if m.clockSkew != 0 {
+		value = m.clockSkew.String()
+	}
+	setReportAnnotation(report, ClassifierReportClockSkewAnnotation, value)
+}
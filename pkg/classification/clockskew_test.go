@@ -0,0 +1,90 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: clock skew", func() {
+	var now time.Time
+
+	BeforeEach(func() {
+		classification.Reset()
+		now = time.Now().Truncate(time.Second)
+	})
+
+	getNode := func(name string, heartbeat time.Time) *corev1.Node {
+		node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		node.Status.Conditions = []corev1.NodeCondition{
+			{Type: corev1.NodeReady, Status: corev1.ConditionTrue, LastHeartbeatTime: metav1.NewTime(heartbeat)},
+		}
+		return node
+	}
+
+	It("detectClockSkew estimates the cluster clock offset from node heartbeats", func() {
+		renewTime := metav1.NewMicroTime(now.Add(-3 * time.Minute))
+		lease := &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "kube-node-lease", Name: "node-a"},
+			Spec:       coordinationv1.LeaseSpec{RenewTime: &renewTime},
+		}
+		classification.InitializeManagerWithObjects(nil, lease,
+			// Lease renewTime takes precedence over the stale Ready heartbeat
+			getNode("node-a", now.Add(-10*time.Minute)),
+			getNode("node-b", now.Add(-2*time.Minute)),
+			getNode("node-c", now.Add(-4*time.Minute)),
+			&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "joining"}})
+		manager := classification.GetManager()
+
+		offset, ok, err := classification.DetectClockSkew(manager, context.TODO(), now)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ok).To(BeTrue())
+		Expect(offset).To(Equal(-3 * time.Minute))
+	})
+
+	It("setClockSkew ignores offsets within tolerance and flags the others", func() {
+		classification.InitializeManagerWithObjects(nil)
+		classification.ApplyOptions(classification.WithClockSkewTolerance(time.Minute))
+		manager := classification.GetManager()
+
+		report := &libsveltosv1alpha1.ClassifierReport{}
+		classification.SetClockSkew(manager, 45*time.Second, true, now)
+		classification.SetReportClockSkew(manager, report)
+		Expect(report.Annotations).ToNot(HaveKey(classification.ClassifierReportClockSkewAnnotation))
+		Expect(classification.GetClusterTime(manager)).To(BeTemporally("~", time.Now(), time.Second))
+
+		classification.SetClockSkew(manager, -2*time.Hour, true, now)
+		classification.SetReportClockSkew(manager, report)
+		Expect(report.Annotations).To(HaveKeyWithValue(classification.ClassifierReportClockSkewAnnotation, "-2h0m0s"))
+		Expect(classification.GetClusterTime(manager)).To(BeTemporally("~", time.Now().Add(-2*time.Hour), time.Second))
+
+		// No heartbeat: last estimate is kept
+		classification.SetClockSkew(manager, 0, false, now)
+		Expect(classification.GetClusterTime(manager)).To(BeTemporally("~", time.Now().Add(-2*time.Hour), time.Second))
+	})
+})
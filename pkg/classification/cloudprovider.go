@@ -0,0 +1,130 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+)
+
+const (
+	// ClassifierReportCloudProviderAnnotation is set on the ClassifierReport of a
+	// Classifier with CloudProviders. Value is the comma separated list of cloud
+	// providers detected in the cluster.
+	ClassifierReportCloudProviderAnnotation = "classifier.projectsveltos.io/cloud-provider"
+)
+
+// nodeGVK is the GVK watched by Classifiers with CloudProviders
+var nodeGVK = schema.GroupVersionKind{Version: "v1", Kind: "Node"}
+
+// isCloudProviderAMatch returns true if Classifier has no CloudProviders or
+// if at least one of the cloud providers detected in the cluster is listed
+// in CloudProviders
+func (m *manager) isCloudProviderAMatch(ctx context.Context,
+	classifier *libsveltosv1alpha1.Classifier) (bool, error) {
+
+	extension, err := getClassifierExtension(classifier)
+	if err != nil {
+		return false, err
+	}
+
+	if len(extension.CloudProviders) == 0 {
+		return true, nil
+	}
+
+	nodes := &corev1.NodeList{}
+	if err := m.List(ctx, nodes); err != nil {
+		return false, err
+	}
+
+	detected := detectCloudProviders(nodes.Items)
+	m.setDetectedCloudProviders(detected)
+	m.log.V(logs.LogDebug).Info(fmt.Sprintf("detected cloud providers: %v", detected))
+
+	for i := range detected {
+		for j := range extension.CloudProviders {
+			if strings.EqualFold(detected[i], extension.CloudProviders[j]) {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// detectCloudProviders returns, sorted, the cloud providers found in nodes
+// spec.providerID. The cloud provider is the providerID scheme, for instance
+// aws for "aws:///us-east-1a/i-0123456789", gce for "gce://project/zone/name"
+// or azure for "azure:///subscriptions/...".
+// Nodes without providerID are ignored.
+func detectCloudProviders(nodes []corev1.Node) []string {
+	providers := make(map[string]bool)
+	for i := range nodes {
+		providerID := nodes[i].Spec.ProviderID
+		index := strings.Index(providerID, "://")
+		if index <= 0 {
+			continue
+		}
+		providers[strings.ToLower(providerID[:index])] = true
+	}
+
+	result := make([]string, 0, len(providers))
+	for provider := range providers {
+		result = append(result, provider)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// setDetectedCloudProviders stores the cloud providers detected in the cluster
+func (m *manager) setDetectedCloudProviders(providers []string) {
+	m.cloudProvidersMu.Lock()
+	defer m.cloudProvidersMu.Unlock()
+	m.cloudProviders = strings.Join(providers, ",")
+}
+
+// getDetectedCloudProviders returns the comma separated list of cloud providers
+// last detected in the cluster
+func (m *manager) getDetectedCloudProviders() string {
+	m.cloudProvidersMu.Lock()
+	defer m.cloudProvidersMu.Unlock()
+	return m.cloudProviders
+}
+
+// setReportCloudProvider sets ClassifierReportCloudProviderAnnotation when
+// Classifier has CloudProviders, and removes it otherwise
+func (m *manager) setReportCloudProvider(report *libsveltosv1alpha1.ClassifierReport,
+	classifier *libsveltosv1alpha1.Classifier) {
+
+	value := ""
+	extension, err := getClassifierExtension(classifier)
+	if err == nil && len(extension.CloudProviders) > 0 {
+		value = m.getDetectedCloudProviders()
+		if value == "" {
+			value = "unknown"
+		}
+	}
+	setReportAnnotation(report, ClassifierReportCloudProviderAnnotation, value)
+}
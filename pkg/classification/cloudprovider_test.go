@@ -0,0 +1,107 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/projectsveltos/classifier-agent/internal/utils"
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+func getNodeWithProviderID(providerID string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: randomString(),
+		},
+		Spec: corev1.NodeSpec{
+			ProviderID: providerID,
+		},
+	}
+}
+
+var _ = Describe("Manager: cloud provider", func() {
+
+	BeforeEach(func() {
+		classification.Reset()
+	})
+
+	It("detectCloudProviders returns providerID schemes", func() {
+		nodes := []corev1.Node{
+			*getNodeWithProviderID("aws:///us-east-1a/i-0123456789abcdef"),
+			*getNodeWithProviderID("gce://project/us-central1-a/node"),
+			*getNodeWithProviderID("AWS:///us-east-1b/i-0123456789abcdeg"),
+			*getNodeWithProviderID(""),
+			*getNodeWithProviderID("invalid"),
+		}
+		Expect(classification.DetectCloudProviders(nodes)).To(Equal([]string{"aws", "gce"}))
+	})
+
+	It("isCloudProviderAMatch matches clusters running on listed cloud providers", func() {
+		classifier := getClassifierWithKubernetesConstraints(version25, libsveltosv1alpha1.ComparisonEqual)
+		classifier.Annotations = map[string]string{
+			classification.ClassifierExtensionAnnotation: `cloudProviders: ["azure", "aws"]`,
+		}
+		node := getNodeWithProviderID("aws:///us-east-1a/i-0123456789abcdef")
+
+		c := classification.InitializeManagerWithObjects(nil, classifier, node)
+		manager := classification.GetManager()
+
+		isMatch, err := classification.IsCloudProviderAMatch(manager, context.TODO(), classifier)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+
+		Expect(classification.CreateClassifierReport(manager, context.TODO(), classifier, isMatch, nil)).
+			To(Succeed())
+		classifierReport := &libsveltosv1alpha1.ClassifierReport{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: utils.ReportNamespace, Name: classifier.Name},
+			classifierReport)).To(Succeed())
+		Expect(classifierReport.Annotations).To(HaveKeyWithValue(
+			classification.ClassifierReportCloudProviderAnnotation, "aws"))
+
+		classifier.Annotations[classification.ClassifierExtensionAnnotation] = `cloudProviders: ["gce"]`
+		isMatch, err = classification.IsCloudProviderAMatch(manager, context.TODO(), classifier)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeFalse())
+
+		// Classifier with no cloudProviders is always a match
+		delete(classifier.Annotations, classification.ClassifierExtensionAnnotation)
+		isMatch, err = classification.IsCloudProviderAMatch(manager, context.TODO(), classifier)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+	})
+
+	It("GetClassifierGVKs includes Node when cloudProviders is set", func() {
+		classifier := getClassifierWithKubernetesConstraints(version25, libsveltosv1alpha1.ComparisonEqual)
+		Expect(classification.GetClassifierGVKs(classifier)).To(BeEmpty())
+
+		classifier.Annotations = map[string]string{
+			classification.ClassifierExtensionAnnotation: `cloudProviders: ["aws"]`,
+		}
+		Expect(classification.GetClassifierGVKs(classifier)).To(ContainElement(
+			schema.GroupVersionKind{Version: "v1", Kind: "Node"}))
+	})
+})
@@ -0,0 +1,77 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"fmt"
+	"strings"
+
+	"emperror.dev/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+// knownClusterTypes are the cluster types registered in the management
+// cluster as of this release. Any other cluster type passing
+// ValidateClusterType is passed through as is.
+var knownClusterTypes = []libsveltosv1alpha1.ClusterType{
+	libsveltosv1alpha1.ClusterTypeCapi,
+	libsveltosv1alpha1.ClusterTypeSveltos,
+}
+
+// ValidateClusterType verifies clusterType can be used in ClassifierReport
+// names and labels in the management cluster. Cluster type is lowercased in
+// ClassifierReport names, so it must then be a valid DNS-1123 label.
+func ValidateClusterType(clusterType libsveltosv1alpha1.ClusterType) error {
+	if clusterType == "" {
+		return fmt.Errorf("cluster type must be set")
+	}
+
+	if errs := validation.IsValidLabelValue(string(clusterType)); len(errs) > 0 {
+		return fmt.Errorf("invalid cluster type %q: %s", clusterType, strings.Join(errs, ", "))
+	}
+
+	if errs := validation.IsDNS1123Label(strings.ToLower(string(clusterType))); len(errs) > 0 {
+		return fmt.Errorf("invalid cluster type %q: %s", clusterType, strings.Join(errs, ", "))
+	}
+
+	return nil
+}
+
+// IsKnownClusterType returns true if clusterType is one of the cluster types
+// known by this release
+func IsKnownClusterType(clusterType libsveltosv1alpha1.ClusterType) bool {
+	for i := range knownClusterTypes {
+		if strings.EqualFold(string(knownClusterTypes[i]), string(clusterType)) {
+			return true
+		}
+	}
+	return false
+}
+
+// wrapClusterTypeError adds context to errors returned by the management cluster
+// when it rejects a ClassifierReport. Management clusters running an older
+// release may not accept cluster types added later.
+func wrapClusterTypeError(err error, clusterType libsveltosv1alpha1.ClusterType) error {
+	if err == nil || !apierrors.IsInvalid(err) || IsKnownClusterType(clusterType) {
+		return err
+	}
+	return errors.Wrap(err,
+		fmt.Sprintf("management cluster rejected ClassifierReport for cluster type %q", clusterType))
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: cluster type", func() {
+	It("ValidateClusterType accepts any type usable in ClassifierReport names and labels", func() {
+		for _, clusterType := range []libsveltosv1alpha1.ClusterType{
+			libsveltosv1alpha1.ClusterTypeCapi, libsveltosv1alpha1.ClusterTypeSveltos, "HostedControlPlane", "k0smotron",
+		} {
+			Expect(classification.ValidateClusterType(clusterType)).To(Succeed())
+		}
+
+		for _, clusterType := range []libsveltosv1alpha1.ClusterType{
+			"", "hosted control plane", "hosted_control_plane", "-capi", "a/b",
+		} {
+			Expect(classification.ValidateClusterType(clusterType)).ToNot(Succeed(), string(clusterType))
+		}
+	})
+
+	It("IsKnownClusterType ignores case", func() {
+		Expect(classification.IsKnownClusterType("capi")).To(BeTrue())
+		Expect(classification.IsKnownClusterType(libsveltosv1alpha1.ClusterTypeSveltos)).To(BeTrue())
+		Expect(classification.IsKnownClusterType("HostedControlPlane")).To(BeFalse())
+	})
+
+	It("wrapClusterTypeError explains rejections of unknown cluster types", func() {
+		invalid := apierrors.NewInvalid(schema.GroupKind{Group: "lib.projectsveltos.io", Kind: "ClassifierReport"},
+			"report", field.ErrorList{field.Invalid(field.NewPath("spec", "clusterType"), "Hosted", "unsupported value")})
+
+		err := classification.WrapClusterTypeError(invalid, "Hosted")
+		Expect(err.Error()).To(ContainSubstring(`cluster type "Hosted"`))
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+
+		Expect(classification.WrapClusterTypeError(invalid, libsveltosv1alpha1.ClusterTypeCapi)).To(Equal(invalid))
+
+		other := errors.New("connection refused")
+		Expect(classification.WrapClusterTypeError(other, "Hosted")).To(Equal(other))
+		Expect(classification.WrapClusterTypeError(nil, "Hosted")).To(BeNil())
+	})
+})
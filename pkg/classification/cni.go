@@ -0,0 +1,131 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+// CNIs detected by classifier-agent
+const (
+	CNICalico    = "calico"
+	CNICilium    = "cilium"
+	CNIFlannel   = "flannel"
+	CNIAWSVPCCNI = "aws-vpc-cni"
+)
+
+// daemonSetGVK is watched by Classifiers with CNIs
+var daemonSetGVK = appsv1.SchemeGroupVersion.WithKind("DaemonSet")
+
+// cniDaemonSets maps the name of the DaemonSet each CNI deploys to the CNI
+var cniDaemonSets = map[string]string{
+	"calico-node":     CNICalico,
+	"cilium":          CNICilium,
+	"kube-flannel-ds": CNIFlannel,
+	"kube-flannel":    CNIFlannel,
+	"aws-node":        CNIAWSVPCCNI,
+}
+
+// cniCRDs maps a CustomResourceDefinition each CNI installs to the CNI. Those detect
+// CNIs deployed with a renamed DaemonSet, for instance by an operator.
+var cniCRDs = map[string]string{
+	"felixconfigurations.crd.projectcalico.org": CNICalico,
+	"ciliumnetworkpolicies.cilium.io":           CNICilium,
+	"eniconfigs.crd.k8s.amazonaws.com":          CNIAWSVPCCNI,
+}
+
+// isCNIAMatch returns true if Classifier has no CNIs or if at least one of the CNIs
+// detected in the cluster is listed in CNIs
+func (m *manager) isCNIAMatch(ctx context.Context, classifier *libsveltosv1alpha1.Classifier) (bool, error) {
+	extension, err := getClassifierExtension(classifier)
+	if err != nil {
+		return false, err
+	}
+
+	if len(extension.CNIs) == 0 {
+		return true, nil
+	}
+
+	detected, err := m.detectCNIs(ctx)
+	if err != nil {
+		return false, err
+	}
+	if len(detected) == 0 {
+		addEvaluationNote(ctx, "no known CNI detected")
+		return false, nil
+	}
+	addEvaluationNote(ctx, fmt.Sprintf("detected CNIs: %s", strings.Join(detected, ",")))
+
+	for i := range detected {
+		for j := range extension.CNIs {
+			if strings.EqualFold(detected[i], extension.CNIs[j]) {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// detectCNIs returns, sorted, the CNIs whose well-known DaemonSets or
+// CustomResourceDefinitions are present
+func (m *manager) detectCNIs(ctx context.Context) ([]string, error) {
+	cnis := make(map[string]bool)
+
+	daemonSets := &appsv1.DaemonSetList{}
+	if err := m.List(ctx, daemonSets); err != nil {
+		return nil, err
+	}
+	for i := range daemonSets.Items {
+		if cni, ok := cniDaemonSets[daemonSets.Items[i].Name]; ok {
+			cnis[cni] = true
+		}
+	}
+
+	for name, cni := range cniCRDs {
+		if cnis[cni] {
+			continue
+		}
+		crd, err := m.getCustomResourceDefinition(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if crd != nil {
+			cnis[cni] = true
+		}
+	}
+
+	result := make([]string, 0, len(cnis))
+	for cni := range cnis {
+		result = append(result, cni)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// getCNIGVKs returns the GVKs CNI detection depends on
+func getCNIGVKs() []schema.GroupVersionKind {
+	return []schema.GroupVersionKind{daemonSetGVK, crdGVK}
+}
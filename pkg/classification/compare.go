@@ -0,0 +1,134 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"fmt"
+	"time"
+
+	"emperror.dev/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+const (
+	// OperationGreaterThan can be used in FieldFilters of ClassifierExtension
+	// DeployedResourceConstraints. Field and filter values are parsed as Kubernetes
+	// quantities, so plain numbers ("3", "0.5") as well as "500m" or "2Gi" are
+	// supported. Field values which are not quantities never satisfy the filter.
+	OperationGreaterThan = libsveltosv1alpha1.Operation("GreaterThan")
+
+	// OperationGreaterThanOrEqual is like OperationGreaterThan but also accepts
+	// equal values
+	OperationGreaterThanOrEqual = libsveltosv1alpha1.Operation("GreaterThanOrEqual")
+
+	// OperationLessThan is satisfied by field values strictly lower than filter value
+	OperationLessThan = libsveltosv1alpha1.Operation("LessThan")
+
+	// OperationLessThanOrEqual is like OperationLessThan but also accepts
+	// equal values
+	OperationLessThanOrEqual = libsveltosv1alpha1.Operation("LessThanOrEqual")
+
+	// OperationOlderThan can be used in FieldFilters of ClassifierExtension
+	// DeployedResourceConstraints. Field value is parsed as a RFC3339 timestamp,
+	// for instance metadata.creationTimestamp, and filter value as a duration,
+	// for instance "72h". Satisfied when the timestamp is more than duration ago.
+	// Field values which are not timestamps never satisfy the filter.
+	OperationOlderThan = libsveltosv1alpha1.Operation("OlderThan")
+
+	// OperationNewerThan is satisfied when the timestamp is less than duration ago
+	OperationNewerThan = libsveltosv1alpha1.Operation("NewerThan")
+)
+
+func isComparisonOperation(operation libsveltosv1alpha1.Operation) bool {
+	switch operation {
+	case OperationGreaterThan, OperationGreaterThanOrEqual, OperationLessThan, OperationLessThanOrEqual:
+		return true
+	default:
+		return false
+	}
+}
+
+func isAgeOperation(operation libsveltosv1alpha1.Operation) bool {
+	return operation == OperationOlderThan || operation == OperationNewerThan
+}
+
+// parseFilterDuration parses the value of a FieldFilter with an age operation
+func parseFilterDuration(filter *libsveltosv1alpha1.FieldFilter) (*time.Duration, error) {
+	duration, err := time.ParseDuration(filter.Value)
+	if err != nil {
+		return nil, errors.Wrap(err,
+			fmt.Sprintf("field filter %q: value %q is not a duration", filter.Field, filter.Value))
+	}
+	return &duration, nil
+}
+
+// compareAge returns true if timestamp value is older (OperationOlderThan)
+// or newer (OperationNewerThan) than duration at now.
+// Returns false if value is not a RFC3339 timestamp.
+func compareAge(value string, operation libsveltosv1alpha1.Operation, duration time.Duration,
+	now time.Time) bool {
+
+	timestamp, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return false
+	}
+
+	age := now.Sub(timestamp)
+	switch operation {
+	case OperationOlderThan:
+		return age > duration
+	case OperationNewerThan:
+		return age < duration
+	default:
+		return false
+	}
+}
+
+// parseFilterQuantity parses the value of a FieldFilter with a comparison operation
+func parseFilterQuantity(filter *libsveltosv1alpha1.FieldFilter) (*resource.Quantity, error) {
+	quantity, err := resource.ParseQuantity(filter.Value)
+	if err != nil {
+		return nil, errors.Wrap(err,
+			fmt.Sprintf("field filter %q: value %q is not a number nor a quantity", filter.Field, filter.Value))
+	}
+	return &quantity, nil
+}
+
+// compareQuantity returns true if value compares to reference as
+// required by operation. Returns false if value is not a quantity.
+func compareQuantity(value string, operation libsveltosv1alpha1.Operation, reference *resource.Quantity) bool {
+	quantity, err := resource.ParseQuantity(value)
+	if err != nil {
+		return false
+	}
+
+	cmp := quantity.Cmp(*reference)
+	switch operation {
+	case OperationGreaterThan:
+		return cmp > 0
+	case OperationGreaterThanOrEqual:
+		return cmp >= 0
+	case OperationLessThan:
+		return cmp < 0
+	case OperationLessThanOrEqual:
+		return cmp <= 0
+	default:
+		return false
+	}
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+// ConditionFilter selects resources by a standard status condition, an entry of
+// status.conditions, for instance Deployments whose Available condition is True
+// or Nodes whose Ready condition is not True.
+type ConditionFilter struct {
+	// Type of the condition, for instance Available or Ready
+	Type string `json:"type"`
+
+	// Status is the condition status to compare with: True, False or Unknown.
+	// A resource without the condition has status Unknown.
+	Status metav1.ConditionStatus `json:"status"`
+
+	// Operation is either Equal or Different. Equal when not set.
+	// +optional
+	Operation libsveltosv1alpha1.Operation `json:"operation,omitempty"`
+}
+
+// validateConditionFilters returns an error if any filter is invalid
+func validateConditionFilters(filters []ConditionFilter) error {
+	for i := range filters {
+		f := &filters[i]
+		if f.Type == "" {
+			return fmt.Errorf("condition filter %d: type is required", i)
+		}
+		switch f.Status {
+		case metav1.ConditionTrue, metav1.ConditionFalse, metav1.ConditionUnknown:
+		default:
+			return fmt.Errorf("condition filter %d: status must be True, False or Unknown, got %q", i, f.Status)
+		}
+		switch f.Operation {
+		case "", libsveltosv1alpha1.OperationEqual, libsveltosv1alpha1.OperationDifferent:
+		default:
+			return fmt.Errorf("condition filter %d: unsupported operation %q", i, f.Operation)
+		}
+	}
+	return nil
+}
+
+// getConditionStatus returns the status of the condition of type conditionType.
+// Unknown if resource has no such condition.
+func getConditionStatus(resource *unstructured.Unstructured, conditionType string) metav1.ConditionStatus {
+	conditions, found, err := unstructured.NestedSlice(resource.Object, "status", "conditions")
+	if err != nil || !found {
+		return metav1.ConditionUnknown
+	}
+
+	for i := range conditions {
+		condition, ok := conditions[i].(map[string]interface{})
+		if !ok || condition["type"] != conditionType {
+			continue
+		}
+		if status, ok := condition["status"].(string); ok && status != "" {
+			return metav1.ConditionStatus(status)
+		}
+		return metav1.ConditionUnknown
+	}
+
+	return metav1.ConditionUnknown
+}
+
+// areConditionFiltersAMatch returns true if resource satisfies all filters
+func areConditionFiltersAMatch(resource *unstructured.Unstructured, filters []ConditionFilter) bool {
+	for i := range filters {
+		isEqual := getConditionStatus(resource, filters[i].Type) == filters[i].Status
+		if isEqual == (filters[i].Operation == libsveltosv1alpha1.OperationDifferent) {
+			return false
+		}
+	}
+	return true
+}
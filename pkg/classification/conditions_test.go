@@ -0,0 +1,104 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: condition filters", func() {
+	var resources []unstructured.Unstructured
+
+	BeforeEach(func() {
+		statuses := map[string][]interface{}{
+			"available": {
+				map[string]interface{}{"type": "Progressing", "status": "True"},
+				map[string]interface{}{"type": "Available", "status": "True"},
+			},
+			"unavailable": {
+				map[string]interface{}{"type": "Available", "status": "False"},
+			},
+			"unknown": {
+				map[string]interface{}{"type": "Available", "status": "Unknown"},
+			},
+			"no-conditions": nil,
+		}
+
+		resources = nil
+		for name, conditions := range statuses {
+			u := unstructured.Unstructured{Object: map[string]interface{}{}}
+			u.SetName(name)
+			if conditions != nil {
+				Expect(unstructured.SetNestedSlice(u.Object, conditions, "status", "conditions")).To(Succeed())
+			}
+			resources = append(resources, u)
+		}
+	})
+
+	filter := func(filters ...classification.ConditionFilter) []string {
+		items, err := classification.FilterResourcesWithConditions(
+			append([]unstructured.Unstructured{}, resources...), filters)
+		Expect(err).To(BeNil())
+		names := make([]string, len(items))
+		for i := range items {
+			names[i] = items[i].GetName()
+		}
+		return names
+	}
+
+	It("ConditionFilters compare condition status", func() {
+		Expect(filter(classification.ConditionFilter{Type: "Available", Status: metav1.ConditionTrue})).
+			To(ConsistOf("available"))
+		Expect(filter(classification.ConditionFilter{Type: "Available", Status: metav1.ConditionFalse})).
+			To(ConsistOf("unavailable"))
+		// A missing condition has status Unknown
+		Expect(filter(classification.ConditionFilter{Type: "Available", Status: metav1.ConditionUnknown})).
+			To(ConsistOf("unknown", "no-conditions"))
+		Expect(filter(classification.ConditionFilter{Type: "Available", Status: metav1.ConditionTrue,
+			Operation: libsveltosv1alpha1.OperationDifferent})).
+			To(ConsistOf("unavailable", "unknown", "no-conditions"))
+
+		// All filters must be satisfied
+		Expect(filter(
+			classification.ConditionFilter{Type: "Available", Status: metav1.ConditionTrue},
+			classification.ConditionFilter{Type: "Progressing", Status: metav1.ConditionTrue},
+		)).To(ConsistOf("available"))
+		Expect(filter(
+			classification.ConditionFilter{Type: "Available", Status: metav1.ConditionFalse},
+			classification.ConditionFilter{Type: "Progressing", Status: metav1.ConditionTrue},
+		)).To(BeEmpty())
+	})
+
+	It("Invalid ConditionFilters are rejected", func() {
+		invalid := []classification.ConditionFilter{
+			{Status: metav1.ConditionTrue},
+			{Type: "Ready", Status: "Yes"},
+			{Type: "Ready", Status: metav1.ConditionTrue, Operation: libsveltosv1alpha1.Operation("GreaterThan")},
+		}
+		for i := range invalid {
+			_, err := classification.FilterResourcesWithConditions(resources, invalid[i:i+1])
+			Expect(err).ToNot(BeNil())
+		}
+	})
+})
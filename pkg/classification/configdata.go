@@ -0,0 +1,156 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"fmt"
+
+	"emperror.dev/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+// ConfigDataKind is the kind of object a ConfigDataConstraint inspects
+type ConfigDataKind string
+
+const (
+	ConfigDataConfigMap = ConfigDataKind("ConfigMap")
+	ConfigDataSecret    = ConfigDataKind("Secret")
+)
+
+// ConfigDataConstraint is satisfied when a key of a ConfigMap or Secret exists and,
+// optionally, its value matches a pattern. Values are only compared in memory: those
+// are never copied into ClassifierReports, evaluation notes or logs.
+type ConfigDataConstraint struct {
+	// Kind is ConfigMap or Secret
+	Kind ConfigDataKind `json:"kind"`
+
+	// Namespace of the ConfigMap or Secret
+	Namespace string `json:"namespace"`
+
+	// Name of the ConfigMap or Secret
+	Name string `json:"name"`
+
+	// Key is the data key which must exist. For ConfigMaps, both data and
+	// binaryData are considered.
+	Key string `json:"key"`
+
+	// Pattern, when set, is a regular expression (RE2 syntax) the whole value
+	// must match, for instance "true|enabled"
+	// +optional
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// validateConfigDataConstraint returns an error if c is not valid
+func validateConfigDataConstraint(c *ConfigDataConstraint) error {
+	if c.Kind != ConfigDataConfigMap && c.Kind != ConfigDataSecret {
+		return fmt.Errorf("kind must be %s or %s, got %q", ConfigDataConfigMap, ConfigDataSecret, c.Kind)
+	}
+	if c.Namespace == "" || c.Name == "" || c.Key == "" {
+		return errors.New("namespace, name and key are required")
+	}
+	return nil
+}
+
+// areConfigDataConstraintsAMatch returns true if all ConfigDataConstraints are satisfied
+func (m *manager) areConfigDataConstraintsAMatch(ctx context.Context,
+	classifier *libsveltosv1alpha1.Classifier) (bool, error) {
+
+	extension, err := getClassifierExtension(classifier)
+	if err != nil {
+		return false, err
+	}
+
+	if len(extension.ConfigDataConstraints) == 0 {
+		return true, nil
+	}
+
+	clientset, err := kubernetes.NewForConfig(m.config)
+	if err != nil {
+		return false, err
+	}
+
+	for i := range extension.ConfigDataConstraints {
+		constraint := &extension.ConfigDataConstraints[i]
+		if err := validateConfigDataConstraint(constraint); err != nil {
+			return false, newInvalidClassifierError(
+				errors.Wrap(err, fmt.Sprintf("invalid config data constraint %d", i)))
+		}
+
+		value, found, err := getConfigDataValue(ctx, clientset, constraint)
+		if err != nil {
+			return false, err
+		}
+		// Notes only ever reference the key: value may be sensitive
+		source := fmt.Sprintf("key %q of %s %s/%s", constraint.Key, constraint.Kind,
+			constraint.Namespace, constraint.Name)
+		if !found {
+			addEvaluationNote(ctx, fmt.Sprintf("config data constraint %d: %s is missing", i, source))
+			return false, nil
+		}
+
+		if constraint.Pattern == "" {
+			continue
+		}
+		regex, err := m.getRegex(classifier, constraint.Pattern)
+		if err != nil {
+			return false, newInvalidClassifierError(err)
+		}
+		if !regex.Match(value) {
+			addEvaluationNote(ctx, fmt.Sprintf("config data constraint %d: value of %s does not match pattern", i, source))
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// getConfigDataValue returns the value of constraint key. Found is false if the
+// object or the key does not exist.
+func getConfigDataValue(ctx context.Context, clientset kubernetes.Interface,
+	constraint *ConfigDataConstraint) (value []byte, found bool, err error) {
+
+	switch constraint.Kind {
+	case ConfigDataSecret:
+		secret, err := clientset.CoreV1().Secrets(constraint.Namespace).Get(ctx, constraint.Name, metav1.GetOptions{})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil, false, nil
+			}
+			return nil, false, err
+		}
+		value, found = secret.Data[constraint.Key]
+		return value, found, nil
+	default:
+		configMap, err := clientset.CoreV1().ConfigMaps(constraint.Namespace).Get(ctx, constraint.Name, metav1.GetOptions{})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil, false, nil
+			}
+			return nil, false, err
+		}
+		if data, ok := configMap.Data[constraint.Key]; ok {
+			return []byte(data), true, nil
+		}
+		value, found = configMap.BinaryData[constraint.Key]
+		return value, found, nil
+	}
+}
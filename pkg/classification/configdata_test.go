@@ -0,0 +1,150 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: config data constraints", func() {
+	const secretValue = "s3cr3t-enabled"
+
+	var server *httptest.Server
+
+	BeforeEach(func() {
+		classification.Reset()
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			var obj interface{}
+			switch r.URL.Path {
+			case "/api/v1/namespaces/default/configmaps/features":
+				obj = &corev1.ConfigMap{
+					TypeMeta:   metav1.TypeMeta{Kind: "ConfigMap", APIVersion: "v1"},
+					ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "features"},
+					Data:       map[string]string{"gateway": "true"},
+					BinaryData: map[string][]byte{"blob": []byte("binary")},
+				}
+			case "/api/v1/namespaces/default/secrets/credentials":
+				obj = &corev1.Secret{
+					TypeMeta:   metav1.TypeMeta{Kind: "Secret", APIVersion: "v1"},
+					ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "credentials"},
+					Data:       map[string][]byte{"token": []byte(secretValue)},
+				}
+			default:
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"NotFound","code":404}`))
+				return
+			}
+			Expect(json.NewEncoder(w).Encode(obj)).To(Succeed())
+		}))
+
+		classification.InitializeManagerWithObjects(&rest.Config{Host: server.URL})
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	getClassifier := func(constraints string) *libsveltosv1alpha1.Classifier {
+		classifier := getClassifierWithKubernetesConstraints(version25, libsveltosv1alpha1.ComparisonEqual)
+		classifier.Annotations = map[string]string{
+			classification.ClassifierExtensionAnnotation: "configDataConstraints:\n" + constraints,
+		}
+		return classifier
+	}
+
+	It("areConfigDataConstraintsAMatch verifies keys exist and values match pattern", func() {
+		manager := classification.GetManager()
+
+		isMatch, _, err := classification.AreConfigDataConstraintsAMatch(manager, getClassifier(`
+- kind: ConfigMap
+  namespace: default
+  name: features
+  key: gateway
+  pattern: "true|yes"
+- kind: ConfigMap
+  namespace: default
+  name: features
+  key: blob
+- kind: Secret
+  namespace: default
+  name: credentials
+  key: token
+  pattern: ".*-enabled"`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(isMatch).To(BeTrue())
+
+		isMatch, notes, err := classification.AreConfigDataConstraintsAMatch(manager, getClassifier(`
+- kind: ConfigMap
+  namespace: default
+  name: missing
+  key: gateway`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(isMatch).To(BeFalse())
+		Expect(notes).To(ConsistOf(ContainSubstring("is missing")))
+
+		isMatch, _, err = classification.AreConfigDataConstraintsAMatch(manager, getClassifier(`
+- kind: Secret
+  namespace: default
+  name: credentials
+  key: password`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(isMatch).To(BeFalse())
+	})
+
+	It("areConfigDataConstraintsAMatch never reports values", func() {
+		manager := classification.GetManager()
+
+		isMatch, notes, err := classification.AreConfigDataConstraintsAMatch(manager, getClassifier(`
+- kind: Secret
+  namespace: default
+  name: credentials
+  key: token
+  pattern: disabled`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(isMatch).To(BeFalse())
+		Expect(notes).To(HaveLen(1))
+		Expect(notes[0]).To(ContainSubstring("does not match pattern"))
+		Expect(strings.Join(notes, " ")).ToNot(ContainSubstring(secretValue))
+	})
+
+	It("areConfigDataConstraintsAMatch rejects invalid constraints", func() {
+		manager := classification.GetManager()
+
+		_, _, err := classification.AreConfigDataConstraintsAMatch(manager, getClassifier(`
+- kind: Deployment
+  namespace: default
+  name: features
+  key: gateway`))
+		Expect(err).To(HaveOccurred())
+		Expect(classification.IsInvalidClassifierError(err)).To(BeTrue())
+	})
+})
@@ -0,0 +1,342 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+)
+
+const (
+	// EventReasonConformanceDivergence is the reason of the Event emitted when the
+	// reference evaluator and the optimized evaluation disagree on a constraint
+	EventReasonConformanceDivergence = "ConformanceDivergence"
+)
+
+var (
+	conformanceChecks = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "conformance_checks_total",
+			Help:      "Number of resource constraints evaluated by the reference evaluator",
+		},
+		[]string{classifierLabel},
+	)
+
+	conformanceDivergences = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "conformance_divergences_total",
+			Help:      "Number of resource constraints the reference evaluator and the optimized evaluation disagree on",
+		},
+		[]string{classifierLabel},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(conformanceChecks, conformanceDivergences)
+}
+
+// conformanceDivergence describes a constraint the reference evaluator and the
+// optimized evaluation disagree on
+type conformanceDivergence struct {
+	// constraint is the index of the constraint in the Classifier resource constraints
+	constraint      int
+	gvk             schema.GroupVersionKind
+	resourceVersion string
+	reference       bool
+	optimized       bool
+}
+
+func (d *conformanceDivergence) String() string {
+	return fmt.Sprintf("constraint %d on %s at resourceVersion %s: reference evaluator says %t, optimized evaluation says %t",
+		d.constraint, d.gvk.String(), d.resourceVersion, d.reference, d.optimized)
+}
+
+// isConformanceSampled returns true if the Classifier just evaluated must be verified
+// by the reference evaluator
+func (m *manager) isConformanceSampled() bool {
+	return m.conformanceSamplePercent > 0 && rand.Intn(100) < m.conformanceSamplePercent //nolint: gosec // not used for security
+}
+
+// verifyConformance evaluates, with the reference evaluator, all resource constraints
+// of classifier and compares each verdict with the one of the optimized evaluation.
+// Both evaluations see the cluster at the same resourceVersion. Divergences are
+// reported via metrics and Events.
+// Constraints the reference evaluator does not support (Trend, Cardinality), constraints
+// depending on the time of the evaluation (MinAge, MaxAge) and constraints the
+// optimized evaluation may only approximate (sampled Percentage, broad constraints
+// when policy is Sample) are not verified.
+func (m *manager) verifyConformance(ctx context.Context,
+	classifier *libsveltosv1alpha1.Classifier) ([]conformanceDivergence, error) {
+
+	extension, err := getClassifierExtension(classifier)
+	if err != nil {
+		return nil, err
+	}
+
+	var divergences []conformanceDivergence
+	constraints := getResourceConstraints(classifier, extension)
+	for i := range constraints {
+		constraint := &constraints[i]
+		if !m.isReferenceSupported(constraint) {
+			continue
+		}
+
+		reference, resourceVersion, served, err := m.referenceEvaluate(ctx, constraint)
+		if err != nil {
+			return divergences, err
+		}
+		if !served {
+			continue
+		}
+
+		snapshot := &evaluationSnapshot{resourceVersion: resourceVersion}
+		optimized, err := m.evaluateResourceConstraint(ctx, classifier, constraint, snapshot)
+		if err != nil {
+			return divergences, err
+		}
+
+		conformanceChecks.WithLabelValues(classifier.Name).Inc()
+		if reference == optimized {
+			continue
+		}
+
+		divergence := conformanceDivergence{
+			constraint:      i,
+			gvk:             schema.GroupVersionKind{Group: constraint.Group, Version: constraint.Version, Kind: constraint.Kind},
+			resourceVersion: resourceVersion,
+			reference:       reference,
+			optimized:       optimized,
+		}
+		divergences = append(divergences, divergence)
+		conformanceDivergences.WithLabelValues(classifier.Name).Inc()
+		m.log.V(logs.LogInfo).Info(fmt.Sprintf("classifier %s: conformance divergence: %s",
+			classifier.Name, divergence.String()))
+		if m.eventRecorder != nil {
+			m.eventRecorder.Event(classifier, corev1.EventTypeWarning, EventReasonConformanceDivergence,
+				divergence.String())
+		}
+	}
+
+	return divergences, nil
+}
+
+// removeConformanceMetrics removes the conformance metrics of a Classifier
+func removeConformanceMetrics(classifierName string) {
+	conformanceChecks.DeleteLabelValues(classifierName)
+	conformanceDivergences.DeleteLabelValues(classifierName)
+}
+
+func (m *manager) isReferenceSupported(constraint *ResourceConstraint) bool {
+	if constraint.Trend != nil || constraint.Cardinality != nil || constraint.MinAge != nil ||
+		constraint.MaxAge != nil || (constraint.Percentage != nil && constraint.Percentage.Sampling) {
+
+		return false
+	}
+	if m.broadConstraintPolicy == BroadConstraintSample &&
+		constraint.Namespace == "" && len(constraint.LabelFilters) == 0 {
+
+		return false
+	}
+	return true
+}
+
+// referenceEvaluate is a deliberately simple evaluation of constraint: all resources
+// of the constraint GVK are listed in a single request with no selector, and all
+// filters are evaluated one resource at a time, with no caching.
+// Returns the verdict and the resourceVersion resources were listed at.
+// Returns false if the constraint GVK is not served by the cluster.
+func (m *manager) referenceEvaluate(ctx context.Context,
+	constraint *ResourceConstraint) (isMatch bool, resourceVersion string, served bool, err error) {
+
+	gvk := schema.GroupVersionKind{Group: constraint.Group, Version: constraint.Version, Kind: constraint.Kind}
+
+	mapper, err := m.getRESTMapper()
+	if err != nil {
+		return false, "", false, err
+	}
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		if meta.IsNoMatchError(err) {
+			return false, "", false, nil
+		}
+		return false, "", false, err
+	}
+
+	d, err := dynamic.NewForConfig(m.config)
+	if err != nil {
+		return false, "", false, err
+	}
+	list, err := d.Resource(mapping.Resource).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return false, "", false, err
+	}
+
+	count, total, err := referenceCount(constraint, mapping.Scope.Name() == meta.RESTScopeNameNamespace,
+		list.Items)
+	if err != nil {
+		return false, "", false, err
+	}
+
+	if constraint.MustNotExist {
+		if err := validateMustNotExist(constraint); err != nil {
+			return false, "", false, newInvalidClassifierError(err)
+		}
+		return count == 0, list.GetResourceVersion(), true, nil
+	}
+	if constraint.Percentage != nil {
+		if err := validatePercentageConstraint(constraint.Percentage); err != nil {
+			return false, "", false, err
+		}
+		return isPercentageAMatch(constraint.Percentage, count, total), list.GetResourceVersion(), true, nil
+	}
+	return isCountAMatch(&constraint.DeployedResourceConstraint, count), list.GetResourceVersion(), true, nil
+}
+
+// referenceCount returns the number of resources satisfying constraint and the number
+// of resources a Percentage is computed on: the ones in the constraint namespaces and
+// satisfying LabelFilters without a regex operation, minus the skipped ones.
+func referenceCount(constraint *ResourceConstraint, namespaced bool,
+	resources []unstructured.Unstructured) (count, total int, err error) {
+
+	selector := labels.Everything()
+	labelSelector, err := getLabelSelector(constraint.LabelFilters)
+	if err != nil {
+		return 0, 0, newInvalidClassifierError(err)
+	}
+	if labelSelector != "" {
+		if selector, err = labels.Parse(labelSelector); err != nil {
+			return 0, 0, newInvalidClassifierError(err)
+		}
+	}
+
+	labelMatchers, err := getLabelFilterMatchers(constraint.LabelFilters, compileRegex)
+	if err != nil {
+		return 0, 0, newInvalidClassifierError(err)
+	}
+	annotationMatchers, err := getAnnotationFilterMatchers(constraint.AnnotationFilters, compileRegex)
+	if err != nil {
+		return 0, 0, newInvalidClassifierError(err)
+	}
+	if err := validateOwnerFilters(constraint.OwnerFilters); err != nil {
+		return 0, 0, newInvalidClassifierError(err)
+	}
+	if err := validateConditionFilters(constraint.ConditionFilters); err != nil {
+		return 0, 0, newInvalidClassifierError(err)
+	}
+	if err := validateRestartCountFilter(constraint); err != nil {
+		return 0, 0, newInvalidClassifierError(err)
+	}
+	if err := validateTaintFilters(constraint); err != nil {
+		return 0, 0, newInvalidClassifierError(err)
+	}
+	fieldMatchers, err := getFieldFilterMatchers(constraint.FieldFilters, compileRegex)
+	if err != nil {
+		return 0, 0, newInvalidClassifierError(err)
+	}
+	if err := applyMissingFieldPolicies(fieldMatchers, constraint.MissingFields); err != nil {
+		return 0, 0, newInvalidClassifierError(err)
+	}
+	if err := applyElementMatchPolicies(fieldMatchers, constraint.ElementMatch); err != nil {
+		return 0, 0, newInvalidClassifierError(err)
+	}
+
+	excluded := make(map[string]bool, len(constraint.ExcludedNamespaces))
+	for i := range constraint.ExcludedNamespaces {
+		excluded[constraint.ExcludedNamespaces[i]] = true
+	}
+
+	names := make(map[string]bool, len(constraint.ResourceNames))
+	for i := range constraint.ResourceNames {
+		names[constraint.ResourceNames[i]] = true
+	}
+
+	for i := range resources {
+		resource := &resources[i]
+		if constraint.Namespace != "" && resource.GetNamespace() != constraint.Namespace {
+			continue
+		}
+		if len(names) != 0 && !names[resource.GetName()] {
+			continue
+		}
+		if namespaced && excluded[resource.GetNamespace()] {
+			continue
+		}
+		if !selector.Matches(labels.Set(resource.GetLabels())) {
+			continue
+		}
+
+		// Only reconciled resources satisfying label, annotation, owner, condition,
+		// restart count and taint filters can be skipped because of missing fields
+		if (constraint.ReconciledOnly && !isReconciled(resource)) ||
+			!areLabelFilterMatchersAMatch(resource.Object, labelMatchers) ||
+			!areAnnotationFilterMatchersAMatch(resource.Object, annotationMatchers) ||
+			!areOwnerFiltersAMatch(resource, constraint.OwnerFilters) ||
+			!areConditionFiltersAMatch(resource, constraint.ConditionFilters) ||
+			!isRestartCountAMatch(resource, constraint.RestartCount) ||
+			!areTaintFiltersAMatch(resource, constraint.TaintFilters) {
+
+			total++
+			continue
+		}
+
+		outcome, err := evaluateFieldFilterMatchers(resource.Object, fieldMatchers)
+		if err != nil {
+			return 0, 0, newInvalidClassifierError(err)
+		}
+		if outcome == fieldFilterSkip {
+			continue
+		}
+		total++
+		if outcome != fieldFilterMatch {
+			continue
+		}
+
+		if constraint.Expression != "" {
+			// Expression is compiled again for every resource
+			prg, err := compileCELExpression(constraint.Expression)
+			if err != nil {
+				return 0, 0, newInvalidClassifierError(err)
+			}
+			isMatch, err := evaluateCELProgram(prg, resource.Object)
+			if err != nil {
+				return 0, 0, newInvalidClassifierError(err)
+			}
+			if !isMatch {
+				continue
+			}
+		}
+		count++
+	}
+
+	return count, total, nil
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: conformance", func() {
+	var server *httptest.Server
+	var mu *sync.Mutex
+	var ignoreLabelSelector bool
+	var resourceVersions []string
+
+	BeforeEach(func() {
+		mu = &sync.Mutex{}
+		ignoreLabelSelector = false
+		resourceVersions = nil
+
+		widget := func(name, app string) string {
+			return fmt.Sprintf(`{"apiVersion":"example.com/v1","kind":"Widget",`+
+				`"metadata":{"name":%q,"namespace":"default","labels":{"app":%q}}}`, name, app)
+		}
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			switch r.URL.Path {
+			case "/api":
+				_, _ = w.Write([]byte(`{"kind":"APIVersions","versions":[]}`))
+			case "/apis":
+				_, _ = w.Write([]byte(`{"kind":"APIGroupList","apiVersion":"v1","groups":[{"name":"example.com",` +
+					`"versions":[{"groupVersion":"example.com/v1","version":"v1"}],` +
+					`"preferredVersion":{"groupVersion":"example.com/v1","version":"v1"}}]}`))
+			case "/apis/example.com/v1":
+				_, _ = w.Write([]byte(`{"kind":"APIResourceList","apiVersion":"v1","groupVersion":"example.com/v1",` +
+					`"resources":[{"name":"widgets","singularName":"widget","namespaced":true,"kind":"Widget",` +
+					`"verbs":["list"]}]}`))
+			case "/apis/example.com/v1/widgets":
+				mu.Lock()
+				resourceVersions = append(resourceVersions, r.URL.Query().Get("resourceVersion"))
+				ignore := ignoreLabelSelector
+				mu.Unlock()
+
+				items := []string{widget("nginx", "nginx"), widget("web", "web"), widget("proxy", "proxy")}
+				if !ignore && r.URL.Query().Get("labelSelector") == "app=nginx" {
+					items = items[:1]
+				}
+				_, _ = w.Write([]byte(`{"kind":"WidgetList","apiVersion":"example.com/v1",` +
+					`"metadata":{"resourceVersion":"42"},"items":[` + strings.Join(items, ",") + `]}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+
+		classification.InitializeManagerWithObjects(&rest.Config{Host: server.URL})
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	getClassifier := func() *libsveltosv1alpha1.Classifier {
+		maxCount := 1
+		return &libsveltosv1alpha1.Classifier{
+			ObjectMeta: metav1.ObjectMeta{Name: randomString()},
+			Spec: libsveltosv1alpha1.ClassifierSpec{
+				DeployedResourceConstraints: []libsveltosv1alpha1.DeployedResourceConstraint{
+					{
+						Group: "example.com", Version: "v1", Kind: "Widget", MaxCount: &maxCount,
+						LabelFilters: []libsveltosv1alpha1.LabelFilter{
+							{Key: "app", Operation: libsveltosv1alpha1.OperationEqual, Value: "nginx"},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	It("verifyConformance finds no divergence when both evaluations agree", func() {
+		recorder := record.NewFakeRecorder(10)
+		classification.ApplyOptions(classification.WithEventRecorder(recorder))
+		manager := classification.GetManager()
+
+		divergences, err := classification.VerifyConformance(manager, context.TODO(), getClassifier())
+		Expect(err).To(BeNil())
+		Expect(divergences).To(BeEmpty())
+		Expect(recorder.Events).To(BeEmpty())
+
+		// Optimized evaluation lists at the resourceVersion the reference evaluator listed at
+		mu.Lock()
+		defer mu.Unlock()
+		Expect(resourceVersions).To(Equal([]string{"", "42"}))
+	})
+
+	It("verifyConformance reports divergences", func() {
+		// API server ignoring label selectors makes optimized evaluation count
+		// resources the reference evaluator filters out
+		ignoreLabelSelector = true
+
+		recorder := record.NewFakeRecorder(10)
+		classification.ApplyOptions(classification.WithEventRecorder(recorder))
+		manager := classification.GetManager()
+
+		divergences, err := classification.VerifyConformance(manager, context.TODO(), getClassifier())
+		Expect(err).To(BeNil())
+		Expect(divergences).To(HaveLen(1))
+
+		Expect(recorder.Events).To(HaveLen(1))
+		event := <-recorder.Events
+		Expect(event).To(ContainSubstring(classification.EventReasonConformanceDivergence))
+		Expect(event).To(ContainSubstring("reference evaluator says true, optimized evaluation says false"))
+	})
+
+	It("verifyConformance skips trend constraints", func() {
+		manager := classification.GetManager()
+
+		classifier := getClassifier()
+		classifier.Annotations = map[string]string{
+			classification.ClassifierExtensionAnnotation: `{"deployedResourceConstraints":[{"group":"example.com",` +
+				`"version":"v1","kind":"Widget","trend":{"window":"1h","minChangePercent":10}}]}`,
+		}
+		classifier.Spec.DeployedResourceConstraints = nil
+
+		divergences, err := classification.VerifyConformance(manager, context.TODO(), classifier)
+		Expect(err).To(BeNil())
+		Expect(divergences).To(BeEmpty())
+
+		mu.Lock()
+		defer mu.Unlock()
+		Expect(resourceVersions).To(BeEmpty())
+	})
+})
@@ -0,0 +1,196 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"fmt"
+	"strconv"
+	"unicode"
+)
+
+// constraintEvaluator returns whether the resource constraint at index is satisfied
+type constraintEvaluator func(index int) (bool, error)
+
+// constraintExpression is a boolean expression over resource constraint results
+type constraintExpression interface {
+	evaluate(evaluator constraintEvaluator) (bool, error)
+}
+
+// constraintRef is the result of the resource constraint at index
+type constraintRef struct {
+	index int
+}
+
+func (e *constraintRef) evaluate(evaluator constraintEvaluator) (bool, error) {
+	return evaluator(e.index)
+}
+
+type notExpression struct {
+	operand constraintExpression
+}
+
+func (e *notExpression) evaluate(evaluator constraintEvaluator) (bool, error) {
+	result, err := e.operand.evaluate(evaluator)
+	return !result, err
+}
+
+type andExpression struct {
+	left, right constraintExpression
+}
+
+func (e *andExpression) evaluate(evaluator constraintEvaluator) (bool, error) {
+	result, err := e.left.evaluate(evaluator)
+	if err != nil || !result {
+		return false, err
+	}
+	return e.right.evaluate(evaluator)
+}
+
+type orExpression struct {
+	left, right constraintExpression
+}
+
+func (e *orExpression) evaluate(evaluator constraintEvaluator) (bool, error) {
+	result, err := e.left.evaluate(evaluator)
+	if err != nil || result {
+		return result, err
+	}
+	return e.right.evaluate(evaluator)
+}
+
+// constraintExpressionParser is a recursive descent parser for:
+//
+//	or      := and ( "||" and )*
+//	and     := unary ( "&&" unary )*
+//	unary   := "!" unary | primary
+//	primary := "(" or ")" | "c" number
+type constraintExpressionParser struct {
+	expression  string
+	position    int
+	constraints int
+}
+
+// parseConstraintExpression parses expression. Constraints are referenced as c1..cN,
+// in the order returned by getResourceConstraints, N being constraints.
+func parseConstraintExpression(expression string, constraints int) (constraintExpression, error) {
+	p := &constraintExpressionParser{expression: expression, constraints: constraints}
+	result, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpaces()
+	if p.position != len(p.expression) {
+		return nil, p.errorf("unexpected %q", p.expression[p.position:])
+	}
+	return result, nil
+}
+
+func (p *constraintExpressionParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("invalid deployedResourceConstraintsExpression %q at position %d: %s",
+		p.expression, p.position, fmt.Sprintf(format, args...))
+}
+
+func (p *constraintExpressionParser) skipSpaces() {
+	for p.position < len(p.expression) && unicode.IsSpace(rune(p.expression[p.position])) {
+		p.position++
+	}
+}
+
+// consume skips spaces and, if the expression continues with token, consumes it
+func (p *constraintExpressionParser) consume(token string) bool {
+	p.skipSpaces()
+	if len(p.expression)-p.position >= len(token) &&
+		p.expression[p.position:p.position+len(token)] == token {
+
+		p.position += len(token)
+		return true
+	}
+	return false
+}
+
+func (p *constraintExpressionParser) parseOr() (constraintExpression, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.consume("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orExpression{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *constraintExpressionParser) parseAnd() (constraintExpression, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.consume("&&") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &andExpression{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *constraintExpressionParser) parseUnary() (constraintExpression, error) {
+	if p.consume("!") {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notExpression{operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *constraintExpressionParser) parsePrimary() (constraintExpression, error) {
+	if p.consume("(") {
+		result, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.consume(")") {
+			return nil, p.errorf("missing closing parenthesis")
+		}
+		return result, nil
+	}
+
+	if !p.consume("c") {
+		if p.position == len(p.expression) {
+			return nil, p.errorf("unexpected end of expression")
+		}
+		return nil, p.errorf("expected a constraint reference such as c1")
+	}
+	start := p.position
+	for p.position < len(p.expression) && unicode.IsDigit(rune(p.expression[p.position])) {
+		p.position++
+	}
+	index, err := strconv.Atoi(p.expression[start:p.position])
+	if err != nil {
+		return nil, p.errorf("expected a constraint number after c")
+	}
+	if index < 1 || index > p.constraints {
+		return nil, p.errorf("c%d does not exist, Classifier has %d resource constraints", index, p.constraints)
+	}
+	return &constraintRef{index: index - 1}, nil
+}
@@ -0,0 +1,194 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: resource constraints expression", func() {
+	It("parseConstraintExpression honors precedence and parentheses", func() {
+		isMatch, _, err := classification.EvaluateConstraintExpression("c1 && (c2 || !c3)",
+			[]bool{true, false, false})
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+
+		// && binds tighter than ||
+		isMatch, _, err = classification.EvaluateConstraintExpression("c1 || c2 && c3", []bool{true, false, false})
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+
+		isMatch, _, err = classification.EvaluateConstraintExpression("(c1 || c2) && c3", []bool{true, false, false})
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeFalse())
+
+		isMatch, _, err = classification.EvaluateConstraintExpression("!!c1", []bool{true})
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+	})
+
+	It("parseConstraintExpression short-circuits evaluation", func() {
+		isMatch, evaluated, err := classification.EvaluateConstraintExpression("c2 || c1 && c3",
+			[]bool{false, true, true})
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+		Expect(evaluated).To(Equal([]int{1}))
+
+		isMatch, evaluated, err = classification.EvaluateConstraintExpression("c1 && c2", []bool{false, true})
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeFalse())
+		Expect(evaluated).To(Equal([]int{0}))
+	})
+
+	It("parseConstraintExpression rejects invalid expressions", func() {
+		for _, expression := range []string{"c1 &&", "(c1 || c2", "c1 c2", "c0", "c3", "x1", "c1 & c2", "c"} {
+			_, _, err := classification.EvaluateConstraintExpression(expression, []bool{true, true})
+			Expect(err).ToNot(BeNil(), expression)
+		}
+	})
+
+	Context("areResourcesAMatch", func() {
+		var server *httptest.Server
+		var mu *sync.Mutex
+		var requests []string
+
+		BeforeEach(func() {
+			mu = &sync.Mutex{}
+			requests = nil
+
+			const widgetsPath = "/apis/example.com/v1/namespaces/default/widgets/"
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				switch {
+				case r.URL.Path == "/api":
+					_, _ = w.Write([]byte(`{"kind":"APIVersions","versions":[]}`))
+				case r.URL.Path == "/apis":
+					_, _ = w.Write([]byte(`{"kind":"APIGroupList","apiVersion":"v1","groups":[{"name":"example.com",` +
+						`"versions":[{"groupVersion":"example.com/v1","version":"v1"}],` +
+						`"preferredVersion":{"groupVersion":"example.com/v1","version":"v1"}}]}`))
+				case r.URL.Path == "/apis/example.com/v1":
+					_, _ = w.Write([]byte(`{"kind":"APIResourceList","apiVersion":"v1","groupVersion":"example.com/v1",` +
+						`"resources":[{"name":"widgets","singularName":"widget","namespaced":true,"kind":"Widget",` +
+						`"verbs":["get","list"]}]}`))
+				case strings.HasPrefix(r.URL.Path, widgetsPath):
+					name := strings.TrimPrefix(r.URL.Path, widgetsPath)
+					mu.Lock()
+					requests = append(requests, name)
+					mu.Unlock()
+					if name != "nginx" {
+						w.WriteHeader(http.StatusNotFound)
+						_, _ = w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Failure",` +
+							`"reason":"NotFound","code":404}`))
+						return
+					}
+					_, _ = w.Write([]byte(`{"apiVersion":"example.com/v1","kind":"Widget",` +
+						`"metadata":{"name":"nginx","namespace":"default"}}`))
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+
+			classification.InitializeManagerWithObjects(&rest.Config{Host: server.URL})
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		getClassifier := func(expression string) *libsveltosv1alpha1.Classifier {
+			// c1: nginx exists, c2: haproxy exists, c3: envoy exists
+			extension := `deployedResourceConstraints:
+- group: example.com
+  version: v1
+  kind: Widget
+  namespace: default
+  minCount: 1
+  resourceNames: [nginx]
+- group: example.com
+  version: v1
+  kind: Widget
+  namespace: default
+  minCount: 1
+  resourceNames: [haproxy]
+- group: example.com
+  version: v1
+  kind: Widget
+  namespace: default
+  minCount: 1
+  resourceNames: [envoy]
+`
+			if expression != "" {
+				extension += "deployedResourceConstraintsExpression: " + expression + "\n"
+			}
+			return &libsveltosv1alpha1.Classifier{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        randomString(),
+					Annotations: map[string]string{classification.ClassifierExtensionAnnotation: extension},
+				},
+			}
+		}
+
+		It("all constraints are required when no expression is set", func() {
+			isMatch, err := classification.AreResourcesAMatch(classification.GetManager(), context.TODO(),
+				getClassifier(""))
+			Expect(err).To(BeNil())
+			Expect(isMatch).To(BeFalse())
+		})
+
+		It("constraints are combined by the expression", func() {
+			isMatch, err := classification.AreResourcesAMatch(classification.GetManager(), context.TODO(),
+				getClassifier(`"c1 && (c2 || !c3)"`))
+			Expect(err).To(BeNil())
+			Expect(isMatch).To(BeTrue())
+
+			mu.Lock()
+			Expect(requests).To(Equal([]string{"nginx", "haproxy", "envoy"}))
+			requests = nil
+			mu.Unlock()
+
+			// c1 is evaluated once even if referenced twice, c3 is never evaluated
+			isMatch, err = classification.AreResourcesAMatch(classification.GetManager(), context.TODO(),
+				getClassifier(`"(c2 || c1) && c1"`))
+			Expect(err).To(BeNil())
+			Expect(isMatch).To(BeTrue())
+
+			mu.Lock()
+			defer mu.Unlock()
+			Expect(requests).To(Equal([]string{"haproxy", "nginx"}))
+		})
+
+		It("an invalid expression makes the Classifier invalid", func() {
+			_, err := classification.AreResourcesAMatch(classification.GetManager(), context.TODO(),
+				getClassifier(`"c1 || c4"`))
+			Expect(err).ToNot(BeNil())
+			Expect(classification.IsInvalidClassifierError(err)).To(BeTrue())
+		})
+	})
+})
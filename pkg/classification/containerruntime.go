@@ -0,0 +1,148 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+// criSocketAnnotation is set by kubeadm on each Node to the CRI socket kubelet is
+// configured with
+const criSocketAnnotation = "kubeadm.alpha.kubernetes.io/cri-socket"
+
+// ContainerRuntimeConstraint is a constraint on the container runtimes nodes run,
+// for instance to hold back migration sensitive add-ons while node pools move from
+// one runtime to another.
+type ContainerRuntimeConstraint struct {
+	// Mixed, when true, requires the cluster to be mid-migration: nodes run more
+	// than one container runtime, or a node is configured with the CRI socket of a
+	// runtime other than the one it reports. When false, requires the opposite.
+	// +optional
+	Mixed *bool `json:"mixed,omitempty"`
+
+	// Runtimes requires all nodes to run one of the listed runtimes, for instance
+	// containerd, cri-o or docker
+	// +optional
+	Runtimes []string `json:"runtimes,omitempty"`
+}
+
+// isContainerRuntimeAMatch returns true if Classifier has no ContainerRuntime constraint
+// or if the container runtimes nodes run satisfy it
+func (m *manager) isContainerRuntimeAMatch(ctx context.Context,
+	classifier *libsveltosv1alpha1.Classifier) (bool, error) {
+
+	extension, err := getClassifierExtension(classifier)
+	if err != nil {
+		return false, err
+	}
+
+	constraint := extension.ContainerRuntime
+	if constraint == nil {
+		return true, nil
+	}
+
+	nodes := &corev1.NodeList{}
+	if err := m.List(ctx, nodes); err != nil {
+		return false, err
+	}
+
+	runtimes, migrating := detectContainerRuntimes(nodes.Items)
+	mixed := len(runtimes) > 1 || len(migrating) > 0
+	addEvaluationNote(ctx, fmt.Sprintf("container runtimes: %s", strings.Join(runtimes, ",")))
+	if len(migrating) > 0 {
+		addEvaluationNote(ctx, fmt.Sprintf("nodes with CRI socket of another runtime: %s",
+			strings.Join(migrating, ",")))
+	}
+
+	if constraint.Mixed != nil && *constraint.Mixed != mixed {
+		return false, nil
+	}
+
+	if len(constraint.Runtimes) == 0 {
+		return true, nil
+	}
+	for i := range runtimes {
+		if !containsRuntime(constraint.Runtimes, runtimes[i]) {
+			addEvaluationNote(ctx, fmt.Sprintf("container runtime %s is not listed", runtimes[i]))
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// detectContainerRuntimes returns, sorted, the container runtimes found in nodes
+// status.nodeInfo.containerRuntimeVersion, and the nodes whose CRI socket belongs to
+// another runtime. The runtime is the version scheme, for instance containerd for
+// "containerd://1.6.8" or cri-o for "cri-o://1.25.1".
+// Nodes not reporting a runtime yet are ignored.
+func detectContainerRuntimes(nodes []corev1.Node) (runtimes, migrating []string) {
+	detected := make(map[string]bool)
+	for i := range nodes {
+		runtimeVersion := nodes[i].Status.NodeInfo.ContainerRuntimeVersion
+		index := strings.Index(runtimeVersion, "://")
+		if index <= 0 {
+			continue
+		}
+		runtime := strings.ToLower(runtimeVersion[:index])
+		detected[runtime] = true
+
+		socketRuntime := getCRISocketRuntime(nodes[i].Annotations[criSocketAnnotation])
+		if socketRuntime != "" && socketRuntime != runtime {
+			migrating = append(migrating, nodes[i].Name)
+		}
+	}
+
+	runtimes = make([]string, 0, len(detected))
+	for runtime := range detected {
+		runtimes = append(runtimes, runtime)
+	}
+	sort.Strings(runtimes)
+	sort.Strings(migrating)
+	return runtimes, migrating
+}
+
+// getCRISocketRuntime returns the runtime serving socket, empty if unknown
+func getCRISocketRuntime(socket string) string {
+	switch {
+	case strings.Contains(socket, "containerd"):
+		return "containerd"
+	case strings.Contains(socket, "crio"):
+		return "cri-o"
+	case strings.Contains(socket, "dockershim"), strings.Contains(socket, "cri-dockerd"):
+		return "docker"
+	default:
+		return ""
+	}
+}
+
+// containsRuntime returns true if runtime is in runtimes, ignoring case
+func containsRuntime(runtimes []string, runtime string) bool {
+	for i := range runtimes {
+		if strings.EqualFold(runtimes[i], runtime) {
+			return true
+		}
+	}
+	return false
+}
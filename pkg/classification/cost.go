@@ -0,0 +1,182 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/client-go/rest"
+)
+
+// EvaluationCost is the cost of evaluating a Classifier
+type EvaluationCost struct {
+	// Requests is the number of requests sent to the API server
+	Requests int64 `json:"requests"`
+
+	// BytesSent is the number of bytes sent to the API server in request bodies
+	BytesSent int64 `json:"bytesSent"`
+
+	// BytesReceived is the number of bytes received from the API server
+	// in response bodies
+	BytesReceived int64 `json:"bytesReceived"`
+
+	// CPUTime is the CPU time used by classifier-agent while evaluating.
+	// Classifiers are evaluated one at a time, so it is mostly spent on this
+	// Classifier, but it also includes CPU used by watchers and controllers
+	// meanwhile.
+	CPUTime time.Duration `json:"cpuTime"`
+}
+
+// costTracker accumulates the API server traffic of one evaluation
+type costTracker struct {
+	requests      int64
+	bytesSent     int64
+	bytesReceived int64
+
+	// maxRequests, when positive, is the number of requests after which
+	// any further request fails
+	maxRequests    int64
+	budgetExceeded int32
+
+	// access, when set, records the verbs and resources requests access
+	access *accessRecorder
+
+	// parent, when set, is attributed all requests as well
+	parent *costTracker
+}
+
+// addRequests counts requests on t and its parents. Returns false if the budget
+// of any of them is exceeded.
+func (t *costTracker) addRequests(requests int64) bool {
+	withinBudget := true
+	for ; t != nil; t = t.parent {
+		total := atomic.AddInt64(&t.requests, requests)
+		if t.maxRequests > 0 && total > t.maxRequests {
+			atomic.StoreInt32(&t.budgetExceeded, 1)
+			withinBudget = false
+		}
+	}
+	return withinBudget
+}
+
+func (t *costTracker) addBytes(sent, received int64) {
+	for ; t != nil; t = t.parent {
+		atomic.AddInt64(&t.bytesSent, sent)
+		atomic.AddInt64(&t.bytesReceived, received)
+	}
+}
+
+func (t *costTracker) recordAccess(req *http.Request, resp *http.Response, err error) {
+	for ; t != nil; t = t.parent {
+		if t.access != nil {
+			t.access.record(req, resp, err)
+		}
+	}
+}
+
+// replay attributes to t the cost and accesses another tracker recorded, as
+// if t had sent the same requests
+func (t *costTracker) replay(cost *EvaluationCost, accesses []AccessRecord) {
+	t.addRequests(cost.Requests)
+	t.addBytes(cost.BytesSent, cost.BytesReceived)
+	for ; t != nil; t = t.parent {
+		if t.access != nil {
+			t.access.merge(accesses)
+		}
+	}
+}
+
+func (t *costTracker) getCost(cpuTime time.Duration) *EvaluationCost {
+	return &EvaluationCost{
+		Requests:      atomic.LoadInt64(&t.requests),
+		BytesSent:     atomic.LoadInt64(&t.bytesSent),
+		BytesReceived: atomic.LoadInt64(&t.bytesReceived),
+		CPUTime:       cpuTime,
+	}
+}
+
+type costTrackerKey struct{}
+
+// withCostTracker returns a context attributing to tracker all API server
+// requests made with it
+func withCostTracker(ctx context.Context, tracker *costTracker) context.Context {
+	return context.WithValue(ctx, costTrackerKey{}, tracker)
+}
+
+func getCostTracker(ctx context.Context) *costTracker {
+	tracker, _ := ctx.Value(costTrackerKey{}).(*costTracker)
+	return tracker
+}
+
+// withCostTracking returns a copy of config whose requests are attributed to
+// the costTracker, if any, in the request context
+func withCostTracking(config *rest.Config) *rest.Config {
+	if config == nil {
+		return nil
+	}
+
+	trackedConfig := rest.CopyConfig(config)
+	trackedConfig.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return &costRoundTripper{rt: rt}
+	})
+	return trackedConfig
+}
+
+// costRoundTripper counts requests and bytes exchanged with the API server
+type costRoundTripper struct {
+	rt http.RoundTripper
+}
+
+func (c *costRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	tracker := getCostTracker(req.Context())
+	if tracker == nil {
+		return c.rt.RoundTrip(req)
+	}
+
+	if !tracker.addRequests(1) {
+		return nil, errRequestBudgetExceeded
+	}
+	if req.ContentLength > 0 {
+		tracker.addBytes(req.ContentLength, 0)
+	}
+
+	resp, err := c.rt.RoundTrip(req)
+	tracker.recordAccess(req, resp, err)
+	if err != nil || resp.Body == nil {
+		return resp, err
+	}
+
+	// Body is read after RoundTrip returns
+	resp.Body = &countingReadCloser{ReadCloser: resp.Body, tracker: tracker}
+	return resp, nil
+}
+
+// countingReadCloser counts bytes read from a response body
+type countingReadCloser struct {
+	io.ReadCloser
+	tracker *costTracker
+}
+
+func (c *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.tracker.addBytes(0, int64(n))
+	return n, err
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/client-go/rest"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+)
+
+var _ = Describe("Manager: evaluation cost", func() {
+	const responseBody = `{"kind":"PodList","items":[]}`
+
+	var server *httptest.Server
+
+	BeforeEach(func() {
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.Copy(io.Discard, r.Body)
+			_, _ = w.Write([]byte(responseBody))
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("withCostTracking attributes requests to the cost tracker in the request context", func() {
+		config := classification.WithCostTracking(&rest.Config{Host: server.URL})
+		httpClient, err := rest.HTTPClientFor(config)
+		Expect(err).To(BeNil())
+
+		tracker := &classification.CostTracker{}
+		ctx := classification.WithCostTracker(context.TODO(), tracker)
+
+		const requestBody = "request body"
+		for i := 0; i < 2; i++ {
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL,
+				strings.NewReader(requestBody))
+			Expect(err).To(BeNil())
+			resp, err := httpClient.Do(req)
+			Expect(err).To(BeNil())
+			_, err = io.ReadAll(resp.Body)
+			Expect(err).To(BeNil())
+			Expect(resp.Body.Close()).To(Succeed())
+		}
+
+		// Requests without a cost tracker are not counted
+		req, err := http.NewRequestWithContext(context.TODO(), http.MethodGet, server.URL, http.NoBody)
+		Expect(err).To(BeNil())
+		resp, err := httpClient.Do(req)
+		Expect(err).To(BeNil())
+		Expect(resp.Body.Close()).To(Succeed())
+
+		cost := classification.GetCost(tracker)
+		Expect(cost.Requests).To(Equal(int64(2)))
+		Expect(cost.BytesSent).To(Equal(int64(2 * len(requestBody))))
+		Expect(cost.BytesReceived).To(Equal(int64(2 * len(responseBody))))
+	})
+})
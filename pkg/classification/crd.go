@@ -0,0 +1,161 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"emperror.dev/errors"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+)
+
+var crdGVK = schema.GroupVersionKind{
+	Group:   apiextensionsv1.SchemeGroupVersion.Group,
+	Version: apiextensionsv1.SchemeGroupVersion.Version,
+	Kind:    "CustomResourceDefinition",
+}
+
+// areCRDsAMatch returns true if all CRDConstraints are satisfied
+func (m *manager) areCRDsAMatch(ctx context.Context, classifier *libsveltosv1alpha1.Classifier) (bool, error) {
+	extension, err := getClassifierExtension(classifier)
+	if err != nil {
+		return false, err
+	}
+
+	for i := range extension.CRDConstraints {
+		constraint := &extension.CRDConstraints[i]
+		if constraint.Name == "" {
+			return false, newInvalidClassifierError(errors.New("crd constraint name is required"))
+		}
+
+		crd, err := m.getCustomResourceDefinition(ctx, constraint.Name)
+		if err != nil {
+			return false, err
+		}
+
+		if !isCRDConstraintAMatch(constraint, crd) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// getCustomResourceDefinition returns the CustomResourceDefinition with given name.
+// Returns nil if CustomResourceDefinition does not exist.
+func (m *manager) getCustomResourceDefinition(ctx context.Context, name string,
+) (*apiextensionsv1.CustomResourceDefinition, error) {
+
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(crdGVK)
+	err := m.Client.Get(ctx, types.NamespacedName{Name: name}, u)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	err = runtime.DefaultUnstructuredConverter.FromUnstructured(u.UnstructuredContent(), crd)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to convert CustomResourceDefinition %s", name))
+	}
+
+	return crd, nil
+}
+
+// isCRDConstraintAMatch returns true if crd is established and, when
+// constraint requires it, serves constraint.ServedVersion.
+// A nil crd (not installed) never satisfies the constraint.
+func isCRDConstraintAMatch(constraint *CRDConstraint, crd *apiextensionsv1.CustomResourceDefinition) bool {
+	if crd == nil || !crd.DeletionTimestamp.IsZero() {
+		return false
+	}
+
+	established := false
+	for i := range crd.Status.Conditions {
+		condition := &crd.Status.Conditions[i]
+		if condition.Type == apiextensionsv1.Established {
+			established = condition.Status == apiextensionsv1.ConditionTrue
+			break
+		}
+	}
+	if !established {
+		return false
+	}
+
+	if constraint.ServedVersion == "" {
+		return true
+	}
+
+	for i := range crd.Spec.Versions {
+		if crd.Spec.Versions[i].Name == constraint.ServedVersion {
+			return crd.Spec.Versions[i].Served
+		}
+	}
+
+	return false
+}
+
+// usesCRDGroup returns true if Classifier has a CRDConstraint on a
+// CustomResourceDefinition of the given group
+func usesCRDGroup(classifier *libsveltosv1alpha1.Classifier, group string) bool {
+	extension, err := getClassifierExtension(classifier)
+	if err != nil {
+		return false
+	}
+
+	// CustomResourceDefinition name is <plural>.<group>
+	suffix := "." + group
+	for i := range extension.CRDConstraints {
+		if strings.HasSuffix(extension.CRDConstraints[i].Name, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// evaluateClassifiersUsingCRD is invoked when a CustomResourceDefinition changes.
+// All Classifiers with a CRDConstraint on a CustomResourceDefinition of the
+// same group are queued for evaluation.
+func (m *manager) evaluateClassifiersUsingCRD(ctx context.Context, gvk *schema.GroupVersionKind) {
+	classifiers, err := m.ListClassifiers(ctx)
+	if err != nil {
+		m.log.V(logs.LogInfo).Info(fmt.Sprintf("failed to list classifiers: %v", err))
+		return
+	}
+
+	for i := range classifiers.Items {
+		if usesCRDGroup(&classifiers.Items[i], gvk.Group) {
+			m.log.V(logs.LogDebug).Info(fmt.Sprintf("CustomResourceDefinition %s changed: queuing classifier %s",
+				gvk.GroupKind().String(), classifiers.Items[i].Name))
+			m.EvaluateClassifier(classifiers.Items[i].Name)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: custom resource definitions", func() {
+	var crd *apiextensionsv1.CustomResourceDefinition
+
+	BeforeEach(func() {
+		crd = &apiextensionsv1.CustomResourceDefinition{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "certificates.cert-manager.io",
+			},
+			Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+				Group: "cert-manager.io",
+				Versions: []apiextensionsv1.CustomResourceDefinitionVersion{
+					{Name: "v1alpha2", Served: false},
+					{Name: "v1", Served: true, Storage: true},
+				},
+			},
+			Status: apiextensionsv1.CustomResourceDefinitionStatus{
+				Conditions: []apiextensionsv1.CustomResourceDefinitionCondition{
+					{Type: apiextensionsv1.Established, Status: apiextensionsv1.ConditionTrue},
+				},
+			},
+		}
+	})
+
+	It("isCRDConstraintAMatch verifies CustomResourceDefinition is established and serves version", func() {
+		constraint := &classification.CRDConstraint{Name: crd.Name}
+		Expect(classification.IsCRDConstraintAMatch(constraint, crd)).To(BeTrue())
+		Expect(classification.IsCRDConstraintAMatch(constraint, nil)).To(BeFalse())
+
+		constraint.ServedVersion = "v1"
+		Expect(classification.IsCRDConstraintAMatch(constraint, crd)).To(BeTrue())
+
+		constraint.ServedVersion = "v1alpha2"
+		Expect(classification.IsCRDConstraintAMatch(constraint, crd)).To(BeFalse())
+
+		constraint.ServedVersion = "v2"
+		Expect(classification.IsCRDConstraintAMatch(constraint, crd)).To(BeFalse())
+
+		constraint.ServedVersion = ""
+		crd.Status.Conditions[0].Status = apiextensionsv1.ConditionFalse
+		Expect(classification.IsCRDConstraintAMatch(constraint, crd)).To(BeFalse())
+	})
+
+	It("usesCRDGroup returns true only for classifiers with constraints on CRDs of the group", func() {
+		classifier := getClassifierWithKubernetesConstraints(version25, libsveltosv1alpha1.ComparisonEqual)
+		classifier.Annotations = map[string]string{
+			classification.ClassifierExtensionAnnotation: `crdConstraints:
+- name: certificates.cert-manager.io
+  servedVersion: v1`,
+		}
+
+		Expect(classification.UsesCRDGroup(classifier, "cert-manager.io")).To(BeTrue())
+		Expect(classification.UsesCRDGroup(classifier, "manager.io")).To(BeFalse())
+		Expect(classification.UsesCRDGroup(classifier, "kyverno.io")).To(BeFalse())
+
+		Expect(classification.UsesCRDGroup(&libsveltosv1alpha1.Classifier{}, "cert-manager.io")).To(BeFalse())
+	})
+})
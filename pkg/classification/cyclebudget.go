@@ -0,0 +1,82 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+)
+
+var (
+	cycleDeadlineExceeded = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "cycle_deadline_exceeded_total",
+			Help:      "Number of evaluation cycles which did not evaluate all queued Classifiers within the cycle budget",
+		},
+	)
+
+	carriedOverClassifiers = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "carried_over_classifiers_total",
+			Help:      "Number of Classifier evaluations carried over to the next cycle because the cycle budget was exhausted",
+		},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(cycleDeadlineExceeded, carriedOverClassifiers)
+}
+
+// getCycleDeadline returns the time by which a cycle starting at start, with the given
+// interval, must be done evaluating Classifiers. Zero time when no cycle budget is set.
+func (m *manager) getCycleDeadline(start time.Time, interval time.Duration) time.Time {
+	if m.cycleBudget <= 0 {
+		return time.Time{}
+	}
+	return start.Add(time.Duration(float64(interval) * m.cycleBudget))
+}
+
+// isCycleDeadlineExceeded returns true if deadline is set and has passed
+func isCycleDeadlineExceeded(deadline, now time.Time) bool {
+	return !deadline.IsZero() && now.After(deadline)
+}
+
+// carryOverClassifiers queues classifierNames ahead of the Classifiers queued so far,
+// so that they are evaluated first in next cycle
+func (m *manager) carryOverClassifiers(classifierNames []string) {
+	if len(classifierNames) == 0 {
+		return
+	}
+
+	m.log.V(logs.LogInfo).Info(fmt.Sprintf("cycle budget exhausted. %d classifiers carried over to next cycle",
+		len(classifierNames)))
+	cycleDeadlineExceeded.Inc()
+	carriedOverClassifiers.Add(float64(len(classifierNames)))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	jobQueue := make([]string, 0, len(classifierNames)+len(m.jobQueue))
+	jobQueue = append(jobQueue, classifierNames...)
+	m.jobQueue = append(jobQueue, m.jobQueue...)
+}
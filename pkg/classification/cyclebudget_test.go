@@ -0,0 +1,58 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+)
+
+var _ = Describe("Manager: cycle budget", func() {
+	BeforeEach(func() {
+		classification.InitializeManagerWithObjects(nil)
+	})
+
+	It("getCycleDeadline is a fraction of the interval", func() {
+		manager := classification.GetManager()
+		start := time.Now()
+
+		deadline := classification.GetCycleDeadline(manager, start, time.Minute)
+		Expect(deadline.IsZero()).To(BeTrue())
+		Expect(classification.IsCycleDeadlineExceeded(deadline, start.Add(time.Hour))).To(BeFalse())
+
+		classification.ApplyOptions(classification.WithCycleBudget(0.5))
+		deadline = classification.GetCycleDeadline(manager, start, time.Minute)
+		Expect(deadline).To(Equal(start.Add(30 * time.Second)))
+		Expect(classification.IsCycleDeadlineExceeded(deadline, start.Add(29*time.Second))).To(BeFalse())
+		Expect(classification.IsCycleDeadlineExceeded(deadline, start.Add(31*time.Second))).To(BeTrue())
+	})
+
+	It("carryOverClassifiers queues classifiers ahead of the others", func() {
+		manager := classification.GetManager()
+
+		manager.EvaluateClassifier("queued")
+		classification.CarryOverClassifiers(manager, []string{"first", "second"})
+		Expect(classification.GetJobQueue()).To(Equal([]string{"first", "second", "queued"}))
+
+		classification.CarryOverClassifiers(manager, nil)
+		Expect(classification.GetJobQueue()).To(HaveLen(3))
+	})
+})
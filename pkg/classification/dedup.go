@@ -0,0 +1,123 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+// ClassifierReportDuplicatesAnnotation is set, when report deduplication is enabled,
+// on the ClassifierReports delivered to the management cluster of Classifiers whose
+// ClassifierLabels overlap with the ones of other Classifiers. Value is a JSON encoded
+// ClassifierDuplicates.
+const ClassifierReportDuplicatesAnnotation = "classifier.projectsveltos.io/duplicates"
+
+// ClassifierDuplicates lists the Classifiers whose ClassifierLabels overlap with the
+// ones of a Classifier
+type ClassifierDuplicates struct {
+	// Identical lists the Classifiers with the same ClassifierLabels, which are redundant
+	Identical []string `json:"identical,omitempty"`
+
+	// Conflicting lists the Classifiers setting at least one of the label keys to a
+	// different value
+	Conflicting []string `json:"conflicting,omitempty"`
+}
+
+// getClassifierDuplicates returns the Classifiers, among classifiers, whose
+// ClassifierLabels overlap with the ones of classifier. Nil if none does.
+func getClassifierDuplicates(classifier *libsveltosv1alpha1.Classifier,
+	classifiers []libsveltosv1alpha1.Classifier) *ClassifierDuplicates {
+
+	labels := getClassifierLabels(classifier)
+	if len(labels) == 0 {
+		return nil
+	}
+
+	duplicates := &ClassifierDuplicates{}
+	for i := range classifiers {
+		other := &classifiers[i]
+		if other.Name == classifier.Name {
+			continue
+		}
+		otherLabels := getClassifierLabels(other)
+		if isSameLabelSet(labels, otherLabels) {
+			duplicates.Identical = append(duplicates.Identical, other.Name)
+			continue
+		}
+		for key, value := range otherLabels {
+			if current, ok := labels[key]; ok && current != value {
+				duplicates.Conflicting = append(duplicates.Conflicting, other.Name)
+				break
+			}
+		}
+	}
+
+	if len(duplicates.Identical) == 0 && len(duplicates.Conflicting) == 0 {
+		return nil
+	}
+	sort.Strings(duplicates.Identical)
+	sort.Strings(duplicates.Conflicting)
+	return duplicates
+}
+
+// getClassifierLabels returns classifier ClassifierLabels as a map
+func getClassifierLabels(classifier *libsveltosv1alpha1.Classifier) map[string]string {
+	labels := make(map[string]string, len(classifier.Spec.ClassifierLabels))
+	for i := range classifier.Spec.ClassifierLabels {
+		labels[classifier.Spec.ClassifierLabels[i].Key] = classifier.Spec.ClassifierLabels[i].Value
+	}
+	return labels
+}
+
+// isSameLabelSet returns true if a and b contain the same labels
+func isSameLabelSet(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		if current, ok := b[key]; !ok || current != value {
+			return false
+		}
+	}
+	return true
+}
+
+// setReportDuplicates sets ClassifierReportDuplicatesAnnotation on report, the
+// ClassifierReport of classifier delivered to the management cluster
+func (m *manager) setReportDuplicates(ctx context.Context, report *libsveltosv1alpha1.ClassifierReport,
+	classifier *libsveltosv1alpha1.Classifier) error {
+
+	classifiers, err := m.ListClassifiers(ctx)
+	if err != nil {
+		return err
+	}
+
+	value := ""
+	if duplicates := getClassifierDuplicates(classifier, classifiers.Items); duplicates != nil {
+		data, err := json.Marshal(duplicates)
+		if err != nil {
+			return err
+		}
+		value = string(data)
+	}
+	setReportAnnotation(report, ClassifierReportDuplicatesAnnotation, value)
+	return nil
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: report deduplication", func() {
+	getClassifier := func(name string, labels ...libsveltosv1alpha1.ClassifierLabel) *libsveltosv1alpha1.Classifier {
+		return &libsveltosv1alpha1.Classifier{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       libsveltosv1alpha1.ClassifierSpec{ClassifierLabels: labels},
+		}
+	}
+
+	gold := libsveltosv1alpha1.ClassifierLabel{Key: "tier", Value: "gold"}
+	silver := libsveltosv1alpha1.ClassifierLabel{Key: "tier", Value: "silver"}
+	eu := libsveltosv1alpha1.ClassifierLabel{Key: "region", Value: "eu"}
+
+	It("getClassifierDuplicates finds identical and conflicting classifiers", func() {
+		classifier := getClassifier("gold-eu", gold, eu)
+		classifiers := []libsveltosv1alpha1.Classifier{
+			*classifier,
+			*getClassifier("eu-gold", eu, gold),
+			*getClassifier("silver", silver),
+			*getClassifier("eu", eu),
+		}
+
+		duplicates := classification.GetClassifierDuplicates(classifier, classifiers)
+		Expect(duplicates).ToNot(BeNil())
+		Expect(duplicates.Identical).To(Equal([]string{"eu-gold"}))
+		Expect(duplicates.Conflicting).To(Equal([]string{"silver"}))
+
+		Expect(classification.GetClassifierDuplicates(getClassifier("eu", eu),
+			[]libsveltosv1alpha1.Classifier{*getClassifier("silver", silver)})).To(BeNil())
+	})
+
+	It("setReportDuplicates sets and removes the duplicates annotation", func() {
+		classifier := getClassifier("gold", gold)
+		classification.InitializeManagerWithObjects(nil, classifier, getClassifier("gold-copy", gold))
+		manager := classification.GetManager()
+
+		report := &libsveltosv1alpha1.ClassifierReport{}
+		Expect(classification.SetReportDuplicates(manager, context.TODO(), report, classifier)).To(Succeed())
+		Expect(report.Annotations).To(HaveKeyWithValue(classification.ClassifierReportDuplicatesAnnotation,
+			`{"identical":["gold-copy"]}`))
+
+		Expect(classification.SetReportDuplicates(manager, context.TODO(), report,
+			getClassifier("gold", silver))).To(Succeed())
+		Expect(report.Annotations).To(HaveKeyWithValue(classification.ClassifierReportDuplicatesAnnotation,
+			`{"conflicting":["gold-copy"]}`))
+
+		Expect(classification.SetReportDuplicates(manager, context.TODO(), report,
+			getClassifier("gold", eu))).To(Succeed())
+		Expect(report.Annotations).ToNot(HaveKey(classification.ClassifierReportDuplicatesAnnotation))
+	})
+})
@@ -0,0 +1,191 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2/klogr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+// unreachableClient fails all patches, as a management cluster which cannot be reached
+type unreachableClient struct {
+	client.Client
+}
+
+func (c *unreachableClient) Patch(ctx context.Context, obj client.Object, patch client.Patch,
+	opts ...client.PatchOption) error {
+
+	return errors.New("connection refused")
+}
+
+// missingReportCRDClient behaves as a cluster where ClassifierReport CRD is not installed
+type missingReportCRDClient struct {
+	client.Client
+}
+
+func (c *missingReportCRDClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object,
+	opts ...client.GetOption) error {
+
+	if _, ok := obj.(*libsveltosv1alpha1.ClassifierReport); ok {
+		gk := schema.GroupKind{Group: libsveltosv1alpha1.GroupVersion.Group, Kind: "ClassifierReport"}
+		return &meta.NoKindMatchError{GroupKind: gk}
+	}
+	return c.Client.Get(ctx, key, obj, opts...)
+}
+
+var _ = Describe("Manager: ClassifierReport delivery", func() {
+	var scheme *runtime.Scheme
+	var classifier *libsveltosv1alpha1.Classifier
+	var managementCluster client.Client
+
+	const (
+		clusterNamespace = "fleet"
+		clusterName      = "production"
+		clusterType      = libsveltosv1alpha1.ClusterTypeCapi
+	)
+
+	BeforeEach(func() {
+		var err error
+		scheme, err = setupScheme()
+		Expect(err).ToNot(HaveOccurred())
+		classification.Reset()
+
+		classifier = getClassifierWithKubernetesConstraints(version25, libsveltosv1alpha1.ComparisonEqual)
+		// In-memory management cluster ClassifierReports are delivered to
+		managementCluster = fake.NewClientBuilder().WithScheme(scheme).Build()
+	})
+
+	getDeliveredReport := func() *libsveltosv1alpha1.ClassifierReport {
+		clusterTypeValue := clusterType
+		key := types.NamespacedName{
+			Namespace: clusterNamespace,
+			Name:      libsveltosv1alpha1.GetClassifierReportName(classifier.Name, clusterName, &clusterTypeValue),
+		}
+		classifierReport := &libsveltosv1alpha1.ClassifierReport{}
+		Expect(managementCluster.Get(context.TODO(), key, classifierReport)).To(Succeed())
+		return classifierReport
+	}
+
+	It("sendClassifierReport creates and then updates ClassifierReport in the management cluster", func() {
+		classification.InitializeManagerWithObjects(nil, classifier)
+		classification.SetManagementCluster(managementCluster, clusterNamespace, clusterName, clusterType)
+		manager := classification.GetManager()
+
+		Expect(classification.CreateClassifierReport(manager, context.TODO(), classifier, true, nil)).
+			To(Succeed())
+		Expect(classification.SendClassifierReport(manager, context.TODO(), classifier)).To(Succeed())
+
+		classifierReport := getDeliveredReport()
+		Expect(classifierReport.Spec.ClassifierName).To(Equal(classifier.Name))
+		Expect(classifierReport.Spec.ClusterNamespace).To(Equal(clusterNamespace))
+		Expect(classifierReport.Spec.ClusterName).To(Equal(clusterName))
+		Expect(classifierReport.Spec.ClusterType).To(Equal(clusterType))
+		Expect(classifierReport.Spec.Match).To(BeTrue())
+		Expect(classifierReport.Labels).To(HaveKeyWithValue(libsveltosv1alpha1.ClassifierLabelName, classifier.Name))
+		Expect(classifierReport.Labels).To(HaveKeyWithValue(classification.ReportShardLabel,
+			classification.DefaultShardHasher(clusterNamespace, clusterName, clusterType)))
+		Expect(classifierReport.Annotations).ToNot(HaveKey(classification.ClassifierReportErrorAnnotation))
+
+		// Evaluation outcome changes: delivered ClassifierReport is updated
+		invalidErr := errors.New("invalid classifier")
+		Expect(classification.CreateClassifierReport(manager, context.TODO(), classifier, false, invalidErr)).
+			To(Succeed())
+		Expect(classification.SendClassifierReport(manager, context.TODO(), classifier)).To(Succeed())
+
+		classifierReport = getDeliveredReport()
+		Expect(classifierReport.Spec.Match).To(BeFalse())
+		Expect(classifierReport.Annotations).To(HaveKeyWithValue(classification.ClassifierReportErrorAnnotation,
+			invalidErr.Error()))
+
+		// Annotations not set anymore are removed from delivered ClassifierReport
+		Expect(classification.CreateClassifierReport(manager, context.TODO(), classifier, false, nil)).
+			To(Succeed())
+		Expect(classification.SendClassifierReport(manager, context.TODO(), classifier)).To(Succeed())
+		Expect(getDeliveredReport().Annotations).ToNot(HaveKey(classification.ClassifierReportErrorAnnotation))
+
+		reports := &libsveltosv1alpha1.ClassifierReportList{}
+		Expect(managementCluster.List(context.TODO(), reports)).To(Succeed())
+		Expect(reports.Items).To(HaveLen(1))
+	})
+
+	It("sendClassifierReport returns an error when management cluster cannot be reached", func() {
+		classification.InitializeManagerWithObjects(nil, classifier)
+		classification.SetManagementCluster(&unreachableClient{Client: managementCluster},
+			clusterNamespace, clusterName, clusterType)
+		manager := classification.GetManager()
+
+		Expect(classification.CreateClassifierReport(manager, context.TODO(), classifier, true, nil)).
+			To(Succeed())
+		// Error is returned so that Classifier is queued for evaluation again
+		Expect(classification.SendClassifierReport(manager, context.TODO(), classifier)).ToNot(Succeed())
+		Expect(classification.RefreshAgentDegraded(manager, context.TODO())).ToNot(Succeed())
+
+		reports := &libsveltosv1alpha1.ClassifierReportList{}
+		Expect(managementCluster.List(context.TODO(), reports)).To(Succeed())
+		Expect(reports.Items).To(BeEmpty())
+	})
+
+	It("sendClassifierReport sends results kept in memory only when enabled", func() {
+		c := &missingReportCRDClient{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(classifier).Build()}
+		classification.InitializeManagerWithSkip(context.TODO(), klogr.New(), nil, c, nil, 10)
+		classification.SetManagementCluster(managementCluster, clusterNamespace, clusterName, clusterType)
+		manager := classification.GetManager()
+
+		classification.StorePendingReport(manager, classifier, true, nil)
+
+		reports := &libsveltosv1alpha1.ClassifierReportList{}
+		Expect(classification.SendClassifierReport(manager, context.TODO(), classifier)).To(Succeed())
+		Expect(managementCluster.List(context.TODO(), reports)).To(Succeed())
+		Expect(reports.Items).To(BeEmpty())
+
+		classification.ApplyOptions(classification.WithReportsWithoutCRD(true))
+		Expect(classification.SendClassifierReport(manager, context.TODO(), classifier)).To(Succeed())
+		Expect(getDeliveredReport().Spec.Match).To(BeTrue())
+	})
+
+	It("refreshAgentDegraded delivers Degraded condition to the management cluster", func() {
+		classification.InitializeManagerWithObjects(nil, classifier)
+		classification.SetManagementCluster(managementCluster, clusterNamespace, clusterName, clusterType)
+		manager := classification.GetManager()
+
+		Expect(classification.CreateClassifierReport(manager, context.TODO(), classifier, true, nil)).
+			To(Succeed())
+		for i := 0; i < 3; i++ {
+			classification.SetOverload(manager, 1, 1, 0, time.Now())
+		}
+		Expect(classification.RefreshAgentDegraded(manager, context.TODO())).To(Succeed())
+
+		classifierReport := getDeliveredReport()
+		Expect(classifierReport.Spec.Match).To(BeTrue())
+		Expect(classifierReport.Annotations).To(HaveKey(classification.ClassifierReportAgentDegradedAnnotation))
+	})
+})
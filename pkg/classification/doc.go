@@ -0,0 +1,36 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package classification evaluates Classifiers in the cluster classifier-agent runs in
+// and reports, with ClassifierReports, whether the cluster is a match.
+//
+// Projects embedding classifier-agent can rely on the following API, which follows
+// semantic versioning: no incompatible change is made to it within a major version.
+//   - Manager lifecycle: InitializeManager, GetManager, the Option type and all With* options,
+//     and the exported methods of the manager returned by GetManager.
+//   - Evaluation helpers: GetClassifierGVKs, EvaluateAll and the evaluation history
+//     (EvaluationResult, EvaluationHistoryHandler).
+//   - Report writers: ClassificationState, WriteClassificationState,
+//     ClassificationStateHandler, WaitForClassification, RunSelfTest and WriteSelfTestReport.
+//   - ClassifierExtension and all types it references, along with the annotations and
+//     labels classifier-agent reads and sets.
+//   - Extension points: RegisterActionFactory and the VersionProvider interface.
+//
+// Anything else exported by this package may change in any release. Helpers only
+// classifier-agent needs live under internal/ and cannot be imported by other modules.
+// The payload explaining evaluation outcomes is defined, and versioned, by package
+// explanation.
+package classification
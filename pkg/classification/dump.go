@@ -0,0 +1,208 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+)
+
+const (
+	// ClassificationStatePath is the path the classification state is served at
+	ClassificationStatePath = "/classification"
+
+	// ClassificationStateVersion is the version of the ClassificationState format.
+	// Fields are only ever added within a version.
+	ClassificationStateVersion = "v1"
+)
+
+// ClassificationStateFormat is the format ClassificationState is written in
+type ClassificationStateFormat string
+
+const (
+	// ClassificationStateJSON writes the ClassificationState as is
+	ClassificationStateJSON = ClassificationStateFormat("json")
+
+	// ClassificationStateFlat writes a single JSON object whose values are all
+	// strings, as expected by Terraform external data sources:
+	// "<classifier>" is "true" or "false" and "<classifier>.error", present
+	// only when evaluation failed, is the reason.
+	ClassificationStateFlat = ClassificationStateFormat("flat")
+)
+
+// ClassificationState is the current classification of the cluster
+type ClassificationState struct {
+	// Version of the format
+	Version string `json:"version"`
+
+	// ClusterNamespace, ClusterName and ClusterType identify the cluster
+	// in the management cluster
+	ClusterNamespace string `json:"clusterNamespace,omitempty"`
+	ClusterName      string `json:"clusterName,omitempty"`
+	ClusterType      string `json:"clusterType,omitempty"`
+
+	// GeneratedAt is the time state was generated
+	GeneratedAt time.Time `json:"generatedAt"`
+
+	// Classifiers contains one entry per evaluated Classifier, sorted by name
+	Classifiers []ClassifierState `json:"classifiers"`
+}
+
+// ClassifierState is the outcome of the most recent evaluation of a Classifier
+type ClassifierState struct {
+	// Name of the Classifier
+	Name string `json:"name"`
+
+	// Match indicates whether cluster is a match
+	Match bool `json:"match"`
+
+	// Error, if not empty, is the reason evaluation failed
+	Error string `json:"error,omitempty"`
+
+	// LastEvaluationTime is the time most recent evaluation started
+	LastEvaluationTime time.Time `json:"lastEvaluationTime"`
+}
+
+// IsValidClassificationStateFormat returns true if format is a known ClassificationStateFormat
+func IsValidClassificationStateFormat(format ClassificationStateFormat) bool {
+	return format == ClassificationStateJSON || format == ClassificationStateFlat
+}
+
+// GetClassificationState returns the current classification of the cluster
+func (m *manager) GetClassificationState() *ClassificationState {
+	summary := m.getEvaluationSummary()
+
+	state := &ClassificationState{
+		Version:          ClassificationStateVersion,
+		ClusterNamespace: m.clusterNamespace,
+		ClusterName:      m.clusterName,
+		ClusterType:      string(m.clusterType),
+		GeneratedAt:      summary.LastUpdateTime,
+		Classifiers:      make([]ClassifierState, len(summary.Classifiers)),
+	}
+
+	for i := range summary.Classifiers {
+		state.Classifiers[i] = ClassifierState{
+			Name:               summary.Classifiers[i].Name,
+			Match:              summary.Classifiers[i].Match,
+			Error:              summary.Classifiers[i].Error,
+			LastEvaluationTime: summary.Classifiers[i].Timestamp,
+		}
+	}
+
+	return state
+}
+
+// WriteClassificationState writes state to w in the given format
+func WriteClassificationState(w io.Writer, state *ClassificationState, format ClassificationStateFormat) error {
+	var body interface{} = state
+	switch format {
+	case ClassificationStateJSON:
+	case ClassificationStateFlat:
+		flat := make(map[string]string, len(state.Classifiers))
+		for i := range state.Classifiers {
+			flat[state.Classifiers[i].Name] = strconv.FormatBool(state.Classifiers[i].Match)
+			if state.Classifiers[i].Error != "" {
+				flat[state.Classifiers[i].Name+".error"] = state.Classifiers[i].Error
+			}
+		}
+		body = flat
+	default:
+		return fmt.Errorf("unknown format %s", format)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(body)
+}
+
+// ClassificationStateHandler returns an http.Handler serving the current classification.
+// Query parameter "format" selects the ClassificationStateFormat (json by default).
+func ClassificationStateHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m := GetManager()
+		if m == nil {
+			http.Error(w, "classification manager not initialized yet", http.StatusServiceUnavailable)
+			return
+		}
+
+		format := ClassificationStateFormat(r.URL.Query().Get("format"))
+		if format == "" {
+			format = ClassificationStateJSON
+		}
+		if !IsValidClassificationStateFormat(format) {
+			http.Error(w, fmt.Sprintf("unknown format %s", format), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := WriteClassificationState(w, m.GetClassificationState(), format); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// WaitForClassification waits till all existing Classifiers have been evaluated
+// at least once and then returns the current classification
+func WaitForClassification(ctx context.Context, pollInterval time.Duration) (*ClassificationState, error) {
+	for {
+		if m := GetManager(); m != nil {
+			evaluated, err := m.areAllClassifiersEvaluated(ctx)
+			if err != nil {
+				m.log.V(logs.LogDebug).Info(fmt.Sprintf("failed to verify classifiers evaluation: %v", err))
+			} else if evaluated {
+				return m.GetClassificationState(), nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// areAllClassifiersEvaluated returns true if there is an evaluation result
+// for every Classifier not being deleted
+func (m *manager) areAllClassifiersEvaluated(ctx context.Context) (bool, error) {
+	classifiers, err := m.ListClassifiers(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	m.historyMu.RLock()
+	defer m.historyMu.RUnlock()
+
+	for i := range classifiers.Items {
+		if !classifiers.Items[i].DeletionTimestamp.IsZero() {
+			continue
+		}
+		if _, ok := m.latest[classifiers.Items[i].Name]; !ok {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: classification state", func() {
+	BeforeEach(func() {
+		var err error
+		scheme, err = setupScheme()
+		Expect(err).ToNot(HaveOccurred())
+		classification.Reset()
+	})
+
+	It("ClassificationStateHandler serves classification in json and flat format", func() {
+		handler := classification.ClassificationStateHandler()
+
+		classification.InitializeManagerWithObjects(nil)
+		manager := classification.GetManager()
+
+		classifierName1 := randomString()
+		classifierName2 := randomString()
+		classification.RecordEvaluation(manager, classifierName1, time.Now(), true, nil, nil)
+		classification.RecordEvaluation(manager, classifierName2, time.Now(), false, fmt.Errorf("failed"), nil)
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, classification.ClassificationStatePath, nil))
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		state := &classification.ClassificationState{}
+		Expect(json.Unmarshal(recorder.Body.Bytes(), state)).To(Succeed())
+		Expect(state.Version).To(Equal(classification.ClassificationStateVersion))
+		Expect(len(state.Classifiers)).To(Equal(2))
+		for i := range state.Classifiers {
+			Expect(state.Classifiers[i].LastEvaluationTime.IsZero()).To(BeFalse())
+			if state.Classifiers[i].Name == classifierName1 {
+				Expect(state.Classifiers[i].Match).To(BeTrue())
+			} else {
+				Expect(state.Classifiers[i].Match).To(BeFalse())
+				Expect(state.Classifiers[i].Error).To(Equal("failed"))
+			}
+		}
+
+		recorder = httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet,
+			fmt.Sprintf("%s?format=%s", classification.ClassificationStatePath, classification.ClassificationStateFlat),
+			nil))
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		flat := map[string]string{}
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &flat)).To(Succeed())
+		Expect(flat).To(Equal(map[string]string{
+			classifierName1:            "true",
+			classifierName2:            "false",
+			classifierName2 + ".error": "failed",
+		}))
+
+		recorder = httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet,
+			fmt.Sprintf("%s?format=yaml", classification.ClassificationStatePath), nil))
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("WaitForClassification returns once all classifiers are evaluated", func() {
+		classifier := getClassifierWithKubernetesConstraints(version25, libsveltosv1alpha1.ComparisonEqual)
+		classification.InitializeManagerWithObjects(nil, classifier)
+		manager := classification.GetManager()
+
+		ctx, cancel := context.WithTimeout(context.TODO(), 100*time.Millisecond)
+		defer cancel()
+		_, err := classification.WaitForClassification(ctx, 10*time.Millisecond)
+		Expect(err).ToNot(BeNil())
+
+		classification.RecordEvaluation(manager, classifier.Name, time.Now(), true, nil, nil)
+		state, err := classification.WaitForClassification(context.TODO(), 10*time.Millisecond)
+		Expect(err).To(BeNil())
+		Expect(len(state.Classifiers)).To(Equal(1))
+		Expect(state.Classifiers[0].Name).To(Equal(classifier.Name))
+	})
+})
@@ -0,0 +1,59 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"fmt"
+)
+
+// ElementMatchPolicy defines, when a field path resolves to multiple values
+// (for instance "spec.containers[*].image"), which of those must satisfy
+// a FieldFilter
+type ElementMatchPolicy string
+
+const (
+	// ElementMatchAny requires at least one value to satisfy the filter
+	ElementMatchAny = ElementMatchPolicy("Any")
+
+	// ElementMatchAll requires every value to satisfy the filter.
+	// A field resolving to no value satisfies the filter.
+	ElementMatchAll = ElementMatchPolicy("All")
+)
+
+// applyElementMatchPolicies sets, on field filter matchers, the element match
+// policies configured per field path
+func applyElementMatchPolicies(matchers []fieldFilterMatcher, policies map[string]ElementMatchPolicy) error {
+	for field, policy := range policies {
+		switch policy {
+		case ElementMatchAny, ElementMatchAll:
+		default:
+			return fmt.Errorf("field %q: unknown element match policy %q", field, policy)
+		}
+
+		found := false
+		for i := range matchers {
+			if matchers[i].filter.Field == field {
+				matchers[i].elements = policy
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("element match policy set for field %q which has no field filter", field)
+		}
+	}
+	return nil
+}
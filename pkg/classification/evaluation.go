@@ -20,33 +20,53 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"regexp"
 	"time"
 
 	"emperror.dev/errors"
-	"github.com/Masterminds/semver"
 	"github.com/go-logr/logr"
+	"github.com/google/cel-go/cel"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/client-go/discovery"
-	"k8s.io/client-go/dynamic"
-	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
-	"github.com/projectsveltos/classifier-agent/pkg/utils"
+	"github.com/projectsveltos/classifier-agent/internal/utils"
+	"github.com/projectsveltos/classifier-agent/pkg/explanation"
 	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
 	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
 )
 
 // evaluateClassifiers evaluates all classifiers awaiting evaluation
 func (m *manager) evaluateClassifiers(ctx context.Context) {
+	interval := m.getInitialInterval()
+	lastCycle := time.Now()
 	for {
+		if !m.holdsHandshake() {
+			// Another classifier-agent instance evaluates Classifiers. Queue is kept
+			// till this instance gets the handshake Lease.
+			m.log.V(logs.LogDebug).Info("handshake lease not held. Not evaluating Classifiers")
+			time.Sleep(interval)
+			continue
+		}
+		if !m.isClusterHealthy(ctx) {
+			// Evaluating Classifiers during a control plane blip would produce non matching
+			// verdicts. Queue and ClassifierReports are kept till the cluster is healthy.
+			time.Sleep(interval)
+			continue
+		}
+		// Queued Classifiers are evaluated a last time before handshake Lease is handed over
+		handingOver := m.handshake != nil && m.handshake.getState() == handshakeHandingOver
+
 		m.log.V(logs.LogDebug).Info("Evaluating Classifiers")
+		m.refreshClockSkew(ctx, time.Now())
 		m.mu.Lock()
 		// Copy queue content. That is only operation that
 		// needs to be done in a mutex protect section
@@ -57,10 +77,29 @@ func (m *manager) evaluateClassifiers(ctx context.Context) {
 		m.mu.Unlock()
 
 		failedEvaluations := make([]string, 0)
+		deferredEvaluations := make([]string, 0)
+		carriedOverEvaluations := make([]string, 0)
+		// Classifiers evaluated in this cycle share the resources matched by identical constraints
+		cycleCtx := m.withMatchCache(ctx)
+		deadline := m.getCycleDeadline(time.Now(), interval)
 
 		for i := range jobQueueCopy {
+			if isCycleDeadlineExceeded(deadline, time.Now()) {
+				// Remaining Classifiers are evaluated first in next cycle
+				carriedOverEvaluations = append(carriedOverEvaluations, jobQueueCopy[i:]...)
+				break
+			}
+			if m.isDeferred(jobQueueCopy[i], time.Now()) {
+				deferredEvaluations = append(deferredEvaluations, jobQueueCopy[i])
+				continue
+			}
 			m.log.V(logs.LogDebug).Info(fmt.Sprintf("Evaluating Classifier %s", jobQueueCopy[i]))
-			err := m.evaluateClassifierInstance(ctx, jobQueueCopy[i])
+			err := m.evaluateClassifierInstanceSerialized(cycleCtx, jobQueueCopy[i])
+			if errors.Is(err, errHandshakeNotHeld) {
+				// Handshake Lease was lost. Remaining Classifiers are kept queued.
+				deferredEvaluations = append(deferredEvaluations, jobQueueCopy[i:]...)
+				break
+			}
 			if err != nil {
 				m.log.V(logs.LogInfo).Error(err,
 					fmt.Sprintf("failed to evaluate classifier %s", jobQueueCopy[i]))
@@ -74,8 +113,45 @@ func (m *manager) evaluateClassifiers(ctx context.Context) {
 			m.EvaluateClassifier(failedEvaluations[i])
 		}
 
+		// Classifiers in the slow lane are kept queued till their evaluation is due
+		for i := range deferredEvaluations {
+			m.EvaluateClassifier(deferredEvaluations[i])
+		}
+
+		m.carryOverClassifiers(carriedOverEvaluations)
+
+		evaluated := len(jobQueueCopy) - len(deferredEvaluations) - len(carriedOverEvaluations)
+		m.updateOverload(ctx, evaluated, len(failedEvaluations))
+
+		if m.evaluationSummary && evaluated > 0 {
+			if err := m.updateEvaluationSummary(ctx); err != nil {
+				m.log.V(logs.LogInfo).Info(fmt.Sprintf("failed to update evaluation summary: %v", err))
+			}
+		}
+
+		if m.stateFile != "" && evaluated > 0 {
+			if err := m.writeStateFile(); err != nil {
+				m.log.V(logs.LogInfo).Info(fmt.Sprintf("failed to write classification state file: %v", err))
+			}
+		}
+
+		if m.nodeLabelPrefix != "" && evaluated > 0 {
+			if err := m.syncAllNodeLabels(ctx); err != nil {
+				m.log.V(logs.LogInfo).Info(fmt.Sprintf("failed to update node labels: %v", err))
+			}
+		}
+
+		if handingOver {
+			if err := m.releaseHandshake(ctx); err != nil {
+				m.log.V(logs.LogInfo).Info(fmt.Sprintf("failed to hand handshake lease over: %v", err))
+			}
+		}
+
 		// Sleep before next evaluation
-		time.Sleep(m.interval)
+		now := time.Now()
+		interval = m.getNextInterval(interval, now.Sub(lastCycle))
+		lastCycle = now
+		time.Sleep(interval)
 	}
 }
 
@@ -86,36 +162,88 @@ func (m *manager) evaluateClassifierInstance(ctx context.Context, classifierName
 	classifier := &libsveltosv1alpha1.Classifier{}
 
 	logger := m.log.WithValues("classifier", classifierName)
-	err := m.Client.Get(ctx, types.NamespacedName{Name: classifierName}, classifier)
+	err := m.getClassifier(ctx, classifierName, classifier)
 	if err != nil {
 		if apierrors.IsNotFound(err) {
+			m.forgetClassifier(classifierName)
 			return m.cleanClassifierReport(ctx, classifierName)
 		}
 		return err
 	}
 
 	if !classifier.DeletionTimestamp.IsZero() {
+		// Remove any label/annotation added by this Classifier actions
+		err = m.UndoActions(ctx, classifier)
+		if err != nil {
+			logger.Error(err, "failed to undo actions")
+			return err
+		}
+		m.forgetClassifier(classifierName)
 		return m.cleanClassifierReport(ctx, classifierName)
 	}
 
-	match, err := m.isVersionAMatch(ctx, classifier)
-	if err != nil {
-		logger.Error(err, "failed to validate if Kubernetes version is a match")
-		return err
+	hookCtx := m.runPreEvaluationHooks(ctx, classifier)
+	tracker := &costTracker{access: newAccessRecorder()}
+	evaluationCtx, cancel, budget := withEvaluationBudget(hookCtx, classifier, tracker)
+	defer cancel()
+	cpuStart := getProcessCPUTime()
+	start := time.Now()
+	match, evaluationErr := m.isClassifierAMatch(withCostTracker(evaluationCtx, tracker), classifier, logger)
+	cost := tracker.getCost(getProcessCPUTime() - cpuStart)
+	if budgetErr := checkEvaluationBudget(evaluationCtx, tracker, budget); budgetErr != nil {
+		evaluationErr = budgetErr
 	}
-
-	if match {
-		match, err = m.areResourcesAMatch(ctx, classifier)
+	m.recordEvaluation(classifierName, start, match, evaluationErr, cost)
+	m.runPostEvaluationHooks(hookCtx, classifier, match, evaluationErr, time.Since(start))
+	m.recordEffectiveRBAC(classifierName, tracker.access.list())
+	recordCostMetrics(classifierName, cost)
+	if evaluationErr == nil && m.isConformanceSampled() {
+		// Divergences are reported by verifyConformance. Failing to verify
+		// does not affect the evaluation outcome.
+		if _, err := m.verifyConformance(ctx, classifier); err != nil {
+			logger.V(logs.LogInfo).Info(fmt.Sprintf("failed to verify conformance: %v", err))
+		}
+	}
+	if isBudgetExceededError(evaluationErr) {
+		// Keep this Classifier from impacting the others: report it as degraded
+		// and evaluate it again only once slow lane interval has elapsed
+		m.deferClassifier(classifierName, time.Now())
+		err = m.markClassifierReportDegraded(ctx, classifier, evaluationErr)
 		if err != nil {
-			logger.Error(err, "failed to validate if current cluster resources are a match")
 			return err
 		}
+		if m.sendReport {
+			return m.sendClassifierReport(ctx, classifier)
+		}
+		return nil
+	}
+	if evaluationErr != nil {
+		if !isInvalidClassifierError(evaluationErr) {
+			return evaluationErr
+		}
+		// Evaluating again won't help till Classifier is changed.
+		// Report cluster as not a match along with the reason.
+		logger.V(logs.LogInfo).Info(fmt.Sprintf("invalid classifier: %v", evaluationErr))
+		match = false
 	}
 
-	err = m.createClassifierReport(ctx, classifier, match)
+	err = m.createClassifierReport(ctx, classifier, match, evaluationErr)
 	if err != nil {
-		logger.Error(err, "failed to create/update ClassifierReport")
-		return err
+		if !isReportCRDMissing(err) {
+			logger.Error(err, "failed to create/update ClassifierReport")
+			return err
+		}
+		// Result is kept in memory and persisted once ClassifierReport CRD is installed
+		logger.V(logs.LogInfo).Info("ClassifierReport CRD is not installed. Keeping result in memory")
+		m.storePendingReport(classifier, match, evaluationErr)
+	} else {
+		m.removePendingReport(classifierName)
+	}
+
+	// Failing actions must not block ClassifierReport delivery. Actions are run
+	// again the next time Classifier is evaluated.
+	if err := m.processActions(ctx, classifier, match); err != nil {
+		logger.Error(err, "failed to process actions")
 	}
 
 	if m.sendReport {
@@ -129,12 +257,104 @@ func (m *manager) evaluateClassifierInstance(ctx context.Context, classifierName
 	return nil
 }
 
+// forgetClassifier removes everything kept in memory about a deleted Classifier
+func (m *manager) forgetClassifier(classifierName string) {
+	m.removeCELPrograms(classifierName)
+	m.removeEvaluationHistory(classifierName)
+	removeCostMetrics(classifierName)
+	removeConformanceMetrics(classifierName)
+	m.removeEffectiveRBAC(classifierName)
+	m.removeDeferred(classifierName)
+	m.removeAgeEvaluation(classifierName)
+	m.removeExplanation(classifierName)
+	m.removeActionMatch(classifierName)
+	m.removeHookAnnotations(classifierName)
+	m.trends.remove(classifierName)
+}
+
+// matchStage is one step of the Classifier evaluation
+type matchStage struct {
+	check explanation.CheckType
+	// subject is used in log messages and explanation reasons
+	subject  string
+	isAMatch func(ctx context.Context, classifier *libsveltosv1alpha1.Classifier) (bool, error)
+}
+
+// getMatchStages returns the Classifier evaluation steps, in the order
+// they are run
+func (m *manager) getMatchStages() []matchStage {
+	return []matchStage{
+		{check: explanation.CheckKubernetesVersion, subject: "Kubernetes version is", isAMatch: m.isVersionAMatch},
+		{check: explanation.CheckCloudProvider, subject: "cluster cloud provider is", isAMatch: m.isCloudProviderAMatch},
+		{check: explanation.CheckContainerRuntime, subject: "node container runtimes are",
+			isAMatch: m.isContainerRuntimeAMatch},
+		{check: explanation.CheckCNI, subject: "cluster CNI is", isAMatch: m.isCNIAMatch},
+		{check: explanation.CheckServiceMesh, subject: "cluster service mesh is", isAMatch: m.isServiceMeshAMatch},
+		{check: explanation.CheckFacts, subject: "cluster facts are", isAMatch: m.areFactsAMatch},
+		{check: explanation.CheckHelmRelease, subject: "deployed helm releases are", isAMatch: m.areHelmReleasesAMatch},
+		{check: explanation.CheckOLMOperator, subject: "installed olm operators are", isAMatch: m.areOLMOperatorsAMatch},
+		{check: explanation.CheckStorageClass, subject: "default storage class is",
+			isAMatch: m.isDefaultStorageClassAMatch},
+		{check: explanation.CheckIngressClass, subject: "ingress classes are", isAMatch: m.areIngressClassesAMatch},
+		{check: explanation.CheckConfigData, subject: "configMap and secret data is",
+			isAMatch: m.areConfigDataConstraintsAMatch},
+		{check: explanation.CheckPodSecurity, subject: "pod security levels are",
+			isAMatch: m.arePodSecurityConstraintsAMatch},
+		{check: explanation.CheckAPIResource, subject: "served api resources are", isAMatch: m.areAPIResourcesAMatch},
+		{check: explanation.CheckAPIService, subject: "aggregated api services are", isAMatch: m.areAPIServicesAMatch},
+		{check: explanation.CheckAPIServerFeature, subject: "api server features are",
+			isAMatch: m.areAPIServerFeaturesAMatch},
+		{check: explanation.CheckAPIServerFlags, subject: "api server flags are", isAMatch: m.areAPIServerFlagsAMatch},
+		{check: explanation.CheckCRD, subject: "custom resource definitions are", isAMatch: m.areCRDsAMatch},
+		{check: explanation.CheckWebhook, subject: "admission webhooks are", isAMatch: m.areWebhooksAMatch},
+		{check: explanation.CheckCertificateExpiration, subject: "certificate expirations are",
+			isAMatch: m.areCertificateExpirationsAMatch},
+		{check: explanation.CheckDeployedResource, subject: "current cluster resources are", isAMatch: m.areResourcesAMatch},
+		{check: explanation.CheckEvents, subject: "recent events are", isAMatch: m.areEventConstraintsAMatch},
+		{check: explanation.CheckUtilization, subject: "resource utilization is",
+			isAMatch: m.areUtilizationConstraintsAMatch},
+		{check: explanation.CheckPrometheus, subject: "prometheus queries are",
+			isAMatch: m.arePrometheusConstraintsAMatch},
+		{check: explanation.CheckMetrics, subject: "provider metrics are", isAMatch: m.areMetricConstraintsAMatch},
+	}
+}
+
+// isClassifierAMatch returns true if current cluster is a match for Classifier.
+// Outcome of each evaluation step is recorded and later added to ClassifierReport.
+func (m *manager) isClassifierAMatch(ctx context.Context, classifier *libsveltosv1alpha1.Classifier,
+	logger logr.Logger) (bool, error) {
+
+	e := &explanation.Explanation{Match: true}
+	defer m.recordExplanation(classifier.Name, e)
+
+	for _, stage := range m.getMatchStages() {
+		stageCtx, notes := withEvaluationNotes(ctx)
+		match, err := stage.isAMatch(stageCtx, classifier)
+		if err != nil {
+			logger.Error(err, fmt.Sprintf("failed to validate if %s a match", stage.subject))
+			e.Match = false
+			e.Checks = append(e.Checks,
+				explanation.Check{Type: stage.check, Reason: err.Error(), Notes: notes.get()})
+			return false, err
+		}
+		if !match {
+			e.Match = false
+			e.Checks = append(e.Checks, explanation.Check{Type: stage.check,
+				Reason: fmt.Sprintf("%s not a match", stage.subject), Notes: notes.get()})
+			return false, nil
+		}
+		e.Checks = append(e.Checks, explanation.Check{Type: stage.check, Satisfied: true, Notes: notes.get()})
+	}
+
+	return true, nil
+}
+
 // isVersionAMatch returns true if current cluster kubernetes version
 // is currently a match for Classif
 func (m *manager) isVersionAMatch(ctx context.Context,
 	classifier *libsveltosv1alpha1.Classifier) (bool, error) {
 
-	currentVersion, err := utils.GetKubernetesVersion(ctx, m.config, m.log)
+	currentVersion, err := m.getKubernetesVersion(ctx)
 	if err != nil {
 		m.log.Error(err, "failed to get cluster kubernetes version")
 		return false, err
@@ -142,38 +362,65 @@ func (m *manager) isVersionAMatch(ctx context.Context,
 
 	m.log.V(logs.LogDebug).Info(fmt.Sprintf("cluster version %s", currentVersion))
 
-	currentSemVersion, err := semver.NewVersion(currentVersion)
+	currentSemVersion, err := getClusterVersion(currentVersion, m.versionMatching)
 	if err != nil {
 		m.log.Error(err, "failed to get semver for current version %s", currentVersion)
 		return false, err
 	}
 
+	m.log.V(logs.LogDebug).Info(fmt.Sprintf("cluster version used for matching %s (mode %s)",
+		currentSemVersion, m.versionMatching))
+
 	for i := range classifier.Spec.KubernetesVersionConstraints {
-		kubernetesVersionConstraint := &classifier.Spec.KubernetesVersionConstraints[i]
-
-		var c *semver.Constraints
-		switch kubernetesVersionConstraint.Comparison {
-		case string(libsveltosv1alpha1.ComparisonEqual):
-			c, err = semver.NewConstraint(fmt.Sprintf("= %s", kubernetesVersionConstraint.Version))
-		case string(libsveltosv1alpha1.ComparisonNotEqual):
-			c, err = semver.NewConstraint(fmt.Sprintf("!= %s", kubernetesVersionConstraint.Version))
-		case string(libsveltosv1alpha1.ComparisonGreaterThan):
-			c, err = semver.NewConstraint(fmt.Sprintf("> %s", kubernetesVersionConstraint.Version))
-		case string(libsveltosv1alpha1.ComparisonGreaterThanOrEqualTo):
-			c, err = semver.NewConstraint(fmt.Sprintf(">= %s", kubernetesVersionConstraint.Version))
-		case string(libsveltosv1alpha1.ComparisonLessThan):
-			c, err = semver.NewConstraint(fmt.Sprintf("< %s", kubernetesVersionConstraint.Version))
-		case string(libsveltosv1alpha1.ComparisonLessThanOrEqualTo):
-			c, err = semver.NewConstraint(fmt.Sprintf("<= %s", kubernetesVersionConstraint.Version))
+		c, err := getComparisonConstraint(&classifier.Spec.KubernetesVersionConstraints[i])
+		if err != nil {
+			m.log.Error(err, "failed to build constraints")
+			return false, err
+		}
+
+		if !c.Check(currentSemVersion) {
+			return false, nil
 		}
+	}
+
+	extension, err := getClassifierExtension(classifier)
+	if err != nil {
+		return false, err
+	}
+
+	for i := range extension.KubernetesVersionConstraints {
+		c, err := getVersionConstraint(&extension.KubernetesVersionConstraints[i])
 		if err != nil {
 			m.log.Error(err, "failed to build constraints")
+			return false, err
 		}
 
 		if !c.Check(currentSemVersion) {
 			return false, nil
 		}
 	}
+
+	// Groups are ORed: at least one must be satisfied
+	isMatch, err := isVersionGroupAMatch(currentSemVersion, extension.KubernetesVersionConstraintGroups)
+	if err != nil {
+		m.log.Error(err, "failed to build constraints")
+		return false, err
+	}
+	if !isMatch {
+		return false, nil
+	}
+
+	if extension.KubeletVersionSkew != nil {
+		isMatch, err = m.isKubeletVersionSkewAMatch(ctx, currentSemVersion, extension.KubeletVersionSkew)
+		if err != nil {
+			m.log.Error(err, "failed to evaluate kubelet version skew")
+			return false, err
+		}
+		if !isMatch {
+			return false, nil
+		}
+	}
+
 	// Check if the version meets the constraints. The a variable will be true.
 	return true, nil
 }
@@ -181,112 +428,673 @@ func (m *manager) isVersionAMatch(ctx context.Context,
 func (m *manager) areResourcesAMatch(ctx context.Context,
 	classifier *libsveltosv1alpha1.Classifier) (bool, error) {
 
-	for i := range classifier.Spec.DeployedResourceConstraints {
-		r := &classifier.Spec.DeployedResourceConstraints[i]
-		isMatch, err := m.isResourceAMatch(ctx, r)
+	extension, err := getClassifierExtension(classifier)
+	if err != nil {
+		return false, err
+	}
+
+	if !m.consistentSnapshot {
+		return m.evaluateResourceConstraints(ctx, classifier, extension, nil)
+	}
+
+	for attempt := 1; ; attempt++ {
+		isMatch, err := m.evaluateResourceConstraints(ctx, classifier, extension, &evaluationSnapshot{})
+		if err != nil && isSnapshotExpired(err) && attempt < snapshotAttempts {
+			m.log.V(logs.LogDebug).Info(fmt.Sprintf("snapshot for classifier %s expired. Retrying",
+				classifier.Name))
+			continue
+		}
+		return isMatch, err
+	}
+}
+
+// evaluateResourceConstraints returns true if all resource constraints, or their
+// combination by DeployedResourceConstraintsExpression, ratio constraints, node
+// constraints, host resource constraints and the Lua script, if any, are satisfied.
+// When snapshot is not nil, all resources are listed at the same resourceVersion.
+func (m *manager) evaluateResourceConstraints(ctx context.Context, classifier *libsveltosv1alpha1.Classifier,
+	extension *ClassifierExtension, snapshot *evaluationSnapshot) (bool, error) {
+
+	// Resources are collected only if they need to be passed to the Lua script
+	var resources []unstructured.Unstructured
+
+	constraints := getResourceConstraints(classifier, extension)
+	results := make(map[int]bool, len(constraints))
+	evaluator := func(i int) (bool, error) {
+		if isMatch, ok := results[i]; ok {
+			return isMatch, nil
+		}
+		isMatch, items, err := m.evaluateResourceConstraintItems(ctx, classifier, &constraints[i], snapshot,
+			extension.LuaScript != "")
 		if err != nil {
 			return false, err
 		}
-		if !isMatch {
-			return false, nil
+		results[i] = isMatch
+		resources = append(resources, items...)
+		return isMatch, nil
+	}
+
+	if extension.DeployedResourceConstraintsExpression != "" {
+		expression, err := parseConstraintExpression(extension.DeployedResourceConstraintsExpression,
+			len(constraints))
+		if err != nil {
+			return false, newInvalidClassifierError(err)
 		}
+		isMatch, err := expression.evaluate(evaluator)
+		if err != nil || !isMatch {
+			return false, err
+		}
+	} else {
+		for i := range constraints {
+			isMatch, err := evaluator(i)
+			if err != nil || !isMatch {
+				return false, err
+			}
+		}
+	}
+
+	isMatch, err := m.areRatioConstraintsAMatch(ctx, classifier, extension, snapshot)
+	if err != nil || !isMatch {
+		return false, err
+	}
+
+	isMatch, err = m.areNodeConstraintsAMatch(ctx, classifier, extension, snapshot)
+	if err != nil || !isMatch {
+		return false, err
+	}
+
+	isMatch, err = m.areCapacityConstraintsAMatch(ctx, classifier, extension, snapshot)
+	if err != nil || !isMatch {
+		return false, err
+	}
+
+	isMatch, err = m.areHostResourceConstraintsAMatch(ctx, classifier, extension, snapshot)
+	if err != nil || !isMatch {
+		return false, err
+	}
+
+	if extension.LuaScript != "" {
+		return m.runLuaScript(ctx, extension.LuaScript, resources)
 	}
+
 	return true, nil
 }
 
+// evaluateResourceConstraintItems evaluates constraint. When collect is set, the
+// resources matching constraint are listed and returned.
+func (m *manager) evaluateResourceConstraintItems(ctx context.Context,
+	classifier *libsveltosv1alpha1.Classifier, constraint *ResourceConstraint, snapshot *evaluationSnapshot,
+	collect bool) (bool, []unstructured.Unstructured, error) {
+
+	if !collect {
+		isMatch, err := m.evaluateResourceConstraint(ctx, classifier, constraint, snapshot)
+		return isMatch, nil, err
+	}
+
+	if err := validateMustNotExist(constraint); err != nil {
+		return false, nil, newInvalidClassifierError(err)
+	}
+	if !constraint.MustNotExist && constraint.Trend != nil {
+		if err := validateTrendConstraint(constraint.Trend, m.trends.retention); err != nil {
+			return false, nil, err
+		}
+	}
+
+	items, total, served, err := m.listMatchingResources(ctx, classifier, constraint, snapshot)
+	if err != nil {
+		return false, nil, err
+	}
+	if constraint.MustNotExist {
+		return !served || len(items) == 0, items, nil
+	}
+	if !served {
+		return false, nil, nil
+	}
+	if constraint.Trend != nil {
+		isMatch, err := m.isTrendCountAMatch(ctx, classifier, constraint, len(items))
+		return isMatch, items, err
+	}
+	if constraint.Cardinality != nil {
+		isMatch, err := isCardinalityAMatch(ctx, constraint, items)
+		return isMatch, items, err
+	}
+	if constraint.Percentage != nil {
+		if err := validatePercentageConstraint(constraint.Percentage); err != nil {
+			return false, nil, err
+		}
+		return isPercentageAMatch(constraint.Percentage, len(items), total), items, nil
+	}
+	return isCountAMatch(&constraint.DeployedResourceConstraint, len(items)), items, nil
+}
+
 func (m *manager) isResourceAMatch(ctx context.Context,
 	deployedResource *libsveltosv1alpha1.DeployedResourceConstraint) (bool, error) {
 
+	return m.isResourceConstraintAMatch(ctx, nil,
+		&ResourceConstraint{DeployedResourceConstraint: *deployedResource})
+}
+
+// isResourceConstraintAMatch returns true if the number of resources matching
+// the constraint is within MinCount and MaxCount or, for percentage constraints,
+// if their percentage is within bounds.
+// classifier is only used when constraint has a CEL Expression.
+func (m *manager) isResourceConstraintAMatch(ctx context.Context, classifier *libsveltosv1alpha1.Classifier,
+	constraint *ResourceConstraint) (bool, error) {
+
+	return m.evaluateResourceConstraint(ctx, classifier, constraint, nil)
+}
+
+// evaluateResourceConstraint counts resources matching constraint and verifies
+// whether the count, or the percentage, satisfies the constraint.
+// Listing stops as soon as the outcome is known.
+func (m *manager) evaluateResourceConstraint(ctx context.Context, classifier *libsveltosv1alpha1.Classifier,
+	constraint *ResourceConstraint, snapshot *evaluationSnapshot) (bool, error) {
+
+	if constraint.MustNotExist {
+		return m.evaluateMustNotExistConstraint(ctx, classifier, constraint, snapshot)
+	}
+
+	if constraint.Trend != nil {
+		return m.evaluateTrendConstraint(ctx, classifier, constraint, snapshot)
+	}
+
+	if constraint.Cardinality != nil {
+		return m.evaluateCardinalityConstraint(ctx, classifier, constraint, snapshot)
+	}
+
+	if constraint.Percentage != nil {
+		if err := validatePercentageConstraint(constraint.Percentage); err != nil {
+			return false, err
+		}
+
+		count, total, served, err := m.countResources(ctx, classifier, constraint, snapshot,
+			getPercentageStopCondition(constraint.Percentage))
+		if err != nil || !served {
+			return false, err
+		}
+		return isPercentageAMatch(constraint.Percentage, count, total), nil
+	}
+
+	// Constraints are memoized only when not pinned to a Classifier snapshot
+	if cache := getMatchCache(ctx); cache != nil && snapshot == nil {
+		count, served, memoized, err := m.countMemoizedResources(ctx, cache, classifier, constraint)
+		if memoized {
+			if err != nil || !served {
+				return false, err
+			}
+			return isCountAMatch(&constraint.DeployedResourceConstraint, count), nil
+		}
+	}
+
+	count, served, err := m.countMatchingResources(ctx, classifier, constraint, snapshot)
+	if err != nil || !served {
+		return false, err
+	}
+
+	return isCountAMatch(&constraint.DeployedResourceConstraint, count), nil
+}
+
+// isCountAMatch returns true if count is within MinCount and MaxCount
+func isCountAMatch(deployedResource *libsveltosv1alpha1.DeployedResourceConstraint, count int) bool {
+	if deployedResource.MinCount != nil {
+		if count < *deployedResource.MinCount {
+			return false
+		}
+	}
+
+	if deployedResource.MaxCount != nil {
+		if count > *deployedResource.MaxCount {
+			return false
+		}
+	}
+
+	return true
+}
+
+// resourceQuery contains what is needed to list and filter resources
+// for a constraint
+type resourceQuery struct {
+	gvk      schema.GroupVersionKind
+	resource string
+	options  metav1.ListOptions
+	// labelMatchers evaluate label filters the API server cannot evaluate
+	labelMatchers      []labelFilterMatcher
+	annotationMatchers []annotationFilterMatcher
+	ownerFilters       []OwnerFilter
+	conditionFilters   []ConditionFilter
+	restartCount       *RestartCountFilter
+	taintFilters       []TaintFilter
+	// reconciledOnly, when set, selects only resources whose observedGeneration
+	// equals their generation
+	reconciledOnly bool
+	// names, when set, are the only resources to get in namespace
+	names         []string
+	namespace     string
+	fieldMatchers []fieldFilterMatcher
+	prg           cel.Program
+	expression    string
+	// skipped is the number of resources filtered out because of
+	// MissingFieldSkip policies
+	skipped int
+	// mustNotExist is set for MustNotExist constraints. The first matching
+	// resources are then recorded in found.
+	mustNotExist bool
+	found        []string
+	// age, when set, selects resources by age
+	age *ageFilter
+}
+
+// getResourceQuery returns the resourceQuery for a constraint.
+// Returns false if the constraint GVK is not served by the cluster.
+func (m *manager) getResourceQuery(classifier *libsveltosv1alpha1.Classifier,
+	constraint *ResourceConstraint) (*resourceQuery, bool, error) {
+
+	var prg cel.Program
+	if constraint.Expression != "" {
+		var err error
+		prg, err = m.getCELProgram(classifier, constraint.Expression)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	deployedResource := &constraint.DeployedResourceConstraint
 	gvk := schema.GroupVersionKind{
 		Group:   deployedResource.Group,
 		Version: deployedResource.Version,
 		Kind:    deployedResource.Kind,
 	}
 
-	dc := discovery.NewDiscoveryClientForConfigOrDie(m.config)
-	groupResources, err := restmapper.GetAPIGroupResources(dc)
+	mapper, err := m.getRESTMapper()
 	if err != nil {
-		return false, err
+		return nil, false, err
 	}
-	mapper := restmapper.NewDiscoveryRESTMapper(groupResources)
-
-	d := dynamic.NewForConfigOrDie(m.config)
 
 	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
 	if err != nil {
 		if meta.IsNoMatchError(err) {
-			return false, nil
+			return nil, false, nil
 		}
-		return false, err
+		return nil, false, err
 	}
 
-	resourceId := schema.GroupVersionResource{
-		Group:    gvk.Group,
-		Version:  gvk.Version,
-		Resource: mapping.Resource.Resource,
+	options := metav1.ListOptions{}
+
+	labelSelector, err := getLabelSelector(deployedResource.LabelFilters)
+	if err != nil {
+		return nil, false, newInvalidClassifierError(err)
 	}
+	options.LabelSelector = labelSelector
 
-	options := metav1.ListOptions{}
+	if deployedResource.Namespace != "" {
+		options.FieldSelector = fmt.Sprintf("metadata.namespace=%s", deployedResource.Namespace)
+	}
 
-	if len(deployedResource.LabelFilters) > 0 {
-		labelFilter := ""
-		for i := range deployedResource.LabelFilters {
-			if labelFilter != "" {
-				labelFilter += ","
-			}
-			f := deployedResource.LabelFilters[i]
-			if f.Operation == libsveltosv1alpha1.OperationEqual {
-				labelFilter += fmt.Sprintf("%s=%s", f.Key, f.Value)
-			} else {
-				labelFilter += fmt.Sprintf("%s!=%s", f.Key, f.Value)
-			}
+	namespaced := mapping.Scope.Name() == meta.RESTScopeNameNamespace
+	if err := validateResourceNames(constraint.ResourceNames, deployedResource.Namespace, namespaced); err != nil {
+		return nil, false, newInvalidClassifierError(err)
+	}
+
+	// Resources in excluded namespaces are filtered out by the API server, so
+	// they are counted neither against MinCount/MaxCount nor as part of the
+	// total Percentage constraints are computed on.
+	if namespaced {
+		excludedSelectors, err := getExcludedNamespacesSelectors(constraint.ExcludedNamespaces)
+		if err != nil {
+			return nil, false, newInvalidClassifierError(err)
 		}
+		options.FieldSelector = joinFieldSelectors(append([]string{options.FieldSelector}, excludedSelectors...)...)
+	}
 
-		options.LabelSelector = labelFilter
+	compile := func(pattern string) (*regexp.Regexp, error) {
+		return m.getRegex(classifier, pattern)
 	}
 
-	if len(deployedResource.FieldFilters) > 0 {
-		fieldFilter := ""
-		for i := range deployedResource.FieldFilters {
-			if fieldFilter != "" {
-				fieldFilter += ","
-			}
-			f := deployedResource.FieldFilters[i]
-			if f.Operation == libsveltosv1alpha1.OperationEqual {
-				fieldFilter += fmt.Sprintf("%s=%s", f.Field, f.Value)
-			} else {
-				fieldFilter += fmt.Sprintf("%s!=%s", f.Field, f.Value)
-			}
-		}
+	labelMatchers, err := getLabelFilterMatchers(deployedResource.LabelFilters, compile)
+	if err != nil {
+		return nil, false, newInvalidClassifierError(err)
+	}
 
-		options.FieldSelector = fieldFilter
+	annotationMatchers, err := getAnnotationFilterMatchers(constraint.AnnotationFilters, compile)
+	if err != nil {
+		return nil, false, newInvalidClassifierError(err)
 	}
 
-	if deployedResource.Namespace != "" {
-		if options.FieldSelector != "" {
-			options.FieldSelector += ","
+	if err := validateOwnerFilters(constraint.OwnerFilters); err != nil {
+		return nil, false, newInvalidClassifierError(err)
+	}
+
+	if err := validateConditionFilters(constraint.ConditionFilters); err != nil {
+		return nil, false, newInvalidClassifierError(err)
+	}
+
+	if err := validateRestartCountFilter(constraint); err != nil {
+		return nil, false, newInvalidClassifierError(err)
+	}
+
+	if err := validateTaintFilters(constraint); err != nil {
+		return nil, false, newInvalidClassifierError(err)
+	}
+
+	fieldMatchers, err := getFieldFilterMatchers(deployedResource.FieldFilters, compile)
+	if err != nil {
+		return nil, false, newInvalidClassifierError(err)
+	}
+	now := m.getClusterTime()
+	for i := range fieldMatchers {
+		fieldMatchers[i].now = now
+	}
+
+	if err := applyMissingFieldPolicies(fieldMatchers, constraint.MissingFields); err != nil {
+		return nil, false, newInvalidClassifierError(err)
+	}
+
+	if err := applyElementMatchPolicies(fieldMatchers, constraint.ElementMatch); err != nil {
+		return nil, false, newInvalidClassifierError(err)
+	}
+
+	// API server only supports field selectors on a handful of fields per resource.
+	// Other FieldFilters are evaluated by classifier-agent. Percentage constraints
+	// need resources not satisfying FieldFilters to be listed as well.
+	// Resources targeted by name are fetched with a Get, which field selectors do
+	// not apply to.
+	if constraint.Percentage == nil && len(constraint.ResourceNames) == 0 {
+		var fieldSelectors []string
+		fieldSelectors, fieldMatchers = getFieldSelectors(gvk.GroupKind(), fieldMatchers)
+		options.FieldSelector = joinFieldSelectors(append([]string{options.FieldSelector}, fieldSelectors...)...)
+	}
+
+	age, err := getAgeFilter(constraint, now)
+	if err != nil {
+		return nil, false, newInvalidClassifierError(err)
+	}
+
+	namespace := ""
+	if namespaced {
+		namespace = deployedResource.Namespace
+	}
+
+	return &resourceQuery{
+		gvk:                gvk,
+		resource:           mapping.Resource.Resource,
+		options:            options,
+		labelMatchers:      labelMatchers,
+		annotationMatchers: annotationMatchers,
+		ownerFilters:       constraint.OwnerFilters,
+		conditionFilters:   constraint.ConditionFilters,
+		restartCount:       constraint.RestartCount,
+		taintFilters:       constraint.TaintFilters,
+		reconciledOnly:     constraint.ReconciledOnly,
+		names:              constraint.ResourceNames,
+		namespace:          namespace,
+		fieldMatchers:      fieldMatchers,
+		prg:                prg,
+		expression:         constraint.Expression,
+		mustNotExist:       constraint.MustNotExist,
+		age:                age,
+	}, true, nil
+}
+
+// getMatchingResources returns all resources matching the constraint. Resources are
+// filtered by namespace, label and field filters and, if set, by the CEL Expression.
+// Returns false if the constraint GVK is not served by the cluster.
+// If snapshot is not nil, resources are listed at the snapshot resourceVersion.
+func (m *manager) getMatchingResources(ctx context.Context, classifier *libsveltosv1alpha1.Classifier,
+	constraint *ResourceConstraint, snapshot *evaluationSnapshot) ([]unstructured.Unstructured, bool, error) {
+
+	items, _, served, err := m.listMatchingResources(ctx, classifier, constraint, snapshot)
+	return items, served, err
+}
+
+// listMatchingResources returns all resources matching the constraint along with the
+// number of resources listed before field filters and CEL Expression were applied
+func (m *manager) listMatchingResources(ctx context.Context, classifier *libsveltosv1alpha1.Classifier,
+	constraint *ResourceConstraint, snapshot *evaluationSnapshot) ([]unstructured.Unstructured, int, bool, error) {
+
+	query, served, err := m.getResourceQuery(classifier, constraint)
+	if err != nil || !served {
+		return nil, 0, served, err
+	}
+	defer query.addMissingFieldNotes(ctx)
+	defer query.addMustNotExistNotes(ctx)
+	defer m.scheduleAgeEvaluation(classifier, query)
+
+	sample, err := m.applyBroadConstraintPolicy(ctx, classifier, query)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	if sample {
+		query.options.Limit = int64(m.getBroadConstraintThreshold())
+	}
+
+	snapshot.setListOptions(&query.options)
+
+	list, err := m.listQueryResources(ctx, query)
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	snapshot.update(list.GetResourceVersion())
+
+	total := len(list.Items)
+	items, err := query.filter(list.Items)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	query.recordFound(items)
+
+	return items, total - query.skipped, true, nil
+}
+
+// countMatchingResources returns the number of resources matching the constraint.
+// Unlike getMatchingResources, resources are listed in pages of listPageSize items.
+// Each page is discarded once its matching resources are counted, so memory
+// is bounded by the page size and not by the number of resources in the cluster.
+// Listing stops once the count is enough to decide whether MinCount and MaxCount
+// are satisfied: the returned count is then a lower bound.
+// Returns false if the constraint GVK is not served by the cluster.
+// If snapshot is not nil, resources are listed at the snapshot resourceVersion.
+func (m *manager) countMatchingResources(ctx context.Context, classifier *libsveltosv1alpha1.Classifier,
+	constraint *ResourceConstraint, snapshot *evaluationSnapshot) (int, bool, error) {
+
+	count, _, served, err := m.countResources(ctx, classifier, constraint, snapshot,
+		getCountStopCondition(&constraint.DeployedResourceConstraint))
+	return count, served, err
+}
+
+// stopCondition returns true when, given the number of matching resources and
+// the number of resources listed so far, listing can stop
+type stopCondition func(count, listed int) bool
+
+// getCountStopCondition returns a stopCondition verified once MaxCount is
+// exceeded or, if MaxCount is not set, once MinCount is reached
+func getCountStopCondition(deployedResource *libsveltosv1alpha1.DeployedResourceConstraint) stopCondition {
+	return func(count, listed int) bool {
+		if deployedResource.MaxCount != nil {
+			return count > *deployedResource.MaxCount
 		}
-		options.FieldSelector += fmt.Sprintf("metadata.namespace=%s", deployedResource.Namespace)
+		return deployedResource.MinCount != nil && count >= *deployedResource.MinCount
+	}
+}
+
+// countResources lists, page by page, resources for constraint and returns the number
+// of matching resources and the number of resources listed. Listing stops when stop,
+// if not nil, returns true.
+func (m *manager) countResources(ctx context.Context, classifier *libsveltosv1alpha1.Classifier,
+	constraint *ResourceConstraint, snapshot *evaluationSnapshot, stop stopCondition) (int, int, bool, error) {
+
+	count := 0
+	listed, served, err := m.scanResources(ctx, classifier, constraint, snapshot,
+		func(items []unstructured.Unstructured, listed int) (bool, error) {
+			count += len(items)
+			return stop != nil && stop(count, listed), nil
+		})
+	if err != nil || !served {
+		return 0, 0, served, err
+	}
+	return count, listed, true, nil
+}
+
+// pageVisitor is invoked with the matching resources of each listed page and the
+// number of resources listed so far. Returns true when listing can stop.
+// Resources must not be used after visitor returns.
+type pageVisitor func(items []unstructured.Unstructured, listed int) (bool, error)
+
+// scanResources lists, page by page, resources for constraint and invokes visit with
+// the matching resources of each page. Each page is discarded once visited.
+// Returns the number of resources listed, skipped ones excluded.
+func (m *manager) scanResources(ctx context.Context, classifier *libsveltosv1alpha1.Classifier,
+	constraint *ResourceConstraint, snapshot *evaluationSnapshot, visit pageVisitor) (int, bool, error) {
+
+	query, served, err := m.getResourceQuery(classifier, constraint)
+	if err != nil || !served {
+		return 0, served, err
 	}
+	defer query.addMissingFieldNotes(ctx)
+	defer query.addMustNotExistNotes(ctx)
+	defer m.scheduleAgeEvaluation(classifier, query)
 
-	list, err := d.Resource(resourceId).List(ctx, options)
+	sample, err := m.applyBroadConstraintPolicy(ctx, classifier, query)
 	if err != nil {
-		return false, err
+		return 0, false, err
 	}
 
-	if deployedResource.MinCount != nil {
-		if len(list.Items) < *deployedResource.MinCount {
-			return false, nil
+	query.options.Limit = listPageSize
+	snapshot.setListOptions(&query.options)
+
+	listed := 0
+	// read also includes skipped resources
+	read := 0
+	for {
+		list, err := m.listQueryResources(ctx, query)
+		if err != nil {
+			return 0, false, err
+		}
+
+		if query.options.Continue == "" {
+			// All pages are served at the resourceVersion of the first one
+			snapshot.update(list.GetResourceVersion())
+		}
+
+		skipped := query.skipped
+		items, err := query.filter(list.Items)
+		if err != nil {
+			return 0, false, err
+		}
+		query.recordFound(items)
+		// Skipped resources are not part of the evaluation
+		listed += len(list.Items) - (query.skipped - skipped)
+		read += len(list.Items)
+
+		stop, err := visit(items, listed)
+		if err != nil {
+			return 0, false, err
 		}
+
+		if list.GetContinue() == "" {
+			return listed, true, nil
+		}
+
+		if sample && read >= m.getBroadConstraintThreshold() {
+			// Only a sample of resources is evaluated
+			return listed, true, nil
+		}
+
+		if stop {
+			return listed, true, nil
+		}
+
+		// resourceVersion cannot be set along with a continue token, which already
+		// encodes it
+		query.options.Continue = list.GetContinue()
+		query.options.ResourceVersion = ""
+		query.options.ResourceVersionMatch = ""
 	}
+}
 
-	if deployedResource.MaxCount != nil {
-		if len(list.Items) > *deployedResource.MaxCount {
-			return false, nil
+// filterResources returns the resources satisfying all field filters and, when prg
+// is not nil, the CEL expression.
+// Resources are only read, never modified. Matching resources are compacted at the
+// beginning of resources, whose backing array is reused: callers must own resources
+// and not use it after this call.
+func filterResources(resources []unstructured.Unstructured, fieldFilters []libsveltosv1alpha1.FieldFilter,
+	prg cel.Program, expression string) ([]unstructured.Unstructured, error) {
+
+	matchers, err := getFieldFilterMatchers(fieldFilters, compileRegex)
+	if err != nil {
+		return nil, newInvalidClassifierError(err)
+	}
+
+	query := &resourceQuery{fieldMatchers: matchers, prg: prg, expression: expression}
+	return query.filter(resources)
+}
+
+// filter returns the resources satisfying all label and field matchers and, when prg
+// is not nil, the CEL expression. Like filterResources, resources backing array is reused.
+// Skipped resources are counted in q.skipped.
+func (q *resourceQuery) filter(resources []unstructured.Unstructured) ([]unstructured.Unstructured, error) {
+	if q.prg == nil && len(q.fieldMatchers) == 0 && len(q.labelMatchers) == 0 &&
+		len(q.annotationMatchers) == 0 && len(q.ownerFilters) == 0 && len(q.conditionFilters) == 0 &&
+		q.restartCount == nil && len(q.taintFilters) == 0 && !q.reconciledOnly && q.age == nil {
+
+		return resources, nil
+	}
+
+	items := resources[:0]
+	for i := range resources {
+		outcome, err := q.evaluate(&resources[i])
+		if err != nil {
+			return nil, err
+		}
+		// Age is verified last so that only resources satisfying all other filters
+		// schedule an evaluation when crossing an age bound
+		if outcome == fieldFilterMatch && q.age != nil && !q.age.isAMatch(&resources[i]) {
+			outcome = fieldFilterNoMatch
+		}
+		switch outcome {
+		case fieldFilterMatch:
+			items = append(items, resources[i])
+		case fieldFilterSkip:
+			q.skipped++
 		}
 	}
 
-	return true, nil
+	return items, nil
+}
+
+// evaluate returns whether resource is reconciled, when required, and satisfies all
+// label, annotation, owner, condition, restart count, taint and field filters and,
+// when prg is not nil, the CEL expression. Resource is skipped when a field filter
+// with MissingFieldSkip policy is evaluated on a missing field.
+func (q *resourceQuery) evaluate(resource *unstructured.Unstructured) (fieldFilterOutcome, error) {
+	if (q.reconciledOnly && !isReconciled(resource)) ||
+		!areLabelFilterMatchersAMatch(resource.Object, q.labelMatchers) ||
+		!areAnnotationFilterMatchersAMatch(resource.Object, q.annotationMatchers) ||
+		!areOwnerFiltersAMatch(resource, q.ownerFilters) ||
+		!areConditionFiltersAMatch(resource, q.conditionFilters) ||
+		!isRestartCountAMatch(resource, q.restartCount) ||
+		!areTaintFiltersAMatch(resource, q.taintFilters) {
+
+		return fieldFilterNoMatch, nil
+	}
+
+	outcome, err := evaluateFieldFilterMatchers(resource.Object, q.fieldMatchers)
+	if err != nil {
+		return fieldFilterNoMatch, newInvalidClassifierError(err)
+	}
+	if outcome != fieldFilterMatch || q.prg == nil {
+		return outcome, nil
+	}
+
+	isMatch, err := evaluateCELProgram(q.prg, resource.Object)
+	if err != nil {
+		return fieldFilterNoMatch, newInvalidClassifierError(errors.Wrap(err,
+			fmt.Sprintf("failed to evaluate expression %q", q.expression)))
+	}
+	if isMatch {
+		return fieldFilterMatch, nil
+	}
+	return fieldFilterNoMatch, nil
 }
 
 // getClassifierReport returns ClassifierReport instance that needs to be created
@@ -311,6 +1119,35 @@ func (m *manager) getClassifierReport(classifierName string, isMatch bool) *libs
 func (m *manager) getManamegentClusterClient(ctx context.Context, logger logr.Logger,
 ) (client.Client, error) {
 
+	if m.managementClient != nil {
+		return m.managementClient, nil
+	}
+
+	restConfig, err := m.getManagementClusterConfig(ctx, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	s := runtime.NewScheme()
+	err = libsveltosv1alpha1.AddToScheme(s)
+	if err != nil {
+		return nil, err
+	}
+
+	agentClient, err := client.New(restConfig, client.Options{Scheme: s})
+	if err != nil {
+		logger.V(logs.LogInfo).Info(fmt.Sprintf("failed to get management cluster client: %v", err))
+		return nil, err
+	}
+
+	return agentClient, nil
+}
+
+// getManagementClusterConfig gets the Secret containing the Kubeconfig to access
+// management cluster and returns the corresponding rest.Config
+func (m *manager) getManagementClusterConfig(ctx context.Context, logger logr.Logger,
+) (*rest.Config, error) {
+
 	kubeconfigContent, err := m.getKubeconfig(ctx)
 	if err != nil {
 		logger.V(logs.LogInfo).Info(fmt.Sprintf("failed to get management cluster kubeconfig: %v", err))
@@ -336,19 +1173,7 @@ func (m *manager) getManamegentClusterClient(ctx context.Context, logger logr.Lo
 		return nil, err
 	}
 
-	s := runtime.NewScheme()
-	err = libsveltosv1alpha1.AddToScheme(s)
-	if err != nil {
-		return nil, err
-	}
-
-	agentClient, err := client.New(restConfig, client.Options{Scheme: s})
-	if err != nil {
-		logger.V(logs.LogInfo).Info(fmt.Sprintf("failed to get management cluster client: %v", err))
-		return nil, err
-	}
-
-	return agentClient, nil
+	return restConfig, nil
 }
 
 // sendClassifierReport sends classifierReport to management cluster
@@ -359,7 +1184,20 @@ func (m *manager) sendClassifierReport(ctx context.Context, classifier *libsvelt
 	err := m.Get(ctx,
 		types.NamespacedName{Namespace: utils.ReportNamespace, Name: classifier.Name}, classifierReport)
 	if err != nil {
-		logger.V(logs.LogInfo).Info(fmt.Sprintf("failed to get classifier: %v", err))
+		if !isReportCRDMissing(err) {
+			logger.V(logs.LogInfo).Info(fmt.Sprintf("failed to get classifier: %v", err))
+			return err
+		}
+		classifierReport = m.getPendingReport(classifier.Name)
+		if classifierReport == nil || !m.sendReportsWithoutCRD {
+			logger.V(logs.LogDebug).Info("ClassifierReport CRD is not installed. Not sending ClassifierReport")
+			return nil
+		}
+	}
+
+	shard, err := m.getReportShard()
+	if err != nil {
+		logger.V(logs.LogInfo).Info(fmt.Sprintf("failed to get report shard: %v", err))
 		return err
 	}
 
@@ -371,40 +1209,30 @@ func (m *manager) sendClassifierReport(ctx context.Context, classifier *libsvelt
 
 	logger.V(logs.LogDebug).Info("send classifierReport to management cluster")
 
-	classifierReportName := libsveltosv1alpha1.GetClassifierReportName(classifier.Name,
-		m.clusterName, &m.clusterType)
-	classifierReportNamespace := m.clusterNamespace
-
-	currentClassifierReport := &libsveltosv1alpha1.ClassifierReport{}
-
-	err = agentClient.Get(ctx,
-		types.NamespacedName{Namespace: classifierReportNamespace, Name: classifierReportName},
-		currentClassifierReport)
-	if err != nil {
-		if apierrors.IsNotFound(err) {
-			currentClassifierReport.Namespace = classifierReportNamespace
-			currentClassifierReport.Name = classifierReportName
-			currentClassifierReport.Spec = classifierReport.Spec
-			currentClassifierReport.Spec.ClusterNamespace = m.clusterNamespace
-			currentClassifierReport.Spec.ClusterName = m.clusterName
-			currentClassifierReport.Spec.ClusterType = m.clusterType
-			currentClassifierReport.Labels = libsveltosv1alpha1.GetClassifierReportLabels(
+	currentClassifierReport := &libsveltosv1alpha1.ClassifierReport{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: m.clusterNamespace,
+			Name: libsveltosv1alpha1.GetClassifierReportName(classifier.Name,
+				m.clusterName, &m.clusterType),
+			Labels: libsveltosv1alpha1.GetClassifierReportLabels(
 				classifier.Name, m.clusterName, &m.clusterType,
-			)
-			return agentClient.Create(ctx, currentClassifierReport)
-		}
-		return err
+			),
+		},
+		Spec: classifierReport.Spec,
 	}
-
-	currentClassifierReport.Namespace = classifierReportNamespace
-	currentClassifierReport.Name = classifierReportName
+	currentClassifierReport.Spec.ClusterNamespace = m.clusterNamespace
+	currentClassifierReport.Spec.ClusterName = m.clusterName
 	currentClassifierReport.Spec.ClusterType = m.clusterType
-	currentClassifierReport.Spec.Match = classifierReport.Spec.Match
-	currentClassifierReport.Labels = libsveltosv1alpha1.GetClassifierReportLabels(
-		classifier.Name, m.clusterName, &m.clusterType,
-	)
+	currentClassifierReport.Labels[ReportShardLabel] = shard
+	copyAnnotations(currentClassifierReport, classifierReport, reportAnnotations)
+	if m.reportDeduplication {
+		if err := m.setReportDuplicates(ctx, currentClassifierReport, classifier); err != nil {
+			logger.V(logs.LogInfo).Info(fmt.Sprintf("failed to find duplicate classifiers: %v", err))
+			return err
+		}
+	}
 
-	return agentClient.Update(ctx, currentClassifierReport)
+	return wrapClusterTypeError(applyClassifierReport(ctx, agentClient, currentClassifierReport), m.clusterType)
 }
 
 func (m *manager) getKubeconfig(ctx context.Context) ([]byte, error) {
@@ -427,53 +1255,39 @@ func (m *manager) getKubeconfig(ctx context.Context) ([]byte, error) {
 }
 
 // createClassifierReport creates ClassifierReport or updates it if already exists.
+// evaluationErr, if not nil, is the reason Classifier could not be evaluated.
 func (m *manager) createClassifierReport(ctx context.Context, classifier *libsveltosv1alpha1.Classifier,
-	isMatch bool) error {
+	isMatch bool, evaluationErr error) error {
 
 	logger := m.log.WithValues("classifier", classifier.Name)
 
-	classifierReport := &libsveltosv1alpha1.ClassifierReport{}
-	err := m.Get(ctx,
-		types.NamespacedName{Namespace: utils.ReportNamespace, Name: classifier.Name}, classifierReport)
-	if err == nil {
-		return m.updateClassifierReport(ctx, classifier, isMatch, classifierReport)
-	}
-
-	if err != nil && !apierrors.IsNotFound(err) {
-		logger.Error(err, "failed to get ClassifierReport")
-		return err
-	}
-
-	logger.V(logs.LogInfo).Info("creating ClassifierReport")
-	classifierReport = m.getClassifierReport(classifier.Name, isMatch)
-	err = m.Create(ctx, classifierReport)
+	logger.V(logs.LogDebug).Info("applying ClassifierReport")
+	classifierReport := m.getClassifierReport(classifier.Name, isMatch)
+	m.setReportAnnotations(classifierReport, classifier, evaluationErr)
+	err := applyClassifierReport(ctx, m.Client, classifierReport)
 	if err != nil {
-		logger.Error(err, "failed to create ClassifierReport")
+		logger.Error(err, "failed to apply ClassifierReport")
 		return err
 	}
 
 	return m.updateClassifierReportStatus(ctx, classifier)
 }
 
-// updateClassifierReport updates ClassifierReport
-func (m *manager) updateClassifierReport(ctx context.Context, classifier *libsveltosv1alpha1.Classifier,
-	isMatch bool, classifierReport *libsveltosv1alpha1.ClassifierReport) error {
-
-	logger := m.log.WithValues("classifier", classifier.Name)
-	logger.V(logs.LogDebug).Info("updating ClassifierReport")
-	if classifierReport.Labels == nil {
-		classifierReport.Labels = map[string]string{}
-	}
-	classifierReport.Labels[libsveltosv1alpha1.ClassifierLabelName] = classifier.Name
-	classifierReport.Spec.Match = isMatch
-
-	err := m.Update(ctx, classifierReport)
-	if err != nil {
-		logger.Error(err, "failed to update ClassifierReport")
-		return err
-	}
-
-	return m.updateClassifierReportStatus(ctx, classifier)
+// setReportAnnotations sets all annotations describing the outcome of a
+// complete Classifier evaluation
+func (m *manager) setReportAnnotations(classifierReport *libsveltosv1alpha1.ClassifierReport,
+	classifier *libsveltosv1alpha1.Classifier, evaluationErr error) {
+
+	setReportError(classifierReport, getErrorMessage(evaluationErr))
+	setReportAnnotation(classifierReport, ClassifierReportDegradedAnnotation, "")
+	m.setReportCloudProvider(classifierReport, classifier)
+	m.setReportExplanation(classifierReport, classifier)
+	m.setReportAgentDegraded(classifierReport)
+	m.setReportClusterUnhealthy(classifierReport)
+	m.setReportStaleFacts(classifierReport, time.Now())
+	m.setReportClockSkew(classifierReport)
+	m.setReportEffectiveRBAC(classifierReport, classifier)
+	m.setReportHookAnnotations(classifierReport, classifier)
 }
 
 // updateClassifierReportStatus updates ClassifierReport Status by marking Phase as ReportWaitingForDelivery
@@ -500,10 +1314,11 @@ func (m *manager) cleanClassifierReport(ctx context.Context, classifierName stri
 	// Find classifierReport and delete it. In the management cluster classifierReport
 	// is removed when Classifier is removed
 	classifierReport := &libsveltosv1alpha1.ClassifierReport{}
+	m.removePendingReport(classifierName)
 	err := m.Get(ctx,
 		types.NamespacedName{Namespace: utils.ReportNamespace, Name: classifierName}, classifierReport)
 	if err != nil {
-		if apierrors.IsNotFound(err) {
+		if apierrors.IsNotFound(err) || isReportCRDMissing(err) {
 			return nil
 		}
 		return err
@@ -0,0 +1,102 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/cel-go/cel"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+const benchmarkResources = 5000
+
+// getBenchmarkPods returns size pods. One in ten runs image nginx:1.14.2
+func getBenchmarkPods(size int) []unstructured.Unstructured {
+	pods := make([]unstructured.Unstructured, size)
+	for i := range pods {
+		image := "envoy:1.24"
+		if i%10 == 0 {
+			image = "nginx:1.14.2"
+		}
+		pods[i] = unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Pod",
+			"metadata": map[string]interface{}{
+				"name":      fmt.Sprintf("pod-%d", i),
+				"namespace": "default",
+			},
+			"spec": map[string]interface{}{
+				"nodeName": fmt.Sprintf("node-%d", i%50),
+				"containers": []interface{}{
+					map[string]interface{}{"name": "main", "image": image},
+					map[string]interface{}{"name": "sidecar", "image": "busybox:1.36"},
+				},
+			},
+			"status": map[string]interface{}{
+				"phase": "Running",
+			},
+		}}
+	}
+	return pods
+}
+
+func benchmarkFilterResources(b *testing.B, fieldFilters []libsveltosv1alpha1.FieldFilter, expression string) {
+	pods := getBenchmarkPods(benchmarkResources)
+
+	var prg cel.Program
+	if expression != "" {
+		var err error
+		prg, err = classification.CompileCELExpression(expression)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	resources := make([]unstructured.Unstructured, len(pods))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// filterResources reuses its input, as getMatchingResources does with the list
+		// returned by the API server
+		copy(resources, pods)
+		items, err := classification.FilterResources(resources, fieldFilters, prg, expression)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(items) != benchmarkResources/10 {
+			b.Fatalf("expected %d resources, got %d", benchmarkResources/10, len(items))
+		}
+	}
+}
+
+func BenchmarkFilterResourcesFieldFilters(b *testing.B) {
+	benchmarkFilterResources(b, []libsveltosv1alpha1.FieldFilter{
+		{Field: "status.phase", Operation: libsveltosv1alpha1.OperationEqual, Value: "Running"},
+		{Field: "spec.containers.image", Operation: libsveltosv1alpha1.OperationEqual, Value: "nginx:1.14.2"},
+	}, "")
+}
+
+func BenchmarkFilterResourcesExpression(b *testing.B) {
+	benchmarkFilterResources(b, nil,
+		`object.spec.containers.exists(c, c.image == "nginx:1.14.2")`)
+}
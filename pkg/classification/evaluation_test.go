@@ -20,11 +20,18 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	gomegatypes "github.com/onsi/gomega/types"
 
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -34,8 +41,9 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
+	"github.com/projectsveltos/classifier-agent/internal/utils"
 	"github.com/projectsveltos/classifier-agent/pkg/classification"
-	"github.com/projectsveltos/classifier-agent/pkg/utils"
+	"github.com/projectsveltos/classifier-agent/pkg/explanation"
 	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
 	libsveltosutils "github.com/projectsveltos/libsveltos/lib/utils"
 )
@@ -58,6 +66,41 @@ spec:
     image: nginx:1.14.2`
 )
 
+// matchStageTestCase is evaluated by the isClassifierAMatch stage recording check
+// on a cluster with objects
+type matchStageTestCase struct {
+	check     explanation.CheckType
+	objects   []client.Object
+	extension string
+	isMatch   bool
+	// notes, when set, verifies the evaluation notes
+	notes gomegatypes.GomegaMatcher
+	// invalid is set when extension must be rejected
+	invalid bool
+}
+
+func evaluateMatchStages(testCases []matchStageTestCase) {
+	for i := range testCases {
+		tc := &testCases[i]
+		classification.InitializeManagerWithObjects(nil, tc.objects...)
+
+		classifier := getClassifierWithKubernetesConstraints(version25, libsveltosv1alpha1.ComparisonEqual)
+		classifier.Annotations = map[string]string{classification.ClassifierExtensionAnnotation: tc.extension}
+
+		isMatch, notes, err := classification.EvaluateMatchStage(classification.GetManager(), tc.check, classifier)
+		if tc.invalid {
+			Expect(err).To(HaveOccurred(), tc.extension)
+			Expect(classification.IsInvalidClassifierError(err)).To(BeTrue(), tc.extension)
+			continue
+		}
+		Expect(err).ToNot(HaveOccurred(), tc.extension)
+		Expect(isMatch).To(Equal(tc.isMatch), tc.extension)
+		if tc.notes != nil {
+			Expect(notes).To(tc.notes, tc.extension)
+		}
+	}
+}
+
 var _ = Describe("Manager: evaluation", func() {
 	var scheme *runtime.Scheme
 
@@ -322,7 +365,7 @@ var _ = Describe("Manager: evaluation", func() {
 		Expect(manager).ToNot(BeNil())
 
 		isMatch := true
-		Expect(classification.CreateClassifierReport(manager, context.TODO(), classifier, isMatch)).To(Succeed())
+		Expect(classification.CreateClassifierReport(manager, context.TODO(), classifier, isMatch, nil)).To(Succeed())
 
 		verifyClassifierReport(c, classifier, isMatch)
 	})
@@ -356,7 +399,7 @@ var _ = Describe("Manager: evaluation", func() {
 		manager := classification.GetManager()
 		Expect(manager).ToNot(BeNil())
 
-		Expect(classification.CreateClassifierReport(manager, context.TODO(), classifier, isMatch)).To(Succeed())
+		Expect(classification.CreateClassifierReport(manager, context.TODO(), classifier, isMatch, nil)).To(Succeed())
 
 		verifyClassifierReport(c, classifier, isMatch)
 	})
@@ -642,6 +685,524 @@ var _ = Describe("Manager: evaluation", func() {
 		}, timeout, pollingInterval).Should(BeTrue())
 	})
 
+	It("isResourceConstraintAMatch only counts resources matching CEL expression", func() {
+		countMin := 1
+		namespace := randomString()
+		podName := randomString()
+		classifier := &libsveltosv1alpha1.Classifier{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: randomString(),
+			},
+			Spec: libsveltosv1alpha1.ClassifierSpec{
+				ClassifierLabels: []libsveltosv1alpha1.ClassifierLabel{
+					{Key: randomString(), Value: randomString()},
+				},
+			},
+		}
+
+		Expect(testEnv.Create(context.TODO(), classifier)).To(Succeed())
+		Expect(waitForObject(context.TODO(), testEnv.Client, classifier)).To(Succeed())
+
+		ns := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: namespace,
+			},
+		}
+		Expect(testEnv.Create(context.TODO(), ns)).To(Succeed())
+		Expect(waitForObject(context.TODO(), testEnv.Client, ns)).To(Succeed())
+
+		pod := fmt.Sprintf(podTemplate, namespace, randomString())
+		u, err := libsveltosutils.GetUnstructured([]byte(pod))
+		Expect(err).To(BeNil())
+		Expect(testEnv.Create(context.TODO(), u)).To(Succeed())
+		Expect(waitForObject(context.TODO(), testEnv.Client, u)).To(Succeed())
+
+		constraint := &classification.ResourceConstraint{
+			DeployedResourceConstraint: libsveltosv1alpha1.DeployedResourceConstraint{
+				Namespace: namespace,
+				MinCount:  &countMin,
+				Group:     "",
+				Version:   "v1",
+				Kind:      "Pod",
+			},
+			Expression: fmt.Sprintf("object.metadata.name == %q", podName),
+		}
+
+		watcherCtx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		classification.InitializeManager(watcherCtx, klogr.New(), testEnv.Config, testEnv.Client,
+			randomString(), randomString(), libsveltosv1alpha1.ClusterTypeSveltos, nil, 10, false)
+		manager := classification.GetManager()
+
+		isMatch, err := classification.IsResourceConstraintAMatch(manager, watcherCtx, classifier, constraint)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeFalse())
+
+		// Create pod whose name matches expression
+		pod = fmt.Sprintf(podTemplate, namespace, podName)
+		u, err = libsveltosutils.GetUnstructured([]byte(pod))
+		Expect(err).To(BeNil())
+		Expect(testEnv.Create(context.TODO(), u)).To(Succeed())
+		Expect(waitForObject(context.TODO(), testEnv.Client, u)).To(Succeed())
+
+		// Use Eventually so cache is in sync
+		Eventually(func() bool {
+			isMatch, err = classification.IsResourceConstraintAMatch(manager, watcherCtx, classifier, constraint)
+			return err == nil && isMatch
+		}, timeout, pollingInterval).Should(BeTrue())
+	})
+
+	It("countMatchingResources counts resources across pages", func() {
+		const pods = 5
+		namespace := randomString()
+
+		ns := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: namespace,
+			},
+		}
+		Expect(testEnv.Create(context.TODO(), ns)).To(Succeed())
+		Expect(waitForObject(context.TODO(), testEnv.Client, ns)).To(Succeed())
+
+		for i := 0; i < pods; i++ {
+			pod := fmt.Sprintf(podTemplate, namespace, randomString())
+			u, err := libsveltosutils.GetUnstructured([]byte(pod))
+			Expect(err).To(BeNil())
+			Expect(testEnv.Create(context.TODO(), u)).To(Succeed())
+			Expect(waitForObject(context.TODO(), testEnv.Client, u)).To(Succeed())
+		}
+
+		constraint := &classification.ResourceConstraint{
+			DeployedResourceConstraint: libsveltosv1alpha1.DeployedResourceConstraint{
+				Namespace: namespace,
+				Group:     "",
+				Version:   "v1",
+				Kind:      "Pod",
+			},
+		}
+
+		classification.InitializeManagerWithSkip(context.TODO(), klogr.New(), testEnv.Config, testEnv.Client, nil, 10)
+		manager := classification.GetManager()
+
+		classification.SetListPageSize(2)
+		defer classification.SetListPageSize(500)
+
+		count, served, err := classification.CountMatchingResources(manager, context.TODO(), nil, constraint,
+			&classification.EvaluationSnapshot{})
+		Expect(err).To(BeNil())
+		Expect(served).To(BeTrue())
+		Expect(count).To(Equal(pods))
+
+		items, served, err := classification.GetMatchingResources(manager, context.TODO(), nil, constraint, nil)
+		Expect(err).To(BeNil())
+		Expect(served).To(BeTrue())
+		Expect(len(items)).To(Equal(count))
+	})
+
+	It("countMatchingResources stops listing once MinCount is reached", func() {
+		const pods = 5
+		namespace := randomString()
+
+		ns := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: namespace,
+			},
+		}
+		Expect(testEnv.Create(context.TODO(), ns)).To(Succeed())
+		Expect(waitForObject(context.TODO(), testEnv.Client, ns)).To(Succeed())
+
+		for i := 0; i < pods; i++ {
+			pod := fmt.Sprintf(podTemplate, namespace, randomString())
+			u, err := libsveltosutils.GetUnstructured([]byte(pod))
+			Expect(err).To(BeNil())
+			Expect(testEnv.Create(context.TODO(), u)).To(Succeed())
+			Expect(waitForObject(context.TODO(), testEnv.Client, u)).To(Succeed())
+		}
+
+		minCount := 2
+		constraint := &classification.ResourceConstraint{
+			DeployedResourceConstraint: libsveltosv1alpha1.DeployedResourceConstraint{
+				Namespace: namespace,
+				Group:     "",
+				Version:   "v1",
+				Kind:      "Pod",
+				MinCount:  &minCount,
+			},
+		}
+
+		classification.InitializeManagerWithSkip(context.TODO(), klogr.New(), testEnv.Config, testEnv.Client, nil, 10)
+		manager := classification.GetManager()
+
+		classification.SetListPageSize(1)
+		defer classification.SetListPageSize(500)
+
+		count, served, err := classification.CountMatchingResources(manager, context.TODO(), nil, constraint, nil)
+		Expect(err).To(BeNil())
+		Expect(served).To(BeTrue())
+		Expect(count).To(Equal(minCount))
+
+		// With MaxCount set all resources need to be counted
+		maxCount := pods
+		constraint.MaxCount = &maxCount
+		count, _, err = classification.CountMatchingResources(manager, context.TODO(), nil, constraint, nil)
+		Expect(err).To(BeNil())
+		Expect(count).To(Equal(pods))
+
+		minPercent := 100
+		constraint.Percentage = &classification.PercentageConstraint{MinPercent: &minPercent}
+		isMatch, err := classification.IsResourceConstraintAMatch(manager, context.TODO(), nil, constraint)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+	})
+
+	It("countMatchingResources applies broad constraint policy to unfiltered constraints", func() {
+		for i := 0; i < 3; i++ {
+			ns := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: randomString(),
+				},
+			}
+			Expect(testEnv.Create(context.TODO(), ns)).To(Succeed())
+			Expect(waitForObject(context.TODO(), testEnv.Client, ns)).To(Succeed())
+		}
+
+		constraint := &classification.ResourceConstraint{
+			DeployedResourceConstraint: libsveltosv1alpha1.DeployedResourceConstraint{
+				Group:   "",
+				Version: "v1",
+				Kind:    "Namespace",
+			},
+		}
+		classifier := getClassifierWithKubernetesConstraints(version25, libsveltosv1alpha1.ComparisonEqual)
+
+		classification.InitializeManagerWithSkip(context.TODO(), klogr.New(), testEnv.Config, testEnv.Client, nil, 10)
+		manager := classification.GetManager()
+
+		classification.SetListPageSize(1)
+		defer classification.SetListPageSize(500)
+		const threshold = 2
+		defer classification.SetBroadConstraintPolicy(classification.BroadConstraintAllow,
+			classification.DefaultBroadConstraintThreshold)
+
+		classification.SetBroadConstraintPolicy(classification.BroadConstraintAllow, threshold)
+		total, _, err := classification.CountMatchingResources(manager, context.TODO(), classifier, constraint, nil)
+		Expect(err).To(BeNil())
+		Expect(total).To(BeNumerically(">", threshold))
+
+		classification.SetBroadConstraintPolicy(classification.BroadConstraintSample, threshold)
+		count, served, err := classification.CountMatchingResources(manager, context.TODO(), classifier, constraint, nil)
+		Expect(err).To(BeNil())
+		Expect(served).To(BeTrue())
+		Expect(count).To(Equal(threshold))
+
+		classification.SetBroadConstraintPolicy(classification.BroadConstraintDefer, threshold)
+		count, _, err = classification.CountMatchingResources(manager, context.TODO(), classifier, constraint, nil)
+		Expect(err).To(BeNil())
+		Expect(count).To(BeNumerically(">=", total))
+		Expect(classification.IsDeferred(manager, classifier.Name, time.Now())).To(BeTrue())
+
+		classification.SetBroadConstraintPolicy(classification.BroadConstraintRefuse, threshold)
+		_, _, err = classification.CountMatchingResources(manager, context.TODO(), classifier, constraint, nil)
+		Expect(err).ToNot(BeNil())
+		Expect(classification.IsInvalidClassifierError(err)).To(BeTrue())
+
+		// Label filters make constraint not broad anymore
+		constraint.LabelFilters = []libsveltosv1alpha1.LabelFilter{
+			{Key: "kubernetes.io/metadata.name", Operation: libsveltosv1alpha1.OperationEqual, Value: "default"},
+		}
+		count, _, err = classification.CountMatchingResources(manager, context.TODO(), classifier, constraint, nil)
+		Expect(err).To(BeNil())
+		Expect(count).To(Equal(1))
+	})
+
+	It("isClassifierAMatch stages detect CNIs, service meshes and container runtimes", func() {
+		getNode := func(name, runtimeVersion, socket string) *corev1.Node {
+			node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}}
+			node.Status.NodeInfo.ContainerRuntimeVersion = runtimeVersion
+			if socket != "" {
+				node.Annotations = map[string]string{"kubeadm.alpha.kubernetes.io/cri-socket": socket}
+			}
+			return node
+		}
+
+		daemonSets := []client.Object{
+			&appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "aws-node"}},
+			&appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "kube-proxy"}},
+		}
+		calico := []client.Object{&apiextensionsv1.CustomResourceDefinition{
+			ObjectMeta: metav1.ObjectMeta{Name: "felixconfigurations.crd.projectcalico.org"},
+		}}
+		controlPlanes := []client.Object{
+			&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "istio-system", Name: "istiod"}},
+			&apiextensionsv1.CustomResourceDefinition{
+				ObjectMeta: metav1.ObjectMeta{Name: "servicedefaults.consul.hashicorp.com"},
+			},
+		}
+		injectedNamespaces := []client.Object{
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "shop",
+				Annotations: map[string]string{"linkerd.io/inject": "enabled"}}},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "legacy",
+				Labels: map[string]string{"istio-injection": "disabled"}}},
+		}
+		nodePools := []client.Object{
+			getNode("pool-a", "containerd://1.6.8", "unix:///run/containerd/containerd.sock"),
+			getNode("pool-b", "cri-o://1.25.1", ""), getNode("joining", "", ""),
+		}
+		// Node migrating has the CRI socket of another runtime
+		migratingNodes := []client.Object{
+			getNode("migrated", "containerd://1.6.8", "unix:///run/containerd/containerd.sock"),
+			getNode("migrating", "containerd://1.6.8", "unix:///var/run/crio/crio.sock"),
+		}
+
+		evaluateMatchStages([]matchStageTestCase{
+			{check: explanation.CheckCNI, objects: daemonSets, extension: "cnis:\n- AWS-VPC-CNI",
+				isMatch: true, notes: ConsistOf("detected CNIs: aws-vpc-cni")},
+			{check: explanation.CheckCNI, objects: daemonSets, extension: "cnis:\n- calico\n- cilium"},
+			{check: explanation.CheckCNI, objects: calico, extension: "cnis:\n- calico",
+				isMatch: true, notes: ConsistOf("detected CNIs: calico")},
+			{check: explanation.CheckCNI, extension: "cnis:\n- flannel",
+				notes: ConsistOf("no known CNI detected")},
+			{check: explanation.CheckServiceMesh, objects: controlPlanes, extension: "serviceMeshes:\n- Istio",
+				isMatch: true, notes: ConsistOf("detected service meshes: consul,istio")},
+			{check: explanation.CheckServiceMesh, objects: controlPlanes, extension: "serviceMeshes:\n- linkerd"},
+			{check: explanation.CheckServiceMesh, objects: injectedNamespaces, extension: "serviceMeshes:\n- linkerd",
+				isMatch: true, notes: ConsistOf("detected service meshes: linkerd")},
+			{check: explanation.CheckServiceMesh, objects: injectedNamespaces, extension: "serviceMeshes:\n- istio"},
+			{check: explanation.CheckContainerRuntime, objects: nodePools, extension: "containerRuntime:\n  mixed: true",
+				isMatch: true, notes: ContainElement("container runtimes: containerd,cri-o")},
+			{check: explanation.CheckContainerRuntime, objects: nodePools, extension: "containerRuntime:\n  mixed: false"},
+			{check: explanation.CheckContainerRuntime, objects: nodePools,
+				extension: "containerRuntime:\n  runtimes:\n  - containerd",
+				notes:     ContainElement("container runtime cri-o is not listed")},
+			{check: explanation.CheckContainerRuntime, objects: migratingNodes,
+				extension: "containerRuntime:\n  mixed: true",
+				isMatch:   true, notes: ContainElement("nodes with CRI socket of another runtime: migrating")},
+			{check: explanation.CheckContainerRuntime, objects: migratingNodes,
+				extension: "containerRuntime:\n  runtimes:\n  - Containerd", isMatch: true},
+		})
+	})
+
+	It("isClassifierAMatch stages detect storage classes, ingress classes and OLM operators", func() {
+		getCSV := func(namespace, name, version, phase string, labels map[string]interface{}) *unstructured.Unstructured {
+			return &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "operators.coreos.com/v1alpha1",
+				"kind":       "ClusterServiceVersion",
+				"metadata":   map[string]interface{}{"namespace": namespace, "name": name, "labels": labels},
+				"spec":       map[string]interface{}{"version": version},
+				"status":     map[string]interface{}{"phase": phase},
+			}}
+		}
+
+		standard := []client.Object{
+			&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "standard"}, Provisioner: "local"},
+		}
+		gp2 := []client.Object{&storagev1.StorageClass{
+			ObjectMeta: metav1.ObjectMeta{Name: "gp2",
+				Annotations: map[string]string{"storageclass.beta.kubernetes.io/is-default-class": "true"}},
+			Provisioner: "kubernetes.io/aws-ebs",
+		}}
+		ingressClasses := []client.Object{
+			&networkingv1.IngressClass{
+				ObjectMeta: metav1.ObjectMeta{Name: "nginx",
+					Annotations: map[string]string{networkingv1.AnnotationIsDefaultIngressClass: "true"}},
+				Spec: networkingv1.IngressClassSpec{Controller: "k8s.io/ingress-nginx"},
+			},
+			&networkingv1.IngressClass{
+				ObjectMeta: metav1.ObjectMeta{Name: "traefik"},
+				Spec:       networkingv1.IngressClassSpec{Controller: "traefik.io/ingress-controller"},
+			},
+		}
+		operators := []client.Object{
+			&unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "operators.coreos.com/v1alpha1",
+				"kind":       "Subscription",
+				"metadata":   map[string]interface{}{"namespace": "operators", "name": "my-cert-manager"},
+				"spec":       map[string]interface{}{"name": "cert-manager"},
+				"status":     map[string]interface{}{"installedCSV": "cert-manager.v1.11.0"},
+			}},
+			getCSV("operators", "cert-manager.v1.11.0", "1.11.0", "Succeeded", nil),
+			// Copy in a watched namespace: ignored
+			getCSV("default", "cert-manager.v1.11.0", "1.11.0", "Succeeded",
+				map[string]interface{}{"olm.copiedFrom": "operators"}),
+			// Installed without a Subscription
+			getCSV("olm", "etcdoperator.v0.9.4", "0.9.4", "Succeeded", nil),
+			// Not installed yet
+			getCSV("olm", "prometheusoperator.0.47.0", "0.47.0", "Installing", nil),
+		}
+
+		evaluateMatchStages([]matchStageTestCase{
+			{check: explanation.CheckStorageClass, objects: standard, extension: "defaultStorageClass: true",
+				notes: ConsistOf("no default storage class")},
+			{check: explanation.CheckStorageClass, objects: standard, extension: "defaultStorageClass: false",
+				isMatch: true},
+			{check: explanation.CheckStorageClass, objects: gp2, extension: "defaultStorageClass: true",
+				isMatch: true, notes: ConsistOf("default storage class is gp2")},
+			{check: explanation.CheckIngressClass, objects: ingressClasses, extension: `ingressClassConstraints:
+- controller: k8s.io/ingress-nginx
+  default: true
+- name: traefik`, isMatch: true},
+			{check: explanation.CheckIngressClass, objects: ingressClasses, extension: `ingressClassConstraints:
+- name: traefik
+  default: true`, notes: ConsistOf("ingress class constraint 0: no ingress class matches")},
+			{check: explanation.CheckIngressClass, objects: ingressClasses, extension: `ingressClassConstraints:
+- default: true`, invalid: true},
+			{check: explanation.CheckOLMOperator, objects: operators, extension: `olmOperatorConstraints:
+- package: cert-manager
+  versionRange: ">=1.10.0 <2.0.0"
+  namespace: operators
+- package: etcdoperator`, isMatch: true},
+			{check: explanation.CheckOLMOperator, objects: operators, extension: `olmOperatorConstraints:
+- package: cert-manager
+  namespace: default`,
+				notes: ConsistOf("olm operator constraint 0: no installed operator of package cert-manager matches")},
+			{check: explanation.CheckOLMOperator, objects: operators, extension: `olmOperatorConstraints:
+- package: prometheusoperator`},
+			{check: explanation.CheckOLMOperator, objects: operators, extension: `olmOperatorConstraints:
+- versionRange: ">=1.0.0"`, invalid: true},
+		})
+	})
+
+	It("isClassifierAMatch stages verify webhooks, pod security, api server flags and api services", func() {
+		getNamespace := func(name string, labels map[string]string) *corev1.Namespace {
+			return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+		}
+
+		url := "https://webhook.example.com"
+		webhooks := []client.Object{
+			&admissionregistrationv1.ValidatingWebhookConfiguration{
+				ObjectMeta: metav1.ObjectMeta{Name: "kyverno-resource-validating-webhook-cfg"},
+				Webhooks: []admissionregistrationv1.ValidatingWebhook{
+					{
+						Name: "validate.kyverno.svc-fail",
+						ClientConfig: admissionregistrationv1.WebhookClientConfig{
+							Service: &admissionregistrationv1.ServiceReference{Namespace: "kyverno", Name: "kyverno-svc"},
+						},
+					},
+				},
+			},
+			&admissionregistrationv1.MutatingWebhookConfiguration{
+				ObjectMeta: metav1.ObjectMeta{Name: "external-mutating"},
+				Webhooks: []admissionregistrationv1.MutatingWebhook{
+					{Name: "mutate.example.com", ClientConfig: admissionregistrationv1.WebhookClientConfig{URL: &url}},
+				},
+			},
+		}
+		namespaces := []client.Object{
+			getNamespace("kube-system", map[string]string{"pod-security.kubernetes.io/enforce": "privileged"}),
+			getNamespace("apps", map[string]string{
+				"pod-security.kubernetes.io/enforce": "restricted",
+				"pod-security.kubernetes.io/warn":    "restricted",
+			}),
+			getNamespace("web", map[string]string{"pod-security.kubernetes.io/enforce": "baseline"}),
+		}
+		// Cluster default level declared in facts
+		defaultLevel := []client.Object{
+			&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: utils.ReportNamespace, Name: classification.FactsConfigMap},
+				Data:       map[string]string{classification.PodSecurityDefaultFactPrefix + "enforce": "baseline"},
+			},
+			getNamespace("default", nil),
+		}
+		apiServerPod := []client.Object{&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "kube-system",
+				Name:      "kube-apiserver-control-plane",
+				Labels:    map[string]string{"component": "kube-apiserver"},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{
+						Name: "kube-apiserver",
+						Command: []string{"kube-apiserver", "--advertise-address=10.0.0.1",
+							"--feature-gates=ValidatingAdmissionPolicy=true,InPlacePodVerticalScaling=false",
+							"--enable-admission-plugins", "NodeRestriction,PodSecurity"},
+					},
+				},
+			},
+		}}
+		kubeadmConfig := []client.Object{&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "kubeadm-config"},
+			Data: map[string]string{"ClusterConfiguration": `apiVersion: kubeadm.k8s.io/v1beta3
+kind: ClusterConfiguration
+apiServer:
+  extraArgs:
+    disable-admission-plugins: DefaultStorageClass
+`},
+		}}
+		apiServices := []client.Object{
+			getAPIService("v1beta1.metrics.k8s.io", "True", "Passed"),
+			getAPIService("v1beta1.custom.metrics.k8s.io", "False", "MissingEndpoints"),
+		}
+
+		testCases := []matchStageTestCase{
+			{check: explanation.CheckWebhook, objects: webhooks, extension: `webhookConstraints:
+- namePattern: "kyverno-.*"
+- type: Validating
+  serviceNamespace: kyverno
+  serviceName: kyverno-svc
+- type: Mutating
+  namePattern: external-.*`, isMatch: true},
+			{check: explanation.CheckWebhook, objects: webhooks, extension: `webhookConstraints:
+- type: Mutating
+  serviceNamespace: kyverno`, notes: ConsistOf(ContainSubstring("no webhook configuration matches"))},
+			{check: explanation.CheckWebhook, objects: webhooks, extension: `webhookConstraints:
+- serviceNamespace: gatekeeper-system
+  absent: true`, isMatch: true},
+			{check: explanation.CheckWebhook, objects: webhooks, extension: `webhookConstraints:
+- namePattern: kyverno-.*
+  absent: true`, notes: ConsistOf(ContainSubstring("kyverno-resource-validating-webhook-cfg is present"))},
+			{check: explanation.CheckWebhook, extension: "webhookConstraints:\n- type: Validating", invalid: true},
+			{check: explanation.CheckWebhook, extension: "webhookConstraints:\n- namePattern: \"kyverno-(\"",
+				invalid: true},
+			{check: explanation.CheckPodSecurity, objects: namespaces, extension: `podSecurityConstraints:
+- minLevel: baseline
+  excludeNamespaces: [kube-system]
+- minLevel: restricted
+  namespaces: [apps, missing]`, isMatch: true},
+			{check: explanation.CheckPodSecurity, objects: namespaces, extension: `podSecurityConstraints:
+- minLevel: restricted
+  excludeNamespaces: [kube-system]`, notes: ConsistOf(ContainSubstring("namespace web enforce level is baseline"))},
+			// Namespaces without warn label have the cluster default level
+			{check: explanation.CheckPodSecurity, objects: namespaces, extension: `podSecurityConstraints:
+- mode: warn
+  maxLevel: privileged
+  excludeNamespaces: [apps]`, isMatch: true},
+			{check: explanation.CheckPodSecurity, objects: defaultLevel, extension: `podSecurityConstraints:
+- minLevel: baseline
+  maxLevel: baseline`, isMatch: true},
+			{check: explanation.CheckAPIServerFlags, objects: apiServerPod, extension: `apiServerFlags:
+  enabledFeatureGates: [ValidatingAdmissionPolicy]
+  disabledFeatureGates: [InPlacePodVerticalScaling]
+  enabledAdmissionPlugins: [PodSecurity]`, isMatch: true},
+			{check: explanation.CheckAPIServerFlags, objects: apiServerPod, extension: `apiServerFlags:
+  enabledFeatureGates: [InPlacePodVerticalScaling]`, notes: ConsistOf(ContainSubstring(
+				"InPlacePodVerticalScaling feature gate is not enabled (read from pod kube-system/kube-apiserver-control-plane)"))},
+			{check: explanation.CheckAPIServerFlags, objects: kubeadmConfig, extension: `apiServerFlags:
+  disabledAdmissionPlugins: [DefaultStorageClass]`, isMatch: true},
+			// Flags are not readable
+			{check: explanation.CheckAPIServerFlags, extension: `apiServerFlags:
+  enabledFeatureGates: [ValidatingAdmissionPolicy]`, notes: HaveLen(1)},
+			{check: explanation.CheckAPIService, objects: apiServices, extension: `apiServiceConstraints:
+- name: v1beta1.metrics.k8s.io`, isMatch: true},
+			{check: explanation.CheckAPIService, objects: apiServices, extension: `apiServiceConstraints:
+- name: v1beta1.metrics.k8s.io
+- name: v1beta1.custom.metrics.k8s.io`},
+			{check: explanation.CheckAPIService, objects: apiServices, extension: `apiServiceConstraints:
+- name: v1beta1.external.metrics.k8s.io`},
+		}
+		for _, constraint := range []string{
+			"- mode: enforce",
+			"- minLevel: strict",
+			"- minLevel: restricted\n  maxLevel: baseline",
+			"- mode: block\n  minLevel: baseline",
+		} {
+			testCases = append(testCases, matchStageTestCase{check: explanation.CheckPodSecurity,
+				extension: "podSecurityConstraints:\n" + constraint, invalid: true})
+		}
+
+		evaluateMatchStages(testCases)
+	})
+
 	It("cleanClassifierReport removes classifier", func() {
 		classifier := getClassifierWithKubernetesConstraints(version24, libsveltosv1alpha1.ComparisonGreaterThan)
 		classifierReport := &libsveltosv1alpha1.ClassifierReport{
@@ -799,6 +1360,9 @@ var _ = Describe("Manager: evaluation", func() {
 		v, ok = currentClassifierReport.Labels[libsveltosv1alpha1.ClassifierLabelName]
 		Expect(ok).To(BeTrue())
 		Expect(v).To(Equal(classifier.Name))
+
+		Expect(currentClassifierReport.Labels).To(HaveKeyWithValue(classification.ReportShardLabel,
+			classification.DefaultShardHasher(clusterNamespace, clusterName, clusterType)))
 	})
 })
 
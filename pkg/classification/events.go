@@ -0,0 +1,303 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+)
+
+const (
+	// MaxEventWindow is the longest EventConstraint window. Event occurrences
+	// older than that are forgotten.
+	MaxEventWindow = 24 * time.Hour
+
+	// maxEventSamples is the maximum number of occurrence samples kept per Event
+	maxEventSamples = 64
+)
+
+var eventGVK = schema.GroupVersionKind{Version: "v1", Kind: "Event"}
+
+// EventConstraint bounds the number of Kubernetes Event occurrences within a time
+// window, for instance more than 10 Warning Events with reason FailedScheduling in
+// the last hour. Occurrences are counted as Events are created and updated, so
+// Events deleted by the API server, once their TTL expires, are still counted till
+// they leave the window.
+type EventConstraint struct {
+	// Type is Normal or Warning. All types when empty.
+	// +optional
+	Type string `json:"type,omitempty"`
+
+	// Reason, when set, only counts Events with this reason, for instance FailedScheduling
+	// +optional
+	Reason string `json:"reason,omitempty"`
+
+	// Namespace restricts Events to a namespace. All namespaces when empty.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// InvolvedObjectKind, when set, only counts Events about this kind of object,
+	// for instance Pod
+	// +optional
+	InvolvedObjectKind string `json:"involvedObjectKind,omitempty"`
+
+	// Window is how far back occurrences are counted, for instance "1h".
+	// At most MaxEventWindow.
+	Window metav1.Duration `json:"window"`
+
+	// MinCount is the minimum number of occurrences within Window
+	// +optional
+	MinCount *int `json:"minCount,omitempty"`
+
+	// MaxCount is the maximum number of occurrences within Window
+	// +optional
+	MaxCount *int `json:"maxCount,omitempty"`
+}
+
+// validateEventConstraint returns an error if c is not valid
+func validateEventConstraint(c *EventConstraint) error {
+	if c.Type != "" && c.Type != corev1.EventTypeNormal && c.Type != corev1.EventTypeWarning {
+		return fmt.Errorf("type must be %s or %s, got %q", corev1.EventTypeNormal, corev1.EventTypeWarning, c.Type)
+	}
+	if c.Window.Duration <= 0 || c.Window.Duration > MaxEventWindow {
+		return fmt.Errorf("window %s is not between 0 and %s", c.Window.Duration, MaxEventWindow)
+	}
+	if c.MinCount == nil && c.MaxCount == nil {
+		return fmt.Errorf("at least one of minCount and maxCount is required")
+	}
+	if c.MinCount != nil && c.MaxCount != nil && *c.MinCount > *c.MaxCount {
+		return fmt.Errorf("minCount %d is greater than maxCount %d", *c.MinCount, *c.MaxCount)
+	}
+	return nil
+}
+
+// eventSample is a number of occurrences of an Event observed at a given time
+type eventSample struct {
+	timestamp time.Time
+	count     int32
+}
+
+// eventRecord contains the occurrences of an Event within MaxEventWindow
+type eventRecord struct {
+	namespace string
+	eventType string
+	reason    string
+	kind      string
+	// count is the Event occurrence count last observed
+	count int32
+	// samples contains occurrences, oldest first
+	samples []eventSample
+}
+
+// eventCounter counts Event occurrences over time. It is fed by the Event watcher,
+// so Events are not listed again on each evaluation.
+// Occurrences of an Event already seen several times when first observed are all
+// attributed to its last occurrence. When more than maxEventSamples are kept for an
+// Event, oldest ones are merged, so counts are approximate for Events occurring
+// continuously.
+type eventCounter struct {
+	mu sync.Mutex
+	// records contains occurrences per Event
+	// Key: Event UID
+	records map[types.UID]*eventRecord
+}
+
+func newEventCounter() *eventCounter {
+	return &eventCounter{records: make(map[types.UID]*eventRecord)}
+}
+
+// record adds occurrences of event observed since it was last recorded
+func (c *eventCounter) record(event *corev1.Event, now time.Time) {
+	count := getEventCount(event)
+	timestamp := getEventTime(event)
+	if timestamp.Before(now.Add(-MaxEventWindow)) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r, ok := c.records[event.UID]
+	if !ok {
+		r = &eventRecord{
+			namespace: event.Namespace,
+			eventType: event.Type,
+			reason:    event.Reason,
+			kind:      event.InvolvedObject.Kind,
+		}
+		c.records[event.UID] = r
+	}
+	if count <= r.count {
+		return
+	}
+
+	r.samples = append(r.samples, eventSample{timestamp: timestamp, count: count - r.count})
+	r.count = count
+	if len(r.samples) > maxEventSamples {
+		r.samples[1].count += r.samples[0].count
+		r.samples = r.samples[1:]
+	}
+}
+
+// count returns the number of occurrences, within constraint window, of Events matching
+// constraint and the time the oldest of those occurred. Occurrences older than
+// MaxEventWindow are dropped.
+func (c *eventCounter) count(constraint *EventConstraint, now time.Time) (count int, oldest time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cutoff := now.Add(-MaxEventWindow)
+	windowStart := now.Add(-constraint.Window.Duration)
+	for uid, r := range c.records {
+		drop := 0
+		for drop < len(r.samples) && r.samples[drop].timestamp.Before(cutoff) {
+			drop++
+		}
+		r.samples = r.samples[drop:]
+		if len(r.samples) == 0 {
+			delete(c.records, uid)
+			continue
+		}
+
+		if !r.isAMatch(constraint) {
+			continue
+		}
+		for i := range r.samples {
+			if r.samples[i].timestamp.Before(windowStart) {
+				continue
+			}
+			count += int(r.samples[i].count)
+			if oldest.IsZero() || r.samples[i].timestamp.Before(oldest) {
+				oldest = r.samples[i].timestamp
+			}
+		}
+	}
+	return count, oldest
+}
+
+func (r *eventRecord) isAMatch(constraint *EventConstraint) bool {
+	return (constraint.Type == "" || r.eventType == constraint.Type) &&
+		(constraint.Reason == "" || r.reason == constraint.Reason) &&
+		(constraint.Namespace == "" || r.namespace == constraint.Namespace) &&
+		(constraint.InvolvedObjectKind == "" || r.kind == constraint.InvolvedObjectKind)
+}
+
+// getEventCount returns the number of times event occurred
+func getEventCount(event *corev1.Event) int32 {
+	count := event.Count
+	if event.Series != nil && event.Series.Count > count {
+		count = event.Series.Count
+	}
+	if count < 1 {
+		count = 1
+	}
+	return count
+}
+
+// getEventTime returns the time event last occurred
+func getEventTime(event *corev1.Event) time.Time {
+	switch {
+	case event.Series != nil && !event.Series.LastObservedTime.IsZero():
+		return event.Series.LastObservedTime.Time
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	default:
+		return event.CreationTimestamp.Time
+	}
+}
+
+// recordEvent is invoked by the Event watcher when an Event is created or updated
+func (m *manager) recordEvent(obj interface{}) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	event := &corev1.Event{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.UnstructuredContent(), event); err != nil {
+		m.log.V(logs.LogDebug).Info(fmt.Sprintf("failed to convert event: %v", err))
+		return
+	}
+	m.events.record(event, time.Now())
+}
+
+// isWatchingEvents returns true if the Event watcher feeds the eventCounter
+func (m *manager) isWatchingEvents() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.watchers[eventGVK]
+	return ok
+}
+
+// areEventConstraintsAMatch returns true if all EventConstraints are satisfied.
+// Till the Event watcher is started, Events are listed on each evaluation.
+// Classifier is evaluated again when the oldest counted occurrence leaves the window,
+// as the verdict may change then.
+func (m *manager) areEventConstraintsAMatch(ctx context.Context,
+	classifier *libsveltosv1alpha1.Classifier) (bool, error) {
+
+	extension, err := getClassifierExtension(classifier)
+	if err != nil {
+		return false, err
+	}
+	if len(extension.EventConstraints) == 0 {
+		return true, nil
+	}
+
+	now := m.getClusterTime()
+	if !m.isWatchingEvents() {
+		events, err := m.listAllResources(ctx, classifier, eventGVK, nil)
+		if err != nil {
+			return false, err
+		}
+		for i := range events {
+			m.recordEvent(&events[i])
+		}
+	}
+
+	for i := range extension.EventConstraints {
+		c := &extension.EventConstraints[i]
+		if err := validateEventConstraint(c); err != nil {
+			return false, newInvalidClassifierError(fmt.Errorf("event constraint %d: %w", i, err))
+		}
+
+		count, oldest := m.events.count(c, now)
+		addEvaluationNote(ctx, fmt.Sprintf("event constraint %d: %d occurrences in the last %s",
+			i, count, c.Window.Duration))
+		if !oldest.IsZero() {
+			m.scheduleEvaluation(classifier.Name, oldest.Add(c.Window.Duration).Add(ageCrossingMargin),
+				fmt.Sprintf("event occurrence leaves event constraint %d window", i))
+		}
+		if (c.MinCount != nil && count < *c.MinCount) || (c.MaxCount != nil && count > *c.MaxCount) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: event constraints", func() {
+	var server *httptest.Server
+	var classifier *libsveltosv1alpha1.Classifier
+
+	BeforeEach(func() {
+		getEvent := func(uid, eventType, reason string, count int, age time.Duration) string {
+			return fmt.Sprintf(`{"apiVersion":"v1","kind":"Event","metadata":{"namespace":"default",`+
+				`"name":"%s","uid":"%s"},"involvedObject":{"kind":"Pod","name":"web"},"type":"%s",`+
+				`"reason":"%s","count":%d,"lastTimestamp":"%s"}`, uid, uid, eventType, reason, count,
+				time.Now().Add(-age).UTC().Format(time.RFC3339))
+		}
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			switch r.URL.Path {
+			case "/api":
+				_, _ = w.Write([]byte(`{"kind":"APIVersions","versions":["v1"]}`))
+			case "/api/v1":
+				_, _ = w.Write([]byte(`{"kind":"APIResourceList","groupVersion":"v1","resources":[` +
+					`{"name":"events","singularName":"event","namespaced":true,"kind":"Event","verbs":["list"]}]}`))
+			case "/apis":
+				_, _ = w.Write([]byte(`{"kind":"APIGroupList","apiVersion":"v1","groups":[]}`))
+			case "/api/v1/events":
+				_, _ = w.Write([]byte(`{"apiVersion":"v1","kind":"EventList","metadata":{},"items":[` +
+					getEvent("a", corev1.EventTypeWarning, "FailedScheduling", 3, 10*time.Minute) + `,` +
+					getEvent("b", corev1.EventTypeWarning, "FailedScheduling", 2, 2*time.Hour) + `,` +
+					getEvent("c", corev1.EventTypeWarning, "BackOff", 5, time.Minute) + `,` +
+					getEvent("d", corev1.EventTypeNormal, "Scheduled", 1, time.Minute) + `]}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+
+		classifier = &libsveltosv1alpha1.Classifier{ObjectMeta: metav1.ObjectMeta{Name: randomString()}}
+
+		classification.InitializeManagerWithObjects(&rest.Config{Host: server.URL})
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	evaluate := func(eventConstraints string) (bool, []string, error) {
+		classifier.Annotations = map[string]string{
+			classification.ClassifierExtensionAnnotation: "eventConstraints:\n" + eventConstraints,
+		}
+		return classification.AreEventConstraintsAMatch(classification.GetManager(), classifier)
+	}
+
+	It("areEventConstraintsAMatch counts occurrences within window", func() {
+		isMatch, notes, err := evaluate(`- {type: Warning, reason: FailedScheduling, window: 1h, minCount: 3}`)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+		Expect(notes).To(ContainElement("event constraint 0: 3 occurrences in the last 1h0m0s"))
+
+		isMatch, notes, err = evaluate(`- {type: Warning, reason: FailedScheduling, window: 3h, minCount: 6}`)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeFalse())
+		Expect(notes).To(ContainElement("event constraint 0: 5 occurrences in the last 3h0m0s"))
+
+		isMatch, _, err = evaluate(`- {type: Warning, involvedObjectKind: Pod, window: 1h, maxCount: 7}`)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeFalse())
+
+		isMatch, _, err = evaluate(`- {namespace: kube-system, window: 1h, maxCount: 0}`)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+	})
+
+	It("areEventConstraintsAMatch rejects invalid constraints", func() {
+		for _, constraint := range []string{
+			`- {type: Error, window: 1h, minCount: 1}`,
+			`- {window: 48h, minCount: 1}`,
+			`- {window: 1h}`,
+			`- {window: 1h, minCount: 2, maxCount: 1}`,
+		} {
+			_, _, err := evaluate(constraint)
+			Expect(err).ToNot(BeNil())
+			Expect(classification.IsInvalidClassifierError(err)).To(BeTrue())
+		}
+	})
+
+	It("eventCounter only counts new occurrences of updated Events", func() {
+		now := time.Now()
+		event := &corev1.Event{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web", UID: types.UID("web")},
+			Type:       corev1.EventTypeWarning,
+			Reason:     "FailedScheduling",
+			Count:      2,
+		}
+		event.LastTimestamp = metav1.NewTime(now.Add(-90 * time.Minute))
+		m := classification.GetManager()
+		classification.RecordEvent(m, event, now)
+
+		// Same occurrences observed again
+		classification.RecordEvent(m, event, now)
+
+		event.Count = 5
+		event.LastTimestamp = metav1.NewTime(now.Add(-time.Minute))
+		classification.RecordEvent(m, event, now)
+
+		minCount := 1
+		lastHour := &classification.EventConstraint{Reason: "FailedScheduling",
+			Window: metav1.Duration{Duration: time.Hour}, MinCount: &minCount}
+		Expect(classification.CountEvents(m, lastHour, now)).To(Equal(3))
+		lastTwoHours := &classification.EventConstraint{Reason: "FailedScheduling",
+			Window: metav1.Duration{Duration: 2 * time.Hour}, MinCount: &minCount}
+		Expect(classification.CountEvents(m, lastTwoHours, now)).To(Equal(5))
+
+		// Occurrences older than MaxEventWindow are forgotten
+		Expect(classification.CountEvents(m, lastHour,
+			now.Add(classification.MaxEventWindow))).To(Equal(0))
+	})
+})
@@ -19,14 +19,28 @@ package classification
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"sync"
 	"time"
 
 	"github.com/go-logr/logr"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
 	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/discovery"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2/klogr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/projectsveltos/classifier-agent/pkg/explanation"
 )
 
 // Those are used only for uts
@@ -36,6 +50,7 @@ var (
 	IsResourceAMatch           = (*manager).isResourceAMatch
 	CleanClassifierReport      = (*manager).cleanClassifierReport
 	CreateClassifierReport     = (*manager).createClassifierReport
+	RecordExplanation          = (*manager).recordExplanation
 	EvaluateClassifierInstance = (*manager).evaluateClassifierInstance
 	BuildList                  = (*manager).buildList
 	BuildSortedList            = (*manager).buildSortedList
@@ -47,18 +62,107 @@ var (
 	SendClassifierReport       = (*manager).sendClassifierReport
 )
 
+var (
+	IsReportCRDMissing = isReportCRDMissing
+	StorePendingReport = (*manager).storePendingReport
+	GetPendingReport   = (*manager).getPendingReport
+
+	RefreshRemoteClassifiers  = (*manager).refreshRemoteClassifiers
+	IsRemoteClassifierChanged = isRemoteClassifierChanged
+	GetClassifier             = (*manager).getClassifier
+)
+
+var (
+	GetClassifierExtension = getClassifierExtension
+	ProcessActions         = (*manager).processActions
+	UpdateMap              = updateMap
+	StartWatcherIfNeeded   = (*manager).startWatcherIfNeeded
+)
+
+var (
+	GetCELProgram              = (*manager).getCELProgram
+	EvaluateCELProgram         = evaluateCELProgram
+	CompileCELExpression       = compileCELExpression
+	FilterResources            = filterResources
+	IsResourceConstraintAMatch = (*manager).isResourceConstraintAMatch
+)
+
+var (
+	RecordEvaluation        = (*manager).recordEvaluation
+	RemoveEvaluationHistory = (*manager).removeEvaluationHistory
+	UpdateEvaluationSummary = (*manager).updateEvaluationSummary
+)
+
+var (
+	RunLuaScript = (*manager).runLuaScript
+)
+
+var (
+	AreResourcesAMatch     = (*manager).areResourcesAMatch
+	SetSnapshotListOptions = (*evaluationSnapshot).setListOptions
+	UpdateSnapshot         = (*evaluationSnapshot).update
+	IsSnapshotExpired      = isSnapshotExpired
+)
+
+type EvaluationSnapshot = evaluationSnapshot
+
+var (
+	NormalizeVersionRange    = normalizeVersionRange
+	ParseVersionRange        = parseVersionRange
+	IsInvalidClassifierError = isInvalidClassifierError
+	IsVersionGroupAMatch     = isVersionGroupAMatch
+	NormalizeVersion         = normalizeVersion
+	GetClusterVersion        = getClusterVersion
+
+	IsKubeletVersionSkewExceeded = isKubeletVersionSkewExceeded
+
+	IsBuiltInType      = isBuiltInType
+	ToUnstructuredList = toUnstructuredList
+	ListResources      = (*manager).listResources
+
+	GetMatchingResources   = (*manager).getMatchingResources
+	CountMatchingResources = (*manager).countMatchingResources
+)
+
+var (
+	ResolveField          = resolveField
+	AreFieldFiltersAMatch = areFieldFiltersAMatch
+)
+
+func GetCELProgramsCount(classifierName string) int {
+	cached, ok := managerInstance.celPrograms[classifierName]
+	if !ok {
+		return 0
+	}
+	return len(cached.programs)
+}
+
 func Reset() {
 	managerInstance = nil
 }
 
 func GetWatchers() map[schema.GroupVersionKind]context.CancelFunc {
-	return managerInstance.watchers
+	watchers := make(map[schema.GroupVersionKind]context.CancelFunc, len(managerInstance.watchers))
+	for gvk, w := range managerInstance.watchers {
+		watchers[gvk] = w.cancel
+	}
+	return watchers
 }
 
 func GetUnknownResourcesToWatch() []schema.GroupVersionKind {
 	return managerInstance.unknownResourcesToWatch
 }
 
+func ApplyOptions(options ...Option) {
+	for i := range options {
+		options[i](managerInstance)
+	}
+}
+
+func SetUnknownResourcesToWatch(gvks []schema.GroupVersionKind) {
+	managerInstance.unknownResourcesToWatch = gvks
+}
+
 func InitializeManagerWithSkip(ctx context.Context, l logr.Logger, config *rest.Config, c client.Client,
 	react ReactToNotification, intervalInSecond uint) {
 
@@ -72,6 +176,7 @@ func InitializeManagerWithSkip(ctx context.Context, l logr.Logger, config *rest.
 			managerInstance.jobQueue = make([]string, 0)
 			managerInstance.interval = time.Duration(intervalInSecond) * time.Second
 			managerInstance.mu = &sync.Mutex{}
+			managerInstance.evaluationMu = &sync.Mutex{}
 
 			managerInstance.resourcesToWatch = make([]schema.GroupVersionKind, 0)
 			managerInstance.rebuildResourceToWatch = 0
@@ -79,7 +184,48 @@ func InitializeManagerWithSkip(ctx context.Context, l logr.Logger, config *rest.
 
 			managerInstance.unknownResourcesToWatch = make([]schema.GroupVersionKind, 0)
 
-			managerInstance.watchers = make(map[schema.GroupVersionKind]context.CancelFunc)
+			managerInstance.watchers = make(map[schema.GroupVersionKind]*watcher)
+
+			managerInstance.actionMatchesMu = &sync.Mutex{}
+			managerInstance.actionMatches = make(map[string]bool)
+			managerInstance.celMu = &sync.Mutex{}
+			managerInstance.celPrograms = make(map[string]*classifierPrograms)
+
+			managerInstance.historyMu = &sync.RWMutex{}
+			managerInstance.historySize = DefaultEvaluationHistorySize
+			managerInstance.history = make(map[string]*evaluationHistory)
+			managerInstance.latest = make(map[string]EvaluationResult)
+			managerInstance.versionMatching = VersionMatchingStrict
+			managerInstance.cloudProvidersMu = &sync.Mutex{}
+			managerInstance.broadConstraintPolicy = BroadConstraintAllow
+			managerInstance.broadConstraintThreshold = DefaultBroadConstraintThreshold
+			managerInstance.deferredMu = &sync.Mutex{}
+			managerInstance.deferred = make(map[string]time.Time)
+			managerInstance.explanationsMu = &sync.Mutex{}
+			managerInstance.explanations = make(map[string]string)
+			managerInstance.hookAnnotationsMu = &sync.Mutex{}
+			managerInstance.hookAnnotations = make(map[string]map[string]string)
+			managerInstance.pendingReportsMu = &sync.Mutex{}
+			managerInstance.pendingReports = make(map[string]*libsveltosv1alpha1.ClassifierReport)
+			managerInstance.remoteMu = &sync.RWMutex{}
+			managerInstance.remoteClassifiers = make(map[string]*libsveltosv1alpha1.Classifier)
+			managerInstance.trends = newTrendStore(DefaultTrendRetention)
+			managerInstance.events = newEventCounter()
+			managerInstance.tuningMu = &sync.RWMutex{}
+			managerInstance.tuningAuthenticator = managerInstance.authenticateTuningRequest
+			managerInstance.discoveryMu = &sync.Mutex{}
+			managerInstance.agentMu = &sync.Mutex{}
+			managerInstance.ageMu = &sync.Mutex{}
+			managerInstance.ageEvaluations = make(map[string]*ageEvaluation)
+			managerInstance.overloadMu = &sync.Mutex{}
+			managerInstance.healthMu = &sync.Mutex{}
+			managerInstance.listRetries = DefaultListRetries
+			managerInstance.listRetryDelay = DefaultListRetryDelay
+			managerInstance.clockSkewMu = &sync.Mutex{}
+			managerInstance.factMu = &sync.Mutex{}
+			managerInstance.factRefreshes = make(map[string]time.Time)
+			managerInstance.rbacMu = &sync.Mutex{}
+			managerInstance.effectiveRBAC = make(map[string][]AccessRecord)
 
 			managerInstance.react = react
 
@@ -90,3 +236,590 @@ func InitializeManagerWithSkip(ctx context.Context, l logr.Logger, config *rest.
 		}
 	}
 }
+
+// InitializeManagerWithObjects resets the manager and initializes it with a fake client
+// containing objects. When config is set, discovery and unstructured lists are served by it.
+func InitializeManagerWithObjects(config *rest.Config, objects ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(libsveltosv1alpha1.AddToScheme(scheme))
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(apiextensionsv1.AddToScheme(scheme))
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+	Reset()
+	InitializeManagerWithSkip(context.TODO(), klogr.New(), config, c, nil, 10)
+	return c
+}
+
+// EvaluateMatchStage runs the isClassifierAMatch stage recording check and returns
+// the evaluation notes
+func EvaluateMatchStage(m *manager, check explanation.CheckType,
+	classifier *libsveltosv1alpha1.Classifier) (bool, []string, error) {
+
+	for _, stage := range m.getMatchStages() {
+		if stage.check == check {
+			ctx, notes := withEvaluationNotes(context.TODO())
+			isMatch, err := stage.isAMatch(ctx, classifier)
+			return isMatch, notes.get(), err
+		}
+	}
+	return false, nil, fmt.Errorf("no evaluation stage records %s", check)
+}
+
+func SetListPageSize(size int64) {
+	listPageSize = size
+}
+
+func SetBroadConstraintPolicy(policy BroadConstraintPolicy, threshold int) {
+	WithBroadConstraintPolicy(policy, threshold)(managerInstance)
+}
+
+var (
+	DeferClassifier = (*manager).deferClassifier
+	IsDeferred      = (*manager).isDeferred
+)
+
+var (
+	AdaptInterval = adaptInterval
+)
+
+func GetNextInterval(current, elapsed time.Duration) time.Duration {
+	return managerInstance.getNextInterval(current, elapsed)
+}
+
+func RecordWatchEvents(events int) {
+	for i := 0; i < events; i++ {
+		managerInstance.recordWatchEvent()
+	}
+}
+
+var (
+	DetectCloudProviders  = detectCloudProviders
+	IsCloudProviderAMatch = (*manager).isCloudProviderAMatch
+	AreFactsAMatch        = (*manager).areFactsAMatch
+)
+
+var (
+	WithCostTracking = withCostTracking
+	WithCostTracker  = withCostTracker
+)
+
+type CostTracker = costTracker
+
+func GetCost(tracker *CostTracker) *EvaluationCost {
+	return tracker.getCost(0)
+}
+
+var (
+	WithEvaluationBudget         = withEvaluationBudget
+	CheckEvaluationBudget        = checkEvaluationBudget
+	IsBudgetExceededError        = isBudgetExceededError
+	MarkClassifierReportDegraded = (*manager).markClassifierReportDegraded
+)
+
+var (
+	DecodeHelmRelease             = decodeHelmRelease
+	IsHelmReleaseConstraintAMatch = isHelmReleaseConstraintAMatch
+
+	IsCRDConstraintAMatch = isCRDConstraintAMatch
+	UsesCRDGroup          = usesCRDGroup
+
+	IsAPIResourceConstraintAMatch = isAPIResourceConstraintAMatch
+	GetRESTMapper                 = (*manager).getRESTMapper
+	InvalidateDiscoveryCache      = (*manager).invalidateDiscoveryCache
+	CRDGVK                        = crdGVK
+	IsAPIServiceAvailable         = isAPIServiceAvailable
+
+	GetCountStopCondition        = getCountStopCondition
+	GetPercentageStopCondition   = getPercentageStopCondition
+	IsPercentageAMatch           = isPercentageAMatch
+	ValidatePercentageConstraint = validatePercentageConstraint
+
+	CompileRegex              = compileRegex
+	GetRegex                  = (*manager).getRegex
+	FilterResourcesWithLabels = filterResourcesWithLabels
+
+	GetLabelSelector = getLabelSelector
+
+	WrapClusterTypeError = wrapClusterTypeError
+
+	AreFieldFiltersAMatchWithElements = areFieldFiltersAMatchWithElements
+	GetFieldSelectorsForFilters       = getFieldSelectorsForFilters
+
+	NewTrendStore           = newTrendStore
+	TrendStoreAdd           = (*trendStore).add
+	TrendStoreCountAt       = (*trendStore).countAt
+	TrendStoreRemove        = (*trendStore).remove
+	IsTrendAMatch           = isTrendAMatch
+	ValidateTrendConstraint = validateTrendConstraint
+	GetTrendKey             = getTrendKey
+
+	SyncAllNodeLabels = (*manager).syncAllNodeLabels
+)
+
+// filterResourcesWithMissingFields filters resources using field filters and
+// missing field policies. Returns matching resources, the number of skipped
+// resources and the evaluation notes.
+func filterResourcesWithMissingFields(resources []unstructured.Unstructured,
+	fieldFilters []libsveltosv1alpha1.FieldFilter, policies map[string]MissingFieldPolicy,
+) ([]unstructured.Unstructured, int, []string, error) {
+
+	matchers, err := getFieldFilterMatchers(fieldFilters, compileRegex)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	if err := applyMissingFieldPolicies(matchers, policies); err != nil {
+		return nil, 0, nil, err
+	}
+	query := &resourceQuery{gvk: schema.GroupVersionKind{Version: "v1", Kind: "Pod"}, fieldMatchers: matchers}
+	items, err := query.filter(resources)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	ctx, notes := withEvaluationNotes(context.TODO())
+	query.addMissingFieldNotes(ctx)
+	return items, query.skipped, notes.get(), nil
+}
+
+var FilterResourcesWithMissingFields = filterResourcesWithMissingFields
+
+type HelmRelease = helmRelease
+
+// filterResourcesWithLabels filters resources using label filters with a regex operation
+func filterResourcesWithLabels(resources []unstructured.Unstructured,
+	labelFilters []libsveltosv1alpha1.LabelFilter) ([]unstructured.Unstructured, error) {
+
+	matchers, err := getLabelFilterMatchers(labelFilters, compileRegex)
+	if err != nil {
+		return nil, err
+	}
+	query := &resourceQuery{labelMatchers: matchers}
+	return query.filter(resources)
+}
+
+// FilterResourcesWithAnnotations filters resources using annotation filters
+func FilterResourcesWithAnnotations(resources []unstructured.Unstructured,
+	annotationFilters []libsveltosv1alpha1.LabelFilter) ([]unstructured.Unstructured, error) {
+
+	matchers, err := getAnnotationFilterMatchers(annotationFilters, compileRegex)
+	if err != nil {
+		return nil, err
+	}
+	query := &resourceQuery{annotationMatchers: matchers}
+	return query.filter(resources)
+}
+
+// FilterResourcesWithOwners filters resources using owner filters
+func FilterResourcesWithOwners(resources []unstructured.Unstructured,
+	ownerFilters []OwnerFilter) ([]unstructured.Unstructured, error) {
+
+	if err := validateOwnerFilters(ownerFilters); err != nil {
+		return nil, err
+	}
+	query := &resourceQuery{ownerFilters: ownerFilters}
+	return query.filter(resources)
+}
+
+// FilterResourcesWithConditions filters resources using condition filters
+func FilterResourcesWithConditions(resources []unstructured.Unstructured,
+	conditionFilters []ConditionFilter) ([]unstructured.Unstructured, error) {
+
+	if err := validateConditionFilters(conditionFilters); err != nil {
+		return nil, err
+	}
+	query := &resourceQuery{conditionFilters: conditionFilters}
+	return query.filter(resources)
+}
+
+// FilterPodsWithRestartCount filters Pods using a restart count filter
+func FilterPodsWithRestartCount(pods []unstructured.Unstructured,
+	filter *RestartCountFilter) ([]unstructured.Unstructured, error) {
+
+	constraint := &ResourceConstraint{RestartCount: filter}
+	constraint.Version = "v1"
+	constraint.Kind = "Pod"
+	if err := validateRestartCountFilter(constraint); err != nil {
+		return nil, err
+	}
+	query := &resourceQuery{restartCount: filter}
+	return query.filter(pods)
+}
+
+// FilterReconciledResources filters out resources which are not reconciled
+func FilterReconciledResources(resources []unstructured.Unstructured) ([]unstructured.Unstructured, error) {
+	query := &resourceQuery{reconciledOnly: true}
+	return query.filter(resources)
+}
+
+// areFieldFiltersAMatchWithElements is like areFieldFiltersAMatch, with
+// element match policies applied
+func areFieldFiltersAMatchWithElements(object map[string]interface{},
+	filters []libsveltosv1alpha1.FieldFilter, policies map[string]ElementMatchPolicy) (bool, error) {
+
+	matchers, err := getFieldFilterMatchers(filters, compileRegex)
+	if err != nil {
+		return false, err
+	}
+	if err := applyElementMatchPolicies(matchers, policies); err != nil {
+		return false, err
+	}
+	return areFieldFilterMatchersAMatch(object, matchers)
+}
+
+// getFieldSelectorsForFilters returns the field selectors for filters and
+// the number of filters left to be evaluated by classifier-agent
+func getFieldSelectorsForFilters(gk schema.GroupKind, filters []libsveltosv1alpha1.FieldFilter,
+	policies map[string]MissingFieldPolicy) (string, int, error) {
+
+	matchers, err := getFieldFilterMatchers(filters, compileRegex)
+	if err != nil {
+		return "", 0, err
+	}
+	if err := applyMissingFieldPolicies(matchers, policies); err != nil {
+		return "", 0, err
+	}
+	selectors, remaining := getFieldSelectors(gk, matchers)
+	return joinFieldSelectors(selectors...), len(remaining), nil
+}
+
+var (
+	AuthenticateTuningRequest = (*manager).authenticateTuningRequest
+)
+
+// SetTuningAuthenticator replaces the tuning endpoint authenticator. Each request
+// is authenticated as user, or rejected if user is empty.
+func SetTuningAuthenticator(user string) {
+	managerInstance.tuningAuthenticator = func(ctx context.Context, r *http.Request) (string, error) {
+		if user == "" {
+			return "", fmt.Errorf("not authenticated")
+		}
+		return user, nil
+	}
+}
+
+var (
+	VerifyConformance = (*manager).verifyConformance
+)
+
+var (
+	GetKubeletConsensusVersion = getKubeletConsensusVersion
+)
+
+// EvaluateConstraintExpression parses expression and evaluates it with results as
+// resource constraint outcomes. Returns the indexes of the evaluated constraints.
+func EvaluateConstraintExpression(expression string, results []bool) (isMatch bool, evaluated []int, err error) {
+	parsed, err := parseConstraintExpression(expression, len(results))
+	if err != nil {
+		return false, nil, err
+	}
+	isMatch, err = parsed.evaluate(func(index int) (bool, error) {
+		evaluated = append(evaluated, index)
+		return results[index], nil
+	})
+	return isMatch, evaluated, err
+}
+
+var (
+	GetImageTag      = getImageTag
+	GetClusterDomain = getClusterDomain
+	GetAgentFacts    = (*manager).getAgentFacts
+)
+
+// EvaluateMustNotExistConstraint evaluates constraint and returns the evaluation notes
+func EvaluateMustNotExistConstraint(m *manager, constraint *ResourceConstraint) (bool, []string, error) {
+	ctx, notes := withEvaluationNotes(context.TODO())
+	isMatch, err := m.evaluateResourceConstraint(ctx, nil, constraint, nil)
+	return isMatch, notes.get(), err
+}
+
+var (
+	ReconcileWatchers = (*manager).reconcileWatchers
+	StopWatcher       = (*manager).stopWatcher
+)
+
+// AddTestWatcher registers a watcher for gvk. When exitOnCancel is set, watcher exits
+// when cancelled. Watcher always exits when the returned function is called.
+func AddTestWatcher(gvk schema.GroupVersionKind, exitOnCancel bool) (exit func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	w := newWatcher(cancel)
+	exitCh := make(chan struct{})
+	go func() {
+		defer close(w.done)
+		if exitOnCancel {
+			select {
+			case <-ctx.Done():
+			case <-exitCh:
+			}
+			return
+		}
+		<-exitCh
+	}()
+	managerInstance.watchers[gvk] = w
+	return func() { close(exitCh) }
+}
+
+func SetResourcesToWatch(gvks []schema.GroupVersionKind) {
+	managerInstance.resourcesToWatch = gvks
+}
+
+func GetStoppingWatchers() int {
+	return len(managerInstance.stoppingWatchers)
+}
+
+func GetZombieWatchers() float64 {
+	return testutil.ToFloat64(zombieWatchers)
+}
+
+// IsRatioConstraintAMatch evaluates ratio and returns the evaluation notes
+func IsRatioConstraintAMatch(m *manager, classifier *libsveltosv1alpha1.Classifier,
+	ratio *RatioConstraint) (bool, []string, error) {
+
+	ctx, notes := withEvaluationNotes(context.TODO())
+	isMatch, err := m.isRatioConstraintAMatch(ctx, classifier, ratio, nil)
+	return isMatch, notes.get(), err
+}
+
+// EvaluateCardinalityConstraint evaluates constraint and returns the evaluation notes
+func EvaluateCardinalityConstraint(m *manager, constraint *ResourceConstraint) (bool, []string, error) {
+	ctx, notes := withEvaluationNotes(context.TODO())
+	isMatch, err := m.evaluateResourceConstraint(ctx, nil, constraint, nil)
+	return isMatch, notes.get(), err
+}
+
+// IsCardinalityAMatch evaluates constraint on items and returns the evaluation notes
+func IsCardinalityAMatch(constraint *ResourceConstraint, items []unstructured.Unstructured) (bool, []string, error) {
+	ctx, notes := withEvaluationNotes(context.TODO())
+	isMatch, err := isCardinalityAMatch(ctx, constraint, items)
+	return isMatch, notes.get(), err
+}
+
+// GetAgeEvaluation returns when classifier is scheduled to be evaluated again
+// because of an age bound
+func GetAgeEvaluation(classifierName string) (time.Time, bool) {
+	managerInstance.ageMu.Lock()
+	defer managerInstance.ageMu.Unlock()
+	evaluation, ok := managerInstance.ageEvaluations[classifierName]
+	if !ok {
+		return time.Time{}, false
+	}
+	return evaluation.due, true
+}
+
+func GetJobQueue() []string {
+	managerInstance.mu.Lock()
+	defer managerInstance.mu.Unlock()
+	return append([]string(nil), managerInstance.jobQueue...)
+}
+
+var (
+	SetOverload          = (*manager).setOverload
+	RefreshAgentDegraded = (*manager).refreshAgentDegraded
+)
+
+var (
+	CheckClusterHealth = (*manager).checkClusterHealth
+	IsClusterHealthy   = (*manager).isClusterHealthy
+)
+
+var (
+	GetReportShard = (*manager).getReportShard
+
+	ValidateRestartCountFilter = validateRestartCountFilter
+)
+
+// AreAPIServerFeaturesAMatch evaluates classifier API server features and returns
+// the evaluation notes
+func AreAPIServerFeaturesAMatch(m *manager, classifier *libsveltosv1alpha1.Classifier) (bool, []string, error) {
+	ctx, notes := withEvaluationNotes(context.TODO())
+	isMatch, err := m.areAPIServerFeaturesAMatch(ctx, classifier)
+	return isMatch, notes.get(), err
+}
+
+// AreNodeConstraintsAMatch evaluates classifier node constraints and returns the
+// evaluation notes
+func AreNodeConstraintsAMatch(m *manager, classifier *libsveltosv1alpha1.Classifier) (bool, []string, error) {
+	extension, err := getClassifierExtension(classifier)
+	if err != nil {
+		return false, nil, err
+	}
+	ctx, notes := withEvaluationNotes(context.TODO())
+	isMatch, err := m.areNodeConstraintsAMatch(ctx, classifier, extension, nil)
+	return isMatch, notes.get(), err
+}
+
+// AreCapacityConstraintsAMatch evaluates classifier capacity constraints and returns the
+// evaluation notes
+func AreCapacityConstraintsAMatch(m *manager, classifier *libsveltosv1alpha1.Classifier) (bool, []string, error) {
+	extension, err := getClassifierExtension(classifier)
+	if err != nil {
+		return false, nil, err
+	}
+	ctx, notes := withEvaluationNotes(context.TODO())
+	isMatch, err := m.areCapacityConstraintsAMatch(ctx, classifier, extension, nil)
+	return isMatch, notes.get(), err
+}
+
+var (
+	RecordFactRefresh = (*manager).recordFactRefresh
+)
+
+// SetManagementCluster makes manager send ClassifierReports of cluster clusterNamespace/clusterName
+// to c, an in-memory management cluster, instead of using the kubeconfig Secret
+func SetManagementCluster(c client.Client, clusterNamespace, clusterName string,
+	clusterType libsveltosv1alpha1.ClusterType) {
+
+	managerInstance.managementClient = c
+	managerInstance.sendReport = true
+	managerInstance.clusterNamespace = clusterNamespace
+	managerInstance.clusterName = clusterName
+	managerInstance.clusterType = clusterType
+}
+
+var (
+	GetConstraintKey           = getConstraintKey
+	EvaluateResourceConstraint = (*manager).evaluateResourceConstraint
+)
+
+// WithMatchCache returns a context sharing matched resources, as Classifiers evaluated
+// in the same cycle do
+func WithMatchCache(m *manager) context.Context {
+	return m.withMatchCache(context.TODO())
+}
+
+// AreUtilizationConstraintsAMatch evaluates classifier utilization constraints and
+// returns the evaluation notes
+func AreUtilizationConstraintsAMatch(m *manager, classifier *libsveltosv1alpha1.Classifier) (bool, []string, error) {
+	ctx, notes := withEvaluationNotes(context.TODO())
+	isMatch, err := m.areUtilizationConstraintsAMatch(ctx, classifier)
+	return isMatch, notes.get(), err
+}
+
+// ArePrometheusConstraintsAMatch evaluates classifier prometheus constraints and
+// returns the evaluation notes
+func ArePrometheusConstraintsAMatch(m *manager, classifier *libsveltosv1alpha1.Classifier) (bool, []string, error) {
+	ctx, notes := withEvaluationNotes(context.TODO())
+	isMatch, err := m.arePrometheusConstraintsAMatch(ctx, classifier)
+	return isMatch, notes.get(), err
+}
+
+// NewHandshakeManager returns a manager, not registered as the singleton, which
+// only coordinates with other instances via the handshake Lease
+func NewHandshakeManager(c client.Client, namespace, identity string, leaseDuration time.Duration) *manager {
+	m := &manager{log: logr.Discard(), Client: c, evaluationMu: &sync.Mutex{}}
+	WithUpgradeHandshake(namespace, identity, leaseDuration)(m)
+	return m
+}
+
+var (
+	SyncHandshake    = (*manager).syncHandshake
+	ReleaseHandshake = (*manager).releaseHandshake
+	HoldsHandshake   = (*manager).holdsHandshake
+)
+
+func IsHandingOverHandshake(m *manager) bool {
+	return m.handshake.getState() == handshakeHandingOver
+}
+
+// AreEventConstraintsAMatch evaluates classifier event constraints and returns the
+// evaluation notes
+func AreEventConstraintsAMatch(m *manager, classifier *libsveltosv1alpha1.Classifier) (bool, []string, error) {
+	ctx, notes := withEvaluationNotes(context.TODO())
+	isMatch, err := m.areEventConstraintsAMatch(ctx, classifier)
+	return isMatch, notes.get(), err
+}
+
+func RecordEvent(m *manager, event *corev1.Event, now time.Time) {
+	m.events.record(event, now)
+}
+
+func CountEvents(m *manager, constraint *EventConstraint, now time.Time) int {
+	count, _ := m.events.count(constraint, now)
+	return count
+}
+
+var (
+	GetAccessRecord        = getAccessRecord
+	RecordEffectiveRBAC    = (*manager).recordEffectiveRBAC
+	SetReportEffectiveRBAC = (*manager).setReportEffectiveRBAC
+)
+
+// WithAccessRecorder returns a context whose API server accesses are recorded, and
+// a function returning those
+func WithAccessRecorder(ctx context.Context) (context.Context, func() []AccessRecord) {
+	tracker := &costTracker{access: newAccessRecorder()}
+	return withCostTracker(ctx, tracker), tracker.access.list
+}
+
+// WithAccessCostTracker returns a context whose API server cost and accesses are
+// recorded by the returned tracker
+func WithAccessCostTracker(ctx context.Context) (context.Context, *CostTracker) {
+	tracker := &costTracker{access: newAccessRecorder()}
+	return withCostTracker(ctx, tracker), tracker
+}
+
+func GetAccesses(tracker *CostTracker) []AccessRecord {
+	return tracker.access.list()
+}
+
+// AreConfigDataConstraintsAMatch evaluates classifier config data constraints and returns
+// the evaluation notes
+func AreConfigDataConstraintsAMatch(m *manager, classifier *libsveltosv1alpha1.Classifier) (bool, []string, error) {
+	ctx, notes := withEvaluationNotes(context.TODO())
+	isMatch, err := m.areConfigDataConstraintsAMatch(ctx, classifier)
+	return isMatch, notes.get(), err
+}
+
+// AreMetricConstraintsAMatch evaluates classifier metric constraints and returns the
+// evaluation notes
+func AreMetricConstraintsAMatch(m *manager, classifier *libsveltosv1alpha1.Classifier) (bool, []string, error) {
+	ctx, notes := withEvaluationNotes(context.TODO())
+	isMatch, err := m.areMetricConstraintsAMatch(ctx, classifier)
+	return isMatch, notes.get(), err
+}
+
+var IsTransientError = isTransientError
+
+var GetKubeadmAPIServerExtraArgs = getKubeadmAPIServerExtraArgs
+
+func GetBehavior() (pollInterval time.Duration, summary bool) {
+	return managerInstance.pollInterval, managerInstance.evaluationSummary
+}
+
+var (
+	RunPreEvaluationHooks  = (*manager).runPreEvaluationHooks
+	RunPostEvaluationHooks = (*manager).runPostEvaluationHooks
+)
+
+// AreCertificateExpirationsAMatch evaluates classifier certificate expiration constraints
+// and returns the evaluation notes
+func AreCertificateExpirationsAMatch(m *manager, classifier *libsveltosv1alpha1.Classifier) (bool, []string, error) {
+	ctx, notes := withEvaluationNotes(context.TODO())
+	isMatch, err := m.areCertificateExpirationsAMatch(ctx, classifier)
+	return isMatch, notes.get(), err
+}
+
+var (
+	GetCycleDeadline        = (*manager).getCycleDeadline
+	IsCycleDeadlineExceeded = isCycleDeadlineExceeded
+	CarryOverClassifiers    = (*manager).carryOverClassifiers
+)
+
+var (
+	GetClassifierDuplicates = getClassifierDuplicates
+	SetReportDuplicates     = (*manager).setReportDuplicates
+)
+
+var (
+	DetectClockSkew    = (*manager).detectClockSkew
+	SetClockSkew       = (*manager).setClockSkew
+	GetClusterTime     = (*manager).getClusterTime
+	SetReportClockSkew = (*manager).setReportClockSkew
+)
+
+var WriteStateFile = (*manager).writeStateFile
+
+func SetDiscoveryClient(m *manager, dc discovery.CachedDiscoveryInterface) {
+	m.discoveryClient = dc
+}
@@ -0,0 +1,700 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"bytes"
+	"fmt"
+
+	"emperror.dev/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/yaml"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+const (
+	// ClassifierExtensionAnnotation is the annotation a Classifier can use to
+	// specify settings only consumed by the classifier-agent.
+	// Value is expected to be a ClassifierExtension in YAML or JSON format.
+	ClassifierExtensionAnnotation = "classifier.projectsveltos.io/extension"
+)
+
+// ClassifierExtension contains Classifier settings evaluated only by
+// classifier-agent running in the managed cluster.
+// Those settings are not part of libsveltos Classifier Spec.
+type ClassifierExtension struct {
+	// Actions lists the actions to take in the managed cluster
+	// when Classifier is a match
+	// +optional
+	Actions []MatchAction `json:"actions,omitempty"`
+
+	// DeployedResourceConstraints are evaluated in addition to the
+	// DeployedResourceConstraints in the Classifier Spec.
+	// All constraints must be satisfied for cluster to be a match, unless
+	// DeployedResourceConstraintsExpression is set.
+	// +optional
+	DeployedResourceConstraints []ResourceConstraint `json:"deployedResourceConstraints,omitempty"`
+
+	// DeployedResourceConstraintsExpression, when set, combines resource constraints
+	// with a boolean expression instead of requiring all of them, for instance
+	// "c1 && (c2 || !c3)". Constraints are referenced as c1..cN: the ones in the
+	// Classifier Spec first, followed by the ones in DeployedResourceConstraints.
+	// Supported operators are &&, || and !, with the usual precedence, and parentheses.
+	// Constraints not referenced are not evaluated.
+	// +optional
+	DeployedResourceConstraintsExpression string `json:"deployedResourceConstraintsExpression,omitempty"`
+
+	// RatioConstraints bound the ratio between the number of resources of two
+	// selections, for instance the percentage of Nodes which are Ready.
+	// Those are evaluated after DeployedResourceConstraints and all must be
+	// satisfied for cluster to be a match.
+	// +optional
+	RatioConstraints []RatioConstraint `json:"ratioConstraints,omitempty"`
+
+	// NodeConstraints bound the number of Nodes with given labels, conditions,
+	// taints and advertised resources, and the resources allocatable on them, for
+	// instance GPUs. Classifier is evaluated again when Nodes change.
+	// All constraints must be satisfied for cluster to be a match.
+	// +optional
+	NodeConstraints []NodeConstraint `json:"nodeConstraints,omitempty"`
+
+	// CapacityConstraints bound the sum of cpu, memory or any other resource
+	// allocatable on all Nodes and requested by all Pods, for instance to classify
+	// large or over-committed clusters. Classifier is evaluated again when Nodes
+	// or Pods change. All constraints must be satisfied for cluster to be a match.
+	// +optional
+	CapacityConstraints []CapacityConstraint `json:"capacityConstraints,omitempty"`
+
+	// UtilizationConstraints bound the live cpu or memory utilization of Nodes or
+	// Pods reported by metrics-server. Those are evaluated after all other
+	// constraints and are not satisfied when metrics.k8s.io is not available.
+	// Classifier is evaluated again when the selected Nodes or Pods change.
+	// +optional
+	UtilizationConstraints []UtilizationConstraint `json:"utilizationConstraints,omitempty"`
+
+	// EventConstraints bound the number of Kubernetes Event occurrences within a
+	// time window, for instance Warning Events with reason FailedScheduling in the
+	// last hour. Classifier is evaluated again when Events change and when counted
+	// occurrences leave the window. All constraints must be satisfied for cluster
+	// to be a match.
+	// +optional
+	EventConstraints []EventConstraint `json:"eventConstraints,omitempty"`
+
+	// PrometheusConstraints compare the results of PromQL queries, run against the
+	// Prometheus classifier-agent is configured with, with thresholds. Those are not
+	// satisfied when no Prometheus is configured. Classifier is evaluated again
+	// periodically. All constraints must be satisfied for cluster to be a match.
+	// +optional
+	PrometheusConstraints []PrometheusConstraint `json:"prometheusConstraints,omitempty"`
+
+	// MetricConstraints compare numeric facts, published by MetricsProviders such as
+	// sidecars, with thresholds.
+	// All constraints must be satisfied for cluster to be a match.
+	// +optional
+	MetricConstraints []MetricConstraint `json:"metricConstraints,omitempty"`
+
+	// HostResourceConstraints are evaluated against the cluster hosting the
+	// control plane of the managed cluster (hosted control planes such as Kamaji,
+	// vCluster or HyperShift), instead of the managed cluster. All must be
+	// satisfied, along with all other constraints, for the cluster to match.
+	// Those are never satisfied when classifier-agent has no host cluster kubeconfig.
+	// Resources matching those are not passed to LuaScript.
+	// +optional
+	HostResourceConstraints []ResourceConstraint `json:"hostResourceConstraints,omitempty"`
+
+	// LuaScript is a Lua script evaluated after all DeployedResourceConstraints
+	// are satisfied. Script must define a function evaluate(resources) returning
+	// a boolean. resources contains all resources matching any of the
+	// DeployedResourceConstraints. Cluster is a match only if evaluate returns true.
+	// +optional
+	LuaScript string `json:"luaScript,omitempty"`
+
+	// KubernetesVersionConstraints are evaluated in addition to the
+	// KubernetesVersionConstraints in the Classifier Spec.
+	// All constraints must be satisfied for cluster to be a match.
+	// +optional
+	KubernetesVersionConstraints []VersionConstraint `json:"kubernetesVersionConstraints,omitempty"`
+
+	// KubernetesVersionConstraintGroups allows expressing alternatives.
+	// Constraints within a group are ANDed, groups are ORed: at least one
+	// group must be satisfied for cluster to be a match.
+	// Groups are evaluated in addition to any other Kubernetes version constraint.
+	// +optional
+	KubernetesVersionConstraintGroups []VersionConstraintGroup `json:"kubernetesVersionConstraintGroups,omitempty"`
+
+	// KubeletVersionSkew, when set, requires the version skew between API server
+	// and node kubelets to exceed a threshold for cluster to be a match.
+	// Useful to detect clusters being upgraded or violating the supported skew policy.
+	// +optional
+	KubeletVersionSkew *KubeletVersionSkewConstraint `json:"kubeletVersionSkew,omitempty"`
+
+	// CloudProviders, when set, requires cluster to run on one of the listed
+	// cloud providers. Cloud provider is detected from Node spec.providerID
+	// scheme (aws, gce, azure, openstack, vsphere, digitalocean, kind...).
+	// +optional
+	CloudProviders []string `json:"cloudProviders,omitempty"`
+
+	// CNIs, when set, requires cluster to run one of the listed CNIs: calico,
+	// cilium, flannel or aws-vpc-cni. CNIs are detected from their well-known
+	// DaemonSets and CustomResourceDefinitions.
+	// +optional
+	CNIs []string `json:"cnis,omitempty"`
+
+	// ServiceMeshes, when set, requires cluster to run one of the listed service
+	// meshes: istio, linkerd or consul. Service meshes are detected from their control
+	// plane Deployments, CustomResourceDefinitions and namespace injection labels.
+	// +optional
+	ServiceMeshes []string `json:"serviceMeshes,omitempty"`
+
+	// ContainerRuntime, when set, constrains the container runtimes nodes run, for
+	// instance to detect clusters migrating from one runtime to another.
+	// +optional
+	ContainerRuntime *ContainerRuntimeConstraint `json:"containerRuntime,omitempty"`
+
+	// FactFilters match the static facts cluster admins declare in the
+	// FactsConfigMap, for instance region=eu-west-1 or tier=gold. Fact keys are
+	// matched as label keys, with the same operations as AnnotationFilters.
+	// Facts about classifier-agent environment, such as AgentImageTagFact, can
+	// be matched as well.
+	// All filters must be satisfied for cluster to be a match.
+	// +optional
+	FactFilters []libsveltosv1alpha1.LabelFilter `json:"factFilters,omitempty"`
+
+	// HelmReleaseConstraints require Helm charts to be deployed in the cluster.
+	// All constraints must be satisfied for cluster to be a match.
+	// +optional
+	HelmReleaseConstraints []HelmReleaseConstraint `json:"helmReleaseConstraints,omitempty"`
+
+	// OLMOperatorConstraints require operators to be installed by the Operator
+	// Lifecycle Manager. Never satisfied on clusters without OLM.
+	// All constraints must be satisfied for cluster to be a match.
+	// +optional
+	OLMOperatorConstraints []OLMOperatorConstraint `json:"olmOperatorConstraints,omitempty"`
+
+	// DefaultStorageClass, when true, requires the cluster to have a default
+	// StorageClass. When false, requires it not to have one.
+	// +optional
+	DefaultStorageClass *bool `json:"defaultStorageClass,omitempty"`
+
+	// IngressClassConstraints require IngressClasses, default or not, to exist.
+	// All constraints must be satisfied for cluster to be a match.
+	// +optional
+	IngressClassConstraints []IngressClassConstraint `json:"ingressClassConstraints,omitempty"`
+
+	// CRDConstraints require CustomResourceDefinitions to be installed.
+	// All constraints must be satisfied for cluster to be a match.
+	// +optional
+	CRDConstraints []CRDConstraint `json:"crdConstraints,omitempty"`
+
+	// APIResourceConstraints require APIs to be served by the cluster.
+	// Those are verified using discovery only.
+	// All constraints must be satisfied for cluster to be a match.
+	// +optional
+	APIResourceConstraints []APIResourceConstraint `json:"apiResourceConstraints,omitempty"`
+
+	// APIServiceConstraints require aggregated APIs to be registered and healthy.
+	// All constraints must be satisfied for cluster to be a match.
+	// +optional
+	APIServiceConstraints []APIServiceConstraint `json:"apiServiceConstraints,omitempty"`
+
+	// ConfigDataConstraints require keys of ConfigMaps or Secrets to exist and,
+	// optionally, their values to match a pattern. Values are never copied into
+	// ClassifierReports.
+	// All constraints must be satisfied for cluster to be a match.
+	// +optional
+	ConfigDataConstraints []ConfigDataConstraint `json:"configDataConstraints,omitempty"`
+
+	// PodSecurityConstraints bound the PodSecurity admission level of namespaces,
+	// for instance to select clusters enforcing at least baseline everywhere.
+	// All constraints must be satisfied for cluster to be a match.
+	// +optional
+	PodSecurityConstraints []PodSecurityConstraint `json:"podSecurityConstraints,omitempty"`
+
+	// WebhookConstraints require admission webhook configurations, for instance the
+	// ones of a policy engine, to be present or absent.
+	// All constraints must be satisfied for cluster to be a match.
+	// +optional
+	WebhookConstraints []WebhookConstraint `json:"webhookConstraints,omitempty"`
+
+	// CertificateExpirationConstraints require certificates, of TLS Secrets or admission
+	// webhook configurations, to expire within a window.
+	// All constraints must be satisfied for cluster to be a match.
+	// +optional
+	CertificateExpirationConstraints []CertificateExpirationConstraint `json:"certificateExpirationConstraints,omitempty"`
+
+	// APIServerFeatures require API server capabilities, probed on the API
+	// server rather than derived from its version: ServerSideApply,
+	// ValidatingAdmissionPolicy and OpenAPIV3.
+	// All features must be available for cluster to be a match.
+	// +optional
+	APIServerFeatures []APIServerFeature `json:"apiServerFeatures,omitempty"`
+
+	// APIServerFlags requires feature gates and admission plugins to be explicitly
+	// enabled or disabled on the API server, where its flags are readable.
+	// +optional
+	APIServerFlags *APIServerFlagsConstraint `json:"apiServerFlags,omitempty"`
+
+	// Budget bounds the resources a single evaluation of this Classifier can use.
+	// An evaluation exceeding it is aborted, the ClassifierReport is marked as
+	// degraded and Classifier is moved to the slow lane.
+	// +optional
+	Budget *EvaluationBudget `json:"budget,omitempty"`
+}
+
+// EvaluationBudget bounds the resources a Classifier evaluation can use
+type EvaluationBudget struct {
+	// MaxRequests is the maximum number of API server requests per evaluation.
+	// Requests served by the local cache are not counted.
+	// +optional
+	MaxRequests int64 `json:"maxRequests,omitempty"`
+
+	// MaxDuration is the maximum duration of an evaluation, for instance "30s"
+	// +optional
+	MaxDuration *metav1.Duration `json:"maxDuration,omitempty"`
+}
+
+// APIServiceConstraint is satisfied when an APIService exists and its
+// Available condition is True
+type APIServiceConstraint struct {
+	// Name is the APIService name, for instance "v1beta1.metrics.k8s.io"
+	Name string `json:"name"`
+}
+
+// APIResourceConstraint is satisfied when the cluster serves a group/version
+// and, optionally, a kind within it
+type APIResourceConstraint struct {
+	// Group of the API. Empty for the core group
+	// +optional
+	Group string `json:"group,omitempty"`
+
+	// Version of the API
+	Version string `json:"version"`
+
+	// Kind, when set, requires this kind to be served within Group/Version
+	// +optional
+	Kind string `json:"kind,omitempty"`
+}
+
+// CRDConstraint is satisfied when a CustomResourceDefinition is installed,
+// established and, optionally, serves a given version
+type CRDConstraint struct {
+	// Name is the CustomResourceDefinition name, for instance
+	// "certificates.cert-manager.io"
+	Name string `json:"name"`
+
+	// ServedVersion, when set, requires the CustomResourceDefinition
+	// to serve this version, for instance "v1"
+	// +optional
+	ServedVersion string `json:"servedVersion,omitempty"`
+}
+
+// HelmReleaseConstraint is satisfied when at least one deployed Helm v3 release
+// matches it
+type HelmReleaseConstraint struct {
+	// ChartName is the name of the chart
+	ChartName string `json:"chartName"`
+
+	// VersionRange is a semver range expression chart version must be within,
+	// for instance ">=1.9.0 <2.0.0"
+	// +optional
+	VersionRange string `json:"versionRange,omitempty"`
+
+	// Namespace, when set, restricts releases to the ones in this namespace
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// ReleaseName, when set, restricts releases to the ones with this name
+	// +optional
+	ReleaseName string `json:"releaseName,omitempty"`
+}
+
+// KubeletVersionSkewConstraint is a constraint on the version skew between
+// API server and node kubelets
+type KubeletVersionSkewConstraint struct {
+	// MinorVersions is the skew threshold. Constraint is satisfied when
+	// API server and at least one node kubelet minor versions differ by
+	// more than MinorVersions.
+	MinorVersions int `json:"minorVersions"`
+}
+
+// VersionConstraint is a constraint on cluster Kubernetes version.
+// Either Range or Version and Comparison must be set.
+type VersionConstraint struct {
+	// Range is a semver range expression, for instance ">=1.24.0 <1.27.0",
+	// "~1.25.x" or ">=1.22.0, <1.23.0 || >=1.25.0"
+	// +optional
+	Range string `json:"range,omitempty"`
+
+	// Version is the Kubernetes version Comparison is done against.
+	// Ignored if Range is set.
+	// +optional
+	Version string `json:"version,omitempty"`
+
+	// Comparison is one of libsveltos KubernetesComparison values.
+	// Ignored if Range is set.
+	// +optional
+	Comparison string `json:"comparison,omitempty"`
+}
+
+// VersionConstraintGroup is a set of constraints which are all
+// required to be satisfied
+type VersionConstraintGroup struct {
+	// Constraints to be satisfied
+	Constraints []VersionConstraint `json:"constraints"`
+}
+
+// ResourceConstraint extends libsveltos DeployedResourceConstraint with
+// settings only evaluated by classifier-agent
+type ResourceConstraint struct {
+	libsveltosv1alpha1.DeployedResourceConstraint `json:",inline"`
+
+	// MustNotExist, when set, requires no resource to match this constraint.
+	// Unlike MaxCount set to zero, it is satisfied when the cluster does not serve
+	// group/version/kind, and resources found are reported in the ClassifierReport
+	// explanation. MinCount, MaxCount, Percentage, Trend and Cardinality must not be set.
+	// +optional
+	MustNotExist bool `json:"mustNotExist,omitempty"`
+
+	// Expression is a CEL expression evaluated against each resource
+	// matching group/version/kind, namespace and filters. The resource is
+	// available in the expression as "object".
+	// Only resources for which the expression evaluates to true are counted.
+	// +optional
+	Expression string `json:"expression,omitempty"`
+
+	// Percentage, when set, constrains the percentage of resources matching
+	// group/version/kind, namespace and label filters which also satisfy field
+	// filters and Expression. MinCount and MaxCount are then ignored.
+	// +optional
+	Percentage *PercentageConstraint `json:"percentage,omitempty"`
+
+	// Trend, when set, constrains how the number of resources matching this
+	// constraint changed over a time window. MinCount, MaxCount and Percentage
+	// are then ignored.
+	// +optional
+	Trend *TrendConstraint `json:"trend,omitempty"`
+
+	// Cardinality, when set, constrains the number of distinct values a field
+	// takes across resources matching this constraint, for instance the number
+	// of zones Nodes span. MinCount and MaxCount are then ignored.
+	// +optional
+	Cardinality *CardinalityConstraint `json:"cardinality,omitempty"`
+
+	// MinAge, when set, only selects resources created at least MinAge ago, for
+	// instance a Deployment which has been running for a week. Classifier is
+	// evaluated again when a resource reaches MinAge.
+	// +optional
+	MinAge *metav1.Duration `json:"minAge,omitempty"`
+
+	// MaxAge, when set, only selects resources created at most MaxAge ago.
+	// Classifier is evaluated again when a resource exceeds MaxAge.
+	// +optional
+	MaxAge *metav1.Duration `json:"maxAge,omitempty"`
+
+	// MissingFields sets, per field path, how FieldFilters on that field are
+	// evaluated on resources where the field does not exist. When not set,
+	// a missing field satisfies Different and NotMatchRegex filters only.
+	// +optional
+	MissingFields map[string]MissingFieldPolicy `json:"missingFields,omitempty"`
+
+	// ElementMatch sets, per field path, whether any or all the values the
+	// field resolves to must satisfy FieldFilters on that field. When not set,
+	// Different and NotMatchRegex filters require all values to satisfy them,
+	// other operations require at least one.
+	// +optional
+	ElementMatch map[string]ElementMatchPolicy `json:"elementMatch,omitempty"`
+
+	// AnnotationFilters select resources by annotation. Those support the same
+	// operations as LabelFilters and are all evaluated by classifier-agent.
+	// +optional
+	AnnotationFilters []libsveltosv1alpha1.LabelFilter `json:"annotationFilters,omitempty"`
+
+	// OwnerFilters select resources by owner, for instance Pods owned by a given
+	// DaemonSet. All filters must be satisfied.
+	// +optional
+	OwnerFilters []OwnerFilter `json:"ownerFilters,omitempty"`
+
+	// ConditionFilters select resources by status condition, for instance
+	// Deployments whose Available condition is True, so that a Classifier
+	// reflects actual health rather than mere presence. All filters must be
+	// satisfied.
+	// +optional
+	ConditionFilters []ConditionFilter `json:"conditionFilters,omitempty"`
+
+	// RestartCount, only valid for Pods, selects Pods by the sum of their
+	// container restart counts. Any Pod in a namespace restarting more than
+	// 20 times is, for instance, MinRestarts 21 with MinCount 1.
+	// +optional
+	RestartCount *RestartCountFilter `json:"restartCount,omitempty"`
+
+	// TaintFilters, only valid for Nodes, select Nodes having all these taints
+	// +optional
+	TaintFilters []TaintFilter `json:"taintFilters,omitempty"`
+
+	// ReconciledOnly, when set, only selects resources whose status.observedGeneration
+	// equals metadata.generation, so that a spec change a controller has not acted on
+	// yet does not affect classification. Resources without status.observedGeneration
+	// are never selected.
+	// +optional
+	ReconciledOnly bool `json:"reconciledOnly,omitempty"`
+
+	// ResourceNames, when set, restricts the constraint to the resources with
+	// those names. Each one is fetched with a Get instead of listing all resources.
+	// Namespace must be set for namespaced resources.
+	// +optional
+	ResourceNames []string `json:"resourceNames,omitempty"`
+
+	// ExcludedNamespaces lists namespaces whose resources are ignored, for instance
+	// kube-system, so that a cluster-wide count is not skewed by infrastructure
+	// resources. Ignored for cluster-scoped resources.
+	// +optional
+	ExcludedNamespaces []string `json:"excludedNamespaces,omitempty"`
+}
+
+// PercentageConstraint bounds a percentage of resources
+type PercentageConstraint struct {
+	// MinPercent is the minimum percentage, between 0 and 100
+	// +optional
+	MinPercent *int `json:"minPercent,omitempty"`
+
+	// MaxPercent is the maximum percentage, between 0 and 100
+	// +optional
+	MaxPercent *int `json:"maxPercent,omitempty"`
+
+	// Sampling, when true, stops listing resources as soon as the percentage
+	// is known, with 99% confidence, to be within or outside bounds.
+	// Estimation assumes the order resources are listed in, by namespace and
+	// name, is not correlated with the evaluated property.
+	// +optional
+	Sampling bool `json:"sampling,omitempty"`
+}
+
+// CardinalityConstraint bounds the number of distinct values of a field
+type CardinalityConstraint struct {
+	// Field is the path of the field whose distinct values are counted, in the
+	// FieldFilters path syntax, for instance
+	// "metadata.labels['topology.kubernetes.io/zone']". Resources where the field
+	// does not exist, or is not a scalar, are ignored. A path with wildcards can
+	// contribute several values per resource.
+	Field string `json:"field"`
+
+	// MinDistinct is the minimum number of distinct values
+	// +optional
+	MinDistinct *int `json:"minDistinct,omitempty"`
+
+	// MaxDistinct is the maximum number of distinct values
+	// +optional
+	MaxDistinct *int `json:"maxDistinct,omitempty"`
+}
+
+// RatioConstraint bounds, in percent, the ratio between the number of resources
+// matching Numerator and the number of resources matching Denominator. For
+// instance "fewer than 5% of Pods are in CrashLoopBackOff" uses as Numerator Pods
+// with a container waiting with reason CrashLoopBackOff and as Denominator all Pods.
+type RatioConstraint struct {
+	// Numerator selects the resources counted in the ratio numerator.
+	// MinCount, MaxCount, Percentage, Trend, Cardinality and MustNotExist must not be set.
+	Numerator ResourceConstraint `json:"numerator"`
+
+	// Denominator selects the resources counted in the ratio denominator.
+	// MinCount, MaxCount, Percentage, Trend, Cardinality and MustNotExist must not be set.
+	Denominator ResourceConstraint `json:"denominator"`
+
+	// MinPercent is the minimum ratio, in percent
+	// +optional
+	MinPercent *int `json:"minPercent,omitempty"`
+
+	// MaxPercent is the maximum ratio, in percent. It can exceed 100 when
+	// Numerator is not a subset of Denominator.
+	// +optional
+	MaxPercent *int `json:"maxPercent,omitempty"`
+}
+
+// TrendConstraint defines the bounds of the change, in percent, of a resource count
+// over a time window. Counts are recorded by classifier-agent every time the
+// Classifier is evaluated and kept in memory.
+type TrendConstraint struct {
+	// Window is how far back the current count is compared to. It cannot
+	// exceed the trend retention (24h by default).
+	Window metav1.Duration `json:"window"`
+
+	// MinChangePercent is the minimum change, a negative value being a decrease.
+	// For instance 50 requires the count to have increased by at least 50%.
+	// +optional
+	MinChangePercent *int `json:"minChangePercent,omitempty"`
+
+	// MaxChangePercent is the maximum change, a negative value being a decrease
+	// +optional
+	MaxChangePercent *int `json:"maxChangePercent,omitempty"`
+}
+
+// getClassifierExtension returns the ClassifierExtension set on a Classifier instance.
+// Returns an empty ClassifierExtension if Classifier has no ClassifierExtensionAnnotation.
+func getClassifierExtension(classifier *libsveltosv1alpha1.Classifier) (*ClassifierExtension, error) {
+	extension := &ClassifierExtension{}
+
+	annotations := classifier.GetAnnotations()
+	if annotations == nil {
+		return extension, nil
+	}
+
+	value, ok := annotations[ClassifierExtensionAnnotation]
+	if !ok || value == "" {
+		return extension, nil
+	}
+
+	const bufferSize = 4096
+	decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader([]byte(value)), bufferSize)
+	if err := decoder.Decode(extension); err != nil {
+		return nil, newInvalidClassifierError(errors.Wrap(err,
+			fmt.Sprintf("failed to parse annotation %s", ClassifierExtensionAnnotation)))
+	}
+
+	return extension, nil
+}
+
+// getResourceConstraints returns all DeployedResourceConstraints for a Classifier:
+// the ones in the Classifier Spec followed by the ones in the ClassifierExtension
+func getResourceConstraints(classifier *libsveltosv1alpha1.Classifier,
+	extension *ClassifierExtension) []ResourceConstraint {
+
+	constraints := make([]ResourceConstraint, 0,
+		len(classifier.Spec.DeployedResourceConstraints)+len(extension.DeployedResourceConstraints))
+	for i := range classifier.Spec.DeployedResourceConstraints {
+		constraints = append(constraints, ResourceConstraint{
+			DeployedResourceConstraint: classifier.Spec.DeployedResourceConstraints[i],
+		})
+	}
+
+	return append(constraints, extension.DeployedResourceConstraints...)
+}
+
+// GetClassifierGVKs returns the GVKs of all resources a Classifier depends on.
+// If the ClassifierExtension cannot be parsed, only the Classifier Spec is considered.
+// Parsing error is reported when Classifier is evaluated.
+func GetClassifierGVKs(classifier *libsveltosv1alpha1.Classifier) []schema.GroupVersionKind {
+	extension, err := getClassifierExtension(classifier)
+	if err != nil {
+		extension = &ClassifierExtension{}
+	}
+
+	constraints := getResourceConstraints(classifier, extension)
+	gvks := make([]schema.GroupVersionKind, len(constraints))
+	for i := range constraints {
+		gvks[i] = schema.GroupVersionKind{
+			Group:   constraints[i].Group,
+			Version: constraints[i].Version,
+			Kind:    constraints[i].Kind,
+		}
+	}
+
+	for i := range extension.RatioConstraints {
+		for _, selection := range []*ResourceConstraint{
+			&extension.RatioConstraints[i].Numerator, &extension.RatioConstraints[i].Denominator} {
+
+			gvks = append(gvks, schema.GroupVersionKind{
+				Group: selection.Group, Version: selection.Version, Kind: selection.Kind})
+		}
+	}
+
+	if len(extension.CloudProviders) > 0 || len(extension.NodeConstraints) > 0 || extension.ContainerRuntime != nil {
+		gvks = append(gvks, nodeGVK)
+	}
+
+	if len(extension.CNIs) > 0 {
+		gvks = append(gvks, getCNIGVKs()...)
+	}
+
+	if len(extension.ServiceMeshes) > 0 {
+		gvks = append(gvks, getServiceMeshGVKs()...)
+	}
+
+	for i := range extension.CapacityConstraints {
+		if extension.CapacityConstraints[i].needsAllocatable() {
+			gvks = append(gvks, nodeGVK)
+		}
+		if extension.CapacityConstraints[i].needsRequested() {
+			gvks = append(gvks, podGVK)
+		}
+	}
+
+	for i := range extension.UtilizationConstraints {
+		if extension.UtilizationConstraints[i].getTarget() == UtilizationTargetPod {
+			gvks = append(gvks, podGVK)
+		} else {
+			gvks = append(gvks, nodeGVK)
+		}
+	}
+
+	if len(extension.EventConstraints) > 0 {
+		gvks = append(gvks, eventGVK)
+	}
+
+	if len(extension.MetricConstraints) > 0 {
+		gvks = append(gvks, configMapGVK)
+	}
+
+	if extension.APIServerFlags != nil {
+		gvks = append(gvks, configMapGVK)
+	}
+
+	if len(extension.PodSecurityConstraints) > 0 {
+		gvks = append(gvks, namespaceGVK, configMapGVK)
+	}
+
+	for i := range extension.WebhookConstraints {
+		gvks = append(gvks, getWebhookGVKs(&extension.WebhookConstraints[i])...)
+	}
+
+	for i := range extension.CertificateExpirationConstraints {
+		gvks = append(gvks, getCertificateExpirationGVKs(&extension.CertificateExpirationConstraints[i])...)
+	}
+
+	for i := range extension.ConfigDataConstraints {
+		if extension.ConfigDataConstraints[i].Kind == ConfigDataSecret {
+			gvks = append(gvks, secretGVK)
+		} else {
+			gvks = append(gvks, configMapGVK)
+		}
+	}
+
+	if len(extension.FactFilters) > 0 {
+		gvks = append(gvks, configMapGVK)
+	}
+
+	if len(extension.HelmReleaseConstraints) > 0 {
+		gvks = append(gvks, secretGVK)
+	}
+
+	if len(extension.OLMOperatorConstraints) > 0 {
+		gvks = append(gvks, csvGVK, subscriptionGVK)
+	}
+
+	gvks = append(gvks, getStorageIngressGVKs(extension)...)
+
+	if len(extension.APIResourceConstraints) > 0 {
+		gvks = append(gvks, crdGVK, apiServiceGVK)
+	}
+
+	if len(extension.APIServiceConstraints) > 0 {
+		gvks = append(gvks, apiServiceGVK)
+	}
+
+	return gvks
+}
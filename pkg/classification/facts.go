@@ -0,0 +1,112 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/projectsveltos/classifier-agent/internal/utils"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+)
+
+const (
+	// FactsConfigMap is the name of the ConfigMap, in the projectsveltos namespace,
+	// where cluster admins declare static facts about the cluster which cannot be
+	// derived from cluster resources, for instance region=eu-west-1 or tier=gold.
+	// Each data key is a fact.
+	FactsConfigMap = "classifier-agent-facts"
+)
+
+// configMapGVK is the GVK watched by Classifiers with FactFilters
+var configMapGVK = schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+
+// getFacts returns the static facts declared in the FactsConfigMap. No facts are
+// returned when the ConfigMap does not exist.
+func (m *manager) getFacts(ctx context.Context) (map[string]string, error) {
+	configMap := &corev1.ConfigMap{}
+	err := m.Get(ctx, client.ObjectKey{Namespace: utils.ReportNamespace, Name: FactsConfigMap}, configMap)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return map[string]string{}, nil
+		}
+		return nil, err
+	}
+	return configMap.Data, nil
+}
+
+// getAllFacts returns the static facts declared in the FactsConfigMap and the
+// facts about classifier-agent environment. The latter take precedence.
+func (m *manager) getAllFacts(ctx context.Context) (map[string]string, error) {
+	static, err := m.getFacts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	agent, err := m.getAgentFacts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	facts := make(map[string]string, len(static)+len(agent))
+	for k, v := range static {
+		facts[k] = v
+	}
+	for k, v := range agent {
+		facts[k] = v
+	}
+	return facts, nil
+}
+
+// areFactsAMatch returns true if the static facts declared for the cluster satisfy
+// all Classifier FactFilters
+func (m *manager) areFactsAMatch(ctx context.Context,
+	classifier *libsveltosv1alpha1.Classifier) (bool, error) {
+
+	extension, err := getClassifierExtension(classifier)
+	if err != nil {
+		return false, err
+	}
+
+	if len(extension.FactFilters) == 0 {
+		return true, nil
+	}
+
+	matchers, err := getKeyValueFilterMatchers("fact filter", extension.FactFilters, compileRegex)
+	if err != nil {
+		return false, newInvalidClassifierError(err)
+	}
+
+	facts, err := m.getAllFacts(ctx)
+	if err != nil {
+		return false, err
+	}
+	m.log.V(logs.LogDebug).Info(fmt.Sprintf("cluster facts: %v", facts))
+
+	for i := range matchers {
+		value, found := facts[matchers[i].key]
+		if !matchers[i].isValueAMatch(value, found) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
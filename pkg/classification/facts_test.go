@@ -0,0 +1,114 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/projectsveltos/classifier-agent/internal/utils"
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: facts", func() {
+
+	BeforeEach(func() {
+		classification.Reset()
+	})
+
+	getClassifierWithFactFilters := func(factFilters string) *libsveltosv1alpha1.Classifier {
+		classifier := getClassifierWithKubernetesConstraints(version25, libsveltosv1alpha1.ComparisonEqual)
+		classifier.Annotations = map[string]string{
+			classification.ClassifierExtensionAnnotation: factFilters,
+		}
+		return classifier
+	}
+
+	It("areFactsAMatch matches facts declared in the facts ConfigMap", func() {
+		facts := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: utils.ReportNamespace,
+				Name:      classification.FactsConfigMap,
+			},
+			Data: map[string]string{"region": "eu-west-1", "tier": "gold"},
+		}
+
+		classification.InitializeManagerWithObjects(nil, facts)
+		manager := classification.GetManager()
+
+		classifier := getClassifierWithFactFilters(`factFilters:
+- {key: region, operation: MatchRegex, value: "eu-.*"}
+- {key: tier, operation: In, value: "gold,silver"}
+- {key: environment, operation: DoesNotExist}`)
+		isMatch, err := classification.AreFactsAMatch(manager, context.TODO(), classifier)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+
+		classifier = getClassifierWithFactFilters(`factFilters:
+- {key: region, operation: Equal, value: eu-west-1}
+- {key: tier, operation: Equal, value: silver}`)
+		isMatch, err = classification.AreFactsAMatch(manager, context.TODO(), classifier)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeFalse())
+	})
+
+	It("areFactsAMatch treats a missing facts ConfigMap as no facts", func() {
+		classification.InitializeManagerWithObjects(nil)
+		manager := classification.GetManager()
+
+		classifier := getClassifierWithFactFilters(`factFilters:
+- {key: tier, operation: Different, value: gold}`)
+		isMatch, err := classification.AreFactsAMatch(manager, context.TODO(), classifier)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+
+		classifier = getClassifierWithFactFilters(`factFilters:
+- {key: tier, operation: Exists}`)
+		isMatch, err = classification.AreFactsAMatch(manager, context.TODO(), classifier)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeFalse())
+
+		// Classifiers with no FactFilters are always a match
+		isMatch, err = classification.AreFactsAMatch(manager, context.TODO(), getClassifierWithFactFilters(""))
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+	})
+
+	It("areFactsAMatch rejects invalid fact filters", func() {
+		classification.InitializeManagerWithObjects(nil)
+		manager := classification.GetManager()
+
+		classifier := getClassifierWithFactFilters(`factFilters:
+- {key: tier, operation: Exists, value: gold}`)
+		_, err := classification.AreFactsAMatch(manager, context.TODO(), classifier)
+		Expect(err).ToNot(BeNil())
+		Expect(classification.IsInvalidClassifierError(err)).To(BeTrue())
+	})
+
+	It("GetClassifierGVKs includes ConfigMap for Classifiers with FactFilters", func() {
+		classifier := getClassifierWithFactFilters(`factFilters:
+- {key: tier, operation: Exists}`)
+		Expect(classification.GetClassifierGVKs(classifier)).To(ContainElement(
+			corev1.SchemeGroupVersion.WithKind("ConfigMap")))
+	})
+})
@@ -0,0 +1,552 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+const (
+	// maxFieldPathDepth is the maximum number of segments in a field path
+	maxFieldPathDepth = 32
+
+	// maxFieldExpansion is the maximum number of values a field path can resolve
+	// to, when resolving it requires expanding arrays
+	maxFieldExpansion = 1024
+
+	// maxFieldValueSize is the maximum size, in bytes, of a resolved value
+	maxFieldValueSize = 64 * 1024
+)
+
+// fieldBuffers contains the buffers used to resolve a field path
+type fieldBuffers struct {
+	current []interface{}
+	next    []interface{}
+}
+
+// fieldBuffersPool reuses fieldBuffers across resolutions, as field paths are
+// resolved on every listed resource
+var fieldBuffersPool = sync.Pool{
+	New: func() interface{} {
+		return &fieldBuffers{
+			current: make([]interface{}, 0, 1),
+			next:    make([]interface{}, 0, 1),
+		}
+	},
+}
+
+func getFieldBuffers() *fieldBuffers {
+	return fieldBuffersPool.Get().(*fieldBuffers)
+}
+
+// releaseFieldBuffers returns buffers to the pool. Values resolved with
+// buffers must not be used after this call.
+func releaseFieldBuffers(buffers *fieldBuffers) {
+	// Drop references so pooled buffers do not keep resources alive
+	for _, buffer := range [][]interface{}{buffers.current, buffers.next} {
+		buffer = buffer[:cap(buffer)]
+		for i := range buffer {
+			buffer[i] = nil
+		}
+	}
+	buffers.current = buffers.current[:0]
+	buffers.next = buffers.next[:0]
+	fieldBuffersPool.Put(buffers)
+}
+
+// FieldResolutionError is returned when a field path cannot be resolved
+// within the resolver limits
+type FieldResolutionError struct {
+	// Path is the field path being resolved
+	Path string
+
+	// Reason explains which limit was exceeded
+	Reason string
+}
+
+func (e *FieldResolutionError) Error() string {
+	return fmt.Sprintf("cannot resolve field %q: %s", e.Path, e.Reason)
+}
+
+// resolveField returns all values found at path in object.
+// path is a JSONPath-like expression (see parseFieldPath). When a field is
+// reached on an array, it is resolved on every element.
+// Only scalar values (strings, numbers, booleans) are returned.
+func resolveField(object map[string]interface{}, path string) ([]string, error) {
+	segments, err := parseFieldPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	buffers := getFieldBuffers()
+	defer releaseFieldBuffers(buffers)
+
+	values, err := resolveSegments(object, segments, path, buffers)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]string, 0, len(values))
+	for i := range values {
+		value, ok, err := scalarToString(values[i], path)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			result = append(result, value)
+		}
+	}
+
+	return result, nil
+}
+
+type fieldSegmentKind int
+
+const (
+	// fieldSegmentName selects a field. On arrays, it is resolved on every element.
+	fieldSegmentName fieldSegmentKind = iota
+	// fieldSegmentIndex selects one array element
+	fieldSegmentIndex
+	// fieldSegmentWildcard selects all array elements, or all map values
+	fieldSegmentWildcard
+)
+
+// fieldSegment is one step of a field path
+type fieldSegment struct {
+	kind fieldSegmentKind
+	name string
+	// index is the array index for fieldSegmentIndex. For fieldSegmentName
+	// written in dot notation with a numeric name ("containers.0"), it is the
+	// index used when segment is reached on an array. It is -1 otherwise.
+	index int
+}
+
+// parseFieldPath returns the segments of a field path.
+// Following notations are accepted, and can be combined:
+//   - dot separated fields: "spec.containers.image";
+//   - array indexes: "spec.containers[0].image" or "spec.containers.0.image";
+//   - wildcards, selecting all elements of an array or all values of a map:
+//     "spec.containers[*].image";
+//   - quoted fields, for names containing dots: "metadata.labels['app.kubernetes.io/name']".
+//
+// Path can optionally start with "$." and be enclosed in braces, as in
+// "{.spec.containers[*].image}".
+func parseFieldPath(path string) ([]fieldSegment, error) {
+	expression := path
+	if strings.HasPrefix(expression, "{") && strings.HasSuffix(expression, "}") {
+		expression = expression[1 : len(expression)-1]
+	}
+	expression = strings.TrimPrefix(expression, "$")
+	expression = strings.TrimPrefix(expression, ".")
+
+	invalid := func(reason string) error {
+		return &FieldResolutionError{Path: path, Reason: "invalid path: " + reason}
+	}
+
+	segments := make([]fieldSegment, 0)
+	for i := 0; i < len(expression); {
+		switch expression[i] {
+		case '[':
+			end := strings.IndexByte(expression[i:], ']')
+			if end < 0 {
+				return nil, invalid("missing ]")
+			}
+			segment, err := parseBracketSegment(expression[i+1 : i+end])
+			if err != nil {
+				return nil, invalid(err.Error())
+			}
+			segments = append(segments, segment)
+			i += end + 1
+			if i < len(expression) && expression[i] == '.' {
+				i++
+				if i == len(expression) {
+					return nil, invalid("empty field name")
+				}
+			}
+		default:
+			end := strings.IndexAny(expression[i:], ".[")
+			if end < 0 {
+				end = len(expression) - i
+			}
+			name := expression[i : i+end]
+			if name == "" {
+				return nil, invalid("empty field name")
+			}
+			index, err := strconv.Atoi(name)
+			if err != nil || index < 0 {
+				index = -1
+			}
+			segments = append(segments, fieldSegment{kind: fieldSegmentName, name: name, index: index})
+			i += end
+			if i < len(expression) && expression[i] == '.' {
+				i++
+				if i == len(expression) {
+					return nil, invalid("empty field name")
+				}
+			}
+		}
+
+		if len(segments) > maxFieldPathDepth {
+			return nil, &FieldResolutionError{Path: path,
+				Reason: fmt.Sprintf("path depth exceeds maximum %d", maxFieldPathDepth)}
+		}
+	}
+
+	if len(segments) == 0 {
+		return nil, invalid("path is empty")
+	}
+	return segments, nil
+}
+
+// parseBracketSegment parses the content of a bracket: "*", an index or a quoted field name
+func parseBracketSegment(content string) (fieldSegment, error) {
+	switch {
+	case content == "*":
+		return fieldSegment{kind: fieldSegmentWildcard, index: -1}, nil
+	case len(content) >= 2 && (content[0] == '\'' || content[0] == '"') && content[len(content)-1] == content[0]:
+		name := content[1 : len(content)-1]
+		if name == "" {
+			return fieldSegment{}, fmt.Errorf("empty field name")
+		}
+		return fieldSegment{kind: fieldSegmentName, name: name, index: -1}, nil
+	default:
+		index, err := strconv.Atoi(content)
+		if err != nil || index < 0 {
+			return fieldSegment{}, fmt.Errorf("%q is neither an index, a wildcard nor a quoted field name", content)
+		}
+		return fieldSegment{kind: fieldSegmentIndex, index: index}, nil
+	}
+}
+
+// resolveSegments returns all values, scalar or not, segments resolve to in object.
+// Returned values are stored in buffers.
+func resolveSegments(object map[string]interface{}, segments []fieldSegment, path string,
+	buffers *fieldBuffers) ([]interface{}, error) {
+
+	// The two buffers are swapped at each segment
+	current := append(buffers.current[:0], object)
+	next := buffers.next[:0]
+	defer func() {
+		// Keep grown buffers so they are reused
+		buffers.current, buffers.next = current, next
+	}()
+
+	for i := range segments {
+		next = next[:0]
+		for j := range current {
+			var err error
+			next, err = appendSegment(next, current[j], &segments[i], path)
+			if err != nil {
+				return nil, err
+			}
+			if len(next) > maxFieldExpansion {
+				return nil, &FieldResolutionError{Path: path,
+					Reason: fmt.Sprintf("field resolves to more than %d values", maxFieldExpansion)}
+			}
+		}
+		current, next = next, current
+	}
+	return current, nil
+}
+
+// scalarToString returns the string representation of a scalar value.
+// Returns false if value is not a scalar (maps, arrays and nil cannot be
+// compared with a filter value).
+func scalarToString(value interface{}, path string) (string, bool, error) {
+	var result string
+	switch v := value.(type) {
+	case string:
+		result = v
+	case bool:
+		result = strconv.FormatBool(v)
+	case int64:
+		result = strconv.FormatInt(v, 10)
+	case int32:
+		result = strconv.FormatInt(int64(v), 10)
+	case int:
+		result = strconv.Itoa(v)
+	case float64:
+		result = strconv.FormatFloat(v, 'g', -1, 64)
+	default:
+		return "", false, nil
+	}
+	if len(result) > maxFieldValueSize {
+		return "", false, &FieldResolutionError{Path: path,
+			Reason: fmt.Sprintf("value size %d exceeds maximum %d", len(result), maxFieldValueSize)}
+	}
+	return result, true, nil
+}
+
+// appendSegment appends to result the values segment resolves to in value
+func appendSegment(result []interface{}, value interface{}, segment *fieldSegment,
+	path string) ([]interface{}, error) {
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		switch segment.kind {
+		case fieldSegmentName:
+			if field, ok := v[segment.name]; ok {
+				result = append(result, field)
+			}
+		case fieldSegmentWildcard:
+			if len(v) > maxFieldExpansion {
+				return nil, &FieldResolutionError{Path: path,
+					Reason: fmt.Sprintf("map with %d entries exceeds maximum %d", len(v), maxFieldExpansion)}
+			}
+			for _, field := range v {
+				result = append(result, field)
+			}
+		}
+		return result, nil
+	case []interface{}:
+		if segment.kind == fieldSegmentIndex || (segment.kind == fieldSegmentName && segment.index >= 0) {
+			if segment.index >= len(v) {
+				return result, nil
+			}
+			return append(result, v[segment.index]), nil
+		}
+		if len(v) > maxFieldExpansion {
+			return nil, &FieldResolutionError{Path: path,
+				Reason: fmt.Sprintf("array with %d elements exceeds maximum %d", len(v), maxFieldExpansion)}
+		}
+		if segment.kind == fieldSegmentWildcard {
+			return append(result, v...), nil
+		}
+		for i := range v {
+			var err error
+			result, err = appendSegment(result, v[i], segment, path)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	default:
+		return result, nil
+	}
+}
+
+// fieldFilterMatcher evaluates a FieldFilter. The field path is split once,
+// so the same matcher can be used on every resource of a list.
+type fieldFilterMatcher struct {
+	filter   *libsveltosv1alpha1.FieldFilter
+	segments []fieldSegment
+	// regex is set only for regex operations
+	regex *regexp.Regexp
+	// quantity is set only for comparison operations
+	quantity *resource.Quantity
+	// age is set only for age operations
+	age *time.Duration
+	// now is the time ages are computed at. Current time when zero.
+	now time.Time
+	// missing, when set, defines how the filter is evaluated when field does
+	// not exist. missingCount is the number of such resources evaluated.
+	missing      MissingFieldPolicy
+	missingCount int
+	// elements, when set, defines whether any or all values the field
+	// resolves to must satisfy the filter
+	elements ElementMatchPolicy
+}
+
+// fieldFilterOutcome is the outcome of evaluating field filters on a resource
+type fieldFilterOutcome int
+
+const (
+	fieldFilterNoMatch fieldFilterOutcome = iota
+	fieldFilterMatch
+	// fieldFilterSkip excludes the resource from the evaluation
+	fieldFilterSkip
+)
+
+// getFieldFilterMatchers returns a matcher per filter. compile is used for
+// filters with a regex operation.
+func getFieldFilterMatchers(filters []libsveltosv1alpha1.FieldFilter,
+	compile regexCompiler) ([]fieldFilterMatcher, error) {
+
+	matchers := make([]fieldFilterMatcher, len(filters))
+	for i := range filters {
+		segments, err := parseFieldPath(filters[i].Field)
+		if err != nil {
+			return nil, err
+		}
+		matchers[i] = fieldFilterMatcher{filter: &filters[i], segments: segments}
+		switch operation := filters[i].Operation; {
+		case isRegexOperation(operation):
+			matchers[i].regex, err = compile(filters[i].Value)
+		case isComparisonOperation(operation):
+			matchers[i].quantity, err = parseFilterQuantity(&filters[i])
+		case isAgeOperation(operation):
+			matchers[i].age, err = parseFilterDuration(&filters[i])
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return matchers, nil
+}
+
+// isAMatch returns true if object satisfies filter.
+// By default, OperationEqual (OperationMatchRegex) requires at least one resolved
+// value to be equal to (to match) filter value.
+// OperationDifferent (OperationNotMatchRegex) requires no resolved value to be
+// equal to (to match) filter value.
+// Comparison and age operations require at least one resolved value to compare
+// to filter value as requested.
+// An ElementMatchPolicy overrides whether any or all resolved values must satisfy
+// the filter.
+func (f *fieldFilterMatcher) isAMatch(object map[string]interface{}) (bool, error) {
+	outcome, err := f.evaluate(object)
+	return outcome == fieldFilterMatch, err
+}
+
+// evaluate is like isAMatch but, when field does not exist and a missing
+// field policy is set, the outcome is decided by the policy
+func (f *fieldFilterMatcher) evaluate(object map[string]interface{}) (fieldFilterOutcome, error) {
+	buffers := getFieldBuffers()
+	defer releaseFieldBuffers(buffers)
+
+	values, err := resolveSegments(object, f.segments, f.filter.Field, buffers)
+	if err != nil {
+		return fieldFilterNoMatch, err
+	}
+
+	if f.missing != "" && isFieldMissing(values) {
+		f.missingCount++
+		switch f.missing {
+		case MissingFieldTreatAsMatch:
+			return fieldFilterMatch, nil
+		case MissingFieldSkip:
+			return fieldFilterSkip, nil
+		default:
+			return fieldFilterNoMatch, nil
+		}
+	}
+
+	quantifier := f.elements
+	if quantifier == "" {
+		quantifier = ElementMatchAny
+		if isNegationOperation(f.filter.Operation) {
+			quantifier = ElementMatchAll
+		}
+	}
+
+	now := f.now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	for i := range values {
+		value, ok, err := scalarToString(values[i], f.filter.Field)
+		if err != nil {
+			return fieldFilterNoMatch, err
+		}
+		if !ok {
+			continue
+		}
+		// For negations, a value satisfies the filter if it is not equal to
+		// (does not match) filter value
+		satisfied := f.isValueAMatch(value, now) != isNegationOperation(f.filter.Operation)
+		if quantifier == ElementMatchAny && satisfied {
+			return fieldFilterMatch, nil
+		}
+		if quantifier == ElementMatchAll && !satisfied {
+			return fieldFilterNoMatch, nil
+		}
+	}
+
+	if quantifier == ElementMatchAll {
+		return fieldFilterMatch, nil
+	}
+	return fieldFilterNoMatch, nil
+}
+
+// isNegationOperation returns true for OperationDifferent and OperationNotMatchRegex
+func isNegationOperation(operation libsveltosv1alpha1.Operation) bool {
+	return operation == libsveltosv1alpha1.OperationDifferent || operation == OperationNotMatchRegex
+}
+
+// isFieldMissing returns true if a field path resolved to no value, or to null values only
+func isFieldMissing(values []interface{}) bool {
+	for i := range values {
+		if values[i] != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// isValueAMatch returns true if a resolved value is equal to, matches or,
+// for comparison and age operations, compares as requested to filter value
+func (f *fieldFilterMatcher) isValueAMatch(value string, now time.Time) bool {
+	switch {
+	case f.regex != nil:
+		return f.regex.MatchString(value)
+	case f.quantity != nil:
+		return compareQuantity(value, f.filter.Operation, f.quantity)
+	case f.age != nil:
+		return compareAge(value, f.filter.Operation, *f.age, now)
+	default:
+		return value == f.filter.Value
+	}
+}
+
+// areFieldFilterMatchersAMatch returns true if object satisfies all matchers
+func areFieldFilterMatchersAMatch(object map[string]interface{}, matchers []fieldFilterMatcher) (bool, error) {
+	outcome, err := evaluateFieldFilterMatchers(object, matchers)
+	return outcome == fieldFilterMatch, err
+}
+
+// evaluateFieldFilterMatchers evaluates all matchers on object. Resource is
+// skipped if any matcher says so, even if another matcher is not satisfied.
+func evaluateFieldFilterMatchers(object map[string]interface{},
+	matchers []fieldFilterMatcher) (fieldFilterOutcome, error) {
+
+	outcome := fieldFilterMatch
+	for i := range matchers {
+		if outcome == fieldFilterNoMatch && matchers[i].missing != MissingFieldSkip {
+			// Only matchers which could skip the resource still matter
+			continue
+		}
+		current, err := matchers[i].evaluate(object)
+		if err != nil {
+			return fieldFilterNoMatch, err
+		}
+		if current == fieldFilterSkip {
+			return fieldFilterSkip, nil
+		}
+		if current == fieldFilterNoMatch {
+			outcome = fieldFilterNoMatch
+		}
+	}
+	return outcome, nil
+}
+
+// areFieldFiltersAMatch returns true if object satisfies all filters
+func areFieldFiltersAMatch(object map[string]interface{}, filters []libsveltosv1alpha1.FieldFilter) (bool, error) {
+	matchers, err := getFieldFilterMatchers(filters, compileRegex)
+	if err != nil {
+		return false, err
+	}
+	return areFieldFilterMatchersAMatch(object, matchers)
+}
@@ -0,0 +1,419 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: field resolution", func() {
+	var object map[string]interface{}
+
+	BeforeEach(func() {
+		object = map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"name": "nginx",
+			},
+			"spec": map[string]interface{}{
+				"replicas": int64(3),
+				"containers": []interface{}{
+					map[string]interface{}{"name": "nginx", "image": "nginx:1.14.2"},
+					map[string]interface{}{"name": "sidecar", "image": "envoy:1.24"},
+				},
+			},
+		}
+	})
+
+	It("resolveField resolves nested fields and expands arrays", func() {
+		values, err := classification.ResolveField(object, "metadata.name")
+		Expect(err).To(BeNil())
+		Expect(values).To(ConsistOf("nginx"))
+
+		values, err = classification.ResolveField(object, "spec.replicas")
+		Expect(err).To(BeNil())
+		Expect(values).To(ConsistOf("3"))
+
+		values, err = classification.ResolveField(object, "spec.containers.image")
+		Expect(err).To(BeNil())
+		Expect(values).To(ConsistOf("nginx:1.14.2", "envoy:1.24"))
+
+		values, err = classification.ResolveField(object, "spec.containers.1.name")
+		Expect(err).To(BeNil())
+		Expect(values).To(ConsistOf("sidecar"))
+
+		values, err = classification.ResolveField(object, "spec.nodeName")
+		Expect(err).To(BeNil())
+		Expect(values).To(BeEmpty())
+	})
+
+	It("resolveField values are not affected by following resolutions", func() {
+		images, err := classification.ResolveField(object, "spec.containers.image")
+		Expect(err).To(BeNil())
+
+		// Buffers are reused, including after a failed resolution
+		_, err = classification.ResolveField(object, strings.Repeat("a.", 40)+"a")
+		Expect(err).ToNot(BeNil())
+		names, err := classification.ResolveField(object, "spec.containers.name")
+		Expect(err).To(BeNil())
+
+		Expect(images).To(ConsistOf("nginx:1.14.2", "envoy:1.24"))
+		Expect(names).To(ConsistOf("nginx", "sidecar"))
+	})
+
+	It("resolveField returns FieldResolutionError when limits are exceeded", func() {
+		path := strings.Repeat("a.", 40) + "a"
+		_, err := classification.ResolveField(object, path)
+		Expect(err).ToNot(BeNil())
+		var resolutionErr *classification.FieldResolutionError
+		Expect(errors.As(err, &resolutionErr)).To(BeTrue())
+		Expect(resolutionErr.Path).To(Equal(path))
+
+		items := make([]interface{}, 2000)
+		for i := range items {
+			items[i] = map[string]interface{}{"key": "value"}
+		}
+		object["data"] = items
+		_, err = classification.ResolveField(object, "data.key")
+		Expect(errors.As(err, &resolutionErr)).To(BeTrue())
+
+		object["data"] = strings.Repeat("x", 128*1024)
+		_, err = classification.ResolveField(object, "data")
+		Expect(errors.As(err, &resolutionErr)).To(BeTrue())
+	})
+
+	It("areFieldFiltersAMatch evaluates Equal and Different operations", func() {
+		filters := []libsveltosv1alpha1.FieldFilter{
+			{Field: "spec.containers.image", Operation: libsveltosv1alpha1.OperationEqual, Value: "envoy:1.24"},
+		}
+		isMatch, err := classification.AreFieldFiltersAMatch(object, filters)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+
+		filters = append(filters, libsveltosv1alpha1.FieldFilter{
+			Field: "metadata.name", Operation: libsveltosv1alpha1.OperationDifferent, Value: "nginx",
+		})
+		isMatch, err = classification.AreFieldFiltersAMatch(object, filters)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeFalse())
+
+		filters = []libsveltosv1alpha1.FieldFilter{
+			{Field: "spec.nodeName", Operation: libsveltosv1alpha1.OperationDifferent, Value: "node1"},
+		}
+		isMatch, err = classification.AreFieldFiltersAMatch(object, filters)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+	})
+
+	It("resolveField accepts array indexes written with brackets", func() {
+		values, err := classification.ResolveField(object, "spec.containers[1].name")
+		Expect(err).To(BeNil())
+		Expect(values).To(ConsistOf("sidecar"))
+	})
+
+	It("resolveField accepts JSONPath wildcards and quoted fields", func() {
+		object["metadata"].(map[string]interface{})["labels"] = map[string]interface{}{
+			"app.kubernetes.io/name": "nginx",
+		}
+
+		values, err := classification.ResolveField(object, "spec.containers[*].image")
+		Expect(err).To(BeNil())
+		Expect(values).To(ConsistOf("nginx:1.14.2", "envoy:1.24"))
+
+		values, err = classification.ResolveField(object, "{.spec.containers[*].name}")
+		Expect(err).To(BeNil())
+		Expect(values).To(ConsistOf("nginx", "sidecar"))
+
+		values, err = classification.ResolveField(object, "$.spec.containers[0]['image']")
+		Expect(err).To(BeNil())
+		Expect(values).To(ConsistOf("nginx:1.14.2"))
+
+		values, err = classification.ResolveField(object, "metadata.labels['app.kubernetes.io/name']")
+		Expect(err).To(BeNil())
+		Expect(values).To(ConsistOf("nginx"))
+
+		values, err = classification.ResolveField(object, "metadata.labels[*]")
+		Expect(err).To(BeNil())
+		Expect(values).To(ConsistOf("nginx"))
+
+		for _, path := range []string{"spec.containers[", "spec.containers[x]", "spec..containers",
+			"spec.containers.", "spec.containers['']", ""} {
+			_, err = classification.ResolveField(object, path)
+			var resolutionErr *classification.FieldResolutionError
+			Expect(errors.As(err, &resolutionErr)).To(BeTrue(), path)
+		}
+	})
+
+	It("areFieldFiltersAMatch applies element match policies", func() {
+		for _, tc := range []struct {
+			filter  libsveltosv1alpha1.FieldFilter
+			policy  classification.ElementMatchPolicy
+			isMatch bool
+		}{
+			{libsveltosv1alpha1.FieldFilter{Field: "spec.containers[*].image",
+				Operation: classification.OperationMatchRegex, Value: "nginx:.*"}, "", true},
+			{libsveltosv1alpha1.FieldFilter{Field: "spec.containers[*].image",
+				Operation: classification.OperationMatchRegex, Value: "nginx:.*"}, classification.ElementMatchAll, false},
+			{libsveltosv1alpha1.FieldFilter{Field: "spec.containers[*].image",
+				Operation: classification.OperationMatchRegex, Value: ".*:1\\..*"}, classification.ElementMatchAll, true},
+			{libsveltosv1alpha1.FieldFilter{Field: "spec.containers[*].name",
+				Operation: libsveltosv1alpha1.OperationDifferent, Value: "nginx"}, "", false},
+			{libsveltosv1alpha1.FieldFilter{Field: "spec.containers[*].name",
+				Operation: libsveltosv1alpha1.OperationDifferent, Value: "nginx"}, classification.ElementMatchAny, true},
+			{libsveltosv1alpha1.FieldFilter{Field: "spec.initContainers[*].name",
+				Operation: libsveltosv1alpha1.OperationEqual, Value: "init"}, classification.ElementMatchAll, true},
+		} {
+			policies := map[string]classification.ElementMatchPolicy{}
+			if tc.policy != "" {
+				policies[tc.filter.Field] = tc.policy
+			}
+			isMatch, err := classification.AreFieldFiltersAMatchWithElements(object,
+				[]libsveltosv1alpha1.FieldFilter{tc.filter}, policies)
+			Expect(err).To(BeNil())
+			Expect(isMatch).To(Equal(tc.isMatch), tc.filter.Field+" "+string(tc.policy))
+		}
+
+		_, err := classification.AreFieldFiltersAMatchWithElements(object,
+			[]libsveltosv1alpha1.FieldFilter{{Field: "spec.replicas", Operation: libsveltosv1alpha1.OperationEqual,
+				Value: "3"}}, map[string]classification.ElementMatchPolicy{"spec.replicas": "Some"})
+		Expect(err).ToNot(BeNil())
+	})
+
+	It("getFieldSelectors pushes filters on selectable fields to the API server", func() {
+		podGK := schema.GroupKind{Kind: "Pod"}
+		filters := []libsveltosv1alpha1.FieldFilter{
+			{Field: "status.phase", Operation: libsveltosv1alpha1.OperationEqual, Value: "Running"},
+			{Field: "spec.nodeName", Operation: libsveltosv1alpha1.OperationDifferent, Value: "node1"},
+			{Field: "metadata.name", Operation: libsveltosv1alpha1.OperationEqual, Value: "a,b"},
+			// Not a selectable field
+			{Field: "spec.priorityClassName", Operation: libsveltosv1alpha1.OperationEqual, Value: "high"},
+			// Not Equal nor Different
+			{Field: "spec.schedulerName", Operation: classification.OperationMatchRegex, Value: "default.*"},
+			// Empty value
+			{Field: "spec.serviceAccountName", Operation: libsveltosv1alpha1.OperationEqual, Value: ""},
+			// Not a plain path
+			{Field: "spec.containers[*].image", Operation: libsveltosv1alpha1.OperationEqual, Value: "nginx"},
+		}
+		selector, remaining, err := classification.GetFieldSelectorsForFilters(podGK, filters, nil)
+		Expect(err).To(BeNil())
+		Expect(selector).To(Equal(`status.phase=Running,spec.nodeName!=node1,metadata.name=a\,b`))
+		Expect(remaining).To(Equal(4))
+
+		// Filters with a missing field policy are evaluated by classifier-agent
+		selector, remaining, err = classification.GetFieldSelectorsForFilters(podGK, filters[:1],
+			map[string]classification.MissingFieldPolicy{"status.phase": classification.MissingFieldTreatAsMatch})
+		Expect(err).To(BeNil())
+		Expect(selector).To(BeEmpty())
+		Expect(remaining).To(Equal(1))
+
+		// status.phase is not a selectable field on Deployments
+		selector, remaining, err = classification.GetFieldSelectorsForFilters(
+			schema.GroupKind{Group: "apps", Kind: "Deployment"}, filters[:1], nil)
+		Expect(err).To(BeNil())
+		Expect(selector).To(BeEmpty())
+		Expect(remaining).To(Equal(1))
+	})
+
+	It("areFieldFiltersAMatch compares numbers and quantities", func() {
+		containers := object["spec"].(map[string]interface{})["containers"].([]interface{})
+		containers[0].(map[string]interface{})["resources"] = map[string]interface{}{
+			"limits": map[string]interface{}{"cpu": "1500m", "memory": "2Gi"},
+		}
+
+		for _, tc := range []struct {
+			filter  libsveltosv1alpha1.FieldFilter
+			isMatch bool
+		}{
+			{libsveltosv1alpha1.FieldFilter{Field: "spec.replicas",
+				Operation: classification.OperationGreaterThan, Value: "2"}, true},
+			{libsveltosv1alpha1.FieldFilter{Field: "spec.replicas",
+				Operation: classification.OperationGreaterThan, Value: "3"}, false},
+			{libsveltosv1alpha1.FieldFilter{Field: "spec.replicas",
+				Operation: classification.OperationGreaterThanOrEqual, Value: "3"}, true},
+			{libsveltosv1alpha1.FieldFilter{Field: "spec.replicas",
+				Operation: classification.OperationLessThan, Value: "3"}, false},
+			{libsveltosv1alpha1.FieldFilter{Field: "spec.containers[0].resources.limits.cpu",
+				Operation: classification.OperationGreaterThanOrEqual, Value: "1"}, true},
+			{libsveltosv1alpha1.FieldFilter{Field: "spec.containers[0].resources.limits.cpu",
+				Operation: classification.OperationLessThanOrEqual, Value: "1500m"}, true},
+			{libsveltosv1alpha1.FieldFilter{Field: "spec.containers[0].resources.limits.memory",
+				Operation: classification.OperationLessThan, Value: "1Gi"}, false},
+			// Values which are not quantities never satisfy a comparison
+			{libsveltosv1alpha1.FieldFilter{Field: "metadata.name",
+				Operation: classification.OperationGreaterThan, Value: "0"}, false},
+			// Missing fields never satisfy a comparison
+			{libsveltosv1alpha1.FieldFilter{Field: "spec.containers[1].resources.limits.cpu",
+				Operation: classification.OperationLessThan, Value: "1"}, false},
+		} {
+			isMatch, err := classification.AreFieldFiltersAMatch(object, []libsveltosv1alpha1.FieldFilter{tc.filter})
+			Expect(err).To(BeNil())
+			Expect(isMatch).To(Equal(tc.isMatch), "filter %+v", tc.filter)
+		}
+
+		_, err := classification.AreFieldFiltersAMatch(object, []libsveltosv1alpha1.FieldFilter{
+			{Field: "spec.replicas", Operation: classification.OperationGreaterThan, Value: "three"},
+		})
+		Expect(err).ToNot(BeNil())
+	})
+
+	It("areFieldFiltersAMatch compares timestamps against durations", func() {
+		object["metadata"].(map[string]interface{})["creationTimestamp"] =
+			time.Now().Add(-96 * time.Hour).UTC().Format(time.RFC3339)
+		object["status"] = map[string]interface{}{
+			"conditions": []interface{}{
+				map[string]interface{}{"type": "Ready",
+					"lastTransitionTime": time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)},
+			},
+		}
+
+		for _, tc := range []struct {
+			filter  libsveltosv1alpha1.FieldFilter
+			isMatch bool
+		}{
+			{libsveltosv1alpha1.FieldFilter{Field: "metadata.creationTimestamp",
+				Operation: classification.OperationOlderThan, Value: "72h"}, true},
+			{libsveltosv1alpha1.FieldFilter{Field: "metadata.creationTimestamp",
+				Operation: classification.OperationNewerThan, Value: "72h"}, false},
+			{libsveltosv1alpha1.FieldFilter{Field: "status.conditions.lastTransitionTime",
+				Operation: classification.OperationNewerThan, Value: "10m"}, true},
+			{libsveltosv1alpha1.FieldFilter{Field: "status.conditions.lastTransitionTime",
+				Operation: classification.OperationOlderThan, Value: "10m"}, false},
+			// Values which are not timestamps never satisfy an age filter
+			{libsveltosv1alpha1.FieldFilter{Field: "metadata.name",
+				Operation: classification.OperationOlderThan, Value: "1s"}, false},
+		} {
+			isMatch, err := classification.AreFieldFiltersAMatch(object, []libsveltosv1alpha1.FieldFilter{tc.filter})
+			Expect(err).To(BeNil())
+			Expect(isMatch).To(Equal(tc.isMatch), "filter %+v", tc.filter)
+		}
+
+		_, err := classification.AreFieldFiltersAMatch(object, []libsveltosv1alpha1.FieldFilter{
+			{Field: "metadata.creationTimestamp", Operation: classification.OperationOlderThan, Value: "3 days"},
+		})
+		Expect(err).ToNot(BeNil())
+	})
+
+	It("missing field policies decide how resources without the field are evaluated", func() {
+		resources := make([]unstructured.Unstructured, 0)
+		for _, nodeName := range []string{"node1", "node2", ""} {
+			spec := map[string]interface{}{}
+			if nodeName != "" {
+				spec["nodeName"] = nodeName
+			}
+			resources = append(resources, unstructured.Unstructured{Object: map[string]interface{}{
+				"metadata": map[string]interface{}{"name": randomString()},
+				"spec":     spec,
+			}})
+		}
+
+		filters := []libsveltosv1alpha1.FieldFilter{
+			{Field: "spec.nodeName", Operation: libsveltosv1alpha1.OperationDifferent, Value: "node1"},
+		}
+
+		filter := func(policies map[string]classification.MissingFieldPolicy) (int, int, []string) {
+			input := make([]unstructured.Unstructured, len(resources))
+			copy(input, resources)
+			items, skipped, notes, err := classification.FilterResourcesWithMissingFields(input, filters, policies)
+			Expect(err).To(BeNil())
+			return len(items), skipped, notes
+		}
+
+		// Default: a missing field satisfies Different
+		matching, skipped, notes := filter(nil)
+		Expect(matching).To(Equal(2))
+		Expect(skipped).To(BeZero())
+		Expect(notes).To(BeEmpty())
+
+		matching, skipped, notes = filter(map[string]classification.MissingFieldPolicy{
+			"spec.nodeName": classification.MissingFieldTreatAsNoMatch})
+		Expect(matching).To(Equal(1))
+		Expect(skipped).To(BeZero())
+		Expect(notes).To(ConsistOf(`Pod: 1 resources missing field "spec.nodeName" treated as no match`))
+
+		matching, skipped, _ = filter(map[string]classification.MissingFieldPolicy{
+			"spec.nodeName": classification.MissingFieldTreatAsMatch})
+		Expect(matching).To(Equal(2))
+		Expect(skipped).To(BeZero())
+
+		matching, skipped, notes = filter(map[string]classification.MissingFieldPolicy{
+			"spec.nodeName": classification.MissingFieldSkip})
+		Expect(matching).To(Equal(1))
+		Expect(skipped).To(Equal(1))
+		Expect(notes).To(ConsistOf(`Pod: 1 resources missing field "spec.nodeName" skipped`))
+
+		// Skip wins over other filters not being satisfied
+		filters = append(filters, libsveltosv1alpha1.FieldFilter{
+			Field: "metadata.name", Operation: libsveltosv1alpha1.OperationEqual, Value: "none"})
+		matching, skipped, _ = filter(map[string]classification.MissingFieldPolicy{
+			"spec.nodeName": classification.MissingFieldSkip})
+		Expect(matching).To(BeZero())
+		Expect(skipped).To(Equal(1))
+	})
+
+	It("missing field policies are validated", func() {
+		filters := []libsveltosv1alpha1.FieldFilter{
+			{Field: "spec.nodeName", Operation: libsveltosv1alpha1.OperationEqual, Value: "node1"},
+		}
+		_, _, _, err := classification.FilterResourcesWithMissingFields(nil, filters,
+			map[string]classification.MissingFieldPolicy{"spec.nodeName": "Ignore"})
+		Expect(err).ToNot(BeNil())
+
+		_, _, _, err = classification.FilterResourcesWithMissingFields(nil, filters,
+			map[string]classification.MissingFieldPolicy{"spec.other": classification.MissingFieldSkip})
+		Expect(err).ToNot(BeNil())
+	})
+
+	It("filterResources keeps, in order, only resources matching field filters", func() {
+		resources := make([]unstructured.Unstructured, 0)
+		for _, image := range []string{"nginx:1.14.2", "envoy:1.24", "nginx:1.14.2"} {
+			resources = append(resources, unstructured.Unstructured{Object: map[string]interface{}{
+				"metadata": map[string]interface{}{"name": randomString()},
+				"spec": map[string]interface{}{
+					"containers": []interface{}{map[string]interface{}{"image": image}},
+				},
+			}})
+		}
+		first := resources[0].GetName()
+		last := resources[2].GetName()
+
+		filters := []libsveltosv1alpha1.FieldFilter{
+			{Field: "spec.containers.image", Operation: libsveltosv1alpha1.OperationEqual, Value: "nginx:1.14.2"},
+		}
+		items, err := classification.FilterResources(resources, filters, nil, "")
+		Expect(err).To(BeNil())
+		Expect(len(items)).To(Equal(2))
+		Expect(items[0].GetName()).To(Equal(first))
+		Expect(items[1].GetName()).To(Equal(last))
+
+		filters = []libsveltosv1alpha1.FieldFilter{
+			{Field: strings.Repeat("a.", 40) + "a", Operation: libsveltosv1alpha1.OperationEqual, Value: "a"},
+		}
+		_, err = classification.FilterResources(items, filters, nil, "")
+		Expect(err).ToNot(BeNil())
+		Expect(classification.IsInvalidClassifierError(err)).To(BeTrue())
+	})
+})
@@ -0,0 +1,137 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+// selectableFields contains, per GroupKind, the fields the API server supports
+// field selectors on, besides metadata.name and metadata.namespace which are
+// supported for all resources
+var selectableFields = map[schema.GroupKind]map[string]bool{
+	{Kind: "Pod"}: {
+		"spec.nodeName": true, "spec.restartPolicy": true, "spec.schedulerName": true,
+		"spec.serviceAccountName": true, "spec.hostNetwork": true,
+		"status.phase": true, "status.podIP": true, "status.nominatedNodeName": true,
+	},
+	{Kind: "Event"}: {
+		"involvedObject.kind": true, "involvedObject.namespace": true, "involvedObject.name": true,
+		"involvedObject.uid": true, "involvedObject.apiVersion": true,
+		"involvedObject.resourceVersion": true, "involvedObject.fieldPath": true,
+		"reason": true, "reportingComponent": true, "source": true, "type": true,
+	},
+	{Kind: "Secret"}:                {"type": true},
+	{Kind: "Node"}:                  {"spec.unschedulable": true},
+	{Kind: "Namespace"}:             {"status.phase": true},
+	{Kind: "ReplicationController"}: {"status.replicas": true},
+	{Group: "batch", Kind: "Job"}:   {"status.successful": true},
+	{Group: "certificates.k8s.io", Kind: "CertificateSigningRequest"}: {"spec.signerName": true},
+}
+
+// isSelectableField returns true if the API server supports field selectors
+// on field for resources of kind gk
+func isSelectableField(gk schema.GroupKind, field string) bool {
+	if field == "metadata.name" || field == "metadata.namespace" {
+		return true
+	}
+	return selectableFields[gk][field]
+}
+
+// getFieldSelectors returns the field selectors for the matchers which can be
+// evaluated by the API server, along with the matchers which must still be
+// evaluated by classifier-agent.
+// Only Equal and Different filters, with a value and no missing field nor element
+// match policy, on a selectable field written as a plain dot separated path are
+// pushed to the API server.
+func getFieldSelectors(gk schema.GroupKind, matchers []fieldFilterMatcher) ([]string, []fieldFilterMatcher) {
+	var selectors []string
+	remaining := make([]fieldFilterMatcher, 0, len(matchers))
+	for i := range matchers {
+		selector, ok := getFieldSelector(gk, &matchers[i])
+		if !ok {
+			remaining = append(remaining, matchers[i])
+			continue
+		}
+		selectors = append(selectors, selector)
+	}
+	return selectors, remaining
+}
+
+func getFieldSelector(gk schema.GroupKind, matcher *fieldFilterMatcher) (string, bool) {
+	// An empty field selector value also selects resources missing the field,
+	// which FieldFilters do not
+	if matcher.missing != "" || matcher.elements != "" || matcher.filter.Value == "" {
+		return "", false
+	}
+
+	var operator string
+	switch matcher.filter.Operation {
+	case libsveltosv1alpha1.OperationEqual:
+		operator = "="
+	case libsveltosv1alpha1.OperationDifferent:
+		operator = "!="
+	default:
+		return "", false
+	}
+
+	names := make([]string, len(matcher.segments))
+	for i := range matcher.segments {
+		segment := &matcher.segments[i]
+		if segment.kind != fieldSegmentName || segment.index >= 0 {
+			return "", false
+		}
+		names[i] = segment.name
+	}
+	field := strings.Join(names, ".")
+	if !isSelectableField(gk, field) {
+		return "", false
+	}
+
+	return fmt.Sprintf("%s%s%s", field, operator, fields.EscapeValue(matcher.filter.Value)), true
+}
+
+// joinFieldSelectors returns a field selector requiring all selectors
+func joinFieldSelectors(selectors ...string) string {
+	nonEmpty := make([]string, 0, len(selectors))
+	for i := range selectors {
+		if selectors[i] != "" {
+			nonEmpty = append(nonEmpty, selectors[i])
+		}
+	}
+	return strings.Join(nonEmpty, ",")
+}
+
+// getExcludedNamespacesSelectors returns the field selectors filtering out
+// resources in the excluded namespaces
+func getExcludedNamespacesSelectors(excludedNamespaces []string) ([]string, error) {
+	selectors := make([]string, 0, len(excludedNamespaces))
+	for i := range excludedNamespaces {
+		if excludedNamespaces[i] == "" {
+			return nil, fmt.Errorf("excludedNamespaces contains an empty namespace")
+		}
+		selectors = append(selectors,
+			fmt.Sprintf("metadata.namespace!=%s", fields.EscapeValue(excludedNamespaces[i])))
+	}
+	return selectors, nil
+}
@@ -0,0 +1,46 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// isReconciled returns true if resource status.observedGeneration equals its
+// metadata.generation, meaning its controller has acted on the current spec.
+// Resources without status.observedGeneration are not reconciled.
+func isReconciled(resource *unstructured.Unstructured) bool {
+	observedGeneration, found, err := unstructured.NestedFieldNoCopy(resource.Object, "status", "observedGeneration")
+	if err != nil || !found {
+		return false
+	}
+
+	var observed int64
+	switch v := observedGeneration.(type) {
+	case int64:
+		observed = v
+	case int32:
+		observed = int64(v)
+	case int:
+		observed = int64(v)
+	case float64:
+		observed = int64(v)
+	default:
+		return false
+	}
+	return observed == resource.GetGeneration()
+}
@@ -0,0 +1,57 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+)
+
+var _ = Describe("Manager: reconciled resources", func() {
+	It("ReconciledOnly selects resources whose observedGeneration equals generation", func() {
+		getResource := func(name string, generation int64, observedGeneration interface{}) unstructured.Unstructured {
+			u := unstructured.Unstructured{Object: map[string]interface{}{}}
+			u.SetName(name)
+			u.SetGeneration(generation)
+			if observedGeneration != nil {
+				Expect(unstructured.SetNestedField(u.Object, observedGeneration, "status", "observedGeneration")).
+					To(Succeed())
+			}
+			return u
+		}
+
+		resources := []unstructured.Unstructured{
+			getResource("reconciled", 3, int64(3)),
+			getResource("pending", 4, int64(3)),
+			getResource("no-status", 1, nil),
+			// Numbers decoded from JSON without type information are float64
+			getResource("decoded", 2, float64(2)),
+		}
+
+		items, err := classification.FilterReconciledResources(resources)
+		Expect(err).To(BeNil())
+		names := make([]string, len(items))
+		for i := range items {
+			names[i] = items[i].GetName()
+		}
+		Expect(names).To(ConsistOf("reconciled", "decoded"))
+	})
+})
@@ -0,0 +1,266 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"emperror.dev/errors"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+)
+
+const (
+	// HandshakeLeaseName is the name of the Lease held by the classifier-agent instance
+	// evaluating Classifiers and delivering ClassifierReports
+	HandshakeLeaseName = "classifier-agent-handshake"
+
+	// HandoverRequestAnnotation is set on the handshake Lease by an instance waiting
+	// for it. Its value is the identity of the waiting instance.
+	HandoverRequestAnnotation = "classifier.projectsveltos.io/handover-requested-by"
+
+	// DefaultHandshakeLeaseDuration is the handshake Lease duration used when none is set
+	DefaultHandshakeLeaseDuration = 15 * time.Second
+)
+
+// errHandshakeNotHeld is returned when a Classifier is not evaluated because
+// this instance does not hold the handshake Lease
+var errHandshakeNotHeld = errors.New("handshake lease not held")
+
+type handshakeState int
+
+const (
+	// handshakeWaiting: another instance holds the Lease. Nothing is evaluated nor delivered.
+	handshakeWaiting handshakeState = iota
+	// handshakeHolding: this instance holds the Lease
+	handshakeHolding
+	// handshakeHandingOver: this instance holds the Lease and an incoming instance
+	// requested it. Queued Classifiers are evaluated a last time, then the Lease is
+	// handed over.
+	handshakeHandingOver
+)
+
+// upgradeHandshake makes sure, during a rolling update, only one classifier-agent
+// instance evaluates Classifiers and delivers ClassifierReports at a time
+type upgradeHandshake struct {
+	namespace     string
+	identity      string
+	leaseDuration time.Duration
+
+	mu    *sync.Mutex
+	state handshakeState
+	// handedOver is set once this instance handed the Lease over to an incoming
+	// instance. A handed over Lease is never requested back.
+	handedOver bool
+}
+
+func (h *upgradeHandshake) getState() handshakeState {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.state
+}
+
+func (h *upgradeHandshake) setState(state handshakeState) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.state = state
+}
+
+func (h *upgradeHandshake) hasHandedOver() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.handedOver
+}
+
+func (h *upgradeHandshake) setHandedOver() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.handedOver = true
+}
+
+// holdsHandshake returns true if this instance can evaluate Classifiers. Always
+// true when handshake is not enabled.
+func (m *manager) holdsHandshake() bool {
+	return m.handshake == nil || m.handshake.getState() != handshakeWaiting
+}
+
+// runHandshake acquires and renews the handshake Lease every third of the
+// Lease duration. When ctx is cancelled, in-flight evaluation is completed and
+// the Lease is released so an incoming instance does not wait for it to expire.
+func (m *manager) runHandshake(ctx context.Context) {
+	ticker := time.NewTicker(m.handshake.leaseDuration / 3)
+	defer ticker.Stop()
+
+	for {
+		if err := m.syncHandshake(ctx); err != nil {
+			m.log.V(logs.LogInfo).Info(fmt.Sprintf("failed to sync handshake lease: %v", err))
+		}
+
+		select {
+		case <-ctx.Done():
+			// Wait for in-flight evaluation
+			m.evaluationMu.Lock()
+			m.handshake.setState(handshakeWaiting)
+			m.evaluationMu.Unlock()
+
+			releaseCtx, cancel := context.WithTimeout(context.Background(), m.handshake.leaseDuration)
+			if err := m.releaseHandshake(releaseCtx); err != nil {
+				m.log.V(logs.LogInfo).Info(fmt.Sprintf("failed to release handshake lease: %v", err))
+			}
+			cancel()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// syncHandshake acquires the handshake Lease if it is free or expired and renews it
+// if this instance holds it. If another instance holds it, a handover is requested,
+// unless this instance already handed the Lease over.
+func (m *manager) syncHandshake(ctx context.Context) error {
+	h := m.handshake
+	now := metav1.NewMicroTime(time.Now())
+
+	lease := &coordinationv1.Lease{}
+	err := m.Get(ctx, types.NamespacedName{Namespace: h.namespace, Name: HandshakeLeaseName}, lease)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		lease.Namespace = h.namespace
+		lease.Name = HandshakeLeaseName
+		m.setHandshakeHolder(lease, h.identity, now)
+		if err := m.Create(ctx, lease); err != nil {
+			return err
+		}
+		m.log.V(logs.LogInfo).Info("acquired handshake lease")
+		h.setState(handshakeHolding)
+		return nil
+	}
+
+	holder := getLeaseHolder(lease)
+	requester := lease.Annotations[HandoverRequestAnnotation]
+	switch {
+	case holder == h.identity:
+		lease.Spec.RenewTime = &now
+		if err := m.Update(ctx, lease); err != nil {
+			return err
+		}
+		if requester != "" && requester != h.identity {
+			if h.getState() != handshakeHandingOver {
+				m.log.V(logs.LogInfo).Info(fmt.Sprintf("handshake lease requested by %s", requester))
+			}
+			h.setState(handshakeHandingOver)
+		} else {
+			h.setState(handshakeHolding)
+		}
+	case holder == "" || isLeaseExpired(lease, now.Time):
+		m.setHandshakeHolder(lease, h.identity, now)
+		if err := m.Update(ctx, lease); err != nil {
+			return err
+		}
+		m.log.V(logs.LogInfo).Info(fmt.Sprintf("acquired handshake lease (previous holder %q)", holder))
+		h.setState(handshakeHolding)
+	default:
+		h.setState(handshakeWaiting)
+		if requester == "" && !h.hasHandedOver() {
+			if lease.Annotations == nil {
+				lease.Annotations = map[string]string{}
+			}
+			lease.Annotations[HandoverRequestAnnotation] = h.identity
+			if err := m.Update(ctx, lease); err != nil {
+				return err
+			}
+			m.log.V(logs.LogInfo).Info(fmt.Sprintf("requested handshake lease to %s", holder))
+		}
+	}
+	return nil
+}
+
+// releaseHandshake hands the handshake Lease over to the instance which requested it,
+// or frees it when none did. Evaluation stops before the Lease is released.
+func (m *manager) releaseHandshake(ctx context.Context) error {
+	h := m.handshake
+	h.setState(handshakeWaiting)
+
+	lease := &coordinationv1.Lease{}
+	err := m.Get(ctx, types.NamespacedName{Namespace: h.namespace, Name: HandshakeLeaseName}, lease)
+	if err != nil {
+		return err
+	}
+	if getLeaseHolder(lease) != h.identity {
+		return nil
+	}
+
+	requester := lease.Annotations[HandoverRequestAnnotation]
+	m.setHandshakeHolder(lease, requester, metav1.NewMicroTime(time.Now()))
+	if err := m.Update(ctx, lease); err != nil {
+		return err
+	}
+	if requester != "" {
+		h.setHandedOver()
+	}
+	m.log.V(logs.LogInfo).Info(fmt.Sprintf("released handshake lease to %q", requester))
+	return nil
+}
+
+// setHandshakeHolder makes holder the handshake Lease holder and clears the
+// handover request. Lease is freed when holder is empty.
+func (m *manager) setHandshakeHolder(lease *coordinationv1.Lease, holder string, now metav1.MicroTime) {
+	delete(lease.Annotations, HandoverRequestAnnotation)
+	if getLeaseHolder(lease) != holder && lease.Spec.HolderIdentity != nil {
+		transitions := int32(1)
+		if lease.Spec.LeaseTransitions != nil {
+			transitions = *lease.Spec.LeaseTransitions + 1
+		}
+		lease.Spec.LeaseTransitions = &transitions
+	}
+
+	if holder == "" {
+		lease.Spec.HolderIdentity = nil
+		return
+	}
+
+	leaseDurationSeconds := int32(m.handshake.leaseDuration / time.Second)
+	lease.Spec.HolderIdentity = &holder
+	lease.Spec.LeaseDurationSeconds = &leaseDurationSeconds
+	lease.Spec.AcquireTime = &now
+	lease.Spec.RenewTime = &now
+}
+
+func getLeaseHolder(lease *coordinationv1.Lease) string {
+	if lease.Spec.HolderIdentity == nil {
+		return ""
+	}
+	return *lease.Spec.HolderIdentity
+}
+
+// isLeaseExpired returns true if lease was not renewed within its duration
+func isLeaseExpired(lease *coordinationv1.Lease, now time.Time) bool {
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+	expiration := lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second)
+	return now.After(expiration)
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+)
+
+var _ = Describe("Upgrade handshake", func() {
+	const namespace = "projectsveltos"
+	var c client.Client
+
+	BeforeEach(func() {
+		c = fake.NewClientBuilder().Build()
+	})
+
+	getLease := func() *coordinationv1.Lease {
+		lease := &coordinationv1.Lease{}
+		Expect(c.Get(context.TODO(),
+			types.NamespacedName{Namespace: namespace, Name: classification.HandshakeLeaseName}, lease)).To(Succeed())
+		return lease
+	}
+
+	It("outgoing instance hands the Lease over to the incoming one", func() {
+		outgoing := classification.NewHandshakeManager(c, namespace, "agent-old", time.Minute)
+		incoming := classification.NewHandshakeManager(c, namespace, "agent-new", time.Minute)
+
+		Expect(classification.HoldsHandshake(outgoing)).To(BeFalse())
+		Expect(classification.SyncHandshake(outgoing, context.TODO())).To(Succeed())
+		Expect(classification.HoldsHandshake(outgoing)).To(BeTrue())
+		Expect(*getLease().Spec.HolderIdentity).To(Equal("agent-old"))
+
+		// Incoming instance waits and requests the Lease
+		Expect(classification.SyncHandshake(incoming, context.TODO())).To(Succeed())
+		Expect(classification.HoldsHandshake(incoming)).To(BeFalse())
+		Expect(getLease().Annotations).To(HaveKeyWithValue(classification.HandoverRequestAnnotation, "agent-new"))
+
+		// Outgoing instance keeps evaluating till its queue is flushed
+		Expect(classification.SyncHandshake(outgoing, context.TODO())).To(Succeed())
+		Expect(classification.IsHandingOverHandshake(outgoing)).To(BeTrue())
+		Expect(classification.HoldsHandshake(outgoing)).To(BeTrue())
+
+		Expect(classification.ReleaseHandshake(outgoing, context.TODO())).To(Succeed())
+		Expect(classification.HoldsHandshake(outgoing)).To(BeFalse())
+		lease := getLease()
+		Expect(*lease.Spec.HolderIdentity).To(Equal("agent-new"))
+		Expect(lease.Annotations).ToNot(HaveKey(classification.HandoverRequestAnnotation))
+		Expect(*lease.Spec.LeaseTransitions).To(Equal(int32(1)))
+
+		Expect(classification.SyncHandshake(incoming, context.TODO())).To(Succeed())
+		Expect(classification.HoldsHandshake(incoming)).To(BeTrue())
+
+		// Outgoing instance does not take the Lease back
+		Expect(classification.SyncHandshake(outgoing, context.TODO())).To(Succeed())
+		Expect(classification.HoldsHandshake(outgoing)).To(BeFalse())
+	})
+
+	It("outgoing instance never requests back a handed over Lease", func() {
+		outgoing := classification.NewHandshakeManager(c, namespace, "agent-old", time.Minute)
+		incoming := classification.NewHandshakeManager(c, namespace, "agent-new", time.Minute)
+
+		Expect(classification.SyncHandshake(outgoing, context.TODO())).To(Succeed())
+		Expect(classification.SyncHandshake(incoming, context.TODO())).To(Succeed())
+		Expect(classification.SyncHandshake(outgoing, context.TODO())).To(Succeed())
+		Expect(classification.ReleaseHandshake(outgoing, context.TODO())).To(Succeed())
+
+		// Both instances keep syncing till the outgoing one exits
+		for i := 0; i < 3; i++ {
+			Expect(classification.SyncHandshake(outgoing, context.TODO())).To(Succeed())
+			Expect(classification.HoldsHandshake(outgoing)).To(BeFalse())
+			Expect(getLease().Annotations).ToNot(HaveKey(classification.HandoverRequestAnnotation))
+
+			Expect(classification.SyncHandshake(incoming, context.TODO())).To(Succeed())
+			Expect(classification.HoldsHandshake(incoming)).To(BeTrue())
+			Expect(classification.IsHandingOverHandshake(incoming)).To(BeFalse())
+			Expect(*getLease().Spec.HolderIdentity).To(Equal("agent-new"))
+		}
+	})
+
+	It("Lease is taken over once expired and freed on release with no request", func() {
+		crashed := classification.NewHandshakeManager(c, namespace, "agent-crashed", time.Minute)
+		Expect(classification.SyncHandshake(crashed, context.TODO())).To(Succeed())
+
+		lease := getLease()
+		renewTime := metav1.NewMicroTime(time.Now().Add(-2 * time.Minute))
+		lease.Spec.RenewTime = &renewTime
+		Expect(c.Update(context.TODO(), lease)).To(Succeed())
+
+		instance := classification.NewHandshakeManager(c, namespace, "agent", time.Minute)
+		Expect(classification.SyncHandshake(instance, context.TODO())).To(Succeed())
+		Expect(classification.HoldsHandshake(instance)).To(BeTrue())
+		Expect(*getLease().Spec.HolderIdentity).To(Equal("agent"))
+
+		Expect(classification.ReleaseHandshake(instance, context.TODO())).To(Succeed())
+		Expect(getLease().Spec.HolderIdentity).To(BeNil())
+
+		// A free Lease is acquired right away
+		Expect(classification.SyncHandshake(crashed, context.TODO())).To(Succeed())
+		Expect(classification.HoldsHandshake(crashed)).To(BeTrue())
+	})
+})
@@ -0,0 +1,237 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+)
+
+const (
+	// ClassifierReportClusterUnhealthyAnnotation is set on all ClassifierReports while
+	// the health gate fails. Classifiers are not evaluated then, so reports keep the
+	// verdict of the last evaluation. Value is a JSON encoded ClusterUnhealthy condition.
+	ClassifierReportClusterUnhealthyAnnotation = "classifier.projectsveltos.io/cluster-unhealthy"
+
+	// ClusterUnhealthyCondition is the type of the condition set while the health
+	// gate fails
+	ClusterUnhealthyCondition = "ClusterUnhealthy"
+
+	// ReasonAPIServerNotReady indicates API server /readyz endpoint did not report ready
+	ReasonAPIServerNotReady = "APIServerNotReady"
+
+	// ReasonDiscoveryFailed indicates API server discovery failed or did not return the
+	// core API group
+	ReasonDiscoveryFailed = "DiscoveryFailed"
+
+	// DefaultHealthGateTimeout bounds each health gate check
+	DefaultHealthGateTimeout = 5 * time.Second
+
+	// maxReadyzMessage is the maximum length of the /readyz response reported in
+	// the ClusterUnhealthy condition
+	maxReadyzMessage = 256
+)
+
+var clusterUnhealthy = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "cluster_unhealthy",
+		Help:      "1 while the health gate fails and evaluation cycles are skipped, 0 otherwise",
+	},
+)
+
+func init() {
+	metrics.Registry.MustRegister(clusterUnhealthy)
+}
+
+// isClusterHealthy runs the health gate, if enabled, before an evaluation cycle. When the
+// cluster becomes unhealthy, or healthy again, all ClassifierReports are updated.
+func (m *manager) isClusterHealthy(ctx context.Context) bool {
+	if !m.healthGate {
+		return true
+	}
+
+	reason, message := m.checkClusterHealth(ctx)
+	if m.setClusterHealth(reason, message, time.Now()) {
+		if err := m.refreshAgentDegraded(ctx); err != nil {
+			m.log.V(logs.LogInfo).Info(fmt.Sprintf("failed to update ClassifierReports cluster unhealthy condition: %v", err))
+		}
+	}
+	return reason == ""
+}
+
+// healthClients are built on the first health gate check and reused by the next ones
+type healthClients struct {
+	httpClient *http.Client
+	readyzURL  string
+	discovery  discovery.DiscoveryInterface
+}
+
+// getHealthClients returns the clients used by the health gate. All requests share
+// one HTTP client, whose timeout bounds each request.
+func (m *manager) getHealthClients() (*healthClients, error) {
+	m.healthMu.Lock()
+	defer m.healthMu.Unlock()
+
+	if m.healthClients != nil {
+		return m.healthClients, nil
+	}
+
+	config := rest.CopyConfig(m.config)
+	config.Timeout = m.healthGateTimeout
+	if config.Timeout == 0 {
+		config.Timeout = DefaultHealthGateTimeout
+	}
+
+	httpClient, err := rest.HTTPClientFor(config)
+	if err != nil {
+		return nil, err
+	}
+	serverURL, _, err := rest.DefaultServerUrlFor(config)
+	if err != nil {
+		return nil, err
+	}
+	serverURL.Path = "/readyz"
+
+	dc, err := discovery.NewDiscoveryClientForConfigAndClient(config, httpClient)
+	if err != nil {
+		return nil, err
+	}
+
+	m.healthClients = &healthClients{httpClient: httpClient, readyzURL: serverURL.String(), discovery: dc}
+	return m.healthClients, nil
+}
+
+// checkClusterHealth verifies API server reports ready and discovery returns the core API group.
+// Returns the reason and message of the failing check, empty strings if all checks passed.
+func (m *manager) checkClusterHealth(ctx context.Context) (reason, message string) {
+	clients, err := m.getHealthClients()
+	if err != nil {
+		return ReasonAPIServerNotReady, err.Error()
+	}
+
+	if err := checkReadyz(ctx, clients.httpClient, clients.readyzURL); err != nil {
+		return ReasonAPIServerNotReady, err.Error()
+	}
+
+	groups, err := clients.discovery.ServerGroups()
+	if err != nil {
+		return ReasonDiscoveryFailed, fmt.Sprintf("failed to discover API groups: %v", err)
+	}
+	if !isCoreGroupServed(groups) {
+		return ReasonDiscoveryFailed, "discovery does not return the core API group"
+	}
+	return "", ""
+}
+
+// isCoreGroupServed returns true if groups contains the core API group with at least
+// one version. A partially started API server can return an empty discovery.
+func isCoreGroupServed(groups *metav1.APIGroupList) bool {
+	if groups == nil {
+		return false
+	}
+	for i := range groups.Groups {
+		if groups.Groups[i].Name == "" && len(groups.Groups[i].Versions) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// checkReadyz returns an error if API server /readyz endpoint does not respond with 200
+func checkReadyz(ctx context.Context, httpClient *http.Client, readyzURL string) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, readyzURL, http.NoBody)
+	if err != nil {
+		return err
+	}
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, maxReadyzMessage))
+		return fmt.Errorf("API server /readyz returned %d: %s", response.StatusCode,
+			strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// setClusterHealth updates the ClusterUnhealthy condition. An empty reason means the
+// cluster is healthy. Returns true if the cluster became unhealthy, healthy again, or
+// is unhealthy for a different reason.
+func (m *manager) setClusterHealth(reason, message string, now time.Time) bool {
+	m.healthMu.Lock()
+	defer m.healthMu.Unlock()
+
+	if reason == "" {
+		if m.unhealthy == nil {
+			return false
+		}
+		m.log.V(logs.LogInfo).Info("cluster is healthy again. Resuming Classifier evaluations")
+		m.unhealthy = nil
+		clusterUnhealthy.Set(0)
+		return true
+	}
+
+	if m.unhealthy != nil && m.unhealthy.Reason == reason {
+		return false
+	}
+	m.log.V(logs.LogInfo).Info(fmt.Sprintf("cluster is unhealthy, skipping Classifier evaluations: %s", message))
+	m.unhealthy = &metav1.Condition{
+		Type:               ClusterUnhealthyCondition,
+		Status:             metav1.ConditionTrue,
+		Reason:             reason,
+		Message:            message,
+		LastTransitionTime: metav1.NewTime(now),
+	}
+	clusterUnhealthy.Set(1)
+	return true
+}
+
+// setReportClusterUnhealthy sets ClassifierReportClusterUnhealthyAnnotation while
+// the health gate fails and removes it otherwise
+func (m *manager) setReportClusterUnhealthy(report *libsveltosv1alpha1.ClassifierReport) {
+	m.healthMu.Lock()
+	defer m.healthMu.Unlock()
+
+	value := ""
+	if m.unhealthy != nil {
+		data, err := json.Marshal(m.unhealthy)
+		if err != nil {
+			m.log.V(logs.LogInfo).Info(fmt.Sprintf("failed to encode cluster unhealthy condition: %v", err))
+		} else {
+			value = string(data)
+		}
+	}
+	setReportAnnotation(report, ClassifierReportClusterUnhealthyAnnotation, value)
+}
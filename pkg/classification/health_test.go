@@ -0,0 +1,131 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+
+	"github.com/projectsveltos/classifier-agent/internal/utils"
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: health gate", func() {
+	var server *httptest.Server
+	var readyzStatus int
+	var serveAPIVersions bool
+
+	BeforeEach(func() {
+		classification.Reset()
+
+		readyzStatus = http.StatusOK
+		serveAPIVersions = true
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/readyz":
+				w.WriteHeader(readyzStatus)
+				if readyzStatus == http.StatusOK {
+					_, _ = w.Write([]byte("ok"))
+				} else {
+					_, _ = w.Write([]byte("[-]etcd failed: reason withheld"))
+				}
+			case "/api":
+				w.Header().Set("Content-Type", "application/json")
+				versions := `[]`
+				if serveAPIVersions {
+					versions = `["v1"]`
+				}
+				_, _ = w.Write([]byte(`{"kind":"APIVersions","versions":` + versions + `}`))
+			case "/apis":
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"kind":"APIGroupList","apiVersion":"v1","groups":[]}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("checkClusterHealth verifies API server readiness and discovery", func() {
+		classification.InitializeManagerWithObjects(&rest.Config{Host: server.URL})
+		manager := classification.GetManager()
+
+		reason, message := classification.CheckClusterHealth(manager, context.TODO())
+		Expect(reason).To(BeEmpty())
+		Expect(message).To(BeEmpty())
+
+		readyzStatus = http.StatusInternalServerError
+		reason, message = classification.CheckClusterHealth(manager, context.TODO())
+		Expect(reason).To(Equal(classification.ReasonAPIServerNotReady))
+		Expect(message).To(ContainSubstring("etcd failed"))
+
+		readyzStatus = http.StatusOK
+		serveAPIVersions = false
+		reason, _ = classification.CheckClusterHealth(manager, context.TODO())
+		Expect(reason).To(Equal(classification.ReasonDiscoveryFailed))
+	})
+
+	It("isClusterHealthy marks ClassifierReports while cluster is unhealthy and keeps match", func() {
+		classifier := getClassifierWithKubernetesConstraints(version25, libsveltosv1alpha1.ComparisonEqual)
+		c := classification.InitializeManagerWithObjects(&rest.Config{Host: server.URL}, classifier)
+		manager := classification.GetManager()
+
+		Expect(classification.CreateClassifierReport(manager, context.TODO(), classifier, true, nil)).
+			To(Succeed())
+
+		// Health gate is disabled by default
+		readyzStatus = http.StatusServiceUnavailable
+		Expect(classification.IsClusterHealthy(manager, context.TODO())).To(BeTrue())
+
+		classification.ApplyOptions(classification.WithHealthGate(true, 0))
+		Expect(classification.IsClusterHealthy(manager, context.TODO())).To(BeFalse())
+
+		classifierReport := &libsveltosv1alpha1.ClassifierReport{}
+		key := types.NamespacedName{Namespace: utils.ReportNamespace, Name: classifier.Name}
+		Expect(c.Get(context.TODO(), key, classifierReport)).To(Succeed())
+		Expect(classifierReport.Spec.Match).To(BeTrue())
+		Expect(classifierReport.Annotations).To(HaveKey(classification.ClassifierReportClusterUnhealthyAnnotation))
+
+		condition := &metav1.Condition{}
+		Expect(json.Unmarshal(
+			[]byte(classifierReport.Annotations[classification.ClassifierReportClusterUnhealthyAnnotation]),
+			condition)).To(Succeed())
+		Expect(condition.Type).To(Equal(classification.ClusterUnhealthyCondition))
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Reason).To(Equal(classification.ReasonAPIServerNotReady))
+
+		readyzStatus = http.StatusOK
+		Expect(classification.IsClusterHealthy(manager, context.TODO())).To(BeTrue())
+		Expect(c.Get(context.TODO(), key, classifierReport)).To(Succeed())
+		Expect(classifierReport.Spec.Match).To(BeTrue())
+		Expect(classifierReport.Annotations).ToNot(HaveKey(classification.ClassifierReportClusterUnhealthyAnnotation))
+	})
+})
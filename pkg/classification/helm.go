@@ -0,0 +1,201 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"emperror.dev/errors"
+	"github.com/Masterminds/semver"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+)
+
+const (
+	// helmReleaseSecretType is the type of the Secrets Helm v3 stores releases in
+	helmReleaseSecretType = "helm.sh/release.v1"
+
+	// helmReleaseLabelSelector selects Secrets of deployed Helm releases
+	helmReleaseLabelSelector = "owner=helm,status=deployed"
+
+	// helmReleaseKey is the Secret key containing the release
+	helmReleaseKey = "release"
+
+	// maxHelmReleaseSize is the maximum size of a decompressed Helm release
+	maxHelmReleaseSize = 32 * 1024 * 1024
+)
+
+// secretGVK is the GVK watched by Classifiers with HelmReleaseConstraints
+var secretGVK = schema.GroupVersionKind{Version: "v1", Kind: "Secret"}
+
+// helmRelease contains the fields of a Helm v3 release used for classification
+type helmRelease struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Chart     struct {
+		Metadata struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"metadata"`
+	} `json:"chart"`
+}
+
+// areHelmReleasesAMatch returns true if, for each HelmReleaseConstraint, at least
+// one deployed Helm release satisfies it
+func (m *manager) areHelmReleasesAMatch(ctx context.Context,
+	classifier *libsveltosv1alpha1.Classifier) (bool, error) {
+
+	extension, err := getClassifierExtension(classifier)
+	if err != nil {
+		return false, err
+	}
+
+	if len(extension.HelmReleaseConstraints) == 0 {
+		return true, nil
+	}
+
+	releases, err := m.getDeployedHelmReleases(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	for i := range extension.HelmReleaseConstraints {
+		isMatch, err := isHelmReleaseConstraintAMatch(&extension.HelmReleaseConstraints[i], releases)
+		if err != nil || !isMatch {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// getDeployedHelmReleases returns all deployed Helm v3 releases.
+// Releases which cannot be decoded are skipped.
+func (m *manager) getDeployedHelmReleases(ctx context.Context) ([]helmRelease, error) {
+	clientset, err := kubernetes.NewForConfig(m.config)
+	if err != nil {
+		return nil, err
+	}
+
+	secrets, err := clientset.CoreV1().Secrets("").List(ctx, metav1.ListOptions{
+		LabelSelector: helmReleaseLabelSelector,
+		FieldSelector: fmt.Sprintf("type=%s", helmReleaseSecretType),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	releases := make([]helmRelease, 0, len(secrets.Items))
+	for i := range secrets.Items {
+		release, err := decodeHelmRelease(&secrets.Items[i])
+		if err != nil {
+			m.log.V(logs.LogDebug).Info(fmt.Sprintf("failed to decode helm release %s/%s: %v",
+				secrets.Items[i].Namespace, secrets.Items[i].Name, err))
+			continue
+		}
+		releases = append(releases, *release)
+	}
+
+	return releases, nil
+}
+
+// decodeHelmRelease decodes the release stored in a Helm v3 release Secret.
+// Helm stores the release JSON gzipped and base64 encoded.
+func decodeHelmRelease(secret *corev1.Secret) (*helmRelease, error) {
+	data, ok := secret.Data[helmReleaseKey]
+	if !ok {
+		return nil, fmt.Errorf("secret has no %s key", helmReleaseKey)
+	}
+
+	decoded := make([]byte, base64.StdEncoding.DecodedLen(len(data)))
+	n, err := base64.StdEncoding.Decode(decoded, data)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode release")
+	}
+	decoded = decoded[:n]
+
+	var reader io.Reader = bytes.NewReader(decoded)
+	gzipMagic := []byte{0x1f, 0x8b}
+	if bytes.HasPrefix(decoded, gzipMagic) {
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to decompress release")
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	}
+
+	release := &helmRelease{}
+	if err := json.NewDecoder(io.LimitReader(reader, maxHelmReleaseSize)).Decode(release); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal release")
+	}
+
+	return release, nil
+}
+
+// isHelmReleaseConstraintAMatch returns true if at least one release satisfies constraint
+func isHelmReleaseConstraintAMatch(constraint *HelmReleaseConstraint, releases []helmRelease) (bool, error) {
+	if constraint.ChartName == "" {
+		return false, newInvalidClassifierError(errors.New("helm release constraint chartName is required"))
+	}
+
+	var versionRange *semver.Constraints
+	if constraint.VersionRange != "" {
+		var err error
+		versionRange, err = parseVersionRange(constraint.VersionRange)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	for i := range releases {
+		release := &releases[i]
+		if release.Chart.Metadata.Name != constraint.ChartName {
+			continue
+		}
+		if constraint.Namespace != "" && release.Namespace != constraint.Namespace {
+			continue
+		}
+		if constraint.ReleaseName != "" && release.Name != constraint.ReleaseName {
+			continue
+		}
+		if versionRange == nil {
+			return true, nil
+		}
+		version, err := semver.NewVersion(release.Chart.Metadata.Version)
+		if err != nil {
+			// Chart version is not semver: it cannot be within a range
+			continue
+		}
+		if versionRange.Check(version) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
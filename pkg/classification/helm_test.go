@@ -0,0 +1,116 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+)
+
+// getHelmReleaseSecret returns a Secret storing a release the way Helm v3 does
+func getHelmReleaseSecret(namespace, releaseName, chartName, chartVersion string) *corev1.Secret {
+	release := fmt.Sprintf(`{"name":%q,"namespace":%q,"version":1,"info":{"status":"deployed"},`+
+		`"chart":{"metadata":{"name":%q,"version":%q}}}`, releaseName, namespace, chartName, chartVersion)
+
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	_, err := writer.Write([]byte(release))
+	Expect(err).To(BeNil())
+	Expect(writer.Close()).To(Succeed())
+
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      fmt.Sprintf("sh.helm.release.v1.%s.v1", releaseName),
+			Labels:    map[string]string{"owner": "helm", "status": "deployed", "name": releaseName},
+		},
+		Type: "helm.sh/release.v1",
+		Data: map[string][]byte{
+			"release": []byte(base64.StdEncoding.EncodeToString(buffer.Bytes())),
+		},
+	}
+}
+
+var _ = Describe("Manager: helm releases", func() {
+	It("decodeHelmRelease decodes Helm v3 release Secrets", func() {
+		secret := getHelmReleaseSecret("kyverno", "kyverno", "kyverno", "v2.6.5")
+
+		release, err := classification.DecodeHelmRelease(secret)
+		Expect(err).To(BeNil())
+		Expect(release.Name).To(Equal("kyverno"))
+		Expect(release.Namespace).To(Equal("kyverno"))
+		Expect(release.Chart.Metadata.Name).To(Equal("kyverno"))
+		Expect(release.Chart.Metadata.Version).To(Equal("v2.6.5"))
+
+		secret.Data["release"] = []byte("not base64!")
+		_, err = classification.DecodeHelmRelease(secret)
+		Expect(err).ToNot(BeNil())
+	})
+
+	It("isHelmReleaseConstraintAMatch matches chart name and version range", func() {
+		releases := make([]classification.HelmRelease, 0)
+		for _, secret := range []*corev1.Secret{
+			getHelmReleaseSecret("kyverno", "kyverno", "kyverno", "v2.6.5"),
+			getHelmReleaseSecret("monitoring", "prometheus", "kube-prometheus-stack", "43.2.1"),
+		} {
+			release, err := classification.DecodeHelmRelease(secret)
+			Expect(err).To(BeNil())
+			releases = append(releases, *release)
+		}
+
+		type testCase struct {
+			constraint classification.HelmReleaseConstraint
+			isMatch    bool
+		}
+		testCases := []testCase{
+			{constraint: classification.HelmReleaseConstraint{ChartName: "kyverno"}, isMatch: true},
+			{constraint: classification.HelmReleaseConstraint{ChartName: "kyverno", VersionRange: ">=2.6.0 <3.0.0"},
+				isMatch: true},
+			{constraint: classification.HelmReleaseConstraint{ChartName: "kyverno", VersionRange: "~2.5.x"},
+				isMatch: false},
+			{constraint: classification.HelmReleaseConstraint{ChartName: "kube-prometheus-stack",
+				Namespace: "monitoring", ReleaseName: "prometheus"}, isMatch: true},
+			{constraint: classification.HelmReleaseConstraint{ChartName: "kube-prometheus-stack",
+				Namespace: "default"}, isMatch: false},
+			{constraint: classification.HelmReleaseConstraint{ChartName: "cert-manager"}, isMatch: false},
+		}
+
+		for i := range testCases {
+			isMatch, err := classification.IsHelmReleaseConstraintAMatch(&testCases[i].constraint, releases)
+			Expect(err).To(BeNil())
+			Expect(isMatch).To(Equal(testCases[i].isMatch), fmt.Sprintf("%+v", testCases[i].constraint))
+		}
+
+		_, err := classification.IsHelmReleaseConstraintAMatch(&classification.HelmReleaseConstraint{}, releases)
+		Expect(err).ToNot(BeNil())
+		Expect(classification.IsInvalidClassifierError(err)).To(BeTrue())
+
+		_, err = classification.IsHelmReleaseConstraintAMatch(
+			&classification.HelmReleaseConstraint{ChartName: "kyverno", VersionRange: ">=2.6.0 <"}, releases)
+		Expect(classification.IsInvalidClassifierError(err)).To(BeTrue())
+	})
+})
@@ -0,0 +1,176 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+const (
+	// DefaultEvaluationHistorySize is the number of evaluation results
+	// kept per Classifier when no size is configured
+	DefaultEvaluationHistorySize = 10
+
+	// EvaluationHistoryPath is the path the evaluation history debug
+	// endpoint is served at
+	EvaluationHistoryPath = "/debug/classifiers"
+)
+
+// EvaluationResult contains the outcome of a single Classifier evaluation
+type EvaluationResult struct {
+	// Timestamp is the time evaluation started
+	Timestamp time.Time `json:"timestamp"`
+
+	// Match indicates whether cluster was a match
+	Match bool `json:"match"`
+
+	// Duration is how long evaluation took
+	Duration time.Duration `json:"duration"`
+
+	// Error, if not empty, is the reason evaluation failed
+	Error string `json:"error,omitempty"`
+
+	// Cost is what evaluation cost in terms of API server traffic and CPU
+	Cost *EvaluationCost `json:"cost,omitempty"`
+}
+
+// evaluationHistory is a bounded ring buffer of EvaluationResults
+type evaluationHistory struct {
+	results []EvaluationResult
+	// next is the position next result will be stored at
+	next int
+	// full indicates all positions have been written at least once
+	full bool
+}
+
+func newEvaluationHistory(size int) *evaluationHistory {
+	return &evaluationHistory{results: make([]EvaluationResult, size)}
+}
+
+func (h *evaluationHistory) add(result EvaluationResult) {
+	h.results[h.next] = result
+	h.next = (h.next + 1) % len(h.results)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// list returns all stored results, oldest first
+func (h *evaluationHistory) list() []EvaluationResult {
+	if !h.full {
+		result := make([]EvaluationResult, h.next)
+		copy(result, h.results[:h.next])
+		return result
+	}
+
+	result := make([]EvaluationResult, 0, len(h.results))
+	result = append(result, h.results[h.next:]...)
+	return append(result, h.results[:h.next]...)
+}
+
+// recordEvaluation stores the outcome of a Classifier evaluation
+func (m *manager) recordEvaluation(classifierName string, start time.Time, isMatch bool, err error,
+	cost *EvaluationCost) {
+
+	result := EvaluationResult{
+		Timestamp: start,
+		Match:     isMatch,
+		Duration:  time.Since(start),
+		Cost:      cost,
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	m.historyMu.Lock()
+	defer m.historyMu.Unlock()
+
+	m.latest[classifierName] = result
+
+	if m.historySize <= 0 {
+		return
+	}
+
+	h, ok := m.history[classifierName]
+	if !ok {
+		h = newEvaluationHistory(m.historySize)
+		m.history[classifierName] = h
+	}
+	h.add(result)
+}
+
+// removeEvaluationHistory drops all results stored for a Classifier
+func (m *manager) removeEvaluationHistory(classifierName string) {
+	m.historyMu.Lock()
+	defer m.historyMu.Unlock()
+
+	delete(m.history, classifierName)
+	delete(m.latest, classifierName)
+}
+
+// GetEvaluationHistory returns the most recent evaluation results for
+// a Classifier, oldest first.
+func (m *manager) GetEvaluationHistory(classifierName string) []EvaluationResult {
+	m.historyMu.RLock()
+	defer m.historyMu.RUnlock()
+
+	h, ok := m.history[classifierName]
+	if !ok {
+		return nil
+	}
+	return h.list()
+}
+
+// getAllEvaluationHistory returns the most recent evaluation results for
+// all Classifiers
+func (m *manager) getAllEvaluationHistory() map[string][]EvaluationResult {
+	m.historyMu.RLock()
+	defer m.historyMu.RUnlock()
+
+	result := make(map[string][]EvaluationResult, len(m.history))
+	for name, h := range m.history {
+		result[name] = h.list()
+	}
+	return result
+}
+
+// EvaluationHistoryHandler returns an http.Handler serving, in JSON format,
+// the most recent evaluation results.
+// Query parameter "classifier" limits the output to a single Classifier.
+func EvaluationHistoryHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m := GetManager()
+		if m == nil {
+			http.Error(w, "classification manager not initialized yet", http.StatusServiceUnavailable)
+			return
+		}
+
+		var body interface{}
+		if name := r.URL.Query().Get("classifier"); name != "" {
+			body = m.GetEvaluationHistory(name)
+		} else {
+			body = m.getAllEvaluationHistory()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(body); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
@@ -0,0 +1,173 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/projectsveltos/classifier-agent/internal/utils"
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+)
+
+var _ = Describe("Manager: evaluation history", func() {
+	BeforeEach(func() {
+		classification.Reset()
+	})
+
+	It("recordEvaluation keeps only most recent results", func() {
+		classification.InitializeManagerWithObjects(nil)
+		size := 3
+		classification.ApplyOptions(classification.WithEvaluationHistorySize(size))
+		manager := classification.GetManager()
+
+		classifierName := randomString()
+		Expect(manager.GetEvaluationHistory(classifierName)).To(BeEmpty())
+
+		start := time.Now()
+		for i := 0; i < size+2; i++ {
+			var err error
+			if i%2 == 0 {
+				err = fmt.Errorf("error %d", i)
+			}
+			classification.RecordEvaluation(manager, classifierName, start.Add(time.Duration(i)*time.Second),
+				i%2 == 1, err, nil)
+		}
+
+		history := manager.GetEvaluationHistory(classifierName)
+		Expect(len(history)).To(Equal(size))
+		// Oldest first
+		for i := range history {
+			Expect(history[i].Timestamp).To(Equal(start.Add(time.Duration(i+2) * time.Second)))
+		}
+		Expect(history[0].Error).To(Equal("error 2"))
+		Expect(history[1].Match).To(BeTrue())
+		Expect(history[1].Error).To(BeEmpty())
+
+		classification.RemoveEvaluationHistory(manager, classifierName)
+		Expect(manager.GetEvaluationHistory(classifierName)).To(BeEmpty())
+	})
+
+	It("recordEvaluation does nothing when history is disabled", func() {
+		classification.InitializeManagerWithObjects(nil)
+		classification.ApplyOptions(classification.WithEvaluationHistorySize(0))
+		manager := classification.GetManager()
+
+		classifierName := randomString()
+		classification.RecordEvaluation(manager, classifierName, time.Now(), true, nil, nil)
+		Expect(manager.GetEvaluationHistory(classifierName)).To(BeEmpty())
+	})
+
+	It("EvaluationHistoryHandler serves evaluation history", func() {
+		handler := classification.EvaluationHistoryHandler()
+
+		// Manager not initialized yet
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, classification.EvaluationHistoryPath, nil))
+		Expect(recorder.Code).To(Equal(http.StatusServiceUnavailable))
+
+		classification.InitializeManagerWithObjects(nil)
+		manager := classification.GetManager()
+
+		classifierName1 := randomString()
+		classifierName2 := randomString()
+		classification.RecordEvaluation(manager, classifierName1, time.Now(), true, nil, nil)
+		classification.RecordEvaluation(manager, classifierName2, time.Now(), false, nil, nil)
+
+		recorder = httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, classification.EvaluationHistoryPath, nil))
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		all := map[string][]classification.EvaluationResult{}
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &all)).To(Succeed())
+		Expect(all).To(HaveKey(classifierName1))
+		Expect(all).To(HaveKey(classifierName2))
+
+		recorder = httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet,
+			fmt.Sprintf("%s?classifier=%s", classification.EvaluationHistoryPath, classifierName1), nil))
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		history := []classification.EvaluationResult{}
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &history)).To(Succeed())
+		Expect(len(history)).To(Equal(1))
+		Expect(history[0].Match).To(BeTrue())
+	})
+})
+
+var _ = Describe("Manager: evaluation summary", func() {
+	BeforeEach(func() {
+		classification.Reset()
+	})
+
+	It("updateEvaluationSummary creates and updates summary ConfigMap", func() {
+		c := classification.InitializeManagerWithObjects(nil)
+		// Summary must list Classifiers even when history is disabled
+		classification.ApplyOptions(classification.WithEvaluationHistorySize(0))
+		manager := classification.GetManager()
+
+		classifierName1 := randomString()
+		classification.RecordEvaluation(manager, classifierName1, time.Now(), true, nil, nil)
+		Expect(classification.UpdateEvaluationSummary(manager, context.TODO())).To(Succeed())
+
+		summary := getEvaluationSummary(c)
+		Expect(len(summary.Classifiers)).To(Equal(1))
+		Expect(summary.Classifiers[0].Name).To(Equal(classifierName1))
+		Expect(summary.Classifiers[0].Match).To(BeTrue())
+
+		classifierName2 := randomString()
+		classification.RecordEvaluation(manager, classifierName2, time.Now(), false, fmt.Errorf("failed"), nil)
+		classification.RecordEvaluation(manager, classifierName1, time.Now(), false, nil, nil)
+		Expect(classification.UpdateEvaluationSummary(manager, context.TODO())).To(Succeed())
+
+		summary = getEvaluationSummary(c)
+		Expect(len(summary.Classifiers)).To(Equal(2))
+		for i := range summary.Classifiers {
+			Expect(summary.Classifiers[i].Match).To(BeFalse())
+			if summary.Classifiers[i].Name == classifierName2 {
+				Expect(summary.Classifiers[i].Error).To(Equal("failed"))
+			}
+		}
+
+		classification.RemoveEvaluationHistory(manager, classifierName1)
+		Expect(classification.UpdateEvaluationSummary(manager, context.TODO())).To(Succeed())
+		summary = getEvaluationSummary(c)
+		Expect(len(summary.Classifiers)).To(Equal(1))
+		Expect(summary.Classifiers[0].Name).To(Equal(classifierName2))
+	})
+})
+
+func getEvaluationSummary(c client.Client) *classification.EvaluationSummary {
+	configMap := &corev1.ConfigMap{}
+	Expect(c.Get(context.TODO(),
+		types.NamespacedName{Namespace: utils.ReportNamespace, Name: classification.EvaluationSummaryName},
+		configMap)).To(Succeed())
+	Expect(configMap.Data).To(HaveKey(classification.EvaluationSummaryKey))
+
+	summary := &classification.EvaluationSummary{}
+	Expect(json.Unmarshal([]byte(configMap.Data[classification.EvaluationSummaryKey]), summary)).To(Succeed())
+	return summary
+}
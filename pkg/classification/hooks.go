@@ -0,0 +1,185 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+)
+
+// HookAnnotationPrefix is the prefix of the ClassifierReport annotations set by
+// EvaluationHooks. Those annotations are kept on the ClassifierReport in the managed
+// cluster only.
+const HookAnnotationPrefix = "hooks.classifier.projectsveltos.io/"
+
+// EvaluationHook is layered around each Classifier evaluation, for instance to trace
+// evaluations, expose custom metrics or log policy decisions. Hooks can annotate the
+// outcome of an evaluation but cannot change it.
+type EvaluationHook interface {
+	// Name identifies the hook in log messages
+	Name() string
+
+	// PreEvaluate is invoked before classifier is evaluated. The returned context,
+	// which must derive from ctx, is used for the evaluation and passed to PostEvaluate.
+	// classifier is a copy: changing it has no effect.
+	PreEvaluate(ctx context.Context, classifier *libsveltosv1alpha1.Classifier) context.Context
+
+	// PostEvaluate is invoked once classifier is evaluated, before its ClassifierReport
+	// is updated
+	PostEvaluate(ctx context.Context, outcome *EvaluationOutcome)
+}
+
+// EvaluationOutcome is the read-only outcome of a Classifier evaluation
+type EvaluationOutcome struct {
+	classifier  *libsveltosv1alpha1.Classifier
+	match       bool
+	err         error
+	duration    time.Duration
+	annotations map[string]string
+}
+
+// Classifier returns a copy of the evaluated Classifier
+func (o *EvaluationOutcome) Classifier() *libsveltosv1alpha1.Classifier {
+	return o.classifier.DeepCopy()
+}
+
+// Match returns true if the cluster is a match for the Classifier
+func (o *EvaluationOutcome) Match() bool {
+	return o.match
+}
+
+// Err returns the error the evaluation failed with, if any
+func (o *EvaluationOutcome) Err() error {
+	return o.err
+}
+
+// Duration returns how long the evaluation took
+func (o *EvaluationOutcome) Duration() time.Duration {
+	return o.duration
+}
+
+// Annotate sets annotation HookAnnotationPrefix+key to value on the Classifier's
+// ClassifierReport. Annotations not set again on next evaluation are removed.
+func (o *EvaluationOutcome) Annotate(key, value string) error {
+	annotation := HookAnnotationPrefix + key
+	if errs := validation.IsQualifiedName(annotation); len(errs) != 0 {
+		return fmt.Errorf("invalid annotation key %q: %s", key, strings.Join(errs, "; "))
+	}
+	o.annotations[annotation] = value
+	return nil
+}
+
+// runPreEvaluationHooks invokes PreEvaluate on all hooks, in order, and returns
+// the context to evaluate classifier with
+func (m *manager) runPreEvaluationHooks(ctx context.Context,
+	classifier *libsveltosv1alpha1.Classifier) context.Context {
+
+	for i := range m.hooks {
+		hookCtx := m.runPreEvaluationHook(ctx, m.hooks[i], classifier)
+		if hookCtx != nil {
+			ctx = hookCtx
+		}
+	}
+	return ctx
+}
+
+// runPostEvaluationHooks invokes PostEvaluate on all hooks, in reverse order, and
+// records the annotations they set
+func (m *manager) runPostEvaluationHooks(ctx context.Context, classifier *libsveltosv1alpha1.Classifier,
+	match bool, evaluationErr error, duration time.Duration) {
+
+	if len(m.hooks) == 0 {
+		return
+	}
+
+	outcome := &EvaluationOutcome{
+		classifier:  classifier,
+		match:       match,
+		err:         evaluationErr,
+		duration:    duration,
+		annotations: make(map[string]string),
+	}
+	for i := len(m.hooks) - 1; i >= 0; i-- {
+		m.runPostEvaluationHook(ctx, m.hooks[i], outcome)
+	}
+
+	m.hookAnnotationsMu.Lock()
+	defer m.hookAnnotationsMu.Unlock()
+	m.hookAnnotations[classifier.Name] = outcome.annotations
+}
+
+// runPreEvaluationHook invokes PreEvaluate. A hook panicking does not affect the evaluation.
+func (m *manager) runPreEvaluationHook(ctx context.Context, hook EvaluationHook,
+	classifier *libsveltosv1alpha1.Classifier) (hookCtx context.Context) {
+
+	defer m.recoverHook(hook, classifier.Name)
+	return hook.PreEvaluate(ctx, classifier.DeepCopy())
+}
+
+// runPostEvaluationHook invokes PostEvaluate. A hook panicking does not affect the evaluation.
+func (m *manager) runPostEvaluationHook(ctx context.Context, hook EvaluationHook, outcome *EvaluationOutcome) {
+	defer m.recoverHook(hook, outcome.classifier.Name)
+	hook.PostEvaluate(ctx, outcome)
+}
+
+func (m *manager) recoverHook(hook EvaluationHook, classifierName string) {
+	if r := recover(); r != nil {
+		m.log.V(logs.LogInfo).Info(fmt.Sprintf("evaluation hook %s panicked evaluating classifier %s: %v",
+			hook.Name(), classifierName, r))
+	}
+}
+
+// removeHookAnnotations forgets the annotations set by hooks for a Classifier
+func (m *manager) removeHookAnnotations(classifierName string) {
+	m.hookAnnotationsMu.Lock()
+	defer m.hookAnnotationsMu.Unlock()
+	delete(m.hookAnnotations, classifierName)
+}
+
+// setReportHookAnnotations sets on report the annotations hooks set on the last
+// evaluation of classifier, removing any other HookAnnotationPrefix annotation
+func (m *manager) setReportHookAnnotations(report *libsveltosv1alpha1.ClassifierReport,
+	classifier *libsveltosv1alpha1.Classifier) {
+
+	m.hookAnnotationsMu.Lock()
+	defer m.hookAnnotationsMu.Unlock()
+
+	for key := range report.Annotations {
+		if strings.HasPrefix(key, HookAnnotationPrefix) {
+			delete(report.Annotations, key)
+		}
+	}
+	for key, value := range m.hookAnnotations[classifier.Name] {
+		setReportAnnotation(report, key, value)
+	}
+}
+
+// copyHookAnnotations sets on dst all HookAnnotationPrefix annotations set on src
+func copyHookAnnotations(dst, src *libsveltosv1alpha1.ClassifierReport) {
+	for key, value := range src.Annotations {
+		if strings.HasPrefix(key, HookAnnotationPrefix) {
+			setReportAnnotation(dst, key, value)
+		}
+	}
+}
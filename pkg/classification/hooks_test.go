@@ -0,0 +1,100 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/projectsveltos/classifier-agent/internal/utils"
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+type hookKey struct{}
+
+// testHook records the order it is invoked in and annotates the outcome
+type testHook struct {
+	name  string
+	calls *[]string
+	panic bool
+}
+
+func (h *testHook) Name() string {
+	return h.name
+}
+
+func (h *testHook) PreEvaluate(ctx context.Context, classifier *libsveltosv1alpha1.Classifier) context.Context {
+	*h.calls = append(*h.calls, "pre-"+h.name)
+	if h.panic {
+		panic("pre")
+	}
+	// Changing the Classifier has no effect
+	classifier.Name = "modified"
+	return context.WithValue(ctx, hookKey{}, h.name)
+}
+
+func (h *testHook) PostEvaluate(ctx context.Context, outcome *classification.EvaluationOutcome) {
+	*h.calls = append(*h.calls, "post-"+h.name)
+	if h.panic {
+		panic("post")
+	}
+	Expect(outcome.Annotate(h.name, ctx.Value(hookKey{}).(string))).To(Succeed())
+	Expect(outcome.Annotate("invalid key", "")).ToNot(Succeed())
+	outcome.Classifier().Name = "modified"
+}
+
+var _ = Describe("Manager: evaluation hooks", func() {
+
+	BeforeEach(func() {
+		classification.Reset()
+	})
+
+	It("hooks are layered around evaluation and annotate ClassifierReport", func() {
+		classifier := getClassifierWithKubernetesConstraints(version25, libsveltosv1alpha1.ComparisonEqual)
+
+		c := classification.InitializeManagerWithObjects(nil, classifier)
+		calls := make([]string, 0)
+		classification.ApplyOptions(classification.WithEvaluationHooks(
+			&testHook{name: "tracing", calls: &calls},
+			&testHook{name: "broken", calls: &calls, panic: true},
+			&testHook{name: "audit", calls: &calls},
+		))
+		manager := classification.GetManager()
+
+		ctx := classification.RunPreEvaluationHooks(manager, context.TODO(), classifier)
+		Expect(ctx.Value(hookKey{})).To(Equal("audit"))
+		classification.RunPostEvaluationHooks(manager, ctx, classifier, true, nil, time.Second)
+		Expect(calls).To(Equal([]string{"pre-tracing", "pre-broken", "pre-audit",
+			"post-audit", "post-broken", "post-tracing"}))
+		Expect(classifier.Name).ToNot(Equal("modified"))
+
+		Expect(classification.CreateClassifierReport(manager, context.TODO(), classifier, true, nil)).
+			To(Succeed())
+		classifierReport := &libsveltosv1alpha1.ClassifierReport{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: utils.ReportNamespace, Name: classifier.Name},
+			classifierReport)).To(Succeed())
+		Expect(classifierReport.Annotations).To(HaveKeyWithValue(classification.HookAnnotationPrefix+"tracing", "audit"))
+		Expect(classifierReport.Annotations).To(HaveKeyWithValue(classification.HookAnnotationPrefix+"audit", "audit"))
+		Expect(classifierReport.Spec.Match).To(BeTrue())
+	})
+})
@@ -0,0 +1,138 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"emperror.dev/errors"
+	"k8s.io/client-go/rest"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+)
+
+// DefaultHostResyncInterval is the default interval at which Classifiers with
+// HostResourceConstraints are evaluated again
+const DefaultHostResyncInterval = time.Minute
+
+// hostCluster contains what is needed to evaluate HostResourceConstraints
+// against the cluster hosting the control plane of the managed cluster
+type hostCluster struct {
+	config *rest.Config
+	// resyncInterval is the interval at which Classifiers with HostResourceConstraints
+	// are queued. Host cluster resources are not watched.
+	resyncInterval time.Duration
+	// trends contains host resource counts over time, kept apart so that an host
+	// constraint does not share history with an identical managed cluster constraint
+	trends *trendStore
+}
+
+// getHostManager returns a manager evaluating resources of the host cluster. It shares
+// all settings of m, but the cluster, trends and discovery cache. Host discovery
+// cache is not tracked for staleness.
+// Returns nil if no host cluster is configured.
+func (m *manager) getHostManager() *manager {
+	if m.host == nil {
+		return nil
+	}
+	host := *m
+	host.config = m.host.config
+	host.trends = m.host.trends
+	host.discoveryMu = &sync.Mutex{}
+	host.discoveryClient = nil
+	host.restMapper = nil
+	host.factMu = nil
+	host.host = nil
+	return &host
+}
+
+// areHostResourceConstraintsAMatch returns true if all HostResourceConstraints are
+// satisfied by the host cluster. Those are not satisfied when no host cluster is
+// configured. When snapshot is not nil, host resources are listed at the same
+// resourceVersion, which is not related to the managed cluster one.
+func (m *manager) areHostResourceConstraintsAMatch(ctx context.Context, classifier *libsveltosv1alpha1.Classifier,
+	extension *ClassifierExtension, snapshot *evaluationSnapshot) (bool, error) {
+
+	if len(extension.HostResourceConstraints) == 0 {
+		return true, nil
+	}
+
+	host := m.getHostManager()
+	if host == nil {
+		addEvaluationNote(ctx, "host resource constraints: no host cluster configured")
+		m.log.V(logs.LogDebug).Info(fmt.Sprintf("classifier %s has host resource constraints "+
+			"but no host cluster is configured", classifier.Name))
+		return false, nil
+	}
+
+	var hostSnapshot *evaluationSnapshot
+	if snapshot != nil {
+		hostSnapshot = &evaluationSnapshot{}
+	}
+
+	for i := range extension.HostResourceConstraints {
+		isMatch, err := host.evaluateResourceConstraint(ctx, classifier, &extension.HostResourceConstraints[i],
+			hostSnapshot)
+		if err != nil {
+			return false, errors.Wrap(err, "host cluster")
+		}
+		if !isMatch {
+			addEvaluationNote(ctx, fmt.Sprintf("host resource constraint %d (%s) not satisfied",
+				i, extension.HostResourceConstraints[i].Kind))
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// hasHostResourceConstraints returns true if classifier has HostResourceConstraints
+func hasHostResourceConstraints(classifier *libsveltosv1alpha1.Classifier) bool {
+	extension, err := getClassifierExtension(classifier)
+	if err != nil {
+		return false
+	}
+	return len(extension.HostResourceConstraints) != 0
+}
+
+// resyncHostClassifiers periodically queues Classifiers with HostResourceConstraints,
+// since changes to host cluster resources are not watched
+func (m *manager) resyncHostClassifiers(ctx context.Context) {
+	ticker := time.NewTicker(m.host.resyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			classifiers, err := m.ListClassifiers(ctx)
+			if err != nil {
+				m.log.V(logs.LogInfo).Info(fmt.Sprintf("failed to list classifiers: %v", err))
+				continue
+			}
+			for i := range classifiers.Items {
+				if hasHostResourceConstraints(&classifiers.Items[i]) {
+					m.EvaluateClassifier(classifiers.Items[i].Name)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: host resource constraints", func() {
+	var workload *httptest.Server
+	var host *httptest.Server
+
+	// newClusterServer returns a server serving count cluster-scoped resources of kind
+	newClusterServer := func(kind string, count int) *httptest.Server {
+		resource := strings.ToLower(kind) + "s"
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			switch r.URL.Path {
+			case "/api":
+				_, _ = w.Write([]byte(`{"kind":"APIVersions","versions":[]}`))
+			case "/apis":
+				_, _ = w.Write([]byte(`{"kind":"APIGroupList","apiVersion":"v1","groups":[{"name":"example.com",` +
+					`"versions":[{"groupVersion":"example.com/v1","version":"v1"}],` +
+					`"preferredVersion":{"groupVersion":"example.com/v1","version":"v1"}}]}`))
+			case "/apis/example.com/v1":
+				_, _ = w.Write([]byte(fmt.Sprintf(`{"kind":"APIResourceList","apiVersion":"v1",`+
+					`"groupVersion":"example.com/v1","resources":[{"name":%q,"namespaced":false,"kind":%q,`+
+					`"verbs":["list"]}]}`, resource, kind)))
+			case "/apis/example.com/v1/" + resource:
+				items := make([]string, count)
+				for i := range items {
+					items[i] = fmt.Sprintf(`{"apiVersion":"example.com/v1","kind":%q,"metadata":{"name":"%s-%d"}}`,
+						kind, resource, i)
+				}
+				_, _ = w.Write([]byte(fmt.Sprintf(`{"apiVersion":"example.com/v1","kind":"%sList","metadata":{},`+
+					`"items":[%s]}`, kind, strings.Join(items, ","))))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+	}
+
+	BeforeEach(func() {
+		workload = newClusterServer("Widget", 1)
+		host = newClusterServer("Gadget", 2)
+
+		classification.InitializeManagerWithObjects(&rest.Config{Host: workload.URL})
+	})
+
+	AfterEach(func() {
+		workload.Close()
+		host.Close()
+	})
+
+	getClassifier := func(hostKind string, hostMinCount int) *libsveltosv1alpha1.Classifier {
+		extension := fmt.Sprintf(`deployedResourceConstraints:
+- group: example.com
+  version: v1
+  kind: Widget
+  minCount: 1
+hostResourceConstraints:
+- group: example.com
+  version: v1
+  kind: %s
+  minCount: %d
+`, hostKind, hostMinCount)
+		return &libsveltosv1alpha1.Classifier{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        randomString(),
+				Annotations: map[string]string{classification.ClassifierExtensionAnnotation: extension},
+			},
+		}
+	}
+
+	It("host resource constraints are evaluated against the host cluster", func() {
+		classification.ApplyOptions(classification.WithHostCluster(&rest.Config{Host: host.URL}, 0))
+
+		isMatch, err := classification.AreResourcesAMatch(classification.GetManager(), context.TODO(),
+			getClassifier("Gadget", 2))
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+
+		isMatch, err = classification.AreResourcesAMatch(classification.GetManager(), context.TODO(),
+			getClassifier("Gadget", 3))
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeFalse())
+
+		// Widget is only served by the managed cluster
+		isMatch, err = classification.AreResourcesAMatch(classification.GetManager(), context.TODO(),
+			getClassifier("Widget", 1))
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeFalse())
+	})
+
+	It("host resource constraints are not satisfied when no host cluster is configured", func() {
+		isMatch, err := classification.AreResourcesAMatch(classification.GetManager(), context.TODO(),
+			getClassifier("Gadget", 0))
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeFalse())
+	})
+})
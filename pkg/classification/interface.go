@@ -16,6 +16,12 @@ limitations under the License.
 
 package classification
 
+import (
+	"context"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
 type ClassifierInterface interface {
 	// EvaluateClassifier requests a classifier to be
 	// evaluated.
@@ -30,4 +36,17 @@ type ClassifierInterface interface {
 	// This evaluation is done asynchronously when at least one request
 	// to re-evaluate has been received
 	ReEvaluateResourceToWatch()
+
+	// GetEvaluationHistory returns the most recent evaluation results
+	// for a Classifier, oldest first.
+	// Number of results kept is bounded.
+	GetEvaluationHistory(classifierName string) []EvaluationResult
+
+	// EvaluateAll synchronously evaluates every Classifier, invoking
+	// progressFn after each evaluation.
+	EvaluateAll(ctx context.Context, progressFn EvaluationProgressFunc) error
+
+	// UndoActions removes labels and annotations added by the
+	// Classifier actions. Invoked when a Classifier is deleted.
+	UndoActions(ctx context.Context, classifier *libsveltosv1alpha1.Classifier) error
 }
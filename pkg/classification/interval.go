@@ -0,0 +1,97 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+)
+
+const (
+	// highChurnEventsPerSecond is the watch event rate above which the
+	// evaluation interval is shortened
+	highChurnEventsPerSecond = 1.0
+)
+
+// isAdaptiveInterval returns true if evaluation interval adapts to cluster churn
+func (m *manager) isAdaptiveInterval() bool {
+	_, _, maxInterval := m.getIntervals()
+	return maxInterval > 0
+}
+
+// recordWatchEvent counts a watch event. Watch event rate is used to adapt
+// the evaluation interval.
+func (m *manager) recordWatchEvent() {
+	atomic.AddUint64(&m.watchEvents, 1)
+}
+
+// getInitialInterval returns the interval used before any churn is measured
+func (m *manager) getInitialInterval() time.Duration {
+	interval, minInterval, maxInterval := m.getIntervals()
+	if maxInterval <= 0 {
+		return interval
+	}
+	return clampInterval(interval, minInterval, maxInterval)
+}
+
+// getNextInterval returns the interval to wait before next evaluation, given
+// current interval and the time elapsed since watch events were last counted.
+// Watch event counter is reset.
+func (m *manager) getNextInterval(current, elapsed time.Duration) time.Duration {
+	interval, minInterval, maxInterval := m.getIntervals()
+	if maxInterval <= 0 {
+		return interval
+	}
+
+	events := atomic.SwapUint64(&m.watchEvents, 0)
+	next := adaptInterval(current, minInterval, maxInterval, events, elapsed)
+	if next != current {
+		m.log.V(logs.LogDebug).Info(fmt.Sprintf("evaluation interval changed from %s to %s (%d watch events in %s)",
+			current, next, events, elapsed))
+	}
+	return next
+}
+
+// adaptInterval doubles current interval when no watch event was received and
+// halves it when watch event rate is above highChurnEventsPerSecond.
+// Result is always within minInterval and maxInterval.
+func adaptInterval(current, minInterval, maxInterval time.Duration, events uint64,
+	elapsed time.Duration) time.Duration {
+
+	next := current
+	switch {
+	case events == 0:
+		next = current * 2
+	case elapsed > 0 && float64(events)/elapsed.Seconds() > highChurnEventsPerSecond:
+		next = current / 2
+	}
+
+	return clampInterval(next, minInterval, maxInterval)
+}
+
+func clampInterval(interval, minInterval, maxInterval time.Duration) time.Duration {
+	if interval < minInterval {
+		return minInterval
+	}
+	if interval > maxInterval {
+		return maxInterval
+	}
+	return interval
+}
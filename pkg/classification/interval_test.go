@@ -0,0 +1,68 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+)
+
+var _ = Describe("Manager: adaptive interval", func() {
+	BeforeEach(func() {
+		classification.Reset()
+	})
+
+	It("adaptInterval lengthens interval on quiet clusters and shortens it on high churn", func() {
+		minInterval := 5 * time.Second
+		maxInterval := 2 * time.Minute
+
+		// No event: interval doubles up to maxInterval
+		Expect(classification.AdaptInterval(10*time.Second, minInterval, maxInterval, 0, 10*time.Second)).
+			To(Equal(20 * time.Second))
+		Expect(classification.AdaptInterval(90*time.Second, minInterval, maxInterval, 0, 90*time.Second)).
+			To(Equal(maxInterval))
+
+		// Low churn: interval is unchanged
+		Expect(classification.AdaptInterval(20*time.Second, minInterval, maxInterval, 5, 20*time.Second)).
+			To(Equal(20 * time.Second))
+
+		// High churn: interval halves down to minInterval
+		Expect(classification.AdaptInterval(20*time.Second, minInterval, maxInterval, 100, 20*time.Second)).
+			To(Equal(10 * time.Second))
+		Expect(classification.AdaptInterval(6*time.Second, minInterval, maxInterval, 100, 6*time.Second)).
+			To(Equal(minInterval))
+	})
+
+	It("getNextInterval uses watch events received since last call", func() {
+		classification.InitializeManagerWithObjects(nil)
+
+		// Adaptive interval disabled: interval is fixed
+		Expect(classification.GetNextInterval(10*time.Second, 10*time.Second)).To(Equal(10 * time.Second))
+
+		classification.ApplyOptions(classification.WithAdaptiveInterval(5*time.Second, time.Minute))
+
+		classification.RecordWatchEvents(100)
+		Expect(classification.GetNextInterval(10*time.Second, 10*time.Second)).To(Equal(5 * time.Second))
+
+		// Counter was reset
+		Expect(classification.GetNextInterval(5*time.Second, 5*time.Second)).To(Equal(10 * time.Second))
+	})
+})
@@ -0,0 +1,106 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"fmt"
+	"strings"
+
+	"emperror.dev/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+const (
+	// OperationIn can be used in LabelFilters of ClassifierExtension
+	// DeployedResourceConstraints. Value is a comma separated list of values;
+	// label must be present and set to one of those.
+	OperationIn = libsveltosv1alpha1.Operation("In")
+
+	// OperationNotIn requires label to be absent or set to none of the
+	// comma separated values.
+	OperationNotIn = libsveltosv1alpha1.Operation("NotIn")
+
+	// OperationExists requires label to be present. Value must be empty.
+	OperationExists = libsveltosv1alpha1.Operation("Exists")
+
+	// OperationDoesNotExist requires label to be absent. Value must be empty.
+	OperationDoesNotExist = libsveltosv1alpha1.Operation("DoesNotExist")
+)
+
+// labelSelectorOperators maps LabelFilter operations evaluated by the
+// API server to the corresponding label selector operator
+var labelSelectorOperators = map[libsveltosv1alpha1.Operation]selection.Operator{
+	libsveltosv1alpha1.OperationEqual:     selection.Equals,
+	libsveltosv1alpha1.OperationDifferent: selection.NotEquals,
+	OperationIn:                           selection.In,
+	OperationNotIn:                        selection.NotIn,
+	OperationExists:                       selection.Exists,
+	OperationDoesNotExist:                 selection.DoesNotExist,
+}
+
+// getLabelSelectorRequirement converts a LabelFilter to a label selector requirement
+func getLabelSelectorRequirement(f *libsveltosv1alpha1.LabelFilter) (*labels.Requirement, error) {
+	operator, ok := labelSelectorOperators[f.Operation]
+	if !ok {
+		return nil, fmt.Errorf("label filter %q: unsupported operation %q", f.Key, f.Operation)
+	}
+
+	var values []string
+	switch operator {
+	case selection.Exists, selection.DoesNotExist:
+		if f.Value != "" {
+			return nil, fmt.Errorf("label filter %q: operation %s does not accept a value", f.Key, f.Operation)
+		}
+	case selection.In, selection.NotIn:
+		for _, v := range strings.Split(f.Value, ",") {
+			values = append(values, strings.TrimSpace(v))
+		}
+	default:
+		values = []string{f.Value}
+	}
+
+	requirement, err := labels.NewRequirement(f.Key, operator, values)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("label filter %q", f.Key))
+	}
+	return requirement, nil
+}
+
+// getLabelSelector returns the label selector for all LabelFilters the API
+// server can evaluate. Filters with a regex operation are skipped: those are
+// evaluated by classifier-agent.
+func getLabelSelector(filters []libsveltosv1alpha1.LabelFilter) (string, error) {
+	selector := labels.NewSelector()
+	for i := range filters {
+		if isRegexOperation(filters[i].Operation) {
+			continue
+		}
+		requirement, err := getLabelSelectorRequirement(&filters[i])
+		if err != nil {
+			return "", err
+		}
+		selector = selector.Add(*requirement)
+	}
+
+	if selector.Empty() {
+		return "", nil
+	}
+	return selector.String(), nil
+}
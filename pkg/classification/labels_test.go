@@ -0,0 +1,161 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/rest"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: label filters", func() {
+	isAMatch := func(filters []libsveltosv1alpha1.LabelFilter, objectLabels map[string]string) bool {
+		s, err := classification.GetLabelSelector(filters)
+		Expect(err).To(BeNil())
+		selector, err := labels.Parse(s)
+		Expect(err).To(BeNil())
+		return selector.Matches(labels.Set(objectLabels))
+	}
+
+	It("getLabelSelector supports equality operations", func() {
+		s, err := classification.GetLabelSelector([]libsveltosv1alpha1.LabelFilter{
+			{Key: "env", Operation: libsveltosv1alpha1.OperationEqual, Value: "prod"},
+			{Key: "tier", Operation: libsveltosv1alpha1.OperationDifferent, Value: "db"},
+		})
+		Expect(err).To(BeNil())
+		Expect(s).To(Equal("env=prod,tier!=db"))
+
+		s, err = classification.GetLabelSelector(nil)
+		Expect(err).To(BeNil())
+		Expect(s).To(BeEmpty())
+	})
+
+	It("getLabelSelector supports In and NotIn operations", func() {
+		filters := []libsveltosv1alpha1.LabelFilter{
+			{Key: "env", Operation: classification.OperationIn, Value: "prod, staging"},
+		}
+		Expect(isAMatch(filters, map[string]string{"env": "staging"})).To(BeTrue())
+		Expect(isAMatch(filters, map[string]string{"env": "dev"})).To(BeFalse())
+		Expect(isAMatch(filters, map[string]string{})).To(BeFalse())
+
+		filters = []libsveltosv1alpha1.LabelFilter{
+			{Key: "env", Operation: classification.OperationNotIn, Value: "prod,staging"},
+		}
+		Expect(isAMatch(filters, map[string]string{"env": "dev"})).To(BeTrue())
+		Expect(isAMatch(filters, map[string]string{})).To(BeTrue())
+		Expect(isAMatch(filters, map[string]string{"env": "prod"})).To(BeFalse())
+	})
+
+	It("getLabelSelector supports Exists and DoesNotExist operations", func() {
+		filters := []libsveltosv1alpha1.LabelFilter{
+			{Key: "foo", Operation: classification.OperationExists},
+			{Key: "bar", Operation: classification.OperationDoesNotExist},
+		}
+		Expect(isAMatch(filters, map[string]string{"foo": ""})).To(BeTrue())
+		Expect(isAMatch(filters, map[string]string{"foo": "x", "bar": "y"})).To(BeFalse())
+		Expect(isAMatch(filters, map[string]string{"bar": "y"})).To(BeFalse())
+	})
+
+	It("getLabelSelector skips regex operations", func() {
+		s, err := classification.GetLabelSelector([]libsveltosv1alpha1.LabelFilter{
+			{Key: "app", Operation: classification.OperationMatchRegex, Value: "web.*"},
+			{Key: "foo", Operation: classification.OperationExists},
+		})
+		Expect(err).To(BeNil())
+		Expect(s).To(Equal("foo"))
+	})
+
+	It("getLabelSelector returns an error for invalid filters", func() {
+		_, err := classification.GetLabelSelector([]libsveltosv1alpha1.LabelFilter{
+			{Key: "foo", Operation: classification.OperationExists, Value: "bar"},
+		})
+		Expect(err).ToNot(BeNil())
+
+		_, err = classification.GetLabelSelector([]libsveltosv1alpha1.LabelFilter{
+			{Key: "foo", Operation: libsveltosv1alpha1.Operation("Contains"), Value: "bar"},
+		})
+		Expect(err).ToNot(BeNil())
+
+		_, err = classification.GetLabelSelector([]libsveltosv1alpha1.LabelFilter{
+			{Key: "foo", Operation: classification.OperationIn, Value: "a,not a valid value"},
+		})
+		Expect(err).ToNot(BeNil())
+	})
+
+	It("equality LabelFilters are sent to the API server as label selector", func() {
+		mu := &sync.Mutex{}
+		var queries []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			switch r.URL.Path {
+			case "/api":
+				_, _ = w.Write([]byte(`{"kind":"APIVersions","versions":[]}`))
+			case "/apis":
+				_, _ = w.Write([]byte(`{"kind":"APIGroupList","apiVersion":"v1","groups":[{"name":"example.com",` +
+					`"versions":[{"groupVersion":"example.com/v1","version":"v1"}],` +
+					`"preferredVersion":{"groupVersion":"example.com/v1","version":"v1"}}]}`))
+			case "/apis/example.com/v1":
+				_, _ = w.Write([]byte(`{"kind":"APIResourceList","apiVersion":"v1","groupVersion":"example.com/v1",` +
+					`"resources":[{"name":"widgets","singularName":"widget","namespaced":true,"kind":"Widget",` +
+					`"verbs":["list"]}]}`))
+			case "/apis/example.com/v1/widgets":
+				mu.Lock()
+				queries = append(queries, r.URL.Query().Get("labelSelector"))
+				mu.Unlock()
+				_, _ = w.Write([]byte(`{"kind":"WidgetList","apiVersion":"example.com/v1",` +
+					`"metadata":{"resourceVersion":"1"},"items":[]}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer server.Close()
+
+		classification.InitializeManagerWithObjects(&rest.Config{Host: server.URL})
+		manager := classification.GetManager()
+
+		minCount := 1
+		constraint := &classification.ResourceConstraint{
+			DeployedResourceConstraint: libsveltosv1alpha1.DeployedResourceConstraint{
+				Group: "example.com", Version: "v1", Kind: "Widget", MinCount: &minCount,
+				LabelFilters: []libsveltosv1alpha1.LabelFilter{
+					{Key: "app", Operation: libsveltosv1alpha1.OperationEqual, Value: "nginx"},
+					{Key: "env", Operation: libsveltosv1alpha1.OperationEqual, Value: "prod"},
+				},
+			},
+		}
+		isMatch, err := classification.IsResourceConstraintAMatch(manager, context.TODO(), nil, constraint)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeFalse())
+
+		mu.Lock()
+		defer mu.Unlock()
+		Expect(queries).ToNot(BeEmpty())
+		for i := range queries {
+			Expect(queries[i]).To(Equal("app=nginx,env=prod"))
+		}
+	})
+})
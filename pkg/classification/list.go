@@ -0,0 +1,157 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+)
+
+// listPageSize is the maximum number of resources requested per List call
+// when resources are only counted
+var listPageSize int64 = 500
+
+// listResources lists all resources of type gvk.
+// Built-in types, known to client-go scheme, are requested using protobuf,
+// which is cheaper to transfer and decode than JSON, and then converted to
+// unstructured. Any other type (CRDs, aggregated APIs) is listed using the
+// dynamic client. Transient errors are retried.
+func (m *manager) listResources(ctx context.Context, gvk *schema.GroupVersionKind, resource string,
+	options *metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+
+	var list *unstructured.UnstructuredList
+	err := m.retryTransient(ctx, fmt.Sprintf("list %s", gvk.String()), func() error {
+		var err error
+		list, err = m.listResourcesOnce(ctx, gvk, resource, options)
+		return err
+	})
+	return list, err
+}
+
+// listResourcesOnce lists, with a single request, resources of type gvk
+func (m *manager) listResourcesOnce(ctx context.Context, gvk *schema.GroupVersionKind, resource string,
+	options *metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+
+	if m.protobufLists && isBuiltInType(gvk) {
+		return m.listBuiltInResources(ctx, gvk, resource, options)
+	}
+
+	d := dynamic.NewForConfigOrDie(m.config)
+
+	resourceId := schema.GroupVersionResource{
+		Group:    gvk.Group,
+		Version:  gvk.Version,
+		Resource: resource,
+	}
+
+	return d.Resource(resourceId).List(ctx, *options)
+}
+
+// isBuiltInType returns true if a typed list for gvk is registered in client-go scheme
+func isBuiltInType(gvk *schema.GroupVersionKind) bool {
+	return scheme.Scheme.Recognizes(getListGVK(gvk))
+}
+
+func getListGVK(gvk *schema.GroupVersionKind) schema.GroupVersionKind {
+	return gvk.GroupVersion().WithKind(gvk.Kind + "List")
+}
+
+// listBuiltInResources lists resources of a built-in type using protobuf
+func (m *manager) listBuiltInResources(ctx context.Context, gvk *schema.GroupVersionKind, resource string,
+	options *metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+
+	list, err := scheme.Scheme.New(getListGVK(gvk))
+	if err != nil {
+		return nil, err
+	}
+
+	restClient, err := getProtobufRESTClient(m.config, gvk.GroupVersion())
+	if err != nil {
+		return nil, err
+	}
+
+	err = restClient.Get().
+		Resource(resource).
+		VersionedParams(options, scheme.ParameterCodec).
+		Do(ctx).
+		Into(list)
+	if err != nil {
+		return nil, err
+	}
+
+	return toUnstructuredList(gvk, list)
+}
+
+// getProtobufRESTClient returns a REST client for gv requesting protobuf
+// and accepting JSON
+func getProtobufRESTClient(config *rest.Config, gv schema.GroupVersion) (rest.Interface, error) {
+	cfg := rest.CopyConfig(config)
+	cfg.GroupVersion = &gv
+	cfg.APIPath = "/apis"
+	if gv.Group == "" {
+		cfg.APIPath = "/api"
+	}
+	cfg.ContentType = runtime.ContentTypeProtobuf
+	cfg.AcceptContentTypes = runtime.ContentTypeProtobuf + "," + runtime.ContentTypeJSON
+	cfg.NegotiatedSerializer = scheme.Codecs.WithoutConversion()
+	if cfg.UserAgent == "" {
+		cfg.UserAgent = rest.DefaultKubernetesUserAgent()
+	}
+
+	return rest.RESTClientFor(cfg)
+}
+
+// toUnstructuredList converts a typed list to an UnstructuredList. Typed items
+// have no TypeMeta, so apiVersion and kind are set from gvk.
+func toUnstructuredList(gvk *schema.GroupVersionKind, list runtime.Object) (*unstructured.UnstructuredList, error) {
+	listAccessor, err := meta.ListAccessor(list)
+	if err != nil {
+		return nil, err
+	}
+
+	objects, err := meta.ExtractList(list)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &unstructured.UnstructuredList{
+		Items: make([]unstructured.Unstructured, len(objects)),
+	}
+	result.SetResourceVersion(listAccessor.GetResourceVersion())
+	result.SetContinue(listAccessor.GetContinue())
+	result.SetRemainingItemCount(listAccessor.GetRemainingItemCount())
+
+	for i := range objects {
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(objects[i])
+		if err != nil {
+			return nil, err
+		}
+		result.Items[i].Object = content
+		result.Items[i].SetGroupVersionKind(*gvk)
+	}
+
+	return result, nil
+}
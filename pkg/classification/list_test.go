@@ -0,0 +1,93 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog/v2/klogr"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: list", func() {
+	BeforeEach(func() {
+		classification.Reset()
+	})
+
+	It("isBuiltInType returns true only for types known to client-go", func() {
+		Expect(classification.IsBuiltInType(&schema.GroupVersionKind{Version: "v1", Kind: "Pod"})).To(BeTrue())
+		Expect(classification.IsBuiltInType(
+			&schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"})).To(BeTrue())
+		Expect(classification.IsBuiltInType(&schema.GroupVersionKind{
+			Group: libsveltosv1alpha1.GroupVersion.Group, Version: libsveltosv1alpha1.GroupVersion.Version,
+			Kind: libsveltosv1alpha1.ClassifierKind})).To(BeFalse())
+	})
+
+	It("toUnstructuredList converts typed list and sets items apiVersion and kind", func() {
+		gvk := schema.GroupVersionKind{Version: "v1", Kind: "Pod"}
+		list := &corev1.PodList{
+			ListMeta: metav1.ListMeta{ResourceVersion: "100"},
+			Items: []corev1.Pod{
+				{
+					ObjectMeta: metav1.ObjectMeta{Namespace: randomString(), Name: randomString()},
+					Spec:       corev1.PodSpec{NodeName: randomString()},
+				},
+			},
+		}
+
+		result, err := classification.ToUnstructuredList(&gvk, list)
+		Expect(err).To(BeNil())
+		Expect(result.GetResourceVersion()).To(Equal("100"))
+		Expect(len(result.Items)).To(Equal(1))
+		Expect(result.Items[0].GroupVersionKind()).To(Equal(gvk))
+		Expect(result.Items[0].GetName()).To(Equal(list.Items[0].Name))
+		Expect(result.Items[0].GetNamespace()).To(Equal(list.Items[0].Namespace))
+		Expect(result.Items[0].Object["spec"]).To(HaveKeyWithValue("nodeName", list.Items[0].Spec.NodeName))
+	})
+
+	It("listResources lists built-in types using protobuf", func() {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: randomString()}}
+		Expect(testEnv.Create(context.TODO(), ns)).To(Succeed())
+		Expect(waitForObject(context.TODO(), testEnv.Client, ns)).To(Succeed())
+
+		classification.InitializeManagerWithSkip(context.TODO(), klogr.New(), testEnv.Config, testEnv.Client, nil, 10)
+		classification.ApplyOptions(classification.WithProtobufLists(true))
+		manager := classification.GetManager()
+
+		gvk := schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}
+		list, err := classification.ListResources(manager, context.TODO(), &gvk, "namespaces", &metav1.ListOptions{})
+		Expect(err).To(BeNil())
+		Expect(list.GetResourceVersion()).ToNot(BeEmpty())
+
+		found := false
+		for i := range list.Items {
+			Expect(list.Items[i].GroupVersionKind()).To(Equal(gvk))
+			if list.Items[i].GetName() == ns.Name {
+				found = true
+			}
+		}
+		Expect(found).To(BeTrue())
+	})
+})
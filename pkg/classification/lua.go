@@ -0,0 +1,143 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"emperror.dev/errors"
+	lua "github.com/yuin/gopher-lua"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// luaEvaluateFunction is the function a Lua script must define
+	luaEvaluateFunction = "evaluate"
+
+	// luaTimeout is the maximum time a Lua script can run
+	luaTimeout = 5 * time.Second
+)
+
+// runLuaScript runs the Lua script evaluate function passing resources as argument.
+// Only base, table, string and math libraries are available to the script.
+func (m *manager) runLuaScript(ctx context.Context, script string,
+	resources []unstructured.Unstructured) (bool, error) {
+
+	l := newLuaState()
+	defer l.Close()
+
+	luaCtx, cancel := context.WithTimeout(ctx, luaTimeout)
+	defer cancel()
+	l.SetContext(luaCtx)
+
+	if err := l.DoString(script); err != nil {
+		return false, newInvalidClassifierError(errors.Wrap(err, "failed to load lua script"))
+	}
+
+	fn := l.GetGlobal(luaEvaluateFunction)
+	if fn.Type() != lua.LTFunction {
+		return false, newInvalidClassifierError(
+			fmt.Errorf("lua script does not define function %s", luaEvaluateFunction))
+	}
+
+	arg := l.NewTable()
+	for i := range resources {
+		arg.Append(toLuaValue(l, resources[i].Object))
+	}
+
+	if err := l.CallByParam(lua.P{Fn: fn, NRet: 1, Protect: true}, arg); err != nil {
+		if luaCtx.Err() != nil {
+			return false, newInvalidClassifierError(errors.Wrap(err,
+				fmt.Sprintf("lua script did not complete in %s", luaTimeout)))
+		}
+		return false, newInvalidClassifierError(errors.Wrap(err, "failed to run lua script"))
+	}
+
+	ret := l.Get(-1)
+	l.Pop(1)
+
+	result, ok := ret.(lua.LBool)
+	if !ok {
+		return false, newInvalidClassifierError(fmt.Errorf("lua function %s returned %s instead of a boolean",
+			luaEvaluateFunction, ret.Type()))
+	}
+
+	return bool(result), nil
+}
+
+// newLuaState returns a Lua state with no access to the filesystem or to the OS
+func newLuaState() *lua.LState {
+	l := lua.NewState(lua.Options{SkipOpenLibs: true})
+
+	libs := []struct {
+		name string
+		fn   lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	}
+	for i := range libs {
+		l.Push(l.NewFunction(libs[i].fn))
+		l.Push(lua.LString(libs[i].name))
+		l.Call(1, 0)
+	}
+
+	// Base library allows loading files
+	for _, name := range []string{"dofile", "loadfile"} {
+		l.SetGlobal(name, lua.LNil)
+	}
+
+	return l
+}
+
+// toLuaValue converts an unstructured value to its Lua representation
+func toLuaValue(l *lua.LState, value interface{}) lua.LValue {
+	switch v := value.(type) {
+	case nil:
+		return lua.LNil
+	case bool:
+		return lua.LBool(v)
+	case string:
+		return lua.LString(v)
+	case int64:
+		return lua.LNumber(v)
+	case int32:
+		return lua.LNumber(v)
+	case int:
+		return lua.LNumber(v)
+	case float64:
+		return lua.LNumber(v)
+	case map[string]interface{}:
+		table := l.NewTable()
+		for k := range v {
+			table.RawSetString(k, toLuaValue(l, v[k]))
+		}
+		return table
+	case []interface{}:
+		table := l.NewTable()
+		for i := range v {
+			table.Append(toLuaValue(l, v[i]))
+		}
+		return table
+	default:
+		return lua.LString(fmt.Sprintf("%v", v))
+	}
+}
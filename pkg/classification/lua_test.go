@@ -0,0 +1,110 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosutils "github.com/projectsveltos/libsveltos/lib/utils"
+)
+
+const (
+	// Cluster is a match if every namespace with a Pod also has a Deployment
+	luaScript = `
+function evaluate(resources)
+  local pods = {}
+  local deployments = {}
+  for _, r in ipairs(resources) do
+    if r.kind == "Pod" then
+      pods[r.metadata.namespace] = true
+    elseif r.kind == "Deployment" then
+      deployments[r.metadata.namespace] = true
+    end
+  end
+  for ns, _ in pairs(pods) do
+    if not deployments[ns] then
+      return false
+    end
+  end
+  return true
+end`
+
+	deploymentTemplate = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  namespace: %s
+  name: %s
+spec:
+  replicas: 1`
+)
+
+var _ = Describe("Manager: Lua", func() {
+	BeforeEach(func() {
+		classification.Reset()
+	})
+
+	It("runLuaScript evaluates resources", func() {
+		classification.InitializeManagerWithObjects(nil)
+		manager := classification.GetManager()
+
+		namespace := randomString()
+		pod, err := libsveltosutils.GetUnstructured([]byte(fmt.Sprintf(podTemplate, namespace, randomString())))
+		Expect(err).To(BeNil())
+
+		isMatch, err := classification.RunLuaScript(manager, context.TODO(), luaScript,
+			[]unstructured.Unstructured{*pod})
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeFalse())
+
+		deployment, err := libsveltosutils.GetUnstructured([]byte(fmt.Sprintf(deploymentTemplate, namespace, randomString())))
+		Expect(err).To(BeNil())
+
+		isMatch, err = classification.RunLuaScript(manager, context.TODO(), luaScript,
+			[]unstructured.Unstructured{*pod, *deployment})
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+	})
+
+	It("runLuaScript returns an error for invalid scripts", func() {
+		classification.InitializeManagerWithObjects(nil)
+		manager := classification.GetManager()
+
+		// evaluate not defined
+		_, err := classification.RunLuaScript(manager, context.TODO(), `function eval() return true end`, nil)
+		Expect(err).ToNot(BeNil())
+
+		// evaluate not returning a boolean
+		_, err = classification.RunLuaScript(manager, context.TODO(), `function evaluate(r) return 1 end`, nil)
+		Expect(err).ToNot(BeNil())
+
+		// filesystem access is not allowed
+		_, err = classification.RunLuaScript(manager, context.TODO(),
+			`function evaluate(r) dofile("/etc/passwd") return true end`, nil)
+		Expect(err).ToNot(BeNil())
+
+		// syntax error
+		_, err = classification.RunLuaScript(manager, context.TODO(), `function evaluate(r)`, nil)
+		Expect(err).ToNot(BeNil())
+	})
+})
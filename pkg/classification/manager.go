@@ -22,15 +22,18 @@ import (
 	"reflect"
 	"sync"
 	"sync/atomic"
-	"syscall"
 	"time"
 
 	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/rest"
-	"k8s.io/klog/v2/klogr"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/projectsveltos/classifier-agent/pkg/sharedwatch"
 	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
 	"github.com/projectsveltos/libsveltos/lib/crd"
 	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
@@ -45,6 +48,11 @@ type ReactToNotification func(gvk *schema.GroupVersionKind)
 
 // manager represents a client implementing the ClassifierInterface
 type manager struct {
+	// watchEvents is the number of watch events received since evaluation
+	// interval was last adapted. Accessed atomically, first field so it is
+	// 64-bit aligned.
+	watchEvents uint64
+
 	log logr.Logger
 	client.Client
 	config *rest.Config
@@ -53,6 +61,11 @@ type manager struct {
 	clusterNamespace string
 	clusterName      string
 	clusterType      libsveltosv1alpha1.ClusterType
+	// shardHasher computes ReportShardLabel. DefaultShardHasher when nil.
+	shardHasher ShardHasher
+	// managementClient, when set, is used to send ClassifierReports instead of
+	// a client built from the management cluster kubeconfig Secret. Used by tests.
+	managementClient client.Client
 
 	watchMu *sync.Mutex
 	// rebuildResourceToWatch indicates (value different from zero) that list
@@ -64,13 +77,26 @@ type manager struct {
 	mu *sync.Mutex
 	// jobQueue contains name of all Classifier instances that need to be evaluated
 	jobQueue []string
+	// evaluationMu serializes Classifier evaluations of the background loop
+	// and of EvaluateAll
+	evaluationMu *sync.Mutex
 	// interval is the interval at which queued Classifiers are evaluated
 	interval time.Duration
+	// minInterval and maxInterval, when maxInterval is set, bound the
+	// evaluation interval which is adapted to cluster churn
+	minInterval time.Duration
+	maxInterval time.Duration
 
 	// List of gvk with a watcher
 	// Key: GroupResourceVersion currently being watched
-	// Value: stop channel
-	watchers map[schema.GroupVersionKind]context.CancelFunc
+	// Value: watcher, used to stop it and verify it exited
+	watchers map[schema.GroupVersionKind]*watcher
+	// stoppingWatchers are watchers which have been cancelled and whose
+	// exit has not been verified yet
+	stoppingWatchers []stoppingWatcher
+	// sharedWatches, when set, is used to watch resources in place of
+	// dedicated informers
+	sharedWatches *sharedwatch.Registry
 
 	// List of resources to watch not installed in the cluster yet
 	unknownResourcesToWatch []schema.GroupVersionKind
@@ -78,22 +104,256 @@ type manager struct {
 	// react is the method that gets invoked when any of the resources
 	// being watched changes
 	react ReactToNotification
+
+	// allowedActions contains the ActionTypes that can be executed.
+	// When nil, DefaultAllowedActions are allowed.
+	allowedActions []ActionType
+	// actionConfigMapNamespaces contains the namespaces Label actions can
+	// create and update ConfigMaps in
+	actionConfigMapNamespaces []string
+	// actionsDryRun indicates actions must only be logged
+	actionsDryRun bool
+	// eventRecorder is used by Event actions
+	eventRecorder record.EventRecorder
+
+	actionMatchesMu *sync.Mutex
+	// actionMatches contains, per Classifier, the match result non idempotent
+	// actions last ran with
+	actionMatches map[string]bool
+
+	celMu *sync.Mutex
+	// celPrograms contains, per Classifier, the compiled CEL programs
+	// Key: Classifier name
+	celPrograms map[string]*classifierPrograms
+
+	historyMu *sync.RWMutex
+	// historySize is the number of evaluation results kept per Classifier
+	historySize int
+	// history contains, per Classifier, most recent evaluation results
+	// Key: Classifier name
+	history map[string]*evaluationHistory
+	// latest contains, per Classifier, the most recent evaluation result.
+	// Kept even when history is disabled as it is used for the evaluation summary
+	latest map[string]EvaluationResult
+	// evaluationSummary indicates whether the evaluation summary ConfigMap
+	// must be refreshed after each evaluation cycle
+	evaluationSummary bool
+
+	// clockSkewTolerance is the offset between cluster and classifier-agent clocks
+	// below which no correction is applied. Zero disables clock skew detection.
+	clockSkewTolerance time.Duration
+	clockSkewMu        *sync.Mutex
+	// clockSkew is the offset of the cluster clock, zero when within tolerance
+	clockSkew          time.Duration
+	clockSkewRefreshed time.Time
+
+	// stateFile, when set, is the file the classification state is written to after
+	// each evaluation cycle, in stateFileFormat
+	stateFile       string
+	stateFileFormat ClassificationStateFormat
+
+	// reportDeduplication indicates whether ClassifierReports delivered to the management
+	// cluster list the Classifiers with overlapping ClassifierLabels
+	reportDeduplication bool
+
+	// cycleBudget, when positive, is the fraction of the evaluation interval an evaluation
+	// cycle can take. Classifiers not evaluated by then are carried over to next cycle.
+	cycleBudget float64
+
+	// pollInterval, when positive, disables resource watches: all Classifiers are
+	// evaluated every pollInterval instead
+	pollInterval time.Duration
+	// reportVerbosity, when minimal, omits the explanation annotation on ClassifierReports
+	reportVerbosity ReportVerbosity
+
+	// consistentSnapshot indicates whether all resources a Classifier depends on
+	// must be listed at the same resourceVersion
+	consistentSnapshot bool
+
+	// constraintMemoization indicates whether, within one evaluation cycle, resources
+	// matching identical constraints are counted once for all Classifiers
+	constraintMemoization bool
+
+	// versionMatching defines how cluster Kubernetes version is compared
+	// against version constraints
+	versionMatching VersionMatchingMode
+
+	// protobufLists indicates whether built-in types are listed using protobuf
+	protobufLists bool
+
+	cloudProvidersMu *sync.Mutex
+	// cloudProviders is the comma separated list of cloud providers last
+	// detected in the cluster
+	cloudProviders string
+
+	// broadConstraintPolicy is applied to constraints listing more than
+	// broadConstraintThreshold resources with no namespace nor label filter
+	broadConstraintPolicy    BroadConstraintPolicy
+	broadConstraintThreshold int
+	deferredMu               *sync.Mutex
+	// deferred contains Classifiers in the slow lane
+	// Key: Classifier name
+	// Value: time Classifier can be evaluated again
+	deferred map[string]time.Time
+
+	explanationsMu *sync.Mutex
+	// explanations contains the JSON encoded explanation of the last evaluation
+	// Key: Classifier name
+	explanations map[string]string
+
+	// hooks are layered around each Classifier evaluation
+	hooks             []EvaluationHook
+	hookAnnotationsMu *sync.Mutex
+	// hookAnnotations contains the annotations hooks set on the last evaluation
+	// of each Classifier
+	hookAnnotations map[string]map[string]string
+
+	pendingReportsMu *sync.Mutex
+	// pendingReports contains results which could not be persisted because
+	// ClassifierReport CRD is not installed
+	// Key: Classifier name
+	pendingReports map[string]*libsveltosv1alpha1.ClassifierReport
+	// sendReportsWithoutCRD indicates whether pendingReports are sent to the
+	// management cluster
+	sendReportsWithoutCRD bool
+
+	// remoteInterval, when set, enables remote mode: Classifiers are fetched
+	// from the management cluster at this interval instead of being read from
+	// the managed cluster
+	remoteInterval time.Duration
+	remoteMu       *sync.RWMutex
+	// remoteClassifiers contains Classifiers last fetched from management cluster
+	// Key: Classifier name
+	remoteClassifiers map[string]*libsveltosv1alpha1.Classifier
+
+	// trends contains resource counts over time, used by TrendConstraints
+	trends *trendStore
+
+	// events counts Event occurrences over time, used by EventConstraints
+	events *eventCounter
+
+	// nodeLabelPrefix, when set, enables mirroring of evaluation results to
+	// labels, with this prefix, on all Nodes
+	nodeLabelPrefix string
+	// nodeLabelClassifiers are the Classifiers whose results are mirrored to
+	// node labels. All when empty.
+	nodeLabelClassifiers []string
+
+	// versionProviders are queried, in order, for the cluster Kubernetes version.
+	// API server is used when empty.
+	versionProviders []VersionProvider
+
+	rbacMu *sync.Mutex
+	// effectiveRBAC contains, per Classifier, the API server accesses of the last evaluation
+	// Key: Classifier name
+	effectiveRBAC map[string][]AccessRecord
+	// effectiveRBACAnnotation indicates whether effective RBAC is set as annotation
+	// on ClassifierReports in the managed cluster
+	effectiveRBACAnnotation bool
+
+	// conformanceSamplePercent is the percentage of Classifier evaluations
+	// verified by the reference evaluator. Zero disables verification.
+	conformanceSamplePercent int
+
+	// tuningMu protects the knobs which can be changed at runtime: interval,
+	// minInterval, maxInterval and broadConstraintThreshold
+	tuningMu *sync.RWMutex
+	// tuningChanges contains the most recent changes made to tuning knobs
+	tuningChanges []TuningChange
+	// tuningAuthenticator authenticates tuning endpoint requests
+	tuningAuthenticator tuningAuthenticator
+
+	// agentNamespace and agentPodName identify the Pod classifier-agent runs in
+	agentNamespace string
+	agentPodName   string
+	agentMu        *sync.Mutex
+	// agentFacts are the facts about classifier-agent environment. Collected on first use.
+	agentFacts map[string]string
+
+	discoveryMu *sync.Mutex
+	// discoveryClient caches discovery results used by APIResourceConstraints.
+	// Created on first use.
+	discoveryClient discovery.CachedDiscoveryInterface
+	// restMapper maps constraint GVKs to resources using discoveryClient.
+	// Created on first use.
+	restMapper *restmapper.DeferredDiscoveryRESTMapper
+
+	// host, when set, is the cluster hosting the control plane of the managed
+	// cluster, which HostResourceConstraints are evaluated against
+	host *hostCluster
+
+	// prometheus, when set, is the endpoint PrometheusConstraints are evaluated against
+	prometheus *prometheusEndpoint
+
+	// metricsProviders are the HTTP MetricsProviders MetricConstraints can reference,
+	// in addition to ConfigMaps labeled MetricsProviderLabel
+	metricsProviders []MetricsProvider
+	// metricsResyncInterval is the interval at which Classifiers with MetricConstraints
+	// are queued when metricsProviders is not empty
+	metricsResyncInterval time.Duration
+
+	// handshake, when set, makes sure only the classifier-agent instance holding the
+	// handshake Lease evaluates Classifiers and delivers ClassifierReports
+	handshake *upgradeHandshake
+
+	ageMu *sync.Mutex
+	// ageEvaluations contains, per Classifier, the evaluation scheduled for when
+	// a resource crosses a MinAge or MaxAge bound, or an Event occurrence leaves
+	// an EventConstraint window
+	// Key: Classifier name
+	ageEvaluations map[string]*ageEvaluation
+
+	overloadMu *sync.Mutex
+	// memoryPressureThreshold is the heap size, in bytes, above which classifier-agent
+	// is degraded. Zero disables memory pressure detection.
+	memoryPressureThreshold uint64
+	// failedCycles is the number of consecutive evaluation cycles in which all
+	// evaluations failed
+	failedCycles int
+	// degraded is the Degraded condition while classifier-agent is overloaded, nil otherwise
+	degraded *metav1.Condition
+
+	// listRetries is the number of times a List or Get failing with a transient
+	// error is retried within an evaluation. Zero disables retries.
+	listRetries int
+	// listRetryDelay is the delay before the first retry
+	listRetryDelay time.Duration
+
+	healthMu *sync.Mutex
+	// healthGate, when set, skips evaluation cycles while API server is not ready
+	// or discovery fails
+	healthGate bool
+	// healthGateTimeout bounds each health gate check
+	healthGateTimeout time.Duration
+	// unhealthy is the ClusterUnhealthy condition while the health gate fails, nil otherwise
+	unhealthy *metav1.Condition
+	// healthClients are the clients used by the health gate
+	healthClients *healthClients
+
+	factMu *sync.Mutex
+	// factRefreshes contains, per cached fact, the time it was last refreshed
+	factRefreshes map[string]time.Time
+	// maxFactStaleness is the age above which a cached fact is stale. Zero disables
+	// staleness reporting.
+	maxFactStaleness time.Duration
 }
 
 // InitializeManager initializes a manager implementing the ClassifierInterface
 func InitializeManager(ctx context.Context, l logr.Logger, config *rest.Config, c client.Client,
 	clusterNamespace, clusterName string, cluserType libsveltosv1alpha1.ClusterType,
-	react ReactToNotification, intervalInSecond uint, sendReport bool) {
+	react ReactToNotification, intervalInSecond uint, sendReport bool, options ...Option) {
 
 	if managerInstance == nil {
 		getManagerLock.Lock()
 		defer getManagerLock.Unlock()
 		if managerInstance == nil {
 			l.V(logs.LogInfo).Info(fmt.Sprintf("Creating manager now. Interval (in seconds): %d", intervalInSecond))
-			managerInstance = &manager{log: l, Client: c, config: config}
+			// API server traffic is attributed to the Classifier being evaluated
+			managerInstance = &manager{log: l, Client: c, config: withCostTracking(config)}
 			managerInstance.jobQueue = make([]string, 0)
 			managerInstance.interval = time.Duration(intervalInSecond) * time.Second
 			managerInstance.mu = &sync.Mutex{}
+			managerInstance.evaluationMu = &sync.Mutex{}
 
 			managerInstance.resourcesToWatch = make([]schema.GroupVersionKind, 0)
 			managerInstance.rebuildResourceToWatch = 0
@@ -101,7 +361,49 @@ func InitializeManager(ctx context.Context, l logr.Logger, config *rest.Config,
 
 			managerInstance.unknownResourcesToWatch = make([]schema.GroupVersionKind, 0)
 
-			managerInstance.watchers = make(map[schema.GroupVersionKind]context.CancelFunc)
+			managerInstance.watchers = make(map[schema.GroupVersionKind]*watcher)
+
+			managerInstance.actionMatchesMu = &sync.Mutex{}
+			managerInstance.actionMatches = make(map[string]bool)
+			managerInstance.celMu = &sync.Mutex{}
+			managerInstance.celPrograms = make(map[string]*classifierPrograms)
+
+			managerInstance.historyMu = &sync.RWMutex{}
+			managerInstance.historySize = DefaultEvaluationHistorySize
+			managerInstance.history = make(map[string]*evaluationHistory)
+			managerInstance.latest = make(map[string]EvaluationResult)
+			managerInstance.versionMatching = VersionMatchingStrict
+			managerInstance.cloudProvidersMu = &sync.Mutex{}
+			managerInstance.broadConstraintPolicy = BroadConstraintAllow
+			managerInstance.broadConstraintThreshold = DefaultBroadConstraintThreshold
+			managerInstance.deferredMu = &sync.Mutex{}
+			managerInstance.deferred = make(map[string]time.Time)
+			managerInstance.explanationsMu = &sync.Mutex{}
+			managerInstance.explanations = make(map[string]string)
+			managerInstance.hookAnnotationsMu = &sync.Mutex{}
+			managerInstance.hookAnnotations = make(map[string]map[string]string)
+			managerInstance.pendingReportsMu = &sync.Mutex{}
+			managerInstance.pendingReports = make(map[string]*libsveltosv1alpha1.ClassifierReport)
+			managerInstance.remoteMu = &sync.RWMutex{}
+			managerInstance.remoteClassifiers = make(map[string]*libsveltosv1alpha1.Classifier)
+			managerInstance.trends = newTrendStore(DefaultTrendRetention)
+			managerInstance.events = newEventCounter()
+			managerInstance.tuningMu = &sync.RWMutex{}
+			managerInstance.tuningAuthenticator = managerInstance.authenticateTuningRequest
+			managerInstance.discoveryMu = &sync.Mutex{}
+			managerInstance.agentMu = &sync.Mutex{}
+			managerInstance.ageMu = &sync.Mutex{}
+			managerInstance.ageEvaluations = make(map[string]*ageEvaluation)
+			managerInstance.overloadMu = &sync.Mutex{}
+			managerInstance.healthMu = &sync.Mutex{}
+			managerInstance.listRetries = DefaultListRetries
+			managerInstance.listRetryDelay = DefaultListRetryDelay
+			managerInstance.clockSkewMu = &sync.Mutex{}
+			managerInstance.clockSkewTolerance = DefaultClockSkewTolerance
+			managerInstance.factMu = &sync.Mutex{}
+			managerInstance.factRefreshes = make(map[string]time.Time)
+			managerInstance.rbacMu = &sync.Mutex{}
+			managerInstance.effectiveRBAC = make(map[string][]AccessRecord)
 
 			managerInstance.react = react
 			managerInstance.sendReport = sendReport
@@ -109,11 +411,45 @@ func InitializeManager(ctx context.Context, l logr.Logger, config *rest.Config,
 			managerInstance.clusterName = clusterName
 			managerInstance.clusterType = cluserType
 
+			// Options take precedence over the cluster type profile
+			managerInstance.applyBehaviorProfile(GetDefaultBehaviorProfile(cluserType))
+			for i := range options {
+				options[i](managerInstance)
+			}
+
+			if managerInstance.isRemoteMode() {
+				managerInstance.react = managerInstance.reactToRemoteClassifiers
+				go managerInstance.syncRemoteClassifiers(ctx)
+			}
+
+			l.V(logs.LogInfo).Info(fmt.Sprintf("cluster type %s: poll interval %s, report verbosity %q",
+				cluserType, managerInstance.pollInterval, managerInstance.reportVerbosity))
 			go managerInstance.evaluateClassifiers(ctx)
-			go managerInstance.buildResourceToWatch(ctx)
+			if managerInstance.isPolling() {
+				go managerInstance.pollClassifiers(ctx)
+			} else {
+				go managerInstance.buildResourceToWatch(ctx)
+			}
+			if managerInstance.host != nil {
+				go managerInstance.resyncHostClassifiers(ctx)
+			}
+			if managerInstance.prometheus != nil {
+				go managerInstance.resyncPrometheusClassifiers(ctx)
+			}
+			if len(managerInstance.metricsProviders) != 0 {
+				go managerInstance.resyncMetricClassifiers(ctx)
+			}
+			if managerInstance.handshake != nil {
+				go managerInstance.runHandshake(ctx)
+			}
 			// Start a watcher for CustomResourceDefinition
 			go crd.WatchCustomResourceDefinition(ctx, managerInstance.config,
-				restartIfNeeded, managerInstance.log)
+				func(gvk *schema.GroupVersionKind) {
+					managerInstance.invalidateDiscoveryCache(&crdGVK)
+					managerInstance.startWatcherIfNeeded(ctx, gvk)
+					managerInstance.evaluateClassifiersUsingCRD(ctx, gvk)
+					managerInstance.evaluatePendingReports(gvk)
+				}, managerInstance.log)
 		}
 	}
 }
@@ -139,24 +475,32 @@ func (m *manager) EvaluateClassifier(classifierName string) {
 	m.jobQueue = append(m.jobQueue, classifierName)
 }
 
-// If there is any classifier using this GVK, restart agent
-// On restart, agent will be able to start a watcher (a watcher
-// cannot be started on api-resources not present in the cluster)
-func restartIfNeeded(gvk *schema.GroupVersionKind) {
-	manager := GetManager()
-	manager.mu.Lock()
-	defer manager.mu.Unlock()
-
-	logger := klogr.New()
-	logger.V(logs.LogDebug).Info(fmt.Sprintf("react to CustomResourceDefinition %s change",
-		gvk.String()))
-
-	for i := range manager.unknownResourcesToWatch {
-		tmpGVK := manager.unknownResourcesToWatch[i]
-		if reflect.DeepEqual(*gvk, tmpGVK) {
-			if killErr := syscall.Kill(syscall.Getpid(), syscall.SIGTERM); killErr != nil {
-				panic("kill -TERM failed")
-			}
+// startWatcherIfNeeded is invoked when a CustomResourceDefinition changes.
+// If there is any classifier using this GVK, a watcher is started for it.
+// A watcher cannot be started on api-resources not present in the cluster yet,
+// so such GVKs are kept in unknownResourcesToWatch till their CRD is installed.
+func (m *manager) startWatcherIfNeeded(ctx context.Context, gvk *schema.GroupVersionKind) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	logger := m.log.WithValues("gvk", gvk.String())
+	logger.V(logs.LogDebug).Info("react to CustomResourceDefinition change")
+
+	for i := range m.unknownResourcesToWatch {
+		tmpGVK := m.unknownResourcesToWatch[i]
+		if !reflect.DeepEqual(*gvk, tmpGVK) {
+			continue
 		}
+
+		// CustomResourceDefinition might not be served yet. If so, watcher
+		// will be started on next CustomResourceDefinition update (Established)
+		err := m.startWatcher(ctx, gvk, m.react)
+		if err != nil {
+			logger.V(logs.LogInfo).Info(fmt.Sprintf("failed to start watcher: %v", err))
+			return
+		}
+
+		m.unknownResourcesToWatch = remove(m.unknownResourcesToWatch, i)
+		return
 	}
 }
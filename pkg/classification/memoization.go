@@ -0,0 +1,195 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var memoizedConstraints = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "memoized_constraints_total",
+		Help:      "Number of resource constraints evaluated reusing the resources matched for another Classifier",
+	},
+)
+
+func init() {
+	metrics.Registry.MustRegister(memoizedConstraints)
+}
+
+// matchCache shares, within one evaluation cycle, the number of resources matching
+// a resource constraint across all Classifiers with an identical constraint, count
+// thresholds aside. Resources are listed only for the first Classifier; MinCount
+// and MaxCount are then verified for each Classifier.
+type matchCache struct {
+	mu      sync.Mutex
+	entries map[string]*matchCacheEntry
+}
+
+type matchCacheEntry struct {
+	count  int
+	served bool
+	// notes added while resources were matched, added again for each Classifier
+	notes []string
+	// cost and accesses of matching resources, attributed again to each Classifier
+	// so its cost and effective RBAC do not depend on the evaluation order
+	cost     *EvaluationCost
+	accesses []AccessRecord
+}
+
+type matchCacheKey struct{}
+
+// withMatchCache returns a context sharing a new matchCache across all Classifiers
+// evaluated with it. No-op if memoization is disabled.
+func (m *manager) withMatchCache(ctx context.Context) context.Context {
+	if !m.constraintMemoization {
+		return ctx
+	}
+	return context.WithValue(ctx, matchCacheKey{}, &matchCache{entries: make(map[string]*matchCacheEntry)})
+}
+
+func getMatchCache(ctx context.Context) *matchCache {
+	cache, _ := ctx.Value(matchCacheKey{}).(*matchCache)
+	return cache
+}
+
+func (c *matchCache) get(key string) (*matchCacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	return entry, ok
+}
+
+func (c *matchCache) set(key string, entry *matchCacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = entry
+}
+
+// getConstraintKey returns a key identifying the resources matching constraint:
+// GVK, namespace and filters, with filters sorted and MinCount and MaxCount ignored.
+// Returns false if constraint cannot be memoized: when it does not verify a count,
+// or when matching resources depends on the evaluation time.
+func getConstraintKey(constraint *ResourceConstraint) (string, bool) {
+	if constraint.MustNotExist || constraint.Trend != nil || constraint.Cardinality != nil ||
+		constraint.Percentage != nil || constraint.MinAge != nil || constraint.MaxAge != nil {
+
+		return "", false
+	}
+
+	key, err := getNormalizedConstraint(constraint, "minCount", "maxCount")
+	if err != nil {
+		return "", false
+	}
+	return key, true
+}
+
+// getNormalizedConstraint returns constraint JSON encoding, without ignoredFields,
+// with lists and map keys sorted. Constraints selecting the same resources have
+// the same encoding.
+func getNormalizedConstraint(constraint *ResourceConstraint, ignoredFields ...string) (string, error) {
+	data, err := json.Marshal(constraint)
+	if err != nil {
+		return "", err
+	}
+	fields := make(map[string]interface{})
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return "", err
+	}
+	for i := range ignoredFields {
+		delete(fields, ignoredFields[i])
+	}
+
+	// All lists in a constraint are combined regardless of their order
+	for name, value := range fields {
+		list, ok := value.([]interface{})
+		if !ok {
+			continue
+		}
+		elements := make([]string, len(list))
+		for i := range list {
+			element, err := json.Marshal(list[i])
+			if err != nil {
+				return "", err
+			}
+			elements[i] = string(element)
+		}
+		sort.Strings(elements)
+		fields[name] = elements
+	}
+
+	// Map keys are sorted when encoding
+	key, err := json.Marshal(fields)
+	if err != nil {
+		return "", err
+	}
+	return string(key), nil
+}
+
+// countMemoizedResources returns the number of resources matching constraint, computing
+// it only if no other Classifier evaluated with the same cache has an identical constraint.
+// Unlike countMatchingResources, all resources are counted so that the count can be
+// verified against any threshold. Returns false, as last value, if constraint cannot be
+// memoized.
+func (m *manager) countMemoizedResources(ctx context.Context, cache *matchCache,
+	classifier *libsveltosv1alpha1.Classifier, constraint *ResourceConstraint) (int, bool, bool, error) {
+
+	// Broad constraint policies other than Allow act on the Classifier being evaluated
+	if m.broadConstraintPolicy != "" && m.broadConstraintPolicy != BroadConstraintAllow {
+		return 0, false, false, nil
+	}
+
+	key, ok := getConstraintKey(constraint)
+	if !ok {
+		return 0, false, false, nil
+	}
+
+	tracker := getCostTracker(ctx)
+	entry, ok := cache.get(key)
+	if ok {
+		memoizedConstraints.Inc()
+		if tracker != nil {
+			tracker.replay(entry.cost, entry.accesses)
+		}
+	} else {
+		// Requests are attributed to the Classifier being evaluated through parent
+		// and recorded to be attributed to the next ones
+		memoTracker := &costTracker{access: newAccessRecorder(), parent: tracker}
+		countCtx, notes := withEvaluationNotes(withCostTracker(ctx, memoTracker))
+		count, _, served, err := m.countResources(countCtx, classifier, constraint, nil, nil)
+		if err != nil {
+			return 0, false, true, err
+		}
+		entry = &matchCacheEntry{count: count, served: served, notes: notes.get(),
+			cost: memoTracker.getCost(0), accesses: memoTracker.access.list()}
+		cache.set(key, entry)
+	}
+
+	for i := range entry.notes {
+		addEvaluationNote(ctx, entry.notes[i])
+	}
+	return entry.count, entry.served, true, nil
+}
@@ -0,0 +1,189 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2/klogr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: constraint memoization", func() {
+	var server *httptest.Server
+	var podLists int32
+
+	BeforeEach(func() {
+		atomic.StoreInt32(&podLists, 0)
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			switch r.URL.Path {
+			case "/api":
+				_, _ = w.Write([]byte(`{"kind":"APIVersions","versions":["v1"]}`))
+			case "/api/v1":
+				_, _ = w.Write([]byte(`{"kind":"APIResourceList","groupVersion":"v1","resources":[` +
+					`{"name":"pods","singularName":"pod","namespaced":true,"kind":"Pod","verbs":["list"]}]}`))
+			case "/apis":
+				_, _ = w.Write([]byte(`{"kind":"APIGroupList","apiVersion":"v1","groups":[]}`))
+			case "/api/v1/pods":
+				atomic.AddInt32(&podLists, 1)
+				_, _ = w.Write([]byte(`{"apiVersion":"v1","kind":"PodList","metadata":{},"items":[` +
+					`{"apiVersion":"v1","kind":"Pod","metadata":{"namespace":"default","name":"a"}},` +
+					`{"apiVersion":"v1","kind":"Pod","metadata":{"namespace":"default","name":"b"}},` +
+					`{"apiVersion":"v1","kind":"Pod","metadata":{"namespace":"default","name":"c"}}]}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+
+		classification.InitializeManagerWithObjects(&rest.Config{Host: server.URL})
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	getPodConstraint := func(minCount, maxCount *int, names ...string) *classification.ResourceConstraint {
+		constraint := &classification.ResourceConstraint{ResourceNames: names}
+		constraint.Version = "v1"
+		constraint.Kind = "Pod"
+		constraint.Namespace = "default"
+		constraint.MinCount = minCount
+		constraint.MaxCount = maxCount
+		return constraint
+	}
+
+	It("getConstraintKey ignores count thresholds and filter order", func() {
+		one, three := 1, 3
+
+		key, ok := classification.GetConstraintKey(getPodConstraint(&one, nil, "a", "b"))
+		Expect(ok).To(BeTrue())
+		otherKey, ok := classification.GetConstraintKey(getPodConstraint(nil, &three, "b", "a"))
+		Expect(ok).To(BeTrue())
+		Expect(otherKey).To(Equal(key))
+
+		otherKey, ok = classification.GetConstraintKey(getPodConstraint(&one, nil, "a"))
+		Expect(ok).To(BeTrue())
+		Expect(otherKey).ToNot(Equal(key))
+
+		constraint := getPodConstraint(&one, nil)
+		constraint.Namespace = "kube-system"
+		otherKey, ok = classification.GetConstraintKey(constraint)
+		Expect(ok).To(BeTrue())
+		Expect(otherKey).ToNot(Equal(key))
+
+		// Constraints not verifying a count are not memoized
+		constraint = getPodConstraint(nil, nil)
+		constraint.MustNotExist = true
+		_, ok = classification.GetConstraintKey(constraint)
+		Expect(ok).To(BeFalse())
+
+		constraint = getPodConstraint(nil, nil)
+		constraint.Percentage = &classification.PercentageConstraint{}
+		_, ok = classification.GetConstraintKey(constraint)
+		Expect(ok).To(BeFalse())
+	})
+
+	It("evaluateResourceConstraint counts resources once for identical constraints", func() {
+		classification.ApplyOptions(classification.WithConstraintMemoization(true))
+		manager := classification.GetManager()
+		two, three, four := 2, 3, 4
+		classifier := &libsveltosv1alpha1.Classifier{}
+
+		ctx := classification.WithMatchCache(manager)
+		isMatch, err := classification.EvaluateResourceConstraint(manager, ctx, classifier,
+			getPodConstraint(&two, nil), nil)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+
+		// Thresholds are still verified per constraint
+		isMatch, err = classification.EvaluateResourceConstraint(manager, ctx, classifier,
+			getPodConstraint(&four, nil), nil)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeFalse())
+
+		isMatch, err = classification.EvaluateResourceConstraint(manager, ctx, classifier,
+			getPodConstraint(nil, &three), nil)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+		Expect(atomic.LoadInt32(&podLists)).To(Equal(int32(1)))
+
+		// Next cycle lists resources again
+		ctx = classification.WithMatchCache(manager)
+		_, err = classification.EvaluateResourceConstraint(manager, ctx, classifier,
+			getPodConstraint(&two, nil), nil)
+		Expect(err).To(BeNil())
+		Expect(atomic.LoadInt32(&podLists)).To(Equal(int32(2)))
+	})
+
+	It("Classifiers sharing a memoized constraint are attributed the same cost and accesses", func() {
+		classification.Reset()
+		classification.InitializeManagerWithSkip(context.TODO(), klogr.New(),
+			classification.WithCostTracking(&rest.Config{Host: server.URL}), fake.NewClientBuilder().Build(), nil, 10)
+		classification.ApplyOptions(classification.WithConstraintMemoization(true))
+		manager := classification.GetManager()
+		two, three := 2, 3
+
+		ctx := classification.WithMatchCache(manager)
+		firstCtx, first := classification.WithAccessCostTracker(ctx)
+		isMatch, err := classification.EvaluateResourceConstraint(manager, firstCtx,
+			&libsveltosv1alpha1.Classifier{ObjectMeta: metav1.ObjectMeta{Name: randomString()}},
+			getPodConstraint(&two, nil), nil)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+
+		secondCtx, second := classification.WithAccessCostTracker(ctx)
+		isMatch, err = classification.EvaluateResourceConstraint(manager, secondCtx,
+			&libsveltosv1alpha1.Classifier{ObjectMeta: metav1.ObjectMeta{Name: randomString()}},
+			getPodConstraint(nil, &three), nil)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+		Expect(atomic.LoadInt32(&podLists)).To(Equal(int32(1)))
+
+		Expect(classification.GetAccesses(first)).To(ContainElement(classification.AccessRecord{
+			Verb: "list", Resource: "pods", Succeeded: true}))
+		Expect(classification.GetAccesses(second)).To(Equal(classification.GetAccesses(first)))
+		Expect(classification.GetCost(second).Requests).To(BeNumerically(">", 0))
+		Expect(classification.GetCost(second)).To(Equal(classification.GetCost(first)))
+	})
+
+	It("evaluateResourceConstraint lists resources for each constraint when memoization is disabled", func() {
+		manager := classification.GetManager()
+		two := 2
+		classifier := &libsveltosv1alpha1.Classifier{}
+
+		ctx := classification.WithMatchCache(manager)
+		for i := 0; i < 2; i++ {
+			isMatch, err := classification.EvaluateResourceConstraint(manager, ctx, classifier,
+				getPodConstraint(&two, nil), nil)
+			Expect(err).To(BeNil())
+			Expect(isMatch).To(BeTrue())
+		}
+		Expect(atomic.LoadInt32(&podLists)).To(Equal(int32(2)))
+	})
+})
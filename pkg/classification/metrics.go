@@ -0,0 +1,77 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	metricsNamespace = "classifier_agent"
+	classifierLabel  = "classifier"
+	directionLabel   = "direction"
+)
+
+var (
+	apiServerRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "apiserver_requests_total",
+			Help:      "Number of requests sent to the API server while evaluating a Classifier",
+		},
+		[]string{classifierLabel},
+	)
+
+	apiServerBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "apiserver_bytes_total",
+			Help:      "Number of bytes exchanged with the API server while evaluating a Classifier",
+		},
+		[]string{classifierLabel, directionLabel},
+	)
+
+	evaluationCPUSeconds = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "evaluation_cpu_seconds_total",
+			Help:      "CPU time used while evaluating a Classifier",
+		},
+		[]string{classifierLabel},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(apiServerRequests, apiServerBytes, evaluationCPUSeconds)
+}
+
+// recordCostMetrics adds cost to the metrics of a Classifier
+func recordCostMetrics(classifierName string, cost *EvaluationCost) {
+	apiServerRequests.WithLabelValues(classifierName).Add(float64(cost.Requests))
+	apiServerBytes.WithLabelValues(classifierName, "sent").Add(float64(cost.BytesSent))
+	apiServerBytes.WithLabelValues(classifierName, "received").Add(float64(cost.BytesReceived))
+	evaluationCPUSeconds.WithLabelValues(classifierName).Add(cost.CPUTime.Seconds())
+}
+
+// removeCostMetrics removes the metrics of a Classifier
+func removeCostMetrics(classifierName string) {
+	apiServerRequests.DeleteLabelValues(classifierName)
+	apiServerBytes.DeleteLabelValues(classifierName, "sent")
+	apiServerBytes.DeleteLabelValues(classifierName, "received")
+	evaluationCPUSeconds.DeleteLabelValues(classifierName)
+}
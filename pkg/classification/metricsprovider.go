@@ -0,0 +1,269 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/projectsveltos/classifier-agent/internal/utils"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+)
+
+const (
+	// MetricsProviderLabel marks ConfigMaps, in the projectsveltos namespace, where
+	// sidecars publish numeric facts. ConfigMap name is the provider name and each
+	// data key whose value is a number is a metric.
+	MetricsProviderLabel = "classifier.projectsveltos.io/metrics-provider"
+
+	// DefaultMetricsProviderResyncInterval is the default interval at which Classifiers
+	// with MetricConstraints are evaluated again when HTTP metrics providers are set
+	DefaultMetricsProviderResyncInterval = time.Minute
+
+	// maxMetricsResponseSize bounds the response of an HTTP metrics provider
+	maxMetricsResponseSize = 1 << 20
+)
+
+// MetricsProvider publishes numeric facts, for instance the number of tenants,
+// MetricConstraints compare with thresholds
+type MetricsProvider interface {
+	// Name identifies the provider in MetricConstraints
+	Name() string
+
+	// GetMetrics returns the current value of all metrics, by name
+	GetMetrics(ctx context.Context) (map[string]float64, error)
+}
+
+// MetricConstraint compares a metric published by a MetricsProvider with a threshold.
+// A metric which is not published never satisfies the constraint.
+type MetricConstraint struct {
+	// Provider is the name of the MetricsProvider: the name of an HTTP provider
+	// or of a ConfigMap labeled MetricsProviderLabel
+	Provider string `json:"provider"`
+
+	// Name is the metric name
+	Name string `json:"name"`
+
+	// Operation compares metric with Value: GreaterThan, GreaterThanOrEqual,
+	// LessThan or LessThanOrEqual
+	Operation libsveltosv1alpha1.Operation `json:"operation"`
+
+	// Value is the threshold, a number
+	Value string `json:"value"`
+}
+
+// validateMetricConstraint returns the threshold of c, or an error if c is not valid
+func validateMetricConstraint(c *MetricConstraint) (float64, error) {
+	if c.Provider == "" || c.Name == "" {
+		return 0, fmt.Errorf("provider and name are required")
+	}
+	if !isComparisonOperation(c.Operation) {
+		return 0, fmt.Errorf("unsupported operation %q", c.Operation)
+	}
+	threshold, err := strconv.ParseFloat(c.Value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("value %q is not a number", c.Value)
+	}
+	return threshold, nil
+}
+
+// areMetricConstraintsAMatch returns true if all MetricConstraints are satisfied
+func (m *manager) areMetricConstraintsAMatch(ctx context.Context,
+	classifier *libsveltosv1alpha1.Classifier) (bool, error) {
+
+	extension, err := getClassifierExtension(classifier)
+	if err != nil {
+		return false, err
+	}
+
+	if len(extension.MetricConstraints) == 0 {
+		return true, nil
+	}
+
+	// Each provider is queried once per evaluation
+	metrics := make(map[string]map[string]float64)
+	for i := range extension.MetricConstraints {
+		c := &extension.MetricConstraints[i]
+		threshold, err := validateMetricConstraint(c)
+		if err != nil {
+			return false, newInvalidClassifierError(fmt.Errorf("metric constraint %d: %w", i, err))
+		}
+
+		providerMetrics, ok := metrics[c.Provider]
+		if !ok {
+			providerMetrics, err = m.getProviderMetrics(ctx, c.Provider)
+			if err != nil {
+				return false, errors.Wrap(err, fmt.Sprintf("metric constraint %d", i))
+			}
+			metrics[c.Provider] = providerMetrics
+		}
+
+		value, ok := providerMetrics[c.Name]
+		if !ok {
+			addEvaluationNote(ctx, fmt.Sprintf("metric constraint %d: %s/%s is not published", i, c.Provider, c.Name))
+			return false, nil
+		}
+		addEvaluationNote(ctx, fmt.Sprintf("metric constraint %d: %s/%s is %s", i, c.Provider, c.Name,
+			strconv.FormatFloat(value, 'g', -1, 64)))
+		if !compareFloat(value, c.Operation, threshold) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// getProviderMetrics returns the metrics of the named provider. HTTP providers take
+// precedence over ConfigMaps. No metrics are returned when no provider has that name.
+func (m *manager) getProviderMetrics(ctx context.Context, name string) (map[string]float64, error) {
+	for i := range m.metricsProviders {
+		if m.metricsProviders[i].Name() == name {
+			return m.metricsProviders[i].GetMetrics(ctx)
+		}
+	}
+
+	provider := &configMapMetricsProvider{Client: m.Client, name: name, log: m.log}
+	return provider.GetMetrics(ctx)
+}
+
+// hasMetricConstraints returns true if classifier has MetricConstraints
+func hasMetricConstraints(classifier *libsveltosv1alpha1.Classifier) bool {
+	extension, err := getClassifierExtension(classifier)
+	if err != nil {
+		return false
+	}
+	return len(extension.MetricConstraints) != 0
+}
+
+// resyncMetricClassifiers periodically queues Classifiers with MetricConstraints, since
+// metrics served by HTTP providers change without any watched resource changing
+func (m *manager) resyncMetricClassifiers(ctx context.Context) {
+	m.resyncClassifiers(ctx, m.metricsResyncInterval, hasMetricConstraints)
+}
+
+// httpMetricsProvider fetches metrics from an HTTP endpoint, typically served by a
+// sidecar, returning a JSON object whose values are numbers, for instance
+// {"tenants": 42, "queue_depth": 3.5}
+type httpMetricsProvider struct {
+	name       string
+	url        string
+	httpClient *http.Client
+}
+
+// NewHTTPMetricsProvider returns a MetricsProvider fetching metrics from url, which
+// must respond with a JSON object whose values are numbers. timeout bounds each request.
+func NewHTTPMetricsProvider(name, url string, timeout time.Duration) MetricsProvider {
+	return &httpMetricsProvider{name: name, url: url, httpClient: &http.Client{Timeout: timeout}}
+}
+
+func (p *httpMetricsProvider) Name() string {
+	return p.name
+}
+
+func (p *httpMetricsProvider) GetMetrics(ctx context.Context) (map[string]float64, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", "application/json")
+
+	response, err := p.httpClient.Do(request)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to fetch metrics of provider %s", p.name))
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("metrics provider %s returned %d", p.name, response.StatusCode)
+	}
+
+	metrics := make(map[string]float64)
+	if err := json.NewDecoder(io.LimitReader(response.Body, maxMetricsResponseSize)).Decode(&metrics); err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("metrics provider %s returned invalid metrics", p.name))
+	}
+	return metrics, nil
+}
+
+// configMapMetricsProvider reads metrics from the ConfigMap, in the projectsveltos
+// namespace, named after the provider and labeled MetricsProviderLabel. Data values
+// which are not numbers are ignored.
+type configMapMetricsProvider struct {
+	client.Client
+	name string
+	log  logr.Logger
+}
+
+func (p *configMapMetricsProvider) Name() string {
+	return p.name
+}
+
+func (p *configMapMetricsProvider) GetMetrics(ctx context.Context) (map[string]float64, error) {
+	metrics := make(map[string]float64)
+
+	configMap := &corev1.ConfigMap{}
+	err := p.Get(ctx, client.ObjectKey{Namespace: utils.ReportNamespace, Name: p.name}, configMap)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return metrics, nil
+		}
+		return nil, err
+	}
+	// Only ConfigMaps sidecars explicitly publish metrics in are considered
+	if _, ok := configMap.Labels[MetricsProviderLabel]; !ok {
+		return metrics, nil
+	}
+
+	for key, value := range configMap.Data {
+		number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			p.log.V(logs.LogDebug).Info(fmt.Sprintf("metrics provider %s: %s is not a number", p.name, key))
+			continue
+		}
+		metrics[key] = number
+	}
+	return metrics, nil
+}
+
+// ParseMetricsProviders parses name=url pairs into HTTP MetricsProviders
+func ParseMetricsProviders(values []string, timeout time.Duration) ([]MetricsProvider, error) {
+	providers := make([]MetricsProvider, 0, len(values))
+	names := make(map[string]bool, len(values))
+	for _, value := range values {
+		name, url, found := strings.Cut(value, "=")
+		if !found || name == "" || url == "" {
+			return nil, fmt.Errorf("metrics provider %q is not name=url", value)
+		}
+		if names[name] {
+			return nil, fmt.Errorf("duplicate metrics provider %q", name)
+		}
+		names[name] = true
+		providers = append(providers, NewHTTPMetricsProvider(name, url, timeout))
+	}
+	return providers, nil
+}
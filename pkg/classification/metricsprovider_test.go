@@ -0,0 +1,128 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/projectsveltos/classifier-agent/internal/utils"
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: metrics providers", func() {
+	var server *httptest.Server
+
+	BeforeEach(func() {
+		classification.Reset()
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"tenants": 42, "queue_depth": 3.5}`))
+		}))
+
+		published := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: utils.ReportNamespace,
+				Name:      "billing",
+				Labels:    map[string]string{classification.MetricsProviderLabel: "true"},
+			},
+			Data: map[string]string{"customers": "120", "plan": "gold"},
+		}
+		// Not labeled: not a metrics provider
+		unlabeled := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: utils.ReportNamespace, Name: "other"},
+			Data:       map[string]string{"customers": "5"},
+		}
+
+		classification.InitializeManagerWithObjects(nil, published, unlabeled)
+
+		providers, err := classification.ParseMetricsProviders([]string{"sidecar=" + server.URL}, time.Second)
+		Expect(err).ToNot(HaveOccurred())
+		classification.ApplyOptions(classification.WithMetricsProviders(providers, 0))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	getClassifier := func(constraints string) *libsveltosv1alpha1.Classifier {
+		classifier := getClassifierWithKubernetesConstraints(version25, libsveltosv1alpha1.ComparisonEqual)
+		classifier.Annotations = map[string]string{
+			classification.ClassifierExtensionAnnotation: "metricConstraints:\n" + constraints,
+		}
+		return classifier
+	}
+
+	It("areMetricConstraintsAMatch compares HTTP and ConfigMap metrics with thresholds", func() {
+		manager := classification.GetManager()
+
+		isMatch, notes, err := classification.AreMetricConstraintsAMatch(manager, getClassifier(`
+- provider: sidecar
+  name: tenants
+  operation: GreaterThanOrEqual
+  value: "40"
+- provider: billing
+  name: customers
+  operation: GreaterThan
+  value: "100"`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(isMatch).To(BeTrue())
+		Expect(notes).To(ContainElement(ContainSubstring("sidecar/tenants is 42")))
+
+		isMatch, _, err = classification.AreMetricConstraintsAMatch(manager, getClassifier(`
+- provider: sidecar
+  name: queue_depth
+  operation: LessThan
+  value: "1"`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(isMatch).To(BeFalse())
+	})
+
+	It("areMetricConstraintsAMatch is not a match when metric is not published", func() {
+		manager := classification.GetManager()
+
+		for _, constraint := range []string{
+			// Not a number
+			"- provider: billing\n  name: plan\n  operation: GreaterThan\n  value: \"0\"",
+			// ConfigMap is not labeled
+			"- provider: other\n  name: customers\n  operation: GreaterThan\n  value: \"0\"",
+			"- provider: missing\n  name: customers\n  operation: GreaterThan\n  value: \"0\"",
+		} {
+			isMatch, notes, err := classification.AreMetricConstraintsAMatch(manager, getClassifier(constraint))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(isMatch).To(BeFalse())
+			Expect(notes).To(ConsistOf(ContainSubstring("is not published")))
+		}
+	})
+
+	It("ParseMetricsProviders rejects invalid values", func() {
+		_, err := classification.ParseMetricsProviders([]string{"sidecar"}, time.Second)
+		Expect(err).To(HaveOccurred())
+
+		_, err = classification.ParseMetricsProviders([]string{"a=http://x", "a=http://y"}, time.Second)
+		Expect(err).To(HaveOccurred())
+	})
+})
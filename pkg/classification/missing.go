@@ -0,0 +1,128 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// MissingFieldPolicy defines how a FieldFilter is evaluated on a resource
+// where its field does not exist
+type MissingFieldPolicy string
+
+const (
+	// MissingFieldTreatAsNoMatch makes the filter not satisfied
+	MissingFieldTreatAsNoMatch = MissingFieldPolicy("TreatAsNoMatch")
+
+	// MissingFieldTreatAsMatch makes the filter satisfied
+	MissingFieldTreatAsMatch = MissingFieldPolicy("TreatAsMatch")
+
+	// MissingFieldSkip ignores the resource altogether: it is neither counted
+	// as matching nor, for percentage constraints, as part of the total
+	MissingFieldSkip = MissingFieldPolicy("Skip")
+)
+
+// describe returns how a missing field is treated, as reported in explanations
+func (p MissingFieldPolicy) describe() string {
+	switch p {
+	case MissingFieldTreatAsMatch:
+		return "treated as match"
+	case MissingFieldSkip:
+		return "skipped"
+	default:
+		return "treated as no match"
+	}
+}
+
+// applyMissingFieldPolicies sets, on field filter matchers, the policies
+// configured per field path
+func applyMissingFieldPolicies(matchers []fieldFilterMatcher, policies map[string]MissingFieldPolicy) error {
+	for field, policy := range policies {
+		switch policy {
+		case MissingFieldTreatAsNoMatch, MissingFieldTreatAsMatch, MissingFieldSkip:
+		default:
+			return fmt.Errorf("field %q: unknown missing field policy %q", field, policy)
+		}
+
+		found := false
+		for i := range matchers {
+			if matchers[i].filter.Field == field {
+				matchers[i].missing = policy
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("missing field policy set for field %q which has no field filter", field)
+		}
+	}
+	return nil
+}
+
+// addMissingFieldNotes adds to the evaluation notes in ctx how many resources
+// were missing fields with a missing field policy
+func (q *resourceQuery) addMissingFieldNotes(ctx context.Context) {
+	for i := range q.fieldMatchers {
+		matcher := &q.fieldMatchers[i]
+		if matcher.missing == "" || matcher.missingCount == 0 {
+			continue
+		}
+		addEvaluationNote(ctx, fmt.Sprintf("%s: %d resources missing field %q %s",
+			q.gvk.Kind, matcher.missingCount, matcher.filter.Field, matcher.missing.describe()))
+	}
+}
+
+// evaluationNotes collects notes about an evaluation step, which are
+// reported in the ClassifierReport explanation
+type evaluationNotes struct {
+	mu    sync.Mutex
+	notes []string
+}
+
+type evaluationNotesKey struct{}
+
+// withEvaluationNotes returns a context collecting notes in the returned evaluationNotes
+func withEvaluationNotes(ctx context.Context) (context.Context, *evaluationNotes) {
+	notes := &evaluationNotes{}
+	return context.WithValue(ctx, evaluationNotesKey{}, notes), notes
+}
+
+// addEvaluationNote adds note to the evaluationNotes in ctx, if any
+func addEvaluationNote(ctx context.Context, note string) {
+	notes, ok := ctx.Value(evaluationNotesKey{}).(*evaluationNotes)
+	if !ok {
+		return
+	}
+	notes.mu.Lock()
+	defer notes.mu.Unlock()
+	notes.notes = append(notes.notes, note)
+}
+
+// get returns the collected notes, sorted
+func (n *evaluationNotes) get() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if len(n.notes) == 0 {
+		return nil
+	}
+	notes := make([]string, len(n.notes))
+	copy(notes, n.notes)
+	sort.Strings(notes)
+	return notes
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+// maxReportedResources is the maximum number of resources violating a
+// MustNotExist constraint listed in the evaluation notes
+const maxReportedResources = 5
+
+// validateMustNotExist verifies a MustNotExist constraint sets no other bound
+func validateMustNotExist(constraint *ResourceConstraint) error {
+	if !constraint.MustNotExist {
+		return nil
+	}
+	if constraint.MinCount != nil || constraint.MaxCount != nil || constraint.Percentage != nil ||
+		constraint.Trend != nil || constraint.Cardinality != nil {
+
+		return fmt.Errorf("mustNotExist cannot be combined with minCount, maxCount, percentage, trend or cardinality")
+	}
+	return nil
+}
+
+// evaluateMustNotExistConstraint returns true if no resource matches constraint.
+// A constraint whose GVK is not served by the cluster is satisfied. Listing stops
+// at the first page with a matching resource.
+func (m *manager) evaluateMustNotExistConstraint(ctx context.Context,
+	classifier *libsveltosv1alpha1.Classifier, constraint *ResourceConstraint,
+	snapshot *evaluationSnapshot) (bool, error) {
+
+	if err := validateMustNotExist(constraint); err != nil {
+		return false, newInvalidClassifierError(err)
+	}
+
+	count, _, served, err := m.countResources(ctx, classifier, constraint, snapshot,
+		func(count, _ int) bool { return count > 0 })
+	if err != nil {
+		return false, err
+	}
+	return !served || count == 0, nil
+}
+
+// recordFound records, for MustNotExist constraints, the first resources
+// matching the constraint
+func (q *resourceQuery) recordFound(items []unstructured.Unstructured) {
+	if !q.mustNotExist {
+		return
+	}
+	for i := range items {
+		if len(q.found) == maxReportedResources {
+			return
+		}
+		name := items[i].GetName()
+		if items[i].GetNamespace() != "" {
+			name = fmt.Sprintf("%s/%s", items[i].GetNamespace(), name)
+		}
+		q.found = append(q.found, name)
+	}
+}
+
+// addMustNotExistNotes adds to the evaluation notes in ctx the resources found
+// for a MustNotExist constraint
+func (q *resourceQuery) addMustNotExistNotes(ctx context.Context) {
+	if len(q.found) == 0 {
+		return
+	}
+	addEvaluationNote(ctx, fmt.Sprintf("%s: must not exist, found %s", q.gvk.Kind, strings.Join(q.found, ", ")))
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/client-go/rest"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: mustNotExist", func() {
+	var server *httptest.Server
+
+	BeforeEach(func() {
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			switch r.URL.Path {
+			case "/api":
+				_, _ = w.Write([]byte(`{"kind":"APIVersions","versions":[]}`))
+			case "/apis":
+				_, _ = w.Write([]byte(`{"kind":"APIGroupList","apiVersion":"v1","groups":[{"name":"example.com",` +
+					`"versions":[{"groupVersion":"example.com/v1","version":"v1"}],` +
+					`"preferredVersion":{"groupVersion":"example.com/v1","version":"v1"}}]}`))
+			case "/apis/example.com/v1":
+				_, _ = w.Write([]byte(`{"kind":"APIResourceList","apiVersion":"v1","groupVersion":"example.com/v1",` +
+					`"resources":[{"name":"widgets","singularName":"widget","namespaced":true,"kind":"Widget",` +
+					`"verbs":["get","list"]}]}`))
+			case "/apis/example.com/v1/widgets":
+				_, _ = w.Write([]byte(`{"apiVersion":"example.com/v1","kind":"WidgetList","metadata":{},"items":[` +
+					`{"apiVersion":"example.com/v1","kind":"Widget","metadata":{"name":"nginx","namespace":"default"}},` +
+					`{"apiVersion":"example.com/v1","kind":"Widget","metadata":{"name":"envoy","namespace":"mesh"}}]}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+
+		classification.InitializeManagerWithObjects(&rest.Config{Host: server.URL})
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("mustNotExist is not satisfied when resources exist, which are reported", func() {
+		constraint := &classification.ResourceConstraint{
+			DeployedResourceConstraint: libsveltosv1alpha1.DeployedResourceConstraint{
+				Group: "example.com", Version: "v1", Kind: "Widget",
+			},
+			MustNotExist: true,
+		}
+		isMatch, notes, err := classification.EvaluateMustNotExistConstraint(classification.GetManager(), constraint)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeFalse())
+		Expect(notes).To(Equal([]string{"Widget: must not exist, found default/nginx, mesh/envoy"}))
+
+		constraint.AnnotationFilters = []libsveltosv1alpha1.LabelFilter{
+			{Key: "tier", Operation: classification.OperationExists},
+		}
+		isMatch, notes, err = classification.EvaluateMustNotExistConstraint(classification.GetManager(), constraint)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+		Expect(notes).To(BeEmpty())
+	})
+
+	It("mustNotExist is satisfied when group/version/kind is not served", func() {
+		constraint := &classification.ResourceConstraint{
+			DeployedResourceConstraint: libsveltosv1alpha1.DeployedResourceConstraint{
+				Group: "example.com", Version: "v1", Kind: "Gadget",
+			},
+			MustNotExist: true,
+		}
+		isMatch, _, err := classification.EvaluateMustNotExistConstraint(classification.GetManager(), constraint)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+	})
+
+	It("mustNotExist cannot be combined with other bounds", func() {
+		maxCount := 0
+		constraint := &classification.ResourceConstraint{
+			DeployedResourceConstraint: libsveltosv1alpha1.DeployedResourceConstraint{
+				Group: "example.com", Version: "v1", Kind: "Widget", MaxCount: &maxCount,
+			},
+			MustNotExist: true,
+		}
+		_, _, err := classification.EvaluateMustNotExistConstraint(classification.GetManager(), constraint)
+		Expect(err).ToNot(BeNil())
+		Expect(classification.IsInvalidClassifierError(err)).To(BeTrue())
+	})
+})
@@ -0,0 +1,116 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// validateResourceNames returns an error if names cannot be fetched
+func validateResourceNames(names []string, namespace string, namespaced bool) error {
+	if len(names) == 0 {
+		return nil
+	}
+	if namespaced && namespace == "" {
+		return fmt.Errorf("resourceNames require namespace to be set for namespaced resources")
+	}
+	for i := range names {
+		if names[i] == "" {
+			return fmt.Errorf("resourceNames contains an empty name")
+		}
+	}
+	return nil
+}
+
+// listQueryResources returns the resources for query. When query targets resources
+// by name, each one is fetched with a Get. Otherwise resources are listed.
+// Transient errors are retried.
+func (m *manager) listQueryResources(ctx context.Context, query *resourceQuery) (*unstructured.UnstructuredList, error) {
+	if len(query.names) == 0 {
+		return m.listResources(ctx, &query.gvk, query.resource, &query.options)
+	}
+
+	var list *unstructured.UnstructuredList
+	err := m.retryTransient(ctx, fmt.Sprintf("get %s", query.gvk.String()), func() error {
+		var err error
+		list, err = m.getNamedResources(ctx, query)
+		return err
+	})
+	return list, err
+}
+
+// getNamedResources gets the resources query targets by name. Resources which do
+// not exist are ignored. The API server does not apply selectors to a Get, so query
+// label and field selectors are evaluated here.
+func (m *manager) getNamedResources(ctx context.Context, query *resourceQuery) (*unstructured.UnstructuredList, error) {
+	labelSelector, err := labels.Parse(query.options.LabelSelector)
+	if err != nil {
+		return nil, err
+	}
+	fieldSelector, err := fields.ParseSelector(query.options.FieldSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := dynamic.NewForConfig(m.config)
+	if err != nil {
+		return nil, err
+	}
+	namespaceableClient := d.Resource(schema.GroupVersionResource{
+		Group: query.gvk.Group, Version: query.gvk.Version, Resource: query.resource,
+	})
+	var resourceClient dynamic.ResourceInterface = namespaceableClient
+	if query.namespace != "" {
+		resourceClient = namespaceableClient.Namespace(query.namespace)
+	}
+
+	list := &unstructured.UnstructuredList{}
+	seen := make(map[string]bool, len(query.names))
+	for _, name := range query.names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		resource, err := resourceClient.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+
+		resourceFields := fields.Set{
+			"metadata.name":      resource.GetName(),
+			"metadata.namespace": resource.GetNamespace(),
+		}
+		if !labelSelector.Matches(labels.Set(resource.GetLabels())) || !fieldSelector.Matches(resourceFields) {
+			continue
+		}
+		list.Items = append(list.Items, *resource)
+	}
+	return list, nil
+}
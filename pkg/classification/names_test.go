@@ -0,0 +1,163 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/client-go/rest"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: resource names", func() {
+	var server *httptest.Server
+	var mu *sync.Mutex
+	var requests []string
+
+	BeforeEach(func() {
+		mu = &sync.Mutex{}
+		requests = nil
+
+		widgets := map[string]string{"nginx": "web", "envoy": "proxy"}
+		const widgetsPath = "/apis/example.com/v1/namespaces/default/widgets"
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			switch {
+			case r.URL.Path == "/api":
+				_, _ = w.Write([]byte(`{"kind":"APIVersions","versions":[]}`))
+			case r.URL.Path == "/apis":
+				_, _ = w.Write([]byte(`{"kind":"APIGroupList","apiVersion":"v1","groups":[{"name":"example.com",` +
+					`"versions":[{"groupVersion":"example.com/v1","version":"v1"}],` +
+					`"preferredVersion":{"groupVersion":"example.com/v1","version":"v1"}}]}`))
+			case r.URL.Path == "/apis/example.com/v1":
+				_, _ = w.Write([]byte(`{"kind":"APIResourceList","apiVersion":"v1","groupVersion":"example.com/v1",` +
+					`"resources":[{"name":"widgets","singularName":"widget","namespaced":true,"kind":"Widget",` +
+					`"verbs":["get","list"]}]}`))
+			case strings.HasPrefix(r.URL.Path, widgetsPath):
+				mu.Lock()
+				requests = append(requests, r.URL.Path)
+				mu.Unlock()
+
+				name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, widgetsPath), "/")
+				app, ok := widgets[name]
+				if name == "" || !ok {
+					w.WriteHeader(http.StatusNotFound)
+					_, _ = w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Failure",` +
+						`"reason":"NotFound","code":404}`))
+					return
+				}
+				_, _ = w.Write([]byte(fmt.Sprintf(`{"apiVersion":"example.com/v1","kind":"Widget",`+
+					`"metadata":{"name":%q,"namespace":"default","labels":{"app":%q}}}`, name, app)))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+
+		classification.InitializeManagerWithObjects(&rest.Config{Host: server.URL})
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("resources targeted by name are fetched with a Get", func() {
+		manager := classification.GetManager()
+
+		minCount := 2
+		constraint := &classification.ResourceConstraint{
+			DeployedResourceConstraint: libsveltosv1alpha1.DeployedResourceConstraint{
+				Group: "example.com", Version: "v1", Kind: "Widget", Namespace: "default", MinCount: &minCount,
+			},
+			ResourceNames: []string{"nginx", "envoy", "nginx"},
+		}
+		isMatch, err := classification.IsResourceConstraintAMatch(manager, context.TODO(), nil, constraint)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+
+		// Missing resources are not counted
+		constraint.ResourceNames = []string{"nginx", "haproxy"}
+		isMatch, err = classification.IsResourceConstraintAMatch(manager, context.TODO(), nil, constraint)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeFalse())
+
+		mu.Lock()
+		defer mu.Unlock()
+		// Each name is fetched once per evaluation and resources are never listed
+		Expect(requests).To(Equal([]string{
+			"/apis/example.com/v1/namespaces/default/widgets/nginx",
+			"/apis/example.com/v1/namespaces/default/widgets/envoy",
+			"/apis/example.com/v1/namespaces/default/widgets/nginx",
+			"/apis/example.com/v1/namespaces/default/widgets/haproxy",
+		}))
+	})
+
+	It("filters are applied to resources targeted by name", func() {
+		manager := classification.GetManager()
+
+		minCount := 1
+		constraint := &classification.ResourceConstraint{
+			DeployedResourceConstraint: libsveltosv1alpha1.DeployedResourceConstraint{
+				Group: "example.com", Version: "v1", Kind: "Widget", Namespace: "default", MinCount: &minCount,
+				LabelFilters: []libsveltosv1alpha1.LabelFilter{
+					{Key: "app", Operation: libsveltosv1alpha1.OperationEqual, Value: "web"},
+				},
+				FieldFilters: []libsveltosv1alpha1.FieldFilter{
+					{Field: "metadata.name", Operation: libsveltosv1alpha1.OperationEqual, Value: "envoy"},
+				},
+			},
+			ResourceNames: []string{"nginx", "envoy"},
+		}
+		isMatch, err := classification.IsResourceConstraintAMatch(manager, context.TODO(), nil, constraint)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeFalse())
+
+		constraint.FieldFilters = nil
+		isMatch, err = classification.IsResourceConstraintAMatch(manager, context.TODO(), nil, constraint)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+
+		constraint.ExcludedNamespaces = []string{"default"}
+		isMatch, err = classification.IsResourceConstraintAMatch(manager, context.TODO(), nil, constraint)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeFalse())
+	})
+
+	It("resourceNames on namespaced resources require a namespace", func() {
+		manager := classification.GetManager()
+
+		constraint := &classification.ResourceConstraint{
+			DeployedResourceConstraint: libsveltosv1alpha1.DeployedResourceConstraint{
+				Group: "example.com", Version: "v1", Kind: "Widget",
+			},
+			ResourceNames: []string{"nginx"},
+		}
+		_, err := classification.IsResourceConstraintAMatch(manager, context.TODO(), nil, constraint)
+		Expect(err).ToNot(BeNil())
+		Expect(classification.IsInvalidClassifierError(err)).To(BeTrue())
+	})
+})
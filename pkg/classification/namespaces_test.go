@@ -0,0 +1,133 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/client-go/rest"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: excluded namespaces", func() {
+	var server *httptest.Server
+	var mu *sync.Mutex
+	var queries map[string][]string
+
+	BeforeEach(func() {
+		mu = &sync.Mutex{}
+		queries = make(map[string][]string)
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			switch r.URL.Path {
+			case "/api":
+				_, _ = w.Write([]byte(`{"kind":"APIVersions","versions":[]}`))
+			case "/apis":
+				_, _ = w.Write([]byte(`{"kind":"APIGroupList","apiVersion":"v1","groups":[{"name":"example.com",` +
+					`"versions":[{"groupVersion":"example.com/v1","version":"v1"}],` +
+					`"preferredVersion":{"groupVersion":"example.com/v1","version":"v1"}}]}`))
+			case "/apis/example.com/v1":
+				_, _ = w.Write([]byte(`{"kind":"APIResourceList","apiVersion":"v1","groupVersion":"example.com/v1",` +
+					`"resources":[{"name":"widgets","singularName":"widget","namespaced":true,"kind":"Widget",` +
+					`"verbs":["list"]},{"name":"gizmos","singularName":"gizmo","namespaced":false,"kind":"Gizmo",` +
+					`"verbs":["list"]}]}`))
+			case "/apis/example.com/v1/widgets", "/apis/example.com/v1/gizmos":
+				mu.Lock()
+				queries[r.URL.Path] = append(queries[r.URL.Path], r.URL.Query().Get("fieldSelector"))
+				mu.Unlock()
+				_, _ = w.Write([]byte(`{"kind":"List","apiVersion":"example.com/v1",` +
+					`"metadata":{"resourceVersion":"1"},"items":[]}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+
+		classification.InitializeManagerWithObjects(&rest.Config{Host: server.URL})
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("resources in excluded namespaces are filtered out by the API server", func() {
+		manager := classification.GetManager()
+
+		maxCount := 0
+		constraint := &classification.ResourceConstraint{
+			DeployedResourceConstraint: libsveltosv1alpha1.DeployedResourceConstraint{
+				Group: "example.com", Version: "v1", Kind: "Widget", MaxCount: &maxCount,
+			},
+			ExcludedNamespaces: []string{"kube-system", "kube-public"},
+		}
+		isMatch, err := classification.IsResourceConstraintAMatch(manager, context.TODO(), nil, constraint)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+
+		constraint.Namespace = "default"
+		_, err = classification.IsResourceConstraintAMatch(manager, context.TODO(), nil, constraint)
+		Expect(err).To(BeNil())
+
+		mu.Lock()
+		defer mu.Unlock()
+		Expect(queries["/apis/example.com/v1/widgets"]).To(ConsistOf(
+			"metadata.namespace!=kube-system,metadata.namespace!=kube-public",
+			"metadata.namespace=default,metadata.namespace!=kube-system,metadata.namespace!=kube-public",
+		))
+	})
+
+	It("excluded namespaces are ignored for cluster-scoped resources", func() {
+		manager := classification.GetManager()
+
+		maxCount := 0
+		constraint := &classification.ResourceConstraint{
+			DeployedResourceConstraint: libsveltosv1alpha1.DeployedResourceConstraint{
+				Group: "example.com", Version: "v1", Kind: "Gizmo", MaxCount: &maxCount,
+			},
+			ExcludedNamespaces: []string{"kube-system"},
+		}
+		isMatch, err := classification.IsResourceConstraintAMatch(manager, context.TODO(), nil, constraint)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+
+		mu.Lock()
+		defer mu.Unlock()
+		Expect(queries["/apis/example.com/v1/gizmos"]).To(ConsistOf(""))
+	})
+
+	It("an empty excluded namespace makes the Classifier invalid", func() {
+		manager := classification.GetManager()
+
+		constraint := &classification.ResourceConstraint{
+			DeployedResourceConstraint: libsveltosv1alpha1.DeployedResourceConstraint{
+				Group: "example.com", Version: "v1", Kind: "Widget",
+			},
+			ExcludedNamespaces: []string{""},
+		}
+		_, err := classification.IsResourceConstraintAMatch(manager, context.TODO(), nil, constraint)
+		Expect(err).ToNot(BeNil())
+		Expect(classification.IsInvalidClassifierError(err)).To(BeTrue())
+	})
+})
@@ -0,0 +1,160 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+)
+
+// DefaultNodeLabelPrefix is the prefix of the node labels classification is
+// mirrored to, when no prefix is configured
+const DefaultNodeLabelPrefix = "classifier.projectsveltos.io"
+
+// ValidateNodeLabelPrefix returns an error if prefix cannot be used as label prefix
+func ValidateNodeLabelPrefix(prefix string) error {
+	if errs := validation.IsDNS1123Subdomain(prefix); len(errs) > 0 {
+		return fmt.Errorf("invalid node label prefix %q: %s", prefix, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// getNodeLabelKey returns the key of the node label mirroring a Classifier result.
+// Returns false if Classifier name cannot be used as label name.
+func (m *manager) getNodeLabelKey(classifierName string) (string, bool) {
+	if len(validation.IsQualifiedName(classifierName)) > 0 {
+		return "", false
+	}
+	return fmt.Sprintf("%s/%s", m.nodeLabelPrefix, classifierName), true
+}
+
+// isNodeLabelClassifier returns true if the result of a Classifier is mirrored to node labels
+func (m *manager) isNodeLabelClassifier(classifierName string) bool {
+	if len(m.nodeLabelClassifiers) == 0 {
+		return true
+	}
+	for i := range m.nodeLabelClassifiers {
+		if m.nodeLabelClassifiers[i] == classifierName {
+			return true
+		}
+	}
+	return false
+}
+
+// getNodeLabels returns the node labels mirroring the latest evaluation results.
+// Labels of Classifiers whose last evaluation failed are returned in unknown:
+// those are left untouched.
+func (m *manager) getNodeLabels() (desired map[string]string, unknown map[string]bool) {
+	m.historyMu.RLock()
+	defer m.historyMu.RUnlock()
+
+	desired = make(map[string]string)
+	unknown = make(map[string]bool)
+	for classifierName, result := range m.latest {
+		if !m.isNodeLabelClassifier(classifierName) {
+			continue
+		}
+		key, ok := m.getNodeLabelKey(classifierName)
+		if !ok {
+			m.log.V(logs.LogDebug).Info(fmt.Sprintf("classifier %s name cannot be used as node label",
+				classifierName))
+			continue
+		}
+		if result.Error != "" {
+			unknown[key] = true
+			continue
+		}
+		desired[key] = strconv.FormatBool(result.Match)
+	}
+	return desired, unknown
+}
+
+// syncNodeLabels sets, on node, labels mirroring the latest evaluation results.
+// Labels with the node label prefix of Classifiers not evaluated anymore are removed.
+func (m *manager) syncNodeLabels(ctx context.Context, node *corev1.Node) error {
+	desired, unknown := m.getNodeLabels()
+
+	labels := node.GetLabels()
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+
+	changed := false
+	prefix := m.nodeLabelPrefix + "/"
+	for key := range labels {
+		if !strings.HasPrefix(key, prefix) || unknown[key] {
+			continue
+		}
+		if _, ok := desired[key]; !ok {
+			delete(labels, key)
+			changed = true
+		}
+	}
+	for key, value := range desired {
+		if labels[key] != value {
+			labels[key] = value
+			changed = true
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+	node.SetLabels(labels)
+	return m.Update(ctx, node)
+}
+
+// syncAllNodeLabels mirrors the latest evaluation results to all Nodes
+func (m *manager) syncAllNodeLabels(ctx context.Context) error {
+	nodes := &corev1.NodeList{}
+	if err := m.List(ctx, nodes); err != nil {
+		return err
+	}
+	for i := range nodes.Items {
+		if err := m.syncNodeLabels(ctx, &nodes.Items[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SyncNodeLabels mirrors the latest evaluation results to a Node, when node
+// labels are enabled. Meant to be invoked when a Node changes so new Nodes
+// get labels as well.
+func (m *manager) SyncNodeLabels(ctx context.Context, nodeName string) error {
+	if m.nodeLabelPrefix == "" {
+		return nil
+	}
+
+	node := &corev1.Node{}
+	if err := m.Get(ctx, types.NamespacedName{Name: nodeName}, node); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	return m.syncNodeLabels(ctx, node)
+}
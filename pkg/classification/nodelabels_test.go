@@ -0,0 +1,102 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+)
+
+var _ = Describe("Manager: node labels", func() {
+	var node *corev1.Node
+
+	BeforeEach(func() {
+		classification.Reset()
+
+		node = &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   randomString(),
+				Labels: map[string]string{"kubernetes.io/os": "linux"},
+			},
+		}
+	})
+
+	getNodeLabels := func(name string) map[string]string {
+		currentNode := &corev1.Node{}
+		manager := classification.GetManager()
+		Expect(manager.Get(context.TODO(), types.NamespacedName{Name: name}, currentNode)).To(Succeed())
+		return currentNode.Labels
+	}
+
+	It("syncAllNodeLabels mirrors evaluation results to node labels", func() {
+		classification.InitializeManagerWithObjects(nil, node)
+		manager := classification.GetManager()
+		classification.ApplyOptions(classification.WithNodeLabels(classification.DefaultNodeLabelPrefix, nil))
+
+		classification.RecordEvaluation(manager, "gpu", time.Now(), true, nil, nil)
+		classification.RecordEvaluation(manager, "edge", time.Now(), false, nil, nil)
+		Expect(classification.SyncAllNodeLabels(manager, context.TODO())).To(Succeed())
+
+		labels := getNodeLabels(node.Name)
+		Expect(labels).To(HaveKeyWithValue("kubernetes.io/os", "linux"))
+		Expect(labels).To(HaveKeyWithValue(classification.DefaultNodeLabelPrefix+"/gpu", "true"))
+		Expect(labels).To(HaveKeyWithValue(classification.DefaultNodeLabelPrefix+"/edge", "false"))
+
+		// Failed evaluation leaves label untouched, removed Classifier label is removed
+		classification.RecordEvaluation(manager, "gpu", time.Now(), false, errors.New("failed"), nil)
+		classification.RemoveEvaluationHistory(manager, "edge")
+		Expect(classification.SyncAllNodeLabels(manager, context.TODO())).To(Succeed())
+
+		labels = getNodeLabels(node.Name)
+		Expect(labels).To(HaveKeyWithValue("kubernetes.io/os", "linux"))
+		Expect(labels).To(HaveKeyWithValue(classification.DefaultNodeLabelPrefix+"/gpu", "true"))
+		Expect(labels).ToNot(HaveKey(classification.DefaultNodeLabelPrefix + "/edge"))
+	})
+
+	It("SyncNodeLabels only mirrors selected classifiers", func() {
+		classification.InitializeManagerWithObjects(nil, node)
+		manager := classification.GetManager()
+
+		classification.RecordEvaluation(manager, "gpu", time.Now(), true, nil, nil)
+		classification.RecordEvaluation(manager, "edge", time.Now(), true, nil, nil)
+
+		// Node labels are disabled by default
+		Expect(manager.SyncNodeLabels(context.TODO(), node.Name)).To(Succeed())
+		Expect(getNodeLabels(node.Name)).To(HaveLen(1))
+
+		classification.ApplyOptions(classification.WithNodeLabels("example.com", []string{"gpu"}))
+		Expect(manager.SyncNodeLabels(context.TODO(), node.Name)).To(Succeed())
+		labels := getNodeLabels(node.Name)
+		Expect(labels).To(HaveKeyWithValue("example.com/gpu", "true"))
+		Expect(labels).ToNot(HaveKey("example.com/edge"))
+	})
+
+	It("ValidateNodeLabelPrefix rejects invalid prefixes", func() {
+		Expect(classification.ValidateNodeLabelPrefix(classification.DefaultNodeLabelPrefix)).To(Succeed())
+		Expect(classification.ValidateNodeLabelPrefix("Not_Valid")).ToNot(Succeed())
+	})
+})
@@ -0,0 +1,278 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+// TaintFilter selects Nodes by taint. A Node satisfies the filter if at least
+// one of its taints matches all the fields which are set.
+type TaintFilter struct {
+	// Key of the taint
+	Key string `json:"key"`
+
+	// Value of the taint. Any value when empty.
+	// +optional
+	Value string `json:"value,omitempty"`
+
+	// Effect of the taint: NoSchedule, PreferNoSchedule or NoExecute.
+	// Any effect when empty.
+	// +optional
+	Effect corev1.TaintEffect `json:"effect,omitempty"`
+}
+
+// NodeConstraint bounds the number of Nodes with given labels, conditions, taints and
+// advertised resources, for instance at most one Node with DiskPressure, or at least
+// three Ready Nodes without a NoSchedule taint. It can also bound the sum of resources
+// allocatable on those Nodes, for instance at least 8 GPUs.
+type NodeConstraint struct {
+	// LabelFilters select Nodes by label
+	// +optional
+	LabelFilters []libsveltosv1alpha1.LabelFilter `json:"labelFilters,omitempty"`
+
+	// Conditions select Nodes by condition, for instance Ready, MemoryPressure
+	// or DiskPressure. All filters must be satisfied.
+	// +optional
+	Conditions []ConditionFilter `json:"conditions,omitempty"`
+
+	// Taints select Nodes having all these taints
+	// +optional
+	Taints []TaintFilter `json:"taints,omitempty"`
+
+	// AdvertisedResources select Nodes with a non zero allocatable quantity of
+	// all these resources, for instance nvidia.com/gpu
+	// +optional
+	AdvertisedResources []corev1.ResourceName `json:"advertisedResources,omitempty"`
+
+	// Allocatable bounds the sum, across all selected Nodes, of the allocatable
+	// quantity of resources, for instance at least 8 nvidia.com/gpu
+	// +optional
+	Allocatable []AllocatableConstraint `json:"allocatable,omitempty"`
+
+	// MinCount is the minimum number of selected Nodes
+	// +optional
+	MinCount *int `json:"minCount,omitempty"`
+
+	// MaxCount is the maximum number of selected Nodes
+	// +optional
+	MaxCount *int `json:"maxCount,omitempty"`
+}
+
+// AllocatableConstraint bounds the sum of the allocatable quantity of a resource
+// across Nodes
+type AllocatableConstraint struct {
+	// Resource is the name of the resource, for instance nvidia.com/gpu, cpu or memory
+	Resource corev1.ResourceName `json:"resource"`
+
+	// Min is the minimum sum
+	// +optional
+	Min *resource.Quantity `json:"min,omitempty"`
+
+	// Max is the maximum sum
+	// +optional
+	Max *resource.Quantity `json:"max,omitempty"`
+}
+
+// getResourceConstraint returns the resource constraint on Nodes equivalent to c
+func (c *NodeConstraint) getResourceConstraint() *ResourceConstraint {
+	constraint := &ResourceConstraint{
+		ConditionFilters: c.Conditions,
+		TaintFilters:     c.Taints,
+	}
+	constraint.Group = nodeGVK.Group
+	constraint.Version = nodeGVK.Version
+	constraint.Kind = nodeGVK.Kind
+	constraint.LabelFilters = c.LabelFilters
+	constraint.MinCount = c.MinCount
+	constraint.MaxCount = c.MaxCount
+	return constraint
+}
+
+// areNodeConstraintsAMatch returns true if all NodeConstraints are satisfied
+func (m *manager) areNodeConstraintsAMatch(ctx context.Context, classifier *libsveltosv1alpha1.Classifier,
+	extension *ClassifierExtension, snapshot *evaluationSnapshot) (bool, error) {
+
+	for i := range extension.NodeConstraints {
+		isMatch, err := m.isNodeConstraintAMatch(ctx, classifier, &extension.NodeConstraints[i], snapshot)
+		if err != nil {
+			return false, err
+		}
+		if !isMatch {
+			addEvaluationNote(ctx, fmt.Sprintf("node constraint %d not satisfied", i))
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// validateTaintFilters returns an error if any filter is invalid or the
+// constraint is not on Nodes
+func validateTaintFilters(constraint *ResourceConstraint) error {
+	if len(constraint.TaintFilters) == 0 {
+		return nil
+	}
+	if constraint.Group != nodeGVK.Group || constraint.Kind != nodeGVK.Kind {
+		return fmt.Errorf("taint filters are only supported for Nodes")
+	}
+	for i := range constraint.TaintFilters {
+		f := &constraint.TaintFilters[i]
+		if f.Key == "" {
+			return fmt.Errorf("taint filter %d: key is required", i)
+		}
+		switch f.Effect {
+		case "", corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+		default:
+			return fmt.Errorf("taint filter %d: unknown effect %q", i, f.Effect)
+		}
+	}
+	return nil
+}
+
+// areTaintFiltersAMatch returns true if node satisfies all filters
+func areTaintFiltersAMatch(node *unstructured.Unstructured, filters []TaintFilter) bool {
+	if len(filters) == 0 {
+		return true
+	}
+
+	taints, _, err := unstructured.NestedSlice(node.Object, "spec", "taints")
+	if err != nil {
+		return false
+	}
+
+	for i := range filters {
+		found := false
+		for j := range taints {
+			taint, ok := taints[j].(map[string]interface{})
+			if ok && filters[i].isAMatch(taint) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// isAMatch returns true if taint satisfies the filter
+func (f *TaintFilter) isAMatch(taint map[string]interface{}) bool {
+	if taint["key"] != f.Key {
+		return false
+	}
+	if f.Value != "" && taint["value"] != f.Value {
+		return false
+	}
+	if f.Effect != "" && taint["effect"] != string(f.Effect) {
+		return false
+	}
+	return true
+}
+
+// isNodeConstraintAMatch returns true if nodeConstraint is satisfied. Nodes are
+// listed only when their allocatable resources must be inspected.
+func (m *manager) isNodeConstraintAMatch(ctx context.Context, classifier *libsveltosv1alpha1.Classifier,
+	nodeConstraint *NodeConstraint, snapshot *evaluationSnapshot) (bool, error) {
+
+	constraint := nodeConstraint.getResourceConstraint()
+	if len(nodeConstraint.AdvertisedResources) == 0 && len(nodeConstraint.Allocatable) == 0 {
+		return m.evaluateResourceConstraint(ctx, classifier, constraint, snapshot)
+	}
+
+	if err := validateAllocatableConstraints(nodeConstraint.Allocatable); err != nil {
+		return false, newInvalidClassifierError(err)
+	}
+
+	nodes, _, err := m.getMatchingResources(ctx, classifier, constraint, snapshot)
+	if err != nil {
+		return false, err
+	}
+
+	selected := nodes[:0]
+	for i := range nodes {
+		if isAdvertisingResources(&nodes[i], nodeConstraint.AdvertisedResources) {
+			selected = append(selected, nodes[i])
+		}
+	}
+
+	if !isCountAMatch(&constraint.DeployedResourceConstraint, len(selected)) {
+		return false, nil
+	}
+
+	for i := range nodeConstraint.Allocatable {
+		allocatable := &nodeConstraint.Allocatable[i]
+		total := getAllocatableSum(selected, allocatable.Resource)
+		addEvaluationNote(ctx, fmt.Sprintf("%s: %s allocatable on %d Nodes",
+			allocatable.Resource, total.String(), len(selected)))
+		if !isQuantityInRange(&total, allocatable.Min, allocatable.Max) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// validateAllocatableConstraints returns an error if any constraint has no resource
+// or a minimum greater than its maximum
+func validateAllocatableConstraints(constraints []AllocatableConstraint) error {
+	for i := range constraints {
+		c := &constraints[i]
+		if c.Resource == "" {
+			return fmt.Errorf("allocatable constraint %d: resource is required", i)
+		}
+		if c.Min != nil && c.Max != nil && c.Min.Cmp(*c.Max) > 0 {
+			return fmt.Errorf("allocatable constraint %d: min %s is greater than max %s",
+				i, c.Min.String(), c.Max.String())
+		}
+	}
+	return nil
+}
+
+// getAllocatableQuantity returns node status.allocatable quantity of resourceName.
+// Zero if node does not advertise it or its quantity cannot be parsed.
+func getAllocatableQuantity(node *unstructured.Unstructured, resourceName corev1.ResourceName) resource.Quantity {
+	return getQuantity(node.Object, "status", "allocatable", string(resourceName))
+}
+
+// isAdvertisingResources returns true if node has a non zero allocatable
+// quantity of all resources
+func isAdvertisingResources(node *unstructured.Unstructured, resources []corev1.ResourceName) bool {
+	for i := range resources {
+		quantity := getAllocatableQuantity(node, resources[i])
+		if quantity.IsZero() {
+			return false
+		}
+	}
+	return true
+}
+
+// getAllocatableSum returns the sum of the allocatable quantity of resourceName
+// across nodes
+func getAllocatableSum(nodes []unstructured.Unstructured, resourceName corev1.ResourceName) resource.Quantity {
+	total := resource.Quantity{}
+	for i := range nodes {
+		total.Add(getAllocatableQuantity(&nodes[i], resourceName))
+	}
+	return total
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: node constraints", func() {
+	var server *httptest.Server
+	var classifier *libsveltosv1alpha1.Classifier
+
+	BeforeEach(func() {
+		ready := `{"type":"Ready","status":"True"}`
+		notReady := `{"type":"Ready","status":"False"}`
+		diskPressure := `{"type":"DiskPressure","status":"True"}`
+		noSchedule := `{"key":"node-role.kubernetes.io/control-plane","effect":"NoSchedule"}`
+
+		getNode := func(name, labels, taints, allocatable string, conditions ...string) string {
+			conditionList := ""
+			for i := range conditions {
+				if i > 0 {
+					conditionList += ","
+				}
+				conditionList += conditions[i]
+			}
+			return `{"apiVersion":"v1","kind":"Node","metadata":{"name":"` + name + `","labels":{` + labels + `}},` +
+				`"spec":{"taints":[` + taints + `]},"status":{"allocatable":{` + allocatable + `},` +
+				`"conditions":[` + conditionList + `]}}`
+		}
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			switch r.URL.Path {
+			case "/api":
+				_, _ = w.Write([]byte(`{"kind":"APIVersions","versions":["v1"]}`))
+			case "/api/v1":
+				_, _ = w.Write([]byte(`{"kind":"APIResourceList","groupVersion":"v1","resources":[` +
+					`{"name":"nodes","singularName":"node","namespaced":false,"kind":"Node","verbs":["list"]}]}`))
+			case "/apis":
+				_, _ = w.Write([]byte(`{"kind":"APIGroupList","apiVersion":"v1","groups":[]}`))
+			case "/api/v1/nodes":
+				_, _ = w.Write([]byte(`{"apiVersion":"v1","kind":"NodeList","metadata":{},"items":[` +
+					getNode("control-plane", `"tier":"control"`, noSchedule, `"cpu":"2","memory":"4Gi"`, ready) + `,` +
+					getNode("worker-1", `"tier":"worker"`, "", `"cpu":"8","nvidia.com/gpu":"4"`, ready) + `,` +
+					getNode("worker-2", `"tier":"worker"`, "", `"cpu":"8","nvidia.com/gpu":"2"`, ready, diskPressure) +
+					`,` + getNode("worker-3", `"tier":"worker"`, "", `"cpu":"8","nvidia.com/gpu":"0"`, notReady) + `]}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+
+		classifier = &libsveltosv1alpha1.Classifier{ObjectMeta: metav1.ObjectMeta{Name: randomString()}}
+
+		classification.InitializeManagerWithObjects(&rest.Config{Host: server.URL})
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	evaluate := func(nodeConstraints string) (bool, []string) {
+		classifier.Annotations = map[string]string{
+			classification.ClassifierExtensionAnnotation: "nodeConstraints:\n" + nodeConstraints,
+		}
+		isMatch, notes, err := classification.AreNodeConstraintsAMatch(classification.GetManager(), classifier)
+		Expect(err).To(BeNil())
+		return isMatch, notes
+	}
+
+	It("areNodeConstraintsAMatch counts Nodes by condition", func() {
+		isMatch, _ := evaluate(`- conditions: [{type: Ready, status: "True"}]
+  minCount: 3`)
+		Expect(isMatch).To(BeTrue())
+
+		isMatch, notes := evaluate(`- conditions: [{type: DiskPressure, status: "True"}]
+  maxCount: 0`)
+		Expect(isMatch).To(BeFalse())
+		Expect(notes).To(ContainElement("node constraint 0 not satisfied"))
+
+		// Nodes without the condition have status Unknown
+		isMatch, _ = evaluate(`- conditions: [{type: DiskPressure, status: "True", operation: Different}]
+  minCount: 3
+  maxCount: 3`)
+		Expect(isMatch).To(BeTrue())
+	})
+
+	It("areNodeConstraintsAMatch counts Nodes by taint", func() {
+		isMatch, _ := evaluate(`- taints: [{key: node-role.kubernetes.io/control-plane, effect: NoSchedule}]
+  minCount: 1
+  maxCount: 1`)
+		Expect(isMatch).To(BeTrue())
+
+		isMatch, _ = evaluate(`- taints: [{key: node-role.kubernetes.io/control-plane, effect: NoExecute}]
+  minCount: 1`)
+		Expect(isMatch).To(BeFalse())
+	})
+
+	It("areNodeConstraintsAMatch sums allocatable resources", func() {
+		isMatch, notes := evaluate(`- advertisedResources: [nvidia.com/gpu]
+  minCount: 2
+  maxCount: 2
+  allocatable: [{resource: nvidia.com/gpu, min: "6"}]`)
+		Expect(isMatch).To(BeTrue())
+		Expect(notes).To(ContainElement("nvidia.com/gpu: 6 allocatable on 2 Nodes"))
+
+		// Only Ready Nodes without DiskPressure
+		isMatch, _ = evaluate(`- conditions: [{type: Ready, status: "True"},
+    {type: DiskPressure, status: "True", operation: Different}]
+  allocatable: [{resource: nvidia.com/gpu, min: "6"}]`)
+		Expect(isMatch).To(BeFalse())
+
+		isMatch, _ = evaluate(`- allocatable: [{resource: cpu, min: "24", max: "32"}, {resource: memory, max: 8Gi}]`)
+		Expect(isMatch).To(BeTrue())
+
+		classifier.Annotations = map[string]string{
+			classification.ClassifierExtensionAnnotation: `nodeConstraints: [{allocatable: [{resource: cpu, min: "4", max: "2"}]}]`,
+		}
+		_, _, err := classification.AreNodeConstraintsAMatch(classification.GetManager(), classifier)
+		Expect(classification.IsInvalidClassifierError(err)).To(BeTrue())
+	})
+
+	It("areNodeConstraintsAMatch rejects invalid taint filters", func() {
+		classifier.Annotations = map[string]string{
+			classification.ClassifierExtensionAnnotation: `nodeConstraints: [{taints: [{key: a, effect: Sometimes}]}]`,
+		}
+		_, _, err := classification.AreNodeConstraintsAMatch(classification.GetManager(), classifier)
+		Expect(classification.IsInvalidClassifierError(err)).To(BeTrue())
+	})
+
+	It("GetClassifierGVKs includes Node for node constraints", func() {
+		classifier.Annotations = map[string]string{
+			classification.ClassifierExtensionAnnotation: `nodeConstraints: [{minCount: 1}]`,
+		}
+		Expect(classification.GetClassifierGVKs(classifier)).To(ContainElement(
+			schema.GroupVersionKind{Version: "v1", Kind: "Node"}))
+	})
+})
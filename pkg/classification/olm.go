@@ -0,0 +1,234 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/Masterminds/semver"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+const (
+	// csvPhaseSucceeded is the phase of a ClusterServiceVersion whose operator is installed
+	csvPhaseSucceeded = "Succeeded"
+
+	// csvCopiedFromLabel is set by OLM on the copies of a ClusterServiceVersion it
+	// creates in the namespaces an operator watches
+	csvCopiedFromLabel = "olm.copiedFrom"
+)
+
+var (
+	// csvGVK and subscriptionGVK are watched by Classifiers with OLMOperatorConstraints
+	csvGVK = schema.GroupVersionKind{
+		Group:   "operators.coreos.com",
+		Version: "v1alpha1",
+		Kind:    "ClusterServiceVersion",
+	}
+	subscriptionGVK = schema.GroupVersionKind{
+		Group:   "operators.coreos.com",
+		Version: "v1alpha1",
+		Kind:    "Subscription",
+	}
+)
+
+// OLMOperatorConstraint is satisfied when at least one operator installed by the
+// Operator Lifecycle Manager matches it
+type OLMOperatorConstraint struct {
+	// Package is the name of the operator package, as in Subscription spec.name,
+	// for instance "cert-manager". For an operator installed without a Subscription,
+	// its ClusterServiceVersion name without the version suffix.
+	Package string `json:"package"`
+
+	// VersionRange is a semver range expression the operator version must be within,
+	// for instance ">=1.9.0 <2.0.0"
+	// +optional
+	VersionRange string `json:"versionRange,omitempty"`
+
+	// Namespace, when set, restricts operators to the ones installed in this namespace
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// olmOperator contains the fields of an installed ClusterServiceVersion used for classification
+type olmOperator struct {
+	namespace string
+	name      string
+	pkg       string
+	version   string
+}
+
+// areOLMOperatorsAMatch returns true if, for each OLMOperatorConstraint, at least
+// one installed operator satisfies it
+func (m *manager) areOLMOperatorsAMatch(ctx context.Context,
+	classifier *libsveltosv1alpha1.Classifier) (bool, error) {
+
+	extension, err := getClassifierExtension(classifier)
+	if err != nil {
+		return false, err
+	}
+
+	if len(extension.OLMOperatorConstraints) == 0 {
+		return true, nil
+	}
+
+	operators, installed, err := m.getInstalledOLMOperators(ctx)
+	if err != nil {
+		return false, err
+	}
+	if !installed {
+		addEvaluationNote(ctx, "operator lifecycle manager is not installed")
+		return false, nil
+	}
+
+	for i := range extension.OLMOperatorConstraints {
+		constraint := &extension.OLMOperatorConstraints[i]
+		isMatch, err := isOLMOperatorConstraintAMatch(constraint, operators)
+		if err != nil {
+			return false, err
+		}
+		if !isMatch {
+			addEvaluationNote(ctx, fmt.Sprintf("olm operator constraint %d: no installed operator of package %s matches",
+				i, constraint.Package))
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// getInstalledOLMOperators returns the operators whose ClusterServiceVersion succeeded.
+// Returns false if the ClusterServiceVersion CRD is not installed.
+func (m *manager) getInstalledOLMOperators(ctx context.Context) ([]olmOperator, bool, error) {
+	csvs := &unstructured.UnstructuredList{}
+	csvs.SetGroupVersionKind(csvGVK)
+	if err := m.List(ctx, csvs); err != nil {
+		if meta.IsNoMatchError(err) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	packages, err := m.getOLMSubscriptionPackages(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	operators := make([]olmOperator, 0, len(csvs.Items))
+	for i := range csvs.Items {
+		csv := &csvs.Items[i]
+		if _, ok := csv.GetLabels()[csvCopiedFromLabel]; ok {
+			continue
+		}
+		phase, _, _ := unstructured.NestedString(csv.Object, "status", "phase")
+		if phase != csvPhaseSucceeded {
+			continue
+		}
+		version, _, _ := unstructured.NestedString(csv.Object, "spec", "version")
+		pkg, ok := packages[types.NamespacedName{Namespace: csv.GetNamespace(), Name: csv.GetName()}]
+		if !ok {
+			pkg = getCSVPackage(csv.GetName(), version)
+		}
+		operators = append(operators, olmOperator{namespace: csv.GetNamespace(), name: csv.GetName(),
+			pkg: pkg, version: version})
+	}
+
+	return operators, true, nil
+}
+
+// getOLMSubscriptionPackages returns the package of each ClusterServiceVersion
+// installed by a Subscription
+func (m *manager) getOLMSubscriptionPackages(ctx context.Context) (map[types.NamespacedName]string, error) {
+	subscriptions := &unstructured.UnstructuredList{}
+	subscriptions.SetGroupVersionKind(subscriptionGVK)
+	if err := m.List(ctx, subscriptions); err != nil {
+		if meta.IsNoMatchError(err) {
+			return map[types.NamespacedName]string{}, nil
+		}
+		return nil, err
+	}
+
+	packages := make(map[types.NamespacedName]string, len(subscriptions.Items))
+	for i := range subscriptions.Items {
+		subscription := &subscriptions.Items[i]
+		installedCSV, _, _ := unstructured.NestedString(subscription.Object, "status", "installedCSV")
+		pkg, _, _ := unstructured.NestedString(subscription.Object, "spec", "name")
+		if installedCSV == "" || pkg == "" {
+			continue
+		}
+		packages[types.NamespacedName{Namespace: subscription.GetNamespace(), Name: installedCSV}] = pkg
+	}
+	return packages, nil
+}
+
+// getCSVPackage returns the ClusterServiceVersion name without its version suffix,
+// for instance etcdoperator for "etcdoperator.v0.9.4"
+func getCSVPackage(name, version string) string {
+	for _, suffix := range []string{".v" + version, "." + version} {
+		if version != "" && strings.HasSuffix(name, suffix) {
+			return strings.TrimSuffix(name, suffix)
+		}
+	}
+	return name
+}
+
+// isOLMOperatorConstraintAMatch returns true if at least one operator satisfies constraint
+func isOLMOperatorConstraintAMatch(constraint *OLMOperatorConstraint, operators []olmOperator) (bool, error) {
+	if constraint.Package == "" {
+		return false, newInvalidClassifierError(errors.New("olm operator constraint package is required"))
+	}
+
+	var versionRange *semver.Constraints
+	if constraint.VersionRange != "" {
+		var err error
+		versionRange, err = parseVersionRange(constraint.VersionRange)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	for i := range operators {
+		operator := &operators[i]
+		if operator.pkg != constraint.Package {
+			continue
+		}
+		if constraint.Namespace != "" && operator.namespace != constraint.Namespace {
+			continue
+		}
+		if versionRange == nil {
+			return true, nil
+		}
+		version, err := semver.NewVersion(operator.version)
+		if err != nil {
+			// Operator version is not semver: it cannot be within a range
+			continue
+		}
+		if versionRange.Check(version) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
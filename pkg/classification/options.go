@@ -0,0 +1,421 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/projectsveltos/classifier-agent/pkg/sharedwatch"
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+)
+
+// Option configures optional manager behaviors
+type Option func(m *manager)
+
+// WithAllowedActions sets the ActionTypes classifier-agent is allowed to execute.
+// Actions of any other type are skipped.
+func WithAllowedActions(actionTypes []ActionType) Option {
+	return func(m *manager) {
+		m.allowedActions = actionTypes
+	}
+}
+
+// WithActionConfigMapNamespaces sets the namespaces Label actions can create and
+// update ConfigMaps in. Actions targeting a ConfigMap in any other namespace are invalid.
+func WithActionConfigMapNamespaces(namespaces []string) Option {
+	return func(m *manager) {
+		m.actionConfigMapNamespaces = namespaces
+	}
+}
+
+// WithActionsDryRun, when dryRun is true, makes classifier-agent only log
+// actions instead of executing those.
+func WithActionsDryRun(dryRun bool) Option {
+	return func(m *manager) {
+		m.actionsDryRun = dryRun
+	}
+}
+
+// WithEventRecorder sets the EventRecorder used by Event actions
+func WithEventRecorder(recorder record.EventRecorder) Option {
+	return func(m *manager) {
+		m.eventRecorder = recorder
+	}
+}
+
+// WithEvaluationHistorySize sets the number of evaluation results kept
+// per Classifier. A size of zero disables the evaluation history.
+func WithEvaluationHistorySize(size int) Option {
+	return func(m *manager) {
+		m.historySize = size
+	}
+}
+
+// WithEvaluationSummary, when enabled, makes classifier-agent refresh, after each
+// evaluation cycle, a ConfigMap summarizing the outcome of all Classifiers.
+func WithEvaluationSummary(enabled bool) Option {
+	return func(m *manager) {
+		m.evaluationSummary = enabled
+	}
+}
+
+// WithConsistentSnapshot, when enabled, makes classifier-agent list all resources
+// needed to evaluate a Classifier at the same resourceVersion. This prevents
+// verdicts from being computed on mutually inconsistent reads, at the cost of
+// re-evaluating when the resourceVersion is compacted.
+func WithConsistentSnapshot(enabled bool) Option {
+	return func(m *manager) {
+		m.consistentSnapshot = enabled
+	}
+}
+
+// WithVersionMatching sets how the cluster Kubernetes version is compared against
+// Classifier version constraints
+func WithVersionMatching(mode VersionMatchingMode) Option {
+	return func(m *manager) {
+		m.versionMatching = mode
+	}
+}
+
+// WithProtobufLists, when enabled, makes classifier-agent list built-in types
+// using protobuf instead of JSON. Custom resources are always listed using JSON.
+// Disabled by default.
+func WithProtobufLists(enabled bool) Option {
+	return func(m *manager) {
+		m.protobufLists = enabled
+	}
+}
+
+// WithAdaptiveInterval makes the evaluation interval adapt to cluster churn:
+// it is lengthened, up to maxInterval, while no watch event is received and
+// shortened, down to minInterval, when watch event rate is high.
+// A zero maxInterval keeps the evaluation interval fixed.
+func WithAdaptiveInterval(minInterval, maxInterval time.Duration) Option {
+	return func(m *manager) {
+		m.minInterval = minInterval
+		m.maxInterval = maxInterval
+	}
+}
+
+// WithBroadConstraintPolicy sets the policy applied to resource constraints listing,
+// with no namespace nor label filter, more than threshold resources.
+// A non positive threshold keeps DefaultBroadConstraintThreshold.
+func WithBroadConstraintPolicy(policy BroadConstraintPolicy, threshold int) Option {
+	return func(m *manager) {
+		m.broadConstraintPolicy = policy
+		if threshold > 0 {
+			m.broadConstraintThreshold = threshold
+		}
+	}
+}
+
+// WithSharedWatches makes classifier-agent watch resources through registry.
+// Agents embedded in the same process and using the same registry share a
+// single watch per resource type.
+func WithSharedWatches(registry *sharedwatch.Registry) Option {
+	return func(m *manager) {
+		m.sharedWatches = registry
+	}
+}
+
+// WithReportsWithoutCRD, when enabled, makes classifier-agent send results to the
+// management cluster even if ClassifierReport CRD is not installed in the managed
+// cluster. Results are otherwise kept in memory till the CRD is installed.
+func WithReportsWithoutCRD(enabled bool) Option {
+	return func(m *manager) {
+		m.sendReportsWithoutCRD = enabled
+	}
+}
+
+// WithRemoteClassifiers makes classifier-agent fetch Classifiers from the management
+// cluster every interval, instead of reading those from the managed cluster.
+// Classifiers are cached and evaluated locally. A zero interval disables remote mode.
+func WithRemoteClassifiers(interval time.Duration) Option {
+	return func(m *manager) {
+		m.remoteInterval = interval
+	}
+}
+
+// WithTrendRetention sets how long resource counts are kept for TrendConstraints.
+// It bounds the window TrendConstraints can use.
+func WithTrendRetention(retention time.Duration) Option {
+	return func(m *manager) {
+		if retention > 0 {
+			m.trends.retention = retention
+		}
+	}
+}
+
+// WithNodeLabels enables mirroring of evaluation results to labels on all Nodes.
+// Label key is prefix/<classifier name>, value is "true" when cluster is a match,
+// "false" otherwise. Only results of classifiers are mirrored, all when empty.
+// An empty prefix disables node labels.
+func WithNodeLabels(prefix string, classifiers []string) Option {
+	return func(m *manager) {
+		m.nodeLabelPrefix = prefix
+		m.nodeLabelClassifiers = classifiers
+	}
+}
+
+// WithConformance makes classifier-agent verify samplePercent percent of Classifier
+// evaluations with a slow and simple reference evaluator. Any divergence between the
+// two is a bug in the optimized evaluation, and is reported via metrics and Events.
+func WithConformance(samplePercent int) Option {
+	return func(m *manager) {
+		m.conformanceSamplePercent = samplePercent
+	}
+}
+
+// WithVersionProviders sets the sources of the cluster Kubernetes version. Those are
+// queried in order, and the first one which has a version is used. This allows
+// overriding the version reported by control planes which misreport it.
+// Unknown types are ignored.
+func WithVersionProviders(types ...VersionProviderType) Option {
+	return func(m *manager) {
+		for i := range types {
+			provider, err := getVersionProvider(types[i], m.config, m.Client, m.log)
+			if err != nil {
+				m.log.V(logs.LogInfo).Info(err.Error())
+				continue
+			}
+			m.versionProviders = append(m.versionProviders, provider)
+		}
+	}
+}
+
+// WithVersionProvider adds a custom source of the cluster Kubernetes version,
+// queried after the ones already configured
+func WithVersionProvider(provider VersionProvider) Option {
+	return func(m *manager) {
+		m.versionProviders = append(m.versionProviders, provider)
+	}
+}
+
+// WithAgentPod sets the namespace and name of the Pod classifier-agent runs in,
+// usually exposed via the downward API. Those are used to collect facts about
+// classifier-agent environment.
+func WithAgentPod(namespace, name string) Option {
+	return func(m *manager) {
+		m.agentNamespace = namespace
+		m.agentPodName = name
+	}
+}
+
+// WithHostCluster sets the cluster hosting the control plane of the managed cluster,
+// for instance with Kamaji, vCluster or HyperShift. HostResourceConstraints are
+// evaluated against it. Host resources are not watched: Classifiers with
+// HostResourceConstraints are evaluated again every resyncInterval
+// (DefaultHostResyncInterval when not positive).
+func WithHostCluster(config *rest.Config, resyncInterval time.Duration) Option {
+	return func(m *manager) {
+		if config == nil {
+			return
+		}
+		if resyncInterval <= 0 {
+			resyncInterval = DefaultHostResyncInterval
+		}
+		m.host = &hostCluster{
+			// API server traffic is attributed to the Classifier being evaluated
+			config:         withCostTracking(config),
+			resyncInterval: resyncInterval,
+			trends:         newTrendStore(m.trends.retention),
+		}
+	}
+}
+
+// WithMemoryPressureThreshold sets the heap size, in bytes, above which classifier-agent
+// is degraded. While degraded, ClassifierReports carry a Degraded condition so that the
+// management cluster can avoid acting on classifications which may be stale.
+// Zero disables memory pressure detection.
+func WithMemoryPressureThreshold(bytes uint64) Option {
+	return func(m *manager) {
+		m.memoryPressureThreshold = bytes
+	}
+}
+
+// WithShardHasher sets how the ReportShardLabel value set on ClassifierReports sent
+// to the management cluster is computed. DefaultShardHasher is used when hasher is nil.
+func WithShardHasher(hasher ShardHasher) Option {
+	return func(m *manager) {
+		m.shardHasher = hasher
+	}
+}
+
+// WithMaxFactStaleness sets the age above which a cached fact, such as discovery
+// data, is stale. Classifiers are still evaluated, but their ClassifierReports are
+// annotated with the stale facts. Zero disables staleness reporting.
+func WithMaxFactStaleness(maxStaleness time.Duration) Option {
+	return func(m *manager) {
+		m.maxFactStaleness = maxStaleness
+	}
+}
+
+// WithConstraintMemoization, when enabled, makes Classifiers evaluated in the same
+// cycle share the resources matched by identical resource constraints, MinCount and
+// MaxCount aside: resources are counted once and each Classifier only verifies its
+// thresholds. Not applied when WithConsistentSnapshot is enabled.
+func WithConstraintMemoization(enabled bool) Option {
+	return func(m *manager) {
+		m.constraintMemoization = enabled
+	}
+}
+
+// WithPrometheus sets the in-cluster Prometheus PrometheusConstraints are evaluated
+// against. Classifiers with PrometheusConstraints are evaluated again every
+// resyncInterval (DefaultPrometheusResyncInterval when not positive).
+func WithPrometheus(endpoint *PrometheusEndpoint, resyncInterval time.Duration) Option {
+	return func(m *manager) {
+		if endpoint == nil || endpoint.URL == "" {
+			return
+		}
+		if resyncInterval <= 0 {
+			resyncInterval = DefaultPrometheusResyncInterval
+		}
+		m.prometheus = &prometheusEndpoint{
+			PrometheusEndpoint: *endpoint,
+			resyncInterval:     resyncInterval,
+		}
+	}
+}
+
+// WithUpgradeHandshake makes classifier-agent instances coordinate, via the HandshakeLeaseName
+// Lease in namespace, so that only one of them evaluates Classifiers and delivers
+// ClassifierReports at a time. An incoming instance requests the Lease; the instance
+// holding it evaluates its queued Classifiers a last time, then hands the Lease over.
+// leaseDuration is DefaultHandshakeLeaseDuration when shorter than a second.
+func WithUpgradeHandshake(namespace, identity string, leaseDuration time.Duration) Option {
+	return func(m *manager) {
+		if leaseDuration < time.Second {
+			leaseDuration = DefaultHandshakeLeaseDuration
+		}
+		m.handshake = &upgradeHandshake{
+			namespace:     namespace,
+			identity:      identity,
+			leaseDuration: leaseDuration,
+			mu:            &sync.Mutex{},
+		}
+	}
+}
+
+// WithEffectiveRBACAnnotation, when enabled, sets ClassifierReportEffectiveRBACAnnotation
+// on ClassifierReports in the managed cluster. Effective RBAC is always served by the
+// EffectiveRBACPath debug endpoint.
+func WithEffectiveRBACAnnotation(enabled bool) Option {
+	return func(m *manager) {
+		m.effectiveRBACAnnotation = enabled
+	}
+}
+
+// WithHealthGate, when enabled, checks API server /readyz endpoint and discovery before
+// each evaluation cycle. While checks fail, the cycle is skipped, ClassifierReports keep
+// their last verdict and ClassifierReportClusterUnhealthyAnnotation is set on them.
+// timeout bounds each check and is DefaultHealthGateTimeout when zero.
+func WithHealthGate(enabled bool, timeout time.Duration) Option {
+	return func(m *manager) {
+		m.healthGate = enabled
+		m.healthGateTimeout = timeout
+	}
+}
+
+// WithMetricsProviders sets the MetricsProviders, in addition to ConfigMaps labeled
+// MetricsProviderLabel, MetricConstraints can reference. Classifiers with MetricConstraints
+// are evaluated again every resyncInterval (DefaultMetricsProviderResyncInterval when
+// not positive).
+func WithMetricsProviders(providers []MetricsProvider, resyncInterval time.Duration) Option {
+	return func(m *manager) {
+		if resyncInterval <= 0 {
+			resyncInterval = DefaultMetricsProviderResyncInterval
+		}
+		m.metricsProviders = providers
+		m.metricsResyncInterval = resyncInterval
+	}
+}
+
+// WithListRetries sets how many times, within an evaluation, a List or Get failing
+// with a transient error (throttling, etcd leader change) is retried before the
+// evaluation fails. delay, DefaultListRetryDelay when not positive, is the delay
+// before the first retry. Zero retries disables retrying.
+func WithListRetries(retries int, delay time.Duration) Option {
+	return func(m *manager) {
+		if retries < 0 {
+			retries = 0
+		}
+		if delay <= 0 {
+			delay = DefaultListRetryDelay
+		}
+		m.listRetries = retries
+		m.listRetryDelay = delay
+	}
+}
+
+// WithBehaviorProfile overrides, with its non zero fields, the behavior profile of
+// the cluster type
+func WithBehaviorProfile(profile BehaviorProfile) Option {
+	return func(m *manager) {
+		m.applyBehaviorProfile(profile)
+	}
+}
+
+// WithEvaluationHooks layers hooks around each Classifier evaluation. PreEvaluate is
+// invoked in the order hooks are passed, PostEvaluate in reverse order.
+func WithEvaluationHooks(hooks ...EvaluationHook) Option {
+	return func(m *manager) {
+		m.hooks = append(m.hooks, hooks...)
+	}
+}
+
+// WithCycleBudget bounds each evaluation cycle to fraction of the evaluation interval.
+// Classifiers not evaluated within it are evaluated first in next cycle. Zero, the
+// default, does not bound cycles.
+func WithCycleBudget(fraction float64) Option {
+	return func(m *manager) {
+		m.cycleBudget = fraction
+	}
+}
+
+// WithReportDeduplication, when enabled, sets ClassifierReportDuplicatesAnnotation on the
+// ClassifierReports delivered to the management cluster, so that redundant or conflicting
+// Classifiers can be detected there
+func WithReportDeduplication(enabled bool) Option {
+	return func(m *manager) {
+		m.reportDeduplication = enabled
+	}
+}
+
+// WithClockSkewTolerance sets the offset between cluster and classifier-agent clocks,
+// estimated from node heartbeats, beyond which age and time window constraints are
+// evaluated on the cluster clock and ClassifierReports are flagged. Zero disables
+// clock skew detection.
+func WithClockSkewTolerance(tolerance time.Duration) Option {
+	return func(m *manager) {
+		m.clockSkewTolerance = tolerance
+	}
+}
+
+// WithStateFile makes classifier-agent write the classification state to path, in format,
+// after each evaluation cycle, for instance on a volume shared with node-local tooling.
+// File is replaced atomically.
+func WithStateFile(path string, format ClassificationStateFormat) Option {
+	return func(m *manager) {
+		m.stateFile = path
+		m.stateFileFormat = format
+	}
+}
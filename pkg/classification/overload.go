@@ -0,0 +1,190 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/projectsveltos/classifier-agent/internal/utils"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+)
+
+const (
+	// ClassifierReportAgentDegradedAnnotation is set on all ClassifierReports while
+	// classifier-agent is overloaded, so that the management cluster can avoid acting
+	// on classifications which may be stale. Value is a JSON encoded Degraded condition.
+	ClassifierReportAgentDegradedAnnotation = "classifier.projectsveltos.io/agent-degraded"
+
+	// AgentDegradedCondition is the type of the condition set while classifier-agent
+	// is overloaded
+	AgentDegradedCondition = "Degraded"
+
+	// ReasonMemoryPressure indicates classifier-agent heap exceeds the memory
+	// pressure threshold
+	ReasonMemoryPressure = "MemoryPressure"
+
+	// ReasonEvaluationFailures indicates all Classifier evaluations failed in
+	// the last evaluationFailureCycles cycles
+	ReasonEvaluationFailures = "EvaluationFailures"
+
+	// evaluationFailureCycles is the number of consecutive evaluation cycles in which
+	// all evaluations failed after which classifier-agent is degraded
+	evaluationFailureCycles = 3
+)
+
+var agentDegraded = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "degraded",
+		Help:      "1 while classifier-agent is overloaded and reports may be stale, 0 otherwise",
+	},
+)
+
+func init() {
+	metrics.Registry.MustRegister(agentDegraded)
+}
+
+// updateOverload is invoked after each evaluation cycle, with the number of Classifiers
+// evaluated and the number of evaluations which failed. When classifier-agent enters
+// or leaves degraded mode, all ClassifierReports are updated.
+func (m *manager) updateOverload(ctx context.Context, evaluated, failed int) {
+	var heapInUse uint64
+	if m.memoryPressureThreshold > 0 {
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		heapInUse = stats.HeapInuse
+	}
+
+	if !m.setOverload(evaluated, failed, heapInUse, time.Now()) {
+		return
+	}
+	if err := m.refreshAgentDegraded(ctx); err != nil {
+		m.log.V(logs.LogInfo).Info(fmt.Sprintf("failed to update ClassifierReports degraded condition: %v", err))
+	}
+}
+
+// setOverload updates the degraded condition. Returns true if classifier-agent entered
+// degraded mode, left it, or is degraded for a different reason.
+func (m *manager) setOverload(evaluated, failed int, heapInUse uint64, now time.Time) bool {
+	m.overloadMu.Lock()
+	defer m.overloadMu.Unlock()
+
+	if evaluated > 0 {
+		if failed == evaluated {
+			m.failedCycles++
+		} else {
+			m.failedCycles = 0
+		}
+	}
+
+	var reason, message string
+	switch {
+	case m.memoryPressureThreshold > 0 && heapInUse > m.memoryPressureThreshold:
+		reason = ReasonMemoryPressure
+		message = fmt.Sprintf("heap in use exceeds %d bytes", m.memoryPressureThreshold)
+	case m.failedCycles >= evaluationFailureCycles:
+		reason = ReasonEvaluationFailures
+		message = fmt.Sprintf("all classifier evaluations failed in the last %d evaluation cycles",
+			evaluationFailureCycles)
+	}
+
+	if reason == "" {
+		if m.degraded == nil {
+			return false
+		}
+		m.log.V(logs.LogInfo).Info("classifier-agent is not degraded anymore")
+		m.degraded = nil
+		agentDegraded.Set(0)
+		return true
+	}
+
+	if m.degraded != nil && m.degraded.Reason == reason {
+		return false
+	}
+	m.log.V(logs.LogInfo).Info(fmt.Sprintf("classifier-agent is degraded: %s", message))
+	m.degraded = &metav1.Condition{
+		Type:               AgentDegradedCondition,
+		Status:             metav1.ConditionTrue,
+		Reason:             reason,
+		Message:            message,
+		LastTransitionTime: metav1.NewTime(now),
+	}
+	agentDegraded.Set(1)
+	return true
+}
+
+// setReportAgentDegraded sets ClassifierReportAgentDegradedAnnotation while
+// classifier-agent is degraded and removes it otherwise
+func (m *manager) setReportAgentDegraded(report *libsveltosv1alpha1.ClassifierReport) {
+	m.overloadMu.Lock()
+	defer m.overloadMu.Unlock()
+
+	value := ""
+	if m.degraded != nil {
+		data, err := json.Marshal(m.degraded)
+		if err != nil {
+			m.log.V(logs.LogInfo).Info(fmt.Sprintf("failed to encode degraded condition: %v", err))
+		} else {
+			value = string(data)
+		}
+	}
+	setReportAnnotation(report, ClassifierReportAgentDegradedAnnotation, value)
+}
+
+// refreshAgentDegraded sets the current degraded and cluster unhealthy conditions on all ClassifierReports,
+// leaving their match untouched, and sends them to the management cluster when
+// reports are sent. Classifiers are not evaluated.
+func (m *manager) refreshAgentDegraded(ctx context.Context) error {
+	reports := &libsveltosv1alpha1.ClassifierReportList{}
+	if err := m.List(ctx, reports, client.InNamespace(utils.ReportNamespace)); err != nil {
+		if isReportCRDMissing(err) {
+			return nil
+		}
+		return err
+	}
+
+	for i := range reports.Items {
+		current := &reports.Items[i]
+		// All fields classifier-agent owns are applied again, as any field not applied
+		// would be removed
+		classifierReport := m.getClassifierReport(current.Name, current.Spec.Match)
+		copyReportAnnotations(classifierReport, current)
+		m.setReportAgentDegraded(classifierReport)
+		m.setReportClusterUnhealthy(classifierReport)
+		if err := applyClassifierReport(ctx, m.Client, classifierReport); err != nil {
+			return err
+		}
+
+		if m.sendReport {
+			classifier := &libsveltosv1alpha1.Classifier{ObjectMeta: metav1.ObjectMeta{Name: current.Name}}
+			if err := m.sendClassifierReport(ctx, classifier); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
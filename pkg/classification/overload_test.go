@@ -0,0 +1,110 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/projectsveltos/classifier-agent/internal/utils"
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: overload", func() {
+
+	BeforeEach(func() {
+		classification.Reset()
+	})
+
+	It("setOverload degrades agent after consecutive failed evaluation cycles", func() {
+		classification.InitializeManagerWithObjects(nil)
+		manager := classification.GetManager()
+
+		now := time.Now()
+		Expect(classification.SetOverload(manager, 2, 2, 0, now)).To(BeFalse())
+		Expect(classification.SetOverload(manager, 2, 2, 0, now)).To(BeFalse())
+		// Cycles with nothing to evaluate do not reset failures
+		Expect(classification.SetOverload(manager, 0, 0, 0, now)).To(BeFalse())
+		Expect(classification.SetOverload(manager, 1, 1, 0, now)).To(BeTrue())
+		Expect(classification.SetOverload(manager, 1, 1, 0, now)).To(BeFalse())
+
+		// One successful evaluation is enough to leave degraded mode
+		Expect(classification.SetOverload(manager, 2, 1, 0, now)).To(BeTrue())
+		Expect(classification.SetOverload(manager, 2, 1, 0, now)).To(BeFalse())
+	})
+
+	It("setOverload degrades agent when heap exceeds memory pressure threshold", func() {
+		classification.InitializeManagerWithObjects(nil)
+		// Threshold is well above the actual heap, so that evaluation loop does not
+		// detect memory pressure on its own
+		threshold := uint64(1) << 50
+		classification.ApplyOptions(classification.WithMemoryPressureThreshold(threshold))
+		manager := classification.GetManager()
+
+		Expect(classification.SetOverload(manager, 1, 0, threshold, time.Now())).To(BeFalse())
+		Expect(classification.SetOverload(manager, 1, 0, threshold+1, time.Now())).To(BeTrue())
+	})
+
+	It("refreshAgentDegraded sets Degraded condition on ClassifierReports and keeps match", func() {
+		classifier := getClassifierWithKubernetesConstraints(version25, libsveltosv1alpha1.ComparisonEqual)
+		c := classification.InitializeManagerWithObjects(nil, classifier)
+		manager := classification.GetManager()
+
+		Expect(classification.CreateClassifierReport(manager, context.TODO(), classifier, true, nil)).
+			To(Succeed())
+
+		for i := 0; i < 3; i++ {
+			classification.SetOverload(manager, 1, 1, 0, time.Now())
+		}
+		Expect(classification.RefreshAgentDegraded(manager, context.TODO())).To(Succeed())
+
+		classifierReport := &libsveltosv1alpha1.ClassifierReport{}
+		key := types.NamespacedName{Namespace: utils.ReportNamespace, Name: classifier.Name}
+		Expect(c.Get(context.TODO(), key, classifierReport)).To(Succeed())
+		Expect(classifierReport.Spec.Match).To(BeTrue())
+		Expect(classifierReport.Annotations).To(HaveKey(classification.ClassifierReportAgentDegradedAnnotation))
+
+		condition := &metav1.Condition{}
+		Expect(json.Unmarshal(
+			[]byte(classifierReport.Annotations[classification.ClassifierReportAgentDegradedAnnotation]),
+			condition)).To(Succeed())
+		Expect(condition.Type).To(Equal(classification.AgentDegradedCondition))
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Reason).To(Equal(classification.ReasonEvaluationFailures))
+
+		// Reports created while degraded carry the condition as well
+		Expect(classification.CreateClassifierReport(manager, context.TODO(), classifier, false, nil)).
+			To(Succeed())
+		Expect(c.Get(context.TODO(), key, classifierReport)).To(Succeed())
+		Expect(classifierReport.Spec.Match).To(BeFalse())
+		Expect(classifierReport.Annotations).To(HaveKey(classification.ClassifierReportAgentDegradedAnnotation))
+
+		Expect(classification.SetOverload(manager, 1, 0, 0, time.Now())).To(BeTrue())
+		Expect(classification.RefreshAgentDegraded(manager, context.TODO())).To(Succeed())
+		Expect(c.Get(context.TODO(), key, classifierReport)).To(Succeed())
+		Expect(classifierReport.Spec.Match).To(BeFalse())
+		Expect(classifierReport.Annotations).ToNot(HaveKey(classification.ClassifierReportAgentDegradedAnnotation))
+	})
+})
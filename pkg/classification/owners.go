@@ -0,0 +1,98 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// OwnerFilter selects resources by owner. A resource satisfies the filter if
+// at least one of its ownerReferences matches all the fields which are set.
+type OwnerFilter struct {
+	// Group of the owner. Core group when empty and Kind is set.
+	// +optional
+	Group string `json:"group,omitempty"`
+
+	// Kind of the owner. Any kind when empty.
+	// +optional
+	Kind string `json:"kind,omitempty"`
+
+	// Name of the owner. Any name when empty.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Controller, when set, requires the ownerReference controller field
+	// to have this value
+	// +optional
+	Controller *bool `json:"controller,omitempty"`
+}
+
+// validateOwnerFilters returns an error if any filter is invalid
+func validateOwnerFilters(filters []OwnerFilter) error {
+	for i := range filters {
+		if filters[i].Group != "" && filters[i].Kind == "" {
+			return fmt.Errorf("owner filter %d: group requires kind", i)
+		}
+	}
+	return nil
+}
+
+// isAMatch returns true if ownerReference satisfies the filter
+func (f *OwnerFilter) isAMatch(ownerReference *metav1.OwnerReference) bool {
+	if f.Kind != "" {
+		gv, err := schema.ParseGroupVersion(ownerReference.APIVersion)
+		if err != nil || gv.Group != f.Group || ownerReference.Kind != f.Kind {
+			return false
+		}
+	}
+	if f.Name != "" && ownerReference.Name != f.Name {
+		return false
+	}
+	if f.Controller != nil {
+		isController := ownerReference.Controller != nil && *ownerReference.Controller
+		if isController != *f.Controller {
+			return false
+		}
+	}
+	return true
+}
+
+// areOwnerFiltersAMatch returns true if resource satisfies all filters
+func areOwnerFiltersAMatch(resource *unstructured.Unstructured, filters []OwnerFilter) bool {
+	if len(filters) == 0 {
+		return true
+	}
+
+	ownerReferences := resource.GetOwnerReferences()
+	for i := range filters {
+		found := false
+		for j := range ownerReferences {
+			if filters[i].isAMatch(&ownerReferences[j]) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
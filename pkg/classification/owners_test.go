@@ -0,0 +1,107 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+)
+
+var _ = Describe("Manager: owner filters", func() {
+	var resources []unstructured.Unstructured
+
+	BeforeEach(func() {
+		controller := true
+		notController := false
+
+		owned := map[string][]metav1.OwnerReference{
+			"fluentd-abcde": {
+				{APIVersion: "apps/v1", Kind: "DaemonSet", Name: "fluentd", Controller: &controller},
+			},
+			"nginx-7d9f8-xyz": {
+				{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "nginx-7d9f8", Controller: &controller},
+			},
+			"operator-managed": {
+				{APIVersion: "example.com/v1", Kind: "Database", Name: "db", Controller: &notController},
+				{APIVersion: "v1", Kind: "ConfigMap", Name: "settings"},
+			},
+			"standalone": nil,
+		}
+
+		resources = nil
+		for name, ownerReferences := range owned {
+			u := unstructured.Unstructured{}
+			u.SetName(name)
+			u.SetOwnerReferences(ownerReferences)
+			resources = append(resources, u)
+		}
+	})
+
+	filter := func(filters ...classification.OwnerFilter) []string {
+		items, err := classification.FilterResourcesWithOwners(
+			append([]unstructured.Unstructured{}, resources...), filters)
+		Expect(err).To(BeNil())
+		names := make([]string, len(items))
+		for i := range items {
+			names[i] = items[i].GetName()
+		}
+		return names
+	}
+
+	It("OwnerFilters match on owner kind, name and controller", func() {
+		controller := true
+		notController := false
+
+		Expect(filter(classification.OwnerFilter{Group: "apps", Kind: "DaemonSet", Name: "fluentd"})).
+			To(ConsistOf("fluentd-abcde"))
+		Expect(filter(classification.OwnerFilter{Group: "apps", Kind: "DaemonSet"})).
+			To(ConsistOf("fluentd-abcde"))
+		// Kind without group is a core group kind
+		Expect(filter(classification.OwnerFilter{Kind: "DaemonSet"})).To(BeEmpty())
+		Expect(filter(classification.OwnerFilter{Kind: "ConfigMap"})).To(ConsistOf("operator-managed"))
+		Expect(filter(classification.OwnerFilter{Group: "example.com", Kind: "Database"})).
+			To(ConsistOf("operator-managed"))
+		Expect(filter(classification.OwnerFilter{Controller: &controller})).
+			To(ConsistOf("fluentd-abcde", "nginx-7d9f8-xyz"))
+		Expect(filter(classification.OwnerFilter{Controller: &notController})).
+			To(ConsistOf("operator-managed"))
+		// An empty filter matches all owned resources
+		Expect(filter(classification.OwnerFilter{})).
+			To(ConsistOf("fluentd-abcde", "nginx-7d9f8-xyz", "operator-managed"))
+
+		// All filters must be satisfied, each by any ownerReference
+		Expect(filter(
+			classification.OwnerFilter{Kind: "ConfigMap", Name: "settings"},
+			classification.OwnerFilter{Group: "example.com", Kind: "Database", Controller: &notController},
+		)).To(ConsistOf("operator-managed"))
+		Expect(filter(
+			classification.OwnerFilter{Kind: "ConfigMap"},
+			classification.OwnerFilter{Controller: &controller},
+		)).To(BeEmpty())
+	})
+
+	It("OwnerFilters with group but no kind are rejected", func() {
+		_, err := classification.FilterResourcesWithOwners(resources,
+			[]classification.OwnerFilter{{Group: "apps"}})
+		Expect(err).ToNot(BeNil())
+	})
+})
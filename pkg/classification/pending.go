@@ -0,0 +1,88 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+)
+
+var classifierReportGK = schema.GroupKind{
+	Group: libsveltosv1alpha1.GroupVersion.Group,
+	Kind:  "ClassifierReport",
+}
+
+// isReportCRDMissing returns true if err indicates the ClassifierReport
+// CustomResourceDefinition is not installed in the managed cluster
+func isReportCRDMissing(err error) bool {
+	return meta.IsNoMatchError(err)
+}
+
+// storePendingReport keeps in memory the result of a Classifier evaluation
+// which could not be persisted because ClassifierReport CRD is not installed
+func (m *manager) storePendingReport(classifier *libsveltosv1alpha1.Classifier, isMatch bool,
+	evaluationErr error) {
+
+	classifierReport := m.getClassifierReport(classifier.Name, isMatch)
+	m.setReportAnnotations(classifierReport, classifier, evaluationErr)
+
+	m.pendingReportsMu.Lock()
+	defer m.pendingReportsMu.Unlock()
+	m.pendingReports[classifier.Name] = classifierReport
+}
+
+// getPendingReport returns the result, kept in memory, of the last evaluation
+// of a Classifier. Returns nil if there is none.
+func (m *manager) getPendingReport(classifierName string) *libsveltosv1alpha1.ClassifierReport {
+	m.pendingReportsMu.Lock()
+	defer m.pendingReportsMu.Unlock()
+
+	classifierReport, ok := m.pendingReports[classifierName]
+	if !ok {
+		return nil
+	}
+	return classifierReport.DeepCopy()
+}
+
+func (m *manager) removePendingReport(classifierName string) {
+	m.pendingReportsMu.Lock()
+	defer m.pendingReportsMu.Unlock()
+	delete(m.pendingReports, classifierName)
+}
+
+// evaluatePendingReports is invoked when a CustomResourceDefinition changes.
+// When ClassifierReport CRD is installed, Classifiers whose results are only
+// in memory are queued for evaluation, so their ClassifierReports are created.
+func (m *manager) evaluatePendingReports(gvk *schema.GroupVersionKind) {
+	if gvk.GroupKind() != classifierReportGK {
+		return
+	}
+
+	m.pendingReportsMu.Lock()
+	defer m.pendingReportsMu.Unlock()
+
+	for classifierName := range m.pendingReports {
+		m.log.V(logs.LogDebug).Info(fmt.Sprintf("ClassifierReport CRD changed: queuing classifier %s",
+			classifierName))
+		m.EvaluateClassifier(classifierName)
+	}
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: ClassifierReport CRD not installed", func() {
+	var classifier *libsveltosv1alpha1.Classifier
+
+	BeforeEach(func() {
+		classification.Reset()
+
+		classifier = getClassifierWithKubernetesConstraints(version25, libsveltosv1alpha1.ComparisonEqual)
+	})
+
+	It("isReportCRDMissing detects ClassifierReport CRD is not installed", func() {
+		gk := schema.GroupKind{Group: libsveltosv1alpha1.GroupVersion.Group, Kind: "ClassifierReport"}
+		Expect(classification.IsReportCRDMissing(&meta.NoKindMatchError{GroupKind: gk})).To(BeTrue())
+
+		Expect(classification.IsReportCRDMissing(
+			apierrors.NewNotFound(libsveltosv1alpha1.GroupVersion.WithResource("classifierreports").GroupResource(),
+				classifier.Name))).To(BeFalse())
+		Expect(classification.IsReportCRDMissing(errors.New("some error"))).To(BeFalse())
+	})
+
+	It("storePendingReport keeps result in memory till Classifier is cleaned", func() {
+		classification.InitializeManagerWithObjects(nil, classifier)
+		manager := classification.GetManager()
+
+		Expect(classification.GetPendingReport(manager, classifier.Name)).To(BeNil())
+
+		classification.StorePendingReport(manager, classifier, true, nil)
+
+		pending := classification.GetPendingReport(manager, classifier.Name)
+		Expect(pending).ToNot(BeNil())
+		Expect(pending.Spec.ClassifierName).To(Equal(classifier.Name))
+		Expect(pending.Spec.Match).To(BeTrue())
+		Expect(pending.Annotations).ToNot(HaveKey(classification.ClassifierReportErrorAnnotation))
+
+		// Changing returned copy does not change what is kept in memory
+		pending.Spec.Match = false
+		Expect(classification.GetPendingReport(manager, classifier.Name).Spec.Match).To(BeTrue())
+
+		Expect(classification.CleanClassifierReport(manager, context.TODO(), classifier.Name)).To(Succeed())
+		Expect(classification.GetPendingReport(manager, classifier.Name)).To(BeNil())
+	})
+})
@@ -0,0 +1,119 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	// samplingConfidence is the probability the estimated percentage is
+	// within samplingError of the actual one
+	samplingConfidence = 0.99
+
+	// minSampleSize is the minimum number of resources a percentage is estimated on
+	minSampleSize = 100
+
+	percentMax = 100
+)
+
+// validatePercentageConstraint returns an invalidClassifierError if percentage
+// bounds are not between 0 and 100 or MinPercent is greater than MaxPercent
+func validatePercentageConstraint(percentage *PercentageConstraint) error {
+	if percentage.MinPercent != nil && (*percentage.MinPercent < 0 || *percentage.MinPercent > percentMax) {
+		return newInvalidClassifierError(fmt.Errorf("minPercent %d is not between 0 and 100",
+			*percentage.MinPercent))
+	}
+
+	if percentage.MaxPercent != nil && (*percentage.MaxPercent < 0 || *percentage.MaxPercent > percentMax) {
+		return newInvalidClassifierError(fmt.Errorf("maxPercent %d is not between 0 and 100",
+			*percentage.MaxPercent))
+	}
+
+	if percentage.MinPercent != nil && percentage.MaxPercent != nil &&
+		*percentage.MinPercent > *percentage.MaxPercent {
+
+		return newInvalidClassifierError(fmt.Errorf("minPercent %d is greater than maxPercent %d",
+			*percentage.MinPercent, *percentage.MaxPercent))
+	}
+
+	return nil
+}
+
+// isPercentageAMatch returns true if count out of total is within percentage bounds.
+// When total is zero, percentage is considered to be zero.
+func isPercentageAMatch(percentage *PercentageConstraint, count, total int) bool {
+	value := 0.0
+	if total > 0 {
+		value = float64(count) * percentMax / float64(total)
+	}
+
+	if percentage.MinPercent != nil && value < float64(*percentage.MinPercent) {
+		return false
+	}
+
+	if percentage.MaxPercent != nil && value > float64(*percentage.MaxPercent) {
+		return false
+	}
+
+	return true
+}
+
+// getPercentageStopCondition returns, when sampling is enabled, a stopCondition
+// verified once enough resources have been listed to know, with samplingConfidence,
+// whether the percentage is within bounds.
+func getPercentageStopCondition(percentage *PercentageConstraint) stopCondition {
+	if !percentage.Sampling {
+		return nil
+	}
+
+	return func(count, listed int) bool {
+		if listed < minSampleSize {
+			return false
+		}
+
+		estimate := float64(count) * percentMax / float64(listed)
+		margin := samplingError(listed) * percentMax
+
+		return isIntervalWithinBounds(percentage, estimate-margin, estimate+margin) ||
+			isIntervalOutsideBounds(percentage, estimate-margin, estimate+margin)
+	}
+}
+
+// samplingError returns the maximum difference, with samplingConfidence, between the
+// proportion estimated on n resources and the actual one (Hoeffding inequality)
+func samplingError(n int) float64 {
+	return math.Sqrt(math.Log(2/(1-samplingConfidence)) / (2 * float64(n)))
+}
+
+func isIntervalWithinBounds(percentage *PercentageConstraint, low, high float64) bool {
+	if percentage.MinPercent != nil && low < float64(*percentage.MinPercent) {
+		return false
+	}
+	if percentage.MaxPercent != nil && high > float64(*percentage.MaxPercent) {
+		return false
+	}
+	return true
+}
+
+func isIntervalOutsideBounds(percentage *PercentageConstraint, low, high float64) bool {
+	if percentage.MinPercent != nil && high < float64(*percentage.MinPercent) {
+		return true
+	}
+	return percentage.MaxPercent != nil && low > float64(*percentage.MaxPercent)
+}
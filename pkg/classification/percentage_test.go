@@ -0,0 +1,88 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: early exit and sampling", func() {
+	It("getCountStopCondition stops once MinCount is reached or MaxCount is exceeded", func() {
+		minCount := 3
+		maxCount := 10
+
+		stop := classification.GetCountStopCondition(&libsveltosv1alpha1.DeployedResourceConstraint{})
+		Expect(stop(1000, 1000)).To(BeFalse())
+
+		stop = classification.GetCountStopCondition(&libsveltosv1alpha1.DeployedResourceConstraint{
+			MinCount: &minCount})
+		Expect(stop(2, 500)).To(BeFalse())
+		Expect(stop(3, 500)).To(BeTrue())
+
+		// Once MaxCount is set, reaching MinCount is not enough
+		stop = classification.GetCountStopCondition(&libsveltosv1alpha1.DeployedResourceConstraint{
+			MinCount: &minCount, MaxCount: &maxCount})
+		Expect(stop(3, 500)).To(BeFalse())
+		Expect(stop(10, 500)).To(BeFalse())
+		Expect(stop(11, 500)).To(BeTrue())
+	})
+
+	It("isPercentageAMatch verifies percentage is within bounds", func() {
+		minPercent := 50
+		maxPercent := 90
+		percentage := &classification.PercentageConstraint{MinPercent: &minPercent, MaxPercent: &maxPercent}
+
+		Expect(classification.IsPercentageAMatch(percentage, 5, 10)).To(BeTrue())
+		Expect(classification.IsPercentageAMatch(percentage, 9, 10)).To(BeTrue())
+		Expect(classification.IsPercentageAMatch(percentage, 4, 10)).To(BeFalse())
+		Expect(classification.IsPercentageAMatch(percentage, 10, 10)).To(BeFalse())
+		Expect(classification.IsPercentageAMatch(percentage, 0, 0)).To(BeFalse())
+
+		Expect(classification.ValidatePercentageConstraint(percentage)).To(Succeed())
+
+		invalid := 101
+		err := classification.ValidatePercentageConstraint(&classification.PercentageConstraint{MinPercent: &invalid})
+		Expect(classification.IsInvalidClassifierError(err)).To(BeTrue())
+
+		err = classification.ValidatePercentageConstraint(&classification.PercentageConstraint{
+			MinPercent: &maxPercent, MaxPercent: &minPercent})
+		Expect(classification.IsInvalidClassifierError(err)).To(BeTrue())
+	})
+
+	It("getPercentageStopCondition stops once percentage is known to be within or outside bounds", func() {
+		minPercent := 50
+		percentage := &classification.PercentageConstraint{MinPercent: &minPercent}
+		Expect(classification.GetPercentageStopCondition(percentage)).To(BeNil())
+
+		percentage.Sampling = true
+		stop := classification.GetPercentageStopCondition(percentage)
+
+		// Not enough resources listed
+		Expect(stop(0, 50)).To(BeFalse())
+		// Clearly above and clearly below 50%
+		Expect(stop(950, 1000)).To(BeTrue())
+		Expect(stop(50, 1000)).To(BeTrue())
+		// Too close to 50% to decide
+		Expect(stop(510, 1000)).To(BeFalse())
+		Expect(stop(5100, 10000)).To(BeFalse())
+		Expect(stop(56000, 100000)).To(BeTrue())
+	})
+})
@@ -0,0 +1,225 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+// PodSecurityLevel is a Pod Security Standards level
+type PodSecurityLevel string
+
+const (
+	PodSecurityPrivileged = PodSecurityLevel("privileged")
+	PodSecurityBaseline   = PodSecurityLevel("baseline")
+	PodSecurityRestricted = PodSecurityLevel("restricted")
+)
+
+// PodSecurityMode is the PodSecurity admission mode a level applies to
+type PodSecurityMode string
+
+const (
+	PodSecurityEnforce = PodSecurityMode("enforce")
+	PodSecurityAudit   = PodSecurityMode("audit")
+	PodSecurityWarn    = PodSecurityMode("warn")
+)
+
+const (
+	// podSecurityLabelPrefix prefixes the namespace labels setting a PodSecurity
+	// admission level, for instance pod-security.kubernetes.io/enforce
+	podSecurityLabelPrefix = "pod-security.kubernetes.io/"
+
+	// PodSecurityDefaultFactPrefix prefixes the facts, in the FactsConfigMap, declaring
+	// the cluster default level of a mode, for instance pod-security-default-enforce=baseline.
+	// The PodSecurity admission configuration is an API server file, so the cluster
+	// default cannot be read from the cluster. Kubernetes default, privileged, is used
+	// when no fact is declared.
+	PodSecurityDefaultFactPrefix = "pod-security-default-"
+)
+
+var namespaceGVK = schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}
+
+// podSecurityLevelStrictness orders levels from the least to the most strict
+var podSecurityLevelStrictness = map[PodSecurityLevel]int{
+	PodSecurityPrivileged: 0,
+	PodSecurityBaseline:   1,
+	PodSecurityRestricted: 2,
+}
+
+// PodSecurityConstraint is satisfied when the PodSecurity admission level of all
+// selected namespaces is within MinLevel and MaxLevel. A namespace without level
+// label has the cluster default level.
+type PodSecurityConstraint struct {
+	// Mode is enforce, audit or warn. Default is enforce.
+	// +optional
+	Mode PodSecurityMode `json:"mode,omitempty"`
+
+	// MinLevel is the least strict level allowed, for instance baseline
+	// +optional
+	MinLevel PodSecurityLevel `json:"minLevel,omitempty"`
+
+	// MaxLevel is the most strict level allowed
+	// +optional
+	MaxLevel PodSecurityLevel `json:"maxLevel,omitempty"`
+
+	// Namespaces restricts the constraint to these namespaces. All namespaces when empty.
+	// Namespaces which do not exist are ignored.
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// ExcludeNamespaces are ignored, for instance kube-system, usually exempted
+	// +optional
+	ExcludeNamespaces []string `json:"excludeNamespaces,omitempty"`
+}
+
+func (c *PodSecurityConstraint) getMode() PodSecurityMode {
+	if c.Mode == "" {
+		return PodSecurityEnforce
+	}
+	return c.Mode
+}
+
+// validatePodSecurityConstraint returns an error if c is not valid
+func validatePodSecurityConstraint(c *PodSecurityConstraint) error {
+	switch c.getMode() {
+	case PodSecurityEnforce, PodSecurityAudit, PodSecurityWarn:
+	default:
+		return fmt.Errorf("mode must be %s, %s or %s, got %q", PodSecurityEnforce, PodSecurityAudit,
+			PodSecurityWarn, c.Mode)
+	}
+	if c.MinLevel == "" && c.MaxLevel == "" {
+		return fmt.Errorf("at least one of minLevel and maxLevel is required")
+	}
+	for _, level := range []PodSecurityLevel{c.MinLevel, c.MaxLevel} {
+		if _, ok := podSecurityLevelStrictness[level]; level != "" && !ok {
+			return fmt.Errorf("level must be %s, %s or %s, got %q", PodSecurityPrivileged, PodSecurityBaseline,
+				PodSecurityRestricted, level)
+		}
+	}
+	if c.MinLevel != "" && c.MaxLevel != "" &&
+		podSecurityLevelStrictness[c.MinLevel] > podSecurityLevelStrictness[c.MaxLevel] {
+
+		return fmt.Errorf("minLevel %s is stricter than maxLevel %s", c.MinLevel, c.MaxLevel)
+	}
+	return nil
+}
+
+// arePodSecurityConstraintsAMatch returns true if all PodSecurityConstraints are satisfied
+func (m *manager) arePodSecurityConstraintsAMatch(ctx context.Context,
+	classifier *libsveltosv1alpha1.Classifier) (bool, error) {
+
+	extension, err := getClassifierExtension(classifier)
+	if err != nil {
+		return false, err
+	}
+
+	if len(extension.PodSecurityConstraints) == 0 {
+		return true, nil
+	}
+
+	namespaces := &corev1.NamespaceList{}
+	if err := m.List(ctx, namespaces); err != nil {
+		return false, err
+	}
+	facts, err := m.getFacts(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	for i := range extension.PodSecurityConstraints {
+		c := &extension.PodSecurityConstraints[i]
+		if err := validatePodSecurityConstraint(c); err != nil {
+			return false, newInvalidClassifierError(fmt.Errorf("pod security constraint %d: %w", i, err))
+		}
+
+		defaultLevel := getPodSecurityDefaultLevel(facts, c.getMode())
+		for j := range namespaces.Items {
+			ns := &namespaces.Items[j]
+			if !isPodSecurityNamespaceSelected(c, ns.Name) {
+				continue
+			}
+			level := getNamespacePodSecurityLevel(ns, c.getMode(), defaultLevel)
+			if !isPodSecurityLevelInRange(level, c.MinLevel, c.MaxLevel) {
+				addEvaluationNote(ctx, fmt.Sprintf("pod security constraint %d: namespace %s %s level is %s",
+					i, ns.Name, c.getMode(), level))
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
+
+// getPodSecurityDefaultLevel returns the cluster default level of mode, as declared
+// in facts. Privileged when not declared or not valid.
+func getPodSecurityDefaultLevel(facts map[string]string, mode PodSecurityMode) PodSecurityLevel {
+	level := PodSecurityLevel(facts[PodSecurityDefaultFactPrefix+string(mode)])
+	if _, ok := podSecurityLevelStrictness[level]; !ok {
+		return PodSecurityPrivileged
+	}
+	return level
+}
+
+// getNamespacePodSecurityLevel returns the level of mode set on namespace, defaultLevel
+// when not set. As PodSecurity admission does, an invalid level is considered restricted.
+func getNamespacePodSecurityLevel(ns *corev1.Namespace, mode PodSecurityMode,
+	defaultLevel PodSecurityLevel) PodSecurityLevel {
+
+	value, ok := ns.Labels[podSecurityLabelPrefix+string(mode)]
+	if !ok {
+		return defaultLevel
+	}
+	level := PodSecurityLevel(value)
+	if _, ok := podSecurityLevelStrictness[level]; !ok {
+		return PodSecurityRestricted
+	}
+	return level
+}
+
+func isPodSecurityNamespaceSelected(c *PodSecurityConstraint, namespace string) bool {
+	for i := range c.ExcludeNamespaces {
+		if c.ExcludeNamespaces[i] == namespace {
+			return false
+		}
+	}
+	if len(c.Namespaces) == 0 {
+		return true
+	}
+	for i := range c.Namespaces {
+		if c.Namespaces[i] == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// isPodSecurityLevelInRange returns true if level is at least as strict as minLevel
+// and at most as strict as maxLevel. Empty bounds are not verified.
+func isPodSecurityLevelInRange(level, minLevel, maxLevel PodSecurityLevel) bool {
+	if minLevel != "" && podSecurityLevelStrictness[level] < podSecurityLevelStrictness[minLevel] {
+		return false
+	}
+	if maxLevel != "" && podSecurityLevelStrictness[level] > podSecurityLevelStrictness[maxLevel] {
+		return false
+	}
+	return true
+}
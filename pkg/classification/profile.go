@@ -0,0 +1,116 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+// ReportVerbosity controls how much classifier-agent adds to ClassifierReports
+type ReportVerbosity string
+
+const (
+	// ReportVerbosityFull sets the explanation annotation on ClassifierReports
+	ReportVerbosityFull = ReportVerbosity("full")
+
+	// ReportVerbosityMinimal omits the explanation annotation
+	ReportVerbosityMinimal = ReportVerbosity("minimal")
+)
+
+// DefaultSveltosPollInterval is the interval at which Classifiers are evaluated in
+// clusters registered with Sveltos, which are not watched by default
+const DefaultSveltosPollInterval = 5 * time.Minute
+
+// BehaviorProfile groups behavior defaults. The profile of the cluster type passed
+// to InitializeManager is applied before any Option, so options always take precedence.
+// Zero fields keep the current behavior.
+type BehaviorProfile struct {
+	// Interval, when positive, overrides the evaluation interval passed to InitializeManager
+	Interval time.Duration
+
+	// PollInterval, when positive, disables resource watches: all Classifiers are
+	// instead evaluated every PollInterval. Watches react to changes as they happen
+	// but keep an informer per watched resource type.
+	PollInterval time.Duration
+
+	// ReportVerbosity is full or minimal
+	ReportVerbosity ReportVerbosity
+}
+
+// defaultBehaviorProfiles contains the profile of each cluster type. CAPI provisioned
+// clusters are fully managed, so resources are watched and reports are verbose.
+// Clusters registered with Sveltos are often small or edge clusters: resources are
+// polled and reports are minimal. Any other cluster type keeps the defaults.
+var defaultBehaviorProfiles = map[libsveltosv1alpha1.ClusterType]BehaviorProfile{
+	libsveltosv1alpha1.ClusterTypeCapi: {
+		ReportVerbosity: ReportVerbosityFull,
+	},
+	libsveltosv1alpha1.ClusterTypeSveltos: {
+		PollInterval:    DefaultSveltosPollInterval,
+		ReportVerbosity: ReportVerbosityMinimal,
+	},
+}
+
+// GetDefaultBehaviorProfile returns the profile applied to clusters of type clusterType
+func GetDefaultBehaviorProfile(clusterType libsveltosv1alpha1.ClusterType) BehaviorProfile {
+	for known, profile := range defaultBehaviorProfiles {
+		if strings.EqualFold(string(known), string(clusterType)) {
+			return profile
+		}
+	}
+	return BehaviorProfile{}
+}
+
+// ValidateReportVerbosity returns an error if verbosity is not full or minimal
+func ValidateReportVerbosity(verbosity ReportVerbosity) error {
+	if verbosity != ReportVerbosityFull && verbosity != ReportVerbosityMinimal {
+		return fmt.Errorf("report verbosity must be %s or %s, got %q", ReportVerbosityFull,
+			ReportVerbosityMinimal, verbosity)
+	}
+	return nil
+}
+
+// applyBehaviorProfile sets the non zero fields of profile
+func (m *manager) applyBehaviorProfile(profile BehaviorProfile) {
+	if profile.Interval > 0 {
+		m.interval = profile.Interval
+	}
+	if profile.PollInterval > 0 {
+		m.pollInterval = profile.PollInterval
+	}
+	switch profile.ReportVerbosity {
+	case ReportVerbosityFull, ReportVerbosityMinimal:
+		m.reportVerbosity = profile.ReportVerbosity
+	}
+}
+
+// isPolling returns true if resources are not watched and Classifiers are instead
+// evaluated every pollInterval
+func (m *manager) isPolling() bool {
+	return m.pollInterval > 0
+}
+
+// pollClassifiers queues all Classifiers every pollInterval
+func (m *manager) pollClassifiers(ctx context.Context) {
+	m.resyncClassifiers(ctx, m.pollInterval,
+		func(classifier *libsveltosv1alpha1.Classifier) bool { return true })
+}
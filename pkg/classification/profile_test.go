@@ -0,0 +1,88 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/projectsveltos/classifier-agent/internal/utils"
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	"github.com/projectsveltos/classifier-agent/pkg/explanation"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: behavior profiles", func() {
+
+	BeforeEach(func() {
+		classification.Reset()
+	})
+
+	It("GetDefaultBehaviorProfile polls registered clusters and watches CAPI ones", func() {
+		profile := classification.GetDefaultBehaviorProfile(libsveltosv1alpha1.ClusterTypeCapi)
+		Expect(profile.PollInterval).To(BeZero())
+		Expect(profile.ReportVerbosity).To(Equal(classification.ReportVerbosityFull))
+
+		profile = classification.GetDefaultBehaviorProfile(libsveltosv1alpha1.ClusterType("sveltos"))
+		Expect(profile.PollInterval).To(Equal(classification.DefaultSveltosPollInterval))
+		Expect(profile.ReportVerbosity).To(Equal(classification.ReportVerbosityMinimal))
+
+		Expect(classification.GetDefaultBehaviorProfile(libsveltosv1alpha1.ClusterType("edge"))).
+			To(Equal(classification.BehaviorProfile{}))
+
+		Expect(classification.ValidateReportVerbosity(classification.ReportVerbosityMinimal)).To(Succeed())
+		Expect(classification.ValidateReportVerbosity("verbose")).ToNot(Succeed())
+	})
+
+	It("WithBehaviorProfile minimal verbosity omits explanation", func() {
+		classifier := getClassifierWithKubernetesConstraints(version25, libsveltosv1alpha1.ComparisonEqual)
+
+		c := classification.InitializeManagerWithObjects(nil, classifier)
+		classification.ApplyOptions(classification.WithEvaluationSummary(true),
+			classification.WithBehaviorProfile(classification.BehaviorProfile{
+				PollInterval:    time.Minute,
+				ReportVerbosity: classification.ReportVerbosityMinimal,
+			}))
+		manager := classification.GetManager()
+
+		pollInterval, summary := classification.GetBehavior()
+		Expect(pollInterval).To(Equal(time.Minute))
+		// Evaluation summary is only controlled by WithEvaluationSummary
+		Expect(summary).To(BeTrue())
+
+		classification.RecordExplanation(manager, classifier.Name, &explanation.Explanation{
+			Match:  true,
+			Checks: []explanation.Check{{Type: explanation.CheckKubernetesVersion, Satisfied: true}},
+		})
+		Expect(classification.CreateClassifierReport(manager, context.TODO(), classifier, true, nil)).
+			To(Succeed())
+		classifierReport := &libsveltosv1alpha1.ClassifierReport{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: utils.ReportNamespace, Name: classifier.Name},
+			classifierReport)).To(Succeed())
+		Expect(classifierReport.Annotations).ToNot(HaveKey(explanation.Annotation))
+
+		// Zero fields keep the current behavior
+		classification.ApplyOptions(classification.WithBehaviorProfile(classification.BehaviorProfile{}))
+		pollInterval, _ = classification.GetBehavior()
+		Expect(pollInterval).To(Equal(time.Minute))
+	})
+})
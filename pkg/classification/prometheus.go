@@ -0,0 +1,310 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+)
+
+// DefaultPrometheusResyncInterval is the default interval at which Classifiers with
+// PrometheusConstraints are evaluated again
+const DefaultPrometheusResyncInterval = time.Minute
+
+// PrometheusEndpoint is the in-cluster Prometheus PrometheusConstraints are evaluated against
+type PrometheusEndpoint struct {
+	// URL is the Prometheus base URL, for instance http://prometheus.monitoring:9090
+	URL string
+
+	// BearerTokenFile, when set, contains the token sent with each query. File is
+	// read before each query so that rotated tokens are used.
+	BearerTokenFile string
+
+	// HTTPClient is used to query Prometheus. http.DefaultClient when nil.
+	HTTPClient *http.Client
+}
+
+// prometheusEndpoint contains what is needed to evaluate PrometheusConstraints
+type prometheusEndpoint struct {
+	PrometheusEndpoint
+	// resyncInterval is the interval at which Classifiers with PrometheusConstraints
+	// are queued. Metrics change without any resource being updated.
+	resyncInterval time.Duration
+}
+
+// PrometheusConstraint compares the result of a PromQL instant query with a threshold,
+// for instance the error rate of an ingress controller above 0.05.
+// When query returns a vector, all its samples must satisfy the comparison. An empty
+// vector never satisfies it.
+type PrometheusConstraint struct {
+	// Query is the PromQL instant query. It must return a scalar or a vector.
+	Query string `json:"query"`
+
+	// Operation compares query result with Value: GreaterThan, GreaterThanOrEqual,
+	// LessThan or LessThanOrEqual
+	Operation libsveltosv1alpha1.Operation `json:"operation"`
+
+	// Value is the threshold, a number
+	Value string `json:"value"`
+}
+
+// prometheusResponse is the Prometheus HTTP API response to an instant query
+type prometheusResponse struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Data      struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+// prometheusBadData is the errorType Prometheus returns for an invalid query
+const prometheusBadData = "bad_data"
+
+// validatePrometheusConstraint returns the threshold of c, or an error if c is not valid
+func validatePrometheusConstraint(c *PrometheusConstraint) (float64, error) {
+	if c.Query == "" {
+		return 0, fmt.Errorf("query is required")
+	}
+	if !isComparisonOperation(c.Operation) {
+		return 0, fmt.Errorf("unsupported operation %q", c.Operation)
+	}
+	threshold, err := strconv.ParseFloat(c.Value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("value %q is not a number", c.Value)
+	}
+	return threshold, nil
+}
+
+// arePrometheusConstraintsAMatch returns true if all PrometheusConstraints are satisfied.
+// Those are not satisfied when no Prometheus endpoint is configured.
+func (m *manager) arePrometheusConstraintsAMatch(ctx context.Context,
+	classifier *libsveltosv1alpha1.Classifier) (bool, error) {
+
+	extension, err := getClassifierExtension(classifier)
+	if err != nil {
+		return false, err
+	}
+
+	if len(extension.PrometheusConstraints) == 0 {
+		return true, nil
+	}
+
+	if m.prometheus == nil {
+		addEvaluationNote(ctx, "prometheus constraints: no Prometheus endpoint configured")
+		return false, nil
+	}
+
+	for i := range extension.PrometheusConstraints {
+		c := &extension.PrometheusConstraints[i]
+		threshold, err := validatePrometheusConstraint(c)
+		if err != nil {
+			return false, newInvalidClassifierError(fmt.Errorf("prometheus constraint %d: %w", i, err))
+		}
+
+		values, err := m.prometheus.query(ctx, c.Query)
+		if err != nil {
+			return false, errors.Wrap(err, fmt.Sprintf("prometheus constraint %d", i))
+		}
+
+		addEvaluationNote(ctx, fmt.Sprintf("prometheus constraint %d: %s", i, formatPrometheusValues(values)))
+		if len(values) == 0 {
+			return false, nil
+		}
+		for j := range values {
+			if !compareFloat(values[j], c.Operation, threshold) {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
+
+// compareFloat returns true if value compares to reference as required by operation
+func compareFloat(value float64, operation libsveltosv1alpha1.Operation, reference float64) bool {
+	switch operation {
+	case OperationGreaterThan:
+		return value > reference
+	case OperationGreaterThanOrEqual:
+		return value >= reference
+	case OperationLessThan:
+		return value < reference
+	case OperationLessThanOrEqual:
+		return value <= reference
+	default:
+		return false
+	}
+}
+
+func formatPrometheusValues(values []float64) string {
+	if len(values) == 0 {
+		return "no sample"
+	}
+	formatted := make([]string, len(values))
+	for i := range values {
+		formatted[i] = strconv.FormatFloat(values[i], 'g', -1, 64)
+	}
+	return strings.Join(formatted, ", ")
+}
+
+// query runs a PromQL instant query and returns the sample values. An invalid
+// query is reported as an invalid Classifier.
+func (p *prometheusEndpoint) query(ctx context.Context, query string) ([]float64, error) {
+	queryURL, err := url.Parse(strings.TrimSuffix(p.URL, "/") + "/api/v1/query")
+	if err != nil {
+		return nil, err
+	}
+	queryURL.RawQuery = url.Values{"query": []string{query}}.Encode()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, queryURL.String(), http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	if p.BearerTokenFile != "" {
+		token, err := os.ReadFile(p.BearerTokenFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read Prometheus bearer token")
+		}
+		request.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	httpClient := p.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	result := &prometheusResponse{}
+	if err := json.NewDecoder(response.Body).Decode(result); err != nil {
+		return nil, fmt.Errorf("unexpected Prometheus response (%s): %w", response.Status, err)
+	}
+	if result.Status != "success" {
+		err := fmt.Errorf("prometheus query failed (%s): %s", result.ErrorType, result.Error)
+		if result.ErrorType == prometheusBadData {
+			return nil, newInvalidClassifierError(err)
+		}
+		return nil, err
+	}
+
+	return getPrometheusValues(result.Data.ResultType, result.Data.Result)
+}
+
+// getPrometheusValues returns the values of a scalar or vector query result
+func getPrometheusValues(resultType string, result json.RawMessage) ([]float64, error) {
+	switch resultType {
+	case "scalar":
+		var sample []interface{}
+		if err := json.Unmarshal(result, &sample); err != nil {
+			return nil, err
+		}
+		value, err := parsePrometheusSample(sample)
+		if err != nil {
+			return nil, err
+		}
+		return []float64{value}, nil
+	case "vector":
+		var samples []struct {
+			Value []interface{} `json:"value"`
+		}
+		if err := json.Unmarshal(result, &samples); err != nil {
+			return nil, err
+		}
+		values := make([]float64, len(samples))
+		for i := range samples {
+			value, err := parsePrometheusSample(samples[i].Value)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		return values, nil
+	default:
+		return nil, newInvalidClassifierError(
+			fmt.Errorf("prometheus query returned a %s. Only scalar and vector are supported", resultType))
+	}
+}
+
+// parsePrometheusSample parses a [timestamp, "value"] sample
+func parsePrometheusSample(sample []interface{}) (float64, error) {
+	if len(sample) != 2 {
+		return 0, fmt.Errorf("unexpected Prometheus sample %v", sample)
+	}
+	value, ok := sample[1].(string)
+	if !ok {
+		return 0, fmt.Errorf("unexpected Prometheus sample value %v", sample[1])
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+// hasPrometheusConstraints returns true if classifier has PrometheusConstraints
+func hasPrometheusConstraints(classifier *libsveltosv1alpha1.Classifier) bool {
+	extension, err := getClassifierExtension(classifier)
+	if err != nil {
+		return false
+	}
+	return len(extension.PrometheusConstraints) != 0
+}
+
+// resyncPrometheusClassifiers periodically queues Classifiers with PrometheusConstraints,
+// since metrics change without any watched resource changing
+func (m *manager) resyncPrometheusClassifiers(ctx context.Context) {
+	m.resyncClassifiers(ctx, m.prometheus.resyncInterval, hasPrometheusConstraints)
+}
+
+// resyncClassifiers queues, every interval, all Classifiers for which needsResync
+// returns true
+func (m *manager) resyncClassifiers(ctx context.Context, interval time.Duration,
+	needsResync func(classifier *libsveltosv1alpha1.Classifier) bool) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			classifiers, err := m.ListClassifiers(ctx)
+			if err != nil {
+				m.log.V(logs.LogInfo).Info(fmt.Sprintf("failed to list classifiers: %v", err))
+				continue
+			}
+			for i := range classifiers.Items {
+				if needsResync(&classifiers.Items[i]) {
+					m.EvaluateClassifier(classifiers.Items[i].Name)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: prometheus constraints", func() {
+	var server *httptest.Server
+	var authorization string
+	var classifier *libsveltosv1alpha1.Classifier
+
+	BeforeEach(func() {
+		authorization = ""
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authorization = r.Header.Get("Authorization")
+			w.Header().Set("Content-Type", "application/json")
+			if r.URL.Path != "/api/v1/query" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			switch r.URL.Query().Get("query") {
+			case "scalar(ingress_error_rate)":
+				_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"scalar",` +
+					`"result":[1672531200,"0.07"]}}`))
+			case "node_load":
+				_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[` +
+					`{"metric":{"node":"a"},"value":[1672531200,"0.5"]},` +
+					`{"metric":{"node":"b"},"value":[1672531200,"0.9"]}]}}`))
+			case "absent_metric":
+				_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+			default:
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"status":"error","errorType":"bad_data","error":"parse error"}`))
+			}
+		}))
+
+		classifier = &libsveltosv1alpha1.Classifier{ObjectMeta: metav1.ObjectMeta{Name: randomString()}}
+
+		classification.InitializeManagerWithObjects(nil)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	evaluate := func(prometheusConstraints string) (bool, []string, error) {
+		classifier.Annotations = map[string]string{
+			classification.ClassifierExtensionAnnotation: "prometheusConstraints:\n" + prometheusConstraints,
+		}
+		return classification.ArePrometheusConstraintsAMatch(classification.GetManager(), classifier)
+	}
+
+	It("arePrometheusConstraintsAMatch is not satisfied when no Prometheus is configured", func() {
+		isMatch, notes, err := evaluate(`- {query: node_load, operation: LessThan, value: "1"}`)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeFalse())
+		Expect(notes).To(ContainElement("prometheus constraints: no Prometheus endpoint configured"))
+	})
+
+	It("arePrometheusConstraintsAMatch compares query results with threshold", func() {
+		classification.ApplyOptions(classification.WithPrometheus(
+			&classification.PrometheusEndpoint{URL: server.URL}, 0))
+
+		isMatch, notes, err := evaluate(`- {query: "scalar(ingress_error_rate)", operation: GreaterThan, value: "0.05"}`)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+		Expect(notes).To(ContainElement("prometheus constraint 0: 0.07"))
+
+		// All samples must satisfy the comparison
+		isMatch, _, err = evaluate(`- {query: node_load, operation: LessThan, value: "1"}`)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+		isMatch, notes, err = evaluate(`- {query: node_load, operation: LessThan, value: "0.8"}`)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeFalse())
+		Expect(notes).To(ContainElement("prometheus constraint 0: 0.5, 0.9"))
+
+		isMatch, notes, err = evaluate(`- {query: absent_metric, operation: LessThan, value: "1"}`)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeFalse())
+		Expect(notes).To(ContainElement("prometheus constraint 0: no sample"))
+	})
+
+	It("arePrometheusConstraintsAMatch sends bearer token", func() {
+		tokenFile := filepath.Join(GinkgoT().TempDir(), "token")
+		Expect(os.WriteFile(tokenFile, []byte("secret\n"), 0o600)).To(Succeed())
+		classification.ApplyOptions(classification.WithPrometheus(
+			&classification.PrometheusEndpoint{URL: server.URL, BearerTokenFile: tokenFile}, 0))
+
+		_, _, err := evaluate(`- {query: node_load, operation: LessThan, value: "1"}`)
+		Expect(err).To(BeNil())
+		Expect(authorization).To(Equal("Bearer secret"))
+	})
+
+	It("arePrometheusConstraintsAMatch rejects invalid constraints", func() {
+		classification.ApplyOptions(classification.WithPrometheus(
+			&classification.PrometheusEndpoint{URL: server.URL}, 0))
+
+		_, _, err := evaluate(`- {query: node_load, operation: Equal, value: "1"}`)
+		Expect(err).ToNot(BeNil())
+
+		_, _, err = evaluate(`- {query: node_load, operation: LessThan, value: high}`)
+		Expect(err).ToNot(BeNil())
+
+		// Invalid query
+		_, _, err = evaluate(`- {query: "sum(", operation: LessThan, value: "1"}`)
+		Expect(err).ToNot(BeNil())
+		Expect(classification.IsInvalidClassifierError(err)).To(BeTrue())
+	})
+})
@@ -0,0 +1,93 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"fmt"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+// validateRatioConstraint verifies selections set no bound of their own and ratio
+// bounds are not negative, MinPercent being not greater than MaxPercent
+func validateRatioConstraint(ratio *RatioConstraint) error {
+	for name, selection := range map[string]*ResourceConstraint{
+		"numerator": &ratio.Numerator, "denominator": &ratio.Denominator} {
+
+		if selection.MinCount != nil || selection.MaxCount != nil || selection.Percentage != nil ||
+			selection.Trend != nil || selection.Cardinality != nil || selection.MustNotExist {
+
+			return fmt.Errorf("ratio %s cannot set minCount, maxCount, percentage, trend, cardinality or mustNotExist",
+				name)
+		}
+	}
+
+	if ratio.MinPercent != nil && *ratio.MinPercent < 0 {
+		return fmt.Errorf("ratio minPercent %d is negative", *ratio.MinPercent)
+	}
+	if ratio.MaxPercent != nil && *ratio.MaxPercent < 0 {
+		return fmt.Errorf("ratio maxPercent %d is negative", *ratio.MaxPercent)
+	}
+	if ratio.MinPercent != nil && ratio.MaxPercent != nil && *ratio.MinPercent > *ratio.MaxPercent {
+		return fmt.Errorf("ratio minPercent %d is greater than maxPercent %d", *ratio.MinPercent, *ratio.MaxPercent)
+	}
+	return nil
+}
+
+// areRatioConstraintsAMatch returns true if all RatioConstraints are satisfied
+func (m *manager) areRatioConstraintsAMatch(ctx context.Context, classifier *libsveltosv1alpha1.Classifier,
+	extension *ClassifierExtension, snapshot *evaluationSnapshot) (bool, error) {
+
+	for i := range extension.RatioConstraints {
+		isMatch, err := m.isRatioConstraintAMatch(ctx, classifier, &extension.RatioConstraints[i], snapshot)
+		if err != nil || !isMatch {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// isRatioConstraintAMatch counts the resources of both selections and verifies their
+// ratio is within bounds. A selection whose group/version/kind is not served by the
+// cluster counts zero resources. When the denominator is zero, the ratio is zero.
+func (m *manager) isRatioConstraintAMatch(ctx context.Context, classifier *libsveltosv1alpha1.Classifier,
+	ratio *RatioConstraint, snapshot *evaluationSnapshot) (bool, error) {
+
+	if err := validateRatioConstraint(ratio); err != nil {
+		return false, newInvalidClassifierError(err)
+	}
+
+	numerator, _, _, err := m.countResources(ctx, classifier, &ratio.Numerator, snapshot, nil)
+	if err != nil {
+		return false, err
+	}
+	denominator, _, _, err := m.countResources(ctx, classifier, &ratio.Denominator, snapshot, nil)
+	if err != nil {
+		return false, err
+	}
+
+	value := 0.0
+	if denominator > 0 {
+		value = float64(numerator) * percentMax / float64(denominator)
+	}
+	addEvaluationNote(ctx, fmt.Sprintf("ratio %s/%s: %d out of %d (%.1f%%)",
+		ratio.Numerator.Kind, ratio.Denominator.Kind, numerator, denominator, value))
+
+	percentage := &PercentageConstraint{MinPercent: ratio.MinPercent, MaxPercent: ratio.MaxPercent}
+	return isPercentageAMatch(percentage, numerator, denominator), nil
+}
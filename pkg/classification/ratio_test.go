@@ -0,0 +1,117 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: ratio constraints", func() {
+	var server *httptest.Server
+	var classifier *libsveltosv1alpha1.Classifier
+
+	BeforeEach(func() {
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			switch r.URL.Path {
+			case "/api":
+				_, _ = w.Write([]byte(`{"kind":"APIVersions","versions":[]}`))
+			case "/apis":
+				_, _ = w.Write([]byte(`{"kind":"APIGroupList","apiVersion":"v1","groups":[{"name":"example.com",` +
+					`"versions":[{"groupVersion":"example.com/v1","version":"v1"}],` +
+					`"preferredVersion":{"groupVersion":"example.com/v1","version":"v1"}}]}`))
+			case "/apis/example.com/v1":
+				_, _ = w.Write([]byte(`{"kind":"APIResourceList","apiVersion":"v1","groupVersion":"example.com/v1",` +
+					`"resources":[{"name":"widgets","singularName":"widget","namespaced":false,"kind":"Widget",` +
+					`"verbs":["list"]}]}`))
+			case "/apis/example.com/v1/widgets":
+				_, _ = w.Write([]byte(`{"apiVersion":"example.com/v1","kind":"WidgetList","metadata":{},"items":[` +
+					`{"apiVersion":"example.com/v1","kind":"Widget","metadata":{"name":"a"},"status":{"ready":true}},` +
+					`{"apiVersion":"example.com/v1","kind":"Widget","metadata":{"name":"b"},"status":{"ready":true}},` +
+					`{"apiVersion":"example.com/v1","kind":"Widget","metadata":{"name":"c"},"status":{"ready":true}},` +
+					`{"apiVersion":"example.com/v1","kind":"Widget","metadata":{"name":"d"},"status":{"ready":false}}]}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+
+		classifier = &libsveltosv1alpha1.Classifier{ObjectMeta: metav1.ObjectMeta{Name: randomString()}}
+
+		classification.InitializeManagerWithObjects(&rest.Config{Host: server.URL})
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	getRatioConstraint := func(minPercent, maxPercent *int) *classification.RatioConstraint {
+		widgets := libsveltosv1alpha1.DeployedResourceConstraint{Group: "example.com", Version: "v1", Kind: "Widget"}
+		return &classification.RatioConstraint{
+			Numerator: classification.ResourceConstraint{
+				DeployedResourceConstraint: widgets,
+				Expression:                 "object.status.ready == true",
+			},
+			Denominator: classification.ResourceConstraint{DeployedResourceConstraint: widgets},
+			MinPercent:  minPercent,
+			MaxPercent:  maxPercent,
+		}
+	}
+
+	It("isRatioConstraintAMatch compares the counts of two selections", func() {
+		minPercent := 75
+		isMatch, notes, err := classification.IsRatioConstraintAMatch(classification.GetManager(), classifier,
+			getRatioConstraint(&minPercent, nil))
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+		Expect(notes).To(Equal([]string{"ratio Widget/Widget: 3 out of 4 (75.0%)"}))
+
+		maxPercent := 50
+		isMatch, _, err = classification.IsRatioConstraintAMatch(classification.GetManager(), classifier,
+			getRatioConstraint(nil, &maxPercent))
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeFalse())
+	})
+
+	It("isRatioConstraintAMatch considers the ratio zero when the denominator is empty", func() {
+		maxPercent := 0
+		ratio := getRatioConstraint(nil, &maxPercent)
+		// Gadget is not served by the cluster
+		ratio.Denominator.Kind = "Gadget"
+		isMatch, _, err := classification.IsRatioConstraintAMatch(classification.GetManager(), classifier, ratio)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+	})
+
+	It("isRatioConstraintAMatch rejects selections with bounds", func() {
+		minCount := 1
+		ratio := getRatioConstraint(nil, nil)
+		ratio.Denominator.MinCount = &minCount
+		_, _, err := classification.IsRatioConstraintAMatch(classification.GetManager(), classifier, ratio)
+		Expect(err).ToNot(BeNil())
+		Expect(classification.IsInvalidClassifierError(err)).To(BeTrue())
+	})
+})
@@ -0,0 +1,363 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+const (
+	// EffectiveRBACPath is the path the effective RBAC debug endpoint is served at
+	EffectiveRBACPath = "/debug/rbac"
+
+	// ClassifierReportEffectiveRBACAnnotation, when enabled, is set on ClassifierReports
+	// in the managed cluster only. Value is the JSON encoded list of AccessRecords
+	// of the last evaluation.
+	ClassifierReportEffectiveRBACAnnotation = "classifier.projectsveltos.io/effective-rbac"
+
+	// effectiveRBACClusterRoleName is the name of the ClusterRole served by the
+	// effective RBAC debug endpoint
+	effectiveRBACClusterRoleName = "classifier-agent-evaluation"
+)
+
+// AccessRecord is an API server access a Classifier evaluation required.
+// Requests served by the local cache are not recorded.
+type AccessRecord struct {
+	// Verb is the Kubernetes verb, for instance list, or the lowercase HTTP method
+	// for NonResourceURL
+	Verb string `json:"verb"`
+
+	// APIGroup of Resource. Empty for the core group
+	// +optional
+	APIGroup string `json:"apiGroup,omitempty"`
+
+	// Resource, for instance "pods" or "pods/log". Empty when NonResourceURL is set.
+	// +optional
+	Resource string `json:"resource,omitempty"`
+
+	// NonResourceURL is set, instead of Resource, for requests such as discovery
+	// +optional
+	NonResourceURL string `json:"nonResourceURL,omitempty"`
+
+	// Succeeded is true if all requests succeeded. A resource not found is a success.
+	Succeeded bool `json:"succeeded"`
+
+	// Forbidden is true if API server rejected at least one request for lack of permission
+	// +optional
+	Forbidden bool `json:"forbidden,omitempty"`
+}
+
+// accessRecorder accumulates the API server accesses of one evaluation
+type accessRecorder struct {
+	mu sync.Mutex
+	// records contains one AccessRecord per verb and resource
+	// Key: AccessRecord with Succeeded and Forbidden not set
+	records map[AccessRecord]*AccessRecord
+}
+
+func newAccessRecorder() *accessRecorder {
+	return &accessRecorder{records: make(map[AccessRecord]*AccessRecord)}
+}
+
+// record adds the access made with req, whose outcome is resp or err
+func (r *accessRecorder) record(req *http.Request, resp *http.Response, err error) {
+	key := getAccessRecord(req)
+	succeeded := err == nil && (resp.StatusCode < http.StatusBadRequest || resp.StatusCode == http.StatusNotFound)
+	forbidden := err == nil && resp.StatusCode == http.StatusForbidden
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	access, ok := r.records[key]
+	if !ok {
+		access = &AccessRecord{Verb: key.Verb, APIGroup: key.APIGroup, Resource: key.Resource,
+			NonResourceURL: key.NonResourceURL, Succeeded: true}
+		r.records[key] = access
+	}
+	access.Succeeded = access.Succeeded && succeeded
+	access.Forbidden = access.Forbidden || forbidden
+}
+
+// merge adds accesses recorded by another accessRecorder
+func (r *accessRecorder) merge(accesses []AccessRecord) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range accesses {
+		key := accesses[i]
+		key.Succeeded = false
+		key.Forbidden = false
+
+		access, ok := r.records[key]
+		if !ok {
+			access = &AccessRecord{Verb: key.Verb, APIGroup: key.APIGroup, Resource: key.Resource,
+				NonResourceURL: key.NonResourceURL, Succeeded: true}
+			r.records[key] = access
+		}
+		access.Succeeded = access.Succeeded && accesses[i].Succeeded
+		access.Forbidden = access.Forbidden || accesses[i].Forbidden
+	}
+}
+
+// list returns all accesses sorted by group, resource, non resource URL and verb
+func (r *accessRecorder) list() []AccessRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := make([]AccessRecord, 0, len(r.records))
+	for _, access := range r.records {
+		result = append(result, *access)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := &result[i], &result[j]
+		if a.APIGroup != b.APIGroup {
+			return a.APIGroup < b.APIGroup
+		}
+		if a.Resource != b.Resource {
+			return a.Resource < b.Resource
+		}
+		if a.NonResourceURL != b.NonResourceURL {
+			return a.NonResourceURL < b.NonResourceURL
+		}
+		return a.Verb < b.Verb
+	})
+	return result
+}
+
+// getAccessRecord returns the verb and resource, or non resource URL, req accesses
+func getAccessRecord(req *http.Request) AccessRecord {
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+
+	var group string
+	var resourceParts []string
+	switch {
+	case len(parts) >= 3 && parts[0] == "api":
+		resourceParts = parts[2:]
+	case len(parts) >= 4 && parts[0] == "apis":
+		group = parts[1]
+		resourceParts = parts[3:]
+	default:
+		return AccessRecord{Verb: strings.ToLower(req.Method), NonResourceURL: req.URL.Path}
+	}
+
+	// namespaces/<namespace>/<resource>/<name>/<subresource>
+	if len(resourceParts) >= 3 && resourceParts[0] == "namespaces" {
+		resourceParts = resourceParts[2:]
+	}
+
+	resource := resourceParts[0]
+	named := len(resourceParts) >= 2
+	if len(resourceParts) >= 3 {
+		resource += "/" + resourceParts[2]
+	}
+
+	return AccessRecord{Verb: getRequestVerb(req, named), APIGroup: group, Resource: resource}
+}
+
+// getRequestVerb returns the Kubernetes verb of a resource request.
+// named is true if request targets a single resource.
+func getRequestVerb(req *http.Request, named bool) string {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		if watch := req.URL.Query().Get("watch"); watch == "true" || watch == "1" {
+			return "watch"
+		}
+		if named {
+			return "get"
+		}
+		return "list"
+	case http.MethodPost:
+		return "create"
+	case http.MethodPut:
+		return "update"
+	case http.MethodPatch:
+		return "patch"
+	case http.MethodDelete:
+		if named {
+			return "delete"
+		}
+		return "deletecollection"
+	default:
+		return strings.ToLower(req.Method)
+	}
+}
+
+// recordEffectiveRBAC stores the API server accesses of the last Classifier evaluation
+func (m *manager) recordEffectiveRBAC(classifierName string, accesses []AccessRecord) {
+	m.rbacMu.Lock()
+	defer m.rbacMu.Unlock()
+	m.effectiveRBAC[classifierName] = accesses
+}
+
+func (m *manager) removeEffectiveRBAC(classifierName string) {
+	m.rbacMu.Lock()
+	defer m.rbacMu.Unlock()
+	delete(m.effectiveRBAC, classifierName)
+}
+
+// GetEffectiveRBAC returns the API server accesses of the last evaluation of a Classifier
+func (m *manager) GetEffectiveRBAC(classifierName string) []AccessRecord {
+	m.rbacMu.Lock()
+	defer m.rbacMu.Unlock()
+	return m.effectiveRBAC[classifierName]
+}
+
+func (m *manager) getAllEffectiveRBAC() map[string][]AccessRecord {
+	m.rbacMu.Lock()
+	defer m.rbacMu.Unlock()
+
+	result := make(map[string][]AccessRecord, len(m.effectiveRBAC))
+	for name, accesses := range m.effectiveRBAC {
+		result[name] = accesses
+	}
+	return result
+}
+
+// setReportEffectiveRBAC sets ClassifierReportEffectiveRBACAnnotation, when enabled,
+// to the API server accesses of the last evaluation
+func (m *manager) setReportEffectiveRBAC(report *libsveltosv1alpha1.ClassifierReport,
+	classifier *libsveltosv1alpha1.Classifier) {
+
+	value := ""
+	if accesses := m.GetEffectiveRBAC(classifier.Name); m.effectiveRBACAnnotation && len(accesses) != 0 {
+		if data, err := json.Marshal(accesses); err == nil {
+			value = string(data)
+		}
+	}
+	setReportAnnotation(report, ClassifierReportEffectiveRBACAnnotation, value)
+}
+
+// getEffectiveClusterRole returns a ClusterRole granting all accesses. As classifier-agent
+// watches the resources Classifiers list, watch is granted along with list.
+func getEffectiveClusterRole(accesses map[string][]AccessRecord) *rbacv1.ClusterRole {
+	type resourceKey struct {
+		group    string
+		resource string
+	}
+	resourceVerbs := make(map[resourceKey]map[string]bool)
+	urlVerbs := make(map[string]map[string]bool)
+	add := func(verbs map[string]bool, verb string) map[string]bool {
+		if verbs == nil {
+			verbs = make(map[string]bool)
+		}
+		verbs[verb] = true
+		return verbs
+	}
+
+	for _, classifierAccesses := range accesses {
+		for i := range classifierAccesses {
+			access := &classifierAccesses[i]
+			if access.NonResourceURL != "" {
+				urlVerbs[access.NonResourceURL] = add(urlVerbs[access.NonResourceURL], access.Verb)
+				continue
+			}
+			key := resourceKey{group: access.APIGroup, resource: access.Resource}
+			resourceVerbs[key] = add(resourceVerbs[key], access.Verb)
+			if access.Verb == "list" {
+				resourceVerbs[key] = add(resourceVerbs[key], "watch")
+			}
+		}
+	}
+
+	clusterRole := &rbacv1.ClusterRole{
+		TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRole"},
+		ObjectMeta: metav1.ObjectMeta{Name: effectiveRBACClusterRoleName},
+		Rules:      []rbacv1.PolicyRule{},
+	}
+	for key, verbs := range resourceVerbs {
+		clusterRole.Rules = append(clusterRole.Rules, rbacv1.PolicyRule{
+			APIGroups: []string{key.group},
+			Resources: []string{key.resource},
+			Verbs:     getSortedKeys(verbs),
+		})
+	}
+	for url, verbs := range urlVerbs {
+		clusterRole.Rules = append(clusterRole.Rules, rbacv1.PolicyRule{
+			NonResourceURLs: []string{url},
+			Verbs:           getSortedKeys(verbs),
+		})
+	}
+	sort.Slice(clusterRole.Rules, func(i, j int) bool {
+		a, b := &clusterRole.Rules[i], &clusterRole.Rules[j]
+		if len(a.APIGroups) != len(b.APIGroups) {
+			// Resource rules first
+			return len(a.APIGroups) > len(b.APIGroups)
+		}
+		if len(a.APIGroups) != 0 && a.APIGroups[0] != b.APIGroups[0] {
+			return a.APIGroups[0] < b.APIGroups[0]
+		}
+		if len(a.Resources) != 0 {
+			return a.Resources[0] < b.Resources[0]
+		}
+		return a.NonResourceURLs[0] < b.NonResourceURLs[0]
+	})
+	return clusterRole
+}
+
+func getSortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// EffectiveRBACHandler returns an http.Handler serving the API server accesses of the
+// last evaluation of all Classifiers, or of the one set with the "classifier" query
+// parameter. With "format=clusterrole", a ClusterRole granting those accesses is
+// served instead, which cluster admins can use as a starting point for a
+// least-privilege Role. It only covers Classifier evaluations: permissions needed
+// to manage Classifiers and ClassifierReports must be granted as well.
+func EffectiveRBACHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m := GetManager()
+		if m == nil {
+			http.Error(w, "classification manager not initialized yet", http.StatusServiceUnavailable)
+			return
+		}
+
+		accesses := m.getAllEffectiveRBAC()
+		if name := r.URL.Query().Get("classifier"); name != "" {
+			accesses = map[string][]AccessRecord{name: m.GetEffectiveRBAC(name)}
+		}
+
+		var body interface{} = accesses
+		switch format := r.URL.Query().Get("format"); format {
+		case "":
+		case "clusterrole":
+			body = getEffectiveClusterRole(accesses)
+		default:
+			http.Error(w, "unknown format "+format, http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(body); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
@@ -0,0 +1,155 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Effective RBAC", func() {
+	It("getAccessRecord returns verb and resource of a request", func() {
+		getRecord := func(method, url string) classification.AccessRecord {
+			req, err := http.NewRequest(method, "https://cluster"+url, http.NoBody)
+			Expect(err).To(BeNil())
+			return classification.GetAccessRecord(req)
+		}
+
+		Expect(getRecord(http.MethodGet, "/api/v1/pods")).To(Equal(
+			classification.AccessRecord{Verb: "list", Resource: "pods"}))
+		Expect(getRecord(http.MethodGet, "/api/v1/namespaces/default/pods?watch=true")).To(Equal(
+			classification.AccessRecord{Verb: "watch", Resource: "pods"}))
+		Expect(getRecord(http.MethodGet, "/api/v1/namespaces/kube-system")).To(Equal(
+			classification.AccessRecord{Verb: "get", Resource: "namespaces"}))
+		Expect(getRecord(http.MethodGet, "/api/v1/namespaces/default/pods/web/log")).To(Equal(
+			classification.AccessRecord{Verb: "get", Resource: "pods/log"}))
+		Expect(getRecord(http.MethodGet, "/apis/apps/v1/namespaces/default/deployments/web")).To(Equal(
+			classification.AccessRecord{Verb: "get", APIGroup: "apps", Resource: "deployments"}))
+		Expect(getRecord(http.MethodPost, "/apis/authorization.k8s.io/v1/selfsubjectaccessreviews")).To(Equal(
+			classification.AccessRecord{Verb: "create", APIGroup: "authorization.k8s.io",
+				Resource: "selfsubjectaccessreviews"}))
+		Expect(getRecord(http.MethodGet, "/apis/metrics.k8s.io/v1beta1")).To(Equal(
+			classification.AccessRecord{Verb: "get", NonResourceURL: "/apis/metrics.k8s.io/v1beta1"}))
+		Expect(getRecord(http.MethodGet, "/version")).To(Equal(
+			classification.AccessRecord{Verb: "get", NonResourceURL: "/version"}))
+	})
+
+	It("API server accesses are recorded along with their outcome", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			switch r.URL.Path {
+			case "/api/v1/pods":
+				_, _ = w.Write([]byte(`{"apiVersion":"v1","kind":"PodList","metadata":{},"items":[]}`))
+			case "/api/v1/secrets":
+				w.WriteHeader(http.StatusForbidden)
+				_, _ = w.Write([]byte(`{"apiVersion":"v1","kind":"Status","status":"Failure","reason":"Forbidden","code":403}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(`{"apiVersion":"v1","kind":"Status","status":"Failure","reason":"NotFound","code":404}`))
+			}
+		}))
+		defer server.Close()
+
+		config := classification.WithCostTracking(&rest.Config{Host: server.URL,
+			ContentConfig: rest.ContentConfig{ContentType: "application/json"}})
+		clientset, err := kubernetes.NewForConfig(config)
+		Expect(err).To(BeNil())
+
+		ctx, getAccesses := classification.WithAccessRecorder(context.TODO())
+		_, err = clientset.CoreV1().Pods("").List(ctx, metav1.ListOptions{})
+		Expect(err).To(BeNil())
+		_, err = clientset.CoreV1().Secrets("").List(ctx, metav1.ListOptions{})
+		Expect(err).ToNot(BeNil())
+		_, err = clientset.CoreV1().ConfigMaps("default").Get(ctx, "facts", metav1.GetOptions{})
+		Expect(err).ToNot(BeNil())
+
+		// Requests not made on behalf of an evaluation are not recorded
+		_, err = clientset.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{})
+		Expect(err).ToNot(BeNil())
+
+		Expect(getAccesses()).To(Equal([]classification.AccessRecord{
+			{Verb: "get", Resource: "configmaps", Succeeded: true},
+			{Verb: "list", Resource: "pods", Succeeded: true},
+			{Verb: "list", Resource: "secrets", Succeeded: false, Forbidden: true},
+		}))
+	})
+
+	It("effective RBAC is served by the debug endpoint and set on ClassifierReports", func() {
+		classification.InitializeManagerWithObjects(nil)
+		m := classification.GetManager()
+
+		classification.RecordEffectiveRBAC(m, "gpu", []classification.AccessRecord{
+			{Verb: "list", Resource: "nodes", Succeeded: true},
+			{Verb: "get", NonResourceURL: "/apis", Succeeded: true},
+		})
+		classification.RecordEffectiveRBAC(m, "helm", []classification.AccessRecord{
+			{Verb: "list", Resource: "secrets", Forbidden: true},
+			{Verb: "get", APIGroup: "apps", Resource: "deployments", Succeeded: true},
+		})
+
+		recorder := httptest.NewRecorder()
+		classification.EffectiveRBACHandler().ServeHTTP(recorder,
+			httptest.NewRequest(http.MethodGet, classification.EffectiveRBACPath+"?classifier=gpu", http.NoBody))
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		accesses := map[string][]classification.AccessRecord{}
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &accesses)).To(Succeed())
+		Expect(accesses).To(HaveLen(1))
+		Expect(accesses["gpu"]).To(HaveLen(2))
+
+		recorder = httptest.NewRecorder()
+		classification.EffectiveRBACHandler().ServeHTTP(recorder,
+			httptest.NewRequest(http.MethodGet, classification.EffectiveRBACPath+"?format=clusterrole", http.NoBody))
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		clusterRole := &rbacv1.ClusterRole{}
+		Expect(json.Unmarshal(recorder.Body.Bytes(), clusterRole)).To(Succeed())
+		Expect(clusterRole.Kind).To(Equal("ClusterRole"))
+		Expect(clusterRole.Rules).To(Equal([]rbacv1.PolicyRule{
+			{APIGroups: []string{""}, Resources: []string{"nodes"}, Verbs: []string{"list", "watch"}},
+			{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"list", "watch"}},
+			{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"get"}},
+			{NonResourceURLs: []string{"/apis"}, Verbs: []string{"get"}},
+		}))
+
+		recorder = httptest.NewRecorder()
+		classification.EffectiveRBACHandler().ServeHTTP(recorder,
+			httptest.NewRequest(http.MethodGet, classification.EffectiveRBACPath+"?format=yaml", http.NoBody))
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+
+		classifier := &libsveltosv1alpha1.Classifier{ObjectMeta: metav1.ObjectMeta{Name: "gpu"}}
+		report := &libsveltosv1alpha1.ClassifierReport{}
+		classification.SetReportEffectiveRBAC(m, report, classifier)
+		Expect(report.Annotations).ToNot(HaveKey(classification.ClassifierReportEffectiveRBACAnnotation))
+
+		classification.ApplyOptions(classification.WithEffectiveRBACAnnotation(true))
+		classification.SetReportEffectiveRBAC(m, report, classifier)
+		Expect(report.Annotations).To(HaveKeyWithValue(classification.ClassifierReportEffectiveRBACAnnotation,
+			`[{"verb":"list","resource":"nodes","succeeded":true},{"verb":"get","nonResourceURL":"/apis","succeeded":true}]`))
+	})
+})
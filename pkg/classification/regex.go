@@ -0,0 +1,135 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"fmt"
+	"regexp"
+
+	"emperror.dev/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+const (
+	// OperationMatchRegex can be used in LabelFilters and FieldFilters of
+	// ClassifierExtension DeployedResourceConstraints. Value is a regular
+	// expression (RE2 syntax) the whole label or field value must match.
+	OperationMatchRegex = libsveltosv1alpha1.Operation("MatchRegex")
+
+	// OperationNotMatchRegex is the negation of OperationMatchRegex
+	OperationNotMatchRegex = libsveltosv1alpha1.Operation("NotMatchRegex")
+)
+
+// regexCompiler returns the compiled regular expression for a pattern
+type regexCompiler func(pattern string) (*regexp.Regexp, error)
+
+func isRegexOperation(operation libsveltosv1alpha1.Operation) bool {
+	return operation == OperationMatchRegex || operation == OperationNotMatchRegex
+}
+
+// compileRegex compiles pattern so that it only matches whole values
+func compileRegex(pattern string) (*regexp.Regexp, error) {
+	regex, err := regexp.Compile(fmt.Sprintf("^(?:%s)$", pattern))
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to compile regular expression %q", pattern))
+	}
+	return regex, nil
+}
+
+// getRegex returns the compiled regular expression for pattern.
+// Like CEL programs, regular expressions are compiled once per Classifier generation.
+// classifier is nil when constraint is not part of a Classifier: pattern is then
+// compiled on every call.
+func (m *manager) getRegex(classifier *libsveltosv1alpha1.Classifier, pattern string) (*regexp.Regexp, error) {
+	if classifier == nil {
+		return compileRegex(pattern)
+	}
+
+	m.celMu.Lock()
+	defer m.celMu.Unlock()
+
+	cached := m.getClassifierPrograms(classifier)
+	if regex, ok := cached.regexes[pattern]; ok {
+		return regex, nil
+	}
+
+	regex, err := compileRegex(pattern)
+	if err != nil {
+		return nil, err
+	}
+	cached.regexes[pattern] = regex
+	return regex, nil
+}
+
+// labelFilterMatcher evaluates a LabelFilter with a regex operation.
+// Such filters cannot be evaluated by the API server.
+type labelFilterMatcher struct {
+	key    string
+	regex  *regexp.Regexp
+	negate bool
+}
+
+// getLabelFilterMatchers returns a matcher per LabelFilter with a regex operation
+func getLabelFilterMatchers(filters []libsveltosv1alpha1.LabelFilter,
+	compile regexCompiler) ([]labelFilterMatcher, error) {
+
+	var matchers []labelFilterMatcher
+	for i := range filters {
+		if !isRegexOperation(filters[i].Operation) {
+			continue
+		}
+		regex, err := compile(filters[i].Value)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, labelFilterMatcher{
+			key:    filters[i].Key,
+			regex:  regex,
+			negate: filters[i].Operation == OperationNotMatchRegex,
+		})
+	}
+	return matchers, nil
+}
+
+// isAMatch returns true if object satisfies the filter.
+// OperationMatchRegex requires label to be present and its value to match.
+// OperationNotMatchRegex requires label to be absent or its value not to match.
+func (l *labelFilterMatcher) isAMatch(object map[string]interface{}) bool {
+	value, found, err := unstructured.NestedFieldNoCopy(object, "metadata", "labels", l.key)
+	found = found && err == nil
+	if found {
+		var s string
+		s, found = value.(string)
+		found = found && l.regex.MatchString(s)
+	}
+
+	if l.negate {
+		return !found
+	}
+	return found
+}
+
+func areLabelFilterMatchersAMatch(object map[string]interface{}, matchers []labelFilterMatcher) bool {
+	for i := range matchers {
+		if !matchers[i].isAMatch(object) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/klog/v2/klogr"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: regex filters", func() {
+	var resources []unstructured.Unstructured
+
+	BeforeEach(func() {
+		classification.Reset()
+
+		resources = make([]unstructured.Unstructured, 0)
+		for _, pod := range []struct{ name, image, app string }{
+			{name: "nginx-7d9c5", image: "nginx:1.23.3", app: "web"},
+			{name: "envoy-5f6b7", image: "envoy:v1.24.1", app: "proxy"},
+			{name: "nginx-debug", image: "nginx:latest", app: ""},
+		} {
+			u := unstructured.Unstructured{Object: map[string]interface{}{
+				"metadata": map[string]interface{}{"name": pod.name},
+				"spec": map[string]interface{}{
+					"containers": []interface{}{map[string]interface{}{"image": pod.image}},
+				},
+			}}
+			if pod.app != "" {
+				u.SetLabels(map[string]string{"app": pod.app})
+			}
+			resources = append(resources, u)
+		}
+	})
+
+	It("field filters with MatchRegex and NotMatchRegex match whole values", func() {
+		filters := []libsveltosv1alpha1.FieldFilter{
+			{Field: "spec.containers.image", Operation: classification.OperationMatchRegex,
+				Value: `nginx:1\.[0-9]+\.[0-9]+`},
+		}
+		items, err := classification.FilterResources(resources, filters, nil, "")
+		Expect(err).To(BeNil())
+		Expect(len(items)).To(Equal(1))
+		Expect(items[0].GetName()).To(Equal("nginx-7d9c5"))
+
+		// Pattern must match the whole value
+		filters = []libsveltosv1alpha1.FieldFilter{
+			{Field: "metadata.name", Operation: classification.OperationMatchRegex, Value: "nginx"},
+		}
+		items, err = classification.FilterResources(resources, filters, nil, "")
+		Expect(err).To(BeNil())
+		Expect(len(items)).To(BeZero())
+
+		filters = []libsveltosv1alpha1.FieldFilter{
+			{Field: "metadata.name", Operation: classification.OperationNotMatchRegex, Value: "nginx-.*"},
+		}
+		items, err = classification.FilterResources(resources, filters, nil, "")
+		Expect(err).To(BeNil())
+		Expect(len(items)).To(Equal(1))
+		Expect(items[0].GetName()).To(Equal("envoy-5f6b7"))
+
+		filters = []libsveltosv1alpha1.FieldFilter{
+			{Field: "metadata.name", Operation: classification.OperationMatchRegex, Value: "nginx-("},
+		}
+		_, err = classification.FilterResources(resources, filters, nil, "")
+		Expect(classification.IsInvalidClassifierError(err)).To(BeTrue())
+	})
+
+	It("label filters with MatchRegex and NotMatchRegex are evaluated on listed resources", func() {
+		items, err := classification.FilterResourcesWithLabels(resources, []libsveltosv1alpha1.LabelFilter{
+			{Key: "app", Operation: classification.OperationMatchRegex, Value: "web|proxy"},
+		})
+		Expect(err).To(BeNil())
+		Expect(len(items)).To(Equal(2))
+
+		// Resources without the label do not match, but are kept on negation
+		items, err = classification.FilterResourcesWithLabels(resources, []libsveltosv1alpha1.LabelFilter{
+			{Key: "app", Operation: classification.OperationNotMatchRegex, Value: "w.*"},
+		})
+		Expect(err).To(BeNil())
+		Expect(len(items)).To(Equal(2))
+		Expect(items[0].GetName()).To(Equal("envoy-5f6b7"))
+		Expect(items[1].GetName()).To(Equal("nginx-debug"))
+	})
+
+	It("getRegex compiles regular expressions once per classifier generation", func() {
+		classification.InitializeManagerWithSkip(context.TODO(), klogr.New(), nil, nil, nil, 10)
+		manager := classification.GetManager()
+
+		classifier := getClassifierWithKubernetesConstraints(version25, libsveltosv1alpha1.ComparisonEqual)
+		classifier.Generation = 1
+
+		regex, err := classification.GetRegex(manager, classifier, "v1\\..*")
+		Expect(err).To(BeNil())
+		Expect(regex.MatchString("v1.25")).To(BeTrue())
+
+		cached, err := classification.GetRegex(manager, classifier, "v1\\..*")
+		Expect(err).To(BeNil())
+		Expect(cached).To(BeIdenticalTo(regex))
+
+		classifier.Generation = 2
+		recompiled, err := classification.GetRegex(manager, classifier, "v1\\..*")
+		Expect(err).To(BeNil())
+		Expect(recompiled).ToNot(BeIdenticalTo(regex))
+
+		_, err = classification.GetRegex(manager, classifier, "v1\\.(")
+		Expect(err).ToNot(BeNil())
+	})
+})
@@ -0,0 +1,174 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+)
+
+// isRemoteMode returns true if Classifiers are fetched from the management
+// cluster instead of being read from the managed cluster
+func (m *manager) isRemoteMode() bool {
+	return m.remoteInterval > 0
+}
+
+// getClassifier gets a Classifier by name. In remote mode Classifier is taken from
+// the cache of Classifiers fetched from the management cluster.
+func (m *manager) getClassifier(ctx context.Context, classifierName string,
+	classifier *libsveltosv1alpha1.Classifier) error {
+
+	if !m.isRemoteMode() {
+		return m.Client.Get(ctx, types.NamespacedName{Name: classifierName}, classifier)
+	}
+
+	m.remoteMu.RLock()
+	defer m.remoteMu.RUnlock()
+
+	cached, ok := m.remoteClassifiers[classifierName]
+	if !ok {
+		return apierrors.NewNotFound(
+			libsveltosv1alpha1.GroupVersion.WithResource("classifiers").GroupResource(), classifierName)
+	}
+	cached.DeepCopyInto(classifier)
+	return nil
+}
+
+// ListClassifiers returns all Classifiers to evaluate. In remote mode those are
+// the Classifiers last fetched from the management cluster.
+func (m *manager) ListClassifiers(ctx context.Context) (*libsveltosv1alpha1.ClassifierList, error) {
+	classifiers := &libsveltosv1alpha1.ClassifierList{}
+	if !m.isRemoteMode() {
+		if err := m.Client.List(ctx, classifiers); err != nil {
+			return nil, err
+		}
+		return classifiers, nil
+	}
+
+	m.remoteMu.RLock()
+	defer m.remoteMu.RUnlock()
+
+	classifiers.Items = make([]libsveltosv1alpha1.Classifier, 0, len(m.remoteClassifiers))
+	for _, classifier := range m.remoteClassifiers {
+		classifiers.Items = append(classifiers.Items, *classifier.DeepCopy())
+	}
+	sort.Slice(classifiers.Items, func(i, j int) bool {
+		return classifiers.Items[i].Name < classifiers.Items[j].Name
+	})
+	return classifiers, nil
+}
+
+// syncRemoteClassifiers periodically fetches Classifiers from the management cluster
+func (m *manager) syncRemoteClassifiers(ctx context.Context) {
+	for {
+		agentClient, err := m.getManamegentClusterClient(ctx, m.log)
+		if err == nil {
+			err = m.refreshRemoteClassifiers(ctx, agentClient)
+		}
+		if err != nil {
+			m.log.V(logs.LogInfo).Info(fmt.Sprintf("failed to fetch classifiers from management cluster: %v", err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(m.remoteInterval):
+		}
+	}
+}
+
+// refreshRemoteClassifiers lists Classifiers in the management cluster and updates
+// the cache. Classifiers which were added, removed or whose spec or extension changed
+// are queued for evaluation.
+func (m *manager) refreshRemoteClassifiers(ctx context.Context, agentClient client.Client) error {
+	classifiers := &libsveltosv1alpha1.ClassifierList{}
+	if err := agentClient.List(ctx, classifiers); err != nil {
+		return err
+	}
+
+	m.remoteMu.Lock()
+	current := make(map[string]*libsveltosv1alpha1.Classifier, len(classifiers.Items))
+	changed := make([]string, 0)
+	for i := range classifiers.Items {
+		classifier := &classifiers.Items[i]
+		current[classifier.Name] = classifier
+		cached, ok := m.remoteClassifiers[classifier.Name]
+		if !ok || isRemoteClassifierChanged(cached, classifier) {
+			changed = append(changed, classifier.Name)
+		}
+	}
+	for classifierName := range m.remoteClassifiers {
+		if _, ok := current[classifierName]; !ok {
+			changed = append(changed, classifierName)
+		}
+	}
+	m.remoteClassifiers = current
+	m.remoteMu.Unlock()
+	m.recordFactRefresh(FactRemoteClassifiers, time.Now())
+
+	if len(changed) == 0 {
+		return nil
+	}
+
+	m.ReEvaluateResourceToWatch()
+	for i := range changed {
+		m.log.V(logs.LogDebug).Info(fmt.Sprintf("remote classifier %s changed: queuing it", changed[i]))
+		m.EvaluateClassifier(changed[i])
+	}
+
+	return nil
+}
+
+// isRemoteClassifierChanged returns true if the Classifier needs to be evaluated again.
+// ClassifierExtension is an annotation, so editing it does not change generation.
+func isRemoteClassifierChanged(cached, classifier *libsveltosv1alpha1.Classifier) bool {
+	return cached.Generation != classifier.Generation ||
+		cached.DeletionTimestamp.IsZero() != classifier.DeletionTimestamp.IsZero() ||
+		cached.GetAnnotations()[ClassifierExtensionAnnotation] !=
+			classifier.GetAnnotations()[ClassifierExtensionAnnotation]
+}
+
+// reactToRemoteClassifiers is invoked, in remote mode, when an instance of a
+// watched gvk changes. All cached Classifiers depending on gvk are queued.
+func (m *manager) reactToRemoteClassifiers(gvk *schema.GroupVersionKind) {
+	if gvk == nil {
+		return
+	}
+
+	m.remoteMu.RLock()
+	defer m.remoteMu.RUnlock()
+
+	for classifierName, classifier := range m.remoteClassifiers {
+		gvks := GetClassifierGVKs(classifier)
+		for i := range gvks {
+			if gvks[i] == *gvk {
+				m.EvaluateClassifier(classifierName)
+				break
+			}
+		}
+	}
+}
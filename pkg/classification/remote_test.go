@@ -0,0 +1,109 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog/v2/klogr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: remote Classifiers", func() {
+	var scheme *runtime.Scheme
+
+	BeforeEach(func() {
+		var err error
+		scheme, err = setupScheme()
+		Expect(err).ToNot(HaveOccurred())
+		classification.Reset()
+	})
+
+	It("refreshRemoteClassifiers caches Classifiers fetched from management cluster", func() {
+		classifier := getClassifierWithKubernetesConstraints(version25, libsveltosv1alpha1.ComparisonEqual)
+
+		// Classifier exists only in the management cluster
+		localClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+		managementClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(classifier).Build()
+
+		classification.InitializeManagerWithSkip(context.TODO(), klogr.New(), nil, localClient, nil, 10)
+		manager := classification.GetManager()
+		classification.ApplyOptions(classification.WithRemoteClassifiers(time.Minute))
+
+		currentClassifier := &libsveltosv1alpha1.Classifier{}
+		err := classification.GetClassifier(manager, context.TODO(), classifier.Name, currentClassifier)
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+		Expect(classification.RefreshRemoteClassifiers(manager, context.TODO(), managementClient)).To(Succeed())
+
+		Expect(classification.GetClassifier(manager, context.TODO(), classifier.Name, currentClassifier)).To(Succeed())
+		Expect(currentClassifier.Spec).To(Equal(classifier.Spec))
+
+		classifiers, err := manager.ListClassifiers(context.TODO())
+		Expect(err).To(BeNil())
+		Expect(len(classifiers.Items)).To(Equal(1))
+		Expect(classifiers.Items[0].Name).To(Equal(classifier.Name))
+
+		// Classifier removed from management cluster is removed from the cache
+		Expect(managementClient.Delete(context.TODO(), classifier)).To(Succeed())
+		Expect(classification.RefreshRemoteClassifiers(manager, context.TODO(), managementClient)).To(Succeed())
+
+		err = classification.GetClassifier(manager, context.TODO(), classifier.Name, currentClassifier)
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		classifiers, err = manager.ListClassifiers(context.TODO())
+		Expect(err).To(BeNil())
+		Expect(classifiers.Items).To(BeEmpty())
+	})
+
+	It("isRemoteClassifierChanged detects ClassifierExtension changes", func() {
+		cached := getClassifierWithKubernetesConstraints(version25, libsveltosv1alpha1.ComparisonEqual)
+		cached.Generation = 1
+
+		// Nothing changed
+		classifier := cached.DeepCopy()
+		Expect(classification.IsRemoteClassifierChanged(cached, classifier)).To(BeFalse())
+
+		// ClassifierExtension is an annotation, so editing it does not bump generation
+		classifier.Annotations = map[string]string{
+			classification.ClassifierExtensionAnnotation: "cnis:\n- cilium",
+		}
+		Expect(classification.IsRemoteClassifierChanged(cached, classifier)).To(BeTrue())
+
+		classifier = cached.DeepCopy()
+		classifier.Generation = 2
+		Expect(classification.IsRemoteClassifierChanged(cached, classifier)).To(BeTrue())
+	})
+
+	It("ListClassifiers reads local Classifiers when remote mode is not enabled", func() {
+		classifier := getClassifierWithKubernetesConstraints(version25, libsveltosv1alpha1.ComparisonEqual)
+		classification.InitializeManagerWithObjects(nil, classifier)
+		manager := classification.GetManager()
+
+		classifiers, err := manager.ListClassifiers(context.TODO())
+		Expect(err).To(BeNil())
+		Expect(len(classifiers.Items)).To(Equal(1))
+	})
+})
@@ -0,0 +1,191 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"fmt"
+
+	"emperror.dev/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/projectsveltos/classifier-agent/pkg/explanation"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+)
+
+const (
+	// ClassifierReportErrorAnnotation is set on a ClassifierReport when Classifier
+	// could not be evaluated because of an invalid configuration.
+	// Value is the reason evaluation failed.
+	ClassifierReportErrorAnnotation = "classifier.projectsveltos.io/error"
+
+	// ReportFieldManager is the field manager classifier-agent applies ClassifierReports
+	// with, both in the managed and in the management cluster
+	ReportFieldManager = "classifier-agent"
+)
+
+// invalidClassifierError indicates Classifier cannot be evaluated because of
+// its configuration. Evaluating it again won't succeed till Classifier is changed.
+type invalidClassifierError struct {
+	err error
+}
+
+func newInvalidClassifierError(err error) error {
+	return &invalidClassifierError{err: err}
+}
+
+func (e *invalidClassifierError) Error() string {
+	return e.err.Error()
+}
+
+func (e *invalidClassifierError) Unwrap() error {
+	return e.err
+}
+
+// isInvalidClassifierError returns true if err, or any error it wraps,
+// is an invalidClassifierError
+func isInvalidClassifierError(err error) bool {
+	var invalidErr *invalidClassifierError
+	return errors.As(err, &invalidErr)
+}
+
+// reportAnnotations lists the annotations set by classifier-agent on a ClassifierReport.
+// Those are propagated to the ClassifierReport in the management cluster.
+// ClassifierReportDuplicatesAnnotation is only set when delivering.
+var reportAnnotations = []string{
+	ClassifierReportErrorAnnotation,
+	ClassifierReportCloudProviderAnnotation,
+	ClassifierReportDegradedAnnotation,
+	ClassifierReportAgentDegradedAnnotation,
+	ClassifierReportClusterUnhealthyAnnotation,
+	ClassifierReportStaleFactsAnnotation,
+	ClassifierReportClockSkewAnnotation,
+	explanation.Annotation,
+	ClassifierReportDuplicatesAnnotation,
+}
+
+// setReportError sets ClassifierReportErrorAnnotation to message.
+// Annotation is removed if message is empty.
+func setReportError(report *libsveltosv1alpha1.ClassifierReport, message string) {
+	setReportAnnotation(report, ClassifierReportErrorAnnotation, message)
+}
+
+// setReportAnnotation sets annotation key to value.
+// Annotation is removed if value is empty.
+func setReportAnnotation(report *libsveltosv1alpha1.ClassifierReport, key, value string) {
+	annotations := report.GetAnnotations()
+	if value == "" {
+		delete(annotations, key)
+		report.SetAnnotations(annotations)
+		return
+	}
+
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[key] = value
+	report.SetAnnotations(annotations)
+}
+
+// localReportAnnotations lists the annotations set by classifier-agent on a
+// ClassifierReport which are not propagated to the management cluster
+var localReportAnnotations = []string{
+	ClassifierReportEffectiveRBACAnnotation,
+}
+
+// copyReportAnnotations sets on dst all reportAnnotations, localReportAnnotations
+// and hook annotations as set on src
+func copyReportAnnotations(dst, src *libsveltosv1alpha1.ClassifierReport) {
+	copyAnnotations(dst, src, reportAnnotations)
+	copyAnnotations(dst, src, localReportAnnotations)
+	copyHookAnnotations(dst, src)
+}
+
+// copyAnnotations sets on dst all keys annotations as set on src
+func copyAnnotations(dst, src *libsveltosv1alpha1.ClassifierReport, keys []string) {
+	for _, key := range keys {
+		setReportAnnotation(dst, key, src.Annotations[key])
+	}
+}
+
+// applyClassifierReport creates or updates, with server-side apply, a ClassifierReport
+// with the labels, reportAnnotations, localReportAnnotations and spec of report. Fields set by other writers
+// are left untouched, while fields previously applied by classifier-agent and not
+// set in report anymore, for instance an annotation, are removed.
+func applyClassifierReport(ctx context.Context, c client.Client, report *libsveltosv1alpha1.ClassifierReport) error {
+	applied := &libsveltosv1alpha1.ClassifierReport{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: libsveltosv1alpha1.GroupVersion.String(),
+			Kind:       classifierReportGK.Kind,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: report.Namespace,
+			Name:      report.Name,
+			Labels:    report.Labels,
+		},
+		Spec: report.Spec,
+	}
+	copyReportAnnotations(applied, report)
+
+	return c.Patch(ctx, applied, client.Apply, client.FieldOwner(ReportFieldManager), client.ForceOwnership)
+}
+
+// getErrorMessage returns err message or an empty string if err is nil
+func getErrorMessage(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// recordExplanation stores the explanation of the last Classifier evaluation.
+// It is added to ClassifierReport by setReportExplanation.
+func (m *manager) recordExplanation(classifierName string, e *explanation.Explanation) {
+	data, err := explanation.Encode(e)
+	if err != nil {
+		m.log.V(logs.LogInfo).Info(fmt.Sprintf("failed to encode explanation for classifier %s: %v",
+			classifierName, err))
+		data = nil
+	}
+
+	m.explanationsMu.Lock()
+	defer m.explanationsMu.Unlock()
+	m.explanations[classifierName] = string(data)
+}
+
+// removeExplanation forgets the explanation recorded for a Classifier
+func (m *manager) removeExplanation(classifierName string) {
+	m.explanationsMu.Lock()
+	defer m.explanationsMu.Unlock()
+	delete(m.explanations, classifierName)
+}
+
+// setReportExplanation sets explanation.Annotation to the explanation of
+// the last Classifier evaluation
+func (m *manager) setReportExplanation(report *libsveltosv1alpha1.ClassifierReport,
+	classifier *libsveltosv1alpha1.Classifier) {
+
+	m.explanationsMu.Lock()
+	defer m.explanationsMu.Unlock()
+	value := m.explanations[classifier.Name]
+	if m.reportVerbosity == ReportVerbosityMinimal {
+		value = ""
+	}
+	setReportAnnotation(report, explanation.Annotation, value)
+}
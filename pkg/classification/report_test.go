@@ -0,0 +1,140 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/projectsveltos/classifier-agent/internal/utils"
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	"github.com/projectsveltos/classifier-agent/pkg/explanation"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: report", func() {
+
+	BeforeEach(func() {
+		classification.Reset()
+	})
+
+	It("createClassifierReport adds last evaluation explanation", func() {
+		classifier := getClassifierWithKubernetesConstraints(version25, libsveltosv1alpha1.ComparisonEqual)
+
+		c := classification.InitializeManagerWithObjects(nil, classifier)
+		manager := classification.GetManager()
+
+		classification.RecordExplanation(manager, classifier.Name, &explanation.Explanation{
+			Match: false,
+			Checks: []explanation.Check{
+				{Type: explanation.CheckKubernetesVersion, Satisfied: true},
+				{Type: explanation.CheckCRD, Reason: "custom resource definitions are not a match"},
+			},
+		})
+
+		Expect(classification.CreateClassifierReport(manager, context.TODO(), classifier, false, nil)).
+			To(Succeed())
+		classifierReport := &libsveltosv1alpha1.ClassifierReport{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: utils.ReportNamespace, Name: classifier.Name},
+			classifierReport)).To(Succeed())
+
+		e, err := explanation.FromAnnotations(classifierReport.Annotations)
+		Expect(err).To(BeNil())
+		Expect(e).ToNot(BeNil())
+		Expect(e.Version).To(Equal(explanation.Version))
+		Expect(e.Match).To(BeFalse())
+		failed, ok := e.Failed()
+		Expect(ok).To(BeTrue())
+		Expect(failed.Type).To(Equal(explanation.CheckCRD))
+
+		classification.RecordExplanation(manager, classifier.Name, &explanation.Explanation{
+			Match:  true,
+			Checks: []explanation.Check{{Type: explanation.CheckKubernetesVersion, Satisfied: true}},
+		})
+		Expect(classification.CreateClassifierReport(manager, context.TODO(), classifier, true, nil)).
+			To(Succeed())
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: utils.ReportNamespace, Name: classifier.Name},
+			classifierReport)).To(Succeed())
+		e, err = explanation.FromAnnotations(classifierReport.Annotations)
+		Expect(err).To(BeNil())
+		Expect(e.Match).To(BeTrue())
+	})
+
+	It("explanation of a deleted Classifier is forgotten", func() {
+		classifier := getClassifierWithKubernetesConstraints(version25, libsveltosv1alpha1.ComparisonEqual)
+
+		c := classification.InitializeManagerWithObjects(nil)
+		manager := classification.GetManager()
+
+		classification.RecordExplanation(manager, classifier.Name, &explanation.Explanation{
+			Match:  true,
+			Checks: []explanation.Check{{Type: explanation.CheckKubernetesVersion, Satisfied: true}},
+		})
+
+		// Classifier does not exist
+		Expect(classification.EvaluateClassifierInstance(manager, context.TODO(), classifier.Name)).To(Succeed())
+
+		Expect(c.Create(context.TODO(), classifier)).To(Succeed())
+		Expect(classification.CreateClassifierReport(manager, context.TODO(), classifier, false, nil)).
+			To(Succeed())
+		classifierReport := &libsveltosv1alpha1.ClassifierReport{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: utils.ReportNamespace, Name: classifier.Name},
+			classifierReport)).To(Succeed())
+		e, err := explanation.FromAnnotations(classifierReport.Annotations)
+		Expect(err).To(BeNil())
+		Expect(e).To(BeNil())
+	})
+
+	It("createClassifierReport leaves fields set by other writers untouched", func() {
+		classifier := getClassifierWithKubernetesConstraints(version25, libsveltosv1alpha1.ComparisonEqual)
+		classifierReport := &libsveltosv1alpha1.ClassifierReport{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   utils.ReportNamespace,
+				Name:        classifier.Name,
+				Labels:      map[string]string{"team": "platform"},
+				Annotations: map[string]string{"example.com/owner": "controller"},
+			},
+		}
+
+		c := classification.InitializeManagerWithObjects(nil, classifier, classifierReport)
+		manager := classification.GetManager()
+
+		Expect(classification.CreateClassifierReport(manager, context.TODO(), classifier, false,
+			fmt.Errorf("invalid classifier"))).To(Succeed())
+		key := types.NamespacedName{Namespace: utils.ReportNamespace, Name: classifier.Name}
+		Expect(c.Get(context.TODO(), key, classifierReport)).To(Succeed())
+		Expect(classifierReport.Labels).To(HaveKeyWithValue("team", "platform"))
+		Expect(classifierReport.Labels).To(HaveKeyWithValue(libsveltosv1alpha1.ClassifierLabelName, classifier.Name))
+		Expect(classifierReport.Annotations).To(HaveKeyWithValue("example.com/owner", "controller"))
+		Expect(classifierReport.Annotations).To(HaveKeyWithValue(classification.ClassifierReportErrorAnnotation,
+			"invalid classifier"))
+
+		// Annotations classifier-agent does not set anymore are removed
+		Expect(classification.CreateClassifierReport(manager, context.TODO(), classifier, true, nil)).
+			To(Succeed())
+		Expect(c.Get(context.TODO(), key, classifierReport)).To(Succeed())
+		Expect(classifierReport.Spec.Match).To(BeTrue())
+		Expect(classifierReport.Annotations).To(HaveKeyWithValue("example.com/owner", "controller"))
+		Expect(classifierReport.Annotations).ToNot(HaveKey(classification.ClassifierReportErrorAnnotation))
+	})
+})
@@ -0,0 +1,110 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// RestartCountFilter selects Pods by the sum of the restartCount of their
+// containers, for instance Pods which restarted more than 20 times
+type RestartCountFilter struct {
+	// MinRestarts is the minimum sum of container restart counts
+	// +optional
+	MinRestarts *int64 `json:"minRestarts,omitempty"`
+
+	// MaxRestarts is the maximum sum of container restart counts
+	// +optional
+	MaxRestarts *int64 `json:"maxRestarts,omitempty"`
+
+	// IncludeInitContainers, when set, adds init container restart counts to the sum
+	// +optional
+	IncludeInitContainers bool `json:"includeInitContainers,omitempty"`
+}
+
+// validateRestartCountFilter verifies filter is only used for Pods and bounds are valid
+func validateRestartCountFilter(constraint *ResourceConstraint) error {
+	filter := constraint.RestartCount
+	if filter == nil {
+		return nil
+	}
+	if constraint.Group != "" || constraint.Kind != "Pod" {
+		return fmt.Errorf("restartCount is only supported for Pods")
+	}
+	if filter.MinRestarts != nil && *filter.MinRestarts < 0 {
+		return fmt.Errorf("restartCount minRestarts %d is negative", *filter.MinRestarts)
+	}
+	if filter.MaxRestarts != nil && *filter.MaxRestarts < 0 {
+		return fmt.Errorf("restartCount maxRestarts %d is negative", *filter.MaxRestarts)
+	}
+	if filter.MinRestarts != nil && filter.MaxRestarts != nil && *filter.MinRestarts > *filter.MaxRestarts {
+		return fmt.Errorf("restartCount minRestarts %d is greater than maxRestarts %d",
+			*filter.MinRestarts, *filter.MaxRestarts)
+	}
+	return nil
+}
+
+// getRestartCount returns the sum of status.containerStatuses[*].restartCount and,
+// when includeInitContainers is set, of status.initContainerStatuses[*].restartCount
+func getRestartCount(pod *unstructured.Unstructured, includeInitContainers bool) int64 {
+	fields := []string{"containerStatuses"}
+	if includeInitContainers {
+		fields = append(fields, "initContainerStatuses")
+	}
+
+	var restarts int64
+	for _, field := range fields {
+		statuses, _, err := unstructured.NestedSlice(pod.Object, "status", field)
+		if err != nil {
+			continue
+		}
+		for i := range statuses {
+			status, ok := statuses[i].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			switch v := status["restartCount"].(type) {
+			case int64:
+				restarts += v
+			case int32:
+				restarts += int64(v)
+			case int:
+				restarts += int64(v)
+			case float64:
+				restarts += int64(v)
+			}
+		}
+	}
+	return restarts
+}
+
+// isRestartCountAMatch returns true if pod satisfies filter. All Pods satisfy a nil filter.
+func isRestartCountAMatch(pod *unstructured.Unstructured, filter *RestartCountFilter) bool {
+	if filter == nil {
+		return true
+	}
+	restarts := getRestartCount(pod, filter.IncludeInitContainers)
+	if filter.MinRestarts != nil && restarts < *filter.MinRestarts {
+		return false
+	}
+	if filter.MaxRestarts != nil && restarts > *filter.MaxRestarts {
+		return false
+	}
+	return true
+}
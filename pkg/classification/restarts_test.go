@@ -0,0 +1,100 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+)
+
+var _ = Describe("Manager: restart count", func() {
+	var pods []unstructured.Unstructured
+
+	BeforeEach(func() {
+		getPod := func(name string, restarts []int64, initRestarts []int64) unstructured.Unstructured {
+			pod := unstructured.Unstructured{Object: map[string]interface{}{}}
+			pod.SetName(name)
+			for field, counts := range map[string][]int64{
+				"containerStatuses": restarts, "initContainerStatuses": initRestarts} {
+
+				statuses := make([]interface{}, len(counts))
+				for i := range counts {
+					statuses[i] = map[string]interface{}{"restartCount": counts[i]}
+				}
+				Expect(unstructured.SetNestedSlice(pod.Object, statuses, "status", field)).To(Succeed())
+			}
+			return pod
+		}
+
+		pods = []unstructured.Unstructured{
+			getPod("stable", []int64{0, 0}, nil),
+			getPod("flapping", []int64{15, 10}, nil),
+			getPod("init-failing", []int64{1}, []int64{30}),
+			{Object: map[string]interface{}{"metadata": map[string]interface{}{"name": "pending"}}},
+		}
+	})
+
+	filter := func(f *classification.RestartCountFilter) []string {
+		items, err := classification.FilterPodsWithRestartCount(
+			append([]unstructured.Unstructured{}, pods...), f)
+		Expect(err).To(BeNil())
+		names := make([]string, len(items))
+		for i := range items {
+			names[i] = items[i].GetName()
+		}
+		return names
+	}
+
+	It("RestartCount sums container restart counts", func() {
+		zero, one, twentyOne, twentyFive := int64(0), int64(1), int64(21), int64(25)
+		Expect(filter(&classification.RestartCountFilter{MinRestarts: &twentyOne})).
+			To(ConsistOf("flapping"))
+		Expect(filter(&classification.RestartCountFilter{MinRestarts: &twentyOne,
+			IncludeInitContainers: true})).To(ConsistOf("flapping", "init-failing"))
+		// Pods without container statuses have no restart
+		Expect(filter(&classification.RestartCountFilter{MaxRestarts: &zero})).
+			To(ConsistOf("stable", "pending"))
+		Expect(filter(&classification.RestartCountFilter{MinRestarts: &one,
+			MaxRestarts: &twentyFive})).To(ConsistOf("flapping", "init-failing"))
+	})
+
+	It("RestartCount is only valid for Pods and with valid bounds", func() {
+		zero, one, negative := int64(0), int64(1), int64(-1)
+		constraint := &classification.ResourceConstraint{
+			RestartCount: &classification.RestartCountFilter{MinRestarts: &one},
+		}
+		constraint.Version = "v1"
+		constraint.Kind = "Pod"
+		Expect(classification.ValidateRestartCountFilter(constraint)).To(Succeed())
+
+		constraint.Kind = "Deployment"
+		constraint.Group = "apps"
+		Expect(classification.ValidateRestartCountFilter(constraint)).ToNot(Succeed())
+
+		constraint.Kind = "Pod"
+		constraint.Group = ""
+		constraint.RestartCount.MaxRestarts = &zero
+		Expect(classification.ValidateRestartCountFilter(constraint)).ToNot(Succeed())
+
+		constraint.RestartCount = &classification.RestartCountFilter{MinRestarts: &negative}
+		Expect(classification.ValidateRestartCountFilter(constraint)).ToNot(Succeed())
+	})
+})
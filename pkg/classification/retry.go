@@ -0,0 +1,104 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+)
+
+const (
+	// DefaultListRetries is the default number of times a List or Get failing with
+	// a transient error is retried within an evaluation
+	DefaultListRetries = 3
+
+	// DefaultListRetryDelay is the default delay before the first retry. Delay doubles
+	// with each retry and is jittered.
+	DefaultListRetryDelay = 200 * time.Millisecond
+
+	// maxListRetryDelay bounds the delay before a retry, including the delay the API
+	// server suggests when throttling
+	maxListRetryDelay = 2 * time.Second
+)
+
+var listRetries = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "list_retries_total",
+		Help:      "Number of List and Get requests retried after a transient API server error",
+	},
+)
+
+func init() {
+	metrics.Registry.MustRegister(listRetries)
+}
+
+// isTransientError returns true if err is likely to go away when the request is
+// sent again: throttling, API server or etcd unavailability (for instance during an
+// etcd leader change) and connections reset by the peer
+func isTransientError(err error) bool {
+	return apierrors.IsTooManyRequests(err) ||
+		apierrors.IsServerTimeout(err) ||
+		apierrors.IsTimeout(err) ||
+		apierrors.IsServiceUnavailable(err) ||
+		apierrors.IsInternalError(err) ||
+		utilnet.IsConnectionReset(err) ||
+		utilnet.IsConnectionRefused(err)
+}
+
+// retryTransient runs read, which must be idempotent, and runs it again, up to
+// m.listRetries times, while it fails with a transient error. Delay between attempts
+// grows exponentially, is jittered and never exceeds maxListRetryDelay. No retry is
+// done once ctx is done, so an evaluation budget is never exceeded waiting.
+func (m *manager) retryTransient(ctx context.Context, description string, read func() error) error {
+	delay := m.listRetryDelay
+	for attempt := 0; ; attempt++ {
+		err := read()
+		if err == nil || attempt >= m.listRetries || !isTransientError(err) {
+			return err
+		}
+
+		pause := wait.Jitter(delay, 1.0)
+		if seconds, ok := apierrors.SuggestsClientDelay(err); ok {
+			pause = time.Duration(seconds) * time.Second
+		}
+		if pause > maxListRetryDelay {
+			pause = maxListRetryDelay
+		}
+		m.log.V(logs.LogDebug).Info(fmt.Sprintf("%s failed with transient error: %v. Retrying in %s",
+			description, err, pause))
+
+		timer := time.NewTimer(pause)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		listRetries.Inc()
+		delay *= 2
+	}
+}
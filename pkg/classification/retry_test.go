@@ -0,0 +1,108 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+)
+
+var _ = Describe("Manager: transient error retries", func() {
+	var server *httptest.Server
+	var requests int32
+	var failures int32
+	var failureStatus int
+
+	gvk := schema.GroupVersionKind{Version: "v1", Kind: "Pod"}
+
+	BeforeEach(func() {
+		classification.Reset()
+		atomic.StoreInt32(&requests, 0)
+		failures = 0
+		failureStatus = http.StatusInternalServerError
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			if atomic.AddInt32(&requests, 1) <= failures {
+				w.WriteHeader(failureStatus)
+				_, _ = w.Write([]byte(fmt.Sprintf(`{"kind":"Status","apiVersion":"v1","status":"Failure",`+
+					`"message":"etcdserver: leader changed","code":%d}`, failureStatus)))
+				return
+			}
+			_, _ = w.Write([]byte(`{"kind":"PodList","apiVersion":"v1","metadata":{"resourceVersion":"7"},"items":[]}`))
+		}))
+
+		classification.InitializeManagerWithObjects(&rest.Config{Host: server.URL})
+		classification.ApplyOptions(classification.WithListRetries(2, time.Millisecond))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("listResources retries transient errors", func() {
+		failures = 2
+
+		list, err := classification.ListResources(classification.GetManager(), context.TODO(), &gvk, "pods",
+			&metav1.ListOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(list.GetResourceVersion()).To(Equal("7"))
+		Expect(atomic.LoadInt32(&requests)).To(Equal(int32(3)))
+	})
+
+	It("listResources fails once retries are exhausted", func() {
+		failures = 3
+
+		_, err := classification.ListResources(classification.GetManager(), context.TODO(), &gvk, "pods",
+			&metav1.ListOptions{})
+		Expect(err).To(HaveOccurred())
+		Expect(atomic.LoadInt32(&requests)).To(Equal(int32(3)))
+	})
+
+	It("listResources does not retry other errors", func() {
+		failures = 1
+		failureStatus = http.StatusForbidden
+
+		_, err := classification.ListResources(classification.GetManager(), context.TODO(), &gvk, "pods",
+			&metav1.ListOptions{})
+		Expect(apierrors.IsForbidden(err)).To(BeTrue())
+		Expect(atomic.LoadInt32(&requests)).To(Equal(int32(1)))
+	})
+
+	It("isTransientError detects throttling and unavailability", func() {
+		resource := schema.GroupResource{Resource: "pods"}
+		Expect(classification.IsTransientError(apierrors.NewTooManyRequests("throttled", 1))).To(BeTrue())
+		Expect(classification.IsTransientError(apierrors.NewServiceUnavailable("unavailable"))).To(BeTrue())
+		Expect(classification.IsTransientError(apierrors.NewServerTimeout(resource, "list", 1))).To(BeTrue())
+		Expect(classification.IsTransientError(apierrors.NewNotFound(resource, "pod"))).To(BeFalse())
+		Expect(classification.IsTransientError(apierrors.NewBadRequest("bad"))).To(BeFalse())
+	})
+})
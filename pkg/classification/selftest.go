@@ -0,0 +1,283 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+const (
+	SelfTestDiscovery         = "Discovery"
+	SelfTestCRDs              = "CRDs"
+	SelfTestRBAC              = "RBAC"
+	SelfTestManagementCluster = "ManagementCluster"
+	SelfTestClockSkew         = "ClockSkew"
+
+	// DefaultMaxClockSkew is the maximum difference tolerated between local
+	// clock and API server clock
+	DefaultMaxClockSkew = 30 * time.Second
+)
+
+// SelfTestOptions configures the self-test
+type SelfTestOptions struct {
+	// SendReports must be set when classifier-agent sends ClassifierReports
+	// to the management cluster. Management cluster connectivity is then verified.
+	SendReports bool
+
+	// MaxClockSkew is the maximum tolerated clock skew. DefaultMaxClockSkew when zero.
+	MaxClockSkew time.Duration
+}
+
+// SelfTestCheck is the outcome of one self-test check
+type SelfTestCheck struct {
+	// Name identifies the check
+	Name string `json:"name"`
+
+	// Passed is true if the check passed
+	Passed bool `json:"passed"`
+
+	// Message describes why the check failed or, if it passed, what was verified
+	Message string `json:"message,omitempty"`
+}
+
+// SelfTestReport is the outcome of the self-test
+type SelfTestReport struct {
+	// Passed is true if all checks passed
+	Passed bool `json:"passed"`
+
+	// Checks lists all checks in the order they were run
+	Checks []SelfTestCheck `json:"checks"`
+}
+
+// requiredAccess lists the permissions classifier-agent needs in the managed cluster
+var requiredAccess = []authorizationv1.ResourceAttributes{
+	{Group: "*", Resource: "*", Verb: "list"},
+	{Group: "*", Resource: "*", Verb: "watch"},
+	{Group: libsveltosv1alpha1.GroupVersion.Group, Resource: "classifiers", Verb: "list"},
+	{Group: libsveltosv1alpha1.GroupVersion.Group, Resource: "classifierreports", Verb: "create"},
+	{Group: libsveltosv1alpha1.GroupVersion.Group, Resource: "classifierreports", Verb: "update"},
+	{Group: libsveltosv1alpha1.GroupVersion.Group, Resource: "classifierreports", Verb: "patch"},
+	{Group: libsveltosv1alpha1.GroupVersion.Group, Resource: "classifierreports", Verb: "delete"},
+	{Group: "apiextensions.k8s.io", Resource: "customresourcedefinitions", Verb: "watch"},
+	{Resource: "nodes", Verb: "list"},
+}
+
+// RunSelfTest verifies the environment classifier-agent is deployed in: API server
+// discovery health, presence of Classifier and ClassifierReport CRDs, RBAC, management
+// cluster connectivity (when reports are sent) and clock skew with the API server.
+// All checks are run, even when one fails.
+func RunSelfTest(ctx context.Context, config *rest.Config, c client.Client, logger logr.Logger,
+	options SelfTestOptions) *SelfTestReport {
+
+	if options.MaxClockSkew == 0 {
+		options.MaxClockSkew = DefaultMaxClockSkew
+	}
+
+	m := &manager{Client: c, config: config, log: logger}
+
+	report := &SelfTestReport{Passed: true}
+	run := func(name string, check func() (string, error)) {
+		message, err := check()
+		result := SelfTestCheck{Name: name, Passed: err == nil, Message: message}
+		if err != nil {
+			result.Message = err.Error()
+			report.Passed = false
+		}
+		report.Checks = append(report.Checks, result)
+	}
+
+	run(SelfTestDiscovery, func() (string, error) { return checkDiscovery(config) })
+	run(SelfTestCRDs, func() (string, error) { return checkCRDs(config) })
+	run(SelfTestRBAC, func() (string, error) { return checkRBAC(ctx, config) })
+	if options.SendReports {
+		run(SelfTestManagementCluster, func() (string, error) {
+			managementConfig, err := m.getManagementClusterConfig(ctx, logger)
+			if err != nil {
+				return "", err
+			}
+			return checkServerVersion(managementConfig)
+		})
+	}
+	run(SelfTestClockSkew, func() (string, error) { return checkClockSkew(ctx, config, options.MaxClockSkew) })
+
+	return report
+}
+
+// WriteSelfTestReport writes the JSON encoded report to w
+func WriteSelfTestReport(w io.Writer, report *SelfTestReport) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+func checkServerVersion(config *rest.Config) (string, error) {
+	dc, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return "", err
+	}
+	version, err := dc.ServerVersion()
+	if err != nil {
+		return "", fmt.Errorf("failed to get API server version: %w", err)
+	}
+	return fmt.Sprintf("API server version %s", version.GitVersion), nil
+}
+
+// checkDiscovery verifies the API server version can be fetched and all
+// API groups can be discovered
+func checkDiscovery(config *rest.Config) (string, error) {
+	message, err := checkServerVersion(config)
+	if err != nil {
+		return "", err
+	}
+
+	dc, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return "", err
+	}
+	_, _, err = dc.ServerGroupsAndResources()
+	if err != nil {
+		if groupErr, ok := err.(*discovery.ErrGroupDiscoveryFailed); ok {
+			failed := make([]string, 0, len(groupErr.Groups))
+			for gv := range groupErr.Groups {
+				failed = append(failed, gv.String())
+			}
+			sort.Strings(failed)
+			return "", fmt.Errorf("discovery failed for %s", strings.Join(failed, ", "))
+		}
+		return "", err
+	}
+	return message, nil
+}
+
+// checkCRDs verifies Classifier and ClassifierReport are served
+func checkCRDs(config *rest.Config) (string, error) {
+	dc, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return "", err
+	}
+
+	missing := map[string]bool{"Classifier": true, "ClassifierReport": true}
+	resources, err := dc.ServerResourcesForGroupVersion(libsveltosv1alpha1.GroupVersion.String())
+	if err == nil {
+		for i := range resources.APIResources {
+			delete(missing, resources.APIResources[i].Kind)
+		}
+	}
+	if len(missing) != 0 {
+		kinds := make([]string, 0, len(missing))
+		for kind := range missing {
+			kinds = append(kinds, kind)
+		}
+		sort.Strings(kinds)
+		return "", fmt.Errorf("%s not served in %s", strings.Join(kinds, ", "),
+			libsveltosv1alpha1.GroupVersion.String())
+	}
+	return "Classifier and ClassifierReport are served", nil
+}
+
+// checkRBAC verifies, with SelfSubjectAccessReviews, classifier-agent has all
+// permissions it needs
+func checkRBAC(ctx context.Context, config *rest.Config) (string, error) {
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return "", err
+	}
+
+	var denied []string
+	for i := range requiredAccess {
+		attributes := requiredAccess[i]
+		review := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{ResourceAttributes: &attributes},
+		}
+		review, err = clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+		if err != nil {
+			return "", fmt.Errorf("failed to review access: %w", err)
+		}
+		if !review.Status.Allowed {
+			resource := attributes.Resource
+			if attributes.Group != "" {
+				resource = fmt.Sprintf("%s.%s", attributes.Resource, attributes.Group)
+			}
+			denied = append(denied, fmt.Sprintf("%s %s", attributes.Verb, resource))
+		}
+	}
+
+	if len(denied) != 0 {
+		return "", fmt.Errorf("not allowed to %s", strings.Join(denied, ", "))
+	}
+	return fmt.Sprintf("all %d required permissions granted", len(requiredAccess)), nil
+}
+
+// checkClockSkew compares local clock with the Date the API server sets on responses
+func checkClockSkew(ctx context.Context, config *rest.Config, maxSkew time.Duration) (string, error) {
+	httpClient, err := rest.HTTPClientFor(config)
+	if err != nil {
+		return "", err
+	}
+	serverURL, _, err := rest.DefaultServerUrlFor(config)
+	if err != nil {
+		return "", err
+	}
+	serverURL.Path = "/version"
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, serverURL.String(), http.NoBody)
+	if err != nil {
+		return "", err
+	}
+
+	start := time.Now()
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	// Server time is compared with the middle of the request round trip
+	local := start.Add(time.Since(start) / 2)
+
+	serverTime, err := http.ParseTime(response.Header.Get("Date"))
+	if err != nil {
+		return "", fmt.Errorf("API server response has no valid Date header: %w", err)
+	}
+
+	skew := serverTime.Sub(local)
+	if skew < 0 {
+		skew = -skew
+	}
+	// Date header has a one second resolution
+	skew = skew.Truncate(time.Second)
+	if skew > maxSkew {
+		return "", fmt.Errorf("clock skew with API server is %s (max %s)", skew, maxSkew)
+	}
+	return fmt.Sprintf("clock skew with API server is %s", skew), nil
+}
@@ -0,0 +1,177 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2/klogr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Self-test", func() {
+	var server *httptest.Server
+	var deniedResource string
+	var serveReports bool
+	var serverClockOffset time.Duration
+
+	BeforeEach(func() {
+		deniedResource = ""
+		serveReports = true
+		serverClockOffset = 0
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			if serverClockOffset != 0 {
+				w.Header().Set("Date", time.Now().Add(serverClockOffset).UTC().Format(http.TimeFormat))
+			}
+			switch r.URL.Path {
+			case "/version":
+				_, _ = w.Write([]byte(`{"major":"1","minor":"25","gitVersion":"v1.25.3"}`))
+			case "/api":
+				_, _ = w.Write([]byte(`{"kind":"APIVersions","versions":[]}`))
+			case "/apis":
+				_, _ = w.Write([]byte(`{"kind":"APIGroupList","apiVersion":"v1","groups":[{"name":"lib.projectsveltos.io",` +
+					`"versions":[{"groupVersion":"lib.projectsveltos.io/v1alpha1","version":"v1alpha1"}],` +
+					`"preferredVersion":{"groupVersion":"lib.projectsveltos.io/v1alpha1","version":"v1alpha1"}}]}`))
+			case "/apis/lib.projectsveltos.io/v1alpha1":
+				resources := `{"name":"classifiers","singularName":"classifier","namespaced":false,` +
+					`"kind":"Classifier","verbs":["list"]}`
+				if serveReports {
+					resources += `,{"name":"classifierreports","singularName":"classifierreport","namespaced":true,` +
+						`"kind":"ClassifierReport","verbs":["list"]}`
+				}
+				_, _ = w.Write([]byte(`{"kind":"APIResourceList","apiVersion":"v1",` +
+					`"groupVersion":"lib.projectsveltos.io/v1alpha1","resources":[` + resources + `]}`))
+			case "/apis/authorization.k8s.io/v1/selfsubjectaccessreviews":
+				review := &authorizationv1.SelfSubjectAccessReview{}
+				Expect(json.NewDecoder(r.Body).Decode(review)).To(Succeed())
+				review.Status.Allowed = review.Spec.ResourceAttributes.Resource != deniedResource
+				w.WriteHeader(http.StatusCreated)
+				Expect(json.NewEncoder(w).Encode(review)).To(Succeed())
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	getCheck := func(report *classification.SelfTestReport, name string) *classification.SelfTestCheck {
+		for i := range report.Checks {
+			if report.Checks[i].Name == name {
+				return &report.Checks[i]
+			}
+		}
+		return nil
+	}
+
+	runSelfTest := func(c client.Client, options classification.SelfTestOptions) *classification.SelfTestReport {
+		config := &rest.Config{Host: server.URL, ContentConfig: rest.ContentConfig{ContentType: "application/json"}}
+		return classification.RunSelfTest(context.TODO(), config, c, klogr.New(), options)
+	}
+
+	It("RunSelfTest passes in a healthy environment", func() {
+		kubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: management
+  cluster:
+    server: %s
+contexts:
+- name: management
+  context:
+    cluster: management
+    user: agent
+current-context: management
+users:
+- name: agent
+  user:
+    token: abc
+`, server.URL)
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: libsveltosv1alpha1.ClassifierSecretNamespace,
+				Name:      libsveltosv1alpha1.ClassifierSecretName,
+			},
+			Data: map[string][]byte{"kubeconfig": []byte(kubeconfig)},
+		}
+		c := fake.NewClientBuilder().WithObjects(secret).Build()
+
+		report := runSelfTest(c, classification.SelfTestOptions{SendReports: true})
+		Expect(report.Passed).To(BeTrue(), fmt.Sprintf("%v", report.Checks))
+		Expect(report.Checks).To(HaveLen(5))
+		Expect(getCheck(report, classification.SelfTestDiscovery).Message).To(ContainSubstring("v1.25.3"))
+		Expect(getCheck(report, classification.SelfTestManagementCluster).Passed).To(BeTrue())
+
+		var buffer bytes.Buffer
+		Expect(classification.WriteSelfTestReport(&buffer, report)).To(Succeed())
+		decoded := &classification.SelfTestReport{}
+		Expect(json.Unmarshal(buffer.Bytes(), decoded)).To(Succeed())
+		Expect(decoded).To(Equal(report))
+	})
+
+	It("RunSelfTest reports all failing checks", func() {
+		deniedResource = "nodes"
+		serveReports = false
+		serverClockOffset = 5 * time.Minute
+
+		// No management cluster kubeconfig Secret
+		c := fake.NewClientBuilder().Build()
+
+		report := runSelfTest(c, classification.SelfTestOptions{SendReports: true})
+		Expect(report.Passed).To(BeFalse())
+		Expect(report.Checks).To(HaveLen(5))
+		Expect(getCheck(report, classification.SelfTestDiscovery).Passed).To(BeTrue())
+
+		check := getCheck(report, classification.SelfTestCRDs)
+		Expect(check.Passed).To(BeFalse())
+		Expect(check.Message).To(ContainSubstring("ClassifierReport"))
+
+		check = getCheck(report, classification.SelfTestRBAC)
+		Expect(check.Passed).To(BeFalse())
+		Expect(check.Message).To(ContainSubstring("list nodes"))
+
+		Expect(getCheck(report, classification.SelfTestManagementCluster).Passed).To(BeFalse())
+		Expect(getCheck(report, classification.SelfTestClockSkew).Passed).To(BeFalse())
+	})
+
+	It("RunSelfTest skips management cluster check when reports are not sent", func() {
+		report := runSelfTest(fake.NewClientBuilder().Build(), classification.SelfTestOptions{})
+		Expect(report.Passed).To(BeTrue(), fmt.Sprintf("%v", report.Checks))
+		Expect(getCheck(report, classification.SelfTestManagementCluster)).To(BeNil())
+	})
+})
@@ -0,0 +1,165 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+// Service meshes detected by classifier-agent
+const (
+	ServiceMeshIstio   = "istio"
+	ServiceMeshLinkerd = "linkerd"
+	ServiceMeshConsul  = "consul"
+)
+
+// deploymentGVK is watched by Classifiers with ServiceMeshes
+var deploymentGVK = appsv1.SchemeGroupVersion.WithKind("Deployment")
+
+// serviceMeshDeployments maps the name of the control plane Deployment of each
+// service mesh to the service mesh
+var serviceMeshDeployments = map[string]string{
+	"istiod":                  ServiceMeshIstio,
+	"linkerd-destination":     ServiceMeshLinkerd,
+	"linkerd-controller":      ServiceMeshLinkerd,
+	"consul-connect-injector": ServiceMeshConsul,
+}
+
+// serviceMeshCRDs maps a CustomResourceDefinition each service mesh installs to the
+// service mesh
+var serviceMeshCRDs = map[string]string{
+	"virtualservices.networking.istio.io":  ServiceMeshIstio,
+	"serviceprofiles.linkerd.io":           ServiceMeshLinkerd,
+	"servicedefaults.consul.hashicorp.com": ServiceMeshConsul,
+}
+
+// serviceMeshInjection maps the namespace label or annotation enabling sidecar
+// injection to the service mesh
+var serviceMeshInjection = map[string]string{
+	"istio-injection":                     ServiceMeshIstio,
+	"istio.io/rev":                        ServiceMeshIstio,
+	"linkerd.io/inject":                   ServiceMeshLinkerd,
+	"consul.hashicorp.com/connect-inject": ServiceMeshConsul,
+}
+
+// isServiceMeshAMatch returns true if Classifier has no ServiceMeshes or if at least
+// one of the service meshes detected in the cluster is listed in ServiceMeshes
+func (m *manager) isServiceMeshAMatch(ctx context.Context, classifier *libsveltosv1alpha1.Classifier) (bool, error) {
+	extension, err := getClassifierExtension(classifier)
+	if err != nil {
+		return false, err
+	}
+
+	if len(extension.ServiceMeshes) == 0 {
+		return true, nil
+	}
+
+	detected, err := m.detectServiceMeshes(ctx)
+	if err != nil {
+		return false, err
+	}
+	if len(detected) == 0 {
+		addEvaluationNote(ctx, "no known service mesh detected")
+		return false, nil
+	}
+	addEvaluationNote(ctx, fmt.Sprintf("detected service meshes: %s", strings.Join(detected, ",")))
+
+	for i := range detected {
+		for j := range extension.ServiceMeshes {
+			if strings.EqualFold(detected[i], extension.ServiceMeshes[j]) {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// detectServiceMeshes returns, sorted, the service meshes whose control plane
+// Deployment or CustomResourceDefinitions are present, or which namespaces are
+// labeled or annotated for sidecar injection by
+func (m *manager) detectServiceMeshes(ctx context.Context) ([]string, error) {
+	meshes := make(map[string]bool)
+
+	deployments := &appsv1.DeploymentList{}
+	if err := m.List(ctx, deployments); err != nil {
+		return nil, err
+	}
+	for i := range deployments.Items {
+		if mesh, ok := serviceMeshDeployments[deployments.Items[i].Name]; ok {
+			meshes[mesh] = true
+		}
+	}
+
+	namespaces := &corev1.NamespaceList{}
+	if err := m.List(ctx, namespaces); err != nil {
+		return nil, err
+	}
+	for i := range namespaces.Items {
+		for key, mesh := range serviceMeshInjection {
+			if isInjectionEnabled(namespaces.Items[i].Labels, key) ||
+				isInjectionEnabled(namespaces.Items[i].Annotations, key) {
+
+				meshes[mesh] = true
+			}
+		}
+	}
+
+	for name, mesh := range serviceMeshCRDs {
+		if meshes[mesh] {
+			continue
+		}
+		crd, err := m.getCustomResourceDefinition(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if crd != nil {
+			meshes[mesh] = true
+		}
+	}
+
+	result := make([]string, 0, len(meshes))
+	for mesh := range meshes {
+		result = append(result, mesh)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// isInjectionEnabled returns true if values contains key with a value other than
+// disabled or false. istio.io/rev value is a revision name.
+func isInjectionEnabled(values map[string]string, key string) bool {
+	value, ok := values[key]
+	if !ok {
+		return false
+	}
+	return !strings.EqualFold(value, "disabled") && !strings.EqualFold(value, "false")
+}
+
+// getServiceMeshGVKs returns the GVKs service mesh detection depends on
+func getServiceMeshGVKs() []schema.GroupVersionKind {
+	return []schema.GroupVersionKind{deploymentGVK, namespaceGVK, crdGVK}
+}
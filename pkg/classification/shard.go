@@ -0,0 +1,58 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+// ReportShardLabel is set on all ClassifierReports sent to the management cluster.
+// Its value is a deterministic hash of the cluster namespace, name and type, so
+// that the management cluster can shard report processing across controller replicas.
+const ReportShardLabel = "classifier.projectsveltos.io/shard"
+
+// ShardHasher returns the value of ReportShardLabel for a cluster. Value must be
+// a valid label value and must only depend on the arguments.
+type ShardHasher func(clusterNamespace, clusterName string, clusterType libsveltosv1alpha1.ClusterType) string
+
+// DefaultShardHasher returns the hex encoded 32-bit FNV-1a hash of
+// clusterNamespace/clusterName/clusterType
+func DefaultShardHasher(clusterNamespace, clusterName string, clusterType libsveltosv1alpha1.ClusterType) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(fmt.Sprintf("%s/%s/%s", clusterNamespace, clusterName, clusterType)))
+	return fmt.Sprintf("%08x", h.Sum32())
+}
+
+// getReportShard returns the value of ReportShardLabel for this cluster
+func (m *manager) getReportShard() (string, error) {
+	hasher := m.shardHasher
+	if hasher == nil {
+		hasher = DefaultShardHasher
+	}
+
+	shard := hasher(m.clusterNamespace, m.clusterName, m.clusterType)
+	if errs := validation.IsValidLabelValue(shard); len(errs) != 0 {
+		return "", fmt.Errorf("invalid %s label value %q: %s", ReportShardLabel, shard, strings.Join(errs, ", "))
+	}
+	return shard, nil
+}
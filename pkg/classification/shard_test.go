@@ -0,0 +1,68 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: report shard", func() {
+	BeforeEach(func() {
+		classification.Reset()
+	})
+
+	It("DefaultShardHasher is deterministic and depends on all cluster facts", func() {
+		shard := classification.DefaultShardHasher("default", "cluster1", libsveltosv1alpha1.ClusterTypeCapi)
+		Expect(shard).To(HaveLen(8))
+		Expect(classification.DefaultShardHasher("default", "cluster1", libsveltosv1alpha1.ClusterTypeCapi)).
+			To(Equal(shard))
+		Expect(classification.DefaultShardHasher("default", "cluster2", libsveltosv1alpha1.ClusterTypeCapi)).
+			ToNot(Equal(shard))
+		Expect(classification.DefaultShardHasher("other", "cluster1", libsveltosv1alpha1.ClusterTypeCapi)).
+			ToNot(Equal(shard))
+		Expect(classification.DefaultShardHasher("default", "cluster1", libsveltosv1alpha1.ClusterTypeSveltos)).
+			ToNot(Equal(shard))
+	})
+
+	It("getReportShard uses the configured hasher and validates its value", func() {
+		classification.InitializeManagerWithObjects(nil)
+		manager := classification.GetManager()
+
+		shard, err := classification.GetReportShard(manager)
+		Expect(err).To(BeNil())
+		Expect(shard).To(Equal(classification.DefaultShardHasher("", "", "")))
+
+		classification.ApplyOptions(classification.WithShardHasher(
+			func(_, _ string, _ libsveltosv1alpha1.ClusterType) string {
+				return "shard-1"
+			}))
+		shard, err = classification.GetReportShard(manager)
+		Expect(err).To(BeNil())
+		Expect(shard).To(Equal("shard-1"))
+
+		classification.ApplyOptions(classification.WithShardHasher(
+			func(_, _ string, _ libsveltosv1alpha1.ClusterType) string {
+				return "not a label value"
+			}))
+		_, err = classification.GetReportShard(manager)
+		Expect(err).ToNot(BeNil())
+	})
+})
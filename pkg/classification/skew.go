@@ -0,0 +1,94 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"fmt"
+
+	"emperror.dev/errors"
+	"github.com/Masterminds/semver"
+	corev1 "k8s.io/api/core/v1"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+// HasKubeletVersionSkewConstraint returns true if Classifier depends on
+// node kubelet versions
+func HasKubeletVersionSkewConstraint(classifier *libsveltosv1alpha1.Classifier) bool {
+	extension, err := getClassifierExtension(classifier)
+	if err != nil {
+		// Classifier is reported as invalid when evaluated.
+		return false
+	}
+
+	return extension.KubeletVersionSkew != nil
+}
+
+// isKubeletVersionSkewAMatch returns true if the skew between API server version
+// and at least one node kubelet version exceeds constraint threshold
+func (m *manager) isKubeletVersionSkewAMatch(ctx context.Context, apiServerVersion *semver.Version,
+	constraint *KubeletVersionSkewConstraint) (bool, error) {
+
+	if constraint.MinorVersions < 0 {
+		return false, newInvalidClassifierError(
+			fmt.Errorf("kubelet version skew minorVersions %d must not be negative", constraint.MinorVersions))
+	}
+
+	nodes := &corev1.NodeList{}
+	if err := m.List(ctx, nodes); err != nil {
+		return false, err
+	}
+
+	return isKubeletVersionSkewExceeded(apiServerVersion, nodes.Items, constraint.MinorVersions,
+		m.versionMatching)
+}
+
+// isKubeletVersionSkewExceeded returns true if at least one node runs a kubelet
+// whose minor version differs from API server minor version by more than
+// maxSkew. A different major version always exceeds maxSkew.
+// Nodes not reporting a kubelet version yet are ignored.
+func isKubeletVersionSkewExceeded(apiServerVersion *semver.Version, nodes []corev1.Node,
+	maxSkew int, mode VersionMatchingMode) (bool, error) {
+
+	for i := range nodes {
+		kubeletVersion := nodes[i].Status.NodeInfo.KubeletVersion
+		if kubeletVersion == "" {
+			continue
+		}
+
+		v, err := getClusterVersion(kubeletVersion, mode)
+		if err != nil {
+			return false, errors.Wrap(err,
+				fmt.Sprintf("failed to parse node %s kubelet version %s", nodes[i].Name, kubeletVersion))
+		}
+
+		if v.Major() != apiServerVersion.Major() {
+			return true, nil
+		}
+
+		skew := apiServerVersion.Minor() - v.Minor()
+		if skew < 0 {
+			skew = -skew
+		}
+		if skew > int64(maxSkew) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
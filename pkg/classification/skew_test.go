@@ -0,0 +1,92 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"github.com/Masterminds/semver"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+func getNodeWithKubeletVersion(kubeletVersion string) corev1.Node {
+	return corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: randomString(),
+		},
+		Status: corev1.NodeStatus{
+			NodeInfo: corev1.NodeSystemInfo{
+				KubeletVersion: kubeletVersion,
+			},
+		},
+	}
+}
+
+var _ = Describe("Manager: kubelet version skew", func() {
+	var apiServerVersion *semver.Version
+
+	BeforeEach(func() {
+		var err error
+		apiServerVersion, err = semver.NewVersion("v1.26.1")
+		Expect(err).To(BeNil())
+	})
+
+	It("isKubeletVersionSkewExceeded returns true only when skew exceeds threshold", func() {
+		nodes := []corev1.Node{
+			getNodeWithKubeletVersion("v1.26.1"),
+			getNodeWithKubeletVersion("v1.25.6-eks-48e63af"),
+			getNodeWithKubeletVersion(""),
+		}
+
+		exceeded, err := classification.IsKubeletVersionSkewExceeded(apiServerVersion, nodes, 1,
+			classification.VersionMatchingNormalized)
+		Expect(err).To(BeNil())
+		Expect(exceeded).To(BeFalse())
+
+		exceeded, err = classification.IsKubeletVersionSkewExceeded(apiServerVersion, nodes, 0,
+			classification.VersionMatchingNormalized)
+		Expect(err).To(BeNil())
+		Expect(exceeded).To(BeTrue())
+
+		nodes = append(nodes, getNodeWithKubeletVersion("v1.23.17"))
+		exceeded, err = classification.IsKubeletVersionSkewExceeded(apiServerVersion, nodes, 2,
+			classification.VersionMatchingNormalized)
+		Expect(err).To(BeNil())
+		Expect(exceeded).To(BeTrue())
+
+		nodes = []corev1.Node{getNodeWithKubeletVersion("not-a-version")}
+		_, err = classification.IsKubeletVersionSkewExceeded(apiServerVersion, nodes, 2,
+			classification.VersionMatchingNormalized)
+		Expect(err).ToNot(BeNil())
+	})
+
+	It("HasKubeletVersionSkewConstraint returns true only when kubeletVersionSkew is set", func() {
+		classifier := getClassifierWithKubernetesConstraints(version25, libsveltosv1alpha1.ComparisonEqual)
+		Expect(classification.HasKubeletVersionSkewConstraint(classifier)).To(BeFalse())
+
+		classifier.Annotations = map[string]string{
+			classification.ClassifierExtensionAnnotation: `kubeletVersionSkew:
+  minorVersions: 1`,
+		}
+		Expect(classification.HasKubeletVersionSkewConstraint(classifier)).To(BeTrue())
+	})
+})
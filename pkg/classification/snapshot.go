@@ -0,0 +1,61 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// snapshotAttempts is the number of times evaluation is attempted when
+	// the snapshot resourceVersion is no longer available
+	snapshotAttempts = 3
+)
+
+// evaluationSnapshot pins all List calls done while evaluating one Classifier
+// to the same resourceVersion.
+// resourceVersion is the etcd revision, which is global across all resource types
+// stored in the same etcd. Listing at an exact resourceVersion returns the state
+// all resources had at that revision.
+type evaluationSnapshot struct {
+	// resourceVersion is the resourceVersion of the first List. Empty till then.
+	resourceVersion string
+}
+
+// setListOptions pins options to the snapshot resourceVersion, if already known
+func (s *evaluationSnapshot) setListOptions(options *metav1.ListOptions) {
+	if s == nil || s.resourceVersion == "" {
+		return
+	}
+	options.ResourceVersion = s.resourceVersion
+	options.ResourceVersionMatch = metav1.ResourceVersionMatchExact
+}
+
+// update records resourceVersion if this is the first List of the snapshot
+func (s *evaluationSnapshot) update(resourceVersion string) {
+	if s == nil || s.resourceVersion != "" {
+		return
+	}
+	s.resourceVersion = resourceVersion
+}
+
+// isSnapshotExpired returns true if err indicates snapshot resourceVersion
+// has been compacted and evaluation needs a new snapshot
+func isSnapshotExpired(err error) bool {
+	return apierrors.IsResourceExpired(err) || apierrors.IsGone(err)
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog/v2/klogr"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+	libsveltosutils "github.com/projectsveltos/libsveltos/lib/utils"
+)
+
+var _ = Describe("Manager: consistent snapshot", func() {
+	BeforeEach(func() {
+		classification.Reset()
+	})
+
+	It("evaluationSnapshot pins List calls to first resourceVersion", func() {
+		snapshot := &classification.EvaluationSnapshot{}
+
+		options := metav1.ListOptions{}
+		classification.SetSnapshotListOptions(snapshot, &options)
+		Expect(options.ResourceVersion).To(BeEmpty())
+		Expect(options.ResourceVersionMatch).To(BeEmpty())
+
+		resourceVersion := "100"
+		classification.UpdateSnapshot(snapshot, resourceVersion)
+		// Only first resourceVersion is kept
+		classification.UpdateSnapshot(snapshot, "200")
+
+		classification.SetSnapshotListOptions(snapshot, &options)
+		Expect(options.ResourceVersion).To(Equal(resourceVersion))
+		Expect(options.ResourceVersionMatch).To(Equal(metav1.ResourceVersionMatchExact))
+
+		// nil snapshot leaves options untouched
+		options = metav1.ListOptions{}
+		classification.SetSnapshotListOptions(nil, &options)
+		Expect(options.ResourceVersion).To(BeEmpty())
+	})
+
+	It("isSnapshotExpired detects compacted resourceVersion", func() {
+		gr := schema.GroupResource{Resource: "pods"}
+		Expect(classification.IsSnapshotExpired(apierrors.NewResourceExpired("too old resource version"))).To(BeTrue())
+		Expect(classification.IsSnapshotExpired(apierrors.NewGone("gone"))).To(BeTrue())
+		Expect(classification.IsSnapshotExpired(apierrors.NewNotFound(gr, randomString()))).To(BeFalse())
+	})
+
+	It("areResourcesAMatch evaluates constraints in a consistent snapshot", func() {
+		countMin := 1
+		namespace := randomString()
+		classifier := &libsveltosv1alpha1.Classifier{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: randomString(),
+			},
+			Spec: libsveltosv1alpha1.ClassifierSpec{
+				ClassifierLabels: []libsveltosv1alpha1.ClassifierLabel{
+					{Key: randomString(), Value: randomString()},
+				},
+				DeployedResourceConstraints: []libsveltosv1alpha1.DeployedResourceConstraint{
+					{Namespace: namespace, MinCount: &countMin, Group: "", Version: "v1", Kind: "Pod"},
+					{MinCount: &countMin, Group: "", Version: "v1", Kind: "Namespace"},
+				},
+			},
+		}
+
+		ns := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: namespace,
+			},
+		}
+		Expect(testEnv.Create(context.TODO(), ns)).To(Succeed())
+		Expect(waitForObject(context.TODO(), testEnv.Client, ns)).To(Succeed())
+
+		watcherCtx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		classification.InitializeManager(watcherCtx, klogr.New(), testEnv.Config, testEnv.Client,
+			randomString(), randomString(), libsveltosv1alpha1.ClusterTypeCapi, nil, 10, false)
+		classification.ApplyOptions(classification.WithConsistentSnapshot(true))
+		manager := classification.GetManager()
+
+		isMatch, err := classification.AreResourcesAMatch(manager, watcherCtx, classifier)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeFalse())
+
+		pod := fmt.Sprintf(podTemplate, namespace, randomString())
+		u, err := libsveltosutils.GetUnstructured([]byte(pod))
+		Expect(err).To(BeNil())
+		Expect(testEnv.Create(context.TODO(), u)).To(Succeed())
+		Expect(waitForObject(context.TODO(), testEnv.Client, u)).To(Succeed())
+
+		Eventually(func() bool {
+			isMatch, err = classification.AreResourcesAMatch(manager, watcherCtx, classifier)
+			return err == nil && isMatch
+		}, timeout, pollingInterval).Should(BeTrue())
+	})
+})
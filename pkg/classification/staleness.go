@@ -0,0 +1,95 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+)
+
+const (
+	// ClassifierReportStaleFactsAnnotation is set on a ClassifierReport when the
+	// evaluation used cached facts older than the configured max staleness. Value is
+	// a JSON object mapping each stale fact to the time it was last refreshed.
+	// Classifier is still evaluated, so consumers can tell fresh verdicts from
+	// verdicts based on cached data.
+	ClassifierReportStaleFactsAnnotation = "classifier.projectsveltos.io/stale-facts"
+
+	// FactDiscovery is the cached discovery data (served API groups and resources)
+	FactDiscovery = "discovery"
+
+	// FactRemoteClassifiers is the cache of Classifiers fetched from the
+	// management cluster in remote mode
+	FactRemoteClassifiers = "remoteClassifiers"
+)
+
+// recordFactRefresh records that the cached fact was refreshed at time at
+func (m *manager) recordFactRefresh(fact string, at time.Time) {
+	if m.factMu == nil {
+		return
+	}
+
+	m.factMu.Lock()
+	defer m.factMu.Unlock()
+	m.factRefreshes[fact] = at
+}
+
+// getStaleFacts returns the cached facts last refreshed more than maxFactStaleness
+// before now, along with the time they were refreshed
+func (m *manager) getStaleFacts(now time.Time) map[string]time.Time {
+	if m.maxFactStaleness == 0 || m.factMu == nil {
+		return nil
+	}
+
+	m.factMu.Lock()
+	defer m.factMu.Unlock()
+
+	var stale map[string]time.Time
+	for fact, refreshed := range m.factRefreshes {
+		if now.Sub(refreshed) <= m.maxFactStaleness {
+			continue
+		}
+		if stale == nil {
+			stale = make(map[string]time.Time)
+		}
+		stale[fact] = refreshed
+	}
+	return stale
+}
+
+// setReportStaleFacts sets ClassifierReportStaleFactsAnnotation when any cached fact
+// is stale and removes it otherwise
+func (m *manager) setReportStaleFacts(report *libsveltosv1alpha1.ClassifierReport, now time.Time) {
+	value := ""
+	if stale := m.getStaleFacts(now); len(stale) != 0 {
+		refreshed := make(map[string]string, len(stale))
+		for fact, at := range stale {
+			refreshed[fact] = at.UTC().Format(time.RFC3339)
+		}
+		data, err := json.Marshal(refreshed)
+		if err != nil {
+			m.log.V(logs.LogInfo).Info(fmt.Sprintf("failed to encode stale facts: %v", err))
+		} else {
+			value = string(data)
+		}
+	}
+	setReportAnnotation(report, ClassifierReportStaleFactsAnnotation, value)
+}
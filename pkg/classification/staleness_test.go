@@ -0,0 +1,88 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/projectsveltos/classifier-agent/internal/utils"
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: fact staleness", func() {
+
+	BeforeEach(func() {
+		classification.Reset()
+	})
+
+	It("createClassifierReport annotates reports with stale facts", func() {
+		classifier := getClassifierWithKubernetesConstraints(version25, libsveltosv1alpha1.ComparisonEqual)
+		c := classification.InitializeManagerWithObjects(nil, classifier)
+		classification.ApplyOptions(classification.WithMaxFactStaleness(time.Minute))
+		manager := classification.GetManager()
+
+		refreshed := time.Now().Add(-time.Hour).Truncate(time.Second)
+		classification.RecordFactRefresh(manager, classification.FactDiscovery, refreshed)
+		classification.RecordFactRefresh(manager, classification.FactRemoteClassifiers, time.Now())
+
+		Expect(classification.CreateClassifierReport(manager, context.TODO(), classifier, true, nil)).
+			To(Succeed())
+
+		classifierReport := &libsveltosv1alpha1.ClassifierReport{}
+		key := types.NamespacedName{Namespace: utils.ReportNamespace, Name: classifier.Name}
+		Expect(c.Get(context.TODO(), key, classifierReport)).To(Succeed())
+		// Evaluation is not failed because of stale facts
+		Expect(classifierReport.Spec.Match).To(BeTrue())
+		Expect(classifierReport.Annotations).To(HaveKey(classification.ClassifierReportStaleFactsAnnotation))
+
+		stale := map[string]string{}
+		Expect(json.Unmarshal([]byte(classifierReport.Annotations[classification.ClassifierReportStaleFactsAnnotation]),
+			&stale)).To(Succeed())
+		Expect(stale).To(Equal(map[string]string{
+			classification.FactDiscovery: refreshed.UTC().Format(time.RFC3339),
+		}))
+
+		classification.RecordFactRefresh(manager, classification.FactDiscovery, time.Now())
+		Expect(classification.CreateClassifierReport(manager, context.TODO(), classifier, true, nil)).
+			To(Succeed())
+		Expect(c.Get(context.TODO(), key, classifierReport)).To(Succeed())
+		Expect(classifierReport.Annotations).ToNot(HaveKey(classification.ClassifierReportStaleFactsAnnotation))
+	})
+
+	It("createClassifierReport does not report staleness when max staleness is not set", func() {
+		classifier := getClassifierWithKubernetesConstraints(version25, libsveltosv1alpha1.ComparisonEqual)
+		c := classification.InitializeManagerWithObjects(nil, classifier)
+		manager := classification.GetManager()
+
+		classification.RecordFactRefresh(manager, classification.FactDiscovery, time.Now().Add(-24*time.Hour))
+		Expect(classification.CreateClassifierReport(manager, context.TODO(), classifier, false, nil)).
+			To(Succeed())
+
+		classifierReport := &libsveltosv1alpha1.ClassifierReport{}
+		key := types.NamespacedName{Namespace: utils.ReportNamespace, Name: classifier.Name}
+		Expect(c.Get(context.TODO(), key, classifierReport)).To(Succeed())
+		Expect(classifierReport.Annotations).ToNot(HaveKey(classification.ClassifierReportStaleFactsAnnotation))
+	})
+})
@@ -0,0 +1,59 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// stateFileMode is the mode of the classification state file, readable by
+// node-local tooling running as another user
+const stateFileMode = 0o644
+
+// writeStateFile writes the current classification to m.stateFile. The state is
+// written to a temporary file in the same directory, which is then renamed, so
+// readers never see a partially written state.
+func (m *manager) writeStateFile() error {
+	dir, base := filepath.Split(m.stateFile)
+	if dir == "" {
+		dir = "."
+	}
+	f, err := os.CreateTemp(dir, "."+base+"-*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	// No-op once renamed
+	defer os.Remove(tmp)
+
+	if err := WriteClassificationState(f, m.GetClassificationState(), m.stateFileFormat); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp, stateFileMode); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.stateFile)
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+)
+
+var _ = Describe("Manager: state file", func() {
+	BeforeEach(func() {
+		classification.InitializeManagerWithObjects(nil)
+	})
+
+	It("writeStateFile atomically replaces the state file", func() {
+		dir := GinkgoT().TempDir()
+		path := filepath.Join(dir, "classification.json")
+		classification.ApplyOptions(classification.WithStateFile(path, classification.ClassificationStateFlat))
+		manager := classification.GetManager()
+
+		classification.RecordEvaluation(manager, "gpu", time.Now(), true, nil, nil)
+		Expect(classification.WriteStateFile(manager)).To(Succeed())
+
+		data, err := os.ReadFile(path)
+		Expect(err).ToNot(HaveOccurred())
+		flat := map[string]string{}
+		Expect(json.Unmarshal(data, &flat)).To(Succeed())
+		Expect(flat).To(Equal(map[string]string{"gpu": "true"}))
+
+		classification.RecordEvaluation(manager, "gpu", time.Now(), false, nil, nil)
+		Expect(classification.WriteStateFile(manager)).To(Succeed())
+		data, err = os.ReadFile(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(json.Unmarshal(data, &flat)).To(Succeed())
+		Expect(flat).To(Equal(map[string]string{"gpu": "false"}))
+
+		info, err := os.Stat(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0o644)))
+
+		// No temporary file is left behind
+		entries, err := os.ReadDir(dir)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(entries).To(HaveLen(1))
+	})
+
+	It("writeStateFile fails when directory does not exist", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "missing", "classification.json")
+		classification.ApplyOptions(classification.WithStateFile(path, classification.ClassificationStateJSON))
+		Expect(classification.WriteStateFile(classification.GetManager())).ToNot(Succeed())
+	})
+})
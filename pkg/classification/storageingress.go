@@ -0,0 +1,165 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+const (
+	// defaultStorageClassAnnotation marks a StorageClass as the default one. Older
+	// clusters may still use the beta annotation.
+	defaultStorageClassAnnotation     = "storageclass.kubernetes.io/is-default-class"
+	betaDefaultStorageClassAnnotation = "storageclass.beta.kubernetes.io/is-default-class"
+)
+
+var (
+	storageClassGVK = storagev1.SchemeGroupVersion.WithKind("StorageClass")
+	ingressClassGVK = networkingv1.SchemeGroupVersion.WithKind("IngressClass")
+)
+
+// IngressClassConstraint is satisfied when at least one IngressClass matches it.
+// Name or Controller must be set.
+type IngressClassConstraint struct {
+	// Name is the name of the IngressClass
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Controller is the controller implementing the IngressClass, for instance
+	// "k8s.io/ingress-nginx"
+	// +optional
+	Controller string `json:"controller,omitempty"`
+
+	// Default, when set, requires the IngressClass to be the default one
+	// +optional
+	Default bool `json:"default,omitempty"`
+}
+
+// isDefaultStorageClassAMatch returns true if Classifier has no DefaultStorageClass
+// or if the presence of a default StorageClass is as required
+func (m *manager) isDefaultStorageClassAMatch(ctx context.Context,
+	classifier *libsveltosv1alpha1.Classifier) (bool, error) {
+
+	extension, err := getClassifierExtension(classifier)
+	if err != nil {
+		return false, err
+	}
+
+	if extension.DefaultStorageClass == nil {
+		return true, nil
+	}
+
+	storageClasses := &storagev1.StorageClassList{}
+	if err := m.List(ctx, storageClasses); err != nil {
+		return false, err
+	}
+
+	defaultStorageClass := getDefaultStorageClass(storageClasses.Items)
+	if defaultStorageClass != "" {
+		addEvaluationNote(ctx, fmt.Sprintf("default storage class is %s", defaultStorageClass))
+	} else {
+		addEvaluationNote(ctx, "no default storage class")
+	}
+	return (defaultStorageClass != "") == *extension.DefaultStorageClass, nil
+}
+
+// getDefaultStorageClass returns the name of the default StorageClass, empty if none
+func getDefaultStorageClass(storageClasses []storagev1.StorageClass) string {
+	for i := range storageClasses {
+		annotations := storageClasses[i].Annotations
+		if strings.EqualFold(annotations[defaultStorageClassAnnotation], "true") ||
+			strings.EqualFold(annotations[betaDefaultStorageClassAnnotation], "true") {
+
+			return storageClasses[i].Name
+		}
+	}
+	return ""
+}
+
+// areIngressClassesAMatch returns true if all IngressClassConstraints are satisfied
+func (m *manager) areIngressClassesAMatch(ctx context.Context,
+	classifier *libsveltosv1alpha1.Classifier) (bool, error) {
+
+	extension, err := getClassifierExtension(classifier)
+	if err != nil {
+		return false, err
+	}
+
+	if len(extension.IngressClassConstraints) == 0 {
+		return true, nil
+	}
+
+	ingressClasses := &networkingv1.IngressClassList{}
+	if err := m.List(ctx, ingressClasses); err != nil {
+		return false, err
+	}
+
+	for i := range extension.IngressClassConstraints {
+		constraint := &extension.IngressClassConstraints[i]
+		if constraint.Name == "" && constraint.Controller == "" {
+			return false, newInvalidClassifierError(
+				fmt.Errorf("ingress class constraint %d: name or controller is required", i))
+		}
+		if !isIngressClassConstraintAMatch(constraint, ingressClasses.Items) {
+			addEvaluationNote(ctx, fmt.Sprintf("ingress class constraint %d: no ingress class matches", i))
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// isIngressClassConstraintAMatch returns true if at least one IngressClass satisfies constraint
+func isIngressClassConstraintAMatch(constraint *IngressClassConstraint, ingressClasses []networkingv1.IngressClass) bool {
+	for i := range ingressClasses {
+		ingressClass := &ingressClasses[i]
+		if constraint.Name != "" && ingressClass.Name != constraint.Name {
+			continue
+		}
+		if constraint.Controller != "" && ingressClass.Spec.Controller != constraint.Controller {
+			continue
+		}
+		if constraint.Default &&
+			!strings.EqualFold(ingressClass.Annotations[networkingv1.AnnotationIsDefaultIngressClass], "true") {
+
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// getStorageIngressGVKs returns the GVKs of the StorageClasses and IngressClasses
+// extension depends on
+func getStorageIngressGVKs(extension *ClassifierExtension) []schema.GroupVersionKind {
+	gvks := make([]schema.GroupVersionKind, 0)
+	if extension.DefaultStorageClass != nil {
+		gvks = append(gvks, storageClassGVK)
+	}
+	if len(extension.IngressClassConstraints) > 0 {
+		gvks = append(gvks, ingressClassGVK)
+	}
+	return gvks
+}
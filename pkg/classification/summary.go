@@ -0,0 +1,113 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/projectsveltos/classifier-agent/internal/utils"
+)
+
+const (
+	// EvaluationSummaryName is the name of the ConfigMap, in the report namespace,
+	// summarizing the outcome of all Classifiers
+	EvaluationSummaryName = "classifier-agent-summary"
+
+	// EvaluationSummaryKey is the ConfigMap data key containing the EvaluationSummary
+	EvaluationSummaryKey = "summary"
+)
+
+// ClassifierSummary contains the most recent evaluation result of a Classifier
+type ClassifierSummary struct {
+	// Name of the Classifier
+	Name string `json:"name"`
+
+	EvaluationResult
+}
+
+// EvaluationSummary lists, for every Classifier, the most recent evaluation result
+type EvaluationSummary struct {
+	// LastUpdateTime is the time summary was generated
+	LastUpdateTime time.Time `json:"lastUpdateTime"`
+
+	// Classifiers contains one entry per Classifier, sorted by name
+	Classifiers []ClassifierSummary `json:"classifiers"`
+}
+
+// getEvaluationSummary builds the EvaluationSummary from the most recent
+// evaluation results
+func (m *manager) getEvaluationSummary() *EvaluationSummary {
+	m.historyMu.RLock()
+	defer m.historyMu.RUnlock()
+
+	summary := &EvaluationSummary{
+		LastUpdateTime: time.Now(),
+		Classifiers:    make([]ClassifierSummary, 0, len(m.latest)),
+	}
+	for name, result := range m.latest {
+		summary.Classifiers = append(summary.Classifiers,
+			ClassifierSummary{Name: name, EvaluationResult: result})
+	}
+
+	sort.Slice(summary.Classifiers, func(i, j int) bool {
+		return summary.Classifiers[i].Name < summary.Classifiers[j].Name
+	})
+
+	return summary
+}
+
+// updateEvaluationSummary creates or updates the ConfigMap containing the EvaluationSummary.
+// The whole summary is stored in a single data key, so readers never see a partially
+// updated summary.
+func (m *manager) updateEvaluationSummary(ctx context.Context) error {
+	data, err := json.Marshal(m.getEvaluationSummary())
+	if err != nil {
+		return err
+	}
+
+	configMap := &corev1.ConfigMap{}
+	err = m.Get(ctx, types.NamespacedName{Namespace: utils.ReportNamespace, Name: EvaluationSummaryName},
+		configMap)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: utils.ReportNamespace,
+				Name:      EvaluationSummaryName,
+			},
+			Data: map[string]string{
+				EvaluationSummaryKey: string(data),
+			},
+		}
+		return m.Create(ctx, configMap)
+	}
+
+	configMap.Data = map[string]string{
+		EvaluationSummaryKey: string(data),
+	}
+	return m.Update(ctx, configMap)
+}
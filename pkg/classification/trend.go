@@ -0,0 +1,209 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+const (
+	// DefaultTrendRetention is how long resource counts are kept when no
+	// retention is configured. It bounds the window of TrendConstraints.
+	DefaultTrendRetention = 24 * time.Hour
+
+	// maxTrendSamples is the maximum number of samples kept per constraint
+	maxTrendSamples = 256
+)
+
+// trendSample is the number of resources matching a constraint at a given time
+type trendSample struct {
+	timestamp time.Time
+	count     int
+}
+
+// trendStore keeps, per Classifier and constraint, the number of matching
+// resources over time. Samples are only added when the count changes, so the
+// count at any time is the one of the most recent sample taken before.
+type trendStore struct {
+	mu        sync.Mutex
+	retention time.Duration
+	// samples contains, per Classifier and constraint, samples oldest first
+	// Key: Classifier name, then constraint key
+	samples map[string]map[string][]trendSample
+}
+
+func newTrendStore(retention time.Duration) *trendStore {
+	return &trendStore{
+		retention: retention,
+		samples:   make(map[string]map[string][]trendSample),
+	}
+}
+
+// add records count at timestamp, and drops samples not needed anymore
+func (s *trendStore) add(classifierName, key string, timestamp time.Time, count int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	perClassifier, ok := s.samples[classifierName]
+	if !ok {
+		perClassifier = make(map[string][]trendSample)
+		s.samples[classifierName] = perClassifier
+	}
+
+	samples := perClassifier[key]
+	if len(samples) == 0 || samples[len(samples)-1].count != count {
+		samples = append(samples, trendSample{timestamp: timestamp, count: count})
+	}
+
+	// The most recent sample older than retention is kept, as it is the
+	// count at the beginning of the retention period
+	cutoff := timestamp.Add(-s.retention)
+	drop := 0
+	for drop+1 < len(samples) && !samples[drop+1].timestamp.After(cutoff) {
+		drop++
+	}
+	if len(samples)-drop > maxTrendSamples {
+		drop = len(samples) - maxTrendSamples
+	}
+	perClassifier[key] = append(samples[:0:0], samples[drop:]...)
+}
+
+// countAt returns the count at timestamp. Returns false if there is no sample
+// taken at or before timestamp.
+func (s *trendStore) countAt(classifierName, key string, timestamp time.Time) (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	samples := s.samples[classifierName][key]
+	for i := len(samples) - 1; i >= 0; i-- {
+		if !samples[i].timestamp.After(timestamp) {
+			return samples[i].count, true
+		}
+	}
+	return 0, false
+}
+
+func (s *trendStore) remove(classifierName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.samples, classifierName)
+}
+
+// getTrendKey returns the key identifying a constraint in the trendStore. Any change
+// to what the constraint counts results in a different key, so a new history.
+// Thresholds and the trend itself do not change what is counted.
+func getTrendKey(constraint *ResourceConstraint) (string, error) {
+	return getNormalizedConstraint(constraint, "minCount", "maxCount", "trend")
+}
+
+// validateTrendConstraint returns an invalidClassifierError if window is not
+// within retention or MinChangePercent is greater than MaxChangePercent
+func validateTrendConstraint(trend *TrendConstraint, retention time.Duration) error {
+	if trend.Window.Duration <= 0 || trend.Window.Duration > retention {
+		return newInvalidClassifierError(fmt.Errorf("trend window %s is not between 0 and %s",
+			trend.Window.Duration, retention))
+	}
+
+	if trend.MinChangePercent != nil && trend.MaxChangePercent != nil &&
+		*trend.MinChangePercent > *trend.MaxChangePercent {
+
+		return newInvalidClassifierError(fmt.Errorf("minChangePercent %d is greater than maxChangePercent %d",
+			*trend.MinChangePercent, *trend.MaxChangePercent))
+	}
+
+	return nil
+}
+
+// getTrendChange returns the change, in percent, from past to current.
+// Any increase from zero is an infinite change.
+func getTrendChange(past, current int) float64 {
+	if past == 0 {
+		if current == 0 {
+			return 0
+		}
+		return math.Inf(1)
+	}
+	return float64(current-past) * percentMax / float64(past)
+}
+
+// isTrendAMatch returns true if change from past to current is within trend bounds
+func isTrendAMatch(trend *TrendConstraint, past, current int) bool {
+	change := getTrendChange(past, current)
+
+	if trend.MinChangePercent != nil && change < float64(*trend.MinChangePercent) {
+		return false
+	}
+
+	if trend.MaxChangePercent != nil && change > float64(*trend.MaxChangePercent) {
+		return false
+	}
+
+	return true
+}
+
+// evaluateTrendConstraint counts resources matching constraint, records the count
+// and verifies how it changed over the trend window.
+// Constraint is not satisfied till the count at the beginning of the window is known.
+func (m *manager) evaluateTrendConstraint(ctx context.Context, classifier *libsveltosv1alpha1.Classifier,
+	constraint *ResourceConstraint, snapshot *evaluationSnapshot) (bool, error) {
+
+	if err := validateTrendConstraint(constraint.Trend, m.trends.retention); err != nil {
+		return false, err
+	}
+
+	count, _, served, err := m.countResources(ctx, classifier, constraint, snapshot, nil)
+	if err != nil || !served {
+		return false, err
+	}
+
+	return m.isTrendCountAMatch(ctx, classifier, constraint, count)
+}
+
+// isTrendCountAMatch records count in the constraint history and verifies its
+// change over the trend window
+func (m *manager) isTrendCountAMatch(ctx context.Context, classifier *libsveltosv1alpha1.Classifier,
+	constraint *ResourceConstraint, count int) (bool, error) {
+
+	trend := constraint.Trend
+	classifierName := ""
+	if classifier != nil {
+		classifierName = classifier.Name
+	}
+	key, err := getTrendKey(constraint)
+	if err != nil {
+		return false, err
+	}
+	now := time.Now()
+
+	past, ok := m.trends.countAt(classifierName, key, now.Add(-trend.Window.Duration))
+	m.trends.add(classifierName, key, now, count)
+	if !ok {
+		addEvaluationNote(ctx, fmt.Sprintf("%s: %d resources, no count known %s ago",
+			constraint.Kind, count, trend.Window.Duration))
+		return false, nil
+	}
+
+	addEvaluationNote(ctx, fmt.Sprintf("%s: %d resources, %d %s ago (%+.0f%%)",
+		constraint.Kind, count, past, trend.Window.Duration, getTrendChange(past, count)))
+	return isTrendAMatch(trend, past, count), nil
+}
@@ -0,0 +1,211 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: trend constraints", func() {
+	const (
+		classifierName = "trend"
+		key            = "v1/Pod"
+	)
+
+	It("trendStore returns count at a given time", func() {
+		store := classification.NewTrendStore(time.Hour)
+		start := time.Now()
+
+		_, ok := classification.TrendStoreCountAt(store, classifierName, key, start)
+		Expect(ok).To(BeFalse())
+
+		classification.TrendStoreAdd(store, classifierName, key, start, 10)
+		classification.TrendStoreAdd(store, classifierName, key, start.Add(10*time.Minute), 10)
+		classification.TrendStoreAdd(store, classifierName, key, start.Add(20*time.Minute), 15)
+
+		_, ok = classification.TrendStoreCountAt(store, classifierName, key, start.Add(-time.Minute))
+		Expect(ok).To(BeFalse())
+
+		count, ok := classification.TrendStoreCountAt(store, classifierName, key, start.Add(15*time.Minute))
+		Expect(ok).To(BeTrue())
+		Expect(count).To(Equal(10))
+
+		count, ok = classification.TrendStoreCountAt(store, classifierName, key, start.Add(30*time.Minute))
+		Expect(ok).To(BeTrue())
+		Expect(count).To(Equal(15))
+
+		classification.TrendStoreRemove(store, classifierName)
+		_, ok = classification.TrendStoreCountAt(store, classifierName, key, start.Add(30*time.Minute))
+		Expect(ok).To(BeFalse())
+	})
+
+	It("trendStore keeps the count at the beginning of the retention period", func() {
+		store := classification.NewTrendStore(time.Hour)
+		start := time.Now()
+
+		classification.TrendStoreAdd(store, classifierName, key, start, 10)
+		classification.TrendStoreAdd(store, classifierName, key, start.Add(time.Minute), 20)
+		classification.TrendStoreAdd(store, classifierName, key, start.Add(3*time.Hour), 30)
+
+		// Sample taken at start is dropped, the one taken a minute later is the
+		// count one hour before last sample
+		_, ok := classification.TrendStoreCountAt(store, classifierName, key, start)
+		Expect(ok).To(BeFalse())
+		count, ok := classification.TrendStoreCountAt(store, classifierName, key, start.Add(2*time.Hour))
+		Expect(ok).To(BeTrue())
+		Expect(count).To(Equal(20))
+	})
+
+	It("isTrendAMatch verifies change is within bounds", func() {
+		minChange := 50
+		maxChange := -10
+		increase := &classification.TrendConstraint{MinChangePercent: &minChange}
+		decrease := &classification.TrendConstraint{MaxChangePercent: &maxChange}
+
+		Expect(classification.IsTrendAMatch(increase, 10, 15)).To(BeTrue())
+		Expect(classification.IsTrendAMatch(increase, 10, 14)).To(BeFalse())
+		Expect(classification.IsTrendAMatch(increase, 0, 1)).To(BeTrue())
+		Expect(classification.IsTrendAMatch(increase, 0, 0)).To(BeFalse())
+
+		Expect(classification.IsTrendAMatch(decrease, 10, 9)).To(BeTrue())
+		Expect(classification.IsTrendAMatch(decrease, 10, 10)).To(BeFalse())
+	})
+
+	It("validateTrendConstraint rejects invalid windows and bounds", func() {
+		minChange := 50
+		maxChange := 10
+		trend := &classification.TrendConstraint{Window: metav1.Duration{Duration: time.Hour}}
+		Expect(classification.ValidateTrendConstraint(trend, classification.DefaultTrendRetention)).To(Succeed())
+
+		trend.Window.Duration = 48 * time.Hour
+		Expect(classification.ValidateTrendConstraint(trend, classification.DefaultTrendRetention)).ToNot(Succeed())
+
+		trend.Window.Duration = time.Hour
+		trend.MinChangePercent = &minChange
+		trend.MaxChangePercent = &maxChange
+		Expect(classification.ValidateTrendConstraint(trend, classification.DefaultTrendRetention)).ToNot(Succeed())
+	})
+
+	It("getTrendKey changes with anything selecting resources", func() {
+		minCount := 1
+		getConstraint := func() *classification.ResourceConstraint {
+			constraint := &classification.ResourceConstraint{
+				ResourceNames: []string{"a", "b"},
+				Trend:         &classification.TrendConstraint{Window: metav1.Duration{Duration: time.Hour}},
+			}
+			constraint.Version = "v1"
+			constraint.Kind = "Pod"
+			return constraint
+		}
+
+		key, err := classification.GetTrendKey(getConstraint())
+		Expect(err).To(BeNil())
+
+		// Thresholds, trend and list order do not change what is counted
+		constraint := getConstraint()
+		constraint.MinCount = &minCount
+		constraint.Trend.Window.Duration = 2 * time.Hour
+		constraint.ResourceNames = []string{"b", "a"}
+		Expect(classification.GetTrendKey(constraint)).To(Equal(key))
+
+		constraint = getConstraint()
+		constraint.ExcludedNamespaces = []string{"kube-system"}
+		Expect(classification.GetTrendKey(constraint)).ToNot(Equal(key))
+
+		constraint = getConstraint()
+		constraint.AnnotationFilters = []libsveltosv1alpha1.LabelFilter{
+			{Key: "team", Operation: libsveltosv1alpha1.OperationEqual, Value: "web"},
+		}
+		Expect(classification.GetTrendKey(constraint)).ToNot(Equal(key))
+
+		constraint = getConstraint()
+		constraint.ResourceNames = []string{"a"}
+		Expect(classification.GetTrendKey(constraint)).ToNot(Equal(key))
+	})
+
+	It("trend constraints are verified when resources are passed to LuaScript", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			switch r.URL.Path {
+			case "/api":
+				_, _ = w.Write([]byte(`{"kind":"APIVersions","versions":["v1"]}`))
+			case "/api/v1":
+				_, _ = w.Write([]byte(`{"kind":"APIResourceList","groupVersion":"v1","resources":[` +
+					`{"name":"pods","singularName":"pod","namespaced":true,"kind":"Pod","verbs":["list"]}]}`))
+			case "/apis":
+				_, _ = w.Write([]byte(`{"kind":"APIGroupList","apiVersion":"v1","groups":[]}`))
+			case "/api/v1/pods":
+				_, _ = w.Write([]byte(`{"apiVersion":"v1","kind":"PodList","metadata":{},"items":[` +
+					`{"apiVersion":"v1","kind":"Pod","metadata":{"namespace":"default","name":"a"}}]}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer server.Close()
+
+		classification.InitializeManagerWithObjects(&rest.Config{Host: server.URL})
+
+		classifier := &libsveltosv1alpha1.Classifier{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: randomString(),
+				Annotations: map[string]string{
+					classification.ClassifierExtensionAnnotation: `deployedResourceConstraints:
+- version: v1
+  kind: Pod
+  trend:
+    window: 1h
+    minChangePercent: 50
+luaScript: |
+  function evaluate(resources)
+    return true
+  end
+`,
+				},
+			},
+		}
+
+		// Count one hour ago is not known yet
+		isMatch, err := classification.AreResourcesAMatch(classification.GetManager(), context.TODO(), classifier)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeFalse())
+
+		classifier.Annotations[classification.ClassifierExtensionAnnotation] = `deployedResourceConstraints:
+- version: v1
+  kind: Pod
+  trend:
+    window: 48h
+luaScript: |
+  function evaluate(resources)
+    return true
+  end
+`
+		_, err = classification.AreResourcesAMatch(classification.GetManager(), context.TODO(), classifier)
+		Expect(classification.IsInvalidClassifierError(err)).To(BeTrue())
+	})
+})
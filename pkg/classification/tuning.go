@@ -0,0 +1,272 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// TuningPath is the path the tuning endpoint is served at
+	TuningPath = "/debug/tuning"
+
+	// maxTuningAuditEntries is the number of tuning changes kept in memory
+	maxTuningAuditEntries = 100
+)
+
+// Tuning contains the runtime tuning knobs. When updating, only knobs
+// which are set are changed.
+type Tuning struct {
+	// Interval is the evaluation interval, used when interval is not adaptive
+	Interval *metav1.Duration `json:"interval,omitempty"`
+
+	// MinInterval and MaxInterval bound the adaptive evaluation interval.
+	// A zero MaxInterval makes the evaluation interval fixed.
+	MinInterval *metav1.Duration `json:"minInterval,omitempty"`
+	MaxInterval *metav1.Duration `json:"maxInterval,omitempty"`
+
+	// BroadConstraintThreshold is the number of resources above which a
+	// constraint with no namespace nor label filter is considered broad
+	BroadConstraintThreshold *int `json:"broadConstraintThreshold,omitempty"`
+}
+
+// TuningChange records a change to a tuning knob
+type TuningChange struct {
+	// Time the change was made at
+	Time time.Time `json:"time"`
+
+	// User who made the change
+	User string `json:"user"`
+
+	// Knob is the name of the changed knob
+	Knob string `json:"knob"`
+
+	// Old and New are the knob values before and after the change
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+// TuningState is served by the tuning endpoint
+type TuningState struct {
+	// Tuning contains the current value of all knobs
+	Tuning Tuning `json:"tuning"`
+
+	// Changes lists the most recent changes, oldest first
+	Changes []TuningChange `json:"changes"`
+}
+
+// tuningAuthenticator returns the user making request. Returns an error if
+// request is not authenticated or user is not allowed to make it.
+type tuningAuthenticator func(ctx context.Context, r *http.Request) (string, error)
+
+// getIntervals returns evaluation interval and adaptive interval bounds
+func (m *manager) getIntervals() (interval, minInterval, maxInterval time.Duration) {
+	m.tuningMu.RLock()
+	defer m.tuningMu.RUnlock()
+	return m.interval, m.minInterval, m.maxInterval
+}
+
+func (m *manager) getBroadConstraintThreshold() int {
+	m.tuningMu.RLock()
+	defer m.tuningMu.RUnlock()
+	return m.broadConstraintThreshold
+}
+
+// getTuningState returns current tuning and most recent changes
+func (m *manager) getTuningState() *TuningState {
+	m.tuningMu.RLock()
+	defer m.tuningMu.RUnlock()
+
+	threshold := m.broadConstraintThreshold
+	state := &TuningState{
+		Tuning: Tuning{
+			Interval:                 &metav1.Duration{Duration: m.interval},
+			MinInterval:              &metav1.Duration{Duration: m.minInterval},
+			MaxInterval:              &metav1.Duration{Duration: m.maxInterval},
+			BroadConstraintThreshold: &threshold,
+		},
+		Changes: make([]TuningChange, len(m.tuningChanges)),
+	}
+	copy(state.Changes, m.tuningChanges)
+	return state
+}
+
+// validateTuning returns an error if applying tuning would result in invalid settings
+func validateTuning(tuning *Tuning, interval, minInterval, maxInterval time.Duration) error {
+	if tuning.Interval != nil {
+		interval = tuning.Interval.Duration
+	}
+	if tuning.MinInterval != nil {
+		minInterval = tuning.MinInterval.Duration
+	}
+	if tuning.MaxInterval != nil {
+		maxInterval = tuning.MaxInterval.Duration
+	}
+
+	if interval <= 0 {
+		return fmt.Errorf("interval must be positive")
+	}
+	if maxInterval < 0 || (maxInterval > 0 && (minInterval <= 0 || minInterval > maxInterval)) {
+		return fmt.Errorf("minInterval must be positive and not greater than maxInterval")
+	}
+	if tuning.BroadConstraintThreshold != nil && *tuning.BroadConstraintThreshold <= 0 {
+		return fmt.Errorf("broadConstraintThreshold must be positive")
+	}
+	return nil
+}
+
+// updateTuning applies tuning and records, in the audit log, every knob changed by user
+func (m *manager) updateTuning(tuning *Tuning, user string) error {
+	m.tuningMu.Lock()
+	defer m.tuningMu.Unlock()
+
+	if err := validateTuning(tuning, m.interval, m.minInterval, m.maxInterval); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	record := func(knob string, old, new interface{}) {
+		change := TuningChange{Time: now, User: user, Knob: knob,
+			Old: fmt.Sprint(old), New: fmt.Sprint(new)}
+		if change.Old == change.New {
+			return
+		}
+		m.log.WithName("audit").Info("tuning changed", "user", change.User, "knob", change.Knob,
+			"old", change.Old, "new", change.New)
+		m.tuningChanges = append(m.tuningChanges, change)
+		if len(m.tuningChanges) > maxTuningAuditEntries {
+			m.tuningChanges = m.tuningChanges[len(m.tuningChanges)-maxTuningAuditEntries:]
+		}
+	}
+
+	if tuning.Interval != nil {
+		record("interval", m.interval, tuning.Interval.Duration)
+		m.interval = tuning.Interval.Duration
+	}
+	if tuning.MinInterval != nil {
+		record("minInterval", m.minInterval, tuning.MinInterval.Duration)
+		m.minInterval = tuning.MinInterval.Duration
+	}
+	if tuning.MaxInterval != nil {
+		record("maxInterval", m.maxInterval, tuning.MaxInterval.Duration)
+		m.maxInterval = tuning.MaxInterval.Duration
+	}
+	if tuning.BroadConstraintThreshold != nil {
+		record("broadConstraintThreshold", m.broadConstraintThreshold, *tuning.BroadConstraintThreshold)
+		m.broadConstraintThreshold = *tuning.BroadConstraintThreshold
+	}
+
+	return nil
+}
+
+// authenticateTuningRequest authenticates the bearer token of request with a
+// TokenReview and verifies, with a SubjectAccessReview, user is allowed to use
+// the tuning endpoint with request method (get to read, post to update).
+func (m *manager) authenticateTuningRequest(ctx context.Context, r *http.Request) (string, error) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" || token == r.Header.Get("Authorization") {
+		return "", fmt.Errorf("bearer token is required")
+	}
+
+	tokenReview := &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token},
+	}
+	if err := m.Create(ctx, tokenReview); err != nil {
+		return "", err
+	}
+	if !tokenReview.Status.Authenticated {
+		return "", fmt.Errorf("authentication failed")
+	}
+
+	user := tokenReview.Status.User
+	extra := make(map[string]authorizationv1.ExtraValue, len(user.Extra))
+	for k, v := range user.Extra {
+		extra[k] = authorizationv1.ExtraValue(v)
+	}
+	accessReview := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   user.Username,
+			UID:    user.UID,
+			Groups: user.Groups,
+			Extra:  extra,
+			NonResourceAttributes: &authorizationv1.NonResourceAttributes{
+				Path: TuningPath,
+				Verb: strings.ToLower(r.Method),
+			},
+		},
+	}
+	if err := m.Create(ctx, accessReview); err != nil {
+		return "", err
+	}
+	if !accessReview.Status.Allowed {
+		return "", fmt.Errorf("user %s is not allowed to %s %s", user.Username, r.Method, TuningPath)
+	}
+
+	return user.Username, nil
+}
+
+// TuningHandler returns an http.Handler serving the tuning endpoint.
+// GET returns the current TuningState. POST, with a JSON encoded Tuning as body,
+// changes the knobs set in it.
+// Requests must carry a bearer token of a user allowed, by RBAC, to get or post
+// the TuningPath non-resource URL.
+func TuningHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m := GetManager()
+		if m == nil {
+			http.Error(w, "classification manager not initialized yet", http.StatusServiceUnavailable)
+			return
+		}
+
+		if r.Method != http.MethodGet && r.Method != http.MethodPost {
+			http.Error(w, "only GET and POST are supported", http.StatusMethodNotAllowed)
+			return
+		}
+
+		user, err := m.tuningAuthenticator(r.Context(), r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		if r.Method == http.MethodPost {
+			tuning := &Tuning{}
+			if err := json.NewDecoder(r.Body).Decode(tuning); err != nil {
+				http.Error(w, fmt.Sprintf("invalid tuning: %v", err), http.StatusBadRequest)
+				return
+			}
+			if err := m.updateTuning(tuning, user); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(m.getTuningState()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
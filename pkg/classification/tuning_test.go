@@ -0,0 +1,131 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+)
+
+var _ = Describe("Manager: runtime tuning", func() {
+	var handler http.Handler
+
+	BeforeEach(func() {
+		classification.Reset()
+
+		classification.InitializeManagerWithObjects(nil)
+		handler = classification.TuningHandler()
+	})
+
+	getTuningState := func(recorder *httptest.ResponseRecorder) *classification.TuningState {
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		state := &classification.TuningState{}
+		Expect(json.Unmarshal(recorder.Body.Bytes(), state)).To(Succeed())
+		return state
+	}
+
+	It("TuningHandler rejects unauthenticated requests", func() {
+		classification.SetTuningAuthenticator("")
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, classification.TuningPath, nil))
+		Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+
+		recorder = httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, classification.TuningPath,
+			strings.NewReader(`{"interval":"1m"}`)))
+		Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+
+		classification.SetTuningAuthenticator("admin")
+		recorder = httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, classification.TuningPath, nil))
+		state := getTuningState(recorder)
+		Expect(state.Tuning.Interval.Duration).To(Equal(10 * time.Second))
+		Expect(state.Changes).To(BeEmpty())
+	})
+
+	It("TuningHandler updates knobs and records changes", func() {
+		classification.SetTuningAuthenticator("admin")
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, classification.TuningPath,
+			strings.NewReader(`{"interval":"1m","broadConstraintThreshold":50}`)))
+		state := getTuningState(recorder)
+		Expect(state.Tuning.Interval.Duration).To(Equal(time.Minute))
+		Expect(*state.Tuning.BroadConstraintThreshold).To(Equal(50))
+		Expect(state.Changes).To(HaveLen(2))
+		Expect(state.Changes[0].User).To(Equal("admin"))
+		Expect(state.Changes[0].Knob).To(Equal("interval"))
+		Expect(state.Changes[0].Old).To(Equal((10 * time.Second).String()))
+		Expect(state.Changes[0].New).To(Equal(time.Minute.String()))
+		Expect(state.Changes[1].Knob).To(Equal("broadConstraintThreshold"))
+		Expect(state.Changes[1].New).To(Equal("50"))
+
+		// Interval is not adaptive, so new interval is used for next evaluation
+		Expect(classification.GetNextInterval(time.Minute, time.Second)).To(Equal(time.Minute))
+
+		// Setting a knob to its current value is not recorded
+		recorder = httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, classification.TuningPath,
+			strings.NewReader(`{"interval":"1m"}`)))
+		Expect(getTuningState(recorder).Changes).To(HaveLen(2))
+	})
+
+	It("TuningHandler rejects invalid tuning", func() {
+		classification.SetTuningAuthenticator("admin")
+
+		for _, body := range []string{
+			`{"interval":"0s"}`,
+			`{"minInterval":"5m","maxInterval":"1m"}`,
+			`{"broadConstraintThreshold":0}`,
+			`not json`,
+		} {
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, classification.TuningPath,
+				strings.NewReader(body)))
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest), body)
+		}
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, classification.TuningPath, nil))
+		state := getTuningState(recorder)
+		Expect(state.Tuning.Interval.Duration).To(Equal(10 * time.Second))
+		Expect(state.Changes).To(BeEmpty())
+	})
+
+	It("authenticateTuningRequest requires a bearer token", func() {
+		manager := classification.GetManager()
+
+		_, err := classification.AuthenticateTuningRequest(manager, context.TODO(),
+			httptest.NewRequest(http.MethodGet, classification.TuningPath, nil))
+		Expect(err).ToNot(BeNil())
+
+		request := httptest.NewRequest(http.MethodGet, classification.TuningPath, nil)
+		request.Header.Set("Authorization", "Basic abc")
+		_, err = classification.AuthenticateTuningRequest(manager, context.TODO(), request)
+		Expect(err).ToNot(BeNil())
+	})
+})
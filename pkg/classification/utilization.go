@@ -0,0 +1,287 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+// UtilizationTarget identifies the resources whose utilization is constrained
+type UtilizationTarget string
+
+const (
+	// UtilizationTargetNode compares Node usage with Node allocatable
+	UtilizationTargetNode = UtilizationTarget("Node")
+
+	// UtilizationTargetPod compares Pod usage with Pod requests
+	UtilizationTargetPod = UtilizationTarget("Pod")
+)
+
+// metricsGroupVersion is the metrics-server API
+var metricsGroupVersion = schema.GroupVersion{Group: "metrics.k8s.io", Version: "v1beta1"}
+
+// UtilizationConstraint bounds the live utilization of Nodes or Pods, as reported by
+// metrics-server, for instance an average Node cpu usage above 70% of allocatable.
+// Utilization is Unknown, and the constraint not satisfied, when metrics.k8s.io is
+// not served or has no metrics for the selected resources.
+type UtilizationConstraint struct {
+	// Target is Node, the default, to compare Node usage with Node allocatable, or
+	// Pod to compare Pod usage with Pod requests. Pods requesting nothing are ignored.
+	// +optional
+	Target UtilizationTarget `json:"target,omitempty"`
+
+	// Resource is cpu or memory
+	Resource corev1.ResourceName `json:"resource"`
+
+	// Namespace restricts Pods to a namespace. All namespaces when empty.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// LabelFilters select Nodes or Pods by label
+	// +optional
+	LabelFilters []libsveltosv1alpha1.LabelFilter `json:"labelFilters,omitempty"`
+
+	// MinAveragePercentage is the minimum average utilization, in percentage
+	// +optional
+	MinAveragePercentage *int `json:"minAveragePercentage,omitempty"`
+
+	// MaxAveragePercentage is the maximum average utilization, in percentage
+	// +optional
+	MaxAveragePercentage *int `json:"maxAveragePercentage,omitempty"`
+}
+
+// getTarget returns the constraint target, UtilizationTargetNode when not set
+func (c *UtilizationConstraint) getTarget() UtilizationTarget {
+	if c.Target == "" {
+		return UtilizationTargetNode
+	}
+	return c.Target
+}
+
+// getResourceConstraint returns the resource constraint selecting the Nodes or Pods
+// whose utilization is evaluated
+func (c *UtilizationConstraint) getResourceConstraint() *ResourceConstraint {
+	gvk := nodeGVK
+	if c.getTarget() == UtilizationTargetPod {
+		gvk = podGVK
+	}
+	constraint := &ResourceConstraint{}
+	constraint.Group = gvk.Group
+	constraint.Version = gvk.Version
+	constraint.Kind = gvk.Kind
+	constraint.Namespace = c.Namespace
+	constraint.LabelFilters = c.LabelFilters
+	return constraint
+}
+
+// validateUtilizationConstraint returns an error if c is not valid
+func validateUtilizationConstraint(c *UtilizationConstraint) error {
+	switch c.getTarget() {
+	case UtilizationTargetNode:
+		if c.Namespace != "" {
+			return fmt.Errorf("namespace is only supported for Pods")
+		}
+	case UtilizationTargetPod:
+	default:
+		return fmt.Errorf("unknown target %q", c.Target)
+	}
+	if c.Resource != corev1.ResourceCPU && c.Resource != corev1.ResourceMemory {
+		return fmt.Errorf("resource must be cpu or memory, got %q", c.Resource)
+	}
+	if c.MinAveragePercentage == nil && c.MaxAveragePercentage == nil {
+		return fmt.Errorf("at least one bound is required")
+	}
+	if (c.MinAveragePercentage != nil && *c.MinAveragePercentage < 0) ||
+		(c.MaxAveragePercentage != nil && *c.MaxAveragePercentage < 0) {
+
+		return fmt.Errorf("average percentage cannot be negative")
+	}
+	if c.MinAveragePercentage != nil && c.MaxAveragePercentage != nil &&
+		*c.MinAveragePercentage > *c.MaxAveragePercentage {
+
+		return fmt.Errorf("minAveragePercentage %d is greater than maxAveragePercentage %d",
+			*c.MinAveragePercentage, *c.MaxAveragePercentage)
+	}
+	return nil
+}
+
+// areUtilizationConstraintsAMatch returns true if all UtilizationConstraints are satisfied.
+// Utilization is Unknown, and Classifier not a match, when metrics are not available.
+func (m *manager) areUtilizationConstraintsAMatch(ctx context.Context,
+	classifier *libsveltosv1alpha1.Classifier) (bool, error) {
+
+	extension, err := getClassifierExtension(classifier)
+	if err != nil {
+		return false, err
+	}
+
+	for i := range extension.UtilizationConstraints {
+		c := &extension.UtilizationConstraints[i]
+		if err := validateUtilizationConstraint(c); err != nil {
+			return false, newInvalidClassifierError(fmt.Errorf("utilization constraint %d: %w", i, err))
+		}
+
+		average, known, err := m.getAverageUtilization(ctx, classifier, c)
+		if err != nil {
+			return false, err
+		}
+		if !known {
+			addEvaluationNote(ctx, fmt.Sprintf("utilization constraint %d: %s utilization Unknown", i, c.Resource))
+			return false, nil
+		}
+
+		addEvaluationNote(ctx, fmt.Sprintf("utilization constraint %d: average %s %s utilization %.1f%%",
+			i, c.getTarget(), c.Resource, average))
+		if (c.MinAveragePercentage != nil && average < float64(*c.MinAveragePercentage)) ||
+			(c.MaxAveragePercentage != nil && average > float64(*c.MaxAveragePercentage)) {
+
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// getAverageUtilization returns the average utilization, in percentage, of the Nodes or Pods
+// selected by c. Returns false if metrics are not available for any of them.
+func (m *manager) getAverageUtilization(ctx context.Context, classifier *libsveltosv1alpha1.Classifier,
+	c *UtilizationConstraint) (float64, bool, error) {
+
+	usages, available, err := m.getUsages(ctx, c)
+	if err != nil || !available {
+		return 0, false, err
+	}
+
+	items, _, err := m.getMatchingResources(ctx, classifier, c.getResourceConstraint(), nil)
+	if err != nil {
+		return 0, false, err
+	}
+
+	total := float64(0)
+	measured := 0
+	for i := range items {
+		usage, ok := usages[getUsageKey(items[i].GetNamespace(), items[i].GetName())]
+		if !ok {
+			continue
+		}
+		var capacity resource.Quantity
+		if c.getTarget() == UtilizationTargetPod {
+			capacity = getPodRequest(&items[i], c.Resource)
+		} else {
+			capacity = getAllocatableQuantity(&items[i], c.Resource)
+		}
+		if capacity.IsZero() {
+			continue
+		}
+		total += float64(usage.MilliValue()) * 100 / float64(capacity.MilliValue())
+		measured++
+	}
+
+	if measured == 0 {
+		addEvaluationNote(ctx, fmt.Sprintf("no metrics for the %d selected %ss", len(items), c.getTarget()))
+		return 0, false, nil
+	}
+	return total / float64(measured), true, nil
+}
+
+// getUsages returns, from metrics-server, the usage of c.Resource per Node or Pod, keyed
+// by getUsageKey. Returns false if metrics.k8s.io is not available.
+func (m *manager) getUsages(ctx context.Context, c *UtilizationConstraint) (map[string]resource.Quantity, bool, error) {
+	d, err := dynamic.NewForConfig(m.config)
+	if err != nil {
+		return nil, false, err
+	}
+
+	resourceName := "nodes"
+	if c.getTarget() == UtilizationTargetPod {
+		resourceName = "pods"
+	}
+	namespaceableClient := d.Resource(metricsGroupVersion.WithResource(resourceName))
+	var resourceClient dynamic.ResourceInterface = namespaceableClient
+	if c.Namespace != "" {
+		resourceClient = namespaceableClient.Namespace(c.Namespace)
+	}
+
+	list, err := resourceClient.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		if isMetricsUnavailable(err) {
+			addEvaluationNote(ctx, fmt.Sprintf("%s is not available: %v", metricsGroupVersion.String(), err))
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	usages := make(map[string]resource.Quantity, len(list.Items))
+	for i := range list.Items {
+		usage := getMetricsUsage(&list.Items[i], c.Resource)
+		usages[getUsageKey(list.Items[i].GetNamespace(), list.Items[i].GetName())] = usage
+	}
+	return usages, true, nil
+}
+
+// isMetricsUnavailable returns true if err indicates metrics-server is not
+// installed or not serving
+func isMetricsUnavailable(err error) bool {
+	return apierrors.IsNotFound(err) || apierrors.IsServiceUnavailable(err) || meta.IsNoMatchError(err)
+}
+
+func getUsageKey(namespace, name string) string {
+	return namespace + "/" + name
+}
+
+// getMetricsUsage returns the usage of resourceName in a NodeMetrics or, summed
+// across containers, in a PodMetrics
+func getMetricsUsage(metrics *unstructured.Unstructured, resourceName corev1.ResourceName) resource.Quantity {
+	if _, found := metrics.Object["usage"]; found {
+		return getQuantity(metrics.Object, "usage", string(resourceName))
+	}
+
+	total := resource.Quantity{}
+	containers, _, _ := unstructured.NestedSlice(metrics.Object, "containers")
+	for i := range containers {
+		container, ok := containers[i].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		total.Add(getQuantity(container, "usage", string(resourceName)))
+	}
+	return total
+}
+
+// getQuantity returns the quantity at fields. Zero if not found or if it cannot be parsed.
+func getQuantity(obj map[string]interface{}, fields ...string) resource.Quantity {
+	value, found, err := unstructured.NestedString(obj, fields...)
+	if err != nil || !found {
+		return resource.Quantity{}
+	}
+	quantity, err := resource.ParseQuantity(value)
+	if err != nil {
+		return resource.Quantity{}
+	}
+	return quantity
+}
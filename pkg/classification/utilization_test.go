@@ -0,0 +1,161 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: utilization constraints", func() {
+	var server *httptest.Server
+	var metricsServed bool
+	var classifier *libsveltosv1alpha1.Classifier
+
+	BeforeEach(func() {
+		metricsServed = true
+
+		getNode := func(name, allocatable string) string {
+			return `{"apiVersion":"v1","kind":"Node","metadata":{"name":"` + name + `"},` +
+				`"status":{"allocatable":{` + allocatable + `}}}`
+		}
+		getNodeMetrics := func(name, usage string) string {
+			return `{"apiVersion":"metrics.k8s.io/v1beta1","kind":"NodeMetrics","metadata":{"name":"` + name + `"},` +
+				`"timestamp":"2023-01-01T00:00:00Z","window":"10s","usage":{` + usage + `}}`
+		}
+		getPod := func(name, cpuRequest string) string {
+			return `{"apiVersion":"v1","kind":"Pod","metadata":{"namespace":"default","name":"` + name + `"},` +
+				`"spec":{"containers":[{"name":"c","image":"nginx","resources":{"requests":{"cpu":"` +
+				cpuRequest + `"}}}]}}`
+		}
+		getPodMetrics := func(name, cpuUsage string) string {
+			return `{"apiVersion":"metrics.k8s.io/v1beta1","kind":"PodMetrics",` +
+				`"metadata":{"namespace":"default","name":"` + name + `"},"timestamp":"2023-01-01T00:00:00Z",` +
+				`"window":"10s","containers":[{"name":"c","usage":{"cpu":"` + cpuUsage + `"}}]}`
+		}
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			switch r.URL.Path {
+			case "/api":
+				_, _ = w.Write([]byte(`{"kind":"APIVersions","versions":["v1"]}`))
+			case "/api/v1":
+				_, _ = w.Write([]byte(`{"kind":"APIResourceList","groupVersion":"v1","resources":[` +
+					`{"name":"nodes","singularName":"node","namespaced":false,"kind":"Node","verbs":["list"]},` +
+					`{"name":"pods","singularName":"pod","namespaced":true,"kind":"Pod","verbs":["list"]}]}`))
+			case "/apis":
+				_, _ = w.Write([]byte(`{"kind":"APIGroupList","apiVersion":"v1","groups":[]}`))
+			case "/api/v1/nodes":
+				_, _ = w.Write([]byte(`{"apiVersion":"v1","kind":"NodeList","metadata":{},"items":[` +
+					getNode("node-1", `"cpu":"4","memory":"8Gi"`) + `,` +
+					getNode("node-2", `"cpu":"4","memory":"8Gi"`) + `,` +
+					// No metrics yet for this Node
+					getNode("node-3", `"cpu":"4","memory":"8Gi"`) + `]}`))
+			case "/api/v1/pods":
+				_, _ = w.Write([]byte(`{"apiVersion":"v1","kind":"PodList","metadata":{},"items":[` +
+					getPod("web", "500m") + `,` + getPod("worker", "1") + `]}`))
+			case "/apis/metrics.k8s.io/v1beta1/nodes":
+				if !metricsServed {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				_, _ = w.Write([]byte(`{"apiVersion":"metrics.k8s.io/v1beta1","kind":"NodeMetricsList",` +
+					`"metadata":{},"items":[` + getNodeMetrics("node-1", `"cpu":"3","memory":"2Gi"`) + `,` +
+					getNodeMetrics("node-2", `"cpu":"2","memory":"4Gi"`) + `]}`))
+			case "/apis/metrics.k8s.io/v1beta1/namespaces/default/pods":
+				_, _ = w.Write([]byte(`{"apiVersion":"metrics.k8s.io/v1beta1","kind":"PodMetricsList",` +
+					`"metadata":{},"items":[` + getPodMetrics("web", "500m") + `,` +
+					getPodMetrics("worker", "250m") + `]}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+
+		classifier = &libsveltosv1alpha1.Classifier{ObjectMeta: metav1.ObjectMeta{Name: randomString()}}
+
+		classification.InitializeManagerWithObjects(&rest.Config{Host: server.URL})
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	evaluate := func(utilizationConstraints string) (bool, []string, error) {
+		classifier.Annotations = map[string]string{
+			classification.ClassifierExtensionAnnotation: "utilizationConstraints:\n" + utilizationConstraints,
+		}
+		return classification.AreUtilizationConstraintsAMatch(classification.GetManager(), classifier)
+	}
+
+	It("areUtilizationConstraintsAMatch averages Node utilization", func() {
+		// 75% and 50%. Node without metrics is ignored
+		isMatch, notes, err := evaluate(`- resource: cpu
+  minAveragePercentage: 60`)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+		Expect(notes).To(ContainElement("utilization constraint 0: average Node cpu utilization 62.5%"))
+
+		isMatch, _, err = evaluate(`- resource: memory
+  minAveragePercentage: 50`)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeFalse())
+	})
+
+	It("areUtilizationConstraintsAMatch averages Pod utilization of requests", func() {
+		// 100% and 25%
+		isMatch, notes, err := evaluate(`- target: Pod
+  resource: cpu
+  namespace: default
+  maxAveragePercentage: 70`)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+		Expect(notes).To(ContainElement("utilization constraint 0: average Pod cpu utilization 62.5%"))
+	})
+
+	It("areUtilizationConstraintsAMatch reports Unknown utilization when metrics-server is absent", func() {
+		metricsServed = false
+
+		isMatch, notes, err := evaluate(`- resource: cpu
+  maxAveragePercentage: 100`)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeFalse())
+		Expect(notes).To(ContainElement("utilization constraint 0: cpu utilization Unknown"))
+	})
+
+	It("areUtilizationConstraintsAMatch rejects invalid constraints", func() {
+		_, _, err := evaluate(`- resource: nvidia.com/gpu
+  minAveragePercentage: 10`)
+		Expect(err).ToNot(BeNil())
+
+		_, _, err = evaluate(`- resource: cpu`)
+		Expect(err).ToNot(BeNil())
+
+		_, _, err = evaluate(`- resource: cpu
+  namespace: default
+  minAveragePercentage: 10`)
+		Expect(err).ToNot(BeNil())
+	})
+})
@@ -0,0 +1,239 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/Masterminds/semver"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+// VersionMatchingMode defines how the cluster Kubernetes version is compared
+// against Classifier version constraints
+type VersionMatchingMode string
+
+const (
+	// VersionMatchingStrict compares the cluster Kubernetes version as reported
+	// by the API server. Versions with a pre-release identifier, such as
+	// v1.25.6-eks-48e63af, only match constraints which contain a pre-release.
+	// This is the default.
+	VersionMatchingStrict = VersionMatchingMode("strict")
+
+	// VersionMatchingNormalized drops build metadata (v1.26.1+k3s1) and provider
+	// suffixes (v1.25.6-eks-48e63af, v1.24.9-gke.300) from the cluster Kubernetes
+	// version before comparing it. Upstream pre-release identifiers (alpha, beta
+	// and rc) are kept.
+	VersionMatchingNormalized = VersionMatchingMode("normalized")
+)
+
+// upstreamPrerelease matches pre-release identifiers used by Kubernetes releases
+var upstreamPrerelease = regexp.MustCompile(`^(alpha|beta|rc)(\.[0-9]+)*$`)
+
+// normalizeVersion returns version stripped of build metadata and of any
+// pre-release identifier which is not an upstream Kubernetes one
+func normalizeVersion(version *semver.Version) *semver.Version {
+	prerelease := version.Prerelease()
+	if !upstreamPrerelease.MatchString(prerelease) {
+		prerelease = ""
+	}
+
+	v := fmt.Sprintf("%d.%d.%d", version.Major(), version.Minor(), version.Patch())
+	if prerelease != "" {
+		v = fmt.Sprintf("%s-%s", v, prerelease)
+	}
+
+	// Major, minor, patch and pre-release come from a valid version
+	return semver.MustParse(v)
+}
+
+// getClusterVersion parses currentVersion according to mode
+func getClusterVersion(currentVersion string, mode VersionMatchingMode) (*semver.Version, error) {
+	version, err := semver.NewVersion(currentVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	if mode == VersionMatchingStrict {
+		return version, nil
+	}
+
+	return normalizeVersion(version), nil
+}
+
+// HasKubernetesVersionConstraints returns true if Classifier depends on
+// cluster Kubernetes version
+func HasKubernetesVersionConstraints(classifier *libsveltosv1alpha1.Classifier) bool {
+	if len(classifier.Spec.KubernetesVersionConstraints) > 0 {
+		return true
+	}
+
+	extension, err := getClassifierExtension(classifier)
+	if err != nil {
+		// Classifier is reported as invalid when evaluated. Evaluate it
+		// on Kubernetes version changes as well.
+		return true
+	}
+
+	return len(extension.KubernetesVersionConstraints) > 0 ||
+		len(extension.KubernetesVersionConstraintGroups) > 0
+}
+
+// getVersionConstraint returns the semver constraint for a VersionConstraint
+func getVersionConstraint(versionConstraint *VersionConstraint) (*semver.Constraints, error) {
+	if versionConstraint.Range != "" {
+		return parseVersionRange(versionConstraint.Range)
+	}
+
+	return getComparisonConstraint(&libsveltosv1alpha1.KubernetesVersionConstraint{
+		Version:    versionConstraint.Version,
+		Comparison: versionConstraint.Comparison,
+	})
+}
+
+// isVersionGroupAMatch returns true if version satisfies at least one group.
+// All groups are parsed, so an invalid group is reported even if a previous
+// group is a match.
+func isVersionGroupAMatch(version *semver.Version, groups []VersionConstraintGroup) (bool, error) {
+	if len(groups) == 0 {
+		return true, nil
+	}
+
+	isMatch := false
+	for i := range groups {
+		if len(groups[i].Constraints) == 0 {
+			return false, newInvalidClassifierError(
+				fmt.Errorf("kubernetes version constraint group %d has no constraints", i))
+		}
+
+		groupMatch := true
+		for j := range groups[i].Constraints {
+			c, err := getVersionConstraint(&groups[i].Constraints[j])
+			if err != nil {
+				return false, err
+			}
+			if !c.Check(version) {
+				groupMatch = false
+			}
+		}
+		isMatch = isMatch || groupMatch
+	}
+
+	return isMatch, nil
+}
+
+// getComparisonConstraint returns the semver constraint for a libsveltos
+// KubernetesVersionConstraint
+func getComparisonConstraint(kubernetesVersionConstraint *libsveltosv1alpha1.KubernetesVersionConstraint,
+) (*semver.Constraints, error) {
+
+	var operator string
+	switch kubernetesVersionConstraint.Comparison {
+	case string(libsveltosv1alpha1.ComparisonEqual):
+		operator = "="
+	case string(libsveltosv1alpha1.ComparisonNotEqual):
+		operator = "!="
+	case string(libsveltosv1alpha1.ComparisonGreaterThan):
+		operator = ">"
+	case string(libsveltosv1alpha1.ComparisonGreaterThanOrEqualTo):
+		operator = ">="
+	case string(libsveltosv1alpha1.ComparisonLessThan):
+		operator = "<"
+	case string(libsveltosv1alpha1.ComparisonLessThanOrEqualTo):
+		operator = "<="
+	default:
+		return nil, newInvalidClassifierError(
+			fmt.Errorf("unknown comparison %q", kubernetesVersionConstraint.Comparison))
+	}
+
+	c, err := semver.NewConstraint(fmt.Sprintf("%s %s", operator, kubernetesVersionConstraint.Version))
+	if err != nil {
+		return nil, newInvalidClassifierError(errors.Wrap(err,
+			fmt.Sprintf("invalid version %q", kubernetesVersionConstraint.Version)))
+	}
+	return c, nil
+}
+
+// parseVersionRange parses a semver range expression. Besides the syntax supported
+// by semver library (comma separated AND, "||" separated OR, hyphen, tilde, caret
+// and wildcard ranges), whitespace separated constraints are ANDed, as in
+// ">=1.24.0 <1.27.0".
+func parseVersionRange(versionRange string) (*semver.Constraints, error) {
+	if strings.TrimSpace(versionRange) == "" {
+		return nil, newInvalidClassifierError(errors.New("empty version range"))
+	}
+
+	c, err := semver.NewConstraint(normalizeVersionRange(versionRange))
+	if err != nil {
+		return nil, newInvalidClassifierError(errors.Wrap(err,
+			fmt.Sprintf("invalid version range %q", versionRange)))
+	}
+	return c, nil
+}
+
+// normalizeVersionRange rewrites whitespace separated constraints as comma separated
+// ones. Operators separated from their version by whitespace ("> 1.24") and hyphen
+// ranges ("1.24 - 1.26") are preserved.
+func normalizeVersionRange(versionRange string) string {
+	ors := strings.Split(versionRange, "||")
+	for i := range ors {
+		ands := make([]string, 0)
+		for _, part := range strings.Split(ors[i], ",") {
+			ands = append(ands, splitConstraints(strings.Fields(part))...)
+		}
+		ors[i] = strings.Join(ands, ", ")
+	}
+	return strings.Join(ors, " || ")
+}
+
+// splitConstraints groups whitespace separated tokens into constraints
+func splitConstraints(tokens []string) []string {
+	constraints := make([]string, 0)
+	current := ""
+	joinNext := false
+	for _, token := range tokens {
+		switch {
+		case token == "-":
+			// Hyphen range: joins previous and next token
+			current += " -"
+			joinNext = true
+		case joinNext:
+			current += " " + token
+			joinNext = false
+		case strings.Trim(token, "=!<>~^") == "":
+			// Operator alone: joins next token
+			if current != "" {
+				constraints = append(constraints, current)
+			}
+			current = token
+			joinNext = true
+		default:
+			if current != "" {
+				constraints = append(constraints, current)
+			}
+			current = token
+		}
+	}
+	if current != "" {
+		constraints = append(constraints, current)
+	}
+	return constraints
+}
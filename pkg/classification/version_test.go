@@ -0,0 +1,239 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Masterminds/semver"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2/klogr"
+
+	"github.com/projectsveltos/classifier-agent/internal/utils"
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+var _ = Describe("Manager: version ranges", func() {
+
+	BeforeEach(func() {
+		classification.Reset()
+	})
+
+	It("normalizeVersionRange converts whitespace separated constraints", func() {
+		Expect(classification.NormalizeVersionRange(">=1.24.0 <1.27.0")).To(Equal(">=1.24.0, <1.27.0"))
+		Expect(classification.NormalizeVersionRange(">= 1.24.0 < 1.27.0")).To(Equal(">= 1.24.0, < 1.27.0"))
+		Expect(classification.NormalizeVersionRange("1.24 - 1.26")).To(Equal("1.24 - 1.26"))
+		Expect(classification.NormalizeVersionRange("~1.25.x")).To(Equal("~1.25.x"))
+		Expect(classification.NormalizeVersionRange(">=1.22.0, <1.23.0 || >=1.25.0")).
+			To(Equal(">=1.22.0, <1.23.0 || >=1.25.0"))
+	})
+
+	It("parseVersionRange parses semver range expressions", func() {
+		type testCase struct {
+			versionRange string
+			version      string
+			isMatch      bool
+		}
+
+		testCases := []testCase{
+			{versionRange: ">=1.24.0 <1.27.0", version: "v1.25.3", isMatch: true},
+			{versionRange: ">=1.24.0 <1.27.0", version: "v1.27.0", isMatch: false},
+			{versionRange: "~1.25.x", version: "v1.25.8", isMatch: true},
+			{versionRange: "~1.25.x", version: "v1.26.0", isMatch: false},
+			{versionRange: "1.22 - 1.24", version: "v1.23.1", isMatch: true},
+			{versionRange: ">=1.22.0 <1.23.0 || >=1.25.0", version: "v1.24.0", isMatch: false},
+			{versionRange: ">=1.22.0 <1.23.0 || >=1.25.0", version: "v1.26.1", isMatch: true},
+		}
+
+		for i := range testCases {
+			c, err := classification.ParseVersionRange(testCases[i].versionRange)
+			Expect(err).To(BeNil())
+			v, err := semver.NewVersion(testCases[i].version)
+			Expect(err).To(BeNil())
+			Expect(c.Check(v)).To(Equal(testCases[i].isMatch),
+				fmt.Sprintf("range %q version %s", testCases[i].versionRange, testCases[i].version))
+		}
+
+		_, err := classification.ParseVersionRange(">=1.24.0 <foo")
+		Expect(err).ToNot(BeNil())
+		Expect(classification.IsInvalidClassifierError(err)).To(BeTrue())
+
+		_, err = classification.ParseVersionRange("")
+		Expect(err).ToNot(BeNil())
+		Expect(classification.IsInvalidClassifierError(err)).To(BeTrue())
+	})
+
+	It("isVersionGroupAMatch ORs groups and ANDs constraints within a group", func() {
+		groups := []classification.VersionConstraintGroup{
+			{Constraints: []classification.VersionConstraint{{Range: "~1.24.x"}}},
+			{Constraints: []classification.VersionConstraint{
+				{Version: "1.26.0", Comparison: string(libsveltosv1alpha1.ComparisonGreaterThanOrEqualTo)},
+				{Version: "1.27.0", Comparison: string(libsveltosv1alpha1.ComparisonLessThan)},
+			}},
+		}
+
+		type testCase struct {
+			version string
+			isMatch bool
+		}
+		testCases := []testCase{
+			{version: "v1.24.3", isMatch: true},
+			{version: "v1.25.0", isMatch: false},
+			{version: "v1.26.5", isMatch: true},
+			{version: "v1.27.0", isMatch: false},
+		}
+		for i := range testCases {
+			v, err := semver.NewVersion(testCases[i].version)
+			Expect(err).To(BeNil())
+			isMatch, err := classification.IsVersionGroupAMatch(v, groups)
+			Expect(err).To(BeNil())
+			Expect(isMatch).To(Equal(testCases[i].isMatch), testCases[i].version)
+		}
+
+		v, err := semver.NewVersion("v1.24.3")
+		Expect(err).To(BeNil())
+
+		// No group means no restriction
+		isMatch, err := classification.IsVersionGroupAMatch(v, nil)
+		Expect(err).To(BeNil())
+		Expect(isMatch).To(BeTrue())
+
+		// Invalid groups are reported even if a previous group is a match
+		groups = append(groups, classification.VersionConstraintGroup{
+			Constraints: []classification.VersionConstraint{{Version: "1.26.0", Comparison: "Unknown"}},
+		})
+		_, err = classification.IsVersionGroupAMatch(v, groups)
+		Expect(err).ToNot(BeNil())
+		Expect(classification.IsInvalidClassifierError(err)).To(BeTrue())
+	})
+
+	It("normalizeVersion drops provider suffixes and build metadata", func() {
+		type testCase struct {
+			version    string
+			normalized string
+		}
+		testCases := []testCase{
+			{version: "v1.25.6-eks-48e63af", normalized: "1.25.6"},
+			{version: "v1.26.1+k3s1", normalized: "1.26.1"},
+			{version: "v1.24.9-gke.300", normalized: "1.24.9"},
+			{version: "v1.27.0-rc.1", normalized: "1.27.0-rc.1"},
+			{version: "v1.27.0-alpha.3+abcdef", normalized: "1.27.0-alpha.3"},
+			{version: "v1.25.3", normalized: "1.25.3"},
+		}
+		for i := range testCases {
+			v, err := semver.NewVersion(testCases[i].version)
+			Expect(err).To(BeNil())
+			Expect(classification.NormalizeVersion(v).String()).To(Equal(testCases[i].normalized))
+		}
+	})
+
+	It("getClusterVersion matches provider versions only in normalized mode", func() {
+		c, err := classification.ParseVersionRange(">=1.25.0 <1.26.0")
+		Expect(err).To(BeNil())
+
+		v, err := classification.GetClusterVersion("v1.25.6-eks-48e63af", classification.VersionMatchingStrict)
+		Expect(err).To(BeNil())
+		Expect(c.Check(v)).To(BeFalse())
+
+		v, err = classification.GetClusterVersion("v1.25.6-eks-48e63af", classification.VersionMatchingNormalized)
+		Expect(err).To(BeNil())
+		Expect(c.Check(v)).To(BeTrue())
+
+		_, err = classification.GetClusterVersion("not-a-version", classification.VersionMatchingNormalized)
+		Expect(err).ToNot(BeNil())
+	})
+
+	It("HasKubernetesVersionConstraints considers ClassifierExtension", func() {
+		classifier := getClassifierWithKubernetesConstraints(version25, libsveltosv1alpha1.ComparisonEqual)
+		Expect(classification.HasKubernetesVersionConstraints(classifier)).To(BeTrue())
+
+		classifier.Spec.KubernetesVersionConstraints = nil
+		Expect(classification.HasKubernetesVersionConstraints(classifier)).To(BeFalse())
+
+		classifier.Annotations = map[string]string{
+			classification.ClassifierExtensionAnnotation: `kubernetesVersionConstraints:
+- range: ">=1.24.0 <1.27.0"`,
+		}
+		Expect(classification.HasKubernetesVersionConstraints(classifier)).To(BeTrue())
+
+		classifier.Annotations = map[string]string{
+			classification.ClassifierExtensionAnnotation: `kubernetesVersionConstraintGroups:
+- constraints:
+  - range: "~1.24.x"
+- constraints:
+  - range: "~1.26.x"`,
+		}
+		Expect(classification.HasKubernetesVersionConstraints(classifier)).To(BeTrue())
+	})
+
+	It("createClassifierReport reports evaluation errors", func() {
+		classifier := getClassifierWithKubernetesConstraints(version24, libsveltosv1alpha1.ComparisonGreaterThan)
+
+		c := classification.InitializeManagerWithObjects(nil, classifier)
+		manager := classification.GetManager()
+
+		message := "invalid version range"
+		Expect(classification.CreateClassifierReport(manager, context.TODO(), classifier, false,
+			errors.New(message))).To(Succeed())
+
+		classifierReport := &libsveltosv1alpha1.ClassifierReport{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: utils.ReportNamespace, Name: classifier.Name},
+			classifierReport)).To(Succeed())
+		Expect(classifierReport.Annotations).To(HaveKeyWithValue(classification.ClassifierReportErrorAnnotation,
+			message))
+
+		// Error is removed once evaluation succeeds
+		Expect(classification.CreateClassifierReport(manager, context.TODO(), classifier, true, nil)).To(Succeed())
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: utils.ReportNamespace, Name: classifier.Name},
+			classifierReport)).To(Succeed())
+		Expect(classifierReport.Annotations).ToNot(HaveKey(classification.ClassifierReportErrorAnnotation))
+		Expect(classifierReport.Spec.Match).To(BeTrue())
+	})
+
+	It("IsVersionAMatch: evaluates version ranges", func() {
+		classifier := getClassifierWithKubernetesConstraints(version24, libsveltosv1alpha1.ComparisonGreaterThan)
+		classifier.Annotations = map[string]string{
+			classification.ClassifierExtensionAnnotation: `kubernetesVersionConstraints:
+- range: ">=1.24.0 <1.26.0"`,
+		}
+
+		classification.InitializeManagerWithSkip(context.TODO(), klogr.New(), testEnv.Config, testEnv.Client, nil, 10)
+		manager := classification.GetManager()
+
+		match, err := classification.IsVersionAMatch(manager, context.TODO(), classifier)
+		Expect(err).To(BeNil())
+		Expect(match).To(BeTrue())
+
+		classifier.Annotations[classification.ClassifierExtensionAnnotation] = `kubernetesVersionConstraints:
+- range: "~1.24.x"`
+		match, err = classification.IsVersionAMatch(manager, context.TODO(), classifier)
+		Expect(err).To(BeNil())
+		Expect(match).To(BeFalse())
+
+		classifier.Annotations[classification.ClassifierExtensionAnnotation] = `kubernetesVersionConstraints:
+- range: ">=1.24.0 <"`
+		_, err = classification.IsVersionAMatch(manager, context.TODO(), classifier)
+		Expect(err).ToNot(BeNil())
+		Expect(classification.IsInvalidClassifierError(err)).To(BeTrue())
+	})
+})
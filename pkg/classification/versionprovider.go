@@ -0,0 +1,249 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/projectsveltos/classifier-agent/internal/utils"
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+)
+
+// VersionProviderType identifies a source of the cluster Kubernetes version
+type VersionProviderType string
+
+const (
+	// VersionProviderAPIServer uses the version reported by the API server /version endpoint
+	VersionProviderAPIServer = VersionProviderType("apiserver")
+
+	// VersionProviderNodes uses the kubelet version reported by most nodes. Ties
+	// are broken in favor of the lowest version.
+	VersionProviderNodes = VersionProviderType("nodes")
+
+	// VersionProviderLabel uses the value of the KubernetesVersionLabel on the
+	// kube-system Namespace
+	VersionProviderLabel = VersionProviderType("label")
+
+	// VersionProviderConfigMap uses the "version" key of the KubernetesVersionConfigMap
+	// ConfigMap in the projectsveltos namespace
+	VersionProviderConfigMap = VersionProviderType("configmap")
+)
+
+const (
+	// KubernetesVersionLabel, set on the kube-system Namespace, overrides the
+	// cluster Kubernetes version when VersionProviderLabel is used
+	KubernetesVersionLabel = "classifier.projectsveltos.io/kubernetes-version"
+
+	// KubernetesVersionConfigMap is the name of the ConfigMap overriding the
+	// cluster Kubernetes version when VersionProviderConfigMap is used
+	KubernetesVersionConfigMap = "classifier-agent-kubernetes-version"
+
+	kubernetesVersionConfigMapKey = "version"
+)
+
+// errVersionNotSet is returned by a VersionProvider which has no version to report,
+// for instance an override which is not set. Next provider is then used.
+var errVersionNotSet = errors.New("version not set")
+
+// VersionProvider returns the cluster Kubernetes version
+type VersionProvider interface {
+	// Type identifies the provider
+	Type() VersionProviderType
+
+	// GetVersion returns the cluster Kubernetes version, for instance v1.26.3
+	GetVersion(ctx context.Context) (string, error)
+}
+
+// IsValidVersionProviderType returns true if t is a known VersionProviderType
+func IsValidVersionProviderType(t VersionProviderType) bool {
+	switch t {
+	case VersionProviderAPIServer, VersionProviderNodes, VersionProviderLabel, VersionProviderConfigMap:
+		return true
+	default:
+		return false
+	}
+}
+
+// getVersionProvider returns the VersionProvider of type t
+func getVersionProvider(t VersionProviderType, config *rest.Config, c client.Client,
+	logger logr.Logger) (VersionProvider, error) {
+
+	switch t {
+	case VersionProviderAPIServer:
+		return &apiServerVersionProvider{config: config, log: logger}, nil
+	case VersionProviderNodes:
+		return &nodesVersionProvider{Client: c}, nil
+	case VersionProviderLabel:
+		return &labelVersionProvider{Client: c}, nil
+	case VersionProviderConfigMap:
+		return &configMapVersionProvider{Client: c}, nil
+	default:
+		return nil, fmt.Errorf("unknown version provider %q", t)
+	}
+}
+
+// getKubernetesVersion returns the cluster Kubernetes version from the first
+// configured provider which has one. API server is used when no provider is configured.
+func (m *manager) getKubernetesVersion(ctx context.Context) (string, error) {
+	providers := m.versionProviders
+	if len(providers) == 0 {
+		providers = []VersionProvider{&apiServerVersionProvider{config: m.config, log: m.log}}
+	}
+
+	for i := range providers {
+		version, err := providers[i].GetVersion(ctx)
+		if err != nil {
+			if errors.Is(err, errVersionNotSet) {
+				m.log.V(logs.LogDebug).Info(fmt.Sprintf("version provider %s has no version: %v",
+					providers[i].Type(), err))
+				continue
+			}
+			return "", errors.Wrap(err, fmt.Sprintf("version provider %s", providers[i].Type()))
+		}
+		m.log.V(logs.LogDebug).Info(fmt.Sprintf("version provider %s: cluster version %s",
+			providers[i].Type(), version))
+		return version, nil
+	}
+
+	return "", errors.WithMessage(errVersionNotSet, "no version provider has a version")
+}
+
+// GetKubernetesVersion returns the cluster Kubernetes version as reported by the
+// configured version providers
+func (m *manager) GetKubernetesVersion(ctx context.Context) (string, error) {
+	return m.getKubernetesVersion(ctx)
+}
+
+type apiServerVersionProvider struct {
+	config *rest.Config
+	log    logr.Logger
+}
+
+func (p *apiServerVersionProvider) Type() VersionProviderType {
+	return VersionProviderAPIServer
+}
+
+func (p *apiServerVersionProvider) GetVersion(ctx context.Context) (string, error) {
+	return utils.GetKubernetesVersion(ctx, p.config, p.log)
+}
+
+type nodesVersionProvider struct {
+	client.Client
+}
+
+func (p *nodesVersionProvider) Type() VersionProviderType {
+	return VersionProviderNodes
+}
+
+func (p *nodesVersionProvider) GetVersion(ctx context.Context) (string, error) {
+	nodes := &corev1.NodeList{}
+	if err := p.List(ctx, nodes); err != nil {
+		return "", err
+	}
+	return getKubeletConsensusVersion(nodes.Items)
+}
+
+// getKubeletConsensusVersion returns the kubelet version reported by most nodes.
+// Ties are broken in favor of the lowest version, so that a rolling upgrade only
+// changes the version once most nodes are upgraded.
+func getKubeletConsensusVersion(nodes []corev1.Node) (string, error) {
+	votes := make(map[string]int)
+	for i := range nodes {
+		if v := nodes[i].Status.NodeInfo.KubeletVersion; v != "" {
+			votes[v]++
+		}
+	}
+
+	consensus := ""
+	for version, count := range votes {
+		if consensus == "" || count > votes[consensus] ||
+			(count == votes[consensus] && isLowerVersion(version, consensus)) {
+
+			consensus = version
+		}
+	}
+	if consensus == "" {
+		return "", errors.WithMessage(errVersionNotSet, "no node reports a kubelet version")
+	}
+	return consensus, nil
+}
+
+// isLowerVersion returns true if a is lower than b. Versions which cannot be parsed
+// are compared as strings.
+func isLowerVersion(a, b string) bool {
+	va, errA := getClusterVersion(a, VersionMatchingStrict)
+	vb, errB := getClusterVersion(b, VersionMatchingStrict)
+	if errA != nil || errB != nil {
+		return strings.Compare(a, b) < 0
+	}
+	return va.LessThan(vb)
+}
+
+type labelVersionProvider struct {
+	client.Client
+}
+
+func (p *labelVersionProvider) Type() VersionProviderType {
+	return VersionProviderLabel
+}
+
+func (p *labelVersionProvider) GetVersion(ctx context.Context) (string, error) {
+	ns := &corev1.Namespace{}
+	if err := p.Get(ctx, client.ObjectKey{Name: kubeSystemNamespace}, ns); err != nil {
+		return "", err
+	}
+	version := ns.Labels[KubernetesVersionLabel]
+	if version == "" {
+		return "", errors.WithMessage(errVersionNotSet,
+			fmt.Sprintf("namespace %s has no label %s", kubeSystemNamespace, KubernetesVersionLabel))
+	}
+	return version, nil
+}
+
+type configMapVersionProvider struct {
+	client.Client
+}
+
+func (p *configMapVersionProvider) Type() VersionProviderType {
+	return VersionProviderConfigMap
+}
+
+func (p *configMapVersionProvider) GetVersion(ctx context.Context) (string, error) {
+	configMap := &corev1.ConfigMap{}
+	key := client.ObjectKey{Namespace: utils.ReportNamespace, Name: KubernetesVersionConfigMap}
+	if err := p.Get(ctx, key, configMap); err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", errors.WithMessage(errVersionNotSet, fmt.Sprintf("configMap %s not found", key))
+		}
+		return "", err
+	}
+	version := configMap.Data[kubernetesVersionConfigMapKey]
+	if version == "" {
+		return "", errors.WithMessage(errVersionNotSet,
+			fmt.Sprintf("configMap %s has no %s key", key, kubernetesVersionConfigMapKey))
+	}
+	return version, nil
+}
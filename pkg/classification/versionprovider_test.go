@@ -0,0 +1,168 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2/klogr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/projectsveltos/classifier-agent/internal/utils"
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+)
+
+type staticVersionProvider struct {
+	version string
+}
+
+func (p *staticVersionProvider) Type() classification.VersionProviderType {
+	return classification.VersionProviderType("static")
+}
+
+func (p *staticVersionProvider) GetVersion(ctx context.Context) (string, error) {
+	return p.version, nil
+}
+
+var _ = Describe("Manager: version providers", func() {
+	var server *httptest.Server
+
+	BeforeEach(func() {
+		classification.Reset()
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			if r.URL.Path == "/version" {
+				_, _ = w.Write([]byte(`{"major":"1","minor":"25","gitVersion":"v1.25.3"}`))
+				return
+			}
+			w.WriteHeader(http.StatusNotFound)
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	newNode := func(name, kubeletVersion string) corev1.Node {
+		return corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status: corev1.NodeStatus{
+				NodeInfo: corev1.NodeSystemInfo{KubeletVersion: kubeletVersion},
+			},
+		}
+	}
+
+	initializeManager := func(c client.Client, types ...classification.VersionProviderType) {
+		classification.InitializeManagerWithSkip(context.TODO(), klogr.New(), &rest.Config{Host: server.URL},
+			c, nil, 10)
+		classification.ApplyOptions(classification.WithVersionProviders(types...))
+	}
+
+	It("getKubeletConsensusVersion returns the version most nodes run, lowest one on ties", func() {
+		nodes := []corev1.Node{
+			newNode("a", "v1.26.3"), newNode("b", "v1.26.3"), newNode("c", "v1.25.9"),
+		}
+		version, err := classification.GetKubeletConsensusVersion(nodes)
+		Expect(err).To(BeNil())
+		Expect(version).To(Equal("v1.26.3"))
+
+		nodes = []corev1.Node{
+			newNode("a", "v1.26.3"), newNode("b", "v1.25.9"), newNode("c", "v1.25.10"), newNode("d", "v1.26.3"),
+			newNode("e", "v1.25.10"),
+		}
+		version, err = classification.GetKubeletConsensusVersion(nodes)
+		Expect(err).To(BeNil())
+		Expect(version).To(Equal("v1.25.10"))
+
+		_, err = classification.GetKubeletConsensusVersion(nil)
+		Expect(err).ToNot(BeNil())
+	})
+
+	It("GetKubernetesVersion uses the API server by default", func() {
+		initializeManager(fake.NewClientBuilder().Build())
+
+		version, err := classification.GetManager().GetKubernetesVersion(context.TODO())
+		Expect(err).To(BeNil())
+		Expect(version).To(Equal("v1.25.3"))
+	})
+
+	It("GetKubernetesVersion uses nodes kubelet consensus", func() {
+		a := newNode("a", "v1.24.1+k3s1")
+		b := newNode("b", "v1.24.1+k3s1")
+		initializeManager(fake.NewClientBuilder().WithObjects(&a, &b).Build(),
+			classification.VersionProviderNodes)
+
+		version, err := classification.GetManager().GetKubernetesVersion(context.TODO())
+		Expect(err).To(BeNil())
+		Expect(version).To(Equal("v1.24.1+k3s1"))
+	})
+
+	It("GetKubernetesVersion falls back to next provider when an override is not set", func() {
+		kubeSystem := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}}
+		c := fake.NewClientBuilder().WithObjects(kubeSystem).Build()
+		initializeManager(c, classification.VersionProviderConfigMap, classification.VersionProviderLabel,
+			classification.VersionProviderAPIServer)
+
+		version, err := classification.GetManager().GetKubernetesVersion(context.TODO())
+		Expect(err).To(BeNil())
+		Expect(version).To(Equal("v1.25.3"))
+
+		kubeSystem.Labels = map[string]string{classification.KubernetesVersionLabel: "v1.27.0"}
+		Expect(c.Update(context.TODO(), kubeSystem)).To(Succeed())
+		version, err = classification.GetManager().GetKubernetesVersion(context.TODO())
+		Expect(err).To(BeNil())
+		Expect(version).To(Equal("v1.27.0"))
+
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: utils.ReportNamespace,
+				Name:      classification.KubernetesVersionConfigMap,
+			},
+			Data: map[string]string{"version": "v1.28.2"},
+		}
+		Expect(c.Create(context.TODO(), configMap)).To(Succeed())
+		version, err = classification.GetManager().GetKubernetesVersion(context.TODO())
+		Expect(err).To(BeNil())
+		Expect(version).To(Equal("v1.28.2"))
+	})
+
+	It("GetKubernetesVersion fails when no provider has a version", func() {
+		initializeManager(fake.NewClientBuilder().Build(), classification.VersionProviderNodes)
+
+		_, err := classification.GetManager().GetKubernetesVersion(context.TODO())
+		Expect(err).ToNot(BeNil())
+	})
+
+	It("WithVersionProvider adds a custom provider", func() {
+		initializeManager(fake.NewClientBuilder().Build())
+		classification.ApplyOptions(classification.WithVersionProvider(&staticVersionProvider{version: "v1.26.0"}))
+
+		version, err := classification.GetManager().GetKubernetesVersion(context.TODO())
+		Expect(err).To(BeNil())
+		Expect(version).To(Equal("v1.26.0"))
+	})
+})
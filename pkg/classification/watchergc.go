@@ -0,0 +1,160 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	logs "github.com/projectsveltos/libsveltos/lib/logsettings"
+)
+
+// watcherStopTimeout is how long a cancelled watcher has to exit before it is
+// considered a zombie
+const watcherStopTimeout = 30 * time.Second
+
+var (
+	runningWatchers = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "watchers_running",
+			Help:      "Number of watchers currently registered",
+		},
+	)
+
+	zombieWatchers = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "watchers_zombie",
+			Help:      "Number of cancelled watchers which did not exit within the stop timeout",
+		},
+	)
+
+	staleWatchers = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "watchers_stale_total",
+			Help:      "Number of registered watchers found exited and removed by watcher reconciliation",
+		},
+	)
+
+	leakedWatchers = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "watchers_leaked_total",
+			Help:      "Number of watchers for resources not needed anymore stopped by watcher reconciliation",
+		},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(runningWatchers, zombieWatchers, staleWatchers, leakedWatchers)
+}
+
+// watcher is a running watcher for a GVK
+type watcher struct {
+	cancel context.CancelFunc
+	// done is closed once the watcher goroutine exits
+	done chan struct{}
+}
+
+func newWatcher(cancel context.CancelFunc) *watcher {
+	return &watcher{cancel: cancel, done: make(chan struct{})}
+}
+
+// exited returns true if the watcher goroutine exited
+func (w *watcher) exited() bool {
+	select {
+	case <-w.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// stoppingWatcher is a cancelled watcher whose exit has not been verified yet
+type stoppingWatcher struct {
+	gvk       schema.GroupVersionKind
+	watcher   *watcher
+	stoppedAt time.Time
+}
+
+// stopWatcher cancels the watcher for gvk, if any, and removes it from the watchers.
+// Its exit is verified by reconcileWatchers. Must be called with m.mu held.
+func (m *manager) stopWatcher(gvk schema.GroupVersionKind, now time.Time) {
+	w, ok := m.watchers[gvk]
+	if !ok {
+		return
+	}
+	w.cancel()
+	delete(m.watchers, gvk)
+	m.stoppingWatchers = append(m.stoppingWatchers, stoppingWatcher{gvk: gvk, watcher: w, stoppedAt: now})
+}
+
+// reconcileWatchers reconciles the watchers map with the watchers actually running:
+//   - registered watchers which exited are removed, and resources to watch rebuilt
+//     so that a new watcher is started;
+//   - watchers for resources not to be watched anymore are stopped;
+//   - cancelled watchers not exited within watcherStopTimeout are reported as zombies.
+//
+// Must be called with m.mu held.
+func (m *manager) reconcileWatchers(now time.Time) {
+	wanted := make(map[schema.GroupVersionKind]bool, len(m.resourcesToWatch))
+	for i := range m.resourcesToWatch {
+		wanted[m.resourcesToWatch[i]] = true
+	}
+
+	for gvk, w := range m.watchers {
+		if w.exited() {
+			m.log.V(logs.LogInfo).Info(fmt.Sprintf("watcher for %s exited. Removing it", gvk.String()))
+			delete(m.watchers, gvk)
+			staleWatchers.Inc()
+			atomic.StoreUint32(&m.rebuildResourceToWatch, 1)
+			continue
+		}
+		if !wanted[gvk] {
+			m.log.V(logs.LogInfo).Info(fmt.Sprintf("watcher for %s not needed anymore. Stopping it", gvk.String()))
+			m.stopWatcher(gvk, now)
+			leakedWatchers.Inc()
+		}
+	}
+
+	zombies := 0
+	stopping := m.stoppingWatchers[:0]
+	for i := range m.stoppingWatchers {
+		s := m.stoppingWatchers[i]
+		if s.watcher.exited() {
+			continue
+		}
+		if now.Sub(s.stoppedAt) > watcherStopTimeout {
+			m.log.V(logs.LogInfo).Info(fmt.Sprintf("watcher for %s did not exit %s after being cancelled",
+				s.gvk.String(), now.Sub(s.stoppedAt).Round(time.Second)))
+			zombies++
+		}
+		stopping = append(stopping, s)
+	}
+	m.stoppingWatchers = stopping
+
+	runningWatchers.Set(float64(len(m.watchers)))
+	zombieWatchers.Set(float64(zombies))
+}
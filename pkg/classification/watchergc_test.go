@@ -0,0 +1,93 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/projectsveltos/classifier-agent/pkg/classification"
+)
+
+var _ = Describe("Manager: watchers reconciliation", func() {
+	podGVK := schema.GroupVersionKind{Version: "v1", Kind: "Pod"}
+	nodeGVK := schema.GroupVersionKind{Version: "v1", Kind: "Node"}
+
+	BeforeEach(func() {
+		classification.InitializeManagerWithObjects(nil)
+	})
+
+	It("stopWatcher removes the watcher and verifies it exits", func() {
+		manager := classification.GetManager()
+		classification.AddTestWatcher(podGVK, true)
+		classification.SetResourcesToWatch([]schema.GroupVersionKind{podGVK})
+
+		now := time.Now()
+		classification.StopWatcher(manager, podGVK, now)
+		Expect(classification.GetWatchers()).To(BeEmpty())
+
+		Eventually(func() int {
+			classification.ReconcileWatchers(manager, now)
+			return classification.GetStoppingWatchers()
+		}, time.Second, 10*time.Millisecond).Should(BeZero())
+		Expect(classification.GetZombieWatchers()).To(BeZero())
+	})
+
+	It("reconcileWatchers reports cancelled watchers which do not exit as zombies", func() {
+		manager := classification.GetManager()
+		exit := classification.AddTestWatcher(podGVK, false)
+
+		now := time.Now()
+		classification.StopWatcher(manager, podGVK, now)
+
+		classification.ReconcileWatchers(manager, now)
+		Expect(classification.GetStoppingWatchers()).To(Equal(1))
+		Expect(classification.GetZombieWatchers()).To(BeZero())
+
+		classification.ReconcileWatchers(manager, now.Add(time.Minute))
+		Expect(classification.GetStoppingWatchers()).To(Equal(1))
+		Expect(classification.GetZombieWatchers()).To(Equal(float64(1)))
+
+		exit()
+		Eventually(func() int {
+			classification.ReconcileWatchers(manager, now.Add(time.Minute))
+			return classification.GetStoppingWatchers()
+		}, time.Second, 10*time.Millisecond).Should(BeZero())
+		Expect(classification.GetZombieWatchers()).To(BeZero())
+	})
+
+	It("reconcileWatchers removes exited watchers and stops watchers not needed anymore", func() {
+		manager := classification.GetManager()
+		exit := classification.AddTestWatcher(podGVK, true)
+		classification.AddTestWatcher(nodeGVK, true)
+		classification.SetResourcesToWatch([]schema.GroupVersionKind{podGVK})
+
+		// Pod watcher exited on its own, Node is not to be watched anymore
+		exit()
+		Eventually(func() bool {
+			classification.ReconcileWatchers(manager, time.Now())
+			_, ok := classification.GetWatchers()[podGVK]
+			return ok
+		}, time.Second, 10*time.Millisecond).Should(BeFalse())
+
+		Expect(classification.GetWatchers()).To(BeEmpty())
+	})
+})
@@ -31,10 +31,11 @@ import (
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/dynamic/dynamicinformer"
 	"k8s.io/client-go/informers"
-	"k8s.io/client-go/restmapper"
 	"k8s.io/client-go/tools/cache"
 
 	"github.com/go-logr/logr"
+
+	"github.com/projectsveltos/classifier-agent/pkg/sharedwatch"
 	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
 	"github.com/projectsveltos/libsveltos/lib/logsettings"
 )
@@ -61,23 +62,28 @@ func (m *manager) buildResourceToWatch(ctx context.Context) {
 				// Updates watchers
 				err = m.updateWatchers(ctx, tmpResourceToWatch)
 				if err != nil {
+					m.mu.Unlock()
 					m.log.Error(err, "failed to update watchers")
 					atomic.StoreUint32(&m.rebuildResourceToWatch, 1)
 					continue
 				}
-				copy(m.resourcesToWatch, tmpResourceToWatch)
+				m.resourcesToWatch = tmpResourceToWatch
 			}
 			m.mu.Unlock()
 		}
 
+		m.mu.Lock()
+		m.reconcileWatchers(time.Now())
+		m.mu.Unlock()
+
 		// Sleep before next evaluation
-		time.Sleep(m.interval)
+		interval, _, _ := m.getIntervals()
+		time.Sleep(interval)
 	}
 }
 
 func (m *manager) buildList(ctx context.Context) (map[schema.GroupVersionKind]bool, error) {
-	classifiers := &libsveltosv1alpha1.ClassifierList{}
-	err := m.List(ctx, classifiers)
+	classifiers, err := m.ListClassifiers(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -98,14 +104,9 @@ func (m *manager) buildList(ctx context.Context) (map[schema.GroupVersionKind]bo
 func (m *manager) addGVKsForClassifier(classifier *libsveltosv1alpha1.Classifier,
 	resources map[schema.GroupVersionKind]bool) map[schema.GroupVersionKind]bool {
 
-	for i := range classifier.Spec.DeployedResourceConstraints {
-		resource := &classifier.Spec.DeployedResourceConstraints[i]
-		gvk := schema.GroupVersionKind{
-			Group:   resource.Group,
-			Kind:    resource.Kind,
-			Version: resource.Version,
-		}
-		resources[gvk] = true
+	gvks := GetClassifierGVKs(classifier)
+	for i := range gvks {
+		resources[gvks[i]] = true
 	}
 
 	return resources
@@ -145,6 +146,10 @@ func (m *manager) updateWatchers(ctx context.Context, resourceToWatch []schema.G
 		return err
 	}
 
+	// unknownResourcesToWatch is rebuilt every time so resources not needed anymore
+	// are not watched when their CustomResourceDefinition is eventually installed
+	m.unknownResourcesToWatch = make([]schema.GroupVersionKind, 0)
+
 	currentResourcesToWatch := make(map[schema.GroupVersionKind]bool)
 	for i := range resourceToWatch {
 		gvk := &resourceToWatch[i]
@@ -165,12 +170,10 @@ func (m *manager) updateWatchers(ctx context.Context, resourceToWatch []schema.G
 
 	// Cancel all watchers we are not interested in anymore
 	for i := range m.resourcesToWatch {
-		gvk := &m.resourcesToWatch[i]
-		if _, ok := currentResourcesToWatch[*gvk]; !ok {
+		gvk := m.resourcesToWatch[i]
+		if _, ok := currentResourcesToWatch[gvk]; !ok {
 			m.log.V(logsettings.LogInfo).Info(fmt.Sprintf("close watcher for %s", gvk.String()))
-			cancel := m.watchers[*gvk]
-			cancel()
-			m.resourcesToWatch = remove(m.resourcesToWatch, i)
+			m.stopWatcher(gvk, time.Now())
 		}
 	}
 
@@ -239,6 +242,10 @@ func (m *manager) startWatcher(ctx context.Context, gvk *schema.GroupVersionKind
 		return nil
 	}
 
+	if m.sharedWatches != nil {
+		return m.startSharedWatcher(ctx, gvk, react, logger)
+	}
+
 	logger.V(logsettings.LogInfo).Info("start watcher")
 	// dynamic informer needs to be told which type to watch
 	dcinformer, err := m.getDynamicInformer(gvk)
@@ -248,8 +255,12 @@ func (m *manager) startWatcher(ctx context.Context, gvk *schema.GroupVersionKind
 	}
 
 	watcherCtx, cancel := context.WithCancel(ctx)
-	m.watchers[*gvk] = cancel
-	go m.runInformer(watcherCtx.Done(), dcinformer.Informer(), gvk, react, logger)
+	w := newWatcher(cancel)
+	m.watchers[*gvk] = w
+	go func() {
+		defer close(w.done)
+		m.runInformer(watcherCtx.Done(), dcinformer.Informer(), gvk, react, logger)
+	}()
 	return nil
 }
 
@@ -267,12 +278,10 @@ func (m *manager) getDynamicInformer(gvk *schema.GroupVersionKind) (informers.Ge
 		nil,
 	)
 
-	dc := discovery.NewDiscoveryClientForConfigOrDie(m.config)
-	groupResources, err := restmapper.GetAPIGroupResources(dc)
+	mapper, err := m.getRESTMapper()
 	if err != nil {
 		return nil, err
 	}
-	mapper := restmapper.NewDiscoveryRESTMapper(groupResources)
 
 	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
 	if err != nil {
@@ -291,24 +300,73 @@ func (m *manager) getDynamicInformer(gvk *schema.GroupVersionKind) (informers.Ge
 	return informer, nil
 }
 
-func (m *manager) runInformer(stopCh <-chan struct{}, s cache.SharedIndexInformer,
-	gvk *schema.GroupVersionKind, react ReactToNotification, logger logr.Logger) {
+// startSharedWatcher subscribes to the shared watch for gvk. Informer is
+// created only if no other agent in this process already watches gvk.
+func (m *manager) startSharedWatcher(ctx context.Context, gvk *schema.GroupVersionKind,
+	react ReactToNotification, logger logr.Logger) error {
+
+	logger.V(logsettings.LogInfo).Info("subscribe to shared watcher")
+	key := sharedwatch.Key{Cluster: m.config.Host, GVK: *gvk}
+	newInformer := func() (cache.SharedIndexInformer, error) {
+		dcinformer, err := m.getDynamicInformer(gvk)
+		if err != nil {
+			return nil, err
+		}
+		return dcinformer.Informer(), nil
+	}
+
+	unsubscribe, err := m.sharedWatches.Subscribe(key, newInformer, m.getEventHandlers(gvk, react, logger))
+	if err != nil {
+		logger.Error(err, "Failed to subscribe to shared watcher")
+		return err
+	}
 
-	handlers := cache.ResourceEventHandlerFuncs{
+	watcherCtx, cancel := context.WithCancel(ctx)
+	w := newWatcher(cancel)
+	m.watchers[*gvk] = w
+	go func() {
+		defer close(w.done)
+		<-watcherCtx.Done()
+		unsubscribe()
+	}()
+	return nil
+}
+
+func (m *manager) getEventHandlers(gvk *schema.GroupVersionKind, react ReactToNotification,
+	logger logr.Logger) cache.ResourceEventHandlerFuncs {
+
+	return cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
 			logger.V(logsettings.LogDebug).Info("got add notification")
+			m.recordWatchEvent()
+			if *gvk == eventGVK {
+				m.recordEvent(obj)
+			}
+			m.invalidateDiscoveryCache(gvk)
 			react(gvk)
 		},
 		DeleteFunc: func(obj interface{}) {
 			logger.V(logsettings.LogDebug).Info("got delete notification")
+			m.recordWatchEvent()
+			m.invalidateDiscoveryCache(gvk)
 			react(gvk)
 		},
 		UpdateFunc: func(oldObj, newObj interface{}) {
 			logger.V(logsettings.LogDebug).Info("got update notification")
+			m.recordWatchEvent()
+			if *gvk == eventGVK {
+				m.recordEvent(newObj)
+			}
+			m.invalidateDiscoveryCache(gvk)
 			react(gvk)
 		},
 	}
-	s.AddEventHandler(handlers)
+}
+
+func (m *manager) runInformer(stopCh <-chan struct{}, s cache.SharedIndexInformer,
+	gvk *schema.GroupVersionKind, react ReactToNotification, logger logr.Logger) {
+
+	s.AddEventHandler(m.getEventHandlers(gvk, react, logger))
 	s.Run(stopCh)
 }
 
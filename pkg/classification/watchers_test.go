@@ -229,4 +229,22 @@ var _ = Describe("Manager: watchers", func() {
 		Expect(len(unknown)).To(Equal(1))
 		Expect(unknown[0].Kind).To(Equal(debuggingConfigurations.Kind))
 	})
+	It("startWatcherIfNeeded starts a watcher for resources previously not installed", func() {
+		classification.InitializeManager(watcherCtx, klogr.New(), testEnv.Config, testEnv.Client,
+			randomString(), randomString(), libsveltosv1alpha1.ClusterTypeCapi, nil, 10, false)
+		manager := classification.GetManager()
+
+		gvk := schema.GroupVersionKind{Group: classifiers.Group, Version: classifiers.Version, Kind: classifiers.Kind}
+		classification.SetUnknownResourcesToWatch([]schema.GroupVersionKind{gvk})
+
+		classification.StartWatcherIfNeeded(manager, watcherCtx, &gvk)
+
+		watchers := classification.GetWatchers()
+		Expect(watchers).ToNot(BeNil())
+		cancel, ok := watchers[gvk]
+		Expect(ok).To(BeTrue())
+		cancel()
+
+		Expect(classification.GetUnknownResourcesToWatch()).To(BeEmpty())
+	})
 })
@@ -0,0 +1,221 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package classification
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"emperror.dev/errors"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
+)
+
+// WebhookType is the type of admission webhook configuration
+type WebhookType string
+
+const (
+	WebhookValidating = WebhookType("Validating")
+	WebhookMutating   = WebhookType("Mutating")
+)
+
+var (
+	validatingWebhookGVK = admissionregistrationv1.SchemeGroupVersion.WithKind("ValidatingWebhookConfiguration")
+	mutatingWebhookGVK   = admissionregistrationv1.SchemeGroupVersion.WithKind("MutatingWebhookConfiguration")
+)
+
+// WebhookConstraint is satisfied when at least one admission webhook configuration
+// matches it, or, when Absent is set, when none does. A configuration matches when
+// its name matches NamePattern and one of its webhooks calls the service identified
+// by ServiceNamespace and ServiceName. Unset fields match any configuration.
+type WebhookConstraint struct {
+	// Type is Validating or Mutating. Both types when empty.
+	// +optional
+	Type WebhookType `json:"type,omitempty"`
+
+	// NamePattern is a regular expression (RE2 syntax) the whole configuration
+	// name must match, for instance "kyverno-.*"
+	// +optional
+	NamePattern string `json:"namePattern,omitempty"`
+
+	// ServiceNamespace is the namespace of the service a webhook calls
+	// +optional
+	ServiceNamespace string `json:"serviceNamespace,omitempty"`
+
+	// ServiceName is the name of the service a webhook calls
+	// +optional
+	ServiceName string `json:"serviceName,omitempty"`
+
+	// Absent, when set, requires no configuration to match, for instance to
+	// detect a conflicting policy engine
+	// +optional
+	Absent bool `json:"absent,omitempty"`
+}
+
+// validateWebhookConstraint returns an error if c is not valid
+func validateWebhookConstraint(c *WebhookConstraint) error {
+	if c.Type != "" && c.Type != WebhookValidating && c.Type != WebhookMutating {
+		return fmt.Errorf("type must be %s or %s, got %q", WebhookValidating, WebhookMutating, c.Type)
+	}
+	if c.NamePattern == "" && c.ServiceNamespace == "" && c.ServiceName == "" {
+		return errors.New("at least one of namePattern, serviceNamespace and serviceName is required")
+	}
+	return nil
+}
+
+// webhookConfiguration is the part of a Validating or MutatingWebhookConfiguration
+// used for classification
+type webhookConfiguration struct {
+	kind      string
+	name      string
+	services  []*admissionregistrationv1.ServiceReference
+	caBundles [][]byte
+}
+
+// areWebhooksAMatch returns true if all WebhookConstraints are satisfied
+func (m *manager) areWebhooksAMatch(ctx context.Context, classifier *libsveltosv1alpha1.Classifier) (bool, error) {
+	extension, err := getClassifierExtension(classifier)
+	if err != nil {
+		return false, err
+	}
+
+	if len(extension.WebhookConstraints) == 0 {
+		return true, nil
+	}
+
+	configurations, err := m.getWebhookConfigurations(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	for i := range extension.WebhookConstraints {
+		constraint := &extension.WebhookConstraints[i]
+		if err := validateWebhookConstraint(constraint); err != nil {
+			return false, newInvalidClassifierError(errors.Wrap(err, fmt.Sprintf("invalid webhook constraint %d", i)))
+		}
+
+		var namePattern *regexp.Regexp
+		if constraint.NamePattern != "" {
+			namePattern, err = m.getRegex(classifier, constraint.NamePattern)
+			if err != nil {
+				return false, newInvalidClassifierError(err)
+			}
+		}
+
+		matching := getMatchingWebhookConfiguration(constraint, namePattern, configurations)
+		if constraint.Absent {
+			if matching != nil {
+				addEvaluationNote(ctx, fmt.Sprintf("webhook constraint %d: %s %s is present",
+					i, matching.kind, matching.name))
+				return false, nil
+			}
+			continue
+		}
+		if matching == nil {
+			addEvaluationNote(ctx, fmt.Sprintf("webhook constraint %d: no webhook configuration matches", i))
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// getMatchingWebhookConfiguration returns the first configuration matching constraint,
+// nil if none does. namePattern is the compiled constraint NamePattern, nil when not set.
+func getMatchingWebhookConfiguration(constraint *WebhookConstraint, namePattern *regexp.Regexp,
+	configurations []webhookConfiguration) *webhookConfiguration {
+
+	for i := range configurations {
+		configuration := &configurations[i]
+		if constraint.Type != "" && configuration.kind != string(constraint.Type)+"WebhookConfiguration" {
+			continue
+		}
+		if namePattern != nil && !namePattern.MatchString(configuration.name) {
+			continue
+		}
+		if isWebhookServiceAMatch(constraint, configuration.services) {
+			return configuration
+		}
+	}
+	return nil
+}
+
+// isWebhookServiceAMatch returns true if one of services matches constraint
+// ServiceNamespace and ServiceName
+func isWebhookServiceAMatch(constraint *WebhookConstraint, services []*admissionregistrationv1.ServiceReference) bool {
+	if constraint.ServiceNamespace == "" && constraint.ServiceName == "" {
+		return true
+	}
+	for _, service := range services {
+		// Webhooks called by URL have no service
+		if service == nil {
+			continue
+		}
+		if (constraint.ServiceNamespace == "" || service.Namespace == constraint.ServiceNamespace) &&
+			(constraint.ServiceName == "" || service.Name == constraint.ServiceName) {
+
+			return true
+		}
+	}
+	return false
+}
+
+// getWebhookConfigurations returns all Validating and MutatingWebhookConfigurations
+func (m *manager) getWebhookConfigurations(ctx context.Context) ([]webhookConfiguration, error) {
+	validating := &admissionregistrationv1.ValidatingWebhookConfigurationList{}
+	if err := m.List(ctx, validating); err != nil {
+		return nil, err
+	}
+	mutating := &admissionregistrationv1.MutatingWebhookConfigurationList{}
+	if err := m.List(ctx, mutating); err != nil {
+		return nil, err
+	}
+
+	configurations := make([]webhookConfiguration, 0, len(validating.Items)+len(mutating.Items))
+	for i := range validating.Items {
+		configuration := webhookConfiguration{kind: validatingWebhookGVK.Kind, name: validating.Items[i].Name}
+		for j := range validating.Items[i].Webhooks {
+			configuration.services = append(configuration.services, validating.Items[i].Webhooks[j].ClientConfig.Service)
+			configuration.caBundles = append(configuration.caBundles, validating.Items[i].Webhooks[j].ClientConfig.CABundle)
+		}
+		configurations = append(configurations, configuration)
+	}
+	for i := range mutating.Items {
+		configuration := webhookConfiguration{kind: mutatingWebhookGVK.Kind, name: mutating.Items[i].Name}
+		for j := range mutating.Items[i].Webhooks {
+			configuration.services = append(configuration.services, mutating.Items[i].Webhooks[j].ClientConfig.Service)
+			configuration.caBundles = append(configuration.caBundles, mutating.Items[i].Webhooks[j].ClientConfig.CABundle)
+		}
+		configurations = append(configurations, configuration)
+	}
+	return configurations, nil
+}
+
+// getWebhookGVKs returns the GVKs of the webhook configurations constraint inspects
+func getWebhookGVKs(constraint *WebhookConstraint) []schema.GroupVersionKind {
+	switch constraint.Type {
+	case WebhookValidating:
+		return []schema.GroupVersionKind{validatingWebhookGVK}
+	case WebhookMutating:
+		return []schema.GroupVersionKind{mutatingWebhookGVK}
+	default:
+		return []schema.GroupVersionKind{validatingWebhookGVK, mutatingWebhookGVK}
+	}
+}
@@ -0,0 +1,176 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package explanation defines the payload classifier-agent attaches to a
+// ClassifierReport to explain why a cluster is, or is not, a match for a Classifier.
+//
+// The payload is versioned. Within a version the following is guaranteed:
+//   - fields are never removed, renamed or changed in type;
+//   - new optional fields and new check types may be added.
+//
+// Consumers must therefore ignore unknown fields and unknown check types.
+// Any incompatible change is published under a new version, and Decode
+// returns ErrUnsupportedVersion for versions this package does not know.
+package explanation
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const (
+	// Annotation is the ClassifierReport annotation containing the JSON
+	// encoded Explanation
+	Annotation = "classifier.projectsveltos.io/explanation"
+
+	// Version is the explanation version produced by this package
+	Version = "v1"
+)
+
+// ErrUnsupportedVersion is returned when decoding an explanation whose
+// version is not known by this package
+var ErrUnsupportedVersion = errors.New("unsupported explanation version")
+
+//go:embed v1.schema.json
+var schemaV1 []byte
+
+// CheckType identifies a step of the Classifier evaluation
+type CheckType string
+
+const (
+	CheckKubernetesVersion     = CheckType("KubernetesVersion")
+	CheckCloudProvider         = CheckType("CloudProvider")
+	CheckContainerRuntime      = CheckType("ContainerRuntime")
+	CheckCNI                   = CheckType("CNI")
+	CheckServiceMesh           = CheckType("ServiceMesh")
+	CheckFacts                 = CheckType("Facts")
+	CheckHelmRelease           = CheckType("HelmRelease")
+	CheckOLMOperator           = CheckType("OLMOperator")
+	CheckStorageClass          = CheckType("StorageClass")
+	CheckIngressClass          = CheckType("IngressClass")
+	CheckConfigData            = CheckType("ConfigData")
+	CheckPodSecurity           = CheckType("PodSecurity")
+	CheckAPIResource           = CheckType("APIResource")
+	CheckAPIService            = CheckType("APIService")
+	CheckAPIServerFeature      = CheckType("APIServerFeature")
+	CheckAPIServerFlags        = CheckType("APIServerFlags")
+	CheckCRD                   = CheckType("CRD")
+	CheckWebhook               = CheckType("Webhook")
+	CheckCertificateExpiration = CheckType("CertificateExpiration")
+	CheckDeployedResource      = CheckType("DeployedResource")
+	CheckEvents                = CheckType("Events")
+	CheckUtilization           = CheckType("Utilization")
+	CheckPrometheus            = CheckType("Prometheus")
+	CheckMetrics               = CheckType("Metrics")
+)
+
+// Check is the outcome of one evaluation step
+type Check struct {
+	// Type identifies the evaluation step
+	Type CheckType `json:"type"`
+
+	// Satisfied is true if the cluster passed this step
+	Satisfied bool `json:"satisfied"`
+
+	// Reason is a human readable message. Set when the step
+	// is not satisfied.
+	Reason string `json:"reason,omitempty"`
+
+	// Notes report how the step was evaluated, for instance how many
+	// resources were missing a filtered field and how those were treated
+	Notes []string `json:"notes,omitempty"`
+}
+
+// Explanation describes how a Classifier was evaluated
+type Explanation struct {
+	// Version of the explanation payload
+	Version string `json:"version"`
+
+	// Match reports whether the cluster is a match for the Classifier
+	Match bool `json:"match"`
+
+	// Checks lists the evaluation steps in the order they were run.
+	// Evaluation stops at the first step which is not satisfied, so
+	// steps after that one are not listed.
+	Checks []Check `json:"checks"`
+}
+
+// Failed returns the first check which is not satisfied, if any
+func (e *Explanation) Failed() (Check, bool) {
+	for i := range e.Checks {
+		if !e.Checks[i].Satisfied {
+			return e.Checks[i], true
+		}
+	}
+	return Check{}, false
+}
+
+// Encode returns the JSON encoding of the explanation, stamped with Version
+func Encode(e *Explanation) ([]byte, error) {
+	encoded := *e
+	encoded.Version = Version
+	if encoded.Checks == nil {
+		encoded.Checks = []Check{}
+	}
+	return json.Marshal(&encoded)
+}
+
+// Decode parses a JSON encoded explanation.
+// Unknown fields are ignored. ErrUnsupportedVersion is returned if
+// the payload version is not known.
+func Decode(data []byte) (*Explanation, error) {
+	header := struct {
+		Version string `json:"version"`
+	}{}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, fmt.Errorf("failed to parse explanation: %w", err)
+	}
+
+	switch header.Version {
+	case Version:
+		e := &Explanation{}
+		if err := json.Unmarshal(data, e); err != nil {
+			return nil, fmt.Errorf("failed to parse explanation %s: %w", header.Version, err)
+		}
+		return e, nil
+	case "":
+		return nil, fmt.Errorf("%w: version is not set", ErrUnsupportedVersion)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedVersion, header.Version)
+	}
+}
+
+// FromAnnotations decodes the explanation stored in the Annotation of a
+// ClassifierReport. Returns nil if annotation is not set.
+func FromAnnotations(annotations map[string]string) (*Explanation, error) {
+	value, ok := annotations[Annotation]
+	if !ok || value == "" {
+		return nil, nil
+	}
+	return Decode([]byte(value))
+}
+
+// Schema returns the JSON schema of the given explanation version
+func Schema(version string) ([]byte, error) {
+	switch version {
+	case Version:
+		return schemaV1, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedVersion, version)
+	}
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package explanation_test
+
+import (
+	"encoding/json"
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectsveltos/classifier-agent/pkg/explanation"
+)
+
+var _ = Describe("Explanation", func() {
+	It("Encode and Decode round trip", func() {
+		e := &explanation.Explanation{
+			Match: false,
+			Checks: []explanation.Check{
+				{Type: explanation.CheckKubernetesVersion, Satisfied: true},
+				{Type: explanation.CheckCRD, Satisfied: false, Reason: "custom resource definitions are not a match"},
+			},
+		}
+
+		data, err := explanation.Encode(e)
+		Expect(err).To(BeNil())
+
+		decoded, err := explanation.Decode(data)
+		Expect(err).To(BeNil())
+		Expect(decoded.Version).To(Equal(explanation.Version))
+		Expect(decoded.Match).To(BeFalse())
+		Expect(decoded.Checks).To(Equal(e.Checks))
+
+		failed, ok := decoded.Failed()
+		Expect(ok).To(BeTrue())
+		Expect(failed.Type).To(Equal(explanation.CheckCRD))
+	})
+
+	It("Decode ignores unknown fields and check types", func() {
+		data := []byte(`{"version":"v1","match":true,"newField":{"a":1},
+			"checks":[{"type":"SomethingNew","satisfied":true,"details":["x"]}]}`)
+
+		decoded, err := explanation.Decode(data)
+		Expect(err).To(BeNil())
+		Expect(decoded.Match).To(BeTrue())
+		Expect(len(decoded.Checks)).To(Equal(1))
+		Expect(decoded.Checks[0].Type).To(Equal(explanation.CheckType("SomethingNew")))
+
+		_, ok := decoded.Failed()
+		Expect(ok).To(BeFalse())
+	})
+
+	It("Decode returns ErrUnsupportedVersion for unknown or missing version", func() {
+		_, err := explanation.Decode([]byte(`{"version":"v2","match":true,"checks":[]}`))
+		Expect(errors.Is(err, explanation.ErrUnsupportedVersion)).To(BeTrue())
+
+		_, err = explanation.Decode([]byte(`{"match":true,"checks":[]}`))
+		Expect(errors.Is(err, explanation.ErrUnsupportedVersion)).To(BeTrue())
+
+		_, err = explanation.Decode([]byte(`not json`))
+		Expect(err).ToNot(BeNil())
+		Expect(errors.Is(err, explanation.ErrUnsupportedVersion)).To(BeFalse())
+	})
+
+	It("FromAnnotations returns nil when annotation is not set", func() {
+		e, err := explanation.FromAnnotations(map[string]string{"foo": "bar"})
+		Expect(err).To(BeNil())
+		Expect(e).To(BeNil())
+
+		data, err := explanation.Encode(&explanation.Explanation{Match: true})
+		Expect(err).To(BeNil())
+		e, err = explanation.FromAnnotations(map[string]string{explanation.Annotation: string(data)})
+		Expect(err).To(BeNil())
+		Expect(e.Match).To(BeTrue())
+		Expect(e.Checks).ToNot(BeNil())
+	})
+
+	It("Schema returns the v1 schema", func() {
+		schema, err := explanation.Schema(explanation.Version)
+		Expect(err).To(BeNil())
+
+		parsed := map[string]interface{}{}
+		Expect(json.Unmarshal(schema, &parsed)).To(Succeed())
+		Expect(parsed["required"]).To(ConsistOf("version", "match", "checks"))
+
+		_, err = explanation.Schema("v0")
+		Expect(errors.Is(err, explanation.ErrUnsupportedVersion)).To(BeTrue())
+	})
+})
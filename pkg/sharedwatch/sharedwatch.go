@@ -0,0 +1,205 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package sharedwatch lets agents running in the same process share watches.
+// When classifier-agent is embedded alongside other Sveltos agents, each agent
+// subscribing to the same Registry gets notified by a single informer per
+// cluster and GroupVersionKind, instead of each opening its own watch.
+package sharedwatch
+
+import (
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Key identifies a shared watch
+type Key struct {
+	// Cluster identifies the watched cluster, typically its API server host
+	Cluster string
+
+	// GVK is the watched GroupVersionKind
+	GVK schema.GroupVersionKind
+}
+
+// InformerFactory creates the informer backing a shared watch.
+// It is invoked only by the first subscriber to a Key.
+type InformerFactory func() (cache.SharedIndexInformer, error)
+
+// Registry keeps one informer per Key and fans its notifications out to
+// all subscribers
+type Registry struct {
+	mu      *sync.Mutex
+	watches map[Key]*sharedWatch
+	nextID  int
+}
+
+var defaultRegistry = NewRegistry()
+
+// Default returns the process wide Registry
+func Default() *Registry {
+	return defaultRegistry
+}
+
+// NewRegistry returns an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{
+		mu:      &sync.Mutex{},
+		watches: make(map[Key]*sharedWatch),
+	}
+}
+
+// Subscribe registers handler for notifications about key. The informer is
+// created with newInformer and started if key is not watched yet.
+// When joining an existing watch, handler is first notified an add for each
+// object already known to the informer, so it may see some objects added twice.
+// Returned function unsubscribes handler; the informer is stopped once its
+// last subscriber is gone.
+func (r *Registry) Subscribe(key Key, newInformer InformerFactory,
+	handler cache.ResourceEventHandlerFuncs) (func(), error) {
+
+	r.mu.Lock()
+	w, ok := r.watches[key]
+	if !ok {
+		informer, err := newInformer()
+		if err != nil {
+			r.mu.Unlock()
+			return nil, err
+		}
+		w = newSharedWatch(informer)
+		r.watches[key] = w
+		go informer.Run(w.stopCh)
+	}
+	id := r.nextID
+	r.nextID++
+	w.addHandler(id, handler)
+	r.mu.Unlock()
+
+	if ok && handler.AddFunc != nil {
+		objects := w.informer.GetStore().List()
+		for i := range objects {
+			handler.AddFunc(objects[i])
+		}
+	}
+
+	once := &sync.Once{}
+	return func() {
+		once.Do(func() { r.unsubscribe(key, id) })
+	}, nil
+}
+
+// Subscribers returns, for each watched Key, the number of subscribers
+func (r *Registry) Subscribers() map[Key]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	subscribers := make(map[Key]int, len(r.watches))
+	for key, w := range r.watches {
+		subscribers[key] = w.len()
+	}
+	return subscribers
+}
+
+func (r *Registry) unsubscribe(key Key, id int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	w, ok := r.watches[key]
+	if !ok {
+		return
+	}
+
+	if w.removeHandler(id) == 0 {
+		close(w.stopCh)
+		delete(r.watches, key)
+	}
+}
+
+// sharedWatch is an informer along with its subscribers
+type sharedWatch struct {
+	informer cache.SharedIndexInformer
+	stopCh   chan struct{}
+
+	mu       *sync.RWMutex
+	handlers map[int]cache.ResourceEventHandlerFuncs
+}
+
+func newSharedWatch(informer cache.SharedIndexInformer) *sharedWatch {
+	w := &sharedWatch{
+		informer: informer,
+		stopCh:   make(chan struct{}),
+		mu:       &sync.RWMutex{},
+		handlers: make(map[int]cache.ResourceEventHandlerFuncs),
+	}
+
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			for _, h := range w.getHandlers() {
+				if h.AddFunc != nil {
+					h.AddFunc(obj)
+				}
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			for _, h := range w.getHandlers() {
+				if h.UpdateFunc != nil {
+					h.UpdateFunc(oldObj, newObj)
+				}
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			for _, h := range w.getHandlers() {
+				if h.DeleteFunc != nil {
+					h.DeleteFunc(obj)
+				}
+			}
+		},
+	})
+
+	return w
+}
+
+func (w *sharedWatch) getHandlers() []cache.ResourceEventHandlerFuncs {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	handlers := make([]cache.ResourceEventHandlerFuncs, 0, len(w.handlers))
+	for _, h := range w.handlers {
+		handlers = append(handlers, h)
+	}
+	return handlers
+}
+
+func (w *sharedWatch) addHandler(id int, handler cache.ResourceEventHandlerFuncs) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.handlers[id] = handler
+}
+
+// removeHandler removes a subscriber and returns the number of remaining ones
+func (w *sharedWatch) removeHandler(id int) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.handlers, id)
+	return len(w.handlers)
+}
+
+func (w *sharedWatch) len() int {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return len(w.handlers)
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sharedwatch_test
+
+import (
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/projectsveltos/classifier-agent/pkg/sharedwatch"
+)
+
+const (
+	timeout         = 10 * time.Second
+	pollingInterval = 50 * time.Millisecond
+)
+
+var _ = Describe("Registry", func() {
+	var fakeWatcher *watch.FakeWatcher
+	var informersCreated int32
+	var newInformer sharedwatch.InformerFactory
+
+	key := sharedwatch.Key{
+		Cluster: "https://127.0.0.1:6443",
+		GVK:     schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"},
+	}
+
+	BeforeEach(func() {
+		fakeWatcher = watch.NewFake()
+		atomic.StoreInt32(&informersCreated, 0)
+		newInformer = func() (cache.SharedIndexInformer, error) {
+			atomic.AddInt32(&informersCreated, 1)
+			lw := &cache.ListWatch{
+				ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+					return &corev1.ConfigMapList{}, nil
+				},
+				WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+					return fakeWatcher, nil
+				},
+			}
+			return cache.NewSharedIndexInformer(lw, &corev1.ConfigMap{}, 0, cache.Indexers{}), nil
+		}
+	})
+
+	countAdds := func(counter *int32) cache.ResourceEventHandlerFuncs {
+		return cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) { atomic.AddInt32(counter, 1) },
+		}
+	}
+
+	It("shares one informer between subscribers of the same key", func() {
+		registry := sharedwatch.NewRegistry()
+
+		var first, second int32
+		unsubscribeFirst, err := registry.Subscribe(key, newInformer, countAdds(&first))
+		Expect(err).To(BeNil())
+		unsubscribeSecond, err := registry.Subscribe(key, newInformer, countAdds(&second))
+		Expect(err).To(BeNil())
+
+		Expect(atomic.LoadInt32(&informersCreated)).To(Equal(int32(1)))
+		Expect(registry.Subscribers()).To(HaveKeyWithValue(key, 2))
+
+		fakeWatcher.Add(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "a"}})
+		Eventually(func() bool {
+			return atomic.LoadInt32(&first) == 1 && atomic.LoadInt32(&second) == 1
+		}, timeout, pollingInterval).Should(BeTrue())
+
+		// Unsubscribing twice has no effect
+		unsubscribeFirst()
+		unsubscribeFirst()
+		Expect(registry.Subscribers()).To(HaveKeyWithValue(key, 1))
+
+		unsubscribeSecond()
+		Expect(registry.Subscribers()).To(BeEmpty())
+	})
+
+	It("replays known objects to late subscribers", func() {
+		registry := sharedwatch.NewRegistry()
+
+		var first, late int32
+		unsubscribe, err := registry.Subscribe(key, newInformer, countAdds(&first))
+		Expect(err).To(BeNil())
+		defer unsubscribe()
+
+		fakeWatcher.Add(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "a"}})
+		Eventually(func() int32 {
+			return atomic.LoadInt32(&first)
+		}, timeout, pollingInterval).Should(Equal(int32(1)))
+
+		unsubscribeLate, err := registry.Subscribe(key, newInformer, countAdds(&late))
+		Expect(err).To(BeNil())
+		defer unsubscribeLate()
+		Expect(atomic.LoadInt32(&late)).To(Equal(int32(1)))
+	})
+
+	It("stops informers without subscribers and keeps one informer per key", func() {
+		registry := sharedwatch.NewRegistry()
+
+		var adds int32
+		unsubscribe, err := registry.Subscribe(key, newInformer, countAdds(&adds))
+		Expect(err).To(BeNil())
+		unsubscribe()
+
+		otherKey := key
+		otherKey.Cluster = "https://10.0.0.1:6443"
+		unsubscribeOther, err := registry.Subscribe(otherKey, newInformer, countAdds(&adds))
+		Expect(err).To(BeNil())
+		defer unsubscribeOther()
+		Expect(atomic.LoadInt32(&informersCreated)).To(Equal(int32(2)))
+		Expect(registry.Subscribers()).To(HaveLen(1))
+	})
+})
@@ -31,12 +31,12 @@ import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
 
-	"github.com/projectsveltos/classifier-agent/pkg/utils"
+	"github.com/projectsveltos/classifier-agent/internal/utils"
 	libsveltosv1alpha1 "github.com/projectsveltos/libsveltos/api/v1alpha1"
 	libsveltosutils "github.com/projectsveltos/libsveltos/lib/utils"
 )
 
-// marking test serial as it requires pod to be restarted
+// marking test serial as it installs a CustomResourceDefinition watched by a Classifier
 var _ = Describe("Classification: crd", Serial, func() {
 	var key string
 	var value string